@@ -0,0 +1,530 @@
+// Package client is a minimal Go client of Centrifugo bidirectional
+// protocol over WebSocket with JSON encoding. It's maintained together with
+// server so protocol changes are tested end-to-end, and can be used by Go
+// applications and services which need real-time messages from Centrifugo.
+//
+// Client does not reconnect automatically: when connection closed Done
+// channel closed and Err returns reason. To continue from the same place
+// application connects again and subscribes with SubscribeOptions.Since set
+// to StreamPosition of previous Subscription.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/protocol"
+	"github.com/gorilla/websocket"
+)
+
+// DefaultPublicationBufferSize is a default value for
+// SubscribeOptions.BufferSize.
+const DefaultPublicationBufferSize = 128
+
+// ErrClosed returned when calling methods of closed client.
+var ErrClosed = errors.New("client closed")
+
+// Error is an error replied by server.
+type Error struct {
+	Code    uint32
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Message)
+}
+
+// Disconnect describes why server closed connection.
+type Disconnect struct {
+	Code      int
+	Reason    string
+	Reconnect bool
+}
+
+func (d *Disconnect) Error() string {
+	return fmt.Sprintf("disconnected: code: %d, reason: %s, reconnect: %t", d.Code, d.Reason, d.Reconnect)
+}
+
+// StreamPosition in channel history stream.
+type StreamPosition struct {
+	Offset uint64
+	Epoch  string
+}
+
+// Publication received from channel.
+type Publication struct {
+	Channel string
+	Data    []byte
+	Offset  uint64
+	Info    *protocol.ClientInfo
+}
+
+// Config of Client.
+type Config struct {
+	// Token is a connection JWT.
+	Token string
+	// Data sent in connect command, must be valid JSON if set.
+	Data []byte
+	// Name and Version of client application.
+	Name    string
+	Version string
+	// Header sent in WebSocket upgrade request.
+	Header http.Header
+	// Dialer to establish WebSocket connection, websocket.DefaultDialer by
+	// default.
+	Dialer *websocket.Dialer
+}
+
+// Client is a connection to Centrifugo.
+type Client struct {
+	conn   *websocket.Conn
+	result *protocol.ConnectResult
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  uint32
+	pending map[uint32]chan *protocol.Reply
+	subs    map[string]*Subscription
+	err     error
+	done    chan struct{}
+}
+
+// Connect dials Centrifugo WebSocket endpoint, for example
+// ws://localhost:8000/connection/websocket, and sends connect command.
+func Connect(ctx context.Context, url string, c Config) (*Client, error) {
+	dialer := c.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	conn, _, err := dialer.DialContext(ctx, url, c.Header)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{
+		conn:    conn,
+		pending: make(map[uint32]chan *protocol.Reply),
+		subs:    make(map[string]*Subscription),
+		done:    make(chan struct{}),
+	}
+	go client.reader()
+
+	var result protocol.ConnectResult
+	err = client.call(ctx, protocol.Command_CONNECT, &protocol.ConnectRequest{
+		Token:   c.Token,
+		Data:    c.Data,
+		Name:    c.Name,
+		Version: c.Version,
+	}, &result)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	client.result = &result
+	return client, nil
+}
+
+// ID of client connection set by server.
+func (c *Client) ID() string {
+	return c.result.Client
+}
+
+// ConnectResult returns result of connect command.
+func (c *Client) ConnectResult() *protocol.ConnectResult {
+	return c.result
+}
+
+// Done closed when connection closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns reason of closing connection, *Disconnect if server
+// disconnected client. Returns nil while connection open.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close connection.
+func (c *Client) Close() error {
+	c.writeMu.Lock()
+	_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	c.writeMu.Unlock()
+	c.shutdown(ErrClosed)
+	return c.conn.Close()
+}
+
+// SubscribeOptions of subscription.
+type SubscribeOptions struct {
+	// Token is a subscription JWT for private channels.
+	Token string
+	// Since turns on recovery of publications missed after position.
+	Since *StreamPosition
+	// BufferSize of publications channel, DefaultPublicationBufferSize by
+	// default. Connection closed if application does not read
+	// publications fast enough.
+	BufferSize int
+}
+
+// Subscription to channel.
+type Subscription struct {
+	client       *Client
+	channel      string
+	result       *protocol.SubscribeResult
+	publications chan Publication
+
+	mu       sync.Mutex
+	position StreamPosition
+	closed   bool
+	// early keeps publications received before subscribe reply processed.
+	early       []*protocol.Publication
+	subscribing bool
+}
+
+// Subscribe to channel. Recovered publications are delivered to
+// Publications channel before new ones.
+func (c *Client) Subscribe(ctx context.Context, channel string, opts SubscribeOptions) (*Subscription, error) {
+	bufferSize := opts.BufferSize
+	if bufferSize == 0 {
+		bufferSize = DefaultPublicationBufferSize
+	}
+	sub := &Subscription{
+		client:       c,
+		channel:      channel,
+		publications: make(chan Publication, bufferSize),
+		subscribing:  true,
+	}
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	if _, ok := c.subs[channel]; ok {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("already subscribed on %s", channel)
+	}
+	// Registered before sending command to not miss publications sent right
+	// after subscribe reply.
+	c.subs[channel] = sub
+	c.mu.Unlock()
+
+	req := &protocol.SubscribeRequest{Channel: channel, Token: opts.Token}
+	if opts.Since != nil {
+		req.Recover = true
+		req.Offset = opts.Since.Offset
+		req.Epoch = opts.Since.Epoch
+	}
+	var result protocol.SubscribeResult
+	if err := c.call(ctx, protocol.Command_SUBSCRIBE, req, &result); err != nil {
+		c.mu.Lock()
+		delete(c.subs, channel)
+		c.mu.Unlock()
+		return nil, err
+	}
+	sub.subscribed(&result, opts.Since)
+	return sub, nil
+}
+
+// Channel of subscription.
+func (s *Subscription) Channel() string {
+	return s.channel
+}
+
+// Result returns result of subscribe command.
+func (s *Subscription) Result() *protocol.SubscribeResult {
+	return s.result
+}
+
+// Recovered is true if all missed publications were recovered.
+func (s *Subscription) Recovered() bool {
+	return s.result.Recovered
+}
+
+// Publications delivers publications of channel. Closed when client
+// unsubscribed or connection closed.
+func (s *Subscription) Publications() <-chan Publication {
+	return s.publications
+}
+
+// StreamPosition of last received publication, can be used to recover
+// missed publications on next subscription.
+func (s *Subscription) StreamPosition() StreamPosition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.position
+}
+
+// Unsubscribe from channel.
+func (s *Subscription) Unsubscribe(ctx context.Context) error {
+	c := s.client
+	c.mu.Lock()
+	if c.subs[s.channel] == s {
+		delete(c.subs, s.channel)
+	}
+	c.mu.Unlock()
+	s.close()
+	return c.call(ctx, protocol.Command_UNSUBSCRIBE, &protocol.UnsubscribeRequest{Channel: s.channel}, nil)
+}
+
+// subscribed delivers recovered publications and publications received
+// while waiting for subscribe reply.
+func (s *Subscription) subscribed(result *protocol.SubscribeResult, since *StreamPosition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.result = result
+	s.subscribing = false
+	if since != nil {
+		s.position.Offset = since.Offset
+	}
+	for _, pub := range result.Publications {
+		s.push(pub)
+	}
+	if result.Offset > s.position.Offset {
+		s.position.Offset = result.Offset
+	}
+	s.position.Epoch = result.Epoch
+	for _, pub := range s.early {
+		s.push(pub)
+	}
+	s.early = nil
+}
+
+func (s *Subscription) deliver(pub *protocol.Publication) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subscribing {
+		s.early = append(s.early, pub)
+		return
+	}
+	s.push(pub)
+}
+
+func (s *Subscription) push(pub *protocol.Publication) {
+	if s.closed {
+		return
+	}
+	if pub.Offset > 0 {
+		if pub.Offset <= s.position.Offset {
+			// Already delivered.
+			return
+		}
+		s.position.Offset = pub.Offset
+	}
+	select {
+	case s.publications <- Publication{Channel: s.channel, Data: pub.Data, Offset: pub.Offset, Info: pub.Info}:
+	default:
+		go s.client.shutdownConn(errors.New("publication buffer overflow on " + s.channel))
+	}
+}
+
+func (s *Subscription) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.publications)
+}
+
+// Publish data into channel.
+func (c *Client) Publish(ctx context.Context, channel string, data []byte) error {
+	return c.call(ctx, protocol.Command_PUBLISH, &protocol.PublishRequest{Channel: channel, Data: data}, nil)
+}
+
+// HistoryOptions of history call.
+type HistoryOptions struct {
+	Limit   int32
+	Since   *StreamPosition
+	Reverse bool
+}
+
+// History returns publications from channel history stream.
+func (c *Client) History(ctx context.Context, channel string, opts HistoryOptions) (*protocol.HistoryResult, error) {
+	req := &protocol.HistoryRequest{Channel: channel, Limit: opts.Limit, Reverse: opts.Reverse}
+	if opts.Since != nil {
+		req.Since = &protocol.StreamPosition{Offset: opts.Since.Offset, Epoch: opts.Since.Epoch}
+	}
+	var result protocol.HistoryResult
+	if err := c.call(ctx, protocol.Command_HISTORY, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Presence returns clients subscribed on channel.
+func (c *Client) Presence(ctx context.Context, channel string) (map[string]*protocol.ClientInfo, error) {
+	var result protocol.PresenceResult
+	if err := c.call(ctx, protocol.Command_PRESENCE, &protocol.PresenceRequest{Channel: channel}, &result); err != nil {
+		return nil, err
+	}
+	return result.Presence, nil
+}
+
+// PresenceStats returns number of clients and unique users subscribed on
+// channel.
+func (c *Client) PresenceStats(ctx context.Context, channel string) (*protocol.PresenceStatsResult, error) {
+	var result protocol.PresenceStatsResult
+	if err := c.call(ctx, protocol.Command_PRESENCE_STATS, &protocol.PresenceStatsRequest{Channel: channel}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// RPC calls method on server and returns reply data.
+func (c *Client) RPC(ctx context.Context, method string, data []byte) ([]byte, error) {
+	var result protocol.RPCResult
+	if err := c.call(ctx, protocol.Command_RPC, &protocol.RPCRequest{Method: method, Data: data}, &result); err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+func (c *Client) call(ctx context.Context, method protocol.Command_MethodType, params interface{}, result interface{}) error {
+	encodedParams, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	replyCh := make(chan *protocol.Reply, 1)
+	c.pending[id] = replyCh
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	cmd, err := json.Marshal(&protocol.Command{Id: id, Method: method, Params: encodedParams})
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	err = c.conn.WriteMessage(websocket.TextMessage, cmd)
+	c.writeMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return c.Err()
+	case reply := <-replyCh:
+		if reply.Error != nil {
+			return &Error{Code: reply.Error.Code, Message: reply.Error.Message}
+		}
+		if result == nil || len(reply.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(reply.Result, result)
+	}
+}
+
+func (c *Client) reader() {
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) && closeErr.Code != websocket.CloseNormalClosure {
+				d := &Disconnect{Code: closeErr.Code, Reason: closeErr.Text}
+				var advice struct {
+					Reason    string `json:"reason"`
+					Reconnect bool   `json:"reconnect"`
+				}
+				if json.Unmarshal([]byte(closeErr.Text), &advice) == nil {
+					d.Reason = advice.Reason
+					d.Reconnect = advice.Reconnect
+				}
+				err = d
+			}
+			c.shutdownConn(err)
+			return
+		}
+		// Server may send several newline delimited replies in one frame.
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			var reply protocol.Reply
+			if err := json.Unmarshal(line, &reply); err != nil {
+				c.shutdownConn(fmt.Errorf("error decoding reply: %w", err))
+				return
+			}
+			c.handleReply(&reply)
+		}
+	}
+}
+
+func (c *Client) handleReply(reply *protocol.Reply) {
+	if reply.Id > 0 {
+		c.mu.Lock()
+		replyCh, ok := c.pending[reply.Id]
+		c.mu.Unlock()
+		if ok {
+			replyCh <- reply
+		}
+		return
+	}
+	var push protocol.Push
+	if err := json.Unmarshal(reply.Result, &push); err != nil {
+		return
+	}
+	c.mu.Lock()
+	sub, ok := c.subs[push.Channel]
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	switch push.Type {
+	case protocol.Push_PUBLICATION:
+		var pub protocol.Publication
+		if err := json.Unmarshal(push.Data, &pub); err != nil {
+			return
+		}
+		sub.deliver(&pub)
+	case protocol.Push_UNSUBSCRIBE:
+		c.mu.Lock()
+		if c.subs[push.Channel] == sub {
+			delete(c.subs, push.Channel)
+		}
+		c.mu.Unlock()
+		sub.close()
+	}
+}
+
+func (c *Client) shutdownConn(err error) {
+	_ = c.conn.Close()
+	c.shutdown(err)
+}
+
+func (c *Client) shutdown(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	subs := c.subs
+	c.subs = make(map[string]*Subscription)
+	c.mu.Unlock()
+	close(c.done)
+	for _, sub := range subs {
+		sub.close()
+	}
+}
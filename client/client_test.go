@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/jwtverify"
+	"github.com/centrifugal/centrifugo/v3/server"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/cristalhq/jwt/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) (*server.Server, string) {
+	channels := server.DefaultChannelConfig()
+	channels.Publish = true
+	channels.Presence = true
+	channels.HistorySize = 10
+	channels.HistoryTTL = server.Duration(time.Minute)
+	channels.Recover = true
+	s, err := server.New(server.Config{
+		Channels: &channels,
+		Token:    server.TokenConfig{HMACSecretKey: "secret"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.Run())
+	ts := httptest.NewServer(s.WebsocketHandler())
+	t.Cleanup(func() {
+		ts.Close()
+		_ = s.Shutdown(context.Background())
+	})
+	return s, "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func connToken(t *testing.T, user string) string {
+	signer, err := jwt.NewSignerHS(jwt.HS256, []byte("secret"))
+	require.NoError(t, err)
+	token, err := jwt.NewBuilder(signer).Build(&jwtverify.ConnectTokenClaims{
+		StandardClaims: jwt.StandardClaims{Subject: user},
+	})
+	require.NoError(t, err)
+	return token.String()
+}
+
+func waitPublication(t *testing.T, sub *Subscription) Publication {
+	select {
+	case pub, ok := <-sub.Publications():
+		require.True(t, ok)
+		return pub
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timeout waiting publication")
+	}
+	return Publication{}
+}
+
+func TestClient(t *testing.T) {
+	_, url := newTestServer(t)
+	ctx := context.Background()
+
+	_, err := Connect(ctx, url, Config{Token: "invalid"})
+	var d *Disconnect
+	require.True(t, errors.As(err, &d))
+	require.Equal(t, 3002, d.Code)
+
+	c, err := Connect(ctx, url, Config{Token: connToken(t, "42")})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	require.NotEmpty(t, c.ID())
+
+	sub, err := c.Subscribe(ctx, "test", SubscribeOptions{})
+	require.NoError(t, err)
+	_, err = c.Subscribe(ctx, "test", SubscribeOptions{})
+	require.Error(t, err)
+
+	require.NoError(t, c.Publish(ctx, "test", []byte(`{"n":1}`)))
+	pub := waitPublication(t, sub)
+	require.JSONEq(t, `{"n":1}`, string(pub.Data))
+	require.Equal(t, uint64(1), pub.Offset)
+	require.Equal(t, "42", pub.Info.User)
+	require.Equal(t, uint64(1), sub.StreamPosition().Offset)
+
+	presence, err := c.Presence(ctx, "test")
+	require.NoError(t, err)
+	require.Contains(t, presence, c.ID())
+	stats, err := c.PresenceStats(ctx, "test")
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), stats.NumUsers)
+
+	history, err := c.History(ctx, "test", HistoryOptions{Limit: -1})
+	require.NoError(t, err)
+	require.Len(t, history.Publications, 1)
+
+	_, err = c.RPC(ctx, "unknown", []byte(`{}`))
+	var replyErr *Error
+	require.True(t, errors.As(err, &replyErr))
+
+	require.NoError(t, sub.Unsubscribe(ctx))
+	_, ok := <-sub.Publications()
+	require.False(t, ok)
+}
+
+func TestClientRecovery(t *testing.T) {
+	s, url := newTestServer(t)
+	ctx := context.Background()
+
+	c, err := Connect(ctx, url, Config{Token: connToken(t, "42")})
+	require.NoError(t, err)
+	sub, err := c.Subscribe(ctx, "test", SubscribeOptions{})
+	require.NoError(t, err)
+	_, err = s.Publish(ctx, "test", []byte(`{"n":1}`))
+	require.NoError(t, err)
+	waitPublication(t, sub)
+	position := sub.StreamPosition()
+	require.NoError(t, c.Close())
+	require.Equal(t, ErrClosed, c.Err())
+
+	// Missed while client offline.
+	_, err = s.Publish(ctx, "test", []byte(`{"n":2}`))
+	require.NoError(t, err)
+	_, err = s.Publish(ctx, "test", []byte(`{"n":3}`))
+	require.NoError(t, err)
+
+	c, err = Connect(ctx, url, Config{Token: connToken(t, "42")})
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	sub, err = c.Subscribe(ctx, "test", SubscribeOptions{Since: &position})
+	require.NoError(t, err)
+	require.True(t, sub.Recovered())
+	require.JSONEq(t, `{"n":2}`, string(waitPublication(t, sub).Data))
+	require.JSONEq(t, `{"n":3}`, string(waitPublication(t, sub).Data))
+
+	_, err = s.Publish(ctx, "test", []byte(`{"n":4}`))
+	require.NoError(t, err)
+	pub := waitPublication(t, sub)
+	require.JSONEq(t, `{"n":4}`, string(pub.Data))
+	require.Equal(t, uint64(4), sub.StreamPosition().Offset)
+}
+
+func TestClientServerDisconnect(t *testing.T) {
+	s, url := newTestServer(t)
+	ctx := context.Background()
+
+	c, err := Connect(ctx, url, Config{Token: connToken(t, "42")})
+	require.NoError(t, err)
+	sub, err := c.Subscribe(ctx, "test", SubscribeOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Node().Disconnect("42", centrifuge.WithDisconnect(centrifuge.DisconnectForceNoReconnect)))
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timeout waiting disconnect")
+	}
+	var d *Disconnect
+	require.True(t, errors.As(c.Err(), &d))
+	require.Equal(t, int(centrifuge.DisconnectForceNoReconnect.Code), d.Code)
+	require.False(t, d.Reconnect)
+	_, ok := <-sub.Publications()
+	require.False(t, ok)
+	require.Error(t, c.Publish(ctx, "test", []byte(`{}`)))
+}
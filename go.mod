@@ -6,6 +6,7 @@ go 1.13
 
 require (
 	github.com/FZambia/eagle v0.0.2
+	github.com/FZambia/sentinel v1.1.0
 	github.com/FZambia/statik v0.1.2-0.20180217151304-b9f012bb2a1b
 	github.com/FZambia/tarantool v0.2.2
 	github.com/FZambia/viper-lite v0.0.0-20171108064948-d5a31e6aa18b
@@ -13,11 +14,14 @@ require (
 	github.com/centrifugal/protocol v0.7.3
 	github.com/cristalhq/jwt/v3 v3.1.0
 	github.com/gobwas/glob v0.2.3
+	github.com/gomodule/redigo v1.8.5
 	github.com/google/uuid v1.3.0
 	github.com/gorilla/securecookie v1.1.1
 	github.com/gorilla/websocket v1.4.2
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-isatty v0.0.12
 	github.com/mitchellh/mapstructure v1.4.1
+	github.com/mna/redisc v1.3.2
 	github.com/nats-io/nats-server/v2 v2.2.1 // indirect
 	github.com/nats-io/nats.go v1.11.0
 	github.com/pelletier/go-toml v1.9.0 // indirect
@@ -25,6 +29,7 @@ require (
 	github.com/prometheus/common v0.20.0 // indirect
 	github.com/rakutentech/jwk-go v1.0.1
 	github.com/rs/zerolog v1.21.0
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
 	github.com/spf13/cast v1.3.1 // indirect
 	github.com/spf13/cobra v0.0.7
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
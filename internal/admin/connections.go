@@ -0,0 +1,178 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/clientcontext"
+	"github.com/centrifugal/centrifugo/v3/internal/geoip"
+	"github.com/centrifugal/centrifugo/v3/internal/sdkinfo"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// ConnectionSource provides current client connections of node.
+type ConnectionSource interface {
+	Clients() []*centrifuge.Client
+}
+
+// DefaultConnectionsExportInterval is a default min interval between
+// connections exports.
+const DefaultConnectionsExportInterval = 10 * time.Second
+
+// Fields of connection in export. All fields except meta exported by default.
+const (
+	connFieldClient      = "client"
+	connFieldUser        = "user"
+	connFieldTransport   = "transport"
+	connFieldProtocol    = "protocol"
+	connFieldConnectedAt = "connected_at"
+	connFieldChannels    = "channels"
+	connFieldNumChannels = "num_channels"
+	connFieldSDK         = "sdk"
+	connFieldLocation    = "location"
+	connFieldMeta        = "meta"
+)
+
+var defaultConnFields = []string{
+	connFieldClient, connFieldUser, connFieldTransport, connFieldProtocol, connFieldConnectedAt,
+	connFieldChannels, connFieldNumChannels, connFieldSDK, connFieldLocation,
+}
+
+var knownConnFields = map[string]struct{}{connFieldMeta: {}}
+
+func init() {
+	for _, f := range defaultConnFields {
+		knownConnFields[f] = struct{}{}
+	}
+}
+
+// Flush output every connectionsFlushSize lines so client sees progress on
+// nodes with many connections.
+const connectionsFlushSize = 1000
+
+// connectionsExporter allows only one export at a time and limits how often
+// exports start, walking over all connections is not free on busy node.
+type connectionsExporter struct {
+	interval time.Duration
+
+	mu         sync.Mutex
+	running    bool
+	lastExport time.Time
+}
+
+func (e *connectionsExporter) acquire(now time.Time) (time.Duration, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.running {
+		return e.interval, false
+	}
+	if !e.lastExport.IsZero() {
+		if wait := e.interval - now.Sub(e.lastExport); wait > 0 {
+			return wait, false
+		}
+	}
+	e.running = true
+	e.lastExport = now
+	return 0, true
+}
+
+func (e *connectionsExporter) release() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.running = false
+}
+
+// connectionsHandler streams snapshot of node connections as NDJSON – one JSON
+// object per line. Fields to export may be selected with comma-separated
+// fields query param.
+func (s *Handler) connectionsHandler(w http.ResponseWriter, r *http.Request) {
+	fields := defaultConnFields
+	if fieldsParam := r.URL.Query().Get("fields"); fieldsParam != "" {
+		fields = strings.Split(fieldsParam, ",")
+		for _, f := range fields {
+			if _, ok := knownConnFields[f]; !ok {
+				http.Error(w, "unknown field: "+f, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	wait, ok := s.connExporter.acquire(time.Now())
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	defer s.connExporter.release()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for i, c := range s.config.Connections.Clients() {
+		select {
+		case <-r.Context().Done():
+			return
+		default:
+		}
+		if err := enc.Encode(connectionEntry(c, fields)); err != nil {
+			return
+		}
+		if (i+1)%connectionsFlushSize == 0 {
+			if bw.Flush() != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	_ = bw.Flush()
+}
+
+func connectionEntry(c *centrifuge.Client, fields []string) map[string]interface{} {
+	entry := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		switch f {
+		case connFieldClient:
+			entry[f] = c.ID()
+		case connFieldUser:
+			entry[f] = c.UserID()
+		case connFieldTransport:
+			entry[f] = c.Transport().Name()
+		case connFieldProtocol:
+			entry[f] = string(c.Transport().Protocol())
+		case connFieldConnectedAt:
+			if connectTime, ok := clientcontext.GetContextConnectTime(c.Context()); ok {
+				entry[f] = connectTime.Unix()
+			}
+		case connFieldChannels:
+			channels := c.Channels()
+			if channels == nil {
+				channels = []string{}
+			}
+			entry[f] = channels
+		case connFieldNumChannels:
+			entry[f] = len(c.Channels())
+		case connFieldSDK:
+			if sdk, ok := sdkinfo.FromContext(c.Context()); ok {
+				entry[f] = map[string]interface{}{"name": sdk.Name, "version": sdk.Version, "features": sdk.Features}
+			}
+		case connFieldLocation:
+			if loc, ok := geoip.FromContext(c.Context()); ok {
+				entry[f] = map[string]string{"country": loc.Country, "region": loc.Region}
+			}
+		case connFieldMeta:
+			if meta, ok := clientcontext.GetContextConnectionMeta(c.Context()); ok && len(meta.Meta) > 0 {
+				entry[f] = meta.Meta
+			}
+		}
+	}
+	return entry
+}
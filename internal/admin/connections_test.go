@@ -0,0 +1,56 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/migrate"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionsHandler(t *testing.T) {
+	node, err := centrifuge.New(centrifuge.DefaultConfig)
+	require.NoError(t, err)
+	registry := migrate.NewRegistry()
+	for i := 0; i < 3; i++ {
+		c, _, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+		require.NoError(t, err)
+		registry.Add(c)
+	}
+	h := NewHandler(node, nil, Config{Insecure: true, Connections: registry, ConnectionsExportInterval: time.Hour})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/connections?fields=client,unknown", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/connections?fields=client,num_channels", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+	scanner := bufio.NewScanner(strings.NewReader(rec.Body.String()))
+	var numLines int
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		require.Len(t, entry, 2)
+		require.NotEmpty(t, entry["client"])
+		require.Equal(t, float64(0), entry["num_channels"])
+		numLines++
+	}
+	require.Equal(t, 3, numLines)
+
+	// Next export allowed only after interval.
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/connections", nil))
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.NotEmpty(t, rec.Header().Get("Retry-After"))
+}
@@ -0,0 +1,64 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// registerDebugHandlers registers pprof and runtime stats endpoints on mux.
+// All endpoints require admin authentication.
+func (s *Handler) registerDebugHandlers(mux *http.ServeMux, prefix string) {
+	pprofPrefix := prefix + "/admin/debug/pprof/"
+	mux.Handle(pprofPrefix, s.adminSecureTokenAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// pprof.Index serves named profiles only under /debug/pprof/ path
+		// so named profiles served with pprof.Handler here.
+		name := strings.TrimPrefix(r.URL.Path, pprofPrefix)
+		if name == "" {
+			pprof.Index(w, r)
+			return
+		}
+		pprof.Handler(name).ServeHTTP(w, r)
+	})))
+	mux.Handle(pprofPrefix+"cmdline", s.adminSecureTokenAuth(http.HandlerFunc(pprof.Cmdline)))
+	mux.Handle(pprofPrefix+"profile", s.adminSecureTokenAuth(http.HandlerFunc(pprof.Profile)))
+	mux.Handle(pprofPrefix+"symbol", s.adminSecureTokenAuth(http.HandlerFunc(pprof.Symbol)))
+	mux.Handle(pprofPrefix+"trace", s.adminSecureTokenAuth(http.HandlerFunc(pprof.Trace)))
+	mux.Handle(prefix+"/admin/debug/runtime", s.adminSecureTokenAuth(http.HandlerFunc(s.runtimeHandler)))
+}
+
+type runtimeStats struct {
+	NumGoroutine   int     `json:"num_goroutine"`
+	NumGC          uint32  `json:"num_gc"`
+	LastGCPauseMs  float64 `json:"last_gc_pause_ms"`
+	TotalGCPauseMs float64 `json:"total_gc_pause_ms"`
+	HeapAlloc      uint64  `json:"heap_alloc"`
+	HeapInuse      uint64  `json:"heap_inuse"`
+	HeapObjects    uint64  `json:"heap_objects"`
+	HeapSys        uint64  `json:"heap_sys"`
+	Sys            uint64  `json:"sys"`
+}
+
+// runtimeHandler responds with Go runtime stats of node.
+func (s *Handler) runtimeHandler(w http.ResponseWriter, _ *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	stats := runtimeStats{
+		NumGoroutine:   runtime.NumGoroutine(),
+		NumGC:          m.NumGC,
+		TotalGCPauseMs: float64(m.PauseTotalNs) / float64(time.Millisecond),
+		HeapAlloc:      m.HeapAlloc,
+		HeapInuse:      m.HeapInuse,
+		HeapObjects:    m.HeapObjects,
+		HeapSys:        m.HeapSys,
+		Sys:            m.Sys,
+	}
+	if m.NumGC > 0 {
+		stats.LastGCPauseMs = float64(m.PauseNs[(m.NumGC+255)%256]) / float64(time.Millisecond)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,47 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugHandlers(t *testing.T) {
+	node, err := centrifuge.New(centrifuge.DefaultConfig)
+	require.NoError(t, err)
+	h := NewHandler(node, nil, Config{Secret: "secret", Debug: true})
+	token, err := generateSecureAdminToken("secret")
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/debug/runtime", nil))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/debug/runtime", nil)
+	req.Header.Set("Authorization", "token "+token)
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var stats runtimeStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	require.True(t, stats.NumGoroutine > 0)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/debug/pprof/goroutine?debug=1&token="+token, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "goroutine profile")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/debug/pprof/goroutine", nil))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// Debug endpoints not registered by default.
+	h = NewHandler(node, nil, Config{Secret: "secret"})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/debug/runtime?token="+token, nil))
+	require.NotEqual(t, http.StatusOK, rec.Code)
+}
@@ -4,13 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/centrifugal/centrifugo/v3/internal/api"
 	"github.com/centrifugal/centrifugo/v3/internal/middleware"
 
 	"github.com/centrifugal/centrifuge"
 	"github.com/gorilla/securecookie"
-	"github.com/rs/zerolog/log"
 )
 
 // Config ...
@@ -38,13 +38,29 @@ type Config struct {
 	// this option enabled otherwise everyone from internet can make admin
 	// actions.
 	Insecure bool
+
+	// Debug enables pprof endpoints and Go runtime stats endpoint which
+	// require admin authentication.
+	Debug bool
+
+	// Connections enables endpoint to export snapshot of node connections.
+	Connections ConnectionSource
+
+	// ConnectionsExportInterval is a min interval between connections exports.
+	// By default DefaultConnectionsExportInterval used.
+	ConnectionsExportInterval time.Duration
+
+	// KeyspaceReport enables endpoint with report of Redis keyspace memory
+	// usage by channel namespace.
+	KeyspaceReport KeyspaceReportSource
 }
 
 // Handler handles admin web interface endpoints.
 type Handler struct {
-	mux    *http.ServeMux
-	node   *centrifuge.Node
-	config Config
+	mux          *http.ServeMux
+	node         *centrifuge.Node
+	config       Config
+	connExporter *connectionsExporter
 }
 
 // NewHandler creates new Handler.
@@ -57,6 +73,20 @@ func NewHandler(n *centrifuge.Node, apiExecutor *api.Executor, c Config) *Handle
 	prefix := strings.TrimRight(h.config.Prefix, "/")
 	mux.Handle(prefix+"/admin/auth", middleware.Post(http.HandlerFunc(h.authHandler)))
 	mux.Handle(prefix+"/admin/api", middleware.Post(h.adminSecureTokenAuth(api.NewHandler(n, apiExecutor, api.Config{}))))
+	if c.Debug {
+		h.registerDebugHandlers(mux, prefix)
+	}
+	if c.Connections != nil {
+		interval := c.ConnectionsExportInterval
+		if interval == 0 {
+			interval = DefaultConnectionsExportInterval
+		}
+		h.connExporter = &connectionsExporter{interval: interval}
+		mux.Handle(prefix+"/admin/connections", middleware.Get(h.adminSecureTokenAuth(http.HandlerFunc(h.connectionsHandler))))
+	}
+	if c.KeyspaceReport != nil {
+		mux.Handle(prefix+"/admin/redis_keyspace", middleware.Get(h.adminSecureTokenAuth(http.HandlerFunc(h.keyspaceHandler))))
+	}
 	webPrefix := prefix + "/"
 	if c.WebPath != "" {
 		mux.Handle(webPrefix, http.StripPrefix(webPrefix, http.FileServer(http.Dir(c.WebPath))))
@@ -83,7 +113,7 @@ func (s *Handler) adminSecureTokenAuth(h http.Handler) http.Handler {
 		}
 
 		if secret == "" {
-			log.Error().Msg("no admin secret key found in configuration")
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "no admin secret key found in configuration"))
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
@@ -135,7 +165,7 @@ func (s *Handler) authHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if password == "" || secret == "" {
-		log.Error().Msg("admin_password and admin_secret must be set in configuration")
+		s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "admin_password and admin_secret must be set in configuration"))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
@@ -144,7 +174,7 @@ func (s *Handler) authHandler(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		token, err := generateSecureAdminToken(secret)
 		if err != nil {
-			log.Error().Msgf("error generating admin token: %v", err)
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error generating admin token", map[string]interface{}{"error": err.Error()}))
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
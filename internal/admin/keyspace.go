@@ -0,0 +1,24 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// KeyspaceReportSource provides last report of Redis keyspace memory usage.
+type KeyspaceReportSource interface {
+	// KeyspaceReport returns report ready for JSON encoding, false returned if
+	// no report made yet.
+	KeyspaceReport() (interface{}, bool)
+}
+
+// keyspaceHandler responds with last Redis keyspace report.
+func (s *Handler) keyspaceHandler(w http.ResponseWriter, _ *http.Request) {
+	report, ok := s.config.KeyspaceReport.KeyspaceReport()
+	if !ok {
+		http.Error(w, "report not ready yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
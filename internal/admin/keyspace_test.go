@@ -0,0 +1,35 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+type testKeyspaceReport struct {
+	report interface{}
+}
+
+func (r *testKeyspaceReport) KeyspaceReport() (interface{}, bool) {
+	return r.report, r.report != nil
+}
+
+func TestKeyspaceHandler(t *testing.T) {
+	node, err := centrifuge.New(centrifuge.DefaultConfig)
+	require.NoError(t, err)
+	source := &testKeyspaceReport{}
+	h := NewHandler(node, nil, Config{Insecure: true, KeyspaceReport: source})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/redis_keyspace", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	source.report = map[string]int{"keys": 1}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/redis_keyspace", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"keys":1}`, rec.Body.String())
+}
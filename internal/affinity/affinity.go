@@ -0,0 +1,92 @@
+// Package affinity helps load balancers to route reconnecting clients to the
+// node they were connected to, so clients with state to recover reach node
+// which is likely to have it.
+package affinity
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// DefaultURLParam is a default name of WebSocket URL query parameter with
+// affinity token.
+const DefaultURLParam = "affinity"
+
+// DataKey is a key of affinity token in connect reply data.
+const DataKey = "affinity"
+
+// Config of node affinity.
+type Config struct {
+	// Token identifies node for load balancer, usually node name.
+	Token string
+	// URLParam is a name of WebSocket URL query parameter client passes
+	// affinity token of previous connection in. DefaultURLParam used if empty.
+	URLParam string
+	// CookieName if set makes node set cookie with affinity token in response
+	// to WebSocket upgrade request, so load balancer can use cookie based
+	// session affinity.
+	CookieName string
+}
+
+// ResponseHeader returns header to send in response to WebSocket upgrade
+// request. Nil returned if cookie not configured.
+func (c Config) ResponseHeader() http.Header {
+	if c.CookieName == "" {
+		return nil
+	}
+	cookie := &http.Cookie{
+		Name:     c.CookieName,
+		Value:    c.Token,
+		Path:     "/",
+		HttpOnly: true,
+	}
+	return http.Header{"Set-Cookie": []string{cookie.String()}}
+}
+
+// Observe checks affinity token passed in WebSocket URL. Returns true if
+// request has token of another node, i.e. load balancer could not route
+// client to the node it was connected to.
+func (c Config) Observe(r *http.Request) bool {
+	urlParam := c.URLParam
+	if urlParam == "" {
+		urlParam = DefaultURLParam
+	}
+	token := r.URL.Query().Get(urlParam)
+	if token == "" {
+		return false
+	}
+	if token == c.Token {
+		requestsCount.WithLabelValues("hit").Inc()
+		return false
+	}
+	requestsCount.WithLabelValues("miss").Inc()
+	return true
+}
+
+// AddToData adds affinity token to connect reply data under DataKey. Data
+// which is not empty and not JSON object returned as is, existing key not
+// overwritten. Passed data is never modified and never shared with result.
+func AddToData(data []byte, token string) []byte {
+	fields := map[string]json.RawMessage{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+			return copyData(data)
+		}
+	}
+	if _, ok := fields[DataKey]; ok {
+		return copyData(data)
+	}
+	fields[DataKey], _ = json.Marshal(token)
+	result, err := json.Marshal(fields)
+	if err != nil {
+		return copyData(data)
+	}
+	return result
+}
+
+func copyData(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+	return append([]byte{}, data...)
+}
@@ -0,0 +1,45 @@
+package affinity
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddToData(t *testing.T) {
+	require.JSONEq(t, `{"affinity":"node1"}`, string(AddToData(nil, "node1")))
+	require.JSONEq(t, `{"affinity":"node1","user":"42"}`, string(AddToData([]byte(`{"user":"42"}`), "node1")))
+	require.Equal(t, `{"affinity":"app"}`, string(AddToData([]byte(`{"affinity":"app"}`), "node1")))
+	require.Equal(t, `[1,2]`, string(AddToData([]byte(`[1,2]`), "node1")))
+	require.Equal(t, `null`, string(AddToData([]byte(`null`), "node1")))
+	require.Equal(t, "binary", string(AddToData([]byte("binary"), "node1")))
+}
+
+func TestAddToDataDoesNotShareInput(t *testing.T) {
+	for _, in := range []string{`{"user":"42"}`, `{"affinity":"app"}`, `[1,2]`, "binary"} {
+		data := []byte(in)
+		result := AddToData(data, "node1")
+		for i := range result {
+			result[i] = 'x'
+		}
+		require.Equal(t, in, string(data))
+	}
+}
+
+func TestObserve(t *testing.T) {
+	c := Config{Token: "node1"}
+	require.False(t, c.Observe(httptest.NewRequest("GET", "/connection/websocket", nil)))
+	require.False(t, c.Observe(httptest.NewRequest("GET", "/connection/websocket?affinity=node1", nil)))
+	require.True(t, c.Observe(httptest.NewRequest("GET", "/connection/websocket?affinity=node2", nil)))
+
+	c = Config{Token: "node1", URLParam: "node"}
+	require.True(t, c.Observe(httptest.NewRequest("GET", "/connection/websocket?node=node2", nil)))
+	require.False(t, c.Observe(httptest.NewRequest("GET", "/connection/websocket?affinity=node2", nil)))
+}
+
+func TestResponseHeader(t *testing.T) {
+	require.Nil(t, Config{Token: "node1"}.ResponseHeader())
+	header := Config{Token: "node1", CookieName: "lb"}.ResponseHeader()
+	require.Equal(t, "lb=node1; Path=/; HttpOnly", header.Get("Set-Cookie"))
+}
@@ -0,0 +1,20 @@
+package affinity
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricsNamespace = "centrifugo"
+
+var (
+	requestsCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "affinity",
+		Name:      "requests",
+		Help:      "Number of WebSocket upgrade requests with affinity token: hit if token matches node, miss otherwise.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(requestsCount)
+}
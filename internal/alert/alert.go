@@ -0,0 +1,287 @@
+// Package alert fires webhook notifications when node-level error counters
+// grow faster than configured thresholds. It's a lightweight alternative to
+// alerting over full monitoring stack: counters are read from Prometheus
+// registry of node, so any counter exported by node can be a source of rule.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultInterval is a default value for Config.Interval.
+const DefaultInterval = time.Minute
+
+// DefaultCooldown is a default value for Config.Cooldown.
+const DefaultCooldown = 10 * time.Minute
+
+// Names of built-in rules.
+const (
+	RuleEngineErrors    = "engine_errors"
+	RuleAuthFailures    = "auth_failures"
+	RuleSlowDisconnects = "slow_disconnects"
+)
+
+// Source is a counter summed up by rule. Counter matches if all Labels
+// equal to labels of counter.
+type Source struct {
+	Metric string
+	Labels map[string]string
+}
+
+// Rule fires alert when sum of Sources increased by Threshold or more during
+// check interval.
+type Rule struct {
+	Name      string
+	Sources   []Source
+	Threshold float64
+}
+
+// EngineErrorsRule counts internal errors replied to clients and failed
+// Redis engine operations.
+func EngineErrorsRule(threshold float64) Rule {
+	return Rule{
+		Name: RuleEngineErrors,
+		Sources: []Source{
+			{Metric: "centrifugo_client_num_reply_errors", Labels: map[string]string{"code": "100"}},
+			{Metric: "centrifugo_redis_operation_errors"},
+		},
+		Threshold: threshold,
+	}
+}
+
+// AuthFailuresRule counts disconnects due to invalid token and unauthorized
+// errors replied to clients.
+func AuthFailuresRule(threshold float64) Rule {
+	return Rule{
+		Name: RuleAuthFailures,
+		Sources: []Source{
+			{Metric: "centrifugo_client_num_server_disconnects", Labels: map[string]string{"code": "3002"}},
+			{Metric: "centrifugo_client_num_reply_errors", Labels: map[string]string{"code": "101"}},
+		},
+		Threshold: threshold,
+	}
+}
+
+// SlowDisconnectsRule counts disconnects of slow clients.
+func SlowDisconnectsRule(threshold float64) Rule {
+	return Rule{
+		Name: RuleSlowDisconnects,
+		Sources: []Source{
+			{Metric: "centrifugo_client_num_server_disconnects", Labels: map[string]string{"code": "3008"}},
+		},
+		Threshold: threshold,
+	}
+}
+
+// Alert of rule.
+type Alert struct {
+	Rule      string  `json:"rule"`
+	Count     float64 `json:"count"`
+	Threshold float64 `json:"threshold"`
+}
+
+// Report sent to Sender when at least one rule fired.
+type Report struct {
+	Node     string    `json:"node"`
+	Time     time.Time `json:"time"`
+	Interval string    `json:"interval"`
+	Alerts   []Alert   `json:"alerts"`
+}
+
+// Sender delivers Report.
+type Sender interface {
+	Send(ctx context.Context, r Report) error
+}
+
+// Config of Alerter.
+type Config struct {
+	Rules  []Rule
+	Sender Sender
+	// NodeName reported in Report.
+	NodeName string
+	// Interval of checks. By default DefaultInterval used.
+	Interval time.Duration
+	// Cooldown is a min time between alerts of the same rule. By default
+	// DefaultCooldown used.
+	Cooldown time.Duration
+	// Gatherer to read counters from, by default prometheus.DefaultGatherer
+	// used.
+	Gatherer prometheus.Gatherer
+}
+
+// Alerter periodically checks rules and sends Report of fired ones.
+type Alerter struct {
+	node   *centrifuge.Node
+	config Config
+
+	prev      map[string]float64
+	lastFired map[string]time.Time
+}
+
+// New creates Alerter.
+func New(node *centrifuge.Node, c Config) (*Alerter, error) {
+	if c.Sender == nil {
+		return nil, errors.New("alert sender required")
+	}
+	if c.Interval == 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.Cooldown == 0 {
+		c.Cooldown = DefaultCooldown
+	}
+	if c.Gatherer == nil {
+		c.Gatherer = prometheus.DefaultGatherer
+	}
+	return &Alerter{
+		node:      node,
+		config:    c,
+		lastFired: make(map[string]time.Time),
+	}, nil
+}
+
+// Run checks rules until ctx done.
+func (a *Alerter) Run(ctx context.Context) {
+	for {
+		a.check(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(a.config.Interval):
+		}
+	}
+}
+
+func (a *Alerter) check(ctx context.Context, now time.Time) {
+	families, err := a.config.Gatherer.Gather()
+	if err != nil {
+		a.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error gathering metrics for alerts", map[string]interface{}{"error": err.Error()}))
+		return
+	}
+	var samples []sample
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			if m.GetCounter() == nil {
+				continue
+			}
+			labels := make(map[string]string, len(m.GetLabel()))
+			for _, p := range m.GetLabel() {
+				labels[p.GetName()] = p.GetValue()
+			}
+			samples = append(samples, sample{metric: f.GetName(), labels: labels, value: m.GetCounter().GetValue()})
+		}
+	}
+	totals := make(map[string]float64, len(a.config.Rules))
+	for _, rule := range a.config.Rules {
+		totals[rule.Name] = sumSources(samples, rule.Sources)
+	}
+	prev := a.prev
+	a.prev = totals
+	if prev == nil {
+		// First check only remembers counters.
+		return
+	}
+
+	var alerts []Alert
+	for _, rule := range a.config.Rules {
+		if rule.Threshold <= 0 {
+			continue
+		}
+		count := totals[rule.Name] - prev[rule.Name]
+		if count < rule.Threshold {
+			continue
+		}
+		if last, ok := a.lastFired[rule.Name]; ok && now.Sub(last) < a.config.Cooldown {
+			continue
+		}
+		a.lastFired[rule.Name] = now
+		alertsFired.WithLabelValues(rule.Name).Inc()
+		alerts = append(alerts, Alert{Rule: rule.Name, Count: count, Threshold: rule.Threshold})
+	}
+	if len(alerts) == 0 {
+		return
+	}
+	report := Report{
+		Node:     a.config.NodeName,
+		Time:     now,
+		Interval: a.config.Interval.String(),
+		Alerts:   alerts,
+	}
+	a.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "error thresholds exceeded, sending alert", map[string]interface{}{"alerts": alerts}))
+	if err := a.config.Sender.Send(ctx, report); err != nil {
+		sendErrors.Inc()
+		a.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error sending alert", map[string]interface{}{"error": err.Error()}))
+	}
+}
+
+// sample is a value of counter.
+type sample struct {
+	metric string
+	labels map[string]string
+	value  float64
+}
+
+func sumSources(samples []sample, sources []Source) float64 {
+	var sum float64
+	for _, smp := range samples {
+		for _, s := range sources {
+			if smp.metric == s.Metric && labelsMatch(smp.labels, s.Labels) {
+				sum += smp.value
+				break
+			}
+		}
+	}
+	return sum
+}
+
+func labelsMatch(labels map[string]string, match map[string]string) bool {
+	for name, value := range match {
+		if labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// WebhookSender posts Report as JSON to URL.
+type WebhookSender struct {
+	URL    string
+	Client *http.Client
+}
+
+// Send Report to webhook.
+func (s *WebhookSender) Send(ctx context.Context, r Report) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected webhook response status %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+type testSender struct {
+	reports []Report
+}
+
+func (s *testSender) Send(_ context.Context, r Report) error {
+	s.reports = append(s.reports, r)
+	return nil
+}
+
+func TestAlerterCheck(t *testing.T) {
+	node, err := centrifuge.New(centrifuge.DefaultConfig)
+	require.NoError(t, err)
+	registry := prometheus.NewRegistry()
+	disconnects := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "centrifugo_client_num_server_disconnects",
+	}, []string{"code"})
+	registry.MustRegister(disconnects)
+
+	sender := &testSender{}
+	a, err := New(node, Config{
+		Rules:    []Rule{SlowDisconnectsRule(10), AuthFailuresRule(0)},
+		Sender:   sender,
+		NodeName: "node",
+		Cooldown: time.Minute,
+		Gatherer: registry,
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	disconnects.WithLabelValues("3008").Add(100)
+	// First check remembers counters.
+	a.check(context.Background(), now)
+	require.Len(t, sender.reports, 0)
+
+	disconnects.WithLabelValues("3008").Add(5)
+	disconnects.WithLabelValues("3002").Add(100)
+	a.check(context.Background(), now.Add(time.Second))
+	require.Len(t, sender.reports, 0)
+
+	disconnects.WithLabelValues("3008").Add(10)
+	a.check(context.Background(), now.Add(2*time.Second))
+	require.Len(t, sender.reports, 1)
+	require.Equal(t, "node", sender.reports[0].Node)
+	require.Equal(t, []Alert{{Rule: RuleSlowDisconnects, Count: 10, Threshold: 10}}, sender.reports[0].Alerts)
+
+	// Cooldown.
+	disconnects.WithLabelValues("3008").Add(10)
+	a.check(context.Background(), now.Add(3*time.Second))
+	require.Len(t, sender.reports, 1)
+
+	disconnects.WithLabelValues("3008").Add(10)
+	a.check(context.Background(), now.Add(2*time.Minute))
+	require.Len(t, sender.reports, 2)
+}
+
+func TestWebhookSender(t *testing.T) {
+	var report Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&report))
+	}))
+	defer server.Close()
+
+	s := &WebhookSender{URL: server.URL}
+	require.NoError(t, s.Send(context.Background(), Report{Node: "node", Alerts: []Alert{{Rule: RuleEngineErrors, Count: 3, Threshold: 1}}}))
+	require.Equal(t, "node", report.Node)
+	require.Len(t, report.Alerts, 1)
+
+	failing := httptest.NewServer(http.NotFoundHandler())
+	defer failing.Close()
+	s = &WebhookSender{URL: failing.URL}
+	require.Error(t, s.Send(context.Background(), Report{}))
+}
@@ -0,0 +1,27 @@
+package alert
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricsNamespace = "centrifugo"
+
+var (
+	alertsFired = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "alert",
+		Name:      "fired_total",
+		Help:      "Number of alerts fired by rule.",
+	}, []string{"rule"})
+	sendErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "alert",
+		Name:      "send_errors_total",
+		Help:      "Number of errors sending alert reports.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(alertsFired)
+	prometheus.MustRegister(sendErrors)
+}
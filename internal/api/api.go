@@ -3,12 +3,26 @@ package api
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/centrifugal/centrifugo/v3/internal/apiaudit"
 	. "github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/disconnect"
+	"github.com/centrifugal/centrifugo/v3/internal/errorcode"
+	"github.com/centrifugal/centrifugo/v3/internal/fanin"
+	"github.com/centrifugal/centrifugo/v3/internal/interceptor"
+	"github.com/centrifugal/centrifugo/v3/internal/limits"
+	"github.com/centrifugal/centrifugo/v3/internal/nsmetrics"
+	"github.com/centrifugal/centrifugo/v3/internal/publisher"
+	"github.com/centrifugal/centrifugo/v3/internal/puborigin"
+	"github.com/centrifugal/centrifugo/v3/internal/pushnotify"
 	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/survey"
+	"github.com/centrifugal/centrifugo/v3/internal/tenant"
 
 	"github.com/centrifugal/centrifuge"
 )
@@ -23,11 +37,145 @@ type Executor struct {
 	protocol      string
 	rpcExtension  map[string]RPCHandler
 	surveyCaller  SurveyCaller
+	locker        Locker
+	timeHistory   TimeHistory
+	historyMeta   HistoryMetaReader
+	compactor     HistoryCompactor
+	metaStore     ChannelMetaStore
+	readPositions ReadPositionStore
+	overrides     rule.OverrideStore
+	channelGroups ChannelGroupManager
+	priorityPub   PriorityPublisher
+	audit         *apiaudit.Log
+	nsMetrics     *nsmetrics.Observer
+	interceptors  *interceptor.Chain
+	tenants       *tenant.Registry
+	publisher     *publisher.Publisher
+	pushNotifier  PushNotifier
+	presence      PresenceLimiter
+	limits        *limits.Container
+	noWait        *noWaitQueue
 }
 
 // SurveyCaller can do surveys.
 type SurveyCaller interface {
 	Channels(ctx context.Context, cmd *ChannelsRequest) (map[string]*ChannelInfo, error)
+	ChannelsCount(ctx context.Context, cmd *ChannelsCountRequest) (uint64, error)
+	UserConnections(ctx context.Context, cmd *UserConnectionsRequest) (map[string]*UserConnectionInfo, error)
+	ChannelSubscribers(ctx context.Context, cmd *ChannelSubscribersRequest) (map[string]uint32, error)
+	UnsubscribeChannel(ctx context.Context, cmd *UnsubscribeChannelRequest) (map[string]uint32, error)
+	ReloadConfig(ctx context.Context, cmd *ReloadConfigRequest) error
+	SetChannelOptions(ctx context.Context, cmd *SetChannelOptionsRequest) error
+	ConnectionEvents(ctx context.Context, cmd *ConnectionEventsRequest) ([]*ConnectionEvent, error)
+	AddRedisShard(ctx context.Context, cmd *AddRedisShardRequest) (uint32, error)
+	MigrateConnections(ctx context.Context, cmd *MigrateConnectionsRequest) (uint32, error)
+}
+
+// Locker manages distributed locks.
+type Locker interface {
+	// AcquireLock acquires lock for owner for ttl. Lock already held by the
+	// same owner extended for ttl. Returns false if lock held by another owner.
+	AcquireLock(name string, owner string, ttl time.Duration) (bool, error)
+	// ReleaseLock releases lock held by owner. Returns false if lock not held
+	// by owner.
+	ReleaseLock(name string, owner string) (bool, error)
+}
+
+// TimeHistory can return channel history starting from time.
+type TimeHistory interface {
+	// HistoryFromTime returns publications added to history at or after from
+	// time, oldest first. Limit has the same meaning as in HistoryFilter.
+	HistoryFromTime(ch string, from time.Time, limit int) ([]*centrifuge.Publication, centrifuge.StreamPosition, error)
+}
+
+// HistoryMetaInfo describes state of channel history stream.
+type HistoryMetaInfo struct {
+	// NumPublications is a number of publications kept in history.
+	NumPublications int
+	// OldestOffset is an offset of the oldest publication in history.
+	OldestOffset uint64
+	// Offset is a top offset of stream.
+	Offset uint64
+	// Epoch of stream.
+	Epoch string
+	// TTL is a remaining time until history expires, zero if unknown.
+	TTL time.Duration
+}
+
+// HistoryMetaReader can return state of channel history without fetching
+// publications.
+type HistoryMetaReader interface {
+	HistoryMeta(ch string) (HistoryMetaInfo, error)
+}
+
+// HistoryCompactor compacts channel history by key.
+type HistoryCompactor interface {
+	// CompactHistory makes publication at stream position sp the latest one
+	// for key in channel history, previous publication with the same key is
+	// removed from history.
+	CompactHistory(ch string, key string, sp centrifuge.StreamPosition) error
+}
+
+// ChannelMetaStore keeps small key-value entries of channels.
+type ChannelMetaStore interface {
+	// SetChannelMeta sets value of channel meta key. Zero ttl means that entry
+	// never expires.
+	SetChannelMeta(ch string, key string, value []byte, ttl time.Duration) error
+	// ChannelMeta returns all not expired meta entries of channel.
+	ChannelMeta(ch string) (map[string][]byte, error)
+	// DeleteChannelMeta deletes channel meta key.
+	DeleteChannelMeta(ch string, key string) error
+}
+
+// ReadPositionStore keeps last read stream positions of users in channels.
+type ReadPositionStore interface {
+	// SetReadPosition sets last read position of user in channel. Position
+	// is only moved forward within the same epoch.
+	SetReadPosition(user string, ch string, sp centrifuge.StreamPosition) error
+	// ReadPositions returns last read positions of user in channels. Channels
+	// without position are not included into result.
+	ReadPositions(user string, channels []string) (map[string]centrifuge.StreamPosition, error)
+}
+
+// ChannelGroupManager manages source channels of channel groups.
+type ChannelGroupManager interface {
+	// ChannelGroup returns source channels of channel group.
+	ChannelGroup(ch string) ([]string, error)
+	// SetChannelGroup sets source channels of channel group.
+	SetChannelGroup(ch string, sources []string) error
+	// RemoveChannelGroup removes channel group.
+	RemoveChannelGroup(ch string) error
+}
+
+// PriorityPublisher can publish data ahead of bulk traffic.
+type PriorityPublisher interface {
+	// PublishPriority publishes data to channel with priority.
+	PublishPriority(ch string, data []byte, opts centrifuge.PublishOptions) (centrifuge.StreamPosition, error)
+}
+
+// PushNotifier sends publications to devices of offline users and keeps
+// devices of users.
+type PushNotifier interface {
+	publisher.PushNotifier
+	// Store returns store of user devices.
+	Store() pushnotify.DeviceStore
+}
+
+// PresenceLimitedResult is a result of PresenceLimiter call.
+type PresenceLimitedResult struct {
+	Presence map[string]*centrifuge.ClientInfo
+	// Truncated is true if channel has more entries than limit.
+	Truncated bool
+	// Partial is true if ctx done before all entries read.
+	Partial bool
+}
+
+// PresenceLimiter can read part of channel presence.
+type PresenceLimiter interface {
+	// PresenceLimited returns at most limit presence entries of channel, zero
+	// limit means no limit. If partial is true entries read before ctx done
+	// returned, otherwise ctx error returned in this case.
+	PresenceLimited(ctx context.Context, ch string, limit int, partial bool) (PresenceLimitedResult, error)
 }
 
 // NewExecutor ...
@@ -38,6 +186,8 @@ func NewExecutor(n *centrifuge.Node, ruleContainer *rule.Container, surveyCaller
 		protocol:      protocol,
 		surveyCaller:  surveyCaller,
 		rpcExtension:  make(map[string]RPCHandler),
+		noWait:        newNoWaitQueue(DefaultNoWaitWorkers, DefaultNoWaitQueueSize),
+		publisher:     publisher.New(n, ruleContainer),
 	}
 	return e
 }
@@ -47,14 +197,162 @@ func (h Executor) SetRPCExtension(method string, handler RPCHandler) {
 	h.rpcExtension[method] = handler
 }
 
+// SetLocker sets Locker to use for lock methods. Lock methods are not
+// available without Locker.
+func (h *Executor) SetLocker(locker Locker) {
+	h.locker = locker
+}
+
+// SetNoWaitQueue sets number of workers publishing no_wait publications and
+// max number of publications waiting in queue of each worker. Publications
+// to the same channel published by one worker in order. No_wait requests
+// rejected with ErrorTooManyRequests when queue is full.
+func (h *Executor) SetNoWaitQueue(numWorkers int, size int) {
+	h.noWait = newNoWaitQueue(numWorkers, size)
+}
+
+// SetTimeHistory sets TimeHistory to use for history requests with since_ms.
+// Such requests are not available without TimeHistory.
+func (h *Executor) SetTimeHistory(timeHistory TimeHistory) {
+	h.timeHistory = timeHistory
+}
+
+// SetHistoryMetaReader sets HistoryMetaReader to use for history_meta requests.
+// Without HistoryMetaReader history meta is calculated from full channel history
+// loaded from engine, TTL is unknown in this case.
+func (h *Executor) SetHistoryMetaReader(r HistoryMetaReader) {
+	h.historyMeta = r
+}
+
+// SetHistoryCompactor sets HistoryCompactor to use for publications with
+// compaction key in channels with history compaction on. Such publications
+// are not available without HistoryCompactor.
+func (h *Executor) SetHistoryCompactor(compactor HistoryCompactor) {
+	h.compactor = compactor
+}
+
+// SetChannelMetaStore sets ChannelMetaStore to use for channel meta methods.
+// Channel meta methods are not available without ChannelMetaStore.
+func (h *Executor) SetChannelMetaStore(metaStore ChannelMetaStore) {
+	h.metaStore = metaStore
+}
+
+// SetReadPositionStore sets ReadPositionStore to use for read position
+// methods. Read position methods are not available without ReadPositionStore.
+func (h *Executor) SetReadPositionStore(store ReadPositionStore) {
+	h.readPositions = store
+}
+
+// SetChannelOptionsStore sets store of channel option overrides. Overrides
+// saved to store are loaded by nodes on start, without store overrides are
+// only kept in memory of running nodes.
+func (h *Executor) SetChannelOptionsStore(store rule.OverrideStore) {
+	h.overrides = store
+}
+
+// SetChannelGroupManager sets ChannelGroupManager to use for channel group
+// methods. Channel group methods are not available without it.
+func (h *Executor) SetChannelGroupManager(m ChannelGroupManager) {
+	h.channelGroups = m
+}
+
+// SetPriorityPublisher sets PriorityPublisher to use for publications with
+// priority flag. Without PriorityPublisher such publications are published
+// as usual.
+func (h *Executor) SetPriorityPublisher(p PriorityPublisher) {
+	h.priorityPub = p
+}
+
+// SetAuditLog sets Log to record API commands processed over HTTP API and
+// Redis API queues in. Commands of GRPC API recorded by GRPCAudit interceptor.
+func (h *Executor) SetAuditLog(l *apiaudit.Log) {
+	h.audit = l
+}
+
+// SetNamespaceMetrics sets Observer to count API publications by namespace.
+func (h *Executor) SetNamespaceMetrics(o *nsmetrics.Observer) {
+	h.nsMetrics = o
+}
+
+// SetInterceptors sets Chain of interceptors called on API publications.
+func (h *Executor) SetInterceptors(c *interceptor.Chain) {
+	h.interceptors = c
+}
+
+// SetTenants sets tenant Registry. Requests authorized with tenant API key
+// can only operate with channels of tenant.
+func (h *Executor) SetTenants(tenants *tenant.Registry) {
+	h.tenants = tenants
+}
+
+// SetPublisher sets Publisher shared with client handler to publish data
+// with. By default Publisher without inbox used.
+func (h *Executor) SetPublisher(p *publisher.Publisher) {
+	h.publisher = p
+}
+
+// SetPushNotifier sets PushNotifier to register devices of users with. Push
+// notifications are sent by Publisher, see Publisher.SetPushNotifier.
+func (h *Executor) SetPushNotifier(n PushNotifier) {
+	h.pushNotifier = n
+}
+
+// SetPresenceLimiter sets PresenceLimiter to read presence with limit and
+// deadline without loading full channel presence from engine. Without
+// PresenceLimiter full presence loaded and truncated afterwards.
+func (h *Executor) SetPresenceLimiter(l PresenceLimiter) {
+	h.presence = l
+}
+
+// SetLimits sets Container with limits. APIPresenceLimit is a max number of
+// entries returned by presence method, result truncated to it even if
+// request has no limit.
+func (h *Executor) SetLimits(c *limits.Container) {
+	h.limits = c
+}
+
+// checkTenant returns ErrorPermissionDenied if request made on behalf of
+// tenant and any of channels does not belong to tenant. Requests of tenants
+// without channels are not allowed since they can affect other tenants.
+func (h *Executor) checkTenant(ctx context.Context, channels ...string) *Error {
+	name, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if len(channels) == 0 {
+		return ErrorPermissionDenied
+	}
+	for _, ch := range channels {
+		if !h.tenants.Enabled() || h.tenants.ChannelTenant(ch) != name {
+			return ErrorPermissionDenied
+		}
+	}
+	return nil
+}
+
+// wrapPublication returns data in publication envelope and publication ID
+// which is returned in publish result.
+func (h *Executor) wrapPublication(chOpts rule.ChannelOptions, data []byte, tags map[string]string) ([]byte, string, *Error) {
+	wrapped, id, err := h.publisher.Wrap(chOpts, data, puborigin.Origin{Type: puborigin.TypeAPI}, tags)
+	if err != nil {
+		return nil, "", ErrorBadRequest
+	}
+	return wrapped, id, nil
+}
+
 // Publish publishes data into channel.
-func (h *Executor) Publish(_ context.Context, cmd *PublishRequest) *PublishResponse {
+func (h *Executor) Publish(ctx context.Context, cmd *PublishRequest) *PublishResponse {
 	defer observe(time.Now(), h.protocol, "publish")
 
 	ch := cmd.Channel
 
 	resp := &PublishResponse{}
 
+	if err := h.checkTenant(ctx, cmd.Channel); err != nil {
+		resp.Error = err
+		return resp
+	}
+
 	if ch == "" {
 		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "channel required for publish", nil))
 		resp.Error = ErrorBadRequest
@@ -96,28 +394,158 @@ func (h *Executor) Publish(_ context.Context, cmd *PublishRequest) *PublishRespo
 		historyTTL = 0
 	}
 
-	result, err := h.node.Publish(
-		cmd.Channel, cmd.Data,
+	compactionKey, apiErr := h.compactionKey(chOpts, historySize, cmd.CompactionKey)
+	if apiErr != nil {
+		resp.Error = apiErr
+		return resp
+	}
+
+	pub, apiErr := h.interceptPublish(ctx, cmd.Channel, data)
+	if apiErr != nil {
+		resp.Error = apiErr
+		return resp
+	}
+	if pub != nil {
+		data = pub.Data
+	}
+
+	data, pubID, apiErr := h.wrapPublication(chOpts, data, cmd.Tags)
+	if apiErr != nil {
+		resp.Error = apiErr
+		return resp
+	}
+
+	if cmd.NoWait {
+		if apiErr := h.publishNoWait(ctx, pub, cmd.Channel, data, "publish", compactionKey, cmd.Priority, centrifuge.WithHistory(historySize, time.Duration(historyTTL))); apiErr != nil {
+			resp.Error = apiErr
+			return resp
+		}
+		resp.Result = &PublishResult{Id: pubID}
+		return resp
+	}
+
+	result, err := h.publish(
+		cmd.Channel, data, cmd.Priority,
 		centrifuge.WithHistory(historySize, time.Duration(historyTTL)),
 	)
+	h.afterPublish(ctx, pub, result.StreamPosition, err)
 	if err != nil {
 		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error publishing message in engine", map[string]interface{}{"error": err.Error(), "channel": cmd.Channel}))
-		resp.Error = ErrorInternal
+		resp.Error = toAPIErr(err)
 		return resp
 	}
+	h.compactHistory(cmd.Channel, compactionKey, result.StreamPosition)
 	resp.Result = &PublishResult{
 		Offset: result.StreamPosition.Offset,
 		Epoch:  result.StreamPosition.Epoch,
+		Id:     pubID,
 	}
 	return resp
 }
 
+// publishNoWait queues publication of data to channel when caller does not
+// wait for result of publishing. Errors can only be logged and counted in
+// this case. ErrorTooManyRequests returned if publication can't be queued.
+func (h *Executor) publishNoWait(ctx context.Context, pub *interceptor.Publication, ch string, data []byte, method string, compactionKey string, priority bool, opts ...centrifuge.PublishOption) *Error {
+	queued := h.noWait.push(ch, func() {
+		result, err := h.publish(ch, data, priority, opts...)
+		h.afterPublish(ctx, pub, result.StreamPosition, err)
+		if err != nil {
+			noWaitPublishErrorCount.WithLabelValues(h.protocol, method).Inc()
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error publishing data to channel in no_wait mode", map[string]interface{}{"channel": ch, "error": err.Error()}))
+			return
+		}
+		h.compactHistory(ch, compactionKey, result.StreamPosition)
+	})
+	if !queued {
+		noWaitPublishRejectedCount.WithLabelValues(h.protocol, method).Inc()
+		h.afterPublish(ctx, pub, centrifuge.StreamPosition{}, ErrorTooManyRequests)
+		return ErrorTooManyRequests
+	}
+	return nil
+}
+
+// publish publishes data to channel. Priority publications are published
+// with PriorityPublisher if it's set.
+func (h *Executor) publish(ch string, data []byte, priority bool, opts ...centrifuge.PublishOption) (centrifuge.PublishResult, error) {
+	var result centrifuge.PublishResult
+	var err error
+	if !priority || h.priorityPub == nil {
+		result, err = h.publisher.Publish(ch, data, opts...)
+	} else {
+		pubOpts := &centrifuge.PublishOptions{}
+		for _, opt := range opts {
+			opt(pubOpts)
+		}
+		result.StreamPosition, err = h.priorityPub.PublishPriority(ch, data, *pubOpts)
+		if err == nil {
+			h.publisher.Published(ch, data)
+		}
+	}
+	h.nsMetrics.ObservePublish(nsmetrics.SourceAPI, ch, data, err)
+	return result, err
+}
+
+// interceptPublish calls publish interceptors. Returned publication is nil
+// if there are no interceptors, otherwise it contains data to publish.
+func (h *Executor) interceptPublish(ctx context.Context, ch string, data []byte) (*interceptor.Publication, *Error) {
+	if h.interceptors == nil {
+		return nil, nil
+	}
+	pub := &interceptor.Publication{
+		Channel: ch,
+		Data:    data,
+		Source:  interceptor.SourceAPI,
+	}
+	if err := h.interceptors.BeforePublish(ctx, pub); err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "publication denied by interceptor", map[string]interface{}{"channel": ch, "error": err.Error()}))
+		return nil, toAPIErr(err)
+	}
+	return pub, nil
+}
+
+func (h *Executor) afterPublish(ctx context.Context, pub *interceptor.Publication, sp centrifuge.StreamPosition, err error) {
+	if pub == nil {
+		return
+	}
+	h.interceptors.AfterPublish(ctx, *pub, sp, err)
+}
+
+// compactionKey returns compaction key to use for publication. Key is ignored
+// if history compaction is off for channel or publication skips history.
+func (h *Executor) compactionKey(chOpts rule.ChannelOptions, historySize int, key string) (string, *Error) {
+	if key == "" || !chOpts.HistoryCompaction || historySize == 0 {
+		return "", nil
+	}
+	if h.compactor == nil {
+		return "", ErrorNotAvailable
+	}
+	return key, nil
+}
+
+// compactHistory marks publication as the latest one for compaction key in
+// channel history. Publication already delivered at this point so errors are
+// only logged, history keeps previous publication with the same key then.
+func (h *Executor) compactHistory(ch string, key string, sp centrifuge.StreamPosition) {
+	if key == "" {
+		return
+	}
+	if err := h.compactor.CompactHistory(ch, key, sp); err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error compacting channel history", map[string]interface{}{"channel": ch, "key": key, "error": err.Error()}))
+	}
+}
+
 // Broadcast publishes the same data into many channels.
-func (h *Executor) Broadcast(_ context.Context, cmd *BroadcastRequest) *BroadcastResponse {
+func (h *Executor) Broadcast(ctx context.Context, cmd *BroadcastRequest) *BroadcastResponse {
 	defer observe(time.Now(), h.protocol, "broadcast")
 
 	resp := &BroadcastResponse{}
 
+	if err := h.checkTenant(ctx, cmd.Channels...); err != nil {
+		resp.Error = err
+		return resp
+	}
+
 	channels := cmd.Channels
 
 	if len(channels) == 0 {
@@ -175,19 +603,53 @@ func (h *Executor) Broadcast(_ context.Context, cmd *BroadcastRequest) *Broadcas
 				historyTTL = 0
 			}
 
-			result, err := h.node.Publish(
-				ch, data,
+			compactionKey, apiErr := h.compactionKey(chOpts, historySize, cmd.CompactionKey)
+			if apiErr != nil {
+				responses[i] = &PublishResponse{Error: apiErr}
+				return
+			}
+
+			data := data
+			pub, apiErr := h.interceptPublish(ctx, ch, data)
+			if apiErr != nil {
+				responses[i] = &PublishResponse{Error: apiErr}
+				return
+			}
+			if pub != nil {
+				data = pub.Data
+			}
+
+			data, pubID, apiErr := h.wrapPublication(chOpts, data, cmd.Tags)
+			if apiErr != nil {
+				responses[i] = &PublishResponse{Error: apiErr}
+				return
+			}
+
+			if cmd.NoWait {
+				if apiErr := h.publishNoWait(ctx, pub, ch, data, "broadcast", compactionKey, cmd.Priority, centrifuge.WithHistory(historySize, time.Duration(historyTTL))); apiErr != nil {
+					responses[i] = &PublishResponse{Error: apiErr}
+					return
+				}
+				responses[i] = &PublishResponse{Result: &PublishResult{Id: pubID}}
+				return
+			}
+
+			result, err := h.publish(
+				ch, data, cmd.Priority,
 				centrifuge.WithHistory(historySize, time.Duration(historyTTL)),
 			)
+			h.afterPublish(ctx, pub, result.StreamPosition, err)
 			resp := &PublishResponse{}
 			if err == nil {
+				h.compactHistory(ch, compactionKey, result.StreamPosition)
 				resp.Result = &PublishResult{
 					Offset: result.StreamPosition.Offset,
 					Epoch:  result.StreamPosition.Epoch,
+					Id:     pubID,
 				}
 			} else {
 				h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error publishing data to channel", map[string]interface{}{"channel": ch, "error": err.Error()}))
-				resp.Error = ErrorInternal
+				resp.Error = toAPIErr(err)
 			}
 			responses[i] = resp
 		}(i, ch)
@@ -199,11 +661,16 @@ func (h *Executor) Broadcast(_ context.Context, cmd *BroadcastRequest) *Broadcas
 
 // Subscribe subscribes user to a channel and sends subscribe
 // control message to other nodes so they could also subscribe user.
-func (h *Executor) Subscribe(_ context.Context, cmd *SubscribeRequest) *SubscribeResponse {
+func (h *Executor) Subscribe(ctx context.Context, cmd *SubscribeRequest) *SubscribeResponse {
 	defer observe(time.Now(), h.protocol, "subscribe")
 
 	resp := &SubscribeResponse{}
 
+	if err := h.checkTenant(ctx, cmd.Channel); err != nil {
+		resp.Error = err
+		return resp
+	}
+
 	user := cmd.User
 	channel := cmd.Channel
 
@@ -267,7 +734,7 @@ func (h *Executor) Subscribe(_ context.Context, cmd *SubscribeRequest) *Subscrib
 	)
 	if err != nil {
 		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error subscribing user to a channel", map[string]interface{}{"channel": channel, "user": user, "error": err.Error()}))
-		resp.Error = ErrorInternal
+		resp.Error = toAPIErr(err)
 		return resp
 	}
 	return resp
@@ -275,11 +742,16 @@ func (h *Executor) Subscribe(_ context.Context, cmd *SubscribeRequest) *Subscrib
 
 // Unsubscribe unsubscribes user from channel and sends unsubscribe
 // control message to other nodes so they could also unsubscribe user.
-func (h *Executor) Unsubscribe(_ context.Context, cmd *UnsubscribeRequest) *UnsubscribeResponse {
+func (h *Executor) Unsubscribe(ctx context.Context, cmd *UnsubscribeRequest) *UnsubscribeResponse {
 	defer observe(time.Now(), h.protocol, "unsubscribe")
 
 	resp := &UnsubscribeResponse{}
 
+	if err := h.checkTenant(ctx, cmd.Channel); err != nil {
+		resp.Error = err
+		return resp
+	}
+
 	user := cmd.User
 	channel := cmd.Channel
 
@@ -304,7 +776,7 @@ func (h *Executor) Unsubscribe(_ context.Context, cmd *UnsubscribeRequest) *Unsu
 	err := h.node.Unsubscribe(user, channel, centrifuge.WithUnsubscribeClient(cmd.Client))
 	if err != nil {
 		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error unsubscribing user from a channel", map[string]interface{}{"channel": channel, "user": user, "error": err.Error()}))
-		resp.Error = ErrorInternal
+		resp.Error = toAPIErr(err)
 		return resp
 	}
 	return resp
@@ -312,11 +784,18 @@ func (h *Executor) Unsubscribe(_ context.Context, cmd *UnsubscribeRequest) *Unsu
 
 // Disconnect disconnects user by its ID and sends disconnect
 // control message to other nodes so they could also disconnect user.
-func (h *Executor) Disconnect(_ context.Context, cmd *DisconnectRequest) *DisconnectResponse {
+// Disconnect code must be one of standard codes or belong to range of
+// application codes, see disconnect package.
+func (h *Executor) Disconnect(ctx context.Context, cmd *DisconnectRequest) *DisconnectResponse {
 	defer observe(time.Now(), h.protocol, "disconnect")
 
 	resp := &DisconnectResponse{}
 
+	if err := h.checkTenant(ctx); err != nil {
+		resp.Error = err
+		return resp
+	}
+
 	user := cmd.User
 	if user == "" {
 		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "user required for disconnect"))
@@ -324,36 +803,43 @@ func (h *Executor) Disconnect(_ context.Context, cmd *DisconnectRequest) *Discon
 		return resp
 	}
 
-	var disconnect *centrifuge.Disconnect
+	var d *centrifuge.Disconnect
 	if cmd.Disconnect == nil {
-		disconnect = centrifuge.DisconnectForceNoReconnect
+		d = centrifuge.DisconnectForceNoReconnect
 	} else {
-		disconnect = &centrifuge.Disconnect{
-			Code:      cmd.Disconnect.Code,
-			Reason:    cmd.Disconnect.Reason,
-			Reconnect: cmd.Disconnect.Reconnect,
+		var err error
+		d, err = disconnect.New(cmd.Disconnect.Code, cmd.Disconnect.Reason, cmd.Disconnect.Reconnect)
+		if err != nil {
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "invalid disconnect code", map[string]interface{}{"user": cmd.User, "code": cmd.Disconnect.Code}))
+			resp.Error = ErrorBadRequest
+			return resp
 		}
 	}
 
 	err := h.node.Disconnect(
 		user,
-		centrifuge.WithDisconnect(disconnect),
+		centrifuge.WithDisconnect(d),
 		centrifuge.WithDisconnectClient(cmd.Client),
 		centrifuge.WithDisconnectClientWhitelist(cmd.Whitelist))
 	if err != nil {
 		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error disconnecting user", map[string]interface{}{"user": cmd.User, "error": err.Error()}))
-		resp.Error = ErrorInternal
+		resp.Error = toAPIErr(err)
 		return resp
 	}
 	return resp
 }
 
 // Refresh user connection by its ID.
-func (h *Executor) Refresh(_ context.Context, cmd *RefreshRequest) *RefreshResponse {
+func (h *Executor) Refresh(ctx context.Context, cmd *RefreshRequest) *RefreshResponse {
 	defer observe(time.Now(), h.protocol, "refresh")
 
 	resp := &RefreshResponse{}
 
+	if err := h.checkTenant(ctx); err != nil {
+		resp.Error = err
+		return resp
+	}
+
 	user := cmd.User
 	if user == "" {
 		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "user required for refresh"))
@@ -370,18 +856,23 @@ func (h *Executor) Refresh(_ context.Context, cmd *RefreshRequest) *RefreshRespo
 	)
 	if err != nil {
 		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error refreshing user", map[string]interface{}{"user": cmd.User, "error": err.Error()}))
-		resp.Error = ErrorInternal
+		resp.Error = toAPIErr(err)
 		return resp
 	}
 	return resp
 }
 
 // Presence returns response with presence information for channel.
-func (h *Executor) Presence(_ context.Context, cmd *PresenceRequest) *PresenceResponse {
+func (h *Executor) Presence(ctx context.Context, cmd *PresenceRequest) *PresenceResponse {
 	defer observe(time.Now(), h.protocol, "presence")
 
 	resp := &PresenceResponse{}
 
+	if err := h.checkTenant(ctx, cmd.Channel); err != nil {
+		resp.Error = err
+		return resp
+	}
+
 	ch := cmd.Channel
 
 	if ch == "" {
@@ -404,10 +895,28 @@ func (h *Executor) Presence(_ context.Context, cmd *PresenceRequest) *PresenceRe
 		return resp
 	}
 
-	presence, err := h.node.Presence(ch)
+	if cmd.Limit < 0 {
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+	limit := int(cmd.Limit)
+	if presenceLimit := h.limits.Config().APIPresenceLimit; presenceLimit > 0 && (limit == 0 || limit > presenceLimit) {
+		limit = presenceLimit
+	}
+	if cmd.TimeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(cmd.TimeoutMs)*time.Millisecond)
+		defer cancel()
+	}
+
+	presence, err := h.presenceLimited(ctx, ch, limit, cmd.Partial)
 	if err != nil {
 		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error calling presence", map[string]interface{}{"error": err.Error()}))
-		resp.Error = ErrorInternal
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			resp.Error = ErrorInternal
+			return resp
+		}
+		resp.Error = toAPIErr(err)
 		return resp
 	}
 
@@ -422,17 +931,70 @@ func (h *Executor) Presence(_ context.Context, cmd *PresenceRequest) *PresenceRe
 	}
 
 	resp.Result = &PresenceResult{
-		Presence: apiPresence,
+		Presence:  apiPresence,
+		Truncated: presence.Truncated,
+		Partial:   presence.Partial,
 	}
 	return resp
 }
 
+// presenceLimited reads presence with PresenceLimiter if set. Otherwise full
+// presence loaded from node until ctx done and truncated to limit entries
+// with the lowest client IDs, so repeated calls return the same entries.
+func (h *Executor) presenceLimited(ctx context.Context, ch string, limit int, partial bool) (PresenceLimitedResult, error) {
+	if h.presence != nil {
+		return h.presence.PresenceLimited(ctx, ch, limit, partial)
+	}
+	type presenceReply struct {
+		result centrifuge.PresenceResult
+		err    error
+	}
+	replyCh := make(chan presenceReply, 1)
+	go func() {
+		result, err := h.node.Presence(ch)
+		replyCh <- presenceReply{result: result, err: err}
+	}()
+	var reply presenceReply
+	select {
+	case reply = <-replyCh:
+	case <-ctx.Done():
+		if !partial {
+			return PresenceLimitedResult{}, ctx.Err()
+		}
+		// Nothing read yet.
+		return PresenceLimitedResult{Partial: true}, nil
+	}
+	if reply.err != nil {
+		return PresenceLimitedResult{}, reply.err
+	}
+	result := PresenceLimitedResult{Presence: reply.result.Presence}
+	if limit > 0 && len(result.Presence) > limit {
+		clientIDs := make([]string, 0, len(result.Presence))
+		for clientID := range result.Presence {
+			clientIDs = append(clientIDs, clientID)
+		}
+		sort.Strings(clientIDs)
+		truncated := make(map[string]*centrifuge.ClientInfo, limit)
+		for _, clientID := range clientIDs[:limit] {
+			truncated[clientID] = result.Presence[clientID]
+		}
+		result.Presence = truncated
+		result.Truncated = true
+	}
+	return result, nil
+}
+
 // PresenceStats returns response with presence stats information for channel.
-func (h *Executor) PresenceStats(_ context.Context, cmd *PresenceStatsRequest) *PresenceStatsResponse {
+func (h *Executor) PresenceStats(ctx context.Context, cmd *PresenceStatsRequest) *PresenceStatsResponse {
 	defer observe(time.Now(), h.protocol, "presence_stats")
 
 	resp := &PresenceStatsResponse{}
 
+	if err := h.checkTenant(ctx, cmd.Channel); err != nil {
+		resp.Error = err
+		return resp
+	}
+
 	ch := cmd.Channel
 
 	if ch == "" {
@@ -458,7 +1020,7 @@ func (h *Executor) PresenceStats(_ context.Context, cmd *PresenceStatsRequest) *
 	stats, err := h.node.PresenceStats(cmd.Channel)
 	if err != nil {
 		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error calling presence stats", map[string]interface{}{"error": err.Error()}))
-		resp.Error = ErrorInternal
+		resp.Error = toAPIErr(err)
 		return resp
 	}
 
@@ -471,11 +1033,16 @@ func (h *Executor) PresenceStats(_ context.Context, cmd *PresenceStatsRequest) *
 }
 
 // History returns response with history information for channel.
-func (h *Executor) History(_ context.Context, cmd *HistoryRequest) *HistoryResponse {
+func (h *Executor) History(ctx context.Context, cmd *HistoryRequest) *HistoryResponse {
 	defer observe(time.Now(), h.protocol, "history")
 
 	resp := &HistoryResponse{}
 
+	if err := h.checkTenant(ctx, cmd.Channel); err != nil {
+		resp.Error = err
+		return resp
+	}
+
 	ch := cmd.Channel
 
 	if ch == "" {
@@ -498,27 +1065,42 @@ func (h *Executor) History(_ context.Context, cmd *HistoryRequest) *HistoryRespo
 		return resp
 	}
 
-	var sp *centrifuge.StreamPosition
-	if cmd.Since != nil {
-		sp = &centrifuge.StreamPosition{
-			Epoch:  cmd.Since.Epoch,
-			Offset: cmd.Since.Offset,
+	var history centrifuge.HistoryResult
+	if cmd.SinceMs > 0 {
+		if cmd.Since != nil || cmd.Reverse {
+			resp.Error = ErrorBadRequest
+			return resp
+		}
+		if h.timeHistory == nil {
+			resp.Error = ErrorNotAvailable
+			return resp
 		}
+		var pubs []*centrifuge.Publication
+		var sp centrifuge.StreamPosition
+		pubs, sp, err = h.timeHistory.HistoryFromTime(ch, time.Unix(0, cmd.SinceMs*int64(time.Millisecond)), int(cmd.Limit))
+		history = centrifuge.HistoryResult{StreamPosition: sp, Publications: pubs}
+	} else {
+		var sp *centrifuge.StreamPosition
+		if cmd.Since != nil {
+			sp = &centrifuge.StreamPosition{
+				Epoch:  cmd.Since.Epoch,
+				Offset: cmd.Since.Offset,
+			}
+		}
+		history, err = h.node.History(
+			ch,
+			centrifuge.WithLimit(int(cmd.Limit)),
+			centrifuge.WithSince(sp),
+			centrifuge.WithReverse(cmd.Reverse),
+		)
 	}
-
-	history, err := h.node.History(
-		ch,
-		centrifuge.WithLimit(int(cmd.Limit)),
-		centrifuge.WithSince(sp),
-		centrifuge.WithReverse(cmd.Reverse),
-	)
 	if err != nil {
 		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error calling history", map[string]interface{}{"error": err.Error()}))
 		if errors.Is(err, centrifuge.ErrorUnrecoverablePosition) {
 			resp.Error = ErrorUnrecoverablePosition
 			return resp
 		}
-		resp.Error = ErrorInternal
+		resp.Error = toAPIErr(err)
 		return resp
 	}
 
@@ -549,11 +1131,16 @@ func (h *Executor) History(_ context.Context, cmd *HistoryRequest) *HistoryRespo
 }
 
 // HistoryRemove removes all history information for channel.
-func (h *Executor) HistoryRemove(_ context.Context, cmd *HistoryRemoveRequest) *HistoryRemoveResponse {
+func (h *Executor) HistoryRemove(ctx context.Context, cmd *HistoryRemoveRequest) *HistoryRemoveResponse {
 	defer observe(time.Now(), h.protocol, "history_remove")
 
 	resp := &HistoryRemoveResponse{}
 
+	if err := h.checkTenant(ctx, cmd.Channel); err != nil {
+		resp.Error = err
+		return resp
+	}
+
 	ch := cmd.Channel
 
 	if ch == "" {
@@ -579,7 +1166,7 @@ func (h *Executor) HistoryRemove(_ context.Context, cmd *HistoryRemoveRequest) *
 	err = h.node.RemoveHistory(ch)
 	if err != nil {
 		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error calling history remove", map[string]interface{}{"error": err.Error()}))
-		resp.Error = ErrorInternal
+		resp.Error = toAPIErr(err)
 		return resp
 	}
 
@@ -587,15 +1174,20 @@ func (h *Executor) HistoryRemove(_ context.Context, cmd *HistoryRemoveRequest) *
 }
 
 // Info returns information about running nodes.
-func (h *Executor) Info(_ context.Context, _ *InfoRequest) *InfoResponse {
+func (h *Executor) Info(ctx context.Context, _ *InfoRequest) *InfoResponse {
 	defer observe(time.Now(), h.protocol, "info")
 
 	resp := &InfoResponse{}
 
+	if err := h.checkTenant(ctx); err != nil {
+		resp.Error = err
+		return resp
+	}
+
 	info, err := h.node.Info()
 	if err != nil {
 		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error calling info", map[string]interface{}{"error": err.Error()}))
-		resp.Error = ErrorInternal
+		resp.Error = toAPIErr(err)
 		return resp
 	}
 
@@ -633,6 +1225,11 @@ func (h *Executor) RPC(ctx context.Context, cmd *RPCRequest) *RPCResponse {
 
 	resp := &RPCResponse{}
 
+	if err := h.checkTenant(ctx); err != nil {
+		resp.Error = err
+		return resp
+	}
+
 	if cmd.Method == "" {
 		resp.Error = ErrorBadRequest
 		return resp
@@ -667,23 +1264,962 @@ func (h *Executor) Channels(ctx context.Context, cmd *ChannelsRequest) *Channels
 
 	resp := &ChannelsResponse{}
 
-	channels, err := h.surveyCaller.Channels(ctx, cmd)
-	if err != nil {
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error calling channels", map[string]interface{}{"error": err.Error()}))
-		resp.Error = toAPIErr(err)
+	if cmd.Limit < 0 {
+		resp.Error = ErrorBadRequest
 		return resp
 	}
 
+	tenantName, isTenant := tenant.FromContext(ctx)
+
+	channels := map[string]*ChannelInfo{}
+	var nextCursor string
+	var found []string
+	req := &ChannelsRequest{Pattern: cmd.Pattern, Cursor: cmd.Cursor, Limit: cmd.Limit}
+	for {
+		pageChannels, err := h.surveyCaller.Channels(ctx, req)
+		if err != nil {
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error calling channels", map[string]interface{}{"error": err.Error()}))
+			resp.Error = toAPIErr(err)
+			return resp
+		}
+		if cmd.Limit == 0 {
+			for ch, info := range pageChannels {
+				if !isTenant || h.tenants.ChannelTenant(ch) == tenantName {
+					channels[ch] = info
+				}
+			}
+			break
+		}
+		// Every node returns up to limit+1 channels after cursor, page
+		// consists of first limit channels of all nodes. Tenant channels
+		// filtered from page, so next pages are requested until limit+1
+		// tenant channels found to know whether there is next page.
+		names := make([]string, 0, len(pageChannels))
+		for ch := range pageChannels {
+			names = append(names, ch)
+		}
+		sort.Strings(names)
+		hasMore := len(names) > int(cmd.Limit)
+		if hasMore {
+			names = names[:cmd.Limit]
+		}
+		for _, ch := range names {
+			if isTenant && h.tenants.ChannelTenant(ch) != tenantName {
+				continue
+			}
+			found = append(found, ch)
+			channels[ch] = pageChannels[ch]
+			if len(found) > int(cmd.Limit) {
+				break
+			}
+		}
+		if len(found) > int(cmd.Limit) {
+			delete(channels, found[cmd.Limit])
+			nextCursor = found[cmd.Limit-1]
+			break
+		}
+		if !hasMore {
+			break
+		}
+		if !isTenant {
+			// All channels of page belong to result, more channels follow.
+			nextCursor = names[len(names)-1]
+			break
+		}
+		req.Cursor = names[len(names)-1]
+	}
+
 	resp.Result = &ChannelsResult{
-		Channels: channels,
+		Channels:   channels,
+		NextCursor: nextCursor,
 	}
 
 	return resp
 }
 
-func toAPIErr(err error) *Error {
-	if apiErr, ok := err.(*Error); ok {
-		return apiErr
+// ChannelsCount returns approximate number of active channels on all nodes.
+func (h *Executor) ChannelsCount(ctx context.Context, cmd *ChannelsCountRequest) *ChannelsCountResponse {
+	defer observe(time.Now(), h.protocol, "channels_count")
+
+	resp := &ChannelsCountResponse{}
+
+	if err := h.checkTenant(ctx); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	count, err := h.surveyCaller.ChannelsCount(ctx, cmd)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error calling channels count", map[string]interface{}{"error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	resp.Result = &ChannelsCountResult{Count: count}
+	return resp
+}
+
+// UserConnections returns active connections of user from all nodes.
+func (h *Executor) UserConnections(ctx context.Context, cmd *UserConnectionsRequest) *UserConnectionsResponse {
+	defer observe(time.Now(), h.protocol, "user_connections")
+
+	resp := &UserConnectionsResponse{}
+
+	if err := h.checkTenant(ctx); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if cmd.User == "" {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "user required for user connections", nil))
+		resp.Error = ErrorBadRequest
+		return resp
 	}
-	return ErrorInternal
+
+	connections, err := h.surveyCaller.UserConnections(ctx, cmd)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error calling user connections", map[string]interface{}{"error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	resp.Result = &UserConnectionsResult{
+		Connections: connections,
+	}
+	return resp
+}
+
+// ChannelSubscribers returns number of channel subscribers on every node.
+func (h *Executor) ChannelSubscribers(ctx context.Context, cmd *ChannelSubscribersRequest) *ChannelSubscribersResponse {
+	defer observe(time.Now(), h.protocol, "channel_subscribers")
+
+	resp := &ChannelSubscribersResponse{}
+
+	if err := h.checkTenant(ctx, cmd.Channel); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if cmd.Channel == "" {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "channel required for channel subscribers", nil))
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	nodes, err := h.surveyCaller.ChannelSubscribers(ctx, cmd)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error calling channel subscribers", map[string]interface{}{"error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	var numClients uint32
+	for _, n := range nodes {
+		numClients += n
+	}
+
+	resp.Result = &ChannelSubscribersResult{
+		NumClients: numClients,
+		Nodes:      nodes,
+	}
+	return resp
+}
+
+// UnsubscribeChannel unsubscribes all clients on all running nodes from
+// channel. Channel history is optionally removed after that. Presence
+// information is removed by clients when they are unsubscribed.
+func (h *Executor) UnsubscribeChannel(ctx context.Context, cmd *UnsubscribeChannelRequest) *UnsubscribeChannelResponse {
+	defer observe(time.Now(), h.protocol, "unsubscribe_channel")
+
+	resp := &UnsubscribeChannelResponse{}
+
+	if err := h.checkTenant(ctx, cmd.Channel); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	ch := cmd.Channel
+
+	if ch == "" {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "channel required for unsubscribe channel", nil))
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	nodes, err := h.surveyCaller.UnsubscribeChannel(ctx, cmd)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error calling unsubscribe channel", map[string]interface{}{"channel": ch, "error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	var numClients uint32
+	for _, n := range nodes {
+		numClients += n
+	}
+
+	if cmd.RemoveHistory {
+		chOpts, found, err := h.ruleContainer.ChannelOptions(ch)
+		if err != nil {
+			resp.Error = ErrorInternal
+			return resp
+		}
+		if found && chOpts.HistorySize > 0 && chOpts.HistoryTTL > 0 {
+			err = h.node.RemoveHistory(ch)
+			if err != nil {
+				h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error calling history remove", map[string]interface{}{"channel": ch, "error": err.Error()}))
+				resp.Error = toAPIErr(err)
+				return resp
+			}
+		}
+	}
+
+	resp.Result = &UnsubscribeChannelResult{NumClients: numClients}
+	return resp
+}
+
+// ReloadConfig applies new configuration on all running nodes.
+func (h *Executor) ReloadConfig(ctx context.Context, cmd *ReloadConfigRequest) *ReloadConfigResponse {
+	defer observe(time.Now(), h.protocol, "reload_config")
+
+	resp := &ReloadConfigResponse{}
+
+	if err := h.checkTenant(ctx); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if len(cmd.Config) == 0 {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "config required for reload", nil))
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	err := h.surveyCaller.ReloadConfig(ctx, cmd)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error reloading config", map[string]interface{}{"error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	resp.Result = &ReloadConfigResult{}
+	return resp
+}
+
+// SetChannelOptions overrides options of a single channel on all running nodes.
+// If channel options store set override is also saved there, so nodes started
+// later load it. Request without override removes channel override so namespace
+// options will be used for channel again.
+func (h *Executor) SetChannelOptions(ctx context.Context, cmd *SetChannelOptionsRequest) *SetChannelOptionsResponse {
+	defer observe(time.Now(), h.protocol, "set_channel_options")
+
+	resp := &SetChannelOptionsResponse{}
+
+	if err := h.checkTenant(ctx); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	ch := cmd.Channel
+	if ch == "" {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "channel required for set channel options", nil))
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	override := survey.ChannelOptionsOverrideFromProto(cmd.Override)
+	if override != nil {
+		err := h.ruleContainer.ValidateChannelOptionsOverride(ch, *override)
+		if err == rule.ErrUnknownNamespace {
+			resp.Error = ErrorUnknownChannel
+			return resp
+		} else if err != nil {
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "invalid channel options override", map[string]interface{}{"channel": ch, "error": err.Error()}))
+			resp.Error = ErrorBadRequest
+			return resp
+		}
+	}
+
+	if h.overrides != nil {
+		// Save override before notifying running nodes, so nodes started
+		// meanwhile load it from store.
+		if err := rule.SaveChannelOptionsOverride(h.overrides, ch, override); err != nil {
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error saving channel options", map[string]interface{}{"channel": ch, "error": err.Error()}))
+			resp.Error = ErrorInternal
+			return resp
+		}
+	}
+
+	err := h.surveyCaller.SetChannelOptions(ctx, cmd)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error setting channel options", map[string]interface{}{"channel": ch, "error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	resp.Result = &SetChannelOptionsResult{}
+	return resp
+}
+
+// ConnectionEvents returns recent connection events from all running nodes
+// matching request filters.
+func (h *Executor) ConnectionEvents(ctx context.Context, cmd *ConnectionEventsRequest) *ConnectionEventsResponse {
+	defer observe(time.Now(), h.protocol, "connection_events")
+
+	resp := &ConnectionEventsResponse{}
+
+	if err := h.checkTenant(ctx); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if cmd.Limit < 0 {
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	events, err := h.surveyCaller.ConnectionEvents(ctx, cmd)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error calling connection events", map[string]interface{}{"error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	resp.Result = &ConnectionEventsResult{Events: events}
+	return resp
+}
+
+// AcquireLock acquires a distributed lock. Lock is automatically released after
+// TTL, owner can extend lock by acquiring it again before TTL passed.
+func (h *Executor) AcquireLock(ctx context.Context, cmd *AcquireLockRequest) *AcquireLockResponse {
+	defer observe(time.Now(), h.protocol, "acquire_lock")
+
+	resp := &AcquireLockResponse{}
+
+	if err := h.checkTenant(ctx); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if h.locker == nil {
+		resp.Error = ErrorNotAvailable
+		return resp
+	}
+
+	if cmd.Name == "" || cmd.Owner == "" || cmd.TtlMs <= 0 {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "name, owner and positive ttl_ms required for acquire lock", nil))
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	acquired, err := h.locker.AcquireLock(cmd.Name, cmd.Owner, time.Duration(cmd.TtlMs)*time.Millisecond)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error acquiring lock", map[string]interface{}{"name": cmd.Name, "error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	resp.Result = &AcquireLockResult{Acquired: acquired}
+	return resp
+}
+
+// ReleaseLock releases a distributed lock held by owner.
+func (h *Executor) ReleaseLock(ctx context.Context, cmd *ReleaseLockRequest) *ReleaseLockResponse {
+	defer observe(time.Now(), h.protocol, "release_lock")
+
+	resp := &ReleaseLockResponse{}
+
+	if err := h.checkTenant(ctx); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if h.locker == nil {
+		resp.Error = ErrorNotAvailable
+		return resp
+	}
+
+	if cmd.Name == "" || cmd.Owner == "" {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "name and owner required for release lock", nil))
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	released, err := h.locker.ReleaseLock(cmd.Name, cmd.Owner)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error releasing lock", map[string]interface{}{"name": cmd.Name, "error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	resp.Result = &ReleaseLockResult{Released: released}
+	return resp
+}
+
+// checkChannelMeta validates channel of channel meta request, returns API
+// error to respond with.
+func (h *Executor) checkChannelMeta(ch string) *Error {
+	if h.metaStore == nil {
+		return ErrorNotAvailable
+	}
+	if ch == "" {
+		return ErrorBadRequest
+	}
+	_, found, err := h.ruleContainer.ChannelOptions(ch)
+	if err != nil {
+		return ErrorInternal
+	}
+	if !found {
+		return ErrorUnknownChannel
+	}
+	return nil
+}
+
+// SetChannelMeta sets channel meta entry. Value must be a valid JSON since
+// entries can be sent to clients on subscribe.
+func (h *Executor) SetChannelMeta(ctx context.Context, cmd *SetChannelMetaRequest) *SetChannelMetaResponse {
+	defer observe(time.Now(), h.protocol, "set_channel_meta")
+
+	resp := &SetChannelMetaResponse{}
+
+	if err := h.checkTenant(ctx, cmd.Channel); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if apiErr := h.checkChannelMeta(cmd.Channel); apiErr != nil {
+		resp.Error = apiErr
+		return resp
+	}
+
+	if cmd.Key == "" || cmd.TtlMs < 0 || !json.Valid(cmd.Value) {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "key, JSON value and non-negative ttl_ms required for set channel meta", nil))
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	err := h.metaStore.SetChannelMeta(cmd.Channel, cmd.Key, cmd.Value, time.Duration(cmd.TtlMs)*time.Millisecond)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error setting channel meta", map[string]interface{}{"channel": cmd.Channel, "error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	resp.Result = &SetChannelMetaResult{}
+	return resp
+}
+
+// GetChannelMeta returns channel meta entries sorted by key.
+func (h *Executor) GetChannelMeta(ctx context.Context, cmd *GetChannelMetaRequest) *GetChannelMetaResponse {
+	defer observe(time.Now(), h.protocol, "get_channel_meta")
+
+	resp := &GetChannelMetaResponse{}
+
+	if err := h.checkTenant(ctx, cmd.Channel); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if apiErr := h.checkChannelMeta(cmd.Channel); apiErr != nil {
+		resp.Error = apiErr
+		return resp
+	}
+
+	meta, err := h.metaStore.ChannelMeta(cmd.Channel)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error getting channel meta", map[string]interface{}{"channel": cmd.Channel, "error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	entries := make([]*ChannelMetaEntry, 0, len(meta))
+	for key, value := range meta {
+		entries = append(entries, &ChannelMetaEntry{Key: key, Value: value})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+
+	resp.Result = &GetChannelMetaResult{Entries: entries}
+	return resp
+}
+
+// DeleteChannelMeta deletes channel meta entry.
+func (h *Executor) DeleteChannelMeta(ctx context.Context, cmd *DeleteChannelMetaRequest) *DeleteChannelMetaResponse {
+	defer observe(time.Now(), h.protocol, "delete_channel_meta")
+
+	resp := &DeleteChannelMetaResponse{}
+
+	if err := h.checkTenant(ctx, cmd.Channel); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if apiErr := h.checkChannelMeta(cmd.Channel); apiErr != nil {
+		resp.Error = apiErr
+		return resp
+	}
+
+	if cmd.Key == "" {
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	err := h.metaStore.DeleteChannelMeta(cmd.Channel, cmd.Key)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error deleting channel meta", map[string]interface{}{"channel": cmd.Channel, "error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	resp.Result = &DeleteChannelMetaResult{}
+	return resp
+}
+
+// AddRedisShard adds Redis shard on all running nodes. Channels which belong
+// to new shard after adding are migrated to it in background.
+func (h *Executor) AddRedisShard(ctx context.Context, cmd *AddRedisShardRequest) *AddRedisShardResponse {
+	defer observe(time.Now(), h.protocol, "add_redis_shard")
+
+	resp := &AddRedisShardResponse{}
+
+	if err := h.checkTenant(ctx); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if cmd.Address == "" {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "address required for adding Redis shard", nil))
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	numMigrated, err := h.surveyCaller.AddRedisShard(ctx, cmd)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error adding Redis shard", map[string]interface{}{"address": cmd.Address, "error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	resp.Result = &AddRedisShardResult{NumMigratedChannels: numMigrated}
+	return resp
+}
+
+// MigrateConnections asks connections of node to reconnect to other nodes
+// before node maintenance. Every connection gets migration notice and is
+// disconnected with reconnect advice after random delay in configured range,
+// so clients do not reconnect all at once.
+func (h *Executor) MigrateConnections(ctx context.Context, cmd *MigrateConnectionsRequest) *MigrateConnectionsResponse {
+	defer observe(time.Now(), h.protocol, "migrate_connections")
+
+	resp := &MigrateConnectionsResponse{}
+
+	if err := h.checkTenant(ctx); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if cmd.Node == "" || cmd.MaxDelayMs < cmd.MinDelayMs {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "node and valid delay range required for migrating connections", nil))
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	numClients, err := h.surveyCaller.MigrateConnections(ctx, cmd)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error migrating connections", map[string]interface{}{"node": cmd.Node, "error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	resp.Result = &MigrateConnectionsResult{NumClients: numClients}
+	return resp
+}
+
+// SetReadPosition sets last read position of user in channel, so number of
+// unread publications can be calculated later. Position is only moved
+// forward within the same epoch.
+func (h *Executor) SetReadPosition(ctx context.Context, cmd *SetReadPositionRequest) *SetReadPositionResponse {
+	defer observe(time.Now(), h.protocol, "set_read_position")
+
+	resp := &SetReadPositionResponse{}
+
+	if err := h.checkTenant(ctx, cmd.Channel); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if h.readPositions == nil {
+		resp.Error = ErrorNotAvailable
+		return resp
+	}
+
+	if cmd.User == "" || cmd.Channel == "" {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "user and channel required for set read position", nil))
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	_, found, err := h.ruleContainer.ChannelOptions(cmd.Channel)
+	if err != nil {
+		resp.Error = ErrorInternal
+		return resp
+	}
+	if !found {
+		resp.Error = ErrorUnknownChannel
+		return resp
+	}
+
+	err = h.readPositions.SetReadPosition(cmd.User, cmd.Channel, centrifuge.StreamPosition{Offset: cmd.Offset, Epoch: cmd.Epoch})
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error setting read position", map[string]interface{}{"channel": cmd.Channel, "user": cmd.User, "error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	resp.Result = &SetReadPositionResult{}
+	return resp
+}
+
+// ReadPositions returns last read positions of user in channels. With unread
+// flag positions returned for all channels together with number of
+// publications in channel stream after read position, all publications of
+// stream counted as unread if read position has another epoch.
+func (h *Executor) ReadPositions(ctx context.Context, cmd *ReadPositionsRequest) *ReadPositionsResponse {
+	defer observe(time.Now(), h.protocol, "read_positions")
+
+	resp := &ReadPositionsResponse{}
+
+	if err := h.checkTenant(ctx, cmd.Channels...); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if h.readPositions == nil {
+		resp.Error = ErrorNotAvailable
+		return resp
+	}
+
+	if cmd.User == "" {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "user required for read positions", nil))
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	positions, err := h.readPositions.ReadPositions(cmd.User, cmd.Channels)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error getting read positions", map[string]interface{}{"user": cmd.User, "error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	result := make([]*ReadPosition, 0, len(positions))
+	for _, ch := range cmd.Channels {
+		sp, ok := positions[ch]
+		if !ok && !cmd.Unread {
+			continue
+		}
+		position := &ReadPosition{Channel: ch, Offset: sp.Offset, Epoch: sp.Epoch}
+		if cmd.Unread {
+			history, err := h.node.History(ch)
+			if err != nil {
+				h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error getting stream position for unread count", map[string]interface{}{"channel": ch, "error": err.Error()}))
+				resp.Error = toAPIErr(err)
+				return resp
+			}
+			top := history.StreamPosition
+			if !ok || top.Epoch != sp.Epoch {
+				position.Unread = top.Offset
+			} else if top.Offset > sp.Offset {
+				position.Unread = top.Offset - sp.Offset
+			}
+		}
+		result = append(result, position)
+	}
+
+	resp.Result = &ReadPositionsResult{Positions: result}
+	return resp
+}
+
+// HistoryMeta returns state of channel history stream: number of kept
+// publications, oldest and top offsets, epoch and TTL. Publications are not
+// returned.
+func (h *Executor) HistoryMeta(ctx context.Context, cmd *HistoryMetaRequest) *HistoryMetaResponse {
+	defer observe(time.Now(), h.protocol, "history_meta")
+
+	resp := &HistoryMetaResponse{}
+
+	if err := h.checkTenant(ctx, cmd.Channel); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	ch := cmd.Channel
+
+	if ch == "" {
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	chOpts, found, err := h.ruleContainer.ChannelOptions(ch)
+	if err != nil {
+		resp.Error = ErrorInternal
+		return resp
+	}
+	if !found {
+		resp.Error = ErrorUnknownChannel
+		return resp
+	}
+
+	if chOpts.HistorySize <= 0 || chOpts.HistoryTTL <= 0 {
+		resp.Error = ErrorNotAvailable
+		return resp
+	}
+
+	var meta HistoryMetaInfo
+	if h.historyMeta != nil {
+		meta, err = h.historyMeta.HistoryMeta(ch)
+	} else {
+		meta, err = h.historyMetaFromHistory(ch)
+	}
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error calling history meta", map[string]interface{}{"channel": ch, "error": err.Error()}))
+		resp.Error = toAPIErr(err)
+		return resp
+	}
+
+	resp.Result = &HistoryMetaResult{
+		NumPublications: uint32(meta.NumPublications),
+		OldestOffset:    meta.OldestOffset,
+		Offset:          meta.Offset,
+		Epoch:           meta.Epoch,
+		TtlMs:           meta.TTL.Milliseconds(),
+	}
+	return resp
+}
+
+func (h *Executor) historyMetaFromHistory(ch string) (HistoryMetaInfo, error) {
+	history, err := h.node.History(ch, centrifuge.WithLimit(centrifuge.NoLimit))
+	if err != nil {
+		return HistoryMetaInfo{}, err
+	}
+	meta := HistoryMetaInfo{
+		NumPublications: len(history.Publications),
+		Offset:          history.Offset,
+		Epoch:           history.Epoch,
+	}
+	if len(history.Publications) > 0 {
+		meta.OldestOffset = history.Publications[0].Offset
+	}
+	return meta, nil
+}
+
+// SetChannelGroup sets source channels of channel group. Subscribers of
+// channel group start receiving publications of new sources immediately.
+func (h *Executor) SetChannelGroup(ctx context.Context, cmd *SetChannelGroupRequest) *SetChannelGroupResponse {
+	defer observe(time.Now(), h.protocol, "set_channel_group")
+
+	resp := &SetChannelGroupResponse{}
+
+	if err := h.checkTenant(ctx, append([]string{cmd.Channel}, cmd.Sources...)...); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if cmd.Channel == "" || len(cmd.Sources) == 0 {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "channel and sources required for set channel group", nil))
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	if apiErr := h.checkChannelGroup(cmd.Channel); apiErr != nil {
+		resp.Error = apiErr
+		return resp
+	}
+
+	if err := h.channelGroups.SetChannelGroup(cmd.Channel, cmd.Sources); err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error setting channel group", map[string]interface{}{"channel": cmd.Channel, "error": err.Error()}))
+		resp.Error = channelGroupAPIErr(err)
+		return resp
+	}
+
+	resp.Result = &SetChannelGroupResult{}
+	return resp
+}
+
+// RemoveChannelGroup removes channel group. Subscribers of channel group stop
+// receiving publications of its sources.
+func (h *Executor) RemoveChannelGroup(ctx context.Context, cmd *RemoveChannelGroupRequest) *RemoveChannelGroupResponse {
+	defer observe(time.Now(), h.protocol, "remove_channel_group")
+
+	resp := &RemoveChannelGroupResponse{}
+
+	if err := h.checkTenant(ctx, cmd.Channel); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if cmd.Channel == "" {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "channel required for remove channel group", nil))
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	if apiErr := h.checkChannelGroup(cmd.Channel); apiErr != nil {
+		resp.Error = apiErr
+		return resp
+	}
+
+	if err := h.channelGroups.RemoveChannelGroup(cmd.Channel); err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error removing channel group", map[string]interface{}{"channel": cmd.Channel, "error": err.Error()}))
+		resp.Error = channelGroupAPIErr(err)
+		return resp
+	}
+
+	resp.Result = &RemoveChannelGroupResult{}
+	return resp
+}
+
+// ChannelGroup returns source channels of channel group.
+func (h *Executor) ChannelGroup(ctx context.Context, cmd *ChannelGroupRequest) *ChannelGroupResponse {
+	defer observe(time.Now(), h.protocol, "channel_group")
+
+	resp := &ChannelGroupResponse{}
+
+	if err := h.checkTenant(ctx, cmd.Channel); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if cmd.Channel == "" {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "channel required for channel group", nil))
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	if apiErr := h.checkChannelGroup(cmd.Channel); apiErr != nil {
+		resp.Error = apiErr
+		return resp
+	}
+
+	sources, err := h.channelGroups.ChannelGroup(cmd.Channel)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error getting channel group", map[string]interface{}{"channel": cmd.Channel, "error": err.Error()}))
+		resp.Error = channelGroupAPIErr(err)
+		return resp
+	}
+
+	resp.Result = &ChannelGroupResult{Sources: sources}
+	return resp
+}
+
+func (h *Executor) checkChannelGroup(ch string) *Error {
+	if h.channelGroups == nil {
+		return ErrorNotAvailable
+	}
+	chOpts, found, err := h.ruleContainer.ChannelOptions(ch)
+	if err != nil {
+		return ErrorInternal
+	}
+	if !found {
+		return ErrorUnknownChannel
+	}
+	if !chOpts.ChannelGroup {
+		return ErrorNotAvailable
+	}
+	return nil
+}
+
+func channelGroupAPIErr(err error) *Error {
+	switch err {
+	case fanin.ErrNotGroup, fanin.ErrStaticGroup, fanin.ErrNoStore:
+		return ErrorNotAvailable
+	case fanin.ErrInvalidSources:
+		return ErrorBadRequest
+	}
+	return toAPIErr(err)
+}
+
+// toAPIErr converts error to API error. Errors of engines and Centrifuge
+// library mapped to public codes, unknown errors become ErrorInternal.
+func toAPIErr(err error) *Error {
+	if apiErr, ok := err.(*Error); ok {
+		return apiErr
+	}
+	e := errorcode.FromError(err)
+	if e == centrifuge.ErrorInternal {
+		return ErrorInternal
+	}
+	return &Error{
+		Code:      e.Code,
+		Message:   e.Message,
+		Temporary: errorcode.IsTemporary(e.Code),
+	}
+}
+
+// RegisterPushDevice registers device of user to send publications to
+// personal channel as push notifications while user is offline.
+func (h *Executor) RegisterPushDevice(ctx context.Context, cmd *RegisterPushDeviceRequest) *RegisterPushDeviceResponse {
+	defer observe(time.Now(), h.protocol, "register_push_device")
+
+	resp := &RegisterPushDeviceResponse{}
+
+	if err := h.checkTenant(ctx); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if h.pushNotifier == nil {
+		resp.Error = ErrorNotAvailable
+		return resp
+	}
+
+	device := pushnotify.Device{Platform: cmd.Platform, Token: cmd.Token}
+	if cmd.User == "" || device.Validate() != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "user, valid platform and token required for register push device", nil))
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	if err := h.pushNotifier.Store().AddDevice(cmd.User, device); err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error registering push device", map[string]interface{}{"user": cmd.User, "platform": cmd.Platform, "error": err.Error()}))
+		resp.Error = ErrorInternal
+		return resp
+	}
+
+	resp.Result = &RegisterPushDeviceResult{}
+	return resp
+}
+
+// UnregisterPushDevice unregisters device of user.
+func (h *Executor) UnregisterPushDevice(ctx context.Context, cmd *UnregisterPushDeviceRequest) *UnregisterPushDeviceResponse {
+	defer observe(time.Now(), h.protocol, "unregister_push_device")
+
+	resp := &UnregisterPushDeviceResponse{}
+
+	if err := h.checkTenant(ctx); err != nil {
+		resp.Error = err
+		return resp
+	}
+
+	if h.pushNotifier == nil {
+		resp.Error = ErrorNotAvailable
+		return resp
+	}
+
+	device := pushnotify.Device{Platform: cmd.Platform, Token: cmd.Token}
+	if cmd.User == "" || device.Validate() != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "user, valid platform and token required for unregister push device", nil))
+		resp.Error = ErrorBadRequest
+		return resp
+	}
+
+	if err := h.pushNotifier.Store().RemoveDevice(cmd.User, device); err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error unregistering push device", map[string]interface{}{"user": cmd.User, "platform": cmd.Platform, "error": err.Error()}))
+		resp.Error = ErrorInternal
+		return resp
+	}
+
+	resp.Result = &UnregisterPushDeviceResult{}
+	return resp
 }
@@ -2,11 +2,25 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	. "github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/fanin"
+	"github.com/centrifugal/centrifugo/v3/internal/inbox"
+	"github.com/centrifugal/centrifugo/v3/internal/interceptor"
+	"github.com/centrifugal/centrifugo/v3/internal/limits"
+	"github.com/centrifugal/centrifugo/v3/internal/memengine"
+	"github.com/centrifugal/centrifugo/v3/internal/pubid"
+	"github.com/centrifugal/centrifugo/v3/internal/publisher"
+	"github.com/centrifugal/centrifugo/v3/internal/pushnotify"
 	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/tenant"
 	"github.com/centrifugal/centrifugo/v3/internal/tools"
 
 	"github.com/centrifugal/centrifuge"
@@ -32,6 +46,38 @@ func (t testSurveyCaller) Channels(_ context.Context, _ *ChannelsRequest) (map[s
 	return nil, nil
 }
 
+func (t testSurveyCaller) ChannelsCount(_ context.Context, _ *ChannelsCountRequest) (uint64, error) {
+	return 0, nil
+}
+
+func (t testSurveyCaller) ReloadConfig(_ context.Context, _ *ReloadConfigRequest) error {
+	return nil
+}
+
+func (t testSurveyCaller) SetChannelOptions(_ context.Context, _ *SetChannelOptionsRequest) error {
+	return nil
+}
+
+func (t testSurveyCaller) ChannelSubscribers(_ context.Context, _ *ChannelSubscribersRequest) (map[string]uint32, error) {
+	return nil, nil
+}
+
+func (t testSurveyCaller) ConnectionEvents(_ context.Context, _ *ConnectionEventsRequest) ([]*ConnectionEvent, error) {
+	return nil, nil
+}
+
+func (t testSurveyCaller) AddRedisShard(_ context.Context, _ *AddRedisShardRequest) (uint32, error) {
+	return 0, nil
+}
+
+func (t testSurveyCaller) UnsubscribeChannel(_ context.Context, _ *UnsubscribeChannelRequest) (map[string]uint32, error) {
+	return map[string]uint32{"node1": 1, "node2": 2}, nil
+}
+
+func (t testSurveyCaller) MigrateConnections(_ context.Context, _ *MigrateConnectionsRequest) (uint32, error) {
+	return 0, nil
+}
+
 func (t testSurveyCaller) UserConnections(_ context.Context, _ *UserConnectionsRequest) (map[string]*UserConnectionInfo, error) {
 	return nil, nil
 }
@@ -56,6 +102,50 @@ func TestPublishAPI(t *testing.T) {
 	require.Equal(t, ErrorUnknownChannel, resp.Error)
 }
 
+func TestPublishAPIPublicationID(t *testing.T) {
+	node := nodeWithMemoryEngine()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.HistorySize = 10
+	ruleConfig.HistoryTTL = tools.Duration(time.Minute)
+	ruleContainer := rule.NewContainer(ruleConfig)
+
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+	resp := api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte("test")})
+	require.Nil(t, resp.Error)
+	require.Equal(t, "", resp.Result.Id)
+
+	generator, err := pubid.NewSnowflakeGenerator(1)
+	require.NoError(t, err)
+	pub := publisher.New(node, ruleContainer)
+	pub.SetIDGenerator(generator)
+	api.SetPublisher(pub)
+
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte("test")})
+	require.Equal(t, ErrorBadRequest, resp.Error)
+
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte(`{"a":1}`)})
+	require.Nil(t, resp.Error)
+	require.NotEqual(t, "", resp.Result.Id)
+	history, err := node.History("test", centrifuge.WithLimit(1), centrifuge.WithReverse(true))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id":"`+resp.Result.Id+`","data":{"a":1}}`, string(history.Publications[0].Data))
+
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte(`{"a":2}`), NoWait: true})
+	require.Nil(t, resp.Error)
+	noWaitID := resp.Result.Id
+	require.NotEqual(t, "", noWaitID)
+	require.Eventually(t, func() bool {
+		history, err := node.History("test", centrifuge.WithLimit(1), centrifuge.WithReverse(true))
+		return err == nil && len(history.Publications) == 1 && string(history.Publications[0].Data) == `{"id":"`+noWaitID+`","data":{"a":2}}`
+	}, time.Second, 10*time.Millisecond)
+
+	broadcastResp := api.Broadcast(context.Background(), &BroadcastRequest{Channels: []string{"test1", "test2"}, Data: []byte(`{}`)})
+	require.Nil(t, broadcastResp.Error)
+	require.NotEqual(t, "", broadcastResp.Result.Responses[0].Result.Id)
+	require.NotEqual(t, broadcastResp.Result.Responses[0].Result.Id, broadcastResp.Result.Responses[1].Result.Id)
+}
+
 func TestBroadcastAPI(t *testing.T) {
 	node := nodeWithMemoryEngine()
 	ruleConfig := rule.DefaultConfig
@@ -80,6 +170,73 @@ func TestBroadcastAPI(t *testing.T) {
 	require.Equal(t, ErrorUnknownChannel, resp.Result.Responses[1].Error)
 }
 
+func TestPublishNoWaitAPI(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.HistorySize = 10
+	ruleConfig.HistoryTTL = tools.Duration(time.Minute)
+	ruleContainer := rule.NewContainer(ruleConfig)
+
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+	resp := api.Publish(context.Background(), &PublishRequest{Channel: "test:test", Data: []byte("test"), NoWait: true})
+	require.Equal(t, ErrorUnknownChannel, resp.Error)
+
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte("test"), NoWait: true})
+	require.Nil(t, resp.Error)
+	require.Zero(t, resp.Result.Offset)
+
+	broadcastResp := api.Broadcast(context.Background(), &BroadcastRequest{Channels: []string{"test", "test:test"}, Data: []byte("test"), NoWait: true})
+	require.Nil(t, broadcastResp.Error)
+	require.Nil(t, broadcastResp.Result.Responses[0].Error)
+	require.Equal(t, ErrorUnknownChannel, broadcastResp.Result.Responses[1].Error)
+
+	require.Eventually(t, func() bool {
+		historyResp := api.History(context.Background(), &HistoryRequest{Channel: "test", Limit: -1})
+		return historyResp.Error == nil && len(historyResp.Result.Publications) == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPublishNoWaitOrder(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.HistorySize = 100
+	ruleConfig.HistoryTTL = tools.Duration(time.Minute)
+	ruleContainer := rule.NewContainer(ruleConfig)
+
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+	api.SetNoWaitQueue(4, 100)
+	for i := 0; i < 50; i++ {
+		resp := api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte(strconv.Itoa(i)), NoWait: true})
+		require.Nil(t, resp.Error)
+	}
+	var publications []*Publication
+	require.Eventually(t, func() bool {
+		historyResp := api.History(context.Background(), &HistoryRequest{Channel: "test", Limit: -1})
+		if historyResp.Error != nil {
+			return false
+		}
+		publications = historyResp.Result.Publications
+		return len(publications) == 50
+	}, time.Second, 10*time.Millisecond)
+	for i, pub := range publications {
+		require.Equal(t, strconv.Itoa(i), string(pub.Data))
+	}
+}
+
+func TestNoWaitQueueFull(t *testing.T) {
+	q := newNoWaitQueue(1, 1)
+	block := make(chan struct{})
+	started := make(chan struct{})
+	require.True(t, q.push("test", func() {
+		close(started)
+		<-block
+	}))
+	<-started
+	require.True(t, q.push("test", func() {}))
+	require.False(t, q.push("other", func() {}))
+	close(block)
+}
+
 func TestHistoryAPI(t *testing.T) {
 	node := nodeWithMemoryEngine()
 	ruleConfig := rule.DefaultConfig
@@ -100,6 +257,144 @@ func TestHistoryAPI(t *testing.T) {
 	require.Nil(t, resp.Error)
 }
 
+func TestHistoryMetaAPI(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.HistorySize = 2
+	ruleConfig.HistoryTTL = tools.Duration(time.Minute)
+	ruleContainer := rule.NewContainer(ruleConfig)
+
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+	resp := api.HistoryMeta(context.Background(), &HistoryMetaRequest{})
+	require.Equal(t, ErrorBadRequest, resp.Error)
+
+	for i := 0; i < 3; i++ {
+		publishResp := api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte("{}")})
+		require.Nil(t, publishResp.Error)
+	}
+
+	resp = api.HistoryMeta(context.Background(), &HistoryMetaRequest{Channel: "test"})
+	require.Nil(t, resp.Error)
+	require.Equal(t, uint32(2), resp.Result.NumPublications)
+	require.Equal(t, uint64(2), resp.Result.OldestOffset)
+	require.Equal(t, uint64(3), resp.Result.Offset)
+	require.NotEmpty(t, resp.Result.Epoch)
+}
+
+func TestPublishCompactionKeyAPI(t *testing.T) {
+	node, err := centrifuge.New(centrifuge.DefaultConfig)
+	require.NoError(t, err)
+	broker, err := memengine.NewBroker(node, memengine.BrokerConfig{})
+	require.NoError(t, err)
+	node.SetBroker(broker)
+	require.NoError(t, node.Run())
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.HistorySize = 10
+	ruleConfig.HistoryTTL = tools.Duration(time.Minute)
+	ruleConfig.HistoryCompaction = true
+	ruleContainer := rule.NewContainer(ruleConfig)
+
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+	resp := api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte("{}"), CompactionKey: "a"})
+	require.Equal(t, ErrorNotAvailable, resp.Error)
+
+	api.SetHistoryCompactor(broker)
+	for _, key := range []string{"a", "b", "a"} {
+		resp = api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte("{}"), CompactionKey: key})
+		require.Nil(t, resp.Error)
+	}
+	broadcastResp := api.Broadcast(context.Background(), &BroadcastRequest{Channels: []string{"test"}, Data: []byte("{}"), CompactionKey: "b"})
+	require.Nil(t, broadcastResp.Error)
+	require.Nil(t, broadcastResp.Result.Responses[0].Error)
+
+	historyResp := api.History(context.Background(), &HistoryRequest{Channel: "test", Limit: -1})
+	require.Nil(t, historyResp.Error)
+	require.Len(t, historyResp.Result.Publications, 2)
+	require.Equal(t, uint64(3), historyResp.Result.Publications[0].Offset)
+	require.Equal(t, uint64(4), historyResp.Result.Publications[1].Offset)
+}
+
+type testPriorityPublisher struct {
+	mu       sync.Mutex
+	channels []string
+}
+
+func (p *testPriorityPublisher) PublishPriority(ch string, _ []byte, opts centrifuge.PublishOptions) (centrifuge.StreamPosition, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.channels = append(p.channels, ch)
+	return centrifuge.StreamPosition{Offset: uint64(opts.HistorySize), Epoch: "priority"}, nil
+}
+
+func TestPublishPriorityAPI(t *testing.T) {
+	node := nodeWithMemoryEngine()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.HistorySize = 10
+	ruleConfig.HistoryTTL = tools.Duration(time.Minute)
+	ruleContainer := rule.NewContainer(ruleConfig)
+
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+	// Published as usual without PriorityPublisher.
+	resp := api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte("{}"), Priority: true})
+	require.Nil(t, resp.Error)
+	require.Equal(t, uint64(1), resp.Result.Offset)
+
+	publisher := &testPriorityPublisher{}
+	api.SetPriorityPublisher(publisher)
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte("{}")})
+	require.Nil(t, resp.Error)
+	require.Equal(t, uint64(2), resp.Result.Offset)
+	require.Empty(t, publisher.channels)
+
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte("{}"), Priority: true})
+	require.Nil(t, resp.Error)
+	require.Equal(t, uint64(10), resp.Result.Offset)
+	require.Equal(t, "priority", resp.Result.Epoch)
+
+	broadcastResp := api.Broadcast(context.Background(), &BroadcastRequest{Channels: []string{"test1", "test2"}, Data: []byte("{}"), Priority: true})
+	require.Nil(t, broadcastResp.Error)
+	require.Equal(t, "priority", broadcastResp.Result.Responses[1].Result.Epoch)
+	require.ElementsMatch(t, []string{"test", "test1", "test2"}, publisher.channels)
+}
+
+func TestHistoryFromTimeAPI(t *testing.T) {
+	node, err := centrifuge.New(centrifuge.DefaultConfig)
+	require.NoError(t, err)
+	broker, err := memengine.NewBroker(node, memengine.BrokerConfig{})
+	require.NoError(t, err)
+	node.SetBroker(broker)
+	require.NoError(t, node.Run())
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.HistorySize = 10
+	ruleConfig.HistoryTTL = tools.Duration(time.Minute)
+	ruleContainer := rule.NewContainer(ruleConfig)
+
+	start := time.Now()
+	_, err = node.Publish("test", []byte(`{}`), centrifuge.WithHistory(10, time.Minute))
+	require.NoError(t, err)
+
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+	sinceMs := start.UnixNano() / int64(time.Millisecond)
+	resp := api.History(context.Background(), &HistoryRequest{Channel: "test", SinceMs: sinceMs, Limit: -1})
+	require.Equal(t, ErrorNotAvailable, resp.Error)
+
+	api.SetTimeHistory(broker)
+	resp = api.History(context.Background(), &HistoryRequest{Channel: "test", SinceMs: sinceMs, Limit: -1, Reverse: true})
+	require.Equal(t, ErrorBadRequest, resp.Error)
+
+	resp = api.History(context.Background(), &HistoryRequest{Channel: "test", SinceMs: sinceMs, Limit: -1})
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.Result.Publications, 1)
+	require.Equal(t, uint64(1), resp.Result.Offset)
+
+	resp = api.History(context.Background(), &HistoryRequest{Channel: "test", SinceMs: sinceMs + 60000, Limit: -1})
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.Result.Publications, 0)
+}
+
 func TestHistoryRemoveAPI(t *testing.T) {
 	node := nodeWithMemoryEngine()
 	ruleConfig := rule.DefaultConfig
@@ -120,6 +415,34 @@ func TestHistoryRemoveAPI(t *testing.T) {
 	require.Nil(t, resp.Error)
 }
 
+func TestUnsubscribeChannelAPI(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.HistorySize = 1
+	ruleConfig.HistoryTTL = tools.Duration(time.Minute)
+	ruleContainer := rule.NewContainer(ruleConfig)
+
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+	resp := api.UnsubscribeChannel(context.Background(), &UnsubscribeChannelRequest{})
+	require.Equal(t, ErrorBadRequest, resp.Error)
+
+	_, err := node.Publish("test", []byte(`{}`), centrifuge.WithHistory(1, time.Minute))
+	require.NoError(t, err)
+
+	resp = api.UnsubscribeChannel(context.Background(), &UnsubscribeChannelRequest{Channel: "test"})
+	require.Nil(t, resp.Error)
+	require.Equal(t, uint32(3), resp.Result.NumClients)
+	historyResp := api.History(context.Background(), &HistoryRequest{Channel: "test", Limit: -1})
+	require.Nil(t, historyResp.Error)
+	require.Len(t, historyResp.Result.Publications, 1)
+
+	resp = api.UnsubscribeChannel(context.Background(), &UnsubscribeChannelRequest{Channel: "test", RemoveHistory: true})
+	require.Nil(t, resp.Error)
+	historyResp = api.History(context.Background(), &HistoryRequest{Channel: "test", Limit: -1})
+	require.Nil(t, historyResp.Error)
+	require.Len(t, historyResp.Result.Publications, 0)
+}
+
 func TestPresenceAPI(t *testing.T) {
 	node := nodeWithMemoryEngine()
 	ruleConfig := rule.DefaultConfig
@@ -140,6 +463,72 @@ func TestPresenceAPI(t *testing.T) {
 	require.Nil(t, resp.Error)
 }
 
+type testPresenceLimiter struct {
+	ctxDone bool
+}
+
+func (l *testPresenceLimiter) PresenceLimited(ctx context.Context, _ string, _ int, partial bool) (PresenceLimitedResult, error) {
+	<-ctx.Done()
+	l.ctxDone = true
+	if !partial {
+		return PresenceLimitedResult{}, ctx.Err()
+	}
+	return PresenceLimitedResult{
+		Presence: map[string]*centrifuge.ClientInfo{"c1": {ClientID: "c1", UserID: "1"}},
+		Partial:  true,
+	}, nil
+}
+
+func TestPresenceLimitAPI(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	presenceManager, err := centrifuge.NewMemoryPresenceManager(node, centrifuge.MemoryPresenceManagerConfig{})
+	require.NoError(t, err)
+	node.SetPresenceManager(presenceManager)
+	for _, clientID := range []string{"c3", "c1", "c2"} {
+		require.NoError(t, presenceManager.AddPresence("test", clientID, &centrifuge.ClientInfo{ClientID: clientID, UserID: "1"}))
+	}
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Presence = true
+	ruleContainer := rule.NewContainer(ruleConfig)
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+
+	resp := api.Presence(context.Background(), &PresenceRequest{Channel: "test", Limit: -1})
+	require.Equal(t, ErrorBadRequest, resp.Error)
+
+	resp = api.Presence(context.Background(), &PresenceRequest{Channel: "test"})
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.Result.Presence, 3)
+	require.False(t, resp.Result.Truncated)
+
+	resp = api.Presence(context.Background(), &PresenceRequest{Channel: "test", Limit: 2})
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.Result.Presence, 2)
+	require.Contains(t, resp.Result.Presence, "c1")
+	require.Contains(t, resp.Result.Presence, "c2")
+	require.True(t, resp.Result.Truncated)
+
+	// Hard cap applied to requests without limit and with greater limit.
+	api.SetLimits(limits.NewContainer(limits.Config{APIPresenceLimit: 1}))
+	resp = api.Presence(context.Background(), &PresenceRequest{Channel: "test"})
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.Result.Presence, 1)
+	require.True(t, resp.Result.Truncated)
+	resp = api.Presence(context.Background(), &PresenceRequest{Channel: "test", Limit: 2})
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.Result.Presence, 1)
+
+	limiter := &testPresenceLimiter{}
+	api.SetPresenceLimiter(limiter)
+	resp = api.Presence(context.Background(), &PresenceRequest{Channel: "test", TimeoutMs: 10})
+	require.Equal(t, ErrorInternal, resp.Error)
+	require.True(t, limiter.ctxDone)
+	resp = api.Presence(context.Background(), &PresenceRequest{Channel: "test", TimeoutMs: 10, Partial: true})
+	require.Nil(t, resp.Error)
+	require.True(t, resp.Result.Partial)
+	require.Len(t, resp.Result.Presence, 1)
+}
+
 func TestPresenceStatsAPI(t *testing.T) {
 	node := nodeWithMemoryEngine()
 	ruleConfig := rule.DefaultConfig
@@ -171,6 +560,16 @@ func TestDisconnectAPI(t *testing.T) {
 		User: "test",
 	})
 	require.Nil(t, resp.Error)
+	resp = api.Disconnect(context.Background(), &DisconnectRequest{
+		User:       "test",
+		Disconnect: &Disconnect{Code: 4001, Reason: "custom"},
+	})
+	require.Nil(t, resp.Error)
+	resp = api.Disconnect(context.Background(), &DisconnectRequest{
+		User:       "test",
+		Disconnect: &Disconnect{Code: 1000},
+	})
+	require.Equal(t, ErrorBadRequest, resp.Error)
 }
 
 func TestUnsubscribeAPI(t *testing.T) {
@@ -197,3 +596,544 @@ func TestInfoAPI(t *testing.T) {
 	resp := api.Info(context.Background(), &InfoRequest{})
 	require.Nil(t, resp.Error)
 }
+
+func TestLockAPI(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	ruleConfig := rule.DefaultConfig
+	ruleContainer := rule.NewContainer(ruleConfig)
+
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+	acquireResp := api.AcquireLock(context.Background(), &AcquireLockRequest{Name: "vehicle.1", Owner: "a", TtlMs: 1000})
+	require.Equal(t, ErrorNotAvailable, acquireResp.Error)
+
+	api.SetLocker(memengine.NewLocker())
+
+	acquireResp = api.AcquireLock(context.Background(), &AcquireLockRequest{Name: "vehicle.1", Owner: "a"})
+	require.Equal(t, ErrorBadRequest, acquireResp.Error)
+
+	acquireResp = api.AcquireLock(context.Background(), &AcquireLockRequest{Name: "vehicle.1", Owner: "a", TtlMs: 1000})
+	require.Nil(t, acquireResp.Error)
+	require.True(t, acquireResp.Result.Acquired)
+
+	acquireResp = api.AcquireLock(context.Background(), &AcquireLockRequest{Name: "vehicle.1", Owner: "b", TtlMs: 1000})
+	require.Nil(t, acquireResp.Error)
+	require.False(t, acquireResp.Result.Acquired)
+
+	releaseResp := api.ReleaseLock(context.Background(), &ReleaseLockRequest{Name: "vehicle.1", Owner: "b"})
+	require.Nil(t, releaseResp.Error)
+	require.False(t, releaseResp.Result.Released)
+
+	releaseResp = api.ReleaseLock(context.Background(), &ReleaseLockRequest{Name: "vehicle.1", Owner: "a"})
+	require.Nil(t, releaseResp.Error)
+	require.True(t, releaseResp.Result.Released)
+
+	acquireResp = api.AcquireLock(context.Background(), &AcquireLockRequest{Name: "vehicle.1", Owner: "b", TtlMs: 1000})
+	require.Nil(t, acquireResp.Error)
+	require.True(t, acquireResp.Result.Acquired)
+}
+
+func TestChannelMetaAPI(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	ruleConfig := rule.DefaultConfig
+	ruleContainer := rule.NewContainer(ruleConfig)
+
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+	setResp := api.SetChannelMeta(context.Background(), &SetChannelMetaRequest{Channel: "test", Key: "topic", Value: []byte(`"news"`)})
+	require.Equal(t, ErrorNotAvailable, setResp.Error)
+
+	api.SetChannelMetaStore(memengine.NewMetaStore())
+
+	setResp = api.SetChannelMeta(context.Background(), &SetChannelMetaRequest{Channel: "test", Key: "topic", Value: []byte(`news`)})
+	require.Equal(t, ErrorBadRequest, setResp.Error)
+	setResp = api.SetChannelMeta(context.Background(), &SetChannelMetaRequest{Channel: "nonexistent:test", Key: "topic", Value: []byte(`"news"`)})
+	require.Equal(t, ErrorUnknownChannel, setResp.Error)
+
+	setResp = api.SetChannelMeta(context.Background(), &SetChannelMetaRequest{Channel: "test", Key: "topic", Value: []byte(`"news"`)})
+	require.Nil(t, setResp.Error)
+	setResp = api.SetChannelMeta(context.Background(), &SetChannelMetaRequest{Channel: "test", Key: "title", Value: []byte(`"live"`), TtlMs: 60000})
+	require.Nil(t, setResp.Error)
+
+	getResp := api.GetChannelMeta(context.Background(), &GetChannelMetaRequest{Channel: "test"})
+	require.Nil(t, getResp.Error)
+	require.Len(t, getResp.Result.Entries, 2)
+	require.Equal(t, "title", getResp.Result.Entries[0].Key)
+	require.Equal(t, Raw(`"news"`), getResp.Result.Entries[1].Value)
+
+	deleteResp := api.DeleteChannelMeta(context.Background(), &DeleteChannelMetaRequest{Channel: "test", Key: "title"})
+	require.Nil(t, deleteResp.Error)
+	getResp = api.GetChannelMeta(context.Background(), &GetChannelMetaRequest{Channel: "test"})
+	require.Nil(t, getResp.Error)
+	require.Len(t, getResp.Result.Entries, 1)
+}
+
+func TestReadPositionsAPI(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.HistorySize = 10
+	ruleConfig.HistoryTTL = tools.Duration(time.Minute)
+	ruleContainer := rule.NewContainer(ruleConfig)
+
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+	setResp := api.SetReadPosition(context.Background(), &SetReadPositionRequest{User: "42", Channel: "test"})
+	require.Equal(t, ErrorNotAvailable, setResp.Error)
+
+	api.SetReadPositionStore(memengine.NewReadPositionStore())
+
+	setResp = api.SetReadPosition(context.Background(), &SetReadPositionRequest{Channel: "test"})
+	require.Equal(t, ErrorBadRequest, setResp.Error)
+	setResp = api.SetReadPosition(context.Background(), &SetReadPositionRequest{User: "42", Channel: "nonexistent:test"})
+	require.Equal(t, ErrorUnknownChannel, setResp.Error)
+
+	var top *PublishResponse
+	for i := 0; i < 5; i++ {
+		top = api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte("{}")})
+		require.Nil(t, top.Error)
+	}
+	setResp = api.SetReadPosition(context.Background(), &SetReadPositionRequest{User: "42", Channel: "test", Offset: 2, Epoch: top.Result.Epoch})
+	require.Nil(t, setResp.Error)
+
+	resp := api.ReadPositions(context.Background(), &ReadPositionsRequest{User: "42", Channels: []string{"test", "other"}})
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.Result.Positions, 1)
+	require.Equal(t, uint64(2), resp.Result.Positions[0].Offset)
+	require.Equal(t, uint64(0), resp.Result.Positions[0].Unread)
+
+	resp = api.ReadPositions(context.Background(), &ReadPositionsRequest{User: "42", Channels: []string{"test", "other"}, Unread: true})
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.Result.Positions, 2)
+	require.Equal(t, uint64(3), resp.Result.Positions[0].Unread)
+	require.Equal(t, "other", resp.Result.Positions[1].Channel)
+	require.Equal(t, uint64(0), resp.Result.Positions[1].Unread)
+}
+
+type testPublishInterceptor struct {
+	published []string
+}
+
+func (i *testPublishInterceptor) Name() string {
+	return "test"
+}
+
+func (i *testPublishInterceptor) BeforePublish(_ context.Context, pub *interceptor.Publication) error {
+	if pub.Channel == "denied" {
+		return centrifuge.ErrorPermissionDenied
+	}
+	pub.Data = []byte(`{"intercepted":true}`)
+	return nil
+}
+
+func (i *testPublishInterceptor) AfterPublish(_ context.Context, pub interceptor.Publication, _ centrifuge.StreamPosition, err error) {
+	if err == nil {
+		i.published = append(i.published, pub.Channel)
+	}
+}
+
+func TestPublishInterceptorAPI(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.HistorySize = 10
+	ruleConfig.HistoryTTL = tools.Duration(time.Minute)
+	ruleContainer := rule.NewContainer(ruleConfig)
+
+	i := &testPublishInterceptor{}
+	chain := interceptor.NewChain()
+	require.NoError(t, chain.Register(i))
+
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+	api.SetInterceptors(chain)
+
+	resp := api.Publish(context.Background(), &PublishRequest{Channel: "denied", Data: []byte("{}")})
+	require.Equal(t, ErrorPermissionDenied, resp.Error)
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte("{}")})
+	require.Nil(t, resp.Error)
+
+	broadcastResp := api.Broadcast(context.Background(), &BroadcastRequest{Channels: []string{"test", "denied"}, Data: []byte("{}")})
+	require.Nil(t, broadcastResp.Error)
+	require.Nil(t, broadcastResp.Result.Responses[0].Error)
+	require.Equal(t, ErrorPermissionDenied, broadcastResp.Result.Responses[1].Error)
+	require.Equal(t, []string{"test", "test"}, i.published)
+
+	history, err := node.History("test", centrifuge.WithLimit(centrifuge.NoLimit))
+	require.NoError(t, err)
+	require.Len(t, history.Publications, 2)
+	require.Equal(t, `{"intercepted":true}`, string(history.Publications[0].Data))
+}
+
+func TestPublicationTagsAPI(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Namespaces = []rule.ChannelNamespace{{
+		Name: "tags",
+		ChannelOptions: rule.ChannelOptions{
+			HistorySize:     10,
+			HistoryTTL:      tools.Duration(time.Minute),
+			PublicationTags: true,
+		},
+	}}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+
+	tags := map[string]string{"region": "eu"}
+	resp := api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte("{}"), Tags: tags})
+	require.Equal(t, ErrorBadRequest, resp.Error)
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "tags:test", Data: []byte("{"), Tags: tags})
+	require.Equal(t, ErrorBadRequest, resp.Error)
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "tags:test", Data: []byte(`{"a":1}`), Tags: tags})
+	require.Nil(t, resp.Error)
+
+	broadcastResp := api.Broadcast(context.Background(), &BroadcastRequest{Channels: []string{"tags:test", "test"}, Data: []byte(`{"a":2}`)})
+	require.Nil(t, broadcastResp.Error)
+	require.Nil(t, broadcastResp.Result.Responses[0].Error)
+	require.Nil(t, broadcastResp.Result.Responses[1].Error)
+
+	history, err := node.History("tags:test", centrifuge.WithLimit(centrifuge.NoLimit))
+	require.NoError(t, err)
+	require.Len(t, history.Publications, 2)
+	require.JSONEq(t, `{"tags":{"region":"eu"},"data":{"a":1}}`, string(history.Publications[0].Data))
+	require.JSONEq(t, `{"data":{"a":2}}`, string(history.Publications[1].Data))
+}
+
+func TestPublicationOriginAPI(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Namespaces = []rule.ChannelNamespace{{
+		Name: "origin",
+		ChannelOptions: rule.ChannelOptions{
+			HistorySize:       10,
+			HistoryTTL:        tools.Duration(time.Minute),
+			PublicationOrigin: true,
+			PublicationTags:   true,
+		},
+	}}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+
+	resp := api.Publish(context.Background(), &PublishRequest{Channel: "origin:test", Data: []byte("{")})
+	require.Equal(t, ErrorBadRequest, resp.Error)
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "origin:test", Data: []byte(`{"a":1}`), Tags: map[string]string{"region": "eu"}})
+	require.Nil(t, resp.Error)
+
+	broadcastResp := api.Broadcast(context.Background(), &BroadcastRequest{Channels: []string{"origin:test"}, Data: []byte(`{"a":2}`)})
+	require.Nil(t, broadcastResp.Error)
+	require.Nil(t, broadcastResp.Result.Responses[0].Error)
+
+	history, err := node.History("origin:test", centrifuge.WithLimit(centrifuge.NoLimit))
+	require.NoError(t, err)
+	require.Len(t, history.Publications, 2)
+	require.JSONEq(t, `{"origin":{"type":"api","node":"`+node.ID()+`"},"tags":{"region":"eu"},"data":{"a":1}}`, string(history.Publications[0].Data))
+	require.JSONEq(t, `{"origin":{"type":"api","node":"`+node.ID()+`"},"data":{"a":2}}`, string(history.Publications[1].Data))
+}
+
+func TestChannelGroupAPI(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Namespaces = []rule.ChannelNamespace{{
+		Name:           "group",
+		ChannelOptions: rule.ChannelOptions{ChannelGroup: true},
+	}}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+
+	resp := api.SetChannelGroup(context.Background(), &SetChannelGroupRequest{Channel: "group:devices", Sources: []string{"device1"}})
+	require.Equal(t, ErrorNotAvailable, resp.Error)
+
+	api.SetChannelGroupManager(fanin.NewManager(node, fanin.Config{
+		IsGroup: func(ch string) bool {
+			chOpts, found, err := ruleContainer.ChannelOptions(ch)
+			return err == nil && found && chOpts.ChannelGroup
+		},
+		Store: memengine.NewChannelGroupStore(),
+	}))
+
+	resp = api.SetChannelGroup(context.Background(), &SetChannelGroupRequest{Channel: "group:devices"})
+	require.Equal(t, ErrorBadRequest, resp.Error)
+	resp = api.SetChannelGroup(context.Background(), &SetChannelGroupRequest{Channel: "unknown:devices", Sources: []string{"device1"}})
+	require.Equal(t, ErrorUnknownChannel, resp.Error)
+	resp = api.SetChannelGroup(context.Background(), &SetChannelGroupRequest{Channel: "devices", Sources: []string{"device1"}})
+	require.Equal(t, ErrorNotAvailable, resp.Error)
+	resp = api.SetChannelGroup(context.Background(), &SetChannelGroupRequest{Channel: "group:devices", Sources: []string{"group:other"}})
+	require.Equal(t, ErrorBadRequest, resp.Error)
+	resp = api.SetChannelGroup(context.Background(), &SetChannelGroupRequest{Channel: "group:devices", Sources: []string{"device1", "device2"}})
+	require.Nil(t, resp.Error)
+
+	groupResp := api.ChannelGroup(context.Background(), &ChannelGroupRequest{Channel: "group:devices"})
+	require.Nil(t, groupResp.Error)
+	require.Equal(t, []string{"device1", "device2"}, groupResp.Result.Sources)
+
+	removeResp := api.RemoveChannelGroup(context.Background(), &RemoveChannelGroupRequest{Channel: "group:devices"})
+	require.Nil(t, removeResp.Error)
+	groupResp = api.ChannelGroup(context.Background(), &ChannelGroupRequest{Channel: "group:devices"})
+	require.Nil(t, groupResp.Error)
+	require.Len(t, groupResp.Result.Sources, 0)
+}
+
+func TestTenantAPI(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Namespaces = []rule.ChannelNamespace{{Name: "app1"}, {Name: "app2"}}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	tenants, err := tenant.NewRegistry([]tenant.Tenant{
+		{Name: "app1", APIKey: "app1_key", Namespaces: []string{"app1"}},
+		{Name: "app2", APIKey: "app2_key", Namespaces: []string{"app2"}},
+	}, ruleContainer.ChannelNamespace)
+	require.NoError(t, err)
+
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+	api.SetTenants(tenants)
+
+	ctx := tenant.SetToContext(context.Background(), "app1")
+
+	resp := api.Publish(ctx, &PublishRequest{Channel: "app1:test", Data: []byte("{}")})
+	require.Nil(t, resp.Error)
+	resp = api.Publish(ctx, &PublishRequest{Channel: "app2:test", Data: []byte("{}")})
+	require.Equal(t, ErrorPermissionDenied, resp.Error)
+	resp = api.Publish(ctx, &PublishRequest{Channel: "test", Data: []byte("{}")})
+	require.Equal(t, ErrorPermissionDenied, resp.Error)
+
+	broadcastResp := api.Broadcast(ctx, &BroadcastRequest{Channels: []string{"app1:test", "app2:test"}, Data: []byte("{}")})
+	require.Equal(t, ErrorPermissionDenied, broadcastResp.Error)
+
+	disconnectResp := api.Disconnect(ctx, &DisconnectRequest{User: "test"})
+	require.Equal(t, ErrorPermissionDenied, disconnectResp.Error)
+
+	// Requests authorized with main API key can use all channels.
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "app1:test", Data: []byte("{}")})
+	require.Nil(t, resp.Error)
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte("{}")})
+	require.Nil(t, resp.Error)
+}
+
+func TestToAPIErr(t *testing.T) {
+	require.Equal(t, ErrorBadRequest, toAPIErr(ErrorBadRequest))
+	require.Equal(t, ErrorInternal, toAPIErr(errors.New("boom")))
+	require.True(t, toAPIErr(errors.New("boom")).Temporary)
+
+	apiErr := toAPIErr(centrifuge.ErrorTooManyRequests)
+	require.Equal(t, centrifuge.ErrorTooManyRequests.Code, apiErr.Code)
+	require.True(t, apiErr.Temporary)
+
+	apiErr = toAPIErr(fmt.Errorf("history: %w", centrifuge.ErrorUnrecoverablePosition))
+	require.Equal(t, ErrorUnrecoverablePosition.Code, apiErr.Code)
+	require.False(t, apiErr.Temporary)
+}
+
+type channelsSurveyCaller struct {
+	testSurveyCaller
+	channels []string
+}
+
+func (t channelsSurveyCaller) Channels(_ context.Context, req *ChannelsRequest) (map[string]*ChannelInfo, error) {
+	names := append([]string(nil), t.channels...)
+	sort.Strings(names)
+	channels := map[string]*ChannelInfo{}
+	for _, ch := range names {
+		if req.Limit > 0 && len(channels) > int(req.Limit) {
+			break
+		}
+		if ch > req.Cursor {
+			channels[ch] = &ChannelInfo{NumClients: 1}
+		}
+	}
+	return channels, nil
+}
+
+func TestChannelsAPIPagination(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	api := NewExecutor(node, ruleContainer, channelsSurveyCaller{channels: []string{"c", "a", "b"}}, "test")
+
+	resp := api.Channels(context.Background(), &ChannelsRequest{Limit: -1})
+	require.Equal(t, ErrorBadRequest, resp.Error)
+
+	resp = api.Channels(context.Background(), &ChannelsRequest{Limit: 2})
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.Result.Channels, 2)
+	require.Contains(t, resp.Result.Channels, "a")
+	require.Contains(t, resp.Result.Channels, "b")
+	require.Equal(t, "b", resp.Result.NextCursor)
+
+	resp = api.Channels(context.Background(), &ChannelsRequest{Limit: 2, Cursor: resp.Result.NextCursor})
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.Result.Channels, 1)
+	require.Contains(t, resp.Result.Channels, "c")
+	require.Equal(t, "", resp.Result.NextCursor)
+
+	resp = api.Channels(context.Background(), &ChannelsRequest{})
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.Result.Channels, 3)
+	require.Equal(t, "", resp.Result.NextCursor)
+}
+
+func TestChannelsAPITenantPagination(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Namespaces = []rule.ChannelNamespace{{Name: "app1"}, {Name: "app2"}}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	tenants, err := tenant.NewRegistry([]tenant.Tenant{
+		{Name: "app1", APIKey: "app1_key", Namespaces: []string{"app1"}},
+		{Name: "app2", APIKey: "app2_key", Namespaces: []string{"app2"}},
+	}, ruleContainer.ChannelNamespace)
+	require.NoError(t, err)
+
+	api := NewExecutor(node, ruleContainer, channelsSurveyCaller{channels: []string{
+		"app1:a", "app2:a", "app2:b", "app2:c", "app1:b", "app1:c",
+	}}, "test")
+	api.SetTenants(tenants)
+	ctx := tenant.SetToContext(context.Background(), "app2")
+
+	resp := api.Channels(ctx, &ChannelsRequest{Limit: 2})
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.Result.Channels, 2)
+	require.Contains(t, resp.Result.Channels, "app2:a")
+	require.Contains(t, resp.Result.Channels, "app2:b")
+	require.Equal(t, "app2:b", resp.Result.NextCursor)
+
+	resp = api.Channels(ctx, &ChannelsRequest{Limit: 2, Cursor: resp.Result.NextCursor})
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.Result.Channels, 1)
+	require.Contains(t, resp.Result.Channels, "app2:c")
+	require.Equal(t, "", resp.Result.NextCursor)
+
+	ctx = tenant.SetToContext(context.Background(), "app1")
+	resp = api.Channels(ctx, &ChannelsRequest{Limit: 3})
+	require.Nil(t, resp.Error)
+	require.Len(t, resp.Result.Channels, 3)
+	require.Equal(t, "", resp.Result.NextCursor)
+}
+
+func TestPublishInboxAPI(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	presenceManager, err := centrifuge.NewMemoryPresenceManager(node, centrifuge.MemoryPresenceManagerConfig{})
+	require.NoError(t, err)
+	node.SetPresenceManager(presenceManager)
+
+	ruleConfig := rule.DefaultConfig
+	ruleContainer := rule.NewContainer(ruleConfig)
+
+	store := memengine.NewInboxStore()
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+	pub := publisher.New(node, ruleContainer)
+	pub.SetInbox(store, inbox.Options{Size: 10, TTL: time.Minute})
+	api.SetPublisher(pub)
+
+	resp := api.Publish(context.Background(), &PublishRequest{Channel: "#42", Data: []byte(`{}`)})
+	require.Nil(t, resp.Error)
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte(`{}`)})
+	require.Nil(t, resp.Error)
+	entries, err := store.PeekInbox("42")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, []byte(`{}`), entries[0].Data)
+	require.NoError(t, store.TrimInbox("42", len(entries)))
+
+	// Online user does not get publications into inbox.
+	require.NoError(t, presenceManager.AddPresence("#42", "client", &centrifuge.ClientInfo{ClientID: "client", UserID: "42"}))
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "#42", Data: []byte(`{}`)})
+	require.Nil(t, resp.Error)
+	entries, err = store.PeekInbox("42")
+	require.NoError(t, err)
+	require.Len(t, entries, 0)
+}
+
+type testPushNotifier struct {
+	store    pushnotify.DeviceStore
+	mu       sync.Mutex
+	notified []string
+}
+
+func (n *testPushNotifier) Notify(user string, _ []byte) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notified = append(n.notified, user)
+}
+
+func (n *testPushNotifier) notifiedUsers() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.notified...)
+}
+
+func (n *testPushNotifier) Store() pushnotify.DeviceStore {
+	return n.store
+}
+
+func TestPushDeviceAPI(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	presenceManager, err := centrifuge.NewMemoryPresenceManager(node, centrifuge.MemoryPresenceManagerConfig{})
+	require.NoError(t, err)
+	node.SetPresenceManager(presenceManager)
+
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+
+	regResp := api.RegisterPushDevice(context.Background(), &RegisterPushDeviceRequest{User: "42", Platform: "fcm", Token: "token"})
+	require.Equal(t, ErrorNotAvailable, regResp.Error)
+
+	notifier := &testPushNotifier{store: memengine.NewPushDeviceStore()}
+	api.SetPushNotifier(notifier)
+	pub := publisher.New(node, ruleContainer)
+	pub.SetPushNotifier(notifier)
+	api.SetPublisher(pub)
+
+	regResp = api.RegisterPushDevice(context.Background(), &RegisterPushDeviceRequest{User: "42", Platform: "unknown", Token: "token"})
+	require.Equal(t, ErrorBadRequest, regResp.Error)
+	regResp = api.RegisterPushDevice(context.Background(), &RegisterPushDeviceRequest{User: "42", Platform: "fcm", Token: "token"})
+	require.Nil(t, regResp.Error)
+	devices, err := notifier.store.Devices("42")
+	require.NoError(t, err)
+	require.Equal(t, []pushnotify.Device{{Platform: "fcm", Token: "token"}}, devices)
+
+	resp := api.Publish(context.Background(), &PublishRequest{Channel: "#42", Data: []byte(`{}`)})
+	require.Nil(t, resp.Error)
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "test", Data: []byte(`{}`)})
+	require.Nil(t, resp.Error)
+	require.Equal(t, []string{"42"}, notifier.notifiedUsers())
+
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "#43", Data: []byte(`{}`), NoWait: true})
+	require.Nil(t, resp.Error)
+	require.Eventually(t, func() bool {
+		return len(notifier.notifiedUsers()) == 2
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"42", "43"}, notifier.notifiedUsers())
+
+	// Online user does not get push notifications.
+	require.NoError(t, presenceManager.AddPresence("#42", "client", &centrifuge.ClientInfo{ClientID: "client", UserID: "42"}))
+	resp = api.Publish(context.Background(), &PublishRequest{Channel: "#42", Data: []byte(`{}`)})
+	require.Nil(t, resp.Error)
+	require.Equal(t, []string{"42", "43"}, notifier.notifiedUsers())
+
+	unregResp := api.UnregisterPushDevice(context.Background(), &UnregisterPushDeviceRequest{User: "42", Platform: "fcm", Token: "token"})
+	require.Nil(t, unregResp.Error)
+	devices, err = notifier.store.Devices("42")
+	require.NoError(t, err)
+	require.Len(t, devices, 0)
+}
+
+func TestSetChannelOptionsStore(t *testing.T) {
+	node := nodeWithMemoryEngine()
+	ruleConfig := rule.DefaultConfig
+	ruleContainer := rule.NewContainer(ruleConfig)
+	api := NewExecutor(node, ruleContainer, &testSurveyCaller{}, "test")
+	store := memengine.NewChannelOptionsStore()
+	api.SetChannelOptionsStore(store)
+
+	resp := api.SetChannelOptions(context.Background(), &SetChannelOptionsRequest{
+		Channel:  "hot",
+		Override: &ChannelOptionsOverride{Presence: &BoolValue{Value: true}},
+	})
+	require.Nil(t, resp.Error)
+
+	// Node started later loads override from store.
+	otherContainer := rule.NewContainer(ruleConfig)
+	skipped, err := otherContainer.LoadChannelOptionsOverrides(store)
+	require.NoError(t, err)
+	require.Len(t, skipped, 0)
+	opts, _, _ := otherContainer.ChannelOptions("hot")
+	require.True(t, opts.Presence)
+
+	resp = api.SetChannelOptions(context.Background(), &SetChannelOptionsRequest{Channel: "hot"})
+	require.Nil(t, resp.Error)
+	overrides, err := store.ChannelOptionsOverrides()
+	require.NoError(t, err)
+	require.Len(t, overrides, 0)
+}
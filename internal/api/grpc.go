@@ -3,38 +3,86 @@ package api
 import (
 	"context"
 	"crypto/subtle"
+	"strings"
+	"time"
 
+	"github.com/centrifugal/centrifugo/v3/internal/apiaudit"
 	. "github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/tenant"
 
 	"github.com/centrifugal/centrifuge"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
-func authorize(ctx context.Context, key []byte) error {
-	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		if len(md["authorization"]) > 0 && subtle.ConstantTimeCompare([]byte(md["authorization"][0]), key) == 1 {
-			return nil
+func authorize(ctx context.Context, key []byte, tenants *tenant.Registry) (context.Context, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md["authorization"]) > 0 {
+		if len(key) > 0 && subtle.ConstantTimeCompare([]byte(md["authorization"][0]), key) == 1 {
+			return ctx, nil
+		}
+		parts := strings.Fields(md["authorization"][0])
+		if len(parts) == 2 && strings.ToLower(parts[0]) == "apikey" {
+			if name, ok := tenants.ByAPIKey(parts[1]); ok {
+				return tenant.SetToContext(ctx, name), nil
+			}
 		}
 	}
-	return status.Error(codes.Unauthenticated, "unauthenticated")
+	return nil, status.Error(codes.Unauthenticated, "unauthenticated")
 }
 
 // GRPCKeyAuth allows to set simple authentication based on string key from configuration.
 // Client should provide per RPC credentials: set authorization key to metadata with value
 // `apikey <KEY>`.
 func GRPCKeyAuth(key string) grpc.ServerOption {
-	authKey := []byte("apikey " + key)
+	return GRPCTenantKeyAuth(key, nil)
+}
+
+// GRPCTenantKeyAuth works like GRPCKeyAuth but additionally accepts API keys
+// of tenants. Requests authorized with tenant key can only operate with tenant
+// channels.
+func GRPCTenantKeyAuth(key string, tenants *tenant.Registry) grpc.ServerOption {
+	var authKey []byte
+	if key != "" {
+		authKey = []byte("apikey " + key)
+	}
 	return grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		if err := authorize(ctx, authKey); err != nil {
+		ctx, err = authorize(ctx, authKey, tenants)
+		if err != nil {
 			return nil, err
 		}
 		return handler(ctx, req)
 	})
 }
 
+// GRPCAudit records GRPC API calls in audit Log. Must be used together with
+// authorization option so key ID of tenant is known.
+func GRPCAudit(l *apiaudit.Log) grpc.ServerOption {
+	return grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		started := time.Now()
+		resp, err := handler(ctx, req)
+		record := apiaudit.Record{
+			Time:     started,
+			Protocol: "grpc",
+			Method:   apiaudit.MethodName(info.FullMethod),
+			KeyID:    apiaudit.KeyIDFromContext(ctx),
+			Latency:  time.Since(started),
+		}
+		if m, ok := req.(proto.Message); ok {
+			record.Params, _ = proto.MarshalOptions{Deterministic: true}.Marshal(m)
+		}
+		if err != nil {
+			record.Code = ErrorInternal.Code
+		} else if r, ok := resp.(interface{ GetError() *Error }); ok && r.GetError() != nil {
+			record.Code = r.GetError().Code
+		}
+		l.Add(record)
+		return resp, err
+	})
+}
+
 // GRPCAPIServiceConfig for GRPC API Service.
 type GRPCAPIServiceConfig struct{}
 
@@ -124,3 +172,113 @@ func (s *grpcAPIService) Refresh(ctx context.Context, req *RefreshRequest) (*Ref
 func (s *grpcAPIService) Channels(ctx context.Context, req *ChannelsRequest) (*ChannelsResponse, error) {
 	return s.api.Channels(ctx, req), nil
 }
+
+// ReloadConfig applies new configuration on all nodes.
+func (s *grpcAPIService) ReloadConfig(ctx context.Context, req *ReloadConfigRequest) (*ReloadConfigResponse, error) {
+	return s.api.ReloadConfig(ctx, req), nil
+}
+
+// SetChannelOptions overrides options of a single channel.
+func (s *grpcAPIService) SetChannelOptions(ctx context.Context, req *SetChannelOptionsRequest) (*SetChannelOptionsResponse, error) {
+	return s.api.SetChannelOptions(ctx, req), nil
+}
+
+// UserConnections returns active connections of user.
+func (s *grpcAPIService) UserConnections(ctx context.Context, req *UserConnectionsRequest) (*UserConnectionsResponse, error) {
+	return s.api.UserConnections(ctx, req), nil
+}
+
+// ChannelSubscribers returns number of channel subscribers on every node.
+func (s *grpcAPIService) ChannelSubscribers(ctx context.Context, req *ChannelSubscribersRequest) (*ChannelSubscribersResponse, error) {
+	return s.api.ChannelSubscribers(ctx, req), nil
+}
+
+// AcquireLock acquires a distributed lock.
+func (s *grpcAPIService) AcquireLock(ctx context.Context, req *AcquireLockRequest) (*AcquireLockResponse, error) {
+	return s.api.AcquireLock(ctx, req), nil
+}
+
+// ReleaseLock releases a distributed lock.
+func (s *grpcAPIService) ReleaseLock(ctx context.Context, req *ReleaseLockRequest) (*ReleaseLockResponse, error) {
+	return s.api.ReleaseLock(ctx, req), nil
+}
+
+// ConnectionEvents returns recent connection events from all nodes.
+func (s *grpcAPIService) ConnectionEvents(ctx context.Context, req *ConnectionEventsRequest) (*ConnectionEventsResponse, error) {
+	return s.api.ConnectionEvents(ctx, req), nil
+}
+
+// SetChannelMeta sets channel meta entry.
+func (s *grpcAPIService) SetChannelMeta(ctx context.Context, req *SetChannelMetaRequest) (*SetChannelMetaResponse, error) {
+	return s.api.SetChannelMeta(ctx, req), nil
+}
+
+// GetChannelMeta returns channel meta entries.
+func (s *grpcAPIService) GetChannelMeta(ctx context.Context, req *GetChannelMetaRequest) (*GetChannelMetaResponse, error) {
+	return s.api.GetChannelMeta(ctx, req), nil
+}
+
+// DeleteChannelMeta deletes channel meta entry.
+func (s *grpcAPIService) DeleteChannelMeta(ctx context.Context, req *DeleteChannelMetaRequest) (*DeleteChannelMetaResponse, error) {
+	return s.api.DeleteChannelMeta(ctx, req), nil
+}
+
+// AddRedisShard adds Redis shard on all running nodes.
+func (s *grpcAPIService) AddRedisShard(ctx context.Context, req *AddRedisShardRequest) (*AddRedisShardResponse, error) {
+	return s.api.AddRedisShard(ctx, req), nil
+}
+
+// HistoryMeta returns channel history stream state.
+func (s *grpcAPIService) HistoryMeta(ctx context.Context, req *HistoryMetaRequest) (*HistoryMetaResponse, error) {
+	return s.api.HistoryMeta(ctx, req), nil
+}
+
+// MigrateConnections asks connections of node to reconnect to other nodes.
+func (s *grpcAPIService) MigrateConnections(ctx context.Context, req *MigrateConnectionsRequest) (*MigrateConnectionsResponse, error) {
+	return s.api.MigrateConnections(ctx, req), nil
+}
+
+// SetReadPosition sets last read position of user in channel.
+func (s *grpcAPIService) SetReadPosition(ctx context.Context, req *SetReadPositionRequest) (*SetReadPositionResponse, error) {
+	return s.api.SetReadPosition(ctx, req), nil
+}
+
+// ReadPositions returns last read positions of user in channels.
+func (s *grpcAPIService) ReadPositions(ctx context.Context, req *ReadPositionsRequest) (*ReadPositionsResponse, error) {
+	return s.api.ReadPositions(ctx, req), nil
+}
+
+// SetChannelGroup sets source channels of channel group.
+func (s *grpcAPIService) SetChannelGroup(ctx context.Context, req *SetChannelGroupRequest) (*SetChannelGroupResponse, error) {
+	return s.api.SetChannelGroup(ctx, req), nil
+}
+
+// RemoveChannelGroup removes channel group.
+func (s *grpcAPIService) RemoveChannelGroup(ctx context.Context, req *RemoveChannelGroupRequest) (*RemoveChannelGroupResponse, error) {
+	return s.api.RemoveChannelGroup(ctx, req), nil
+}
+
+// ChannelGroup returns source channels of channel group.
+func (s *grpcAPIService) ChannelGroup(ctx context.Context, req *ChannelGroupRequest) (*ChannelGroupResponse, error) {
+	return s.api.ChannelGroup(ctx, req), nil
+}
+
+// ChannelsCount returns approximate number of active channels.
+func (s *grpcAPIService) ChannelsCount(ctx context.Context, req *ChannelsCountRequest) (*ChannelsCountResponse, error) {
+	return s.api.ChannelsCount(ctx, req), nil
+}
+
+// UnsubscribeChannel unsubscribes all clients of cluster from channel.
+func (s *grpcAPIService) UnsubscribeChannel(ctx context.Context, req *UnsubscribeChannelRequest) (*UnsubscribeChannelResponse, error) {
+	return s.api.UnsubscribeChannel(ctx, req), nil
+}
+
+// RegisterPushDevice registers device token of user to send push notifications to.
+func (s *grpcAPIService) RegisterPushDevice(ctx context.Context, req *RegisterPushDeviceRequest) (*RegisterPushDeviceResponse, error) {
+	return s.api.RegisterPushDevice(ctx, req), nil
+}
+
+// UnregisterPushDevice unregisters device token of user.
+func (s *grpcAPIService) UnregisterPushDevice(ctx context.Context, req *UnregisterPushDeviceRequest) (*UnregisterPushDeviceResponse, error) {
+	return s.api.UnregisterPushDevice(ctx, req), nil
+}
@@ -4,24 +4,26 @@ import (
 	"context"
 	"testing"
 
+	"github.com/centrifugal/centrifugo/v3/internal/tenant"
+
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/metadata"
 )
 
 func TestGRPC_Authorize_Unauthenticated(t *testing.T) {
-	err := authorize(context.Background(), []byte("apikey xxx"))
+	_, err := authorize(context.Background(), []byte("apikey xxx"), nil)
 	require.Error(t, err)
 
 	md := metadata.New(map[string]string{})
 	ctx := metadata.NewIncomingContext(context.Background(), md)
-	err = authorize(ctx, []byte("apikey xxx"))
+	_, err = authorize(ctx, []byte("apikey xxx"), nil)
 	require.Error(t, err)
 
 	md = metadata.New(map[string]string{
 		"authorization": "apikey yyy",
 	})
 	ctx = metadata.NewIncomingContext(context.Background(), md)
-	err = authorize(ctx, []byte("apikey xxx"))
+	_, err = authorize(ctx, []byte("apikey xxx"), nil)
 	require.Error(t, err)
 }
 
@@ -30,6 +32,28 @@ func TestGRPC_Authorize_OK(t *testing.T) {
 		"authorization": "apikey xxx",
 	})
 	ctx := metadata.NewIncomingContext(context.Background(), md)
-	err := authorize(ctx, []byte("apikey xxx"))
+	_, err := authorize(ctx, []byte("apikey xxx"), nil)
+	require.NoError(t, err)
+}
+
+func TestGRPC_Authorize_Tenant(t *testing.T) {
+	tenants, err := tenant.NewRegistry([]tenant.Tenant{
+		{Name: "app1", APIKey: "app1_key", Namespaces: []string{"app1"}},
+	}, func(string) string { return "" })
+	require.NoError(t, err)
+
+	md := metadata.New(map[string]string{
+		"authorization": "apikey app1_key",
+	})
+	ctx, err := authorize(metadata.NewIncomingContext(context.Background(), md), []byte("apikey xxx"), tenants)
 	require.NoError(t, err)
+	name, ok := tenant.FromContext(ctx)
+	require.True(t, ok)
+	require.Equal(t, "app1", name)
+
+	md = metadata.New(map[string]string{
+		"authorization": "apikey app2_key",
+	})
+	_, err = authorize(metadata.NewIncomingContext(context.Background(), md), []byte("apikey xxx"), tenants)
+	require.Error(t, err)
 }
@@ -4,7 +4,10 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/centrifugal/centrifugo/v3/internal/apiaudit"
 	. "github.com/centrifugal/centrifugo/v3/internal/apiproto"
 
 	"github.com/centrifugal/centrifuge"
@@ -53,7 +56,14 @@ func (s *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	encoder := GetReplyEncoder()
+	// Commands may be sent as newline-delimited JSON objects or as JSON array,
+	// replies are encoded in the same form.
+	var encoder ReplyEncoder
+	if IsCommandArray(data) {
+		encoder = GetArrayReplyEncoder()
+	} else {
+		encoder = GetReplyEncoder()
+	}
 	defer PutReplyEncoder(encoder)
 
 	decoder := GetCommandDecoder(data)
@@ -88,7 +98,57 @@ func (s *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(encoder.Finish())
 }
 
+// HandleCommands processes API commands encoded in the same format as HTTP API
+// request body. It's used to process commands received not over HTTP (for example
+// from Redis queue) so replies are not returned – command errors are logged.
+func (s *Handler) HandleCommands(ctx context.Context, data []byte) error {
+	decoder := GetCommandDecoder(data)
+	defer PutCommandDecoder(decoder)
+
+	for {
+		command, decodeErr := decoder.Decode()
+		if decodeErr != nil && decodeErr != io.EOF {
+			return decodeErr
+		}
+		if command != nil {
+			rep, err := s.handleAPICommand(ctx, command)
+			if err != nil {
+				return err
+			}
+			if rep.Error != nil {
+				s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "error processing API command", map[string]interface{}{"method": command.Method.String(), "error": rep.Error.Error()}))
+			}
+		}
+		if decodeErr == io.EOF {
+			return nil
+		}
+	}
+}
+
 func (s *Handler) handleAPICommand(ctx context.Context, cmd *Command) (*Reply, error) {
+	if s.api.audit == nil {
+		return s.processAPICommand(ctx, cmd)
+	}
+	started := time.Now()
+	rep, err := s.processAPICommand(ctx, cmd)
+	record := apiaudit.Record{
+		Time:     started,
+		Protocol: s.api.protocol,
+		Method:   strings.ToLower(cmd.Method.String()),
+		KeyID:    apiaudit.KeyIDFromContext(ctx),
+		Params:   cmd.Params,
+		Latency:  time.Since(started),
+	}
+	if err != nil {
+		record.Code = ErrorInternal.Code
+	} else if rep.Error != nil {
+		record.Code = rep.Error.Code
+	}
+	s.api.audit.Add(record)
+	return rep, err
+}
+
+func (s *Handler) processAPICommand(ctx context.Context, cmd *Command) (*Reply, error) {
 
 	method := cmd.Method
 	params := cmd.Params
@@ -335,6 +395,402 @@ func (s *Handler) handleAPICommand(ctx context.Context, cmd *Command) (*Reply, e
 				}
 			}
 		}
+	case Command_RELOAD_CONFIG:
+		cmd, err := decoder.DecodeReloadConfig(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding reload config params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.ReloadConfig(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeReloadConfig(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_SET_CHANNEL_OPTIONS:
+		cmd, err := decoder.DecodeSetChannelOptions(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding set channel options params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.SetChannelOptions(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeSetChannelOptions(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_USER_CONNECTIONS:
+		cmd, err := decoder.DecodeUserConnections(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding user connections params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.UserConnections(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeUserConnections(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_CHANNEL_SUBSCRIBERS:
+		cmd, err := decoder.DecodeChannelSubscribers(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding channel subscribers params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.ChannelSubscribers(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeChannelSubscribers(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_ACQUIRE_LOCK:
+		cmd, err := decoder.DecodeAcquireLock(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding acquire lock params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.AcquireLock(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeAcquireLock(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_RELEASE_LOCK:
+		cmd, err := decoder.DecodeReleaseLock(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding release lock params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.ReleaseLock(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeReleaseLock(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_CONNECTION_EVENTS:
+		cmd, err := decoder.DecodeConnectionEvents(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding connection events params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.ConnectionEvents(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeConnectionEvents(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_SET_CHANNEL_META:
+		cmd, err := decoder.DecodeSetChannelMeta(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding set channel meta params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.SetChannelMeta(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeSetChannelMeta(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_GET_CHANNEL_META:
+		cmd, err := decoder.DecodeGetChannelMeta(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding get channel meta params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.GetChannelMeta(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeGetChannelMeta(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_DELETE_CHANNEL_META:
+		cmd, err := decoder.DecodeDeleteChannelMeta(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding delete channel meta params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.DeleteChannelMeta(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeDeleteChannelMeta(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_ADD_REDIS_SHARD:
+		cmd, err := decoder.DecodeAddRedisShard(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding add redis shard params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.AddRedisShard(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeAddRedisShard(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_HISTORY_META:
+		cmd, err := decoder.DecodeHistoryMeta(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding history meta params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.HistoryMeta(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeHistoryMeta(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_MIGRATE_CONNECTIONS:
+		cmd, err := decoder.DecodeMigrateConnections(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding migrate connections params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.MigrateConnections(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeMigrateConnections(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_SET_READ_POSITION:
+		cmd, err := decoder.DecodeSetReadPosition(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding set read position params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.SetReadPosition(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeSetReadPosition(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_READ_POSITIONS:
+		cmd, err := decoder.DecodeReadPositions(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding read positions params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.ReadPositions(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeReadPositions(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_SET_CHANNEL_GROUP:
+		cmd, err := decoder.DecodeSetChannelGroup(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding set channel group params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.SetChannelGroup(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeSetChannelGroup(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_REMOVE_CHANNEL_GROUP:
+		cmd, err := decoder.DecodeRemoveChannelGroup(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding remove channel group params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.RemoveChannelGroup(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeRemoveChannelGroup(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_CHANNEL_GROUP:
+		cmd, err := decoder.DecodeChannelGroup(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding channel group params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.ChannelGroup(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeChannelGroup(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_CHANNELS_COUNT:
+		cmd, err := decoder.DecodeChannelsCount(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding channels count params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.ChannelsCount(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeChannelsCount(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_UNSUBSCRIBE_CHANNEL:
+		cmd, err := decoder.DecodeUnsubscribeChannel(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding unsubscribe channel params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.UnsubscribeChannel(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeUnsubscribeChannel(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_REGISTER_PUSH_DEVICE:
+		cmd, err := decoder.DecodeRegisterPushDevice(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding register push device params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.RegisterPushDevice(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeRegisterPushDevice(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case Command_UNREGISTER_PUSH_DEVICE:
+		cmd, err := decoder.DecodeUnregisterPushDevice(params)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding unregister push device params", map[string]interface{}{"error": err.Error()}))
+			rep.Error = ErrorBadRequest
+			return rep, nil
+		}
+		resp := s.api.UnregisterPushDevice(ctx, cmd)
+		if resp.Error != nil {
+			rep.Error = resp.Error
+		} else {
+			if resp.Result != nil {
+				replyRes, err = encoder.EncodeUnregisterPushDevice(resp.Result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
 	default:
 		rep.Error = ErrorMethodNotFound
 	}
@@ -3,12 +3,18 @@ package api
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 
+	"github.com/centrifugal/centrifugo/v3/internal/apiaudit"
+	. "github.com/centrifugal/centrifugo/v3/internal/apiproto"
 	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/tenant"
 
 	"github.com/stretchr/testify/require"
 )
@@ -55,6 +61,75 @@ func TestAPIHandler(t *testing.T) {
 	require.Equal(t, resp.StatusCode, http.StatusBadRequest)
 }
 
+func TestAPIHandlerBatch(t *testing.T) {
+	n := nodeWithMemoryEngine()
+	defer func() { _ = n.Shutdown(context.Background()) }()
+
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	apiExecutor := NewExecutor(n, ruleContainer, &testSurveyCaller{}, "test")
+
+	server := httptest.NewServer(NewHandler(n, apiExecutor, Config{}))
+	defer server.Close()
+
+	testCases := []struct {
+		Name     string
+		Data     string
+		Expected string
+	}{
+		{
+			Name:     "newline",
+			Data:     "{\"method\":\"publish\",\"params\":{\"channel\": \"test\", \"data\":{}}}\n{\"method\":\"publish\",\"params\":{\"channel\": \"\", \"data\":{}}}",
+			Expected: "{\"result\":{}}\n{\"error\":{\"code\":107,\"message\":\"bad request\"}}",
+		},
+		{
+			Name:     "array",
+			Data:     " [{\"method\":\"publish\",\"params\":{\"channel\": \"test\", \"data\":{}}}, {\"method\":\"publish\",\"params\":{\"channel\": \"\", \"data\":{}}}]",
+			Expected: "[{\"result\":{}},{\"error\":{\"code\":107,\"message\":\"bad request\"}}]",
+		},
+		{
+			Name:     "empty_array",
+			Data:     "[]",
+			Expected: "[]",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			resp, err := http.Post(server.URL, "application/json", strings.NewReader(tc.Data))
+			require.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tc.Expected, string(body))
+		})
+	}
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(`[{"method":"publish"`))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestAPIHandlerHandleCommands(t *testing.T) {
+	n := nodeWithMemoryEngine()
+	defer func() { _ = n.Shutdown(context.Background()) }()
+
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	h := NewHandler(n, NewExecutor(n, ruleContainer, &testSurveyCaller{}, "test"), Config{})
+
+	data := `{"method":"publish","params":{"channel": "test", "data":{}}}
+{"method":"broadcast","params":{"channels": ["test"], "data":{}}}`
+	require.NoError(t, h.HandleCommands(context.Background(), []byte(data)))
+
+	// Command error is not an error of processing.
+	data = `{"method":"publish","params":{"channel": "", "data":{}}}`
+	require.NoError(t, h.HandleCommands(context.Background(), []byte(data)))
+
+	data = `{"method":"unknown"}`
+	require.Error(t, h.HandleCommands(context.Background(), []byte(data)))
+}
+
 func BenchmarkAPIHandler(b *testing.B) {
 	n := nodeWithMemoryEngine()
 	defer func() { _ = n.Shutdown(context.Background()) }()
@@ -80,3 +155,44 @@ func BenchmarkAPIHandler(b *testing.B) {
 		}
 	})
 }
+
+type testAuditSink struct {
+	mu      sync.Mutex
+	entries [][]byte
+}
+
+func (s *testAuditSink) Write(entries [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entries...)
+	return nil
+}
+
+func TestAPIHandlerAudit(t *testing.T) {
+	n := nodeWithMemoryEngine()
+	defer func() { _ = n.Shutdown(context.Background()) }()
+
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	apiExecutor := NewExecutor(n, ruleContainer, &testSurveyCaller{}, "http")
+	sink := &testAuditSink{}
+	auditLog := apiaudit.New(n, apiaudit.Config{Sink: sink})
+	apiExecutor.SetAuditLog(auditLog)
+	handler := NewHandler(n, apiExecutor, Config{})
+
+	err := handler.HandleCommands(context.Background(), []byte(`{"method":"publish","params":{"channel":"test","data":{}}}`))
+	require.NoError(t, err)
+	err = handler.HandleCommands(tenant.SetToContext(context.Background(), "app1"), []byte(`{"method":"publish","params":{"channel":"","data":{}}}`))
+	require.NoError(t, err)
+	auditLog.Close()
+
+	require.Len(t, sink.entries, 2)
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(sink.entries[0], &record))
+	require.Equal(t, "http", record["protocol"])
+	require.Equal(t, "publish", record["method"])
+	require.Equal(t, apiaudit.DefaultKeyID, record["key_id"])
+	require.Equal(t, float64(0), record["code"])
+	require.NoError(t, json.Unmarshal(sink.entries[1], &record))
+	require.Equal(t, "app1", record["key_id"])
+	require.Equal(t, float64(ErrorPermissionDenied.Code), record["code"])
+}
@@ -30,12 +30,26 @@ var (
 		Objectives: map[float64]float64{0.5: 0.05, 0.99: 0.001, 0.999: 0.0001},
 		Help:       "Duration of API per command.",
 	}, []string{"protocol", "method"})
+	noWaitPublishErrorCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "api",
+		Name:      "no_wait_publish_errors",
+		Help:      "Count of errors publishing data to channel in no_wait mode.",
+	}, []string{"protocol", "method"})
+	noWaitPublishRejectedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "api",
+		Name:      "no_wait_publish_rejected",
+		Help:      "Count of no_wait publications rejected due to full publish queue.",
+	}, []string{"protocol", "method"})
 )
 
 func init() {
 	prometheus.MustRegister(apiCommandDurationSummary)
 	prometheus.MustRegister(apiCommandDurationHistogram)
 	prometheus.MustRegister(rpcDurationSummary)
+	prometheus.MustRegister(noWaitPublishErrorCount)
+	prometheus.MustRegister(noWaitPublishRejectedCount)
 }
 
 func observe(started time.Time, protocol string, method string) {
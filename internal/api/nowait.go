@@ -0,0 +1,60 @@
+package api
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+const (
+	// DefaultNoWaitWorkers is a default number of workers publishing
+	// no_wait publications.
+	DefaultNoWaitWorkers = 16
+	// DefaultNoWaitQueueSize is a default max number of no_wait publications
+	// waiting for publishing in queue of one worker.
+	DefaultNoWaitQueueSize = 1024
+)
+
+// noWaitQueue publishes no_wait publications on a fixed number of workers
+// with bounded queues. Publications to the same channel always handled by
+// the same worker, so they are published in order.
+type noWaitQueue struct {
+	once    sync.Once
+	size    int
+	workers []chan func()
+}
+
+func newNoWaitQueue(numWorkers int, size int) *noWaitQueue {
+	if numWorkers <= 0 {
+		numWorkers = DefaultNoWaitWorkers
+	}
+	if size <= 0 {
+		size = DefaultNoWaitQueueSize
+	}
+	return &noWaitQueue{size: size, workers: make([]chan func(), numWorkers)}
+}
+
+func (q *noWaitQueue) start() {
+	for i := range q.workers {
+		ch := make(chan func(), q.size)
+		q.workers[i] = ch
+		go func() {
+			for fn := range ch {
+				fn()
+			}
+		}()
+	}
+}
+
+// push adds publishing function of channel to queue. Returns false if queue
+// of channel worker is full.
+func (q *noWaitQueue) push(channel string, fn func()) bool {
+	q.once.Do(q.start)
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(channel))
+	select {
+	case q.workers[hash.Sum32()%uint32(len(q.workers))] <- fn:
+		return true
+	default:
+		return false
+	}
+}
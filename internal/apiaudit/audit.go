@@ -0,0 +1,219 @@
+// Package apiaudit keeps records of server API calls for compliance. Every
+// call is described by Record (method, API key ID, hash of params, result code
+// and latency), records are written to Sink asynchronously in batches as JSON
+// lines.
+package apiaudit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/centrifugal/centrifugo/v3/internal/tenant"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// Defaults.
+const (
+	DefaultQueueSize = 8192
+)
+
+// maxBatchSize is a max number of records written to Sink at once.
+const maxBatchSize = 128
+
+// DefaultKeyID is an ID of main API key. Calls made with API key of tenant
+// have tenant name as key ID.
+const DefaultKeyID = "default"
+
+// Record of API call.
+type Record struct {
+	Time time.Time
+	// Protocol of API call: http, grpc or redis.
+	Protocol string
+	// Method name in snake case, e.g. user_connections.
+	Method string
+	// KeyID identifies API key call was made with.
+	KeyID string
+	// Params of call, only SHA-256 hash of params written to audit stream.
+	Params []byte
+	// Code of error returned to caller, zero on success.
+	Code    uint32
+	Latency time.Duration
+}
+
+type jsonRecord struct {
+	Time       string `json:"time"`
+	Protocol   string `json:"protocol"`
+	Method     string `json:"method"`
+	KeyID      string `json:"key_id"`
+	ParamsHash string `json:"params_hash"`
+	Code       uint32 `json:"code"`
+	LatencyUS  int64  `json:"latency_us"`
+}
+
+// Encode record to JSON written to audit stream.
+func (r Record) Encode() ([]byte, error) {
+	hash := sha256.Sum256(r.Params)
+	return json.Marshal(jsonRecord{
+		Time:       r.Time.UTC().Format(time.RFC3339Nano),
+		Protocol:   r.Protocol,
+		Method:     r.Method,
+		KeyID:      r.KeyID,
+		ParamsHash: hex.EncodeToString(hash[:]),
+		Code:       r.Code,
+		LatencyUS:  r.Latency.Microseconds(),
+	})
+}
+
+// KeyIDFromContext returns ID of API key request authorized with.
+func KeyIDFromContext(ctx context.Context) string {
+	if name, ok := tenant.FromContext(ctx); ok && name != "" {
+		return name
+	}
+	return DefaultKeyID
+}
+
+// MethodName converts GRPC method name (Publish, UserConnections, RPC) to
+// snake case name used in HTTP API (publish, user_connections, rpc).
+func MethodName(name string) string {
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// Word starts at upper case letter which follows lower case letter
+			// or precedes lower case letter in acronym.
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Sink writes encoded records to audit stream.
+type Sink interface {
+	Write(entries [][]byte) error
+}
+
+// Config of Log.
+type Config struct {
+	Sink Sink
+	// QueueSize is a max number of records waiting to be written, records are
+	// dropped when queue is full. By default DefaultQueueSize used.
+	QueueSize int
+}
+
+// Log writes records of API calls to Sink.
+type Log struct {
+	node    *centrifuge.Node
+	sink    Sink
+	queue   chan Record
+	closeCh chan struct{}
+	doneCh  chan struct{}
+}
+
+// New creates Log and starts writing records to Sink.
+func New(node *centrifuge.Node, c Config) *Log {
+	if c.QueueSize == 0 {
+		c.QueueSize = DefaultQueueSize
+	}
+	l := &Log{
+		node:    node,
+		sink:    c.Sink,
+		queue:   make(chan Record, c.QueueSize),
+		closeCh: make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// Add queues record to be written. Never blocks, record is dropped if queue
+// is full. Add can be safely called on nil Log.
+func (l *Log) Add(r Record) {
+	if l == nil {
+		return
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	select {
+	case l.queue <- r:
+	default:
+		recordsDropped.Inc()
+	}
+}
+
+// Close writes queued records and stops Log.
+func (l *Log) Close() {
+	close(l.closeCh)
+	<-l.doneCh
+}
+
+func (l *Log) run() {
+	defer close(l.doneCh)
+	batch := make([][]byte, 0, maxBatchSize)
+	for {
+		var r Record
+		select {
+		case r = <-l.queue:
+		case <-l.closeCh:
+			for {
+				select {
+				case r = <-l.queue:
+					batch = l.append(batch, r)
+					if len(batch) == maxBatchSize {
+						batch = l.flush(batch)
+					}
+				default:
+					l.flush(batch)
+					return
+				}
+			}
+		}
+		batch = l.append(batch, r)
+	loop:
+		for len(batch) < maxBatchSize {
+			select {
+			case r = <-l.queue:
+				batch = l.append(batch, r)
+			default:
+				break loop
+			}
+		}
+		batch = l.flush(batch)
+	}
+}
+
+func (l *Log) append(batch [][]byte, r Record) [][]byte {
+	entry, err := r.Encode()
+	if err != nil {
+		recordsDropped.Inc()
+		return batch
+	}
+	return append(batch, entry)
+}
+
+func (l *Log) flush(batch [][]byte) [][]byte {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := l.sink.Write(batch); err != nil {
+		writeErrors.Inc()
+		recordsDropped.Add(float64(len(batch)))
+		l.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error writing API audit records", map[string]interface{}{"error": err.Error(), "num_records": len(batch)}))
+	} else {
+		recordsWritten.Add(float64(len(batch)))
+	}
+	return batch[:0]
+}
@@ -0,0 +1,141 @@
+package apiaudit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/tenant"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+type testSink struct {
+	mu      sync.Mutex
+	entries [][]byte
+	err     error
+}
+
+func (s *testSink) Write(entries [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.entries = append(s.entries, entries...)
+	return nil
+}
+
+func (s *testSink) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+func TestMethodName(t *testing.T) {
+	require.Equal(t, "publish", MethodName("/centrifugal.centrifugo.api.CentrifugoApi/Publish"))
+	require.Equal(t, "user_connections", MethodName("UserConnections"))
+	require.Equal(t, "rpc", MethodName("RPC"))
+	require.Equal(t, "add_redis_shard", MethodName("AddRedisShard"))
+}
+
+func TestKeyIDFromContext(t *testing.T) {
+	require.Equal(t, DefaultKeyID, KeyIDFromContext(context.Background()))
+	require.Equal(t, "app1", KeyIDFromContext(tenant.SetToContext(context.Background(), "app1")))
+}
+
+func TestRecordEncode(t *testing.T) {
+	data, err := Record{
+		Time:     time.Unix(0, 0),
+		Protocol: "http",
+		Method:   "publish",
+		KeyID:    DefaultKeyID,
+		Params:   []byte(`{}`),
+		Code:     102,
+		Latency:  1500 * time.Microsecond,
+	}.Encode()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"time":"1970-01-01T00:00:00Z","protocol":"http","method":"publish","key_id":"default","params_hash":"44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","code":102,"latency_us":1500}`, string(data))
+}
+
+func TestLog(t *testing.T) {
+	node, _ := centrifuge.New(centrifuge.DefaultConfig)
+	sink := &testSink{}
+	l := New(node, Config{Sink: sink})
+	for i := 0; i < 300; i++ {
+		l.Add(Record{Method: "publish"})
+	}
+	l.Close()
+	require.Equal(t, 300, sink.len())
+	var r map[string]interface{}
+	require.NoError(t, json.Unmarshal(sink.entries[0], &r))
+	require.Equal(t, "publish", r["method"])
+
+	var nilLog *Log
+	nilLog.Add(Record{})
+}
+
+func TestLogWriteError(t *testing.T) {
+	node, _ := centrifuge.New(centrifuge.DefaultConfig)
+	sink := &testSink{err: errors.New("boom")}
+	l := New(node, Config{Sink: sink})
+	l.Add(Record{Method: "publish"})
+	l.Close()
+	require.Equal(t, 0, sink.len())
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "audit.log")
+
+	// Unrelated file must not be touched by retention.
+	require.NoError(t, ioutil.WriteFile(path+".bak", nil, 0640))
+
+	s, err := NewFileSink(FileConfig{Path: path, MaxSize: 10, MaxBackups: 2})
+	require.NoError(t, err)
+	defer func() { _ = s.Close() }()
+
+	require.NoError(t, s.Write([][]byte{[]byte(`{"a":1}`)}))
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "{\"a\":1}\n", string(data))
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, s.Write([][]byte{[]byte(`{"b":2}`)}))
+	}
+	matches, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	// Two rotated files and unrelated one.
+	require.Len(t, matches, 3)
+	require.Contains(t, matches, path+".bak")
+}
+
+func TestFileSinkMaxAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "audit.log")
+
+	old := path + "." + time.Now().Add(-2*time.Hour).UTC().Format(rotatedTimeFormat)
+	require.NoError(t, ioutil.WriteFile(old, nil, 0640))
+
+	s, err := NewFileSink(FileConfig{Path: path, MaxSize: 1, MaxAge: time.Hour})
+	require.NoError(t, err)
+	defer func() { _ = s.Close() }()
+	require.NoError(t, s.Write([][]byte{[]byte(`{}`)}))
+
+	_, err = os.Stat(old)
+	require.True(t, os.IsNotExist(err))
+	matches, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+}
@@ -0,0 +1,148 @@
+package apiaudit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultFileMaxSize is a default size of audit file in bytes after which
+// file is rotated.
+const DefaultFileMaxSize = 100 * 1024 * 1024
+
+// rotatedTimeFormat is a format of timestamp suffix of rotated files, sorts
+// lexicographically in time order.
+const rotatedTimeFormat = "20060102T150405.000000000"
+
+// FileConfig of FileSink.
+type FileConfig struct {
+	// Path of audit file.
+	Path string
+	// MaxSize of audit file in bytes. When exceeded, file renamed to
+	// <Path>.<timestamp> and new file created. By default DefaultFileMaxSize.
+	MaxSize int64
+	// MaxAge of rotated files, older files removed on rotation. Zero means
+	// rotated files are not removed by age.
+	MaxAge time.Duration
+	// MaxBackups is a max number of rotated files to keep, the oldest ones
+	// removed on rotation. Zero means no limit.
+	MaxBackups int
+}
+
+// FileSink writes records to file as JSON lines, rotates file by size and
+// removes rotated files according to retention policy.
+type FileSink struct {
+	config FileConfig
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+}
+
+// NewFileSink opens audit file for appending.
+func NewFileSink(c FileConfig) (*FileSink, error) {
+	if c.Path == "" {
+		return nil, errors.New("audit file path required")
+	}
+	if c.MaxSize == 0 {
+		c.MaxSize = DefaultFileMaxSize
+	}
+	s := &FileSink{config: c}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+// Write entries to file, one per line.
+func (s *FileSink) Write(entries [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var buf []byte
+	for _, e := range entries {
+		buf = append(buf, e...)
+		buf = append(buf, '\n')
+	}
+	n, err := s.file.Write(buf)
+	s.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if s.size >= s.config.MaxSize {
+		return s.rotate(time.Now())
+	}
+	return nil
+}
+
+func (s *FileSink) rotate(now time.Time) error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(s.config.Path, s.config.Path+"."+now.UTC().Format(rotatedTimeFormat)); err != nil {
+		return err
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+	return s.removeExpired(now)
+}
+
+// removeExpired removes rotated files which are out of retention policy.
+func (s *FileSink) removeExpired(now time.Time) error {
+	if s.config.MaxAge == 0 && s.config.MaxBackups == 0 {
+		return nil
+	}
+	matches, err := filepath.Glob(s.config.Path + ".*")
+	if err != nil {
+		return err
+	}
+	var rotated []string
+	times := map[string]time.Time{}
+	for _, path := range matches {
+		t, err := time.Parse(rotatedTimeFormat, path[len(s.config.Path)+1:])
+		if err != nil {
+			// Not a file rotated by sink.
+			continue
+		}
+		rotated = append(rotated, path)
+		times[path] = t
+	}
+	// Newest first.
+	sort.Sort(sort.Reverse(sort.StringSlice(rotated)))
+	for i, path := range rotated {
+		remove := s.config.MaxBackups > 0 && i >= s.config.MaxBackups
+		if s.config.MaxAge > 0 && now.Sub(times[path]) > s.config.MaxAge {
+			remove = true
+		}
+		if remove {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close audit file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
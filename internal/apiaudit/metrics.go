@@ -0,0 +1,34 @@
+package apiaudit
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricsNamespace = "centrifugo"
+
+var (
+	recordsWritten = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "api_audit",
+		Name:      "records_written",
+		Help:      "Number of API audit records written to audit stream.",
+	})
+	recordsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "api_audit",
+		Name:      "records_dropped",
+		Help:      "Number of API audit records dropped due to full queue or write errors.",
+	})
+	writeErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "api_audit",
+		Name:      "write_errors",
+		Help:      "Number of errors writing API audit records to audit stream.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(recordsWritten)
+	prometheus.MustRegister(recordsDropped)
+	prometheus.MustRegister(writeErrors)
+}
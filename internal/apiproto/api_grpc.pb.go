@@ -39,6 +39,27 @@ type CentrifugoApiClient interface {
 	UnblockUser(ctx context.Context, in *UnblockUserRequest, opts ...grpc.CallOption) (*UnblockUserResponse, error)
 	RevokeToken(ctx context.Context, in *RevokeTokenRequest, opts ...grpc.CallOption) (*RevokeTokenResponse, error)
 	InvalidateUserTokens(ctx context.Context, in *InvalidateUserTokensRequest, opts ...grpc.CallOption) (*InvalidateUserTokensResponse, error)
+	ReloadConfig(ctx context.Context, in *ReloadConfigRequest, opts ...grpc.CallOption) (*ReloadConfigResponse, error)
+	SetChannelOptions(ctx context.Context, in *SetChannelOptionsRequest, opts ...grpc.CallOption) (*SetChannelOptionsResponse, error)
+	ChannelSubscribers(ctx context.Context, in *ChannelSubscribersRequest, opts ...grpc.CallOption) (*ChannelSubscribersResponse, error)
+	AcquireLock(ctx context.Context, in *AcquireLockRequest, opts ...grpc.CallOption) (*AcquireLockResponse, error)
+	ReleaseLock(ctx context.Context, in *ReleaseLockRequest, opts ...grpc.CallOption) (*ReleaseLockResponse, error)
+	ConnectionEvents(ctx context.Context, in *ConnectionEventsRequest, opts ...grpc.CallOption) (*ConnectionEventsResponse, error)
+	SetChannelMeta(ctx context.Context, in *SetChannelMetaRequest, opts ...grpc.CallOption) (*SetChannelMetaResponse, error)
+	GetChannelMeta(ctx context.Context, in *GetChannelMetaRequest, opts ...grpc.CallOption) (*GetChannelMetaResponse, error)
+	DeleteChannelMeta(ctx context.Context, in *DeleteChannelMetaRequest, opts ...grpc.CallOption) (*DeleteChannelMetaResponse, error)
+	AddRedisShard(ctx context.Context, in *AddRedisShardRequest, opts ...grpc.CallOption) (*AddRedisShardResponse, error)
+	HistoryMeta(ctx context.Context, in *HistoryMetaRequest, opts ...grpc.CallOption) (*HistoryMetaResponse, error)
+	MigrateConnections(ctx context.Context, in *MigrateConnectionsRequest, opts ...grpc.CallOption) (*MigrateConnectionsResponse, error)
+	SetReadPosition(ctx context.Context, in *SetReadPositionRequest, opts ...grpc.CallOption) (*SetReadPositionResponse, error)
+	ReadPositions(ctx context.Context, in *ReadPositionsRequest, opts ...grpc.CallOption) (*ReadPositionsResponse, error)
+	SetChannelGroup(ctx context.Context, in *SetChannelGroupRequest, opts ...grpc.CallOption) (*SetChannelGroupResponse, error)
+	RemoveChannelGroup(ctx context.Context, in *RemoveChannelGroupRequest, opts ...grpc.CallOption) (*RemoveChannelGroupResponse, error)
+	ChannelGroup(ctx context.Context, in *ChannelGroupRequest, opts ...grpc.CallOption) (*ChannelGroupResponse, error)
+	ChannelsCount(ctx context.Context, in *ChannelsCountRequest, opts ...grpc.CallOption) (*ChannelsCountResponse, error)
+	UnsubscribeChannel(ctx context.Context, in *UnsubscribeChannelRequest, opts ...grpc.CallOption) (*UnsubscribeChannelResponse, error)
+	RegisterPushDevice(ctx context.Context, in *RegisterPushDeviceRequest, opts ...grpc.CallOption) (*RegisterPushDeviceResponse, error)
+	UnregisterPushDevice(ctx context.Context, in *UnregisterPushDeviceRequest, opts ...grpc.CallOption) (*UnregisterPushDeviceResponse, error)
 }
 
 type centrifugoApiClient struct {
@@ -238,6 +259,195 @@ func (c *centrifugoApiClient) InvalidateUserTokens(ctx context.Context, in *Inva
 	return out, nil
 }
 
+func (c *centrifugoApiClient) ReloadConfig(ctx context.Context, in *ReloadConfigRequest, opts ...grpc.CallOption) (*ReloadConfigResponse, error) {
+	out := new(ReloadConfigResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/ReloadConfig", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) SetChannelOptions(ctx context.Context, in *SetChannelOptionsRequest, opts ...grpc.CallOption) (*SetChannelOptionsResponse, error) {
+	out := new(SetChannelOptionsResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/SetChannelOptions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) ChannelSubscribers(ctx context.Context, in *ChannelSubscribersRequest, opts ...grpc.CallOption) (*ChannelSubscribersResponse, error) {
+	out := new(ChannelSubscribersResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/ChannelSubscribers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) AcquireLock(ctx context.Context, in *AcquireLockRequest, opts ...grpc.CallOption) (*AcquireLockResponse, error) {
+	out := new(AcquireLockResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/AcquireLock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) ReleaseLock(ctx context.Context, in *ReleaseLockRequest, opts ...grpc.CallOption) (*ReleaseLockResponse, error) {
+	out := new(ReleaseLockResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/ReleaseLock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) ConnectionEvents(ctx context.Context, in *ConnectionEventsRequest, opts ...grpc.CallOption) (*ConnectionEventsResponse, error) {
+	out := new(ConnectionEventsResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/ConnectionEvents", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) SetChannelMeta(ctx context.Context, in *SetChannelMetaRequest, opts ...grpc.CallOption) (*SetChannelMetaResponse, error) {
+	out := new(SetChannelMetaResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/SetChannelMeta", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) GetChannelMeta(ctx context.Context, in *GetChannelMetaRequest, opts ...grpc.CallOption) (*GetChannelMetaResponse, error) {
+	out := new(GetChannelMetaResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/GetChannelMeta", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) DeleteChannelMeta(ctx context.Context, in *DeleteChannelMetaRequest, opts ...grpc.CallOption) (*DeleteChannelMetaResponse, error) {
+	out := new(DeleteChannelMetaResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/DeleteChannelMeta", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) AddRedisShard(ctx context.Context, in *AddRedisShardRequest, opts ...grpc.CallOption) (*AddRedisShardResponse, error) {
+	out := new(AddRedisShardResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/AddRedisShard", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) HistoryMeta(ctx context.Context, in *HistoryMetaRequest, opts ...grpc.CallOption) (*HistoryMetaResponse, error) {
+	out := new(HistoryMetaResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/HistoryMeta", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) MigrateConnections(ctx context.Context, in *MigrateConnectionsRequest, opts ...grpc.CallOption) (*MigrateConnectionsResponse, error) {
+	out := new(MigrateConnectionsResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/MigrateConnections", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) SetReadPosition(ctx context.Context, in *SetReadPositionRequest, opts ...grpc.CallOption) (*SetReadPositionResponse, error) {
+	out := new(SetReadPositionResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/SetReadPosition", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) ReadPositions(ctx context.Context, in *ReadPositionsRequest, opts ...grpc.CallOption) (*ReadPositionsResponse, error) {
+	out := new(ReadPositionsResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/ReadPositions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) SetChannelGroup(ctx context.Context, in *SetChannelGroupRequest, opts ...grpc.CallOption) (*SetChannelGroupResponse, error) {
+	out := new(SetChannelGroupResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/SetChannelGroup", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) RemoveChannelGroup(ctx context.Context, in *RemoveChannelGroupRequest, opts ...grpc.CallOption) (*RemoveChannelGroupResponse, error) {
+	out := new(RemoveChannelGroupResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/RemoveChannelGroup", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) ChannelGroup(ctx context.Context, in *ChannelGroupRequest, opts ...grpc.CallOption) (*ChannelGroupResponse, error) {
+	out := new(ChannelGroupResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/ChannelGroup", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) ChannelsCount(ctx context.Context, in *ChannelsCountRequest, opts ...grpc.CallOption) (*ChannelsCountResponse, error) {
+	out := new(ChannelsCountResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/ChannelsCount", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) UnsubscribeChannel(ctx context.Context, in *UnsubscribeChannelRequest, opts ...grpc.CallOption) (*UnsubscribeChannelResponse, error) {
+	out := new(UnsubscribeChannelResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/UnsubscribeChannel", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) RegisterPushDevice(ctx context.Context, in *RegisterPushDeviceRequest, opts ...grpc.CallOption) (*RegisterPushDeviceResponse, error) {
+	out := new(RegisterPushDeviceResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/RegisterPushDevice", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *centrifugoApiClient) UnregisterPushDevice(ctx context.Context, in *UnregisterPushDeviceRequest, opts ...grpc.CallOption) (*UnregisterPushDeviceResponse, error) {
+	out := new(UnregisterPushDeviceResponse)
+	err := c.cc.Invoke(ctx, "/centrifugal.centrifugo.api.CentrifugoApi/UnregisterPushDevice", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CentrifugoApiServer is the server API for CentrifugoApi service.
 // All implementations must embed UnimplementedCentrifugoApiServer
 // for forward compatibility
@@ -263,6 +473,27 @@ type CentrifugoApiServer interface {
 	UnblockUser(context.Context, *UnblockUserRequest) (*UnblockUserResponse, error)
 	RevokeToken(context.Context, *RevokeTokenRequest) (*RevokeTokenResponse, error)
 	InvalidateUserTokens(context.Context, *InvalidateUserTokensRequest) (*InvalidateUserTokensResponse, error)
+	ReloadConfig(context.Context, *ReloadConfigRequest) (*ReloadConfigResponse, error)
+	SetChannelOptions(context.Context, *SetChannelOptionsRequest) (*SetChannelOptionsResponse, error)
+	ChannelSubscribers(context.Context, *ChannelSubscribersRequest) (*ChannelSubscribersResponse, error)
+	AcquireLock(context.Context, *AcquireLockRequest) (*AcquireLockResponse, error)
+	ReleaseLock(context.Context, *ReleaseLockRequest) (*ReleaseLockResponse, error)
+	ConnectionEvents(context.Context, *ConnectionEventsRequest) (*ConnectionEventsResponse, error)
+	SetChannelMeta(context.Context, *SetChannelMetaRequest) (*SetChannelMetaResponse, error)
+	GetChannelMeta(context.Context, *GetChannelMetaRequest) (*GetChannelMetaResponse, error)
+	DeleteChannelMeta(context.Context, *DeleteChannelMetaRequest) (*DeleteChannelMetaResponse, error)
+	AddRedisShard(context.Context, *AddRedisShardRequest) (*AddRedisShardResponse, error)
+	HistoryMeta(context.Context, *HistoryMetaRequest) (*HistoryMetaResponse, error)
+	MigrateConnections(context.Context, *MigrateConnectionsRequest) (*MigrateConnectionsResponse, error)
+	SetReadPosition(context.Context, *SetReadPositionRequest) (*SetReadPositionResponse, error)
+	ReadPositions(context.Context, *ReadPositionsRequest) (*ReadPositionsResponse, error)
+	SetChannelGroup(context.Context, *SetChannelGroupRequest) (*SetChannelGroupResponse, error)
+	RemoveChannelGroup(context.Context, *RemoveChannelGroupRequest) (*RemoveChannelGroupResponse, error)
+	ChannelGroup(context.Context, *ChannelGroupRequest) (*ChannelGroupResponse, error)
+	ChannelsCount(context.Context, *ChannelsCountRequest) (*ChannelsCountResponse, error)
+	UnsubscribeChannel(context.Context, *UnsubscribeChannelRequest) (*UnsubscribeChannelResponse, error)
+	RegisterPushDevice(context.Context, *RegisterPushDeviceRequest) (*RegisterPushDeviceResponse, error)
+	UnregisterPushDevice(context.Context, *UnregisterPushDeviceRequest) (*UnregisterPushDeviceResponse, error)
 	mustEmbedUnimplementedCentrifugoApiServer()
 }
 
@@ -333,6 +564,69 @@ func (UnimplementedCentrifugoApiServer) RevokeToken(context.Context, *RevokeToke
 func (UnimplementedCentrifugoApiServer) InvalidateUserTokens(context.Context, *InvalidateUserTokensRequest) (*InvalidateUserTokensResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method InvalidateUserTokens not implemented")
 }
+func (UnimplementedCentrifugoApiServer) ReloadConfig(context.Context, *ReloadConfigRequest) (*ReloadConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReloadConfig not implemented")
+}
+func (UnimplementedCentrifugoApiServer) SetChannelOptions(context.Context, *SetChannelOptionsRequest) (*SetChannelOptionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetChannelOptions not implemented")
+}
+func (UnimplementedCentrifugoApiServer) ChannelSubscribers(context.Context, *ChannelSubscribersRequest) (*ChannelSubscribersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChannelSubscribers not implemented")
+}
+func (UnimplementedCentrifugoApiServer) AcquireLock(context.Context, *AcquireLockRequest) (*AcquireLockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcquireLock not implemented")
+}
+func (UnimplementedCentrifugoApiServer) ReleaseLock(context.Context, *ReleaseLockRequest) (*ReleaseLockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReleaseLock not implemented")
+}
+func (UnimplementedCentrifugoApiServer) ConnectionEvents(context.Context, *ConnectionEventsRequest) (*ConnectionEventsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConnectionEvents not implemented")
+}
+func (UnimplementedCentrifugoApiServer) SetChannelMeta(context.Context, *SetChannelMetaRequest) (*SetChannelMetaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetChannelMeta not implemented")
+}
+func (UnimplementedCentrifugoApiServer) GetChannelMeta(context.Context, *GetChannelMetaRequest) (*GetChannelMetaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetChannelMeta not implemented")
+}
+func (UnimplementedCentrifugoApiServer) DeleteChannelMeta(context.Context, *DeleteChannelMetaRequest) (*DeleteChannelMetaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteChannelMeta not implemented")
+}
+func (UnimplementedCentrifugoApiServer) AddRedisShard(context.Context, *AddRedisShardRequest) (*AddRedisShardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddRedisShard not implemented")
+}
+func (UnimplementedCentrifugoApiServer) HistoryMeta(context.Context, *HistoryMetaRequest) (*HistoryMetaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HistoryMeta not implemented")
+}
+func (UnimplementedCentrifugoApiServer) MigrateConnections(context.Context, *MigrateConnectionsRequest) (*MigrateConnectionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MigrateConnections not implemented")
+}
+func (UnimplementedCentrifugoApiServer) SetReadPosition(context.Context, *SetReadPositionRequest) (*SetReadPositionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetReadPosition not implemented")
+}
+func (UnimplementedCentrifugoApiServer) ReadPositions(context.Context, *ReadPositionsRequest) (*ReadPositionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadPositions not implemented")
+}
+func (UnimplementedCentrifugoApiServer) SetChannelGroup(context.Context, *SetChannelGroupRequest) (*SetChannelGroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetChannelGroup not implemented")
+}
+func (UnimplementedCentrifugoApiServer) RemoveChannelGroup(context.Context, *RemoveChannelGroupRequest) (*RemoveChannelGroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveChannelGroup not implemented")
+}
+func (UnimplementedCentrifugoApiServer) ChannelGroup(context.Context, *ChannelGroupRequest) (*ChannelGroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChannelGroup not implemented")
+}
+func (UnimplementedCentrifugoApiServer) ChannelsCount(context.Context, *ChannelsCountRequest) (*ChannelsCountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChannelsCount not implemented")
+}
+func (UnimplementedCentrifugoApiServer) UnsubscribeChannel(context.Context, *UnsubscribeChannelRequest) (*UnsubscribeChannelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnsubscribeChannel not implemented")
+}
+func (UnimplementedCentrifugoApiServer) RegisterPushDevice(context.Context, *RegisterPushDeviceRequest) (*RegisterPushDeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterPushDevice not implemented")
+}
+func (UnimplementedCentrifugoApiServer) UnregisterPushDevice(context.Context, *UnregisterPushDeviceRequest) (*UnregisterPushDeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnregisterPushDevice not implemented")
+}
 func (UnimplementedCentrifugoApiServer) mustEmbedUnimplementedCentrifugoApiServer() {}
 
 // UnsafeCentrifugoApiServer may be embedded to opt out of forward compatibility for this service.
@@ -724,6 +1018,384 @@ func _CentrifugoApi_InvalidateUserTokens_Handler(srv interface{}, ctx context.Co
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CentrifugoApi_ReloadConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).ReloadConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/ReloadConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).ReloadConfig(ctx, req.(*ReloadConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_SetChannelOptions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetChannelOptionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).SetChannelOptions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/SetChannelOptions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).SetChannelOptions(ctx, req.(*SetChannelOptionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_ChannelSubscribers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChannelSubscribersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).ChannelSubscribers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/ChannelSubscribers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).ChannelSubscribers(ctx, req.(*ChannelSubscribersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_AcquireLock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AcquireLockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).AcquireLock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/AcquireLock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).AcquireLock(ctx, req.(*AcquireLockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_ReleaseLock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReleaseLockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).ReleaseLock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/ReleaseLock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).ReleaseLock(ctx, req.(*ReleaseLockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_ConnectionEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConnectionEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).ConnectionEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/ConnectionEvents",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).ConnectionEvents(ctx, req.(*ConnectionEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_SetChannelMeta_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetChannelMetaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).SetChannelMeta(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/SetChannelMeta",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).SetChannelMeta(ctx, req.(*SetChannelMetaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_GetChannelMeta_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetChannelMetaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).GetChannelMeta(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/GetChannelMeta",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).GetChannelMeta(ctx, req.(*GetChannelMetaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_DeleteChannelMeta_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteChannelMetaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).DeleteChannelMeta(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/DeleteChannelMeta",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).DeleteChannelMeta(ctx, req.(*DeleteChannelMetaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_AddRedisShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddRedisShardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).AddRedisShard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/AddRedisShard",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).AddRedisShard(ctx, req.(*AddRedisShardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_HistoryMeta_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryMetaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).HistoryMeta(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/HistoryMeta",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).HistoryMeta(ctx, req.(*HistoryMetaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_MigrateConnections_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MigrateConnectionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).MigrateConnections(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/MigrateConnections",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).MigrateConnections(ctx, req.(*MigrateConnectionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_SetReadPosition_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetReadPositionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).SetReadPosition(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/SetReadPosition",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).SetReadPosition(ctx, req.(*SetReadPositionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_ReadPositions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadPositionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).ReadPositions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/ReadPositions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).ReadPositions(ctx, req.(*ReadPositionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_SetChannelGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetChannelGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).SetChannelGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/SetChannelGroup",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).SetChannelGroup(ctx, req.(*SetChannelGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_RemoveChannelGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveChannelGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).RemoveChannelGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/RemoveChannelGroup",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).RemoveChannelGroup(ctx, req.(*RemoveChannelGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_ChannelGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChannelGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).ChannelGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/ChannelGroup",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).ChannelGroup(ctx, req.(*ChannelGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_ChannelsCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChannelsCountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).ChannelsCount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/ChannelsCount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).ChannelsCount(ctx, req.(*ChannelsCountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_UnsubscribeChannel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnsubscribeChannelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).UnsubscribeChannel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/UnsubscribeChannel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).UnsubscribeChannel(ctx, req.(*UnsubscribeChannelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_RegisterPushDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterPushDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).RegisterPushDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/RegisterPushDevice",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).RegisterPushDevice(ctx, req.(*RegisterPushDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CentrifugoApi_UnregisterPushDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnregisterPushDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CentrifugoApiServer).UnregisterPushDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/centrifugal.centrifugo.api.CentrifugoApi/UnregisterPushDevice",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CentrifugoApiServer).UnregisterPushDevice(ctx, req.(*UnregisterPushDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // CentrifugoApi_ServiceDesc is the grpc.ServiceDesc for CentrifugoApi service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -815,6 +1487,90 @@ var CentrifugoApi_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "InvalidateUserTokens",
 			Handler:    _CentrifugoApi_InvalidateUserTokens_Handler,
 		},
+		{
+			MethodName: "ReloadConfig",
+			Handler:    _CentrifugoApi_ReloadConfig_Handler,
+		},
+		{
+			MethodName: "SetChannelOptions",
+			Handler:    _CentrifugoApi_SetChannelOptions_Handler,
+		},
+		{
+			MethodName: "ChannelSubscribers",
+			Handler:    _CentrifugoApi_ChannelSubscribers_Handler,
+		},
+		{
+			MethodName: "AcquireLock",
+			Handler:    _CentrifugoApi_AcquireLock_Handler,
+		},
+		{
+			MethodName: "ReleaseLock",
+			Handler:    _CentrifugoApi_ReleaseLock_Handler,
+		},
+		{
+			MethodName: "ConnectionEvents",
+			Handler:    _CentrifugoApi_ConnectionEvents_Handler,
+		},
+		{
+			MethodName: "SetChannelMeta",
+			Handler:    _CentrifugoApi_SetChannelMeta_Handler,
+		},
+		{
+			MethodName: "GetChannelMeta",
+			Handler:    _CentrifugoApi_GetChannelMeta_Handler,
+		},
+		{
+			MethodName: "DeleteChannelMeta",
+			Handler:    _CentrifugoApi_DeleteChannelMeta_Handler,
+		},
+		{
+			MethodName: "AddRedisShard",
+			Handler:    _CentrifugoApi_AddRedisShard_Handler,
+		},
+		{
+			MethodName: "HistoryMeta",
+			Handler:    _CentrifugoApi_HistoryMeta_Handler,
+		},
+		{
+			MethodName: "MigrateConnections",
+			Handler:    _CentrifugoApi_MigrateConnections_Handler,
+		},
+		{
+			MethodName: "SetReadPosition",
+			Handler:    _CentrifugoApi_SetReadPosition_Handler,
+		},
+		{
+			MethodName: "ReadPositions",
+			Handler:    _CentrifugoApi_ReadPositions_Handler,
+		},
+		{
+			MethodName: "SetChannelGroup",
+			Handler:    _CentrifugoApi_SetChannelGroup_Handler,
+		},
+		{
+			MethodName: "RemoveChannelGroup",
+			Handler:    _CentrifugoApi_RemoveChannelGroup_Handler,
+		},
+		{
+			MethodName: "ChannelGroup",
+			Handler:    _CentrifugoApi_ChannelGroup_Handler,
+		},
+		{
+			MethodName: "ChannelsCount",
+			Handler:    _CentrifugoApi_ChannelsCount_Handler,
+		},
+		{
+			MethodName: "UnsubscribeChannel",
+			Handler:    _CentrifugoApi_UnsubscribeChannel_Handler,
+		},
+		{
+			MethodName: "RegisterPushDevice",
+			Handler:    _CentrifugoApi_RegisterPushDevice_Handler,
+		},
+		{
+			MethodName: "UnregisterPushDevice",
+			Handler:    _CentrifugoApi_UnregisterPushDevice_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "api.proto",
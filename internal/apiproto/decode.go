@@ -3,6 +3,7 @@ package apiproto
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"strconv"
 	"strings"
 )
@@ -31,25 +32,51 @@ type CommandDecoder interface {
 
 var _ CommandDecoder = (*JSONCommandDecoder)(nil)
 
-// JSONCommandDecoder ...
+// IsCommandArray returns true if data contains commands encoded as JSON array,
+// otherwise commands expected to be newline-delimited JSON objects.
+func IsCommandArray(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && data[0] == '['
+}
+
+// JSONCommandDecoder decodes newline-delimited commands or JSON array of commands.
 type JSONCommandDecoder struct {
 	decoder *json.Decoder
+	array   bool
+	started bool
 }
 
 // NewJSONCommandDecoder ...
 func NewJSONCommandDecoder(data []byte) *JSONCommandDecoder {
 	return &JSONCommandDecoder{
 		decoder: json.NewDecoder(bytes.NewReader(data)),
+		array:   IsCommandArray(data),
 	}
 }
 
 // Reset ...
 func (d *JSONCommandDecoder) Reset(data []byte) {
 	d.decoder = json.NewDecoder(bytes.NewReader(data))
+	d.array = IsCommandArray(data)
+	d.started = false
 }
 
 // Decode ...
 func (d *JSONCommandDecoder) Decode() (*Command, error) {
+	if d.array {
+		if !d.started {
+			d.started = true
+			if _, err := d.decoder.Token(); err != nil {
+				return nil, err
+			}
+		}
+		if !d.decoder.More() {
+			if _, err := d.decoder.Token(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+	}
 	var c Command
 	err := d.decoder.Decode(&c)
 	if err != nil {
@@ -73,6 +100,28 @@ type ParamsDecoder interface {
 	DecodeRPC([]byte) (*RPCRequest, error)
 	DecodeRefresh([]byte) (*RefreshRequest, error)
 	DecodeChannels([]byte) (*ChannelsRequest, error)
+	DecodeReloadConfig([]byte) (*ReloadConfigRequest, error)
+	DecodeSetChannelOptions([]byte) (*SetChannelOptionsRequest, error)
+	DecodeUserConnections([]byte) (*UserConnectionsRequest, error)
+	DecodeChannelSubscribers([]byte) (*ChannelSubscribersRequest, error)
+	DecodeAcquireLock([]byte) (*AcquireLockRequest, error)
+	DecodeReleaseLock([]byte) (*ReleaseLockRequest, error)
+	DecodeConnectionEvents([]byte) (*ConnectionEventsRequest, error)
+	DecodeSetChannelMeta([]byte) (*SetChannelMetaRequest, error)
+	DecodeGetChannelMeta([]byte) (*GetChannelMetaRequest, error)
+	DecodeDeleteChannelMeta([]byte) (*DeleteChannelMetaRequest, error)
+	DecodeAddRedisShard([]byte) (*AddRedisShardRequest, error)
+	DecodeHistoryMeta([]byte) (*HistoryMetaRequest, error)
+	DecodeMigrateConnections([]byte) (*MigrateConnectionsRequest, error)
+	DecodeSetReadPosition([]byte) (*SetReadPositionRequest, error)
+	DecodeReadPositions([]byte) (*ReadPositionsRequest, error)
+	DecodeSetChannelGroup([]byte) (*SetChannelGroupRequest, error)
+	DecodeRemoveChannelGroup([]byte) (*RemoveChannelGroupRequest, error)
+	DecodeChannelGroup([]byte) (*ChannelGroupRequest, error)
+	DecodeChannelsCount([]byte) (*ChannelsCountRequest, error)
+	DecodeUnsubscribeChannel([]byte) (*UnsubscribeChannelRequest, error)
+	DecodeRegisterPushDevice([]byte) (*RegisterPushDeviceRequest, error)
+	DecodeUnregisterPushDevice([]byte) (*UnregisterPushDeviceRequest, error)
 }
 
 var _ ParamsDecoder = (*JSONParamsDecoder)(nil)
@@ -215,3 +264,223 @@ func (d *JSONParamsDecoder) DecodeChannels(data []byte) (*ChannelsRequest, error
 	}
 	return &p, nil
 }
+
+// DecodeReloadConfig ...
+func (d *JSONParamsDecoder) DecodeReloadConfig(data []byte) (*ReloadConfigRequest, error) {
+	var p ReloadConfigRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeSetChannelOptions ...
+func (d *JSONParamsDecoder) DecodeSetChannelOptions(data []byte) (*SetChannelOptionsRequest, error) {
+	var p SetChannelOptionsRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeUserConnections ...
+func (d *JSONParamsDecoder) DecodeUserConnections(data []byte) (*UserConnectionsRequest, error) {
+	var p UserConnectionsRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeChannelSubscribers ...
+func (d *JSONParamsDecoder) DecodeChannelSubscribers(data []byte) (*ChannelSubscribersRequest, error) {
+	var p ChannelSubscribersRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeAcquireLock ...
+func (d *JSONParamsDecoder) DecodeAcquireLock(data []byte) (*AcquireLockRequest, error) {
+	var p AcquireLockRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeReleaseLock ...
+func (d *JSONParamsDecoder) DecodeReleaseLock(data []byte) (*ReleaseLockRequest, error) {
+	var p ReleaseLockRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeConnectionEvents ...
+func (d *JSONParamsDecoder) DecodeConnectionEvents(data []byte) (*ConnectionEventsRequest, error) {
+	var p ConnectionEventsRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeSetChannelMeta ...
+func (d *JSONParamsDecoder) DecodeSetChannelMeta(data []byte) (*SetChannelMetaRequest, error) {
+	var p SetChannelMetaRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeGetChannelMeta ...
+func (d *JSONParamsDecoder) DecodeGetChannelMeta(data []byte) (*GetChannelMetaRequest, error) {
+	var p GetChannelMetaRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeDeleteChannelMeta ...
+func (d *JSONParamsDecoder) DecodeDeleteChannelMeta(data []byte) (*DeleteChannelMetaRequest, error) {
+	var p DeleteChannelMetaRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeAddRedisShard ...
+func (d *JSONParamsDecoder) DecodeAddRedisShard(data []byte) (*AddRedisShardRequest, error) {
+	var p AddRedisShardRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeHistoryMeta ...
+func (d *JSONParamsDecoder) DecodeHistoryMeta(data []byte) (*HistoryMetaRequest, error) {
+	var p HistoryMetaRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeMigrateConnections ...
+func (d *JSONParamsDecoder) DecodeMigrateConnections(data []byte) (*MigrateConnectionsRequest, error) {
+	var p MigrateConnectionsRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeSetReadPosition ...
+func (d *JSONParamsDecoder) DecodeSetReadPosition(data []byte) (*SetReadPositionRequest, error) {
+	var p SetReadPositionRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeReadPositions ...
+func (d *JSONParamsDecoder) DecodeReadPositions(data []byte) (*ReadPositionsRequest, error) {
+	var p ReadPositionsRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeSetChannelGroup ...
+func (d *JSONParamsDecoder) DecodeSetChannelGroup(data []byte) (*SetChannelGroupRequest, error) {
+	var p SetChannelGroupRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeRemoveChannelGroup ...
+func (d *JSONParamsDecoder) DecodeRemoveChannelGroup(data []byte) (*RemoveChannelGroupRequest, error) {
+	var p RemoveChannelGroupRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeChannelGroup ...
+func (d *JSONParamsDecoder) DecodeChannelGroup(data []byte) (*ChannelGroupRequest, error) {
+	var p ChannelGroupRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeChannelsCount ...
+func (d *JSONParamsDecoder) DecodeChannelsCount(data []byte) (*ChannelsCountRequest, error) {
+	var p ChannelsCountRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeUnsubscribeChannel ...
+func (d *JSONParamsDecoder) DecodeUnsubscribeChannel(data []byte) (*UnsubscribeChannelRequest, error) {
+	var p UnsubscribeChannelRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeRegisterPushDevice ...
+func (d *JSONParamsDecoder) DecodeRegisterPushDevice(data []byte) (*RegisterPushDeviceRequest, error) {
+	var p RegisterPushDeviceRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DecodeUnregisterPushDevice ...
+func (d *JSONParamsDecoder) DecodeUnregisterPushDevice(data []byte) (*UnregisterPushDeviceRequest, error) {
+	var p UnregisterPushDeviceRequest
+	err := json.Unmarshal(data, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
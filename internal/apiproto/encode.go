@@ -14,9 +14,11 @@ type ReplyEncoder interface {
 
 var _ ReplyEncoder = (*JSONReplyEncoder)(nil)
 
-// JSONReplyEncoder ...
+// JSONReplyEncoder encodes replies as newline-delimited JSON objects or as
+// JSON array when in array mode.
 type JSONReplyEncoder struct {
 	count  int
+	array  bool
 	buffer bytes.Buffer
 }
 
@@ -28,13 +30,18 @@ func NewJSONReplyEncoder() *JSONReplyEncoder {
 // Reset ...
 func (e *JSONReplyEncoder) Reset() {
 	e.count = 0
+	e.array = false
 	e.buffer.Reset()
 }
 
 // Encode ...
 func (e *JSONReplyEncoder) Encode(r *Reply) error {
 	if e.count > 0 {
-		e.buffer.WriteString("\n")
+		if e.array {
+			e.buffer.WriteString(",")
+		} else {
+			e.buffer.WriteString("\n")
+		}
 	}
 	data, err := json.Marshal(r)
 	if err != nil {
@@ -48,6 +55,13 @@ func (e *JSONReplyEncoder) Encode(r *Reply) error {
 // Finish ...
 func (e *JSONReplyEncoder) Finish() []byte {
 	data := e.buffer.Bytes()
+	if e.array {
+		dataCopy := make([]byte, len(data)+2)
+		dataCopy[0] = '['
+		copy(dataCopy[1:], data)
+		dataCopy[len(dataCopy)-1] = ']'
+		return dataCopy
+	}
 	dataCopy := make([]byte, len(data))
 	copy(dataCopy, data)
 	return dataCopy
@@ -68,6 +82,28 @@ type ResultEncoder interface {
 	EncodeRPC(*RPCResult) ([]byte, error)
 	EncodeRefresh(*RefreshResult) ([]byte, error)
 	EncodeChannels(*ChannelsResult) ([]byte, error)
+	EncodeReloadConfig(*ReloadConfigResult) ([]byte, error)
+	EncodeSetChannelOptions(*SetChannelOptionsResult) ([]byte, error)
+	EncodeUserConnections(*UserConnectionsResult) ([]byte, error)
+	EncodeChannelSubscribers(*ChannelSubscribersResult) ([]byte, error)
+	EncodeAcquireLock(*AcquireLockResult) ([]byte, error)
+	EncodeReleaseLock(*ReleaseLockResult) ([]byte, error)
+	EncodeConnectionEvents(*ConnectionEventsResult) ([]byte, error)
+	EncodeSetChannelMeta(*SetChannelMetaResult) ([]byte, error)
+	EncodeGetChannelMeta(*GetChannelMetaResult) ([]byte, error)
+	EncodeDeleteChannelMeta(*DeleteChannelMetaResult) ([]byte, error)
+	EncodeAddRedisShard(*AddRedisShardResult) ([]byte, error)
+	EncodeHistoryMeta(*HistoryMetaResult) ([]byte, error)
+	EncodeMigrateConnections(*MigrateConnectionsResult) ([]byte, error)
+	EncodeSetReadPosition(*SetReadPositionResult) ([]byte, error)
+	EncodeReadPositions(*ReadPositionsResult) ([]byte, error)
+	EncodeSetChannelGroup(*SetChannelGroupResult) ([]byte, error)
+	EncodeRemoveChannelGroup(*RemoveChannelGroupResult) ([]byte, error)
+	EncodeChannelGroup(*ChannelGroupResult) ([]byte, error)
+	EncodeChannelsCount(*ChannelsCountResult) ([]byte, error)
+	EncodeUnsubscribeChannel(*UnsubscribeChannelResult) ([]byte, error)
+	EncodeRegisterPushDevice(*RegisterPushDeviceResult) ([]byte, error)
+	EncodeUnregisterPushDevice(*UnregisterPushDeviceResult) ([]byte, error)
 }
 
 var _ ResultEncoder = (*JSONResultEncoder)(nil)
@@ -150,3 +186,135 @@ func (e *JSONResultEncoder) EncodeChannels(res *ChannelsResult) ([]byte, error)
 	//nolint:staticcheck
 	return json.Marshal(res)
 }
+
+// EncodeReloadConfig ...
+func (e *JSONResultEncoder) EncodeReloadConfig(res *ReloadConfigResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeSetChannelOptions ...
+func (e *JSONResultEncoder) EncodeSetChannelOptions(res *SetChannelOptionsResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeUserConnections ...
+func (e *JSONResultEncoder) EncodeUserConnections(res *UserConnectionsResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeChannelSubscribers ...
+func (e *JSONResultEncoder) EncodeChannelSubscribers(res *ChannelSubscribersResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeAcquireLock ...
+func (e *JSONResultEncoder) EncodeAcquireLock(res *AcquireLockResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeReleaseLock ...
+func (e *JSONResultEncoder) EncodeReleaseLock(res *ReleaseLockResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeConnectionEvents ...
+func (e *JSONResultEncoder) EncodeConnectionEvents(res *ConnectionEventsResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeSetChannelMeta ...
+func (e *JSONResultEncoder) EncodeSetChannelMeta(res *SetChannelMetaResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeGetChannelMeta ...
+func (e *JSONResultEncoder) EncodeGetChannelMeta(res *GetChannelMetaResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeDeleteChannelMeta ...
+func (e *JSONResultEncoder) EncodeDeleteChannelMeta(res *DeleteChannelMetaResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeAddRedisShard ...
+func (e *JSONResultEncoder) EncodeAddRedisShard(res *AddRedisShardResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeHistoryMeta ...
+func (e *JSONResultEncoder) EncodeHistoryMeta(res *HistoryMetaResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeMigrateConnections ...
+func (e *JSONResultEncoder) EncodeMigrateConnections(res *MigrateConnectionsResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeSetReadPosition ...
+func (e *JSONResultEncoder) EncodeSetReadPosition(res *SetReadPositionResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeReadPositions ...
+func (e *JSONResultEncoder) EncodeReadPositions(res *ReadPositionsResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeSetChannelGroup ...
+func (e *JSONResultEncoder) EncodeSetChannelGroup(res *SetChannelGroupResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeRemoveChannelGroup ...
+func (e *JSONResultEncoder) EncodeRemoveChannelGroup(res *RemoveChannelGroupResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeChannelGroup ...
+func (e *JSONResultEncoder) EncodeChannelGroup(res *ChannelGroupResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeChannelsCount ...
+func (e *JSONResultEncoder) EncodeChannelsCount(res *ChannelsCountResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeUnsubscribeChannel ...
+func (e *JSONResultEncoder) EncodeUnsubscribeChannel(res *UnsubscribeChannelResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeRegisterPushDevice ...
+func (e *JSONResultEncoder) EncodeRegisterPushDevice(res *RegisterPushDeviceResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
+
+// EncodeUnregisterPushDevice ...
+func (e *JSONResultEncoder) EncodeUnregisterPushDevice(res *UnregisterPushDeviceResult) ([]byte, error) {
+	//nolint:staticcheck
+	return json.Marshal(res)
+}
@@ -18,6 +18,15 @@ func GetReplyEncoder() ReplyEncoder {
 	return e.(ReplyEncoder)
 }
 
+// GetArrayReplyEncoder returns ReplyEncoder which encodes replies as JSON array.
+func GetArrayReplyEncoder() ReplyEncoder {
+	e := GetReplyEncoder()
+	if je, ok := e.(*JSONReplyEncoder); ok {
+		je.array = true
+	}
+	return e
+}
+
 // PutReplyEncoder ...
 func PutReplyEncoder(e ReplyEncoder) {
 	e.Reset()
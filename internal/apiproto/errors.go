@@ -8,19 +8,26 @@ func (x *Error) Error() string {
 	return fmt.Sprintf("%d: %s", x.Code, x.Message)
 }
 
-// Here we define errors that can be exposed in server API replies.
+// Here we define errors that can be exposed in server API replies. Codes
+// registered in ErrorCode enum of api.proto.
 var (
 	// ErrorInternal means server error, if returned this is a signal
 	// that something went wrong with Centrifugo itself.
 	ErrorInternal = &Error{
-		Code:    100,
-		Message: "internal server error",
+		Code:      100,
+		Message:   "internal server error",
+		Temporary: true,
 	}
 	// ErrorUnknownChannel means that namespace in channel name does not exist.
 	ErrorUnknownChannel = &Error{
 		Code:    102,
 		Message: "unknown channel",
 	}
+	// ErrorPermissionDenied means that access to resource is not allowed.
+	ErrorPermissionDenied = &Error{
+		Code:    103,
+		Message: "permission denied",
+	}
 	// ErrorMethodNotFound means that method sent in command does not exist.
 	ErrorMethodNotFound = &Error{
 		Code:    104,
@@ -37,6 +44,13 @@ var (
 		Code:    108,
 		Message: "not available",
 	}
+	// ErrorTooManyRequests means that server is overloaded and request
+	// should be retried later.
+	ErrorTooManyRequests = &Error{
+		Code:      111,
+		Message:   "too many requests",
+		Temporary: true,
+	}
 	// ErrorUnrecoverablePosition means that stream does not contain required
 	// range of publications to fulfill a history query. This can be happen to
 	// expiration, size limitation or due to wrong epoch.
@@ -22,3 +22,22 @@ gomodifytags -file api.pb.go -field NumSubs -struct NodeResult -all -w -remove-o
 gomodifytags -file api.pb.go -field Channels -struct ChannelsResult -all -w -remove-options json=omitempty >/dev/null
 gomodifytags -file api.pb.go -field Connections -struct UserConnectionsResult -all -w -remove-options json=omitempty >/dev/null
 gomodifytags -file api.pb.go -field Statuses -struct GetUserStatusResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field NumClients -struct ChannelSubscribersResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field Nodes -struct ChannelSubscribersResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field Acquired -struct AcquireLockResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field Released -struct ReleaseLockResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field Events -struct ConnectionEventsResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field Entries -struct GetChannelMetaResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field NumMigratedChannels -struct AddRedisShardResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field NumPublications -struct HistoryMetaResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field OldestOffset -struct HistoryMetaResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field Offset -struct HistoryMetaResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field Epoch -struct HistoryMetaResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field TtlMs -struct HistoryMetaResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field NumClients -struct MigrateConnectionsResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field Positions -struct ReadPositionsResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field Offset -struct ReadPosition -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field Epoch -struct ReadPosition -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field Sources -struct ChannelGroupResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field Count -struct ChannelsCountResult -all -w -remove-options json=omitempty >/dev/null
+gomodifytags -file api.pb.go -field NumClients -struct UnsubscribeChannelResult -all -w -remove-options json=omitempty >/dev/null
@@ -0,0 +1,250 @@
+// Package bridge replicates publications of selected channels to other
+// independent Centrifugo clusters over their GRPC API, so clients can be
+// served from the nearest region while publisher writes to one cluster.
+//
+// Bridge is a publish interceptor: publications made on this cluster (over
+// server API or by clients) are sent to all remote clusters after successful
+// publishing. Publications received from a bridge are marked with origin
+// GRPC metadata and never replicated further, so clusters must be connected
+// with each other directly (full mesh) and loops are not possible.
+package bridge
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/interceptor"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/gobwas/glob"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// OriginMetadataKey is a GRPC metadata key with name of cluster publication
+// replicated from.
+const OriginMetadataKey = "x-centrifugo-bridge-origin"
+
+// Defaults.
+const (
+	DefaultQueueSize = 1024
+	DefaultTimeout   = 5 * time.Second
+)
+
+// Remote cluster to replicate publications to.
+type Remote struct {
+	// Name of remote cluster, used in logs and metrics.
+	Name string `mapstructure:"name" json:"name"`
+	// Address of remote cluster GRPC API, host:port.
+	Address string `mapstructure:"address" json:"address"`
+	// APIKey of remote cluster GRPC API.
+	APIKey string `mapstructure:"api_key" json:"api_key"`
+	// TLS turns on TLS for connection to remote cluster.
+	TLS bool `mapstructure:"tls" json:"tls"`
+}
+
+// Config of Bridge.
+type Config struct {
+	// Name of this cluster sent to remote clusters as publication origin.
+	Name string
+	// Channels is a list of channel glob patterns to replicate.
+	Channels []string
+	// Remotes is a list of clusters to replicate publications to.
+	Remotes []Remote
+	// QueueSize is a max number of publications waiting to be sent to remote
+	// cluster, publications dropped when queue is full. By default
+	// DefaultQueueSize used.
+	QueueSize int
+	// Timeout of publish request to remote cluster. By default DefaultTimeout
+	// used.
+	Timeout time.Duration
+}
+
+// Validate config.
+func (c Config) Validate() error {
+	if c.Name == "" {
+		return errors.New("bridge name required")
+	}
+	if len(c.Channels) == 0 {
+		return errors.New("bridge channels required")
+	}
+	for _, pattern := range c.Channels {
+		if _, err := glob.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid bridge channel pattern %q: %w", pattern, err)
+		}
+	}
+	if len(c.Remotes) == 0 {
+		return errors.New("bridge remotes required")
+	}
+	names := map[string]struct{}{}
+	for _, r := range c.Remotes {
+		if r.Name == "" || r.Address == "" {
+			return errors.New("bridge remote name and address required")
+		}
+		if r.Name == c.Name {
+			return fmt.Errorf("bridge remote name %q matches bridge name", r.Name)
+		}
+		if _, ok := names[r.Name]; ok {
+			return fmt.Errorf("duplicate bridge remote name %q", r.Name)
+		}
+		names[r.Name] = struct{}{}
+	}
+	return nil
+}
+
+var _ interceptor.PublishInterceptor = (*Bridge)(nil)
+
+// Bridge replicates publications to remote clusters.
+type Bridge struct {
+	node     *centrifuge.Node
+	config   Config
+	channels []glob.Glob
+	remotes  []*remote
+	closeCh  chan struct{}
+}
+
+type remote struct {
+	name    string
+	apiKey  string
+	conn    *grpc.ClientConn
+	client  apiproto.CentrifugoApiClient
+	queue   chan interceptor.Publication
+	metrics remoteMetrics
+}
+
+// New creates Bridge and starts sending publications to remote clusters.
+func New(node *centrifuge.Node, config Config) (*Bridge, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.QueueSize == 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+	b := &Bridge{
+		node:    node,
+		config:  config,
+		closeCh: make(chan struct{}),
+	}
+	for _, pattern := range config.Channels {
+		b.channels = append(b.channels, glob.MustCompile(pattern))
+	}
+	for _, r := range config.Remotes {
+		var dialOpts []grpc.DialOption
+		if r.TLS {
+			dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})))
+		} else {
+			dialOpts = append(dialOpts, grpc.WithInsecure())
+		}
+		conn, err := grpc.Dial(r.Address, dialOpts...)
+		if err != nil {
+			b.Close()
+			return nil, fmt.Errorf("error connecting to bridge remote %s: %w", r.Name, err)
+		}
+		b.remotes = append(b.remotes, &remote{
+			name:    r.Name,
+			apiKey:  r.APIKey,
+			conn:    conn,
+			client:  apiproto.NewCentrifugoApiClient(conn),
+			queue:   make(chan interceptor.Publication, config.QueueSize),
+			metrics: newRemoteMetrics(r.Name),
+		})
+	}
+	for _, r := range b.remotes {
+		go b.runRemote(r)
+	}
+	return b, nil
+}
+
+// Close stops sending publications and closes connections to remote clusters.
+// Publications waiting in queues are dropped.
+func (b *Bridge) Close() {
+	close(b.closeCh)
+	for _, r := range b.remotes {
+		_ = r.conn.Close()
+	}
+}
+
+// Name of interceptor.
+func (b *Bridge) Name() string {
+	return "bridge"
+}
+
+// BeforePublish allows all publications.
+func (b *Bridge) BeforePublish(_ context.Context, _ *interceptor.Publication) error {
+	return nil
+}
+
+// AfterPublish queues successful publication to be sent to remote clusters
+// if channel matches bridge channels and publication was not received from
+// other bridge.
+func (b *Bridge) AfterPublish(ctx context.Context, pub interceptor.Publication, _ centrifuge.StreamPosition, err error) {
+	if err != nil || isFromBridge(ctx) || !b.matchChannel(pub.Channel) {
+		return
+	}
+	for _, r := range b.remotes {
+		select {
+		case r.queue <- pub:
+		default:
+			r.metrics.dropped.Inc()
+		}
+	}
+}
+
+func (b *Bridge) matchChannel(ch string) bool {
+	for _, g := range b.channels {
+		if g.Match(ch) {
+			return true
+		}
+	}
+	return false
+}
+
+func isFromBridge(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	return ok && len(md.Get(OriginMetadataKey)) > 0
+}
+
+func (b *Bridge) runRemote(r *remote) {
+	for {
+		select {
+		case <-b.closeCh:
+			return
+		case pub := <-r.queue:
+			if err := b.send(r, pub); err != nil {
+				r.metrics.errors.Inc()
+				b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error sending publication to bridge remote", map[string]interface{}{"remote": r.name, "channel": pub.Channel, "error": err.Error()}))
+				continue
+			}
+			r.metrics.sent.Inc()
+		}
+	}
+}
+
+func (b *Bridge) send(r *remote, pub interceptor.Publication) error {
+	ctx, cancel := context.WithTimeout(context.Background(), b.config.Timeout)
+	defer cancel()
+	md := metadata.Pairs(OriginMetadataKey, b.config.Name)
+	if r.apiKey != "" {
+		md.Set("authorization", "apikey "+r.apiKey)
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+	resp, err := r.client.Publish(ctx, &apiproto.PublishRequest{
+		Channel: pub.Channel,
+		Data:    pub.Data,
+	})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package bridge
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/interceptor"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type testAPIServer struct {
+	apiproto.UnimplementedCentrifugoApiServer
+	mu       sync.Mutex
+	requests []*apiproto.PublishRequest
+	md       []metadata.MD
+}
+
+func (s *testAPIServer) Publish(ctx context.Context, req *apiproto.PublishRequest) (*apiproto.PublishResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.requests = append(s.requests, req)
+	s.md = append(s.md, md)
+	return &apiproto.PublishResponse{}, nil
+}
+
+func (s *testAPIServer) numRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+func runTestAPIServer(t *testing.T) (*testAPIServer, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	apiServer := &testAPIServer{}
+	apiproto.RegisterCentrifugoApiServer(server, apiServer)
+	go func() { _ = server.Serve(l) }()
+	t.Cleanup(server.Stop)
+	return apiServer, l.Addr().String()
+}
+
+func TestConfigValidate(t *testing.T) {
+	remotes := []Remote{{Name: "us", Address: "localhost:10000"}}
+	require.NoError(t, Config{Name: "eu", Channels: []string{"news:*"}, Remotes: remotes}.Validate())
+	require.Error(t, Config{Channels: []string{"news:*"}, Remotes: remotes}.Validate())
+	require.Error(t, Config{Name: "eu", Remotes: remotes}.Validate())
+	require.Error(t, Config{Name: "eu", Channels: []string{"news:[*"}, Remotes: remotes}.Validate())
+	require.Error(t, Config{Name: "eu", Channels: []string{"news:*"}}.Validate())
+	require.Error(t, Config{Name: "us", Channels: []string{"news:*"}, Remotes: remotes}.Validate())
+	require.Error(t, Config{Name: "eu", Channels: []string{"news:*"}, Remotes: append(remotes, remotes...)}.Validate())
+}
+
+func TestBridge(t *testing.T) {
+	node, err := centrifuge.New(centrifuge.DefaultConfig)
+	require.NoError(t, err)
+	apiServer, addr := runTestAPIServer(t)
+
+	b, err := New(node, Config{
+		Name:     "eu",
+		Channels: []string{"news:*"},
+		Remotes:  []Remote{{Name: "us", Address: addr, APIKey: "secret"}},
+	})
+	require.NoError(t, err)
+	defer b.Close()
+
+	ctx := context.Background()
+	b.AfterPublish(ctx, interceptor.Publication{Channel: "chat:1", Data: []byte(`{}`)}, centrifuge.StreamPosition{}, nil)
+	b.AfterPublish(ctx, interceptor.Publication{Channel: "news:1", Data: []byte(`{}`)}, centrifuge.StreamPosition{}, context.Canceled)
+	// Publication replicated from other cluster.
+	fromBridgeCtx := metadata.NewIncomingContext(ctx, metadata.Pairs(OriginMetadataKey, "us"))
+	b.AfterPublish(fromBridgeCtx, interceptor.Publication{Channel: "news:1", Data: []byte(`{}`)}, centrifuge.StreamPosition{}, nil)
+
+	b.AfterPublish(ctx, interceptor.Publication{Channel: "news:1", Data: []byte(`{"a":1}`)}, centrifuge.StreamPosition{}, nil)
+	require.Eventually(t, func() bool {
+		return apiServer.numRequests() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 1, apiServer.numRequests())
+
+	apiServer.mu.Lock()
+	defer apiServer.mu.Unlock()
+	require.Equal(t, "news:1", apiServer.requests[0].Channel)
+	require.Equal(t, apiproto.Raw(`{"a":1}`), apiServer.requests[0].Data)
+	require.Equal(t, []string{"eu"}, apiServer.md[0].Get(OriginMetadataKey))
+	require.Equal(t, []string{"apikey secret"}, apiServer.md[0].Get("authorization"))
+}
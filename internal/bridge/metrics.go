@@ -0,0 +1,34 @@
+package bridge
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricsNamespace = "centrifugo"
+
+var (
+	publicationsCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "bridge",
+		Name:      "publications",
+		Help:      "Number of publications replicated to remote clusters by result.",
+	}, []string{"remote", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(publicationsCount)
+}
+
+type remoteMetrics struct {
+	sent    prometheus.Counter
+	errors  prometheus.Counter
+	dropped prometheus.Counter
+}
+
+func newRemoteMetrics(name string) remoteMetrics {
+	return remoteMetrics{
+		sent:    publicationsCount.WithLabelValues(name, "sent"),
+		errors:  publicationsCount.WithLabelValues(name, "error"),
+		dropped: publicationsCount.WithLabelValues(name, "dropped"),
+	}
+}
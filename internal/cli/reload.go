@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/limits"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+)
+
+type reloadConfigCommand struct {
+	Method string             `json:"method"`
+	Params reloadConfigParams `json:"params"`
+}
+
+type reloadConfigParams struct {
+	Config json.RawMessage `json:"config"`
+}
+
+type reloadConfigReply struct {
+	Error *struct {
+		Code    uint32 `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// reloadConfigObject returns JSON object in configuration file format with
+// channel options, limits and proxy options.
+func reloadConfigObject(ruleConfig rule.Config, limitsConfig limits.Config, proxyOptions map[string]interface{}) ([]byte, error) {
+	object := map[string]interface{}{}
+	for _, v := range []interface{}{ruleConfig, limitsConfig} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &object); err != nil {
+			return nil, err
+		}
+	}
+	for key, value := range proxyOptions {
+		object[key] = value
+	}
+	return json.Marshal(object)
+}
+
+// ReloadConfig sends configuration to Centrifugo server API so it will be applied
+// on all nodes in cluster. Configuration consists of channel options, limits and
+// client proxy options.
+func ReloadConfig(apiEndpoint string, apiKey string, ruleConfig rule.Config, limitsConfig limits.Config, proxyOptions map[string]interface{}) error {
+	if err := ruleConfig.Validate(); err != nil {
+		return err
+	}
+	if err := limitsConfig.Validate(); err != nil {
+		return err
+	}
+	config, err := reloadConfigObject(ruleConfig, limitsConfig, proxyOptions)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(reloadConfigCommand{
+		Method: "reload_config",
+		Params: reloadConfigParams{Config: config},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, apiEndpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "apikey "+apiKey)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response status code: %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var reply reloadConfigReply
+	if err := json.Unmarshal(body, &reply); err != nil {
+		return err
+	}
+	if reply.Error != nil {
+		return fmt.Errorf("error from server: %d: %s", reply.Error.Code, reply.Error.Message)
+	}
+	return nil
+}
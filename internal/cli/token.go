@@ -27,9 +27,40 @@ func GenerateToken(config jwtverify.VerifierConfig, user string, ttlSeconds int6
 	return token.String(), nil
 }
 
+// GenerateSubscriptionToken generates sample subscription JWT for client to
+// subscribe on channel.
+func GenerateSubscriptionToken(config jwtverify.VerifierConfig, client string, channel string, ttlSeconds int64) (string, error) {
+	if config.HMACSecretKey == "" {
+		return "", fmt.Errorf("no HMAC secret key set")
+	}
+	if channel == "" {
+		return "", fmt.Errorf("no channel set")
+	}
+	if client == "" {
+		// Subscription tokens without client are rejected by verifier.
+		return "", fmt.Errorf("no client set")
+	}
+	signer, _ := jwt.NewSignerHS(jwt.HS256, []byte(config.HMACSecretKey))
+	builder := jwt.NewBuilder(signer)
+	token, err := builder.Build(jwtverify.SubscribeTokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(ttlSeconds) * time.Second)),
+		},
+		Client:  client,
+		Channel: channel,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token.String(), nil
+}
+
 func verify(config jwtverify.VerifierConfig, ruleConfig rule.Config, token string) (jwtverify.ConnectToken, error) {
 	ruleContainer := rule.NewContainer(ruleConfig)
-	verifier := jwtverify.NewTokenVerifierJWT(config, ruleContainer)
+	verifier, err := jwtverify.NewVerifierJWT(config, ruleContainer)
+	if err != nil {
+		return jwtverify.ConnectToken{}, err
+	}
 	return verifier.VerifyConnectToken(token)
 }
 
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/centrifugal/centrifugo/v3/internal/jwtverify"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateToken(t *testing.T) {
+	_, err := GenerateToken(jwtverify.VerifierConfig{}, "42", 60)
+	require.Error(t, err)
+
+	config := jwtverify.VerifierConfig{HMACSecretKey: "secret"}
+	token, err := GenerateToken(config, "42", 60)
+	require.NoError(t, err)
+	user, _, err := CheckToken(config, rule.Config{}, token)
+	require.NoError(t, err)
+	require.Equal(t, "42", user)
+}
+
+func TestGenerateSubscriptionToken(t *testing.T) {
+	config := jwtverify.VerifierConfig{HMACSecretKey: "secret"}
+	_, err := GenerateSubscriptionToken(config, "client", "", 60)
+	require.Error(t, err)
+	_, err = GenerateSubscriptionToken(config, "", "$private", 60)
+	require.Error(t, err)
+
+	token, err := GenerateSubscriptionToken(config, "client", "$private", 60)
+	require.NoError(t, err)
+	verifier := jwtverify.NewTokenVerifierJWT(config, rule.NewContainer(rule.Config{}))
+	st, err := verifier.VerifySubscribeToken(token)
+	require.NoError(t, err)
+	require.Equal(t, "client", st.Client)
+	require.Equal(t, "$private", st.Channel)
+}
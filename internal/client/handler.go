@@ -2,15 +2,40 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"sync"
 	"time"
 
+	"github.com/centrifugal/centrifugo/v3/internal/affinity"
 	"github.com/centrifugal/centrifugo/v3/internal/clientcontext"
+	"github.com/centrifugal/centrifugo/v3/internal/connlog"
+	"github.com/centrifugal/centrifugo/v3/internal/connthrottle"
+	"github.com/centrifugal/centrifugo/v3/internal/disconnect"
+	"github.com/centrifugal/centrifugo/v3/internal/errorcode"
+	"github.com/centrifugal/centrifugo/v3/internal/geoip"
+	"github.com/centrifugal/centrifugo/v3/internal/grant"
+	"github.com/centrifugal/centrifugo/v3/internal/inbox"
+	"github.com/centrifugal/centrifugo/v3/internal/interceptor"
 	"github.com/centrifugal/centrifugo/v3/internal/jwtverify"
+	"github.com/centrifugal/centrifugo/v3/internal/limits"
+	"github.com/centrifugal/centrifugo/v3/internal/loadshed"
+	"github.com/centrifugal/centrifugo/v3/internal/middleware"
+	"github.com/centrifugal/centrifugo/v3/internal/migrate"
+	"github.com/centrifugal/centrifugo/v3/internal/nsmetrics"
+	"github.com/centrifugal/centrifugo/v3/internal/presencestate"
 	"github.com/centrifugal/centrifugo/v3/internal/proxy"
+	"github.com/centrifugal/centrifugo/v3/internal/pubdedup"
+	"github.com/centrifugal/centrifugo/v3/internal/publisher"
+	"github.com/centrifugal/centrifugo/v3/internal/puborigin"
+	"github.com/centrifugal/centrifugo/v3/internal/pubtags"
 	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/sdkinfo"
+	"github.com/centrifugal/centrifugo/v3/internal/subcaps"
+	"github.com/centrifugal/centrifugo/v3/internal/tenant"
 
 	"github.com/centrifugal/centrifuge"
+	"github.com/google/uuid"
 )
 
 // UseUnlimitedHistoryByDefault enables mode when requests without limit set
@@ -37,9 +62,42 @@ type Handler struct {
 	node              *centrifuge.Node
 	ruleContainer     *rule.Container
 	tokenVerifier     jwtverify.Verifier
+	proxyMu           sync.RWMutex
 	proxyMap          *ProxyMap
+	proxies           *proxyHandlers
+	limits            *limits.Container
 	rpcExtension      map[string]RPCExtensionFunc
 	granularProxyMode bool
+	connLog           *connlog.Log
+	metaStore         ChannelMetaStore
+	tenants           *tenant.Registry
+	migration         *migrate.Registry
+	affinityToken     string
+	presenceStates    *presencestate.Manager
+	readPositions     ReadPositionStore
+	interceptors      *interceptor.Chain
+	geoIP             *geoip.Resolver
+	geoIPConnInfo     bool
+	connectThrottle   *connthrottle.Throttle
+	loadShedder       *loadshed.Shedder
+	inbox             inbox.Store
+	nsMetrics         *nsmetrics.Observer
+	publishDedup      pubdedup.Store
+	publishDedupTTL   time.Duration
+	publishPendingTTL time.Duration
+	subCaps           *subcaps.Requests
+	publisher         *publisher.Publisher
+}
+
+// ChannelMetaStore can return meta entries of channel.
+type ChannelMetaStore interface {
+	ChannelMeta(ch string) (map[string][]byte, error)
+}
+
+// ReadPositionStore keeps last read stream positions of users in channels.
+type ReadPositionStore interface {
+	SetReadPosition(user string, ch string, sp centrifuge.StreamPosition) error
+	ReadPositions(user string, channels []string) (map[string]centrifuge.StreamPosition, error)
 }
 
 // NewHandler ...
@@ -57,6 +115,8 @@ func NewHandler(
 		proxyMap:          proxyMap,
 		granularProxyMode: granularProxyMode,
 		rpcExtension:      make(map[string]RPCExtensionFunc),
+		subCaps:           subcaps.NewRequests(),
+		publisher:         publisher.New(node, ruleContainer),
 	}
 }
 
@@ -65,53 +125,241 @@ func (h *Handler) SetRPCExtension(method string, handler RPCExtensionFunc) {
 	h.rpcExtension[method] = handler
 }
 
-// Setup event handlers.
-func (h *Handler) Setup() error {
-	var connectProxyHandler centrifuge.ConnectingHandler
-	if h.proxyMap.ConnectProxy != nil {
-		connectProxyHandler = proxy.NewConnectHandler(proxy.ConnectHandlerConfig{
-			Proxy: h.proxyMap.ConnectProxy,
-		}, h.ruleContainer).Handle(h.node)
+// SetLimits sets Container with client limits. Limits of Centrifuge node
+// should be turned off in this case, so reloaded limits take effect.
+func (h *Handler) SetLimits(c *limits.Container) {
+	h.limits = c
+}
+
+// SetConnectionLog sets log to keep connection events in.
+func (h *Handler) SetConnectionLog(l *connlog.Log) {
+	h.connLog = l
+}
+
+// SetChannelMetaStore sets store to get channel meta sent to clients on
+// subscribe from.
+func (h *Handler) SetChannelMetaStore(s ChannelMetaStore) {
+	h.metaStore = s
+}
+
+// SetTenants sets tenant Registry. Connections of tenant can only use channels
+// of tenant, connections without tenant can't use channels of tenants.
+func (h *Handler) SetTenants(tenants *tenant.Registry) {
+	h.tenants = tenants
+}
+
+// SetMigrationRegistry sets registry to keep node connections in, so they can
+// be asked to migrate to other nodes.
+func (h *Handler) SetMigrationRegistry(r *migrate.Registry) {
+	h.migration = r
+}
+
+// SetGeoIP sets Resolver to find out location of clients by IP address at
+// connect time. If connInfo is true location is also added to connection info
+// (see geoip.AddToInfo), so it's available in presence and join/leave messages.
+func (h *Handler) SetGeoIP(r *geoip.Resolver, connInfo bool) {
+	h.geoIP = r
+	h.geoIPConnInfo = connInfo
+}
+
+// geoConnInfo adds client location from ctx to connection info if configured.
+func (h *Handler) geoConnInfo(ctx context.Context, info []byte) []byte {
+	if !h.geoIPConnInfo {
+		return info
+	}
+	loc, ok := geoip.FromContext(ctx)
+	if !ok {
+		return info
 	}
+	return geoip.AddToInfo(info, loc)
+}
 
-	var refreshProxyHandler proxy.RefreshHandlerFunc
-	if h.proxyMap.RefreshProxy != nil {
-		refreshProxyHandler = proxy.NewRefreshHandler(proxy.RefreshHandlerConfig{
-			Proxy: h.proxyMap.RefreshProxy,
-		}).Handle(h.node)
+// SetConnectThrottle sets limiter of connection attempts rate.
+func (h *Handler) SetConnectThrottle(t *connthrottle.Throttle) {
+	h.connectThrottle = t
+}
+
+// SetLoadShedder sets Shedder to reject connection attempts and history
+// reads while node sheds load due to heap soft limit.
+func (h *Handler) SetLoadShedder(s *loadshed.Shedder) {
+	h.loadShedder = s
+}
+
+// SetPublisher sets Publisher shared with server API to publish client data
+// with. By default Publisher without inbox used.
+func (h *Handler) SetPublisher(p *publisher.Publisher) {
+	h.publisher = p
+}
+
+// SetInbox sets Store to drain user inbox from on connect. Inbox entries sent
+// in subscribe result data of personal channel.
+func (h *Handler) SetInbox(s inbox.Store) {
+	h.inbox = s
+}
+
+// SetNamespaceMetrics sets Observer to count client operations with channels
+// by namespace.
+func (h *Handler) SetNamespaceMetrics(o *nsmetrics.Observer) {
+	h.nsMetrics = o
+}
+
+// SetPublishDedupStore sets Store of publication results by client-generated
+// message IDs kept for ttl. Clients can publish with message ID using
+// PublishRPCMethod, retries with the same ID are not published again.
+// Message ID is marked as pending for pendingTTL while publication is in
+// progress, it should exceed max publication time but not ttl.
+func (h *Handler) SetPublishDedupStore(s pubdedup.Store, ttl time.Duration, pendingTTL time.Duration) {
+	if pendingTTL > ttl {
+		pendingTTL = ttl
 	}
+	h.publishDedup = s
+	h.publishDedupTTL = ttl
+	h.publishPendingTTL = pendingTTL
+}
 
-	h.node.OnConnecting(func(ctx context.Context, e centrifuge.ConnectEvent) (centrifuge.ConnectReply, error) {
-		reply, err := h.OnClientConnecting(ctx, e, connectProxyHandler, refreshProxyHandler != nil)
-		if err != nil {
-			return centrifuge.ConnectReply{}, err
-		}
-		return reply, err
-	})
+// SetAffinityToken sets node affinity token added to connect reply data, so
+// clients can pass it to load balancer on reconnect.
+func (h *Handler) SetAffinityToken(token string) {
+	h.affinityToken = token
+}
+
+// SetPresenceStateManager sets manager of connection presence states. Clients
+// can set state of connection in channels with presence state enabled using
+// PresenceStateRPCMethod.
+func (h *Handler) SetPresenceStateManager(m *presencestate.Manager) {
+	h.presenceStates = m
+	h.SetRPCExtension(PresenceStateRPCMethod, h.onPresenceStateRPC)
+}
+
+// SetReadPositionStore sets store of user read positions. Authenticated
+// clients can set and get own read positions using ReadPositionRPCMethod and
+// ReadPositionsRPCMethod.
+func (h *Handler) SetReadPositionStore(s ReadPositionStore) {
+	h.readPositions = s
+	h.SetRPCExtension(ReadPositionRPCMethod, h.onReadPositionRPC)
+	h.SetRPCExtension(ReadPositionsRPCMethod, h.onReadPositionsRPC)
+}
+
+// SetInterceptors sets Chain of interceptors called on client publications
+// and subscriptions.
+func (h *Handler) SetInterceptors(c *interceptor.Chain) {
+	h.interceptors = c
+}
+
+// proxyHandlers are event handlers built from ProxyMap.
+type proxyHandlers struct {
+	connect   centrifuge.ConnectingHandler
+	refresh   proxy.RefreshHandlerFunc
+	rpc       proxy.RPCHandlerFunc
+	publish   proxy.PublishHandlerFunc
+	subscribe proxy.SubscribeHandlerFunc
+}
 
-	var rpcProxyHandler proxy.RPCHandlerFunc
-	if len(h.proxyMap.RpcProxies) > 0 {
-		rpcProxyHandler = proxy.NewRPCHandler(proxy.RPCHandlerConfig{
-			Proxies:           h.proxyMap.RpcProxies,
+func (h *Handler) newProxyHandlers(m *ProxyMap) *proxyHandlers {
+	p := &proxyHandlers{}
+	if m.ConnectProxy != nil {
+		p.connect = proxy.NewConnectHandler(proxy.ConnectHandlerConfig{
+			Proxy: m.ConnectProxy,
+		}, h.ruleContainer).Handle(h.node)
+	}
+	if m.RefreshProxy != nil {
+		p.refresh = proxy.NewRefreshHandler(proxy.RefreshHandlerConfig{
+			Proxy: m.RefreshProxy,
+		}).Handle(h.node)
+	}
+	if len(m.RpcProxies) > 0 {
+		p.rpc = proxy.NewRPCHandler(proxy.RPCHandlerConfig{
+			Proxies:           m.RpcProxies,
 			GranularProxyMode: h.granularProxyMode,
 		}).Handle(h.node)
 	}
-
-	var publishProxyHandler proxy.PublishHandlerFunc
-	if len(h.proxyMap.PublishProxies) > 0 {
-		publishProxyHandler = proxy.NewPublishHandler(proxy.PublishHandlerConfig{
-			Proxies:           h.proxyMap.PublishProxies,
+	if len(m.PublishProxies) > 0 {
+		p.publish = proxy.NewPublishHandler(proxy.PublishHandlerConfig{
+			Proxies:           m.PublishProxies,
 			GranularProxyMode: h.granularProxyMode,
+			Publisher:         h.publisher,
 		}).Handle(h.node)
 	}
-
-	var subscribeProxyHandler proxy.SubscribeHandlerFunc
-	if len(h.proxyMap.SubscribeProxies) > 0 {
-		subscribeProxyHandler = proxy.NewSubscribeHandler(proxy.SubscribeHandlerConfig{
-			Proxies:           h.proxyMap.SubscribeProxies,
+	if len(m.SubscribeProxies) > 0 {
+		p.subscribe = proxy.NewSubscribeHandler(proxy.SubscribeHandlerConfig{
+			Proxies:           m.SubscribeProxies,
 			GranularProxyMode: h.granularProxyMode,
 		}).Handle(h.node)
 	}
+	return p
+}
+
+// currentProxies returns handlers of current ProxyMap.
+func (h *Handler) currentProxies() *proxyHandlers {
+	h.proxyMu.RLock()
+	defer h.proxyMu.RUnlock()
+	return h.proxies
+}
+
+// ReloadProxies replaces ProxyMap of handler and returns previous one. Events
+// already being processed finish with previous proxies. Must be called after
+// Setup.
+func (h *Handler) ReloadProxies(m *ProxyMap) *ProxyMap {
+	p := h.newProxyHandlers(m)
+	h.proxyMu.Lock()
+	defer h.proxyMu.Unlock()
+	prev := h.proxyMap
+	h.proxyMap = m
+	h.proxies = p
+	return prev
+}
+
+// checkConnectLimits checks channel and user connection limits on connect
+// like Centrifuge node does with its own limits.
+func (h *Handler) checkConnectLimits(ctx context.Context, e centrifuge.ConnectEvent, reply centrifuge.ConnectReply) error {
+	l := h.limits.Config()
+	if l.ClientChannelLimit > 0 && len(reply.Subscriptions) > l.ClientChannelLimit {
+		return centrifuge.DisconnectChannelLimit
+	}
+	if l.ClientUserConnectionLimit == 0 {
+		return nil
+	}
+	credentials := reply.Credentials
+	if credentials == nil {
+		credentials, _ = centrifuge.GetCredentials(ctx)
+	}
+	if credentials == nil || credentials.UserID == "" {
+		return nil
+	}
+	if len(h.node.Hub().UserConnections(credentials.UserID)) >= l.ClientUserConnectionLimit {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "limit of connections for user reached", map[string]interface{}{"user": credentials.UserID, "client": e.ClientID, "limit": l.ClientUserConnectionLimit}))
+		return centrifuge.DisconnectConnectionLimit
+	}
+	return nil
+}
+
+// Setup event handlers.
+func (h *Handler) Setup() error {
+	h.proxyMu.Lock()
+	h.proxies = h.newProxyHandlers(h.proxyMap)
+	h.proxyMu.Unlock()
+
+	h.node.OnConnecting(func(ctx context.Context, e centrifuge.ConnectEvent) (centrifuge.ConnectReply, error) {
+		proxies := h.currentProxies()
+		reply, err := h.OnClientConnecting(ctx, e, proxies.connect, proxies.refresh != nil)
+		if err == nil {
+			err = h.checkConnectLimits(ctx, e, reply)
+		}
+		if err != nil {
+			err = clientError(err)
+			h.logConnectFailed(ctx, e, err)
+			return centrifuge.ConnectReply{}, err
+		}
+		return reply, err
+	})
+
+	h.SetRPCExtension(SubscriptionFilterRPCMethod, h.onSubscriptionFilterRPC)
+	h.SetRPCExtension(SubscriptionCapsRPCMethod, h.onSubscriptionCapsRPC)
+	if h.publishDedup != nil {
+		h.SetRPCExtension(PublishRPCMethod, func(c *centrifuge.Client, e centrifuge.RPCEvent) (centrifuge.RPCReply, error) {
+			return h.onPublishRPC(c, e, h.currentProxies().publish)
+		})
+	}
 
 	ruleConfig := h.ruleContainer.Config()
 	usePersonalChannel := ruleConfig.UserSubscribeToPersonal
@@ -121,11 +369,37 @@ func (h *Handler) Setup() error {
 	h.node.OnConnect(func(client *centrifuge.Client) {
 		userID := client.UserID()
 
+		if h.connLog != nil {
+			h.logConnectionEvent(client, connlog.EventConnect, nil)
+		}
+		if h.migration != nil {
+			h.migration.Add(client)
+		}
+		if h.inbox != nil {
+			h.trimInbox(client)
+		}
+		loc, hasLocation := geoip.FromContext(client.Context())
+		if hasLocation {
+			geoip.IncClients(loc)
+		}
+		client.OnDisconnect(func(e centrifuge.DisconnectEvent) {
+			if h.connLog != nil {
+				h.logConnectionEvent(client, connlog.EventDisconnect, e.Disconnect)
+			}
+			if h.migration != nil {
+				h.migration.Remove(client)
+			}
+			if hasLocation {
+				geoip.DecClients(loc)
+			}
+			h.subCaps.Remove(client.ID())
+		})
+
 		if usePersonalChannel && singleConnection && userID != "" {
 			personalChannel := h.ruleContainer.PersonalChannel(userID)
 			presenceStats, err := h.node.PresenceStats(personalChannel)
 			if err != nil {
-				h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error calling presence stats", map[string]interface{}{"error": err.Error(), "client": client.ID(), "user": client.UserID()}))
+				h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error calling presence stats", middleware.WithTraceID(client.Context(), map[string]interface{}{"error": err.Error(), "client": client.ID(), "user": client.UserID()})))
 				client.Disconnect(centrifuge.DisconnectServerError)
 				return
 			}
@@ -136,7 +410,7 @@ func (h *Handler) Setup() error {
 					centrifuge.WithDisconnectClientWhitelist([]string{client.ID()}),
 				)
 				if err != nil {
-					h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error sending disconnect", map[string]interface{}{"error": err.Error(), "client": client.ID(), "user": client.UserID()}))
+					h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error sending disconnect", middleware.WithTraceID(client.Context(), map[string]interface{}{"error": err.Error(), "client": client.ID(), "user": client.UserID()})))
 					client.Disconnect(centrifuge.DisconnectServerError)
 					return
 				}
@@ -150,65 +424,132 @@ func (h *Handler) Setup() error {
 
 		client.OnRefresh(func(event centrifuge.RefreshEvent, cb centrifuge.RefreshCallback) {
 			h.runConcurrentlyIfNeeded(concurrency, semaphore, func() {
-				reply, err := h.OnRefresh(client, event, refreshProxyHandler)
-				cb(reply, err)
+				reply, err := h.OnRefresh(client, event, h.currentProxies().refresh)
+				cb(reply, clientError(err))
 			})
 		})
 
-		if rpcProxyHandler != nil || len(h.rpcExtension) > 0 {
-			client.OnRPC(func(event centrifuge.RPCEvent, cb centrifuge.RPCCallback) {
-				h.runConcurrentlyIfNeeded(concurrency, semaphore, func() {
-					reply, err := h.OnRPC(client, event, rpcProxyHandler)
-					cb(reply, err)
-				})
+		if t, ok := client.Transport().(*pubtags.Transport); ok {
+			client.OnUnsubscribe(func(e centrifuge.UnsubscribeEvent) {
+				t.SetFilter(e.Channel, nil)
 			})
 		}
 
+		client.OnRPC(func(event centrifuge.RPCEvent, cb centrifuge.RPCCallback) {
+			h.runConcurrentlyIfNeeded(concurrency, semaphore, func() {
+				reply, err := h.OnRPC(client, event, h.currentProxies().rpc)
+				cb(reply, clientError(err))
+			})
+		})
+
 		client.OnSubscribe(func(event centrifuge.SubscribeEvent, cb centrifuge.SubscribeCallback) {
 			h.runConcurrentlyIfNeeded(concurrency, semaphore, func() {
-				reply, err := h.OnSubscribe(client, event, subscribeProxyHandler)
-				cb(reply, err)
+				reply, err := h.OnSubscribe(client, event, h.currentProxies().subscribe)
+				h.nsMetrics.Observe(nsmetrics.SourceClient, "subscribe", event.Channel, err)
+				cb(reply, clientError(err))
 			})
 		})
 
 		client.OnSubRefresh(func(event centrifuge.SubRefreshEvent, cb centrifuge.SubRefreshCallback) {
 			h.runConcurrentlyIfNeeded(concurrency, semaphore, func() {
 				reply, err := h.OnSubRefresh(client, event)
-				cb(reply, err)
+				h.nsMetrics.Observe(nsmetrics.SourceClient, "sub_refresh", event.Channel, err)
+				cb(reply, clientError(err))
 			})
 		})
 
 		client.OnPublish(func(event centrifuge.PublishEvent, cb centrifuge.PublishCallback) {
 			h.runConcurrentlyIfNeeded(concurrency, semaphore, func() {
-				reply, err := h.OnPublish(client, event, publishProxyHandler)
-				cb(reply, err)
+				reply, err := h.OnPublish(client, event, h.currentProxies().publish)
+				h.nsMetrics.ObservePublish(nsmetrics.SourceClient, event.Channel, event.Data, err)
+				cb(reply, clientError(err))
 			})
 		})
 
 		client.OnPresence(func(event centrifuge.PresenceEvent, cb centrifuge.PresenceCallback) {
 			h.runConcurrentlyIfNeeded(concurrency, semaphore, func() {
 				reply, err := h.OnPresence(client, event)
-				cb(reply, err)
+				h.nsMetrics.Observe(nsmetrics.SourceClient, "presence", event.Channel, err)
+				cb(reply, clientError(err))
 			})
 		})
 
 		client.OnPresenceStats(func(event centrifuge.PresenceStatsEvent, cb centrifuge.PresenceStatsCallback) {
 			h.runConcurrentlyIfNeeded(concurrency, semaphore, func() {
 				reply, err := h.OnPresenceStats(client, event)
-				cb(reply, err)
+				h.nsMetrics.Observe(nsmetrics.SourceClient, "presence_stats", event.Channel, err)
+				cb(reply, clientError(err))
 			})
 		})
 
 		client.OnHistory(func(event centrifuge.HistoryEvent, cb centrifuge.HistoryCallback) {
 			h.runConcurrentlyIfNeeded(concurrency, semaphore, func() {
 				reply, err := h.OnHistory(client, event)
-				cb(reply, err)
+				h.nsMetrics.Observe(nsmetrics.SourceClient, "history", event.Channel, err)
+				cb(reply, clientError(err))
 			})
 		})
 	})
 	return nil
 }
 
+// clientError converts errors registered in errorcode to public errors, so
+// clients get the same codes as server API callers. Other errors returned as
+// is: Centrifuge logs them and replies with internal error.
+func clientError(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch err.(type) {
+	case *centrifuge.Error, *centrifuge.Disconnect:
+		return err
+	}
+	if code, ok := errorcode.Mapped(err); ok {
+		return code
+	}
+	return err
+}
+
+func (h *Handler) logConnectionEvent(client *centrifuge.Client, eventType connlog.EventType, disconnect *centrifuge.Disconnect) {
+	event := connlog.Event{
+		Type:      eventType,
+		Client:    client.ID(),
+		User:      client.UserID(),
+		Transport: client.Transport().Name(),
+	}
+	if ctx := client.Context(); ctx != nil {
+		event.IP, _ = middleware.GetClientIPFromContext(ctx)
+	}
+	if disconnect != nil {
+		event.Code = disconnect.Code
+		event.Reason = disconnect.Reason
+	}
+	h.connLog.Add(event)
+}
+
+func (h *Handler) logConnectFailed(ctx context.Context, e centrifuge.ConnectEvent, err error) {
+	if h.connLog == nil {
+		return
+	}
+	event := connlog.Event{
+		Type:      connlog.EventConnectFailed,
+		Client:    e.ClientID,
+		Transport: e.Transport.Name(),
+	}
+	event.IP, _ = middleware.GetClientIPFromContext(ctx)
+	switch v := err.(type) {
+	case *centrifuge.Disconnect:
+		event.Code = v.Code
+		event.Reason = v.Reason
+	case *centrifuge.Error:
+		event.Code = v.Code
+		event.Reason = v.Message
+	default:
+		event.Reason = err.Error()
+	}
+	h.connLog.Add(event)
+}
+
 func (h *Handler) runConcurrentlyIfNeeded(concurrency int, semaphore chan struct{}, fn func()) {
 	if concurrency > 1 {
 		semaphore <- struct{}{}
@@ -232,6 +573,8 @@ func (h *Handler) OnClientConnecting(
 		credentials *centrifuge.Credentials
 		data        []byte
 		newCtx      context.Context
+		roles       []string
+		tenantName  string
 	)
 
 	subscriptions := make(map[string]centrifuge.SubscribeOptions)
@@ -240,6 +583,33 @@ func (h *Handler) OnClientConnecting(
 
 	var processClientChannels bool
 
+	if h.loadShedder != nil {
+		if d := h.loadShedder.AllowConnect(); d != nil {
+			return centrifuge.ConnectReply{}, d
+		}
+	}
+
+	if h.connectThrottle != nil {
+		if d := h.connectThrottle.Wait(ctx); d != nil {
+			return centrifuge.ConnectReply{}, d
+		}
+	}
+
+	if e.Token == "" {
+		// Token may be passed by transport outside of connect command.
+		if token, ok := clientcontext.GetContextConnectToken(ctx); ok {
+			e.Token = token
+		}
+	}
+
+	if h.geoIP != nil {
+		if ip, ok := middleware.GetClientIPFromContext(ctx); ok {
+			if loc, ok := h.geoIP.Lookup(ip); ok {
+				ctx = geoip.SetToContext(ctx, loc)
+			}
+		}
+	}
+
 	if e.Token != "" {
 		token, err := h.tokenVerifier.VerifyConnectToken(e.Token)
 		if err != nil {
@@ -247,10 +617,10 @@ func (h *Handler) OnClientConnecting(
 				return centrifuge.ConnectReply{}, centrifuge.ErrorTokenExpired
 			}
 			if errors.Is(err, jwtverify.ErrInvalidToken) {
-				h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "invalid connection token", map[string]interface{}{"error": err.Error(), "client": e.ClientID}))
-				return centrifuge.ConnectReply{}, centrifuge.DisconnectInvalidToken
+				h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "invalid connection token", middleware.WithTraceID(ctx, map[string]interface{}{"error": err.Error(), "client": e.ClientID})))
+				return centrifuge.ConnectReply{}, invalidTokenDisconnect(err)
 			}
-			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "internal server error", map[string]interface{}{"error": err.Error(), "client": e.ClientID}))
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "internal server error", middleware.WithTraceID(ctx, map[string]interface{}{"error": err.Error(), "client": e.ClientID})))
 			return centrifuge.ConnectReply{}, err
 		}
 
@@ -266,11 +636,23 @@ func (h *Handler) OnClientConnecting(
 
 		subscriptions = token.Subs
 
+		newCtx = ctx
 		if token.Meta != nil {
-			newCtx = clientcontext.SetContextConnectionMeta(ctx, clientcontext.ConnectionMeta{
+			newCtx = clientcontext.SetContextConnectionMeta(newCtx, clientcontext.ConnectionMeta{
 				Meta: token.Meta,
 			})
 		}
+		if len(token.Roles) > 0 {
+			newCtx = clientcontext.SetContextConnectionRoles(newCtx, token.Roles)
+		}
+		roles = token.Roles
+		if token.Tenant != "" {
+			newCtx = tenant.SetToContext(newCtx, token.Tenant)
+		}
+		if token.Grants != nil {
+			newCtx = grant.SetToContext(newCtx, token.Grants)
+		}
+		tenantName = token.Tenant
 
 		processClientChannels = true
 	} else if connectProxyHandler != nil {
@@ -293,22 +675,46 @@ func (h *Handler) OnClientConnecting(
 		}
 	}
 
+	var anonymousRestricted bool
+
+	// Proceed with ephemeral user ID and read-only access to public channels in
+	// case restricted anonymous option on.
+	if credentials == nil && ruleConfig.ClientAnonymousRestricted {
+		credentials = &centrifuge.Credentials{
+			UserID: uuid.New().String(),
+		}
+		if newCtx == nil {
+			newCtx = ctx
+		}
+		newCtx = clientcontext.SetContextAnonymousRestricted(newCtx)
+		anonymousRestricted = true
+		processClientChannels = true
+	}
+
 	// Automatically subscribe on personal server-side channel.
-	if credentials != nil && ruleConfig.UserSubscribeToPersonal && credentials.UserID != "" {
+	if credentials != nil && ruleConfig.UserSubscribeToPersonal && credentials.UserID != "" && !anonymousRestricted {
 		personalChannel := h.ruleContainer.PersonalChannel(credentials.UserID)
 		chOpts, found, err := h.ruleContainer.ChannelOptions(personalChannel)
 		if err != nil {
-			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "subscribe channel options error", map[string]interface{}{"error": err.Error(), "channel": personalChannel}))
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "subscribe channel options error", middleware.WithTraceID(ctx, map[string]interface{}{"error": err.Error(), "channel": personalChannel})))
 			return centrifuge.ConnectReply{}, err
 		}
 		if !found {
-			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "subscribe unknown personal channel", map[string]interface{}{"channel": personalChannel}))
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "subscribe unknown personal channel", middleware.WithTraceID(ctx, map[string]interface{}{"channel": personalChannel})))
 			return centrifuge.ConnectReply{}, centrifuge.ErrorUnknownChannel
 		}
+		inboxData, numInboxEntries := h.inboxData(ctx, credentials.UserID)
+		if numInboxEntries > 0 {
+			if newCtx == nil {
+				newCtx = ctx
+			}
+			newCtx = inbox.SetToContext(newCtx, numInboxEntries)
+		}
 		subscriptions[personalChannel] = centrifuge.SubscribeOptions{
 			Presence:  chOpts.Presence,
 			JoinLeave: chOpts.JoinLeave,
 			Recover:   chOpts.Recover,
+			Data:      inboxData,
 		}
 	}
 
@@ -319,11 +725,11 @@ func (h *Handler) OnClientConnecting(
 		for _, ch := range e.Channels {
 			chOpts, found, err := h.ruleContainer.ChannelOptions(ch)
 			if err != nil {
-				h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "channel options error", map[string]interface{}{"error": err.Error(), "channel": ch}))
+				h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "channel options error", middleware.WithTraceID(ctx, map[string]interface{}{"error": err.Error(), "channel": ch})))
 				return centrifuge.ConnectReply{}, err
 			}
 			if !found {
-				h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "subscribe unknown channel", map[string]interface{}{"channel": ch}))
+				h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "subscribe unknown channel", middleware.WithTraceID(ctx, map[string]interface{}{"channel": ch})))
 				return centrifuge.ConnectReply{}, centrifuge.DisconnectBadRequest
 			}
 
@@ -345,6 +751,13 @@ func (h *Handler) OnClientConnecting(
 				channelOk = true
 			}
 
+			if channelOk && !rule.RolesAllowed(chOpts.SubscribeRoles, roles) {
+				channelOk = false
+			}
+			if anonymousRestricted && (!chOpts.Public || isUserLimited || isPrivate) {
+				channelOk = false
+			}
+
 			if channelOk {
 				if _, ok := subscriptions[ch]; !ok {
 					subscriptions[ch] = centrifuge.SubscribeOptions{
@@ -355,28 +768,61 @@ func (h *Handler) OnClientConnecting(
 				}
 			} else {
 				if h.node.LogEnabled(centrifuge.LogLevelDebug) {
-					h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "ignoring subscription to a channel", map[string]interface{}{"channel": ch, "client": e.ClientID, "user": userID}))
+					h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "ignoring subscription to a channel", middleware.WithTraceID(ctx, map[string]interface{}{"channel": ch, "client": e.ClientID, "user": userID})))
 				}
 			}
 		}
 	}
 
+	if h.tenants.Enabled() {
+		for ch := range subscriptions {
+			if !h.tenants.ChannelAllowed(tenantName, ch) {
+				h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "ignoring subscription to a channel of another tenant", middleware.WithTraceID(ctx, map[string]interface{}{"channel": ch, "client": e.ClientID, "tenant": tenantName})))
+				delete(subscriptions, ch)
+			}
+		}
+	}
+
+	if credentials != nil && h.geoIPConnInfo {
+		// Copy since credentials may be shared with proxy reply.
+		creds := *credentials
+		creds.Info = h.geoConnInfo(ctx, creds.Info)
+		credentials = &creds
+	}
+
+	if h.affinityToken != "" {
+		// AddToData returns new slice so connect proxy reply data is kept intact.
+		data = affinity.AddToData(data, h.affinityToken)
+	}
+
+	sdk := sdkinfo.FromConnect(e.Name, e.Version, e.Data)
+	if sdk.Negotiated {
+		data = sdkinfo.AddToData(data)
+	}
+	sdkinfo.Observe(sdk)
+
 	finalReply := centrifuge.ConnectReply{
 		Credentials:       credentials,
 		Subscriptions:     subscriptions,
 		Data:              data,
 		ClientSideRefresh: !refreshProxyEnabled,
 	}
-	if newCtx != nil {
-		finalReply.Context = newCtx
+	if newCtx == nil {
+		newCtx = ctx
 	}
+	newCtx = sdkinfo.SetToContext(newCtx, sdk)
+	finalReply.Context = clientcontext.SetContextConnectTime(newCtx, time.Now())
 	return finalReply, nil
 }
 
 // OnRefresh ...
 func (h *Handler) OnRefresh(c *centrifuge.Client, e centrifuge.RefreshEvent, refreshProxyHandler proxy.RefreshHandlerFunc) (centrifuge.RefreshReply, error) {
 	if refreshProxyHandler != nil {
-		return refreshProxyHandler(c, e)
+		reply, err := refreshProxyHandler(c, e)
+		if err == nil && reply.Info != nil {
+			reply.Info = h.geoConnInfo(c.Context(), reply.Info)
+		}
+		return reply, err
 	}
 	token, err := h.tokenVerifier.VerifyConnectToken(e.Token)
 	if err != nil {
@@ -384,24 +830,32 @@ func (h *Handler) OnRefresh(c *centrifuge.Client, e centrifuge.RefreshEvent, ref
 			return centrifuge.RefreshReply{Expired: true}, nil
 		}
 		if errors.Is(err, jwtverify.ErrInvalidToken) {
-			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "invalid refresh token", map[string]interface{}{"error": err.Error(), "user": c.UserID(), "client": c.ID()}))
-			return centrifuge.RefreshReply{}, centrifuge.DisconnectInvalidToken
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "invalid refresh token", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "user": c.UserID(), "client": c.ID()})))
+			return centrifuge.RefreshReply{}, invalidTokenDisconnect(err)
 		}
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error verifying refresh token", map[string]interface{}{"error": err.Error(), "user": c.UserID(), "client": c.ID()}))
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error verifying refresh token", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "user": c.UserID(), "client": c.ID()})))
 		return centrifuge.RefreshReply{}, err
 	}
 	if token.UserID != c.UserID() {
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "refresh token has different user", map[string]interface{}{"tokenUser": token.UserID, "user": c.UserID(), "client": c.ID()}))
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "refresh token has different user", middleware.WithTraceID(c.Context(), map[string]interface{}{"tokenUser": token.UserID, "user": c.UserID(), "client": c.ID()})))
 		return centrifuge.RefreshReply{}, centrifuge.DisconnectInvalidToken
 	}
+	info := token.Info
+	if info != nil {
+		// New info replaces current one, keep client location in it.
+		info = h.geoConnInfo(c.Context(), info)
+	}
 	return centrifuge.RefreshReply{
 		ExpireAt: token.ExpireAt,
-		Info:     token.Info,
+		Info:     info,
 	}, nil
 }
 
 // OnRPC ...
 func (h *Handler) OnRPC(c *centrifuge.Client, e centrifuge.RPCEvent, rpcProxyHandler proxy.RPCHandlerFunc) (centrifuge.RPCReply, error) {
+	if isAnonymousRestricted(c) {
+		return centrifuge.RPCReply{}, centrifuge.ErrorPermissionDenied
+	}
 	if handler, ok := h.rpcExtension[e.Method]; ok {
 		return handler(c, e)
 	}
@@ -419,13 +873,13 @@ func (h *Handler) OnSubRefresh(c *centrifuge.Client, e centrifuge.SubRefreshEven
 			return centrifuge.SubRefreshReply{Expired: true}, nil
 		}
 		if errors.Is(err, jwtverify.ErrInvalidToken) {
-			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "invalid subscription refresh token", map[string]interface{}{"error": err.Error(), "client": c.ID(), "user": c.UserID()}))
-			return centrifuge.SubRefreshReply{}, centrifuge.DisconnectInvalidToken
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "invalid subscription refresh token", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "client": c.ID(), "user": c.UserID()})))
+			return centrifuge.SubRefreshReply{}, invalidTokenDisconnect(err)
 		}
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error verifying subscription refresh token", map[string]interface{}{"error": err.Error(), "client": c.ID(), "user": c.UserID()}))
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error verifying subscription refresh token", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "client": c.ID(), "user": c.UserID()})))
 		return centrifuge.SubRefreshReply{}, err
 	}
-	if c.ID() != token.Client || e.Channel != token.Channel {
+	if c.ID() != token.Client || e.Channel != token.Channel || token.Tenant != clientTenant(c) {
 		return centrifuge.SubRefreshReply{}, centrifuge.DisconnectInvalidToken
 	}
 	return centrifuge.SubRefreshReply{
@@ -436,37 +890,66 @@ func (h *Handler) OnSubRefresh(c *centrifuge.Client, e centrifuge.SubRefreshEven
 
 // OnSubscribe ...
 func (h *Handler) OnSubscribe(c *centrifuge.Client, e centrifuge.SubscribeEvent, subscribeProxyHandler proxy.SubscribeHandlerFunc) (centrifuge.SubscribeReply, error) {
+	if h.loadShedder != nil && !h.loadShedder.AllowSubscribe() {
+		return centrifuge.SubscribeReply{}, centrifuge.ErrorTooManyRequests
+	}
+
+	if channelLimit := h.limits.Config().ClientChannelLimit; channelLimit > 0 && len(c.Channels()) >= channelLimit {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "maximum limit of channels per client reached", middleware.WithTraceID(c.Context(), map[string]interface{}{"limit": channelLimit, "user": c.UserID(), "client": c.ID()})))
+		return centrifuge.SubscribeReply{}, centrifuge.ErrorLimitExceeded
+	}
+
 	ruleConfig := h.ruleContainer.Config()
 
 	chOpts, found, err := h.ruleContainer.ChannelOptions(e.Channel)
 	if err != nil {
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "subscribe channel options error", map[string]interface{}{"error": err.Error(), "channel": e.Channel, "user": c.UserID(), "client": c.ID()}))
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "subscribe channel options error", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
 		return centrifuge.SubscribeReply{}, err
 	}
 	if !found {
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "subscribe unknown channel", map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()}))
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "subscribe unknown channel", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
 		return centrifuge.SubscribeReply{}, centrifuge.ErrorUnknownChannel
 	}
 
-	if !chOpts.Anonymous && c.UserID() == "" && !ruleConfig.ClientInsecure {
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "anonymous user is not allowed to subscribe on channel", map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()}))
+	if !h.tenantChannelAllowed(c, e.Channel) {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "attempt to subscribe on channel of another tenant", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
+		return centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied
+	}
+
+	if isAnonymousRestricted(c) && !chOpts.Public {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "anonymous connection is not allowed to subscribe on non-public channel", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
+		return centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied
+	}
+
+	// Grant issued to connection allows subscribing regardless channel
+	// permission options, private channels still require subscription token.
+	channelGrant, granted := clientGrant(c, e.Channel)
+
+	if !chOpts.Anonymous && c.UserID() == "" && !ruleConfig.ClientInsecure && !granted {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "anonymous user is not allowed to subscribe on channel", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
 		return centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied
 	}
 
 	isUserLimited := h.ruleContainer.IsUserLimited(e.Channel)
 
 	if isUserLimited && !h.ruleContainer.UserAllowed(e.Channel, c.UserID()) {
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "user is not allowed to subscribe on channel", map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()}))
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "user is not allowed to subscribe on channel", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
+		return centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied
+	}
+
+	if !granted && !clientRolesAllowed(c, chOpts.SubscribeRoles) {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "connection has no role to subscribe on channel", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
 		return centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied
 	}
 
 	var options centrifuge.SubscribeOptions
+	var tokenCaps []string
 
 	isPrivateChannel := h.ruleContainer.IsPrivateChannel(e.Channel)
 
 	if isPrivateChannel {
 		if e.Token == "" {
-			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "subscription token required", map[string]interface{}{"client": c.ID(), "user": c.UserID()}))
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "subscription token required", middleware.WithTraceID(c.Context(), map[string]interface{}{"client": c.ID(), "user": c.UserID()})))
 			return centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied
 		}
 		token, err := h.tokenVerifier.VerifySubscribeToken(e.Token)
@@ -475,79 +958,260 @@ func (h *Handler) OnSubscribe(c *centrifuge.Client, e centrifuge.SubscribeEvent,
 				return centrifuge.SubscribeReply{}, centrifuge.ErrorTokenExpired
 			}
 			if errors.Is(err, jwtverify.ErrInvalidToken) {
-				h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "invalid subscription token", map[string]interface{}{"error": err.Error(), "client": c.ID(), "user": c.UserID()}))
+				h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "invalid subscription token", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "client": c.ID(), "user": c.UserID()})))
 				return centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied
 			}
-			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error verifying subscription token", map[string]interface{}{"error": err.Error(), "client": c.ID(), "user": c.UserID()}))
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error verifying subscription token", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "client": c.ID(), "user": c.UserID()})))
 			return centrifuge.SubscribeReply{}, err
 		}
-		if c.ID() != token.Client || e.Channel != token.Channel {
+		if c.ID() != token.Client || e.Channel != token.Channel || token.Tenant != clientTenant(c) {
 			return centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied
 		}
 		options = token.Options
+		tokenCaps = token.Caps
 	} else if (chOpts.ProxySubscribe || chOpts.SubscribeProxyName != "") && !h.ruleContainer.IsUserLimited(e.Channel) {
 		if subscribeProxyHandler == nil {
-			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "subscribe proxy not enabled", map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()}))
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "subscribe proxy not enabled", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
 			return centrifuge.SubscribeReply{}, centrifuge.ErrorNotAvailable
 		}
-		return subscribeProxyHandler(c, e, chOpts)
+		reply, err := subscribeProxyHandler(c, e, chOpts)
+		if err != nil {
+			return reply, err
+		}
+		if err := h.applySubscribeCaps(c, e.Channel, &reply.Options, nil); err != nil {
+			return centrifuge.SubscribeReply{}, err
+		}
+		return h.interceptSubscribe(c, e.Channel, reply)
 	} else {
 		options.Position = chOpts.Position
 		options.Recover = chOpts.Recover
 		options.Presence = chOpts.Presence
 		options.JoinLeave = chOpts.JoinLeave
+		if granted {
+			channelGrant.Apply(&options)
+		}
 	}
 
-	if chOpts.Protected && !isPrivateChannel && !isUserLimited {
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "attempt to subscribe on protected namespace channel", map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()}))
+	if chOpts.Protected && !isPrivateChannel && !isUserLimited && !granted {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "attempt to subscribe on protected namespace channel", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
 		return centrifuge.SubscribeReply{}, centrifuge.ErrorPermissionDenied
 	}
 
-	return centrifuge.SubscribeReply{
+	if chOpts.ChannelMetaOnSubscribe && h.metaStore != nil && len(options.Data) == 0 {
+		options.Data = h.channelMetaData(c, e.Channel)
+	}
+
+	if err := h.applySubscribeCaps(c, e.Channel, &options, tokenCaps); err != nil {
+		return centrifuge.SubscribeReply{}, err
+	}
+
+	return h.interceptSubscribe(c, e.Channel, centrifuge.SubscribeReply{
 		Options:           options,
 		ClientSideRefresh: true,
-	}, nil
+	})
+}
+
+// applySubscribeCaps narrows subscription options to capabilities client
+// requested for channel and sends enabled capabilities in subscribe data.
+// Options are already narrowed to tokenCaps by token verifier. Capabilities
+// sent if client or token requested them, or if SDK negotiated sub_caps
+// feature to get them for every subscription.
+func (h *Handler) applySubscribeCaps(c *centrifuge.Client, channel string, opts *centrifuge.SubscribeOptions, tokenCaps []string) error {
+	clientCaps, requested := h.subCaps.Get(c.ID(), channel)
+	if requested {
+		if err := subcaps.Narrow(opts, clientCaps); err != nil {
+			return err
+		}
+	}
+	if !requested && tokenCaps == nil {
+		sdk, ok := sdkinfo.FromContext(c.Context())
+		if !ok || !sdk.Supports(sdkinfo.FeatureSubscriptionCaps) {
+			return nil
+		}
+	}
+	return subcaps.Wrap(opts)
+}
+
+// interceptSubscribe calls subscribe interceptors for subscription allowed
+// by Centrifugo, interceptors can deny it or replace subscribe reply data.
+func (h *Handler) interceptSubscribe(c *centrifuge.Client, channel string, reply centrifuge.SubscribeReply) (centrifuge.SubscribeReply, error) {
+	if h.interceptors == nil {
+		return reply, nil
+	}
+	sub := &interceptor.Subscription{
+		Channel: channel,
+		Client:  c.ID(),
+		User:    c.UserID(),
+		Data:    reply.Options.Data,
+	}
+	if err := h.interceptors.BeforeSubscribe(c.Context(), sub); err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "subscription denied by interceptor", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": channel, "user": c.UserID(), "client": c.ID(), "error": err.Error()})))
+		return centrifuge.SubscribeReply{}, err
+	}
+	reply.Options.Data = sub.Data
+	return reply, nil
+}
+
+// channelMetaData returns channel meta entries encoded to JSON object. Errors
+// only logged since meta is not essential for subscription.
+func (h *Handler) channelMetaData(c *centrifuge.Client, channel string) []byte {
+	meta, err := h.metaStore.ChannelMeta(channel)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error getting channel meta", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "channel": channel, "user": c.UserID(), "client": c.ID()})))
+		return nil
+	}
+	if len(meta) == 0 {
+		return nil
+	}
+	values := make(map[string]json.RawMessage, len(meta))
+	for key, value := range meta {
+		values[key] = value
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error encoding channel meta", map[string]interface{}{"error": err.Error(), "channel": channel}))
+		return nil
+	}
+	return data
+}
+
+// inboxData returns subscribe data of personal channel with entries of user
+// inbox and number of entries. Entries stay in inbox until connection is
+// accepted. Nil returned if inbox is empty or not used.
+func (h *Handler) inboxData(ctx context.Context, user string) ([]byte, int) {
+	if h.inbox == nil || !h.ruleContainer.Config().UserPersonalInbox {
+		return nil, 0
+	}
+	entries, err := h.inbox.PeekInbox(user)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error reading user inbox", middleware.WithTraceID(ctx, map[string]interface{}{"error": err.Error(), "user": user})))
+		return nil, 0
+	}
+	if len(entries) == 0 {
+		return nil, 0
+	}
+	data, err := inbox.SubscribeData(entries)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error encoding user inbox", map[string]interface{}{"error": err.Error(), "user": user}))
+		return nil, 0
+	}
+	return data, len(entries)
+}
+
+// trimInbox removes inbox entries sent to accepted connection from inbox.
+func (h *Handler) trimInbox(client *centrifuge.Client) {
+	n, ok := inbox.FromContext(client.Context())
+	if !ok || n == 0 {
+		return
+	}
+	if err := h.inbox.TrimInbox(client.UserID(), n); err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error trimming user inbox", middleware.WithTraceID(client.Context(), map[string]interface{}{"error": err.Error(), "user": client.UserID(), "client": client.ID()})))
+	}
 }
 
 // OnPublish ...
 func (h *Handler) OnPublish(c *centrifuge.Client, e centrifuge.PublishEvent, publishProxyHandler proxy.PublishHandlerFunc) (centrifuge.PublishReply, error) {
+	if h.loadShedder != nil && !h.loadShedder.AllowPublish() {
+		return centrifuge.PublishReply{}, centrifuge.ErrorTooManyRequests
+	}
+
 	ruleConfig := h.ruleContainer.Config()
 
 	chOpts, found, err := h.ruleContainer.ChannelOptions(e.Channel)
 	if err != nil {
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "publish channel options error", map[string]interface{}{"error": err.Error(), "channel": e.Channel, "user": c.UserID(), "client": c.ID()}))
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "publish channel options error", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
 		return centrifuge.PublishReply{}, err
 	}
 	if !found {
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "publish to unknown channel", map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()}))
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "publish to unknown channel", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
 		return centrifuge.PublishReply{}, centrifuge.ErrorUnknownChannel
 	}
 
+	if !h.tenantChannelAllowed(c, e.Channel) {
+		return centrifuge.PublishReply{}, centrifuge.ErrorPermissionDenied
+	}
+
 	if !chOpts.Publish && !ruleConfig.ClientInsecure {
 		return centrifuge.PublishReply{}, centrifuge.ErrorPermissionDenied
 	}
 
+	if isAnonymousRestricted(c) {
+		return centrifuge.PublishReply{}, centrifuge.ErrorPermissionDenied
+	}
+
+	if !clientRolesAllowed(c, chOpts.PublishRoles) {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "connection has no role to publish into channel", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
+		return centrifuge.PublishReply{}, centrifuge.ErrorPermissionDenied
+	}
+
 	if chOpts.SubscribeToPublish {
 		if !c.IsSubscribed(e.Channel) {
 			return centrifuge.PublishReply{}, centrifuge.ErrorPermissionDenied
 		}
 	}
 
+	if chOpts.PublicationSizeLimit > 0 && len(e.Data) > chOpts.PublicationSizeLimit {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "publication size limit exceeded", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID(), "size": len(e.Data)})))
+		return centrifuge.PublishReply{}, &centrifuge.Error{
+			Code:    centrifuge.ErrorLimitExceeded.Code,
+			Message: "publication size limit exceeded",
+		}
+	}
+
+	if err := h.ruleContainer.ValidatePublicationData(chOpts, e.Data); err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "publication does not match schema", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID(), "error": err.Error()})))
+		return centrifuge.PublishReply{}, &centrifuge.Error{
+			Code:    centrifuge.ErrorBadRequest.Code,
+			Message: "invalid publication: " + err.Error(),
+		}
+	}
+
+	var pub *interceptor.Publication
+	if h.interceptors != nil {
+		pub = &interceptor.Publication{
+			Channel: e.Channel,
+			Data:    e.Data,
+			Source:  interceptor.SourceClient,
+			Info:    e.ClientInfo,
+		}
+		if err := h.interceptors.BeforePublish(c.Context(), pub); err != nil {
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "publication denied by interceptor", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID(), "error": err.Error()})))
+			return centrifuge.PublishReply{}, err
+		}
+		e.Data = pub.Data
+	}
+
 	if chOpts.ProxyPublish || chOpts.PublishProxyName != "" {
 		if publishProxyHandler == nil {
-			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "publish proxy not enabled", map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()}))
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "publish proxy not enabled", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
 			return centrifuge.PublishReply{}, centrifuge.ErrorNotAvailable
 		}
-		return publishProxyHandler(c, e, chOpts)
+		reply, err := publishProxyHandler(c, e, chOpts)
+		if pub != nil {
+			var sp centrifuge.StreamPosition
+			if reply.Result != nil {
+				sp = reply.Result.StreamPosition
+			}
+			h.interceptors.AfterPublish(c.Context(), *pub, sp, err)
+		}
+		return reply, err
+	}
+
+	data, err := h.wrapClientData(c, chOpts, e.Data)
+	if err != nil {
+		return centrifuge.PublishReply{}, centrifuge.ErrorBadRequest
 	}
 
-	result, err := h.node.Publish(
-		e.Channel, e.Data,
+	result, err := h.publisher.Publish(
+		e.Channel, data,
 		centrifuge.WithClientInfo(e.ClientInfo),
 		centrifuge.WithHistory(chOpts.HistorySize, time.Duration(chOpts.HistoryTTL)),
 	)
 	if err != nil {
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "publish error", map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID(), "error": err.Error()}))
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "publish error", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID(), "error": err.Error()})))
+	}
+	if pub != nil {
+		h.interceptors.AfterPublish(c.Context(), *pub, result.StreamPosition, err)
 	}
 	return centrifuge.PublishReply{Result: &result}, err
 }
@@ -556,19 +1220,28 @@ func (h *Handler) OnPublish(c *centrifuge.Client, e centrifuge.PublishEvent, pub
 func (h *Handler) OnPresence(c *centrifuge.Client, e centrifuge.PresenceEvent) (centrifuge.PresenceReply, error) {
 	chOpts, found, err := h.ruleContainer.ChannelOptions(e.Channel)
 	if err != nil {
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "presence channel options error", map[string]interface{}{"error": err.Error(), "channel": e.Channel, "user": c.UserID(), "client": c.ID()}))
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "presence channel options error", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
 		return centrifuge.PresenceReply{}, err
 	}
 	if !found {
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "presence for unknown channel", map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()}))
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "presence for unknown channel", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
 		return centrifuge.PresenceReply{}, centrifuge.ErrorUnknownChannel
 	}
+	if !h.tenantChannelAllowed(c, e.Channel) {
+		return centrifuge.PresenceReply{}, centrifuge.ErrorPermissionDenied
+	}
 	if !chOpts.Presence || chOpts.PresenceDisableForClient {
 		return centrifuge.PresenceReply{}, centrifuge.ErrorNotAvailable
 	}
 	if !c.IsSubscribed(e.Channel) {
 		return centrifuge.PresenceReply{}, centrifuge.ErrorPermissionDenied
 	}
+	if isAnonymousRestricted(c) && !chOpts.Public {
+		return centrifuge.PresenceReply{}, centrifuge.ErrorPermissionDenied
+	}
+	if !clientRolesAllowed(c, chOpts.PresenceRoles) {
+		return centrifuge.PresenceReply{}, centrifuge.ErrorPermissionDenied
+	}
 	return centrifuge.PresenceReply{}, nil
 }
 
@@ -576,19 +1249,28 @@ func (h *Handler) OnPresence(c *centrifuge.Client, e centrifuge.PresenceEvent) (
 func (h *Handler) OnPresenceStats(c *centrifuge.Client, e centrifuge.PresenceStatsEvent) (centrifuge.PresenceStatsReply, error) {
 	chOpts, found, err := h.ruleContainer.ChannelOptions(e.Channel)
 	if err != nil {
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "presence stats channel options error", map[string]interface{}{"error": err.Error(), "channel": e.Channel, "user": c.UserID(), "client": c.ID()}))
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "presence stats channel options error", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
 		return centrifuge.PresenceStatsReply{}, err
 	}
 	if !found {
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "presence stats for unknown channel", map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()}))
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "presence stats for unknown channel", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
 		return centrifuge.PresenceStatsReply{}, centrifuge.ErrorUnknownChannel
 	}
+	if !h.tenantChannelAllowed(c, e.Channel) {
+		return centrifuge.PresenceStatsReply{}, centrifuge.ErrorPermissionDenied
+	}
 	if !chOpts.Presence || chOpts.PresenceDisableForClient {
 		return centrifuge.PresenceStatsReply{}, centrifuge.ErrorNotAvailable
 	}
 	if !c.IsSubscribed(e.Channel) {
 		return centrifuge.PresenceStatsReply{}, centrifuge.ErrorPermissionDenied
 	}
+	if isAnonymousRestricted(c) && !chOpts.Public {
+		return centrifuge.PresenceStatsReply{}, centrifuge.ErrorPermissionDenied
+	}
+	if !clientRolesAllowed(c, chOpts.PresenceRoles) {
+		return centrifuge.PresenceStatsReply{}, centrifuge.ErrorPermissionDenied
+	}
 	return centrifuge.PresenceStatsReply{}, nil
 }
 
@@ -596,19 +1278,31 @@ func (h *Handler) OnPresenceStats(c *centrifuge.Client, e centrifuge.PresenceSta
 func (h *Handler) OnHistory(c *centrifuge.Client, e centrifuge.HistoryEvent) (centrifuge.HistoryReply, error) {
 	chOpts, found, err := h.ruleContainer.ChannelOptions(e.Channel)
 	if err != nil {
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "history channel options error", map[string]interface{}{"error": err.Error(), "channel": e.Channel, "user": c.UserID(), "client": c.ID()}))
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "history channel options error", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
 		return centrifuge.HistoryReply{}, err
 	}
 	if !found {
-		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "history for unknown channel", map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()}))
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "history for unknown channel", middleware.WithTraceID(c.Context(), map[string]interface{}{"channel": e.Channel, "user": c.UserID(), "client": c.ID()})))
 		return centrifuge.HistoryReply{}, centrifuge.ErrorUnknownChannel
 	}
+	if !h.tenantChannelAllowed(c, e.Channel) {
+		return centrifuge.HistoryReply{}, centrifuge.ErrorPermissionDenied
+	}
 	if chOpts.HistorySize <= 0 || chOpts.HistoryTTL <= 0 || chOpts.HistoryDisableForClient {
 		return centrifuge.HistoryReply{}, centrifuge.ErrorNotAvailable
 	}
 	if !c.IsSubscribed(e.Channel) {
 		return centrifuge.HistoryReply{}, centrifuge.ErrorPermissionDenied
 	}
+	if isAnonymousRestricted(c) && !chOpts.Public {
+		return centrifuge.HistoryReply{}, centrifuge.ErrorPermissionDenied
+	}
+	if !clientRolesAllowed(c, chOpts.HistoryRoles) {
+		return centrifuge.HistoryReply{}, centrifuge.ErrorPermissionDenied
+	}
+	if h.loadShedder != nil && !h.loadShedder.AllowHistory() {
+		return centrifuge.HistoryReply{}, centrifuge.ErrorTooManyRequests
+	}
 
 	if UseUnlimitedHistoryByDefault && e.Filter.Limit == 0 {
 		result, err := h.node.History(e.Channel, centrifuge.WithSince(e.Filter.Since), centrifuge.WithLimit(centrifuge.NoLimit))
@@ -622,3 +1316,364 @@ func (h *Handler) OnHistory(c *centrifuge.Client, e centrifuge.HistoryEvent) (ce
 
 	return centrifuge.HistoryReply{}, nil
 }
+
+// clientRolesAllowed checks that connection has one of allowed roles. Any
+// connection allowed if no roles required.
+func clientRolesAllowed(c *centrifuge.Client, allowedRoles []string) bool {
+	if len(allowedRoles) == 0 {
+		return true
+	}
+	roles, _ := clientcontext.GetContextConnectionRoles(c.Context())
+	return rule.RolesAllowed(allowedRoles, roles)
+}
+
+// clientGrant returns grant of connection matching channel.
+func clientGrant(c *centrifuge.Client, channel string) (grant.Grant, bool) {
+	ctx := c.Context()
+	if ctx == nil {
+		return grant.Grant{}, false
+	}
+	grants, _ := grant.FromContext(ctx)
+	return grants.Match(channel)
+}
+
+// tenantChannelAllowed checks that channel can be used by connection tenant.
+func (h *Handler) tenantChannelAllowed(c *centrifuge.Client, channel string) bool {
+	return h.tenants.ChannelAllowed(clientTenant(c), channel)
+}
+
+// invalidTokenDisconnect returns Disconnect for token which failed verification
+// with jwtverify.ErrInvalidToken, so clients can distinguish failure types.
+func invalidTokenDisconnect(err error) *centrifuge.Disconnect {
+	switch {
+	case errors.Is(err, jwtverify.ErrTokenNotYetValid):
+		return disconnect.TokenNotYetValid
+	case errors.Is(err, jwtverify.ErrInvalidAudience):
+		return disconnect.InvalidTokenAudience
+	case errors.Is(err, jwtverify.ErrInvalidIssuer):
+		return disconnect.InvalidTokenIssuer
+	default:
+		return centrifuge.DisconnectInvalidToken
+	}
+}
+
+// clientTenant returns tenant of connection, empty string for connections
+// without tenant.
+func clientTenant(c *centrifuge.Client) string {
+	ctx := c.Context()
+	if ctx == nil {
+		return ""
+	}
+	name, _ := tenant.FromContext(ctx)
+	return name
+}
+
+// isAnonymousRestricted returns true for connections accepted without credentials
+// in restricted anonymous mode.
+func isAnonymousRestricted(c *centrifuge.Client) bool {
+	ctx := c.Context()
+	if ctx == nil {
+		return false
+	}
+	return clientcontext.IsContextAnonymousRestricted(ctx)
+}
+
+// PresenceStateRPCMethod is an RPC method clients use to set state of
+// connection in channel presence.
+const PresenceStateRPCMethod = "$presence_state"
+
+type presenceStateRequest struct {
+	Channel string          `json:"channel"`
+	State   json.RawMessage `json:"state"`
+}
+
+type presenceStatePush struct {
+	PresenceState presenceStateUpdate `json:"presence_state"`
+}
+
+type presenceStateUpdate struct {
+	Client string          `json:"client"`
+	User   string          `json:"user"`
+	State  json.RawMessage `json:"state"`
+}
+
+// wrapClientData puts data of publication made on behalf of client into
+// publication envelope. Tags can only be set by server API or publish proxy.
+func (h *Handler) wrapClientData(c *centrifuge.Client, chOpts rule.ChannelOptions, data []byte) ([]byte, error) {
+	data, _, err := h.publisher.Wrap(chOpts, data, puborigin.Origin{Type: puborigin.TypeClient, User: c.UserID(), Client: c.ID()}, nil)
+	return data, err
+}
+
+func (h *Handler) onPresenceStateRPC(c *centrifuge.Client, e centrifuge.RPCEvent) (centrifuge.RPCReply, error) {
+	var req presenceStateRequest
+	if err := json.Unmarshal(e.Data, &req); err != nil || req.Channel == "" {
+		return centrifuge.RPCReply{}, centrifuge.ErrorBadRequest
+	}
+	if len(req.State) > presencestate.MaxStateSize {
+		return centrifuge.RPCReply{}, centrifuge.ErrorLimitExceeded
+	}
+
+	chOpts, found, err := h.ruleContainer.ChannelOptions(req.Channel)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "presence state channel options error", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "channel": req.Channel, "user": c.UserID(), "client": c.ID()})))
+		return centrifuge.RPCReply{}, err
+	}
+	if !found {
+		return centrifuge.RPCReply{}, centrifuge.ErrorUnknownChannel
+	}
+	if !chOpts.PresenceState {
+		return centrifuge.RPCReply{}, centrifuge.ErrorNotAvailable
+	}
+	if !c.IsSubscribed(req.Channel) {
+		return centrifuge.RPCReply{}, centrifuge.ErrorPermissionDenied
+	}
+
+	err = h.presenceStates.SetState(req.Channel, c.ID(), req.State)
+	if err != nil {
+		switch err {
+		case presencestate.ErrNotFound:
+			return centrifuge.RPCReply{}, centrifuge.ErrorPermissionDenied
+		case presencestate.ErrIncompatibleInfo:
+			return centrifuge.RPCReply{}, centrifuge.ErrorNotAvailable
+		}
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error setting presence state", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "channel": req.Channel, "user": c.UserID(), "client": c.ID()})))
+		return centrifuge.RPCReply{}, err
+	}
+
+	if chOpts.PresenceStateBroadcast {
+		state := req.State
+		if len(state) == 0 {
+			state = json.RawMessage("null")
+		}
+		data, _ := json.Marshal(presenceStatePush{PresenceState: presenceStateUpdate{
+			Client: c.ID(),
+			User:   c.UserID(),
+			State:  state,
+		}})
+		data, err = h.wrapClientData(c, chOpts, data)
+		if err == nil {
+			_, err = h.publisher.Publish(
+				req.Channel, data,
+				centrifuge.WithClientInfo(&centrifuge.ClientInfo{ClientID: c.ID(), UserID: c.UserID(), ConnInfo: c.Info()}),
+				centrifuge.WithHistory(chOpts.HistorySize, time.Duration(chOpts.HistoryTTL)),
+			)
+		}
+		if err != nil {
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error broadcasting presence state", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "channel": req.Channel, "user": c.UserID(), "client": c.ID()})))
+		}
+	}
+	return centrifuge.RPCReply{}, nil
+}
+
+const (
+	// ReadPositionRPCMethod is an RPC method clients use to set last read
+	// position in channel.
+	ReadPositionRPCMethod = "$read_position"
+	// ReadPositionsRPCMethod is an RPC method clients use to get own last
+	// read positions in channels.
+	ReadPositionsRPCMethod = "$read_positions"
+)
+
+type readPositionRequest struct {
+	Channel string `json:"channel"`
+	Offset  uint64 `json:"offset"`
+	Epoch   string `json:"epoch"`
+}
+
+type readPositionsRequest struct {
+	Channels []string `json:"channels"`
+}
+
+type readPosition struct {
+	Offset uint64 `json:"offset"`
+	Epoch  string `json:"epoch"`
+}
+
+type readPositionsResult struct {
+	Positions map[string]readPosition `json:"positions"`
+}
+
+func (h *Handler) onReadPositionRPC(c *centrifuge.Client, e centrifuge.RPCEvent) (centrifuge.RPCReply, error) {
+	if c.UserID() == "" {
+		return centrifuge.RPCReply{}, centrifuge.ErrorPermissionDenied
+	}
+	var req readPositionRequest
+	if err := json.Unmarshal(e.Data, &req); err != nil || req.Channel == "" {
+		return centrifuge.RPCReply{}, centrifuge.ErrorBadRequest
+	}
+	if !c.IsSubscribed(req.Channel) {
+		return centrifuge.RPCReply{}, centrifuge.ErrorPermissionDenied
+	}
+	err := h.readPositions.SetReadPosition(c.UserID(), req.Channel, centrifuge.StreamPosition{Offset: req.Offset, Epoch: req.Epoch})
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error setting read position", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "channel": req.Channel, "user": c.UserID(), "client": c.ID()})))
+		return centrifuge.RPCReply{}, centrifuge.ErrorInternal
+	}
+	return centrifuge.RPCReply{}, nil
+}
+
+func (h *Handler) onReadPositionsRPC(c *centrifuge.Client, e centrifuge.RPCEvent) (centrifuge.RPCReply, error) {
+	if c.UserID() == "" {
+		return centrifuge.RPCReply{}, centrifuge.ErrorPermissionDenied
+	}
+	var req readPositionsRequest
+	if err := json.Unmarshal(e.Data, &req); err != nil || len(req.Channels) == 0 {
+		return centrifuge.RPCReply{}, centrifuge.ErrorBadRequest
+	}
+	positions, err := h.readPositions.ReadPositions(c.UserID(), req.Channels)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error getting read positions", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "user": c.UserID(), "client": c.ID()})))
+		return centrifuge.RPCReply{}, centrifuge.ErrorInternal
+	}
+	result := readPositionsResult{Positions: make(map[string]readPosition, len(positions))}
+	for ch, sp := range positions {
+		result.Positions[ch] = readPosition{Offset: sp.Offset, Epoch: sp.Epoch}
+	}
+	data, _ := json.Marshal(result)
+	return centrifuge.RPCReply{Data: data}, nil
+}
+
+// SubscriptionFilterRPCMethod is an RPC method clients use to set filter of
+// publications by tags in channel subscription. Empty filter removes it.
+const SubscriptionFilterRPCMethod = "$subscription_filter"
+
+type subscriptionFilterRequest struct {
+	Channel string `json:"channel"`
+	Filter  string `json:"filter"`
+}
+
+func (h *Handler) onSubscriptionFilterRPC(c *centrifuge.Client, e centrifuge.RPCEvent) (centrifuge.RPCReply, error) {
+	var req subscriptionFilterRequest
+	if err := json.Unmarshal(e.Data, &req); err != nil || req.Channel == "" {
+		return centrifuge.RPCReply{}, centrifuge.ErrorBadRequest
+	}
+
+	chOpts, found, err := h.ruleContainer.ChannelOptions(req.Channel)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "subscription filter channel options error", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "channel": req.Channel, "user": c.UserID(), "client": c.ID()})))
+		return centrifuge.RPCReply{}, err
+	}
+	if !found {
+		return centrifuge.RPCReply{}, centrifuge.ErrorUnknownChannel
+	}
+	if !chOpts.PublicationTags {
+		return centrifuge.RPCReply{}, centrifuge.ErrorNotAvailable
+	}
+	if !c.IsSubscribed(req.Channel) {
+		return centrifuge.RPCReply{}, centrifuge.ErrorPermissionDenied
+	}
+	t, ok := c.Transport().(*pubtags.Transport)
+	if !ok {
+		return centrifuge.RPCReply{}, centrifuge.ErrorNotAvailable
+	}
+
+	var filter *pubtags.Filter
+	if req.Filter != "" {
+		filter, err = pubtags.ParseFilter(req.Filter)
+		if err != nil {
+			return centrifuge.RPCReply{}, centrifuge.ErrorBadRequest
+		}
+	}
+	t.SetFilter(req.Channel, filter)
+	return centrifuge.RPCReply{}, nil
+}
+
+// SubscriptionCapsRPCMethod is an RPC method clients use to request
+// capabilities of subscription to channel before subscribing. Request kept
+// until client disconnects and applied to every subscription to channel,
+// null caps remove it.
+const SubscriptionCapsRPCMethod = "$subscription_caps"
+
+type subscriptionCapsRequest struct {
+	Channel string   `json:"channel"`
+	Caps    []string `json:"caps"`
+}
+
+func (h *Handler) onSubscriptionCapsRPC(c *centrifuge.Client, e centrifuge.RPCEvent) (centrifuge.RPCReply, error) {
+	var req subscriptionCapsRequest
+	if err := json.Unmarshal(e.Data, &req); err != nil || req.Channel == "" {
+		return centrifuge.RPCReply{}, centrifuge.ErrorBadRequest
+	}
+	if err := subcaps.Validate(req.Caps); err != nil {
+		return centrifuge.RPCReply{}, centrifuge.ErrorBadRequest
+	}
+	if c.IsSubscribed(req.Channel) {
+		return centrifuge.RPCReply{}, centrifuge.ErrorAlreadySubscribed
+	}
+	if !h.subCaps.Set(c.ID(), req.Channel, req.Caps) {
+		return centrifuge.RPCReply{}, centrifuge.ErrorLimitExceeded
+	}
+	return centrifuge.RPCReply{}, nil
+}
+
+// PublishRPCMethod is an RPC method clients use to publish with
+// client-generated message ID. Result of publication kept for message ID,
+// retries with the same ID return original result without publishing again.
+const PublishRPCMethod = "$publish"
+
+type publishRequest struct {
+	Channel string          `json:"channel"`
+	Data    json.RawMessage `json:"data"`
+	ID      string          `json:"id"`
+}
+
+type publishResult struct {
+	Offset uint64 `json:"offset,omitempty"`
+	Epoch  string `json:"epoch,omitempty"`
+}
+
+func (h *Handler) onPublishRPC(c *centrifuge.Client, e centrifuge.RPCEvent, publishProxyHandler proxy.PublishHandlerFunc) (centrifuge.RPCReply, error) {
+	var req publishRequest
+	if err := json.Unmarshal(e.Data, &req); err != nil || req.Channel == "" || len(req.Data) == 0 || req.ID == "" {
+		return centrifuge.RPCReply{}, centrifuge.ErrorBadRequest
+	}
+	if len(req.ID) > pubdedup.MaxIDLength {
+		return centrifuge.RPCReply{}, centrifuge.ErrorBadRequest
+	}
+
+	publisher := c.UserID()
+	if publisher == "" {
+		// Anonymous publications can only be deduplicated within connection.
+		publisher = "#" + c.ID()
+	}
+	key := pubdedup.Key(publisher, req.Channel, req.ID)
+	r, exists, err := h.publishDedup.Reserve(key, h.publishPendingTTL)
+	if err != nil {
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error reserving publish message ID", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "channel": req.Channel, "user": c.UserID(), "client": c.ID()})))
+		return centrifuge.RPCReply{}, centrifuge.ErrorInternal
+	}
+	if exists {
+		if r.Pending {
+			// Publication with the same ID is in progress, client should retry later.
+			return centrifuge.RPCReply{}, centrifuge.ErrorTooManyRequests
+		}
+		data, _ := json.Marshal(publishResult{Offset: r.Offset, Epoch: r.Epoch})
+		return centrifuge.RPCReply{Data: data}, nil
+	}
+
+	reply, err := h.OnPublish(c, centrifuge.PublishEvent{
+		Channel:    req.Channel,
+		Data:       req.Data,
+		ClientInfo: &centrifuge.ClientInfo{ClientID: c.ID(), UserID: c.UserID(), ConnInfo: c.Info()},
+	}, publishProxyHandler)
+	h.nsMetrics.ObservePublish(nsmetrics.SourceClient, req.Channel, req.Data, err)
+	if err != nil {
+		if releaseErr := h.publishDedup.Release(key); releaseErr != nil {
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error releasing publish message ID", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": releaseErr.Error(), "channel": req.Channel, "user": c.UserID(), "client": c.ID()})))
+		}
+		return centrifuge.RPCReply{}, err
+	}
+
+	var result centrifuge.PublishResult
+	if reply.Result != nil {
+		result = *reply.Result
+	}
+	r = pubdedup.Result{Offset: result.Offset, Epoch: result.Epoch}
+	if err := h.publishDedup.Save(key, r, h.publishDedupTTL); err != nil {
+		// Publication already sent, retry with the same ID will get too many
+		// requests error until pending mark expires.
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error saving publish result", middleware.WithTraceID(c.Context(), map[string]interface{}{"error": err.Error(), "channel": req.Channel, "user": c.UserID(), "client": c.ID()})))
+	}
+	data, _ := json.Marshal(publishResult{Offset: r.Offset, Epoch: r.Epoch})
+	return centrifuge.RPCReply{Data: data}, nil
+}
@@ -4,11 +4,33 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/centrifugal/centrifugo/v3/internal/clientcontext"
+	"github.com/centrifugal/centrifugo/v3/internal/connlog"
+	"github.com/centrifugal/centrifugo/v3/internal/disconnect"
+	"github.com/centrifugal/centrifugo/v3/internal/errorcode"
+	"github.com/centrifugal/centrifugo/v3/internal/geoip"
+	"github.com/centrifugal/centrifugo/v3/internal/grant"
+	"github.com/centrifugal/centrifugo/v3/internal/inbox"
+	"github.com/centrifugal/centrifugo/v3/internal/interceptor"
 	"github.com/centrifugal/centrifugo/v3/internal/jwtverify"
+	"github.com/centrifugal/centrifugo/v3/internal/memengine"
+	"github.com/centrifugal/centrifugo/v3/internal/middleware"
+	"github.com/centrifugal/centrifugo/v3/internal/presencestate"
+	"github.com/centrifugal/centrifugo/v3/internal/pubdedup"
+	"github.com/centrifugal/centrifugo/v3/internal/pubid"
+	"github.com/centrifugal/centrifugo/v3/internal/publisher"
+	"github.com/centrifugal/centrifugo/v3/internal/puborigin"
+	"github.com/centrifugal/centrifugo/v3/internal/pubtags"
 	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/sdkinfo"
+	"github.com/centrifugal/centrifugo/v3/internal/tenant"
 	"github.com/centrifugal/centrifugo/v3/internal/tools"
 
 	"github.com/centrifugal/centrifuge"
@@ -110,6 +132,46 @@ func TestClientHandlerSetup(t *testing.T) {
 	require.True(t, ok)
 }
 
+func TestClientConnectionLog(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+	connLog := connlog.New(10)
+	h.SetConnectionLog(connLog)
+	require.NoError(t, h.Setup())
+
+	ctx := middleware.SetClientIPToContext(context.Background(), "10.0.0.1")
+
+	client, closeFn, err := centrifuge.NewClient(ctx, node, tools.NewTestTransport())
+	require.NoError(t, err)
+	client.Connect(centrifuge.ConnectRequest{Token: "bad bad token"})
+	_ = closeFn()
+
+	client, closeFn, err = centrifuge.NewClient(ctx, node, tools.NewTestTransport())
+	require.NoError(t, err)
+	client.Connect(centrifuge.ConnectRequest{Token: getConnTokenHS("42", 0)})
+	defer func() { _ = closeFn() }()
+	client.Disconnect(centrifuge.DisconnectForceNoReconnect)
+
+	require.Eventually(t, func() bool {
+		return len(connLog.Query(connlog.Filter{IP: "10.0.0.1"})) == 3
+	}, time.Second, 10*time.Millisecond)
+	events := connLog.Query(connlog.Filter{IP: "10.0.0.1"})
+	require.Equal(t, connlog.EventDisconnect, events[0].Type)
+	require.Equal(t, "42", events[0].User)
+	require.Equal(t, centrifuge.DisconnectForceNoReconnect.Code, events[0].Code)
+	require.Equal(t, connlog.EventConnect, events[1].Type)
+	require.Equal(t, client.ID(), events[1].Client)
+	require.Equal(t, "test_transport", events[1].Transport)
+	require.Equal(t, connlog.EventConnectFailed, events[2].Type)
+	require.Equal(t, centrifuge.DisconnectInvalidToken.Code, events[2].Code)
+}
+
 func TestClientConnectingNoCredentialsNoToken(t *testing.T) {
 	node := tools.NodeWithMemoryEngine()
 	defer func() { _ = node.Shutdown(context.Background()) }()
@@ -125,6 +187,84 @@ func TestClientConnectingNoCredentialsNoToken(t *testing.T) {
 	require.Nil(t, reply.Credentials)
 }
 
+func TestClientConnectingTokenFromContext(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{HMACSecretKey: "secret"}, ruleContainer), &ProxyMap{}, false)
+
+	ctx := clientcontext.SetContextConnectToken(context.Background(), getConnTokenHS("42", 0))
+	reply, err := h.OnClientConnecting(ctx, centrifuge.ConnectEvent{}, nil, false)
+	require.NoError(t, err)
+	require.NotNil(t, reply.Credentials)
+	require.Equal(t, "42", reply.Credentials.UserID)
+
+	// Token from connect command has priority.
+	reply, err = h.OnClientConnecting(ctx, centrifuge.ConnectEvent{Token: getConnTokenHS("43", 0)}, nil, false)
+	require.NoError(t, err)
+	require.Equal(t, "43", reply.Credentials.UserID)
+}
+
+func TestClientConnectingGeoConnInfo(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{HMACSecretKey: "secret"}, ruleContainer), &ProxyMap{}, false)
+
+	ctx := geoip.SetToContext(context.Background(), geoip.Location{Country: "SE"})
+	reply, err := h.OnClientConnecting(ctx, centrifuge.ConnectEvent{Token: getConnTokenHS("42", 0)}, nil, false)
+	require.NoError(t, err)
+	require.JSONEq(t, `{}`, string(reply.Credentials.Info))
+
+	h.SetGeoIP(nil, true)
+	reply, err = h.OnClientConnecting(ctx, centrifuge.ConnectEvent{Token: getConnTokenHS("42", 0)}, nil, false)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"geo":{"country":"SE"}}`, string(reply.Credentials.Info))
+}
+
+func TestClientConnectingAffinityToken(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.ClientInsecure = true
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{}, ruleContainer), &ProxyMap{}, false)
+	h.SetAffinityToken("node1")
+
+	reply, err := h.OnClientConnecting(context.Background(), centrifuge.ConnectEvent{}, nil, false)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"affinity":"node1"}`, string(reply.Data))
+}
+
+func TestClientConnectingSDKCapabilities(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.ClientInsecure = true
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{}, ruleContainer), &ProxyMap{}, false)
+
+	reply, err := h.OnClientConnecting(context.Background(), centrifuge.ConnectEvent{
+		Name:    "centrifuge-js",
+		Version: "2.8.1",
+		Data:    []byte(`{"sdk":{"features":["recovery"]}}`),
+	}, nil, false)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"sdk":{"features":["delta","recovery","binary","sub_caps"]}}`, string(reply.Data))
+	sdk, ok := sdkinfo.FromContext(reply.Context)
+	require.True(t, ok)
+	require.Equal(t, "centrifuge-js", sdk.Name)
+	require.Equal(t, []string{"recovery"}, sdk.Features)
+
+	reply, err = h.OnClientConnecting(context.Background(), centrifuge.ConnectEvent{Name: "centrifuge-js"}, nil, false)
+	require.NoError(t, err)
+	require.Nil(t, reply.Data)
+}
+
 func TestClientConnectingNoCredentialsNoTokenInsecure(t *testing.T) {
 	node := tools.NodeWithMemoryEngine()
 	defer func() { _ = node.Shutdown(context.Background()) }()
@@ -157,6 +297,579 @@ func TestClientConnectNoCredentialsNoTokenAnonymous(t *testing.T) {
 	require.Equal(t, "", reply.Credentials.UserID)
 }
 
+func TestClientConnectNoCredentialsNoTokenAnonymousRestricted(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.ClientAnonymousRestricted = true
+	ruleConfig.Namespaces = []rule.ChannelNamespace{
+		{Name: "public", ChannelOptions: rule.ChannelOptions{Public: true}},
+		{Name: "private"},
+	}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{}, ruleContainer), &ProxyMap{}, false)
+
+	reply, err := h.OnClientConnecting(context.Background(), centrifuge.ConnectEvent{
+		Channels: []string{"public:test", "private:test"},
+	}, nil, false)
+	require.NoError(t, err)
+
+	require.NotNil(t, reply.Credentials)
+	require.NotEqual(t, "", reply.Credentials.UserID)
+	require.True(t, clientcontext.IsContextAnonymousRestricted(reply.Context))
+	require.Len(t, reply.Subscriptions, 1)
+	require.Contains(t, reply.Subscriptions, "public:test")
+}
+
+func TestClientAnonymousRestricted(t *testing.T) {
+	node := tools.NodeWithMemoryEngineNoHandlers()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.ClientAnonymousRestricted = true
+	ruleConfig.Publish = true
+	ruleConfig.Namespaces = []rule.ChannelNamespace{
+		{Name: "public", ChannelOptions: rule.ChannelOptions{Public: true, Publish: true}},
+	}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{}, ruleContainer), &ProxyMap{}, false)
+
+	node.OnConnecting(func(ctx context.Context, e centrifuge.ConnectEvent) (centrifuge.ConnectReply, error) {
+		return h.OnClientConnecting(ctx, e, nil, false)
+	})
+
+	transport := tools.NewTestTransport()
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, transport)
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+
+	connectCommand := &protocol.Command{
+		Id: 1,
+	}
+	encoder := protocol.NewJSONCommandEncoder()
+	data, err := encoder.Encode(connectCommand)
+	require.NoError(t, err)
+	ok := client.Handle(data)
+	require.True(t, ok)
+	require.NotEqual(t, "", client.UserID())
+
+	_, err = h.OnSubscribe(client, centrifuge.SubscribeEvent{
+		Channel: "test",
+	}, nil)
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+
+	_, err = h.OnSubscribe(client, centrifuge.SubscribeEvent{
+		Channel: "public:test",
+	}, nil)
+	require.NoError(t, err)
+
+	_, err = h.OnPublish(client, centrifuge.PublishEvent{
+		Channel: "public:test",
+		Data:    []byte(`{}`),
+	}, nil)
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+
+	_, err = h.OnRPC(client, centrifuge.RPCEvent{Method: "test"}, nil)
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+}
+
+func TestClientPresenceStateRPC(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Namespaces = []rule.ChannelNamespace{{
+		Name: "state",
+		ChannelOptions: rule.ChannelOptions{
+			Presence:      true,
+			PresenceState: true,
+		},
+	}}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{}, ruleContainer), &ProxyMap{}, false)
+	pm, err := centrifuge.NewMemoryPresenceManager(node, centrifuge.MemoryPresenceManagerConfig{})
+	require.NoError(t, err)
+	h.SetPresenceStateManager(presencestate.New(pm, func(ch string) bool { return true }))
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+
+	call := func(data string) error {
+		_, err := h.OnRPC(client, centrifuge.RPCEvent{Method: PresenceStateRPCMethod, Data: []byte(data)}, nil)
+		return err
+	}
+	require.Equal(t, centrifuge.ErrorBadRequest, call(`{}`))
+	require.Equal(t, centrifuge.ErrorUnknownChannel, call(`{"channel":"unknown:test","state":1}`))
+	require.Equal(t, centrifuge.ErrorNotAvailable, call(`{"channel":"test","state":1}`))
+	require.Equal(t, centrifuge.ErrorPermissionDenied, call(`{"channel":"state:test","state":1}`))
+	require.Equal(t, centrifuge.ErrorLimitExceeded, call(`{"channel":"state:test","state":"`+strings.Repeat("x", presencestate.MaxStateSize)+`"}`))
+}
+
+func TestClientPresenceStateBroadcast(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Namespaces = []rule.ChannelNamespace{{
+		Name: "state",
+		ChannelOptions: rule.ChannelOptions{
+			Presence:               true,
+			PresenceState:          true,
+			PresenceStateBroadcast: true,
+			HistorySize:            10,
+			HistoryTTL:             tools.Duration(time.Minute),
+			PublicationOrigin:      true,
+		},
+	}}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+	pm, err := centrifuge.NewMemoryPresenceManager(node, centrifuge.MemoryPresenceManagerConfig{})
+	require.NoError(t, err)
+	states := presencestate.New(pm, func(ch string) bool { return true })
+	node.SetPresenceManager(states)
+	h.SetPresenceStateManager(states)
+	require.NoError(t, h.Setup())
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{Token: getConnTokenHS("42", 0)})
+	require.NoError(t, client.Subscribe("state:test", centrifuge.WithPresence(true)))
+
+	_, err = h.OnRPC(client, centrifuge.RPCEvent{Method: PresenceStateRPCMethod, Data: []byte(`{"channel":"state:test","state":{"typing":true}}`)}, nil)
+	require.NoError(t, err)
+
+	// Broadcast made on behalf of client like other client publications.
+	history, err := node.History("state:test", centrifuge.WithLimit(centrifuge.NoLimit))
+	require.NoError(t, err)
+	require.Len(t, history.Publications, 1)
+	require.Equal(t, client.ID(), history.Publications[0].Info.ClientID)
+	origin, data, ok := puborigin.Unwrap(history.Publications[0].Data)
+	require.True(t, ok)
+	require.Equal(t, puborigin.TypeClient, origin.Type)
+	require.JSONEq(t, `{"presence_state":{"client":"`+client.ID()+`","user":"42","state":{"typing":true}}}`, string(data))
+}
+
+func TestClientReadPositionRPC(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+	store := memengine.NewReadPositionStore()
+	h.SetReadPositionStore(store)
+	require.NoError(t, h.Setup())
+
+	anonymous, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	_, err = h.OnRPC(anonymous, centrifuge.RPCEvent{Method: ReadPositionsRPCMethod, Data: []byte(`{"channels":["test"]}`)}, nil)
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{Token: getConnTokenHS("42", 0)})
+
+	call := func(method string, data string) (centrifuge.RPCReply, error) {
+		return h.OnRPC(client, centrifuge.RPCEvent{Method: method, Data: []byte(data)}, nil)
+	}
+	_, err = call(ReadPositionRPCMethod, `{}`)
+	require.Equal(t, centrifuge.ErrorBadRequest, err)
+	_, err = call(ReadPositionRPCMethod, `{"channel":"test","offset":1,"epoch":"xyz"}`)
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+	_, err = call(ReadPositionsRPCMethod, `{}`)
+	require.Equal(t, centrifuge.ErrorBadRequest, err)
+
+	require.NoError(t, store.SetReadPosition("42", "test", centrifuge.StreamPosition{Offset: 3, Epoch: "xyz"}))
+	reply, err := call(ReadPositionsRPCMethod, `{"channels":["test","other"]}`)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"positions":{"test":{"offset":3,"epoch":"xyz"}}}`, string(reply.Data))
+}
+
+func TestClientSubscriptionFilterRPC(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Namespaces = []rule.ChannelNamespace{{
+		Name: "tags",
+		ChannelOptions: rule.ChannelOptions{
+			Publish:         true,
+			HistorySize:     10,
+			HistoryTTL:      tools.Duration(time.Minute),
+			PublicationTags: true,
+		},
+	}}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+	require.NoError(t, h.Setup())
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, pubtags.NewTransport(tools.NewTestTransport()))
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{Token: getConnTokenHS("42", 0)})
+
+	call := func(data string) error {
+		_, err := h.OnRPC(client, centrifuge.RPCEvent{Method: SubscriptionFilterRPCMethod, Data: []byte(data)}, nil)
+		return err
+	}
+	require.Equal(t, centrifuge.ErrorBadRequest, call(`{}`))
+	require.Equal(t, centrifuge.ErrorUnknownChannel, call(`{"channel":"unknown:test"}`))
+	require.Equal(t, centrifuge.ErrorNotAvailable, call(`{"channel":"test","filter":"tags.region == \"eu\""}`))
+	require.Equal(t, centrifuge.ErrorPermissionDenied, call(`{"channel":"tags:test","filter":"tags.region == \"eu\""}`))
+
+	require.NoError(t, client.Subscribe("tags:test"))
+	require.Equal(t, centrifuge.ErrorBadRequest, call(`{"channel":"tags:test","filter":"region"}`))
+	require.NoError(t, call(`{"channel":"tags:test","filter":"tags.region == \"eu\""}`))
+	require.NoError(t, call(`{"channel":"tags:test","filter":""}`))
+
+	_, err = h.OnPublish(client, centrifuge.PublishEvent{Channel: "tags:test", Data: []byte(`{"a":1}`)}, nil)
+	require.NoError(t, err)
+	history, err := node.History("tags:test", centrifuge.WithLimit(centrifuge.NoLimit))
+	require.NoError(t, err)
+	require.Len(t, history.Publications, 1)
+	require.JSONEq(t, `{"data":{"a":1}}`, string(history.Publications[0].Data))
+
+	other, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	other.Connect(centrifuge.ConnectRequest{Token: getConnTokenHS("42", 0)})
+	require.NoError(t, other.Subscribe("tags:test"))
+	_, err = h.OnRPC(other, centrifuge.RPCEvent{Method: SubscriptionFilterRPCMethod, Data: []byte(`{"channel":"tags:test"}`)}, nil)
+	require.Equal(t, centrifuge.ErrorNotAvailable, err)
+}
+
+func TestClientPublishInbox(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.UserPersonalInbox = true
+	ruleConfig.Publish = true
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+	store := memengine.NewInboxStore()
+	pub := publisher.New(node, ruleContainer)
+	pub.SetInbox(store, inbox.Options{Size: 10, TTL: time.Minute})
+	h.SetPublisher(pub)
+	require.NoError(t, h.Setup())
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{Token: getConnTokenHS("42", 0)})
+
+	_, err = h.OnPublish(client, centrifuge.PublishEvent{Channel: "#42", Data: []byte(`{"a":1}`)}, nil)
+	require.NoError(t, err)
+	_, err = h.OnPublish(client, centrifuge.PublishEvent{Channel: "test", Data: []byte(`{"a":2}`)}, nil)
+	require.NoError(t, err)
+	entries, err := store.PeekInbox("42")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, []byte(`{"a":1}`), entries[0].Data)
+}
+
+type testPushNotifier struct {
+	notified []string
+}
+
+func (n *testPushNotifier) Notify(user string, _ []byte) {
+	n.notified = append(n.notified, user)
+}
+
+func TestClientPublishPush(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Publish = true
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+	notifier := &testPushNotifier{}
+	pub := publisher.New(node, ruleContainer)
+	pub.SetPushNotifier(notifier)
+	h.SetPublisher(pub)
+	require.NoError(t, h.Setup())
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{Token: getConnTokenHS("42", 0)})
+
+	_, err = h.OnPublish(client, centrifuge.PublishEvent{Channel: "#42", Data: []byte(`{}`)}, nil)
+	require.NoError(t, err)
+	_, err = h.OnPublish(client, centrifuge.PublishEvent{Channel: "test", Data: []byte(`{}`)}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"42"}, notifier.notified)
+}
+
+func TestClientSubscriptionCapsRPC(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Presence = true
+	ruleConfig.JoinLeave = true
+	ruleConfig.HistorySize = 10
+	ruleConfig.HistoryTTL = tools.Duration(time.Minute)
+	ruleConfig.Recover = true
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+	require.NoError(t, h.Setup())
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{Token: getConnTokenHS("42", 0)})
+
+	call := func(data string) error {
+		_, err := h.OnRPC(client, centrifuge.RPCEvent{Method: SubscriptionCapsRPCMethod, Data: []byte(data)}, nil)
+		return err
+	}
+	require.Equal(t, centrifuge.ErrorBadRequest, call(`{}`))
+	require.Equal(t, centrifuge.ErrorBadRequest, call(`{"channel":"test","caps":["unknown"]}`))
+	require.Equal(t, centrifuge.ErrorBadRequest, call(`{"channel":"test","caps":["recover"]}`))
+	require.NoError(t, call(`{"channel":"test","caps":["position","recover","presence"]}`))
+
+	reply, err := h.OnSubscribe(client, centrifuge.SubscribeEvent{Channel: "test"}, nil)
+	require.NoError(t, err)
+	require.True(t, reply.Options.Recover)
+	require.True(t, reply.Options.Presence)
+	require.False(t, reply.Options.JoinLeave)
+	require.JSONEq(t, `{"caps":["position","recover","presence"]}`, string(reply.Options.Data))
+
+	// Channel without request subscribed with channel options as is.
+	reply, err = h.OnSubscribe(client, centrifuge.SubscribeEvent{Channel: "other"}, nil)
+	require.NoError(t, err)
+	require.True(t, reply.Options.JoinLeave)
+	require.Nil(t, reply.Options.Data)
+
+	require.NoError(t, client.Subscribe("other"))
+	require.Equal(t, centrifuge.ErrorAlreadySubscribed, call(`{"channel":"other","caps":[]}`))
+
+	// SDK negotiated sub_caps feature gets enabled capabilities of every
+	// subscription.
+	sdkClient, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	sdkClient.Connect(centrifuge.ConnectRequest{
+		Token: getConnTokenHS("42", 0),
+		Data:  []byte(`{"sdk":{"features":["sub_caps"]}}`),
+	})
+	reply, err = h.OnSubscribe(sdkClient, centrifuge.SubscribeEvent{Channel: "test"}, nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"caps":["position","recover","presence","join_leave"]}`, string(reply.Options.Data))
+}
+
+func TestClientPublishOrigin(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Namespaces = []rule.ChannelNamespace{{
+		Name: "origin",
+		ChannelOptions: rule.ChannelOptions{
+			Publish:           true,
+			HistorySize:       10,
+			HistoryTTL:        tools.Duration(time.Minute),
+			PublicationOrigin: true,
+		},
+	}}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+	require.NoError(t, h.Setup())
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{Token: getConnTokenHS("42", 0)})
+
+	_, err = h.OnPublish(client, centrifuge.PublishEvent{Channel: "origin:test", Data: []byte(`{`)}, nil)
+	require.Equal(t, centrifuge.ErrorBadRequest, err)
+	_, err = h.OnPublish(client, centrifuge.PublishEvent{Channel: "origin:test", Data: []byte(`{"a":1}`)}, nil)
+	require.NoError(t, err)
+
+	history, err := node.History("origin:test", centrifuge.WithLimit(centrifuge.NoLimit))
+	require.NoError(t, err)
+	require.Len(t, history.Publications, 1)
+	origin, data, ok := puborigin.Unwrap(history.Publications[0].Data)
+	require.True(t, ok)
+	require.Equal(t, puborigin.Origin{Type: puborigin.TypeClient, User: "42", Client: client.ID(), Node: node.ID()}, origin)
+	require.JSONEq(t, `{"a":1}`, string(data))
+}
+
+func TestClientPublishEnvelope(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Namespaces = []rule.ChannelNamespace{{
+		Name: "envelope",
+		ChannelOptions: rule.ChannelOptions{
+			Publish:           true,
+			HistorySize:       10,
+			HistoryTTL:        tools.Duration(time.Minute),
+			PublicationOrigin: true,
+			PublicationTags:   true,
+		},
+	}}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+	generator, err := pubid.NewSnowflakeGenerator(1)
+	require.NoError(t, err)
+	pub := publisher.New(node, ruleContainer)
+	pub.SetIDGenerator(generator)
+	h.SetPublisher(pub)
+	require.NoError(t, h.Setup())
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{Token: getConnTokenHS("42", 0)})
+
+	_, err = h.OnPublish(client, centrifuge.PublishEvent{Channel: "envelope:test", Data: []byte(`{"a":1}`)}, nil)
+	require.NoError(t, err)
+
+	history, err := node.History("envelope:test", centrifuge.WithLimit(centrifuge.NoLimit))
+	require.NoError(t, err)
+	require.Len(t, history.Publications, 1)
+	var e publisher.Envelope
+	require.NoError(t, json.Unmarshal(history.Publications[0].Data, &e))
+	require.NotEqual(t, "", e.ID)
+	require.Equal(t, &puborigin.Origin{Type: puborigin.TypeClient, User: "42", Client: client.ID(), Node: node.ID()}, e.Origin)
+	require.Nil(t, e.Tags)
+	require.JSONEq(t, `{"a":1}`, string(e.Data))
+}
+
+func TestClientPublishRPC(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Namespaces = []rule.ChannelNamespace{{
+		Name: "chat",
+		ChannelOptions: rule.ChannelOptions{
+			Publish:     true,
+			HistorySize: 10,
+			HistoryTTL:  tools.Duration(time.Minute),
+		},
+	}}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+	store := memengine.NewPublishDedupStore()
+	h.SetPublishDedupStore(store, time.Minute, time.Second)
+	require.NoError(t, h.Setup())
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{Token: getConnTokenHS("42", 0)})
+
+	call := func(data string) (centrifuge.RPCReply, error) {
+		return h.OnRPC(client, centrifuge.RPCEvent{Method: PublishRPCMethod, Data: []byte(data)}, nil)
+	}
+	_, err = call(`{"channel":"chat:index","data":{}}`)
+	require.Equal(t, centrifuge.ErrorBadRequest, err)
+	_, err = call(`{"channel":"chat:index","data":{},"id":"` + strings.Repeat("x", 129) + `"}`)
+	require.Equal(t, centrifuge.ErrorBadRequest, err)
+	_, err = call(`{"channel":"unknown:index","data":{},"id":"msg1"}`)
+	require.Equal(t, centrifuge.ErrorUnknownChannel, err)
+
+	reply, err := call(`{"channel":"chat:index","data":{"text":"hi"},"id":"msg1"}`)
+	require.NoError(t, err)
+	var result publishResult
+	require.NoError(t, json.Unmarshal(reply.Data, &result))
+	require.Equal(t, uint64(1), result.Offset)
+
+	retryReply, err := call(`{"channel":"chat:index","data":{"text":"hi"},"id":"msg1"}`)
+	require.NoError(t, err)
+	require.Equal(t, reply.Data, retryReply.Data)
+
+	// Failed publication can be retried with the same ID.
+	_, exists, err := store.Reserve(pubdedup.Key("42", "unknown:index", "msg1"), time.Minute)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	_, _, err = store.Reserve(pubdedup.Key("42", "chat:index", "msg2"), time.Minute)
+	require.NoError(t, err)
+	_, err = call(`{"channel":"chat:index","data":{},"id":"msg2"}`)
+	require.Equal(t, centrifuge.ErrorTooManyRequests, err)
+
+	history, err := node.History("chat:index", centrifuge.WithLimit(centrifuge.NoLimit))
+	require.NoError(t, err)
+	require.Len(t, history.Publications, 1)
+	require.Equal(t, "42", history.Publications[0].Info.UserID)
+}
+
+type ttlRecordingDedupStore struct {
+	pubdedup.Store
+	reserveTTL time.Duration
+	saveTTL    time.Duration
+}
+
+func (s *ttlRecordingDedupStore) Reserve(key string, ttl time.Duration) (pubdedup.Result, bool, error) {
+	s.reserveTTL = ttl
+	return s.Store.Reserve(key, ttl)
+}
+
+func (s *ttlRecordingDedupStore) Save(key string, r pubdedup.Result, ttl time.Duration) error {
+	s.saveTTL = ttl
+	return s.Store.Save(key, r, ttl)
+}
+
+func TestClientPublishRPCPendingTTL(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Publish = true
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+	store := &ttlRecordingDedupStore{Store: memengine.NewPublishDedupStore()}
+	h.SetPublishDedupStore(store, time.Minute, 5*time.Second)
+	require.NoError(t, h.Setup())
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{Token: getConnTokenHS("42", 0)})
+
+	_, err = h.OnRPC(client, centrifuge.RPCEvent{Method: PublishRPCMethod, Data: []byte(`{"channel":"test","data":{},"id":"msg1"}`)}, nil)
+	require.NoError(t, err)
+	// Pending mark kept only while publishing, result kept for full ttl.
+	require.Equal(t, 5*time.Second, store.reserveTTL)
+	require.Equal(t, time.Minute, store.saveTTL)
+
+	// Pending ttl never exceeds ttl.
+	h.SetPublishDedupStore(store, time.Second, 5*time.Second)
+	_, err = h.OnRPC(client, centrifuge.RPCEvent{Method: PublishRPCMethod, Data: []byte(`{"channel":"test","data":{},"id":"msg2"}`)}, nil)
+	require.NoError(t, err)
+	require.Equal(t, time.Second, store.reserveTTL)
+}
+
 func TestClientConnectWithMalformedToken(t *testing.T) {
 	node := tools.NodeWithMemoryEngine()
 	defer func() { _ = node.Shutdown(context.Background()) }()
@@ -372,6 +1085,44 @@ func TestClientUserPersonalChannel(t *testing.T) {
 	}
 }
 
+func TestClientUserPersonalInbox(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.UserSubscribeToPersonal = true
+	ruleConfig.UserPersonalInbox = true
+	ruleConfig.Presence = true
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+	store := memengine.NewInboxStore()
+	h.SetInbox(store)
+	require.NoError(t, h.Setup())
+
+	require.NoError(t, store.AddToInbox("42", inbox.Entry{Data: []byte(`{"a":1}`), Time: 1}, inbox.Options{TTL: time.Minute}))
+	reply, err := h.OnClientConnecting(context.Background(), centrifuge.ConnectEvent{
+		Token: getConnTokenHS("42", 0),
+	}, nil, false)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"inbox":[{"data":{"a":1},"time":1}]}`, string(reply.Subscriptions["#42"].Data))
+
+	// Entries kept in inbox until connection accepted.
+	entries, err := store.PeekInbox("42")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{Token: getConnTokenHS("42", 0)})
+
+	entries, err = store.PeekInbox("42")
+	require.NoError(t, err)
+	require.Len(t, entries, 0)
+}
+
 func TestClientSubscribeChannel(t *testing.T) {
 	node := tools.NodeWithMemoryEngineNoHandlers()
 	defer func() { _ = node.Shutdown(context.Background()) }()
@@ -410,6 +1161,45 @@ func TestClientSubscribeChannel(t *testing.T) {
 	require.Equal(t, centrifuge.ErrorUnknownChannel, err)
 }
 
+type testSubscribeInterceptor struct{}
+
+func (testSubscribeInterceptor) Name() string {
+	return "test"
+}
+
+func (testSubscribeInterceptor) BeforeSubscribe(_ context.Context, sub *interceptor.Subscription) error {
+	if sub.Channel == "denied" {
+		return centrifuge.ErrorPermissionDenied
+	}
+	sub.Data = []byte(`{"user":"` + sub.User + `"}`)
+	return nil
+}
+
+func TestClientSubscribeInterceptor(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+	chain := interceptor.NewChain()
+	require.NoError(t, chain.Register(testSubscribeInterceptor{}))
+	h.SetInterceptors(chain)
+	require.NoError(t, h.Setup())
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{Token: getConnTokenHS("42", 0)})
+
+	_, err = h.OnSubscribe(client, centrifuge.SubscribeEvent{Channel: "denied"}, nil)
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+	reply, err := h.OnSubscribe(client, centrifuge.SubscribeEvent{Channel: "test"}, nil)
+	require.NoError(t, err)
+	require.Equal(t, `{"user":"42"}`, string(reply.Options.Data))
+}
+
 func TestClientSubscribeChannelProtected(t *testing.T) {
 	node := tools.NodeWithMemoryEngineNoHandlers()
 	defer func() { _ = node.Shutdown(context.Background()) }()
@@ -740,83 +1530,187 @@ func TestClientSubscribePrivateChannelWithTokenAnonymousAllowed(t *testing.T) {
 		Channel: "$test1",
 		Token:   getSubscribeTokenHS("$test1", client.ID(), 0),
 	}, nil)
-	require.NoError(t, err)
-	require.Zero(t, reply.Options.ExpireAt)
+	require.NoError(t, err)
+	require.Zero(t, reply.Options.ExpireAt)
+}
+
+func TestClientSubscribePrivateChannelWithExpiringToken(t *testing.T) {
+	node := tools.NodeWithMemoryEngineNoHandlers()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Anonymous = true
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+
+	_, err := h.OnSubscribe(&centrifuge.Client{}, centrifuge.SubscribeEvent{
+		Channel: "$test1",
+		Token:   getSubscribeTokenHS("$test1", "id", 10),
+	}, nil)
+	require.Equal(t, centrifuge.ErrorTokenExpired, err)
+}
+
+func TestClientSubscribePermissionDeniedForAnonymous(t *testing.T) {
+	node := tools.NodeWithMemoryEngineNoHandlers()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+
+	_, err := h.OnSubscribe(&centrifuge.Client{}, centrifuge.SubscribeEvent{
+		Channel: "test1",
+	}, nil)
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+}
+
+func TestClientPublishNotAllowed(t *testing.T) {
+	node := tools.NodeWithMemoryEngineNoHandlers()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+
+	_, err := h.OnPublish(&centrifuge.Client{}, centrifuge.PublishEvent{
+		Channel: "non_existing_namespace:test1",
+		Data:    []byte(`{}`),
+	}, nil)
+	require.Equal(t, centrifuge.ErrorUnknownChannel, err)
+
+	_, err = h.OnPublish(&centrifuge.Client{}, centrifuge.PublishEvent{
+		Channel: "test1",
+		Data:    []byte(`{}`),
+	}, nil)
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
 }
 
-func TestClientSubscribePrivateChannelWithExpiringToken(t *testing.T) {
+func TestClientPublishAllowed(t *testing.T) {
 	node := tools.NodeWithMemoryEngineNoHandlers()
 	defer func() { _ = node.Shutdown(context.Background()) }()
 
 	ruleConfig := rule.DefaultConfig
-	ruleConfig.Anonymous = true
+	ruleConfig.Publish = true
 	ruleContainer := rule.NewContainer(ruleConfig)
 	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
 		HMACSecretKey: "secret",
 	}, ruleContainer), &ProxyMap{}, false)
 
-	_, err := h.OnSubscribe(&centrifuge.Client{}, centrifuge.SubscribeEvent{
-		Channel: "$test1",
-		Token:   getSubscribeTokenHS("$test1", "id", 10),
+	_, err := h.OnPublish(&centrifuge.Client{}, centrifuge.PublishEvent{
+		Channel: "test1",
+		Data:    []byte(`{}`),
 	}, nil)
-	require.Equal(t, centrifuge.ErrorTokenExpired, err)
+	require.NoError(t, err)
 }
 
-func TestClientSubscribePermissionDeniedForAnonymous(t *testing.T) {
+func TestClientPublishSchema(t *testing.T) {
 	node := tools.NodeWithMemoryEngineNoHandlers()
 	defer func() { _ = node.Shutdown(context.Background()) }()
 
 	ruleConfig := rule.DefaultConfig
+	ruleConfig.Publish = true
+	ruleConfig.PublicationSchema = `{"type": "object", "required": ["text"]}`
 	ruleContainer := rule.NewContainer(ruleConfig)
 	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
 		HMACSecretKey: "secret",
 	}, ruleContainer), &ProxyMap{}, false)
 
-	_, err := h.OnSubscribe(&centrifuge.Client{}, centrifuge.SubscribeEvent{
+	_, err := h.OnPublish(&centrifuge.Client{}, centrifuge.PublishEvent{
 		Channel: "test1",
+		Data:    []byte(`{}`),
 	}, nil)
-	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+	var clientErr *centrifuge.Error
+	require.ErrorAs(t, err, &clientErr)
+	require.Equal(t, centrifuge.ErrorBadRequest.Code, clientErr.Code)
+
+	_, err = h.OnPublish(&centrifuge.Client{}, centrifuge.PublishEvent{
+		Channel: "test1",
+		Data:    []byte(`{"text": "hello"}`),
+	}, nil)
+	require.NoError(t, err)
 }
 
-func TestClientPublishNotAllowed(t *testing.T) {
+func TestClientPublishSizeLimit(t *testing.T) {
 	node := tools.NodeWithMemoryEngineNoHandlers()
 	defer func() { _ = node.Shutdown(context.Background()) }()
 
 	ruleConfig := rule.DefaultConfig
+	ruleConfig.Publish = true
+	ruleConfig.PublicationSizeLimit = 8
 	ruleContainer := rule.NewContainer(ruleConfig)
 	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
 		HMACSecretKey: "secret",
 	}, ruleContainer), &ProxyMap{}, false)
 
 	_, err := h.OnPublish(&centrifuge.Client{}, centrifuge.PublishEvent{
-		Channel: "non_existing_namespace:test1",
-		Data:    []byte(`{}`),
+		Channel: "test1",
+		Data:    []byte(`{"text": "hello"}`),
 	}, nil)
-	require.Equal(t, centrifuge.ErrorUnknownChannel, err)
+	var clientErr *centrifuge.Error
+	require.ErrorAs(t, err, &clientErr)
+	require.Equal(t, centrifuge.ErrorLimitExceeded.Code, clientErr.Code)
 
 	_, err = h.OnPublish(&centrifuge.Client{}, centrifuge.PublishEvent{
 		Channel: "test1",
 		Data:    []byte(`{}`),
 	}, nil)
-	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+	require.NoError(t, err)
 }
 
-func TestClientPublishAllowed(t *testing.T) {
+type testMetaStore map[string]map[string][]byte
+
+func (s testMetaStore) ChannelMeta(ch string) (map[string][]byte, error) {
+	return s[ch], nil
+}
+
+func TestClientSubscribeChannelMeta(t *testing.T) {
 	node := tools.NodeWithMemoryEngineNoHandlers()
 	defer func() { _ = node.Shutdown(context.Background()) }()
 
 	ruleConfig := rule.DefaultConfig
-	ruleConfig.Publish = true
+	ruleConfig.ChannelMetaOnSubscribe = true
 	ruleContainer := rule.NewContainer(ruleConfig)
 	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
 		HMACSecretKey: "secret",
 	}, ruleContainer), &ProxyMap{}, false)
+	h.SetChannelMetaStore(testMetaStore{
+		"test1": {"topic": []byte(`"news"`)},
+	})
 
-	_, err := h.OnPublish(&centrifuge.Client{}, centrifuge.PublishEvent{
+	transport := tools.NewTestTransport()
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, transport)
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+
+	node.OnConnecting(func(ctx context.Context, event centrifuge.ConnectEvent) (centrifuge.ConnectReply, error) {
+		return centrifuge.ConnectReply{
+			Credentials: &centrifuge.Credentials{
+				UserID: "12",
+			},
+		}, nil
+	})
+
+	data, err := protocol.NewJSONCommandEncoder().Encode(&protocol.Command{Id: 1})
+	require.NoError(t, err)
+	require.True(t, client.Handle(data))
+
+	reply, err := h.OnSubscribe(client, centrifuge.SubscribeEvent{
 		Channel: "test1",
-		Data:    []byte(`{}`),
 	}, nil)
 	require.NoError(t, err)
+	require.JSONEq(t, `{"topic": "news"}`, string(reply.Options.Data))
+
+	reply, err = h.OnSubscribe(client, centrifuge.SubscribeEvent{
+		Channel: "test2",
+	}, nil)
+	require.NoError(t, err)
+	require.Nil(t, reply.Options.Data)
 }
 
 func TestClientSubscribeToPublish(t *testing.T) {
@@ -1000,3 +1894,290 @@ func TestClientPresenceStatsError(t *testing.T) {
 	})
 	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
 }
+
+func TestClientRoles(t *testing.T) {
+	node := tools.NodeWithMemoryEngineNoHandlers()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Namespaces = []rule.ChannelNamespace{
+		{
+			Name: "admin",
+			ChannelOptions: rule.ChannelOptions{
+				Publish:        true,
+				SubscribeRoles: []string{"admin"},
+				PublishRoles:   []string{"admin"},
+			},
+		},
+		{
+			Name: "news",
+			ChannelOptions: rule.ChannelOptions{
+				Publish:        true,
+				SubscribeRoles: []string{"admin", "reader"},
+				PublishRoles:   []string{"admin", "editor"},
+			},
+		},
+	}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+
+	transport := tools.NewTestTransport()
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, transport)
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+
+	node.OnConnecting(func(ctx context.Context, event centrifuge.ConnectEvent) (centrifuge.ConnectReply, error) {
+		return centrifuge.ConnectReply{
+			Context: clientcontext.SetContextConnectionRoles(ctx, []string{"reader", "editor"}),
+			Credentials: &centrifuge.Credentials{
+				UserID: "12",
+			},
+		}, nil
+	})
+
+	connectCommand := &protocol.Command{
+		Id: 1,
+	}
+	encoder := protocol.NewJSONCommandEncoder()
+	data, err := encoder.Encode(connectCommand)
+	require.NoError(t, err)
+	ok := client.Handle(data)
+	require.True(t, ok)
+
+	_, err = h.OnSubscribe(client, centrifuge.SubscribeEvent{
+		Channel: "admin:test",
+	}, nil)
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+
+	_, err = h.OnPublish(client, centrifuge.PublishEvent{
+		Channel: "admin:test",
+		Data:    []byte(`{}`),
+	}, nil)
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+
+	_, err = h.OnSubscribe(client, centrifuge.SubscribeEvent{
+		Channel: "news:test",
+	}, nil)
+	require.NoError(t, err)
+
+	_, err = h.OnPublish(client, centrifuge.PublishEvent{
+		Channel: "news:test",
+		Data:    []byte(`{}`),
+	}, nil)
+	require.NoError(t, err)
+}
+
+func TestClientSubscribeGrants(t *testing.T) {
+	node := tools.NodeWithMemoryEngineNoHandlers()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Namespaces = []rule.ChannelNamespace{
+		{
+			Name: "admin",
+			ChannelOptions: rule.ChannelOptions{
+				SubscribeRoles: []string{"admin"},
+			},
+		},
+		{
+			Name: "secret",
+			ChannelOptions: rule.ChannelOptions{
+				Protected: true,
+				Presence:  true,
+			},
+		},
+	}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+
+	signer, _ := jwt.NewSignerHS(jwt.HS256, []byte("secret"))
+	token, err := jwt.NewBuilder(signer).Build(&jwtverify.ConnectTokenClaims{
+		Grants: []grant.Grant{
+			{Channel: "admin:*"},
+			{Channel: "secret:allowed_*", Override: &grant.Override{
+				Presence: &grant.BoolValue{Value: false},
+				Recover:  &grant.BoolValue{Value: true},
+			}},
+			{Channel: "$private"},
+		},
+		StandardClaims: jwt.StandardClaims{Subject: "42"},
+	})
+	require.NoError(t, err)
+
+	connectReply, err := h.OnClientConnecting(context.Background(), centrifuge.ConnectEvent{
+		Token: token.String(),
+	}, nil, false)
+	require.NoError(t, err)
+
+	transport := tools.NewTestTransport()
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, transport)
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+
+	node.OnConnecting(func(ctx context.Context, event centrifuge.ConnectEvent) (centrifuge.ConnectReply, error) {
+		return connectReply, nil
+	})
+
+	connectCommand := &protocol.Command{
+		Id: 1,
+	}
+	encoder := protocol.NewJSONCommandEncoder()
+	data, err := encoder.Encode(connectCommand)
+	require.NoError(t, err)
+	ok := client.Handle(data)
+	require.True(t, ok)
+
+	_, err = h.OnSubscribe(client, centrifuge.SubscribeEvent{
+		Channel: "admin:test",
+	}, nil)
+	require.NoError(t, err)
+
+	reply, err := h.OnSubscribe(client, centrifuge.SubscribeEvent{
+		Channel: "secret:allowed_1",
+	}, nil)
+	require.NoError(t, err)
+	require.False(t, reply.Options.Presence)
+	require.True(t, reply.Options.Recover)
+
+	_, err = h.OnSubscribe(client, centrifuge.SubscribeEvent{
+		Channel: "secret:other",
+	}, nil)
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+
+	_, err = h.OnSubscribe(client, centrifuge.SubscribeEvent{
+		Channel: "$private",
+	}, nil)
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err, "grant must not skip private channel token check")
+
+	_, err = h.OnSubscribe(client, centrifuge.SubscribeEvent{
+		Channel: "$other",
+	}, nil)
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+}
+
+func tenantRuleContainerAndRegistry(t *testing.T) (*rule.Container, *tenant.Registry) {
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Namespaces = []rule.ChannelNamespace{{Name: "app1"}, {Name: "app2"}}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	tenants, err := tenant.NewRegistry([]tenant.Tenant{
+		{Name: "app1", Namespaces: []string{"app1"}, TokenHMACSecretKey: "app1_secret"},
+		{Name: "app2", Namespaces: []string{"app2"}, TokenHMACSecretKey: "app2_secret"},
+	}, ruleContainer.ChannelNamespace)
+	require.NoError(t, err)
+	return ruleContainer, tenants
+}
+
+func TestClientConnectingTenant(t *testing.T) {
+	node := tools.NodeWithMemoryEngineNoHandlers()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleContainer, tenants := tenantRuleContainerAndRegistry(t)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey:        "secret",
+		TenantHMACSecretKeys: map[string]string{"app1": "app1_secret"},
+	}, ruleContainer), &ProxyMap{}, false)
+	h.SetTenants(tenants)
+
+	signer, _ := jwt.NewSignerHS(jwt.HS256, []byte("app1_secret"))
+	token, err := jwt.NewBuilder(signer).Build(&jwtverify.ConnectTokenClaims{
+		Tenant:         "app1",
+		StandardClaims: jwt.StandardClaims{Subject: "42"},
+	})
+	require.NoError(t, err)
+
+	reply, err := h.OnClientConnecting(context.Background(), centrifuge.ConnectEvent{
+		Token:    token.String(),
+		Channels: []string{"app1:test", "app2:test", "test"},
+	}, nil, false)
+	require.NoError(t, err)
+	require.Len(t, reply.Subscriptions, 1)
+	require.Contains(t, reply.Subscriptions, "app1:test")
+	name, ok := tenant.FromContext(reply.Context)
+	require.True(t, ok)
+	require.Equal(t, "app1", name)
+
+	reply, err = h.OnClientConnecting(context.Background(), centrifuge.ConnectEvent{
+		Token:    getConnTokenHS("42", 0),
+		Channels: []string{"app1:test", "test"},
+	}, nil, false)
+	require.NoError(t, err)
+	require.Len(t, reply.Subscriptions, 1)
+	require.Contains(t, reply.Subscriptions, "test")
+}
+
+func TestClientSubscribeTenant(t *testing.T) {
+	node := tools.NodeWithMemoryEngineNoHandlers()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	ruleContainer, tenants := tenantRuleContainerAndRegistry(t)
+	h := NewHandler(node, ruleContainer, jwtverify.NewTokenVerifierJWT(jwtverify.VerifierConfig{
+		HMACSecretKey: "secret",
+	}, ruleContainer), &ProxyMap{}, false)
+	h.SetTenants(tenants)
+
+	transport := tools.NewTestTransport()
+	client, closeFn, err := centrifuge.NewClient(tenant.SetToContext(context.Background(), "app1"), node, transport)
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+
+	node.OnConnecting(func(ctx context.Context, event centrifuge.ConnectEvent) (centrifuge.ConnectReply, error) {
+		return centrifuge.ConnectReply{
+			Credentials: &centrifuge.Credentials{
+				UserID: "12",
+			},
+		}, nil
+	})
+
+	connectCommand := &protocol.Command{
+		Id: 1,
+	}
+	encoder := protocol.NewJSONCommandEncoder()
+	data, err := encoder.Encode(connectCommand)
+	require.NoError(t, err)
+	ok := client.Handle(data)
+	require.True(t, ok)
+
+	_, err = h.OnSubscribe(client, centrifuge.SubscribeEvent{
+		Channel: "app1:test",
+	}, nil)
+	require.NoError(t, err)
+
+	_, err = h.OnSubscribe(client, centrifuge.SubscribeEvent{
+		Channel: "app2:test",
+	}, nil)
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+
+	_, err = h.OnSubscribe(client, centrifuge.SubscribeEvent{
+		Channel: "test",
+	}, nil)
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+
+	_, err = h.OnPublish(client, centrifuge.PublishEvent{
+		Channel: "app2:test",
+		Data:    []byte(`{}`),
+	}, nil)
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+}
+
+func TestInvalidTokenDisconnect(t *testing.T) {
+	require.Equal(t, disconnect.TokenNotYetValid, invalidTokenDisconnect(jwtverify.ErrTokenNotYetValid))
+	require.Equal(t, disconnect.InvalidTokenAudience, invalidTokenDisconnect(jwtverify.ErrInvalidAudience))
+	require.Equal(t, disconnect.InvalidTokenIssuer, invalidTokenDisconnect(jwtverify.ErrInvalidIssuer))
+	require.Equal(t, centrifuge.DisconnectInvalidToken, invalidTokenDisconnect(fmt.Errorf("%w: bad signature", jwtverify.ErrInvalidToken)))
+}
+
+func TestClientError(t *testing.T) {
+	errMapped := errors.New("mapped")
+	errorcode.Map(errMapped, centrifuge.ErrorNotAvailable)
+
+	require.Nil(t, clientError(nil))
+	require.Equal(t, centrifuge.ErrorNotAvailable, clientError(fmt.Errorf("wrapped: %w", errMapped)))
+	require.Equal(t, centrifuge.ErrorPermissionDenied, clientError(centrifuge.ErrorPermissionDenied))
+	require.Equal(t, centrifuge.DisconnectBadRequest, clientError(centrifuge.DisconnectBadRequest))
+	errOther := errors.New("other")
+	require.Equal(t, errOther, clientError(errOther))
+}
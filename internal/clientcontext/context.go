@@ -3,6 +3,7 @@ package clientcontext
 import (
 	"context"
 	"encoding/json"
+	"time"
 )
 
 type ConnectionMeta struct {
@@ -23,3 +24,62 @@ func GetContextConnectionMeta(ctx context.Context) (ConnectionMeta, bool) {
 	}
 	return ConnectionMeta{}, false
 }
+
+type connectTimeContextKey struct{}
+
+func SetContextConnectTime(ctx context.Context, t time.Time) context.Context {
+	ctx = context.WithValue(ctx, connectTimeContextKey{}, t)
+	return ctx
+}
+
+func GetContextConnectTime(ctx context.Context) (time.Time, bool) {
+	if val := ctx.Value(connectTimeContextKey{}); val != nil {
+		values, ok := val.(time.Time)
+		return values, ok
+	}
+	return time.Time{}, false
+}
+
+type connectionRolesContextKey struct{}
+
+func SetContextConnectionRoles(ctx context.Context, roles []string) context.Context {
+	ctx = context.WithValue(ctx, connectionRolesContextKey{}, roles)
+	return ctx
+}
+
+func GetContextConnectionRoles(ctx context.Context) ([]string, bool) {
+	if val := ctx.Value(connectionRolesContextKey{}); val != nil {
+		values, ok := val.([]string)
+		return values, ok
+	}
+	return nil, false
+}
+
+type anonymousRestrictedContextKey struct{}
+
+func SetContextAnonymousRestricted(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, anonymousRestrictedContextKey{}, true)
+	return ctx
+}
+
+func IsContextAnonymousRestricted(ctx context.Context) bool {
+	val, _ := ctx.Value(anonymousRestrictedContextKey{}).(bool)
+	return val
+}
+
+type connectTokenContextKey struct{}
+
+// SetContextConnectToken sets connection token passed by transport outside of
+// connect command, for example in HTTP header of WebSocket upgrade request.
+func SetContextConnectToken(ctx context.Context, token string) context.Context {
+	ctx = context.WithValue(ctx, connectTokenContextKey{}, token)
+	return ctx
+}
+
+func GetContextConnectToken(ctx context.Context) (string, bool) {
+	if val := ctx.Value(connectTokenContextKey{}); val != nil {
+		values, ok := val.(string)
+		return values, ok
+	}
+	return "", false
+}
@@ -0,0 +1,140 @@
+// Package configcheck contains helpers to validate configuration more
+// thoroughly than required to start a node: it checks that referenced files
+// exist, that endpoints are well-formed and optionally that remote services
+// are reachable. All found problems are collected so they can be reported at
+// once.
+package configcheck
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Problem describes a single configuration problem.
+type Problem struct {
+	// Key is a configuration key problem relates to. Empty for problems
+	// which already mention keys in Message.
+	Key string
+	// Message describes what is wrong with value of Key.
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Key == "" {
+		return p.Message
+	}
+	return p.Key + ": " + p.Message
+}
+
+// Checker collects configuration problems.
+type Checker struct {
+	problems []Problem
+}
+
+// Addf adds problem for key.
+func (c *Checker) Addf(key string, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// Error adds problem for key if err is not nil.
+func (c *Checker) Error(key string, err error) {
+	if err != nil {
+		c.Addf(key, "%v", err)
+	}
+}
+
+// Problems returns all collected problems in order they were found.
+func (c *Checker) Problems() []Problem {
+	return c.problems
+}
+
+// UnknownKeys adds problem for every key which is not in known set. Nested keys
+// (separated by dot) are checked by their top-level part only since values of
+// known keys may be arbitrary objects.
+func (c *Checker) UnknownKeys(keys []string, known map[string]struct{}) {
+	unknown := map[string]struct{}{}
+	for _, key := range keys {
+		topLevelKey := strings.SplitN(key, ".", 2)[0]
+		if _, ok := known[topLevelKey]; !ok {
+			unknown[topLevelKey] = struct{}{}
+		}
+	}
+	names := make([]string, 0, len(unknown))
+	for key := range unknown {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		c.Addf(key, "unknown configuration key")
+	}
+}
+
+// File adds problem if path is set but does not point to a readable regular
+// file. Empty path is not checked – use Addf to report missing values.
+func (c *Checker) File(key string, path string) {
+	if path == "" {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.Addf(key, "file %s does not exist", path)
+		} else {
+			c.Addf(key, "can't access file %s: %v", path, err)
+		}
+		return
+	}
+	if info.IsDir() {
+		c.Addf(key, "%s is a directory, not a file", path)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		c.Addf(key, "can't read file %s: %v", path, err)
+		return
+	}
+	_ = f.Close()
+}
+
+// Endpoint adds problem if endpoint is set but malformed. HTTP endpoints must
+// be absolute URLs with http or https scheme, other endpoints are considered
+// GRPC and must be in host:port form.
+func (c *Checker) Endpoint(key string, endpoint string) {
+	if endpoint == "" {
+		return
+	}
+	if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			c.Addf(key, "malformed URL %s: %v", endpoint, err)
+			return
+		}
+		if u.Host == "" {
+			c.Addf(key, "no host in URL %s", endpoint)
+		}
+		return
+	}
+	if strings.Contains(endpoint, "://") {
+		c.Addf(key, "unsupported scheme in %s, expecting http://, https:// or GRPC host:port", endpoint)
+		return
+	}
+	if _, port, err := net.SplitHostPort(endpoint); err != nil || port == "" {
+		c.Addf(key, "malformed GRPC endpoint %s, expecting host:port", endpoint)
+	}
+}
+
+// Dial adds problem if connection to address can't be established within
+// timeout.
+func (c *Checker) Dial(key string, network string, address string, timeout time.Duration) {
+	conn, err := net.DialTimeout(network, address, timeout)
+	if err != nil {
+		c.Addf(key, "%s is not reachable: %v", address, err)
+		return
+	}
+	_ = conn.Close()
+}
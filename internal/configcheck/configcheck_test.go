@@ -0,0 +1,94 @@
+package configcheck
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func problemStrings(c *Checker) []string {
+	var result []string
+	for _, p := range c.Problems() {
+		result = append(result, p.String())
+	}
+	return result
+}
+
+func TestCheckerUnknownKeys(t *testing.T) {
+	c := &Checker{}
+	known := map[string]struct{}{"port": {}, "channel_groups": {}}
+	c.UnknownKeys([]string{"port", "channel_groups.x.channels", "prot", "zzz.a", "zzz.b"}, known)
+	require.Equal(t, []string{
+		"prot: unknown configuration key",
+		"zzz: unknown configuration key",
+	}, problemStrings(c))
+}
+
+func TestCheckerFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "configcheck")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "cert.pem")
+	require.NoError(t, ioutil.WriteFile(path, []byte("test"), 0600))
+
+	c := &Checker{}
+	c.File("tls_cert", "")
+	c.File("tls_cert", path)
+	require.Empty(t, c.Problems())
+
+	c.File("tls_key", filepath.Join(dir, "key.pem"))
+	c.File("tls_cert", dir)
+	require.Len(t, c.Problems(), 2)
+	require.Equal(t, "tls_key", c.Problems()[0].Key)
+	require.Contains(t, c.Problems()[0].Message, "does not exist")
+	require.Contains(t, c.Problems()[1].Message, "is a directory")
+}
+
+func TestCheckerEndpoint(t *testing.T) {
+	c := &Checker{}
+	c.Endpoint("proxy_connect_endpoint", "")
+	c.Endpoint("proxy_connect_endpoint", "http://localhost:3000/connect")
+	c.Endpoint("proxy_connect_endpoint", "https://example.com/connect")
+	c.Endpoint("proxy_connect_endpoint", "localhost:12000")
+	require.Empty(t, c.Problems())
+
+	c.Endpoint("proxy_rpc_endpoint", "http:///rpc")
+	c.Endpoint("proxy_rpc_endpoint", "ftp://localhost/rpc")
+	c.Endpoint("proxy_rpc_endpoint", "localhost")
+	require.Len(t, c.Problems(), 3)
+	require.Contains(t, c.Problems()[0].Message, "no host")
+	require.Contains(t, c.Problems()[1].Message, "unsupported scheme")
+	require.Contains(t, c.Problems()[2].Message, "expecting host:port")
+}
+
+func TestCheckerDial(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := ln.Addr().String()
+
+	c := &Checker{}
+	c.Dial("redis_address", "tcp", address, time.Second)
+	require.Empty(t, c.Problems())
+
+	require.NoError(t, ln.Close())
+	c.Dial("redis_address", "tcp", address, time.Second)
+	require.Len(t, c.Problems(), 1)
+	require.Contains(t, c.Problems()[0].Message, "is not reachable")
+}
+
+func TestCheckerError(t *testing.T) {
+	c := &Checker{}
+	c.Error("namespaces", nil)
+	c.Error("namespaces", errors.New("invalid namespace name"))
+	c.Error("", errors.New("namespace name must be unique: chat"))
+	require.Equal(t, []string{
+		"namespaces: invalid namespace name",
+		"namespace name must be unique: chat",
+	}, problemStrings(c))
+}
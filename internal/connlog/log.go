@@ -0,0 +1,109 @@
+// Package connlog keeps recent connection events of node in memory.
+package connlog
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType is a type of connection event.
+type EventType string
+
+const (
+	// EventConnect is logged when client successfully connected.
+	EventConnect EventType = "connect"
+	// EventConnectFailed is logged when connection was rejected (for example
+	// due to invalid token or connect proxy error).
+	EventConnectFailed EventType = "connect_failed"
+	// EventDisconnect is logged when connected client disconnected.
+	EventDisconnect EventType = "disconnect"
+)
+
+// Event of connection.
+type Event struct {
+	Time      time.Time
+	Type      EventType
+	Client    string
+	User      string
+	IP        string
+	Transport string
+	// Code and Reason of disconnect or connection rejection.
+	Code   uint32
+	Reason string
+}
+
+// Filter of events. Empty fields match all events.
+type Filter struct {
+	User   string
+	Client string
+	IP     string
+	// Limit is a max number of events to return, zero means no limit.
+	Limit int
+}
+
+func (f Filter) match(e Event) bool {
+	return (f.User == "" || f.User == e.User) &&
+		(f.Client == "" || f.Client == e.Client) &&
+		(f.IP == "" || f.IP == e.IP)
+}
+
+// Log is a bounded ring buffer of connection events. When full the oldest
+// event overwritten by a new one.
+type Log struct {
+	mu     sync.RWMutex
+	events []Event
+	next   int
+	full   bool
+}
+
+// New creates Log which keeps up to size events. Nil returned if size is not
+// positive, all Log methods can be safely called on nil Log.
+func New(size int) *Log {
+	if size <= 0 {
+		return nil
+	}
+	return &Log{events: make([]Event, size)}
+}
+
+// Add event to Log.
+func (l *Log) Add(e Event) {
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events[l.next] = e
+	l.next++
+	if l.next == len(l.events) {
+		l.next = 0
+		l.full = true
+	}
+}
+
+// Query returns events matching filter, newest first.
+func (l *Log) Query(f Filter) []Event {
+	if l == nil {
+		return nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	n := l.next
+	if l.full {
+		n = len(l.events)
+	}
+	var result []Event
+	for i := 1; i <= n; i++ {
+		e := l.events[(l.next-i+len(l.events))%len(l.events)]
+		if !f.match(e) {
+			continue
+		}
+		result = append(result, e)
+		if f.Limit > 0 && len(result) >= f.Limit {
+			break
+		}
+	}
+	return result
+}
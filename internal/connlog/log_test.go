@@ -0,0 +1,46 @@
+package connlog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogNil(t *testing.T) {
+	l := New(0)
+	require.Nil(t, l)
+	l.Add(Event{Type: EventConnect})
+	require.Len(t, l.Query(Filter{}), 0)
+}
+
+func TestLogRing(t *testing.T) {
+	l := New(3)
+	l.Add(Event{Type: EventConnect, Client: "1", User: "a"})
+	l.Add(Event{Type: EventConnect, Client: "2", User: "b"})
+	require.Len(t, l.Query(Filter{}), 2)
+
+	l.Add(Event{Type: EventDisconnect, Client: "1", User: "a", Code: 3001})
+	l.Add(Event{Type: EventConnectFailed, User: "a", IP: "127.0.0.1"})
+
+	events := l.Query(Filter{})
+	require.Len(t, events, 3)
+	require.Equal(t, EventConnectFailed, events[0].Type)
+	require.Equal(t, EventDisconnect, events[1].Type)
+	require.Equal(t, "2", events[2].Client)
+	require.False(t, events[0].Time.IsZero())
+}
+
+func TestLogQueryFilter(t *testing.T) {
+	l := New(10)
+	l.Add(Event{Type: EventConnect, Client: "1", User: "a", IP: "10.0.0.1"})
+	l.Add(Event{Type: EventConnect, Client: "2", User: "b", IP: "10.0.0.2"})
+	l.Add(Event{Type: EventDisconnect, Client: "1", User: "a", IP: "10.0.0.1"})
+
+	events := l.Query(Filter{User: "a"})
+	require.Len(t, events, 2)
+	require.Equal(t, EventDisconnect, events[0].Type)
+
+	require.Len(t, l.Query(Filter{Client: "2"}), 1)
+	require.Len(t, l.Query(Filter{IP: "10.0.0.1", Limit: 1}), 1)
+	require.Len(t, l.Query(Filter{User: "c"}), 0)
+}
@@ -0,0 +1,138 @@
+// Package connthrottle limits rate of client connection attempts processed by
+// node, so mass reconnect (for example after load balancer restart) does not
+// overload connect proxy and engine with subscriptions all at once.
+package connthrottle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/disconnect"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// Config of Throttle.
+type Config struct {
+	// Rate is a number of connection attempts per second node processes.
+	Rate int
+	// Burst is a number of connection attempts which can be processed at once
+	// above Rate. By default equals to Rate.
+	Burst int
+	// MaxWait is a max time connection attempt waits in queue for its turn.
+	// Attempts which can't be processed in MaxWait rejected immediately.
+	MaxWait time.Duration
+	// RetryMinDelay and RetryMaxDelay set a range of random delay clients
+	// advised to wait before next connection attempt in rejection.
+	RetryMinDelay time.Duration
+	RetryMaxDelay time.Duration
+}
+
+// Validate config.
+func (c Config) Validate() error {
+	if c.Rate <= 0 {
+		return errors.New("connect rate must be positive")
+	}
+	if c.Burst < 0 || c.MaxWait < 0 || c.RetryMinDelay < 0 {
+		return errors.New("connect burst, max wait and retry delay can't be negative")
+	}
+	if c.RetryMaxDelay < c.RetryMinDelay {
+		return errors.New("connect retry max delay must not be less than min delay")
+	}
+	return nil
+}
+
+// Throttle is a token bucket of connection attempts. Attempts which have no
+// token wait in queue until token available or reject if waiting would take
+// longer than MaxWait.
+type Throttle struct {
+	config Config
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	rand   *rand.Rand
+}
+
+// New creates Throttle.
+func New(c Config) (*Throttle, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if c.Burst == 0 {
+		c.Burst = c.Rate
+	}
+	return &Throttle{
+		config: c,
+		tokens: float64(c.Burst),
+		last:   time.Now(),
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// reserve takes token and returns time to wait before token is available.
+// Token is not taken and false returned if wait exceeds MaxWait.
+func (t *Throttle) reserve(now time.Time) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	elapsed := now.Sub(t.last)
+	if elapsed > 0 {
+		t.tokens += elapsed.Seconds() * float64(t.config.Rate)
+		if t.tokens > float64(t.config.Burst) {
+			t.tokens = float64(t.config.Burst)
+		}
+		t.last = now
+	}
+	tokens := t.tokens - 1
+	var wait time.Duration
+	if tokens < 0 {
+		wait = time.Duration(-tokens / float64(t.config.Rate) * float64(time.Second))
+	}
+	if wait > t.config.MaxWait {
+		return 0, false
+	}
+	t.tokens = tokens
+	return wait, true
+}
+
+// Wait blocks until connection attempt can be processed. Returns Disconnect
+// with code disconnect.ConnectRateLimit and retry advice in reason
+// ("connect rate limit, retry in <N>ms") if attempt must be rejected.
+func (t *Throttle) Wait(ctx context.Context) *centrifuge.Disconnect {
+	wait, ok := t.reserve(time.Now())
+	if !ok {
+		connectsRejected.Inc()
+		return t.disconnect()
+	}
+	if wait <= 0 {
+		return nil
+	}
+	connectsQueued.Inc()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return centrifuge.DisconnectNormal
+	}
+}
+
+func (t *Throttle) disconnect() *centrifuge.Disconnect {
+	delay := t.config.RetryMinDelay
+	if t.config.RetryMaxDelay > t.config.RetryMinDelay {
+		t.mu.Lock()
+		delay += time.Duration(t.rand.Int63n(int64(t.config.RetryMaxDelay - t.config.RetryMinDelay)))
+		t.mu.Unlock()
+	}
+	// Clients can parse retry advice from reason to spread reconnects.
+	return &centrifuge.Disconnect{
+		Code:      disconnect.ConnectRateLimit.Code,
+		Reason:    fmt.Sprintf("%s, retry in %dms", disconnect.ConnectRateLimit.Reason, delay.Milliseconds()),
+		Reconnect: disconnect.ConnectRateLimit.Reconnect,
+	}
+}
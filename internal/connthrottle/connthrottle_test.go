@@ -0,0 +1,59 @@
+package connthrottle
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/disconnect"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	require.NoError(t, Config{Rate: 10}.Validate())
+	require.Error(t, Config{}.Validate())
+	require.Error(t, Config{Rate: 10, Burst: -1}.Validate())
+	require.Error(t, Config{Rate: 10, RetryMinDelay: time.Second}.Validate())
+}
+
+func TestThrottleReserve(t *testing.T) {
+	th, err := New(Config{Rate: 10, Burst: 2, MaxWait: 200 * time.Millisecond})
+	require.NoError(t, err)
+	now := th.last
+
+	// Burst processed immediately.
+	for i := 0; i < 2; i++ {
+		wait, ok := th.reserve(now)
+		require.True(t, ok)
+		require.Zero(t, wait)
+	}
+	// Next attempts queued for 100ms and 200ms.
+	wait, ok := th.reserve(now)
+	require.True(t, ok)
+	require.Equal(t, 100*time.Millisecond, wait)
+	wait, ok = th.reserve(now)
+	require.True(t, ok)
+	require.Equal(t, 200*time.Millisecond, wait)
+	// Waiting longer than MaxWait rejected.
+	_, ok = th.reserve(now)
+	require.False(t, ok)
+
+	// Tokens restored with time.
+	wait, ok = th.reserve(now.Add(time.Second))
+	require.True(t, ok)
+	require.Zero(t, wait)
+}
+
+func TestThrottleWait(t *testing.T) {
+	th, err := New(Config{Rate: 1, Burst: 1, RetryMinDelay: time.Second, RetryMaxDelay: 2 * time.Second})
+	require.NoError(t, err)
+
+	require.Nil(t, th.Wait(context.Background()))
+	d := th.Wait(context.Background())
+	require.NotNil(t, d)
+	require.Equal(t, disconnect.ConnectRateLimit.Code, d.Code)
+	require.True(t, d.Reconnect)
+	require.True(t, strings.HasPrefix(d.Reason, "connect rate limit, retry in "))
+}
@@ -0,0 +1,27 @@
+package connthrottle
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricsNamespace = "centrifugo"
+
+var (
+	connectsQueued = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "client",
+		Name:      "connect_throttle_queued",
+		Help:      "Number of connection attempts waited in queue due to connect rate limit.",
+	})
+	connectsRejected = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "client",
+		Name:      "connect_throttle_rejected",
+		Help:      "Number of connection attempts rejected due to connect rate limit.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(connectsQueued)
+	prometheus.MustRegister(connectsRejected)
+}
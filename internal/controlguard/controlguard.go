@@ -0,0 +1,249 @@
+// Package controlguard counts control messages nodes exchange over broker and
+// suppresses control message storms, for example when misbehaving node
+// re-broadcasts control messages on handling them and floods control channel.
+package controlguard
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Defaults of Config.
+const (
+	DefaultRateLimit          = 10000
+	DefaultDuplicateLimit     = 20
+	DefaultSuppressionTimeout = 10 * time.Second
+)
+
+// ErrSuppressed returned by PublishControl when control message dropped.
+var ErrSuppressed = errors.New("controlguard: control message suppressed")
+
+// methodNode is a method of node info messages nodes periodically send to
+// discover each other. Node messages are never suppressed since otherwise
+// node is removed from other nodes registry.
+const methodNode = "node"
+
+// Directions of control messages.
+const (
+	directionSent     = "sent"
+	directionReceived = "received"
+)
+
+// Config of guard.
+type Config struct {
+	// RateLimit is a max number of control messages of one type from one node
+	// per second. Zero value means that DefaultRateLimit used, negative value
+	// turns off rate check.
+	RateLimit int
+	// DuplicateLimit is a max number of identical control messages per
+	// second. Identical messages repeated many times are a sign of a loop.
+	// Zero value means that DefaultDuplicateLimit used, negative value turns
+	// off duplicate check.
+	DuplicateLimit int
+	// SuppressionTimeout is a time control messages of one type from node
+	// dropped after limit exceeded. By default DefaultSuppressionTimeout used.
+	SuppressionTimeout time.Duration
+}
+
+// Broker wraps centrifuge.Broker to guard control messages sent and received
+// by node. Only control messages are affected.
+func Broker(node *centrifuge.Node, b centrifuge.Broker, c Config) centrifuge.Broker {
+	if c.RateLimit == 0 {
+		c.RateLimit = DefaultRateLimit
+	}
+	if c.DuplicateLimit == 0 {
+		c.DuplicateLimit = DefaultDuplicateLimit
+	}
+	if c.SuppressionTimeout == 0 {
+		c.SuppressionTimeout = DefaultSuppressionTimeout
+	}
+	return &broker{
+		Broker:   b,
+		sent:     newGuard(node, directionSent, c),
+		received: newGuard(node, directionReceived, c),
+	}
+}
+
+type broker struct {
+	centrifuge.Broker
+	sent     *guard
+	received *guard
+}
+
+// Run wraps event handler to guard received control messages.
+func (b *broker) Run(h centrifuge.BrokerEventHandler) error {
+	return b.Broker.Run(&eventHandler{BrokerEventHandler: h, guard: b.received})
+}
+
+// PublishControl drops control message if node sends too many of them,
+// ErrSuppressed returned in this case.
+func (b *broker) PublishControl(data []byte, nodeID, shardKey string) error {
+	if !b.sent.allow(data, time.Now()) {
+		return ErrSuppressed
+	}
+	return b.Broker.PublishControl(data, nodeID, shardKey)
+}
+
+// Close broker.
+func (b *broker) Close(ctx context.Context) error {
+	if closer, ok := b.Broker.(centrifuge.Closer); ok {
+		return closer.Close(ctx)
+	}
+	return nil
+}
+
+type eventHandler struct {
+	centrifuge.BrokerEventHandler
+	guard *guard
+}
+
+// HandleControl drops control message if its sender sends too many of them.
+func (h *eventHandler) HandleControl(data []byte) error {
+	if !h.guard.allow(data, time.Now()) {
+		return nil
+	}
+	return h.BrokerEventHandler.HandleControl(data)
+}
+
+type sourceKey struct {
+	node   string
+	method string
+}
+
+// guard counts control messages in one second windows.
+type guard struct {
+	node      *centrifuge.Node
+	direction string
+	config    Config
+
+	mu         sync.Mutex
+	window     int64
+	counts     map[sourceKey]int
+	duplicates map[uint64]int
+	suppressed map[sourceKey]time.Time
+}
+
+func newGuard(node *centrifuge.Node, direction string, c Config) *guard {
+	return &guard{
+		node:       node,
+		direction:  direction,
+		config:     c,
+		counts:     map[sourceKey]int{},
+		duplicates: map[uint64]int{},
+		suppressed: map[sourceKey]time.Time{},
+	}
+}
+
+func (g *guard) allow(data []byte, now time.Time) bool {
+	nodeID, method := decodeCommand(data)
+	messagesCount.WithLabelValues(g.direction, method).Inc()
+	if method == methodNode {
+		return true
+	}
+	key := sourceKey{node: nodeID, method: method}
+
+	g.mu.Lock()
+	if until, ok := g.suppressed[key]; ok {
+		if now.Before(until) {
+			g.mu.Unlock()
+			suppressedCount.WithLabelValues(g.direction, method).Inc()
+			return false
+		}
+		delete(g.suppressed, key)
+	}
+	if window := now.Unix(); window != g.window {
+		g.window = window
+		g.counts = map[sourceKey]int{}
+		g.duplicates = map[uint64]int{}
+	}
+	g.counts[key]++
+	var reason string
+	if g.config.RateLimit > 0 && g.counts[key] > g.config.RateLimit {
+		reason = "control message rate limit exceeded"
+	}
+	if g.config.DuplicateLimit > 0 {
+		h := fnv.New64a()
+		_, _ = h.Write(data)
+		sum := h.Sum64()
+		g.duplicates[sum]++
+		if g.duplicates[sum] > g.config.DuplicateLimit {
+			reason = "identical control messages repeated, possible control loop"
+		}
+	}
+	if reason != "" {
+		g.suppressed[key] = now.Add(g.config.SuppressionTimeout)
+	}
+	g.mu.Unlock()
+
+	if reason != "" {
+		suppressedCount.WithLabelValues(g.direction, method).Inc()
+		g.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, reason+", suppressing control messages", map[string]interface{}{
+			"direction": g.direction,
+			"node":      nodeID,
+			"method":    method,
+			"timeout":   g.config.SuppressionTimeout.String(),
+		}))
+		return false
+	}
+	return true
+}
+
+// Names of control command methods, indexes match method numbers of
+// Centrifuge control protocol.
+var methodNames = []string{
+	"node",
+	"unsubscribe",
+	"disconnect",
+	"shutdown",
+	"survey_request",
+	"survey_response",
+	"subscribe",
+	"notification",
+	"refresh",
+}
+
+// decodeCommand returns sender node ID and method name of Centrifuge control
+// command: uid is field 1 and method is field 2 of command message.
+func decodeCommand(data []byte) (string, string) {
+	var nodeID string
+	var method uint64
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nodeID, "unknown"
+		}
+		data = data[n:]
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return nodeID, "unknown"
+			}
+			nodeID = string(v)
+			data = data[n:]
+		case num == 2 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return nodeID, "unknown"
+			}
+			method = v
+			data = data[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return nodeID, "unknown"
+			}
+			data = data[n:]
+		}
+	}
+	if method >= uint64(len(methodNames)) {
+		return nodeID, "unknown"
+	}
+	return nodeID, methodNames[method]
+}
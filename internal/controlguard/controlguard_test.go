@@ -0,0 +1,100 @@
+package controlguard
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func encodeCommand(nodeID string, method uint64, params []byte) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, nodeID)
+	if method > 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, method)
+	}
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	b = protowire.AppendBytes(b, params)
+	return b
+}
+
+func TestDecodeCommand(t *testing.T) {
+	nodeID, method := decodeCommand(encodeCommand("node1", 0, []byte("x")))
+	require.Equal(t, "node1", nodeID)
+	require.Equal(t, "node", method)
+	nodeID, method = decodeCommand(encodeCommand("node2", 4, nil))
+	require.Equal(t, "node2", nodeID)
+	require.Equal(t, "survey_request", method)
+	_, method = decodeCommand(encodeCommand("node2", 100, nil))
+	require.Equal(t, "unknown", method)
+	_, method = decodeCommand([]byte{0xff})
+	require.Equal(t, "unknown", method)
+}
+
+func newTestGuard(t *testing.T, c Config) *guard {
+	node := tools.NodeWithMemoryEngine()
+	t.Cleanup(func() { _ = node.Shutdown(context.Background()) })
+	return newGuard(node, directionReceived, c)
+}
+
+func TestGuardRateLimit(t *testing.T) {
+	g := newTestGuard(t, Config{RateLimit: 3, DuplicateLimit: -1, SuppressionTimeout: 5 * time.Second})
+	now := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		require.True(t, g.allow(encodeCommand("node1", 2, []byte{byte(i)}), now))
+	}
+	require.False(t, g.allow(encodeCommand("node1", 2, []byte{3}), now))
+	// Other nodes and methods not affected.
+	require.True(t, g.allow(encodeCommand("node2", 2, []byte{3}), now))
+	require.True(t, g.allow(encodeCommand("node1", 1, []byte{3}), now))
+	// Suppressed in next windows until timeout passed.
+	require.False(t, g.allow(encodeCommand("node1", 2, []byte{4}), now.Add(time.Second)))
+	require.True(t, g.allow(encodeCommand("node1", 2, []byte{5}), now.Add(5*time.Second)))
+}
+
+func TestGuardDuplicateLimit(t *testing.T) {
+	g := newTestGuard(t, Config{RateLimit: -1, DuplicateLimit: 2, SuppressionTimeout: 5 * time.Second})
+	now := time.Unix(1000, 0)
+	data := encodeCommand("node1", 1, []byte("same"))
+	require.True(t, g.allow(data, now))
+	require.True(t, g.allow(data, now))
+	require.False(t, g.allow(data, now))
+	require.False(t, g.allow(encodeCommand("node1", 1, []byte("other")), now))
+}
+
+type testBroker struct {
+	centrifuge.Broker
+	published int
+}
+
+func (b *testBroker) PublishControl([]byte, string, string) error {
+	b.published++
+	return nil
+}
+
+func TestBrokerPublishControl(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+	tb := &testBroker{}
+	b := Broker(node, tb, Config{DuplicateLimit: 1})
+	data := encodeCommand("node1", 1, []byte("x"))
+	require.NoError(t, b.PublishControl(data, "", ""))
+	require.Equal(t, ErrSuppressed, b.PublishControl(data, "", ""))
+	require.Equal(t, 1, tb.published)
+}
+
+func TestGuardNodeMethodNotSuppressed(t *testing.T) {
+	g := newTestGuard(t, Config{RateLimit: 1, DuplicateLimit: 1, SuppressionTimeout: 5 * time.Second})
+	now := time.Unix(1000, 0)
+	data := encodeCommand("node1", 0, []byte("x"))
+	for i := 0; i < 3; i++ {
+		require.True(t, g.allow(data, now))
+	}
+}
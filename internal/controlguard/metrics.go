@@ -0,0 +1,27 @@
+package controlguard
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricsNamespace = "centrifugo"
+
+var (
+	messagesCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "control",
+		Name:      "messages_total",
+		Help:      "Number of control messages sent and received by node by type.",
+	}, []string{"direction", "type"})
+	suppressedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "control",
+		Name:      "suppressed_total",
+		Help:      "Number of control messages dropped due to control message storm.",
+	}, []string{"direction", "type"})
+)
+
+func init() {
+	prometheus.MustRegister(messagesCount)
+	prometheus.MustRegister(suppressedCount)
+}
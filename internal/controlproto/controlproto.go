@@ -0,0 +1,137 @@
+// Package controlproto contains versioned envelope of Centrifugo-level control
+// messages nodes exchange in cluster (survey requests and replies). Envelope
+// carries version of control protocol message encoded with, so nodes of
+// different Centrifugo versions can run in one cluster during rolling upgrade
+// without misinterpreting each other's messages.
+//
+// Envelope is a protobuf message prefixed with zero byte:
+//
+//	0x00 | Envelope{version: 1, data: 2}
+//
+// Requests are JSON or protobuf messages and neither can start with zero
+// byte, so requests without envelope are decoded as version 0 messages.
+// Replies may contain arbitrary binary data (HLL sketches for example), so
+// reply is wrapped into envelope only if request was, see DecodeReply.
+package controlproto
+
+import (
+	"errors"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Version of control protocol. Messages are wrapped into envelope starting
+// from version 2, nodes of version 1 advertise supported survey ops but
+// expect messages without envelope, nodes of version 0 do not advertise
+// anything.
+const Version uint32 = 2
+
+// MinEnvelopeVersion is a min version of control protocol which understands
+// envelope.
+const MinEnvelopeVersion uint32 = 2
+
+const envelopePrefix byte = 0x00
+
+// Envelope field numbers.
+const (
+	fieldVersion protowire.Number = 1
+	fieldData    protowire.Number = 2
+)
+
+// ErrMalformed returned when message has envelope prefix but envelope can't
+// be decoded.
+var ErrMalformed = errors.New("controlproto: malformed envelope")
+
+// Message of control protocol.
+type Message struct {
+	// Version message encoded with.
+	Version uint32
+	// Data of message.
+	Data []byte
+}
+
+// Encode Message. Messages of versions which do not understand envelope
+// returned as is.
+func Encode(m Message) []byte {
+	if m.Version < MinEnvelopeVersion {
+		return m.Data
+	}
+	b := []byte{envelopePrefix}
+	b = protowire.AppendTag(b, fieldVersion, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(m.Version))
+	if len(m.Data) > 0 {
+		b = protowire.AppendTag(b, fieldData, protowire.BytesType)
+		b = protowire.AppendBytes(b, m.Data)
+	}
+	return b
+}
+
+// Decode request Message. Data without envelope (sent by nodes of older
+// versions) decoded as message of version 0. Unknown envelope fields of newer
+// versions skipped.
+func Decode(data []byte) (Message, error) {
+	if len(data) == 0 || data[0] != envelopePrefix {
+		return Message{Data: data}, nil
+	}
+	return decodeEnvelope(data[1:])
+}
+
+// DecodeReply decodes reply Message to request encoded with requestVersion.
+func DecodeReply(requestVersion uint32, data []byte) (Message, error) {
+	if requestVersion < MinEnvelopeVersion {
+		return Message{Version: requestVersion, Data: data}, nil
+	}
+	if len(data) == 0 || data[0] != envelopePrefix {
+		return Message{}, ErrMalformed
+	}
+	return decodeEnvelope(data[1:])
+}
+
+func decodeEnvelope(b []byte) (Message, error) {
+	var m Message
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return Message{}, ErrMalformed
+		}
+		b = b[n:]
+		switch {
+		case num == fieldVersion && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return Message{}, ErrMalformed
+			}
+			m.Version = uint32(v)
+			b = b[n:]
+		case num == fieldData && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return Message{}, ErrMalformed
+			}
+			m.Data = v
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return Message{}, ErrMalformed
+			}
+			b = b[n:]
+		}
+	}
+	if m.Version < MinEnvelopeVersion {
+		return Message{}, ErrMalformed
+	}
+	return m, nil
+}
+
+// Negotiate returns version of control protocol to use for messages sent to
+// all nodes, i.e. min version among versions nodes support.
+func Negotiate(versions ...uint32) uint32 {
+	v := Version
+	for _, nodeVersion := range versions {
+		if nodeVersion < v {
+			v = nodeVersion
+		}
+	}
+	return v
+}
@@ -0,0 +1,72 @@
+package controlproto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	m := Message{Version: Version, Data: []byte(`{"channel":"test"}`)}
+	decoded, err := Decode(Encode(m))
+	require.NoError(t, err)
+	require.Equal(t, m, decoded)
+
+	decoded, err = Decode(Encode(Message{Version: Version}))
+	require.NoError(t, err)
+	require.Equal(t, Message{Version: Version}, decoded)
+}
+
+func TestEncodeDecodeLegacy(t *testing.T) {
+	// Nodes of older versions get messages without envelope.
+	data := []byte(`{"channel":"test"}`)
+	require.Equal(t, data, Encode(Message{Version: 1, Data: data}))
+
+	// Messages of older nodes without envelope decoded as version 0.
+	for _, data := range [][]byte{nil, []byte(`{"channel":"test"}`), {0x0a, 0x01, 0x61}} {
+		decoded, err := Decode(data)
+		require.NoError(t, err)
+		require.Equal(t, Message{Data: data}, decoded)
+	}
+}
+
+func TestDecodeReply(t *testing.T) {
+	// Replies may start with zero byte, so legacy reply is never sniffed.
+	data := []byte{0x00, 0x01}
+	decoded, err := DecodeReply(1, data)
+	require.NoError(t, err)
+	require.Equal(t, Message{Version: 1, Data: data}, decoded)
+
+	m := Message{Version: Version, Data: data}
+	decoded, err = DecodeReply(Version, Encode(m))
+	require.NoError(t, err)
+	require.Equal(t, m, decoded)
+
+	_, err = DecodeReply(Version, []byte("reply"))
+	require.Equal(t, ErrMalformed, err)
+}
+
+func TestDecodeUnknownFields(t *testing.T) {
+	b := Encode(Message{Version: Version + 1, Data: []byte("data")})
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	b = protowire.AppendBytes(b, []byte("new field"))
+	decoded, err := Decode(b)
+	require.NoError(t, err)
+	require.Equal(t, Message{Version: Version + 1, Data: []byte("data")}, decoded)
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	_, err := Decode([]byte{envelopePrefix, 0x08})
+	require.Equal(t, ErrMalformed, err)
+	// Envelope without version.
+	_, err = Decode([]byte{envelopePrefix})
+	require.Equal(t, ErrMalformed, err)
+}
+
+func TestNegotiate(t *testing.T) {
+	require.Equal(t, Version, Negotiate())
+	require.Equal(t, Version, Negotiate(Version, Version+1))
+	require.Equal(t, uint32(1), Negotiate(Version, 1))
+	require.Equal(t, uint32(0), Negotiate(0, Version))
+}
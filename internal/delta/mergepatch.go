@@ -0,0 +1,97 @@
+package delta
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+// createMergePatch creates JSON Merge Patch (RFC 7386) which transforms prev
+// document into cur document. Returns false if patch can't represent the
+// transformation – i.e. documents are not JSON objects or cur contains null
+// values inside objects (null in merge patch means key removal).
+func createMergePatch(prev, cur []byte) ([]byte, bool) {
+	prevDoc, ok := decodeObject(prev)
+	if !ok {
+		return nil, false
+	}
+	curDoc, ok := decodeObject(cur)
+	if !ok {
+		return nil, false
+	}
+	patch, ok := diffObjects(prevDoc, curDoc)
+	if !ok {
+		return nil, false
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func decodeObject(data []byte) (map[string]interface{}, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil || doc == nil {
+		return nil, false
+	}
+	return doc, true
+}
+
+func diffObjects(prev, cur map[string]interface{}) (map[string]interface{}, bool) {
+	patch := map[string]interface{}{}
+	for key, curValue := range cur {
+		if curValue == nil {
+			return nil, false
+		}
+		prevValue, ok := prev[key]
+		if !ok {
+			if !containsNoNull(curValue) {
+				return nil, false
+			}
+			patch[key] = curValue
+			continue
+		}
+		prevObject, prevIsObject := prevValue.(map[string]interface{})
+		curObject, curIsObject := curValue.(map[string]interface{})
+		if prevIsObject && curIsObject {
+			nested, ok := diffObjects(prevObject, curObject)
+			if !ok {
+				return nil, false
+			}
+			if len(nested) > 0 {
+				patch[key] = nested
+			}
+			continue
+		}
+		if !reflect.DeepEqual(prevValue, curValue) {
+			if !containsNoNull(curValue) {
+				return nil, false
+			}
+			patch[key] = curValue
+		}
+	}
+	for key := range prev {
+		if _, ok := cur[key]; !ok {
+			patch[key] = nil
+		}
+	}
+	return patch, true
+}
+
+// containsNoNull checks that value has no null values inside objects. Arrays
+// are replaced entirely by merge patch so nulls inside arrays are fine.
+func containsNoNull(value interface{}) bool {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return true
+	}
+	for _, v := range object {
+		if v == nil || !containsNoNull(v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package delta
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateMergePatch(t *testing.T) {
+	patch, ok := createMergePatch(
+		[]byte(`{"a":1,"b":{"c":"x","d":[1,2]},"e":true}`),
+		[]byte(`{"a":1,"b":{"c":"y","d":[1,2]},"f":2}`),
+	)
+	require.True(t, ok)
+	require.JSONEq(t, `{"b":{"c":"y"},"e":null,"f":2}`, string(patch))
+}
+
+func TestCreateMergePatchUnchanged(t *testing.T) {
+	patch, ok := createMergePatch([]byte(`{"a":1}`), []byte(`{"a":1}`))
+	require.True(t, ok)
+	require.JSONEq(t, `{}`, string(patch))
+}
+
+func TestCreateMergePatchFallback(t *testing.T) {
+	for _, tc := range []struct {
+		prev string
+		cur  string
+	}{
+		{`{"a":1}`, `[1,2]`},
+		{`"a"`, `{"a":1}`},
+		{`{"a":1}`, `{"a":null}`},
+		{`{"a":1}`, `{"a":{"b":null}}`},
+		{`{"a":1}`, `not json`},
+	} {
+		_, ok := createMergePatch([]byte(tc.prev), []byte(tc.cur))
+		require.False(t, ok, tc.cur)
+	}
+}
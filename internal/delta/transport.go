@@ -0,0 +1,198 @@
+package delta
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/protocol"
+)
+
+// TypeMergePatch is a delta type based on JSON Merge Patch (RFC 7386). Client
+// asks for it using delta=merge-patch URL query parameter on connection.
+const TypeMergePatch = "merge-patch"
+
+// maxCacheSize is a max number of channels to keep computed patches for.
+const maxCacheSize = 4096
+
+// Config of delta publications.
+type Config struct {
+	// Enabled reports whether delta publications are on for channel.
+	Enabled func(channel string) bool
+}
+
+type cacheEntry struct {
+	prev  []byte
+	cur   []byte
+	patch []byte
+}
+
+// Manager transforms publications into patches for connections which support
+// it. It caches last computed patch for every channel, so patch computed only
+// once for all connections subscribed to channel.
+//
+// For connections which asked for delta publication data in channels with delta
+// on is always an envelope object: either {"data": <full payload>} or
+// {"patch": <JSON Merge Patch>}. Patch must be applied to the payload of the
+// previous publication push in channel received over the same connection.
+// Publications coming inside subscribe replies (on recovery) do not change the
+// base payload. Payloads which are not JSON objects always sent in full.
+type Manager struct {
+	config Config
+	mu     sync.Mutex
+	cache  map[string]*cacheEntry
+}
+
+// NewManager creates Manager.
+func NewManager(config Config) *Manager {
+	return &Manager{
+		config: config,
+		cache:  map[string]*cacheEntry{},
+	}
+}
+
+// Requested returns true if client asked for delta publications.
+func Requested(r *http.Request) bool {
+	return r.URL.Query().Get("delta") == TypeMergePatch
+}
+
+// NewTransport wraps transport to send delta publications if client asked for
+// them. Original transport returned if delta not requested or can't be used
+// with transport.
+func (m *Manager) NewTransport(t centrifuge.Transport, r *http.Request) centrifuge.Transport {
+	if m == nil || m.config.Enabled == nil || !Requested(r) || t.Protocol() != centrifuge.ProtocolTypeJSON {
+		return t
+	}
+	return &transport{
+		Transport: t,
+		manager:   m,
+		base:      map[string][]byte{},
+	}
+}
+
+func (m *Manager) patch(channel string, prev, cur []byte) ([]byte, bool) {
+	m.mu.Lock()
+	entry, ok := m.cache[channel]
+	if ok && bytes.Equal(entry.prev, prev) && bytes.Equal(entry.cur, cur) {
+		m.mu.Unlock()
+		return entry.patch, entry.patch != nil
+	}
+	m.mu.Unlock()
+
+	patch, ok := createMergePatch(prev, cur)
+	if ok && len(patch) >= len(cur) {
+		// No sense to send patch larger than full payload.
+		patch, ok = nil, false
+	}
+
+	m.mu.Lock()
+	if _, exists := m.cache[channel]; !exists && len(m.cache) >= maxCacheSize {
+		for ch := range m.cache {
+			delete(m.cache, ch)
+			break
+		}
+	}
+	m.cache[channel] = &cacheEntry{prev: prev, cur: cur, patch: patch}
+	m.mu.Unlock()
+	return patch, ok
+}
+
+type transport struct {
+	centrifuge.Transport
+	manager *Manager
+
+	mu sync.Mutex
+	// base contains payload of last publication push sent in channel.
+	base map[string][]byte
+}
+
+// Write data to transport.
+func (t *transport) Write(data []byte) error {
+	return t.Transport.Write(t.transform(data))
+}
+
+// WriteMany data to transport.
+func (t *transport) WriteMany(messages ...[]byte) error {
+	transformed := make([][]byte, 0, len(messages))
+	for _, data := range messages {
+		transformed = append(transformed, t.transform(data))
+	}
+	return t.Transport.WriteMany(transformed...)
+}
+
+type jsonReply struct {
+	ID     uint32          `json:"id"`
+	Result json.RawMessage `json:"result"`
+}
+
+var (
+	fullPrefix  = []byte(`{"data":`)
+	patchPrefix = []byte(`{"patch":`)
+	suffix      = []byte(`}`)
+)
+
+func envelope(prefix []byte, data []byte) []byte {
+	result := make([]byte, 0, len(prefix)+len(data)+len(suffix))
+	result = append(result, prefix...)
+	result = append(result, data...)
+	return append(result, suffix...)
+}
+
+// transform returns data with publication payload replaced by envelope, data
+// returned as is if it's not a publication push in channel with delta on.
+func (t *transport) transform(data []byte) []byte {
+	pushData := data
+	if !t.Unidirectional() {
+		var reply jsonReply
+		if err := json.Unmarshal(data, &reply); err != nil || reply.ID > 0 {
+			return data
+		}
+		pushData = reply.Result
+	}
+	pushDecoder := protocol.NewJSONPushDecoder()
+	push, err := pushDecoder.Decode(pushData)
+	if err != nil || push.Type != protocol.Push_PUBLICATION || !t.manager.config.Enabled(push.Channel) {
+		return data
+	}
+	pub, err := pushDecoder.DecodePublication(push.Data)
+	if err != nil {
+		return data
+	}
+
+	payload := []byte(pub.Data)
+	t.mu.Lock()
+	prev, ok := t.base[push.Channel]
+	t.base[push.Channel] = payload
+	t.mu.Unlock()
+
+	var patch []byte
+	if ok {
+		patch, ok = t.manager.patch(push.Channel, prev, payload)
+	}
+	if ok {
+		pub.Data = envelope(patchPrefix, patch)
+	} else {
+		pub.Data = envelope(fullPrefix, payload)
+	}
+
+	pushEncoder := protocol.NewJSONPushEncoder()
+	pubData, err := pushEncoder.EncodePublication(pub)
+	if err != nil {
+		return data
+	}
+	push.Data = pubData
+	encoded, err := pushEncoder.Encode(push)
+	if err != nil {
+		return data
+	}
+	if t.Unidirectional() {
+		return encoded
+	}
+	encoded, err = protocol.NewJSONReplyEncoder().Encode(&protocol.Reply{Result: encoded})
+	if err != nil {
+		return data
+	}
+	return encoded
+}
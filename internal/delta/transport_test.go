@@ -0,0 +1,99 @@
+package delta
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+type testTransport struct {
+	mu           sync.Mutex
+	unidirection bool
+	protocol     centrifuge.ProtocolType
+	messages     [][]byte
+}
+
+func (t *testTransport) Name() string                         { return "test" }
+func (t *testTransport) Protocol() centrifuge.ProtocolType    { return t.protocol }
+func (t *testTransport) Unidirectional() bool                 { return t.unidirection }
+func (t *testTransport) DisabledPushFlags() uint64            { return 0 }
+func (t *testTransport) Write(data []byte) error              { return t.WriteMany(data) }
+func (t *testTransport) Close(_ *centrifuge.Disconnect) error { return nil }
+
+func (t *testTransport) WriteMany(messages ...[]byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = append(t.messages, messages...)
+	return nil
+}
+
+func (t *testTransport) written() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var result []string
+	for _, m := range t.messages {
+		result = append(result, string(m))
+	}
+	return result
+}
+
+func testManager() *Manager {
+	return NewManager(Config{Enabled: func(channel string) bool {
+		return channel == "delta"
+	}})
+}
+
+func TestManagerNewTransportNotRequested(t *testing.T) {
+	inner := &testTransport{protocol: centrifuge.ProtocolTypeJSON}
+	r := httptest.NewRequest("GET", "/connection/websocket", nil)
+	require.Equal(t, inner, testManager().NewTransport(inner, r))
+
+	var m *Manager
+	r = httptest.NewRequest("GET", "/connection/websocket?delta=merge-patch", nil)
+	require.Equal(t, inner, m.NewTransport(inner, r))
+}
+
+func TestManagerNewTransportProtobuf(t *testing.T) {
+	inner := &testTransport{protocol: centrifuge.ProtocolTypeProtobuf}
+	r := httptest.NewRequest("GET", "/connection/websocket?delta=merge-patch", nil)
+	require.Equal(t, inner, testManager().NewTransport(inner, r))
+}
+
+func TestTransportUnidirectional(t *testing.T) {
+	inner := &testTransport{protocol: centrifuge.ProtocolTypeJSON, unidirection: true}
+	r := httptest.NewRequest("GET", "/connection/uni_websocket?delta=merge-patch", nil)
+	tr := testManager().NewTransport(inner, r)
+
+	require.NoError(t, tr.Write([]byte(`{"channel":"delta","data":{"data":{"a":1,"b":"some long string"}}}`)))
+	require.NoError(t, tr.Write([]byte(`{"channel":"delta","data":{"data":{"a":2,"b":"some long string"}}}`)))
+	require.NoError(t, tr.Write([]byte(`{"channel":"other","data":{"data":{"a":3}}}`)))
+
+	written := inner.written()
+	require.Len(t, written, 3)
+	require.JSONEq(t, `{"channel":"delta","data":{"data":{"data":{"a":1,"b":"some long string"}}}}`, written[0])
+	require.JSONEq(t, `{"channel":"delta","data":{"data":{"patch":{"a":2}}}}`, written[1])
+	require.JSONEq(t, `{"channel":"other","data":{"data":{"a":3}}}`, written[2])
+}
+
+func TestTransportBidirectional(t *testing.T) {
+	inner := &testTransport{protocol: centrifuge.ProtocolTypeJSON}
+	r := httptest.NewRequest("GET", "/connection/websocket?delta=merge-patch", nil)
+	tr := testManager().NewTransport(inner, r)
+
+	require.NoError(t, tr.WriteMany(
+		[]byte(`{"id":1,"result":{}}`),
+		[]byte(`{"result":{"channel":"delta","data":{"data":{"a":1,"b":"some long string"}}}}`),
+		[]byte(`{"result":{"channel":"delta","data":{"data":{"a":1,"b":"some long string","c":true}}}}`),
+		[]byte(`{"result":{"channel":"delta","data":{"data":[1]}}}`),
+	))
+
+	written := inner.written()
+	require.Len(t, written, 4)
+	require.Equal(t, `{"id":1,"result":{}}`, written[0])
+	require.JSONEq(t, `{"result":{"channel":"delta","data":{"data":{"data":{"a":1,"b":"some long string"}}}}}`, written[1])
+	require.JSONEq(t, `{"result":{"channel":"delta","data":{"data":{"patch":{"c":true}}}}}`, written[2])
+	require.JSONEq(t, `{"result":{"channel":"delta","data":{"data":{"data":[1]}}}}`, written[3])
+}
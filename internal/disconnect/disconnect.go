@@ -0,0 +1,151 @@
+// Package disconnect contains registry of standard disconnect codes and
+// validation of custom application disconnect codes.
+package disconnect
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// Disconnect codes are split into ranges:
+// 3000-3999 reserved for standard codes sent by server,
+// 4000-4999 can be used by applications for custom disconnects, SDKs pass
+// them to application code as is.
+const (
+	StandardCodeMin uint32 = 3000
+	StandardCodeMax uint32 = 3999
+	AppCodeMin      uint32 = 4000
+	AppCodeMax      uint32 = 4999
+)
+
+// ErrInvalidCode returned when code does not belong to standard codes or
+// range of application codes.
+var ErrInvalidCode = errors.New("invalid disconnect code")
+
+// Standard codes starting from 3500 sent by Centrifugo itself.
+var (
+	// MessageSizeLimit sent when client sends message exceeding transport
+	// message size limit. Client should not reconnect since it will most
+	// probably send the same message again.
+	MessageSizeLimit = &centrifuge.Disconnect{
+		Code:      3500,
+		Reason:    "message size limit exceeded",
+		Reconnect: false,
+	}
+	// ConnectRateLimit sent when node rejects connection attempt due to rate
+	// of connection attempts. Reason may additionally contain retry advice.
+	ConnectRateLimit = &centrifuge.Disconnect{
+		Code:      3501,
+		Reason:    "connect rate limit",
+		Reconnect: true,
+	}
+	// TokenNotYetValid sent when token nbf claim is in future considering
+	// configured clock skew. Client can reconnect with the same token later.
+	TokenNotYetValid = &centrifuge.Disconnect{
+		Code:      3502,
+		Reason:    "token not yet valid",
+		Reconnect: true,
+	}
+	// InvalidTokenAudience sent when token issued for other audience.
+	InvalidTokenAudience = &centrifuge.Disconnect{
+		Code:      3503,
+		Reason:    "invalid token audience",
+		Reconnect: false,
+	}
+	// InvalidTokenIssuer sent when token issued by unexpected issuer.
+	InvalidTokenIssuer = &centrifuge.Disconnect{
+		Code:      3504,
+		Reason:    "invalid token issuer",
+		Reconnect: false,
+	}
+	// MemoryLimit sent when node rejects connection attempt since it sheds
+	// load due to heap soft limit. Reason may additionally contain retry
+	// advice.
+	MemoryLimit = &centrifuge.Disconnect{
+		Code:      3505,
+		Reason:    "memory limit",
+		Reconnect: true,
+	}
+)
+
+// Code describes standard disconnect code.
+type Code struct {
+	Code      uint32 `json:"code"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason"`
+	Reconnect bool   `json:"reconnect"`
+}
+
+var standardCodes = map[uint32]Code{}
+
+func register(name string, d *centrifuge.Disconnect) {
+	standardCodes[d.Code] = Code{Code: d.Code, Name: name, Reason: d.Reason, Reconnect: d.Reconnect}
+}
+
+func init() {
+	register("normal", centrifuge.DisconnectNormal)
+	register("shutdown", centrifuge.DisconnectShutdown)
+	register("invalid_token", centrifuge.DisconnectInvalidToken)
+	register("bad_request", centrifuge.DisconnectBadRequest)
+	register("server_error", centrifuge.DisconnectServerError)
+	register("expired", centrifuge.DisconnectExpired)
+	register("subscription_expired", centrifuge.DisconnectSubExpired)
+	register("stale", centrifuge.DisconnectStale)
+	register("slow", centrifuge.DisconnectSlow)
+	register("write_error", centrifuge.DisconnectWriteError)
+	register("insufficient_state", centrifuge.DisconnectInsufficientState)
+	register("force_reconnect", centrifuge.DisconnectForceReconnect)
+	register("force_disconnect", centrifuge.DisconnectForceNoReconnect)
+	// Channel limit disconnect uses the same code.
+	register("connection_limit", centrifuge.DisconnectConnectionLimit)
+	register("message_size_limit", MessageSizeLimit)
+	register("connect_rate_limit", ConnectRateLimit)
+	register("token_not_yet_valid", TokenNotYetValid)
+	register("invalid_token_audience", InvalidTokenAudience)
+	register("invalid_token_issuer", InvalidTokenIssuer)
+	register("memory_limit", MemoryLimit)
+}
+
+// StandardCodes returns all standard disconnect codes sorted by code.
+func StandardCodes() []Code {
+	codes := make([]Code, 0, len(standardCodes))
+	for _, c := range standardCodes {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i].Code < codes[j].Code
+	})
+	return codes
+}
+
+// Lookup returns standard disconnect code.
+func Lookup(code uint32) (Code, bool) {
+	c, ok := standardCodes[code]
+	return c, ok
+}
+
+// IsAppCode reports whether code belongs to range of application codes.
+func IsAppCode(code uint32) bool {
+	return code >= AppCodeMin && code <= AppCodeMax
+}
+
+// New validates code and builds Disconnect. Standard code with empty reason
+// gets reason of registered code. Codes from standard range which are not
+// registered and codes outside of standard and application ranges are not
+// allowed.
+func New(code uint32, reason string, reconnect bool) (*centrifuge.Disconnect, error) {
+	if c, ok := Lookup(code); ok {
+		if reason == "" {
+			reason = c.Reason
+		}
+	} else if !IsAppCode(code) {
+		return nil, ErrInvalidCode
+	}
+	return &centrifuge.Disconnect{
+		Code:      code,
+		Reason:    reason,
+		Reconnect: reconnect,
+	}, nil
+}
@@ -0,0 +1,46 @@
+package disconnect
+
+import (
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	d, err := New(centrifuge.DisconnectForceReconnect.Code, "", true)
+	require.NoError(t, err)
+	require.Equal(t, centrifuge.DisconnectForceReconnect.Reason, d.Reason)
+	require.True(t, d.Reconnect)
+
+	d, err = New(3012, "banned", false)
+	require.NoError(t, err)
+	require.Equal(t, "banned", d.Reason)
+
+	d, err = New(4001, "payment required", false)
+	require.NoError(t, err)
+	require.Equal(t, uint32(4001), d.Code)
+	require.Equal(t, "payment required", d.Reason)
+
+	for _, code := range []uint32{0, 1000, 2999, 3999, 5000} {
+		_, err = New(code, "", false)
+		require.Equal(t, ErrInvalidCode, err, code)
+	}
+}
+
+func TestStandardCodes(t *testing.T) {
+	codes := StandardCodes()
+	require.NotEmpty(t, codes)
+	for i, c := range codes {
+		require.True(t, c.Code >= StandardCodeMin && c.Code <= StandardCodeMax)
+		require.NotEmpty(t, c.Name)
+		if i > 0 {
+			require.True(t, codes[i-1].Code < c.Code)
+		}
+	}
+	c, ok := Lookup(3000)
+	require.True(t, ok)
+	require.Equal(t, "normal", c.Name)
+	_, ok = Lookup(4000)
+	require.False(t, ok)
+}
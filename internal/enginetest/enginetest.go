@@ -0,0 +1,434 @@
+// Package enginetest contains conformance tests which every engine (Broker
+// and PresenceManager implementation) used by Centrifugo must pass. Engine
+// packages run suite from their tests:
+//
+//	func TestConformance(t *testing.T) {
+//		enginetest.Run(t, func(t *testing.T) enginetest.Engine {
+//			...
+//		})
+//	}
+//
+// Tests use unique channel names so engine may be backed by shared storage.
+package enginetest
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// Engine under test.
+type Engine struct {
+	// Broker to test, must not be run yet – suite runs it with own
+	// event handler.
+	Broker centrifuge.Broker
+	// PresenceManager to test, presence tests skipped if nil.
+	PresenceManager centrifuge.PresenceManager
+	// NoHistory must be set for brokers without history support, history
+	// tests skipped then.
+	NoHistory bool
+	// HistoryMetaTTL with which Broker configured. Stream position of
+	// inactive channel must be dropped after it. Test skipped if zero.
+	HistoryMetaTTL time.Duration
+}
+
+// Factory creates new Engine for every test.
+type Factory func(t *testing.T) Engine
+
+// Run runs conformance tests of engine.
+func Run(t *testing.T, newEngine Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, e Engine, h *eventHandler)
+	}{
+		{"publish_without_history", testPublishWithoutHistory},
+		{"history_ordering", testHistoryOrdering},
+		{"history_since", testHistorySince},
+		{"history_remove", testHistoryRemove},
+		{"history_ttl", testHistoryTTL},
+		{"drop_inactive", testDropInactive},
+		{"delivery_ordering", testDeliveryOrdering},
+		{"channels", testChannels},
+		{"join_leave", testJoinLeave},
+		{"control", testControl},
+		{"presence", testPresence},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEngine(t)
+			h := newEventHandler()
+			require.NoError(t, e.Broker.Run(h))
+			tt.fn(t, e, h)
+		})
+	}
+}
+
+// eventHandler records events received from Broker.
+type eventHandler struct {
+	mu           sync.Mutex
+	publications map[string][]*centrifuge.Publication
+	positions    map[string][]centrifuge.StreamPosition
+	joins        map[string][]*centrifuge.ClientInfo
+	leaves       map[string][]*centrifuge.ClientInfo
+	controls     [][]byte
+}
+
+func newEventHandler() *eventHandler {
+	return &eventHandler{
+		publications: map[string][]*centrifuge.Publication{},
+		positions:    map[string][]centrifuge.StreamPosition{},
+		joins:        map[string][]*centrifuge.ClientInfo{},
+		leaves:       map[string][]*centrifuge.ClientInfo{},
+	}
+}
+
+func (h *eventHandler) HandlePublication(ch string, pub *centrifuge.Publication, sp centrifuge.StreamPosition) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.publications[ch] = append(h.publications[ch], pub)
+	h.positions[ch] = append(h.positions[ch], sp)
+	return nil
+}
+
+func (h *eventHandler) HandleJoin(ch string, info *centrifuge.ClientInfo) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.joins[ch] = append(h.joins[ch], info)
+	return nil
+}
+
+func (h *eventHandler) HandleLeave(ch string, info *centrifuge.ClientInfo) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leaves[ch] = append(h.leaves[ch], info)
+	return nil
+}
+
+func (h *eventHandler) HandleControl(data []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.controls = append(h.controls, data)
+	return nil
+}
+
+func (h *eventHandler) numPublications(ch string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.publications[ch])
+}
+
+const (
+	waitTimeout = 5 * time.Second
+	waitTick    = 10 * time.Millisecond
+)
+
+func testChannel() string {
+	return "enginetest_" + uuid.New().String()
+}
+
+func skipNoHistory(t *testing.T, e Engine) {
+	if e.NoHistory {
+		t.Skip("broker does not support history")
+	}
+}
+
+func skipShort(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping slow test in short mode")
+	}
+}
+
+func publishN(t *testing.T, b centrifuge.Broker, ch string, n int, opts centrifuge.PublishOptions) centrifuge.StreamPosition {
+	var sp centrifuge.StreamPosition
+	for i := 0; i < n; i++ {
+		var err error
+		sp, err = b.Publish(ch, []byte(strconv.Itoa(i)), opts)
+		require.NoError(t, err)
+	}
+	return sp
+}
+
+func offsets(pubs []*centrifuge.Publication) []uint64 {
+	result := make([]uint64, 0, len(pubs))
+	for _, pub := range pubs {
+		result = append(result, pub.Offset)
+	}
+	return result
+}
+
+func testPublishWithoutHistory(t *testing.T, e Engine, _ *eventHandler) {
+	ch := testChannel()
+	sp, err := e.Broker.Publish(ch, []byte("{}"), centrifuge.PublishOptions{})
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), sp.Offset)
+	if e.NoHistory {
+		return
+	}
+	pubs, _, err := e.Broker.History(ch, centrifuge.HistoryFilter{Limit: -1})
+	require.NoError(t, err)
+	require.Len(t, pubs, 0)
+}
+
+func testHistoryOrdering(t *testing.T, e Engine, _ *eventHandler) {
+	skipNoHistory(t, e)
+	ch := testChannel()
+	opts := centrifuge.PublishOptions{HistorySize: 5, HistoryTTL: time.Minute}
+
+	var epoch string
+	for i := 0; i < 10; i++ {
+		sp, err := e.Broker.Publish(ch, []byte(strconv.Itoa(i)), opts)
+		require.NoError(t, err)
+		require.Equal(t, uint64(i+1), sp.Offset, "offsets must grow by one")
+		if epoch == "" {
+			epoch = sp.Epoch
+		}
+		require.Equal(t, epoch, sp.Epoch, "epoch must not change")
+	}
+
+	pubs, sp, err := e.Broker.History(ch, centrifuge.HistoryFilter{Limit: -1})
+	require.NoError(t, err)
+	require.Equal(t, centrifuge.StreamPosition{Offset: 10, Epoch: epoch}, sp)
+	require.Equal(t, []uint64{6, 7, 8, 9, 10}, offsets(pubs), "history size must be respected, oldest first")
+	require.Equal(t, []byte("5"), pubs[0].Data)
+
+	pubs, _, err = e.Broker.History(ch, centrifuge.HistoryFilter{Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{6, 7}, offsets(pubs))
+
+	pubs, _, err = e.Broker.History(ch, centrifuge.HistoryFilter{Limit: 2, Reverse: true})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{10, 9}, offsets(pubs))
+
+	pubs, sp, err = e.Broker.History(ch, centrifuge.HistoryFilter{Limit: 0})
+	require.NoError(t, err)
+	require.Len(t, pubs, 0)
+	require.Equal(t, uint64(10), sp.Offset)
+}
+
+func testHistorySince(t *testing.T, e Engine, _ *eventHandler) {
+	skipNoHistory(t, e)
+	ch := testChannel()
+	opts := centrifuge.PublishOptions{HistorySize: 10, HistoryTTL: time.Minute}
+	sp := publishN(t, e.Broker, ch, 8, opts)
+
+	pubs, _, err := e.Broker.History(ch, centrifuge.HistoryFilter{
+		Since: &centrifuge.StreamPosition{Offset: 5, Epoch: sp.Epoch},
+		Limit: -1,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{6, 7, 8}, offsets(pubs))
+
+	pubs, _, err = e.Broker.History(ch, centrifuge.HistoryFilter{
+		Since: &centrifuge.StreamPosition{Offset: 5, Epoch: sp.Epoch},
+		Limit: 2,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{6, 7}, offsets(pubs))
+
+	// Client is up to date.
+	pubs, _, err = e.Broker.History(ch, centrifuge.HistoryFilter{
+		Since: &centrifuge.StreamPosition{Offset: 8, Epoch: sp.Epoch},
+		Limit: -1,
+	})
+	require.NoError(t, err)
+	require.Len(t, pubs, 0)
+}
+
+func testHistoryRemove(t *testing.T, e Engine, _ *eventHandler) {
+	skipNoHistory(t, e)
+	ch := testChannel()
+	opts := centrifuge.PublishOptions{HistorySize: 10, HistoryTTL: time.Minute}
+	sp := publishN(t, e.Broker, ch, 3, opts)
+
+	require.NoError(t, e.Broker.RemoveHistory(ch))
+	pubs, sp2, err := e.Broker.History(ch, centrifuge.HistoryFilter{Limit: -1})
+	require.NoError(t, err)
+	require.Len(t, pubs, 0)
+	require.Equal(t, sp, sp2, "stream position must survive history removal")
+
+	sp3, err := e.Broker.Publish(ch, []byte("{}"), opts)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), sp3.Offset)
+	require.Equal(t, sp.Epoch, sp3.Epoch)
+}
+
+func testHistoryTTL(t *testing.T, e Engine, _ *eventHandler) {
+	skipNoHistory(t, e)
+	skipShort(t)
+	ch := testChannel()
+	opts := centrifuge.PublishOptions{HistorySize: 10, HistoryTTL: time.Second}
+	sp := publishN(t, e.Broker, ch, 3, opts)
+
+	require.Eventually(t, func() bool {
+		pubs, _, err := e.Broker.History(ch, centrifuge.HistoryFilter{Limit: -1})
+		require.NoError(t, err)
+		return len(pubs) == 0
+	}, waitTimeout, 100*time.Millisecond, "publications must expire after history TTL")
+
+	if e.HistoryMetaTTL > 0 {
+		// Stream position kept until history meta TTL passes.
+		_, sp2, err := e.Broker.History(ch, centrifuge.HistoryFilter{Limit: 0})
+		require.NoError(t, err)
+		require.Equal(t, sp, sp2)
+	}
+}
+
+func testDropInactive(t *testing.T, e Engine, _ *eventHandler) {
+	skipNoHistory(t, e)
+	if e.HistoryMetaTTL == 0 {
+		t.Skip("history meta TTL not set")
+	}
+	skipShort(t)
+	ch := testChannel()
+	opts := centrifuge.PublishOptions{HistorySize: 10, HistoryTTL: time.Second}
+	sp := publishN(t, e.Broker, ch, 3, opts)
+
+	// Reading history prolongs stream meta so wait without polling. Engines
+	// may clean up expired keys with a delay up to one second.
+	time.Sleep(e.HistoryMetaTTL + 2*time.Second)
+	_, sp2, err := e.Broker.History(ch, centrifuge.HistoryFilter{Limit: 0})
+	require.NoError(t, err)
+	require.True(t, sp2.Epoch != sp.Epoch || sp2.Offset == 0, "stream position of inactive channel must be dropped")
+}
+
+func testDeliveryOrdering(t *testing.T, e Engine, h *eventHandler) {
+	ch := testChannel()
+	require.NoError(t, e.Broker.Subscribe(ch))
+	defer func() { _ = e.Broker.Unsubscribe(ch) }()
+
+	var opts centrifuge.PublishOptions
+	if !e.NoHistory {
+		opts = centrifuge.PublishOptions{HistorySize: 100, HistoryTTL: time.Minute}
+	}
+	const numPublications = 100
+	publishN(t, e.Broker, ch, numPublications, opts)
+
+	require.Eventually(t, func() bool {
+		return h.numPublications(ch) == numPublications
+	}, waitTimeout, waitTick)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, pub := range h.publications[ch] {
+		require.Equal(t, []byte(strconv.Itoa(i)), pub.Data, "publications must be delivered in publish order")
+		if !e.NoHistory {
+			require.Equal(t, uint64(i+1), h.positions[ch][i].Offset)
+		}
+	}
+}
+
+func testChannels(t *testing.T, e Engine, h *eventHandler) {
+	ch1 := testChannel()
+	ch2 := testChannel()
+	require.NoError(t, e.Broker.Subscribe(ch1))
+	require.NoError(t, e.Broker.Subscribe(ch1), "subscribing twice must not fail")
+	require.NoError(t, e.Broker.Subscribe(ch2))
+	defer func() { _ = e.Broker.Unsubscribe(ch2) }()
+
+	var opts centrifuge.PublishOptions
+	if !e.NoHistory {
+		opts = centrifuge.PublishOptions{HistorySize: 10, HistoryTTL: time.Minute}
+	}
+	publishN(t, e.Broker, ch1, 2, opts)
+	publishN(t, e.Broker, ch2, 3, opts)
+
+	require.Eventually(t, func() bool {
+		return h.numPublications(ch1) == 2 && h.numPublications(ch2) == 3
+	}, waitTimeout, waitTick)
+
+	require.NoError(t, e.Broker.Unsubscribe(ch1))
+	require.NoError(t, e.Broker.Unsubscribe(ch1), "unsubscribing twice must not fail")
+
+	// Channel must be delivered again after resubscribe.
+	require.NoError(t, e.Broker.Subscribe(ch1))
+	defer func() { _ = e.Broker.Unsubscribe(ch1) }()
+	publishN(t, e.Broker, ch1, 1, opts)
+	require.Eventually(t, func() bool {
+		return h.numPublications(ch1) == 3
+	}, waitTimeout, waitTick)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	require.Len(t, h.publications[ch2], 3, "publications must be delivered only to own channel")
+	require.Equal(t, []byte("0"), h.publications[ch1][2].Data)
+}
+
+func testJoinLeave(t *testing.T, e Engine, h *eventHandler) {
+	ch := testChannel()
+	require.NoError(t, e.Broker.Subscribe(ch))
+	defer func() { _ = e.Broker.Unsubscribe(ch) }()
+
+	info := &centrifuge.ClientInfo{ClientID: "client", UserID: "user"}
+	require.NoError(t, e.Broker.PublishJoin(ch, info))
+	require.NoError(t, e.Broker.PublishLeave(ch, info))
+
+	require.Eventually(t, func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return len(h.joins[ch]) == 1 && len(h.leaves[ch]) == 1
+	}, waitTimeout, waitTick)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	require.Equal(t, "client", h.joins[ch][0].ClientID)
+	require.Equal(t, "user", h.leaves[ch][0].UserID)
+}
+
+func testControl(t *testing.T, e Engine, h *eventHandler) {
+	data := []byte(uuid.New().String())
+	require.NoError(t, e.Broker.PublishControl(data, "", ""))
+	require.Eventually(t, func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for _, c := range h.controls {
+			if string(c) == string(data) {
+				return true
+			}
+		}
+		return false
+	}, waitTimeout, waitTick)
+}
+
+func testPresence(t *testing.T, e Engine, _ *eventHandler) {
+	if e.PresenceManager == nil {
+		t.Skip("no presence manager")
+	}
+	pm := e.PresenceManager
+	ch := testChannel()
+
+	presence, err := pm.Presence(ch)
+	require.NoError(t, err)
+	require.Len(t, presence, 0)
+
+	require.NoError(t, pm.AddPresence(ch, "c1", &centrifuge.ClientInfo{ClientID: "c1", UserID: "u1"}))
+	require.NoError(t, pm.AddPresence(ch, "c2", &centrifuge.ClientInfo{ClientID: "c2", UserID: "u1"}))
+	require.NoError(t, pm.AddPresence(ch, "c3", &centrifuge.ClientInfo{ClientID: "c3", UserID: "u2"}))
+	// Adding presence again updates it.
+	require.NoError(t, pm.AddPresence(ch, "c3", &centrifuge.ClientInfo{ClientID: "c3", UserID: "u2"}))
+
+	presence, err = pm.Presence(ch)
+	require.NoError(t, err)
+	require.Len(t, presence, 3)
+	require.Equal(t, "u1", presence["c1"].UserID)
+
+	stats, err := pm.PresenceStats(ch)
+	require.NoError(t, err)
+	require.Equal(t, centrifuge.PresenceStats{NumClients: 3, NumUsers: 2}, stats)
+
+	require.NoError(t, pm.RemovePresence(ch, "c1"))
+	require.NoError(t, pm.RemovePresence(ch, "c1"), "removing absent presence must not fail")
+	presence, err = pm.Presence(ch)
+	require.NoError(t, err)
+	require.Len(t, presence, 2)
+	require.NotContains(t, presence, "c1")
+
+	stats, err = pm.PresenceStats(ch)
+	require.NoError(t, err)
+	require.Equal(t, centrifuge.PresenceStats{NumClients: 2, NumUsers: 2}, stats)
+}
@@ -0,0 +1,112 @@
+// Package errorcode contains registry of error codes returned to clients and
+// server API callers together with retryability hints, and mapping of internal
+// errors to public error codes.
+package errorcode
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// Code describes public error code.
+type Code struct {
+	Code    uint32 `json:"code"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+	// Temporary is true if request failed with error can succeed later so
+	// SDKs can retry it with backoff.
+	Temporary bool `json:"temporary"`
+}
+
+var codes = map[uint32]Code{}
+
+func register(name string, e *centrifuge.Error, temporary bool) {
+	codes[e.Code] = Code{Code: e.Code, Name: name, Message: e.Message, Temporary: temporary}
+}
+
+func init() {
+	register("internal", centrifuge.ErrorInternal, true)
+	register("unauthorized", centrifuge.ErrorUnauthorized, false)
+	register("unknown_channel", centrifuge.ErrorUnknownChannel, false)
+	register("permission_denied", centrifuge.ErrorPermissionDenied, false)
+	register("method_not_found", centrifuge.ErrorMethodNotFound, false)
+	register("already_subscribed", centrifuge.ErrorAlreadySubscribed, false)
+	register("limit_exceeded", centrifuge.ErrorLimitExceeded, false)
+	register("bad_request", centrifuge.ErrorBadRequest, false)
+	register("not_available", centrifuge.ErrorNotAvailable, false)
+	register("token_expired", centrifuge.ErrorTokenExpired, false)
+	register("expired", centrifuge.ErrorExpired, false)
+	register("too_many_requests", centrifuge.ErrorTooManyRequests, true)
+	register("unrecoverable_position", centrifuge.ErrorUnrecoverablePosition, false)
+}
+
+// Codes returns all registered error codes sorted by code.
+func Codes() []Code {
+	result := make([]Code, 0, len(codes))
+	for _, c := range codes {
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Code < result[j].Code
+	})
+	return result
+}
+
+// Lookup returns registered error code.
+func Lookup(code uint32) (Code, bool) {
+	c, ok := codes[code]
+	return c, ok
+}
+
+// IsTemporary reports whether error with code can be retried. Unknown codes
+// are not temporary.
+func IsTemporary(code uint32) bool {
+	return codes[code].Temporary
+}
+
+type mapping struct {
+	err  error
+	code *centrifuge.Error
+}
+
+var (
+	mappingsMu sync.RWMutex
+	mappings   []mapping
+)
+
+// Map makes FromError return code for errors matching err. Engines use it
+// to expose their errors to clients and API callers, usually from init.
+func Map(err error, code *centrifuge.Error) {
+	mappingsMu.Lock()
+	defer mappingsMu.Unlock()
+	mappings = append(mappings, mapping{err: err, code: code})
+}
+
+// FromError converts error to public error. Public errors returned as is,
+// errors registered with Map converted to registered code, all other errors
+// become centrifuge.ErrorInternal.
+func FromError(err error) *centrifuge.Error {
+	var e *centrifuge.Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if code, ok := Mapped(err); ok {
+		return code
+	}
+	return centrifuge.ErrorInternal
+}
+
+// Mapped returns code registered with Map for errors matching err.
+func Mapped(err error) (*centrifuge.Error, bool) {
+	mappingsMu.RLock()
+	defer mappingsMu.RUnlock()
+	for _, m := range mappings {
+		if errors.Is(err, m.err) {
+			return m.code, true
+		}
+	}
+	return nil, false
+}
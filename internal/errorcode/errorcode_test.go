@@ -0,0 +1,48 @@
+package errorcode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodes(t *testing.T) {
+	codes := Codes()
+	require.NotEmpty(t, codes)
+	for i, c := range codes {
+		require.NotEmpty(t, c.Name)
+		require.NotEmpty(t, c.Message)
+		if i > 0 {
+			require.True(t, codes[i-1].Code < c.Code)
+		}
+	}
+	c, ok := Lookup(centrifuge.ErrorInternal.Code)
+	require.True(t, ok)
+	require.Equal(t, "internal", c.Name)
+	require.True(t, c.Temporary)
+	_, ok = Lookup(999)
+	require.False(t, ok)
+
+	require.True(t, IsTemporary(centrifuge.ErrorTooManyRequests.Code))
+	require.False(t, IsTemporary(centrifuge.ErrorPermissionDenied.Code))
+	require.False(t, IsTemporary(999))
+}
+
+func TestFromError(t *testing.T) {
+	errTest := errors.New("test")
+	Map(errTest, centrifuge.ErrorNotAvailable)
+
+	require.Equal(t, centrifuge.ErrorNotAvailable, FromError(errTest))
+	require.Equal(t, centrifuge.ErrorNotAvailable, FromError(fmt.Errorf("wrapped: %w", errTest)))
+	require.Equal(t, centrifuge.ErrorLimitExceeded, FromError(centrifuge.ErrorLimitExceeded))
+	require.Equal(t, centrifuge.ErrorInternal, FromError(errors.New("boom")))
+
+	code, ok := Mapped(fmt.Errorf("wrapped: %w", errTest))
+	require.True(t, ok)
+	require.Equal(t, centrifuge.ErrorNotAvailable, code)
+	_, ok = Mapped(errors.New("boom"))
+	require.False(t, ok)
+}
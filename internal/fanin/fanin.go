@@ -0,0 +1,414 @@
+// Package fanin implements channel groups: channels which aggregate
+// publications of a set of source channels. Client subscribes to channel group
+// once and node delivers publications of all source channels to it.
+package fanin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// MaxSources is a max number of source channels in channel group.
+const MaxSources = 1000
+
+// notificationOp is an op of node notification sent when sources of channel
+// group changed.
+const notificationOp = "channel_group"
+
+var (
+	// ErrNotGroup returned for channels which are not channel groups.
+	ErrNotGroup = errors.New("channel is not a channel group")
+	// ErrStaticGroup returned on attempt to change channel group set in
+	// configuration.
+	ErrStaticGroup = errors.New("channel group is set in configuration")
+	// ErrInvalidSources returned for source channels which can't be used in
+	// channel group: empty, too many, or channel groups themselves.
+	ErrInvalidSources = errors.New("invalid channel group sources")
+	// ErrNoStore returned on attempt to change channel groups without Store.
+	ErrNoStore = errors.New("no channel group store")
+)
+
+// Store keeps channel groups set over server API, it must be shared by all
+// nodes.
+type Store interface {
+	// ChannelGroup returns source channels of channel group, nil returned
+	// if group not found.
+	ChannelGroup(ch string) ([]string, error)
+	// SetChannelGroup sets source channels of channel group.
+	SetChannelGroup(ch string, sources []string) error
+	// RemoveChannelGroup removes channel group.
+	RemoveChannelGroup(ch string) error
+}
+
+// Group is a channel group set in configuration.
+type Group struct {
+	// Channel of group.
+	Channel string `mapstructure:"channel" json:"channel"`
+	// Sources are channels which publications delivered to group.
+	Sources []string `mapstructure:"sources" json:"sources"`
+}
+
+// Config of Manager.
+type Config struct {
+	// IsGroup reports whether channel is a channel group.
+	IsGroup func(channel string) bool
+	// Groups set in configuration, they can't be changed over server API.
+	Groups []Group
+	// Store keeps channel groups set over server API. Channel groups can
+	// only be set in configuration if not set.
+	Store Store
+}
+
+// Validate channel groups set in configuration.
+func (c Config) Validate() error {
+	seen := map[string]struct{}{}
+	for _, g := range c.Groups {
+		if c.IsGroup == nil || !c.IsGroup(g.Channel) {
+			return fmt.Errorf("%w: %s", ErrNotGroup, g.Channel)
+		}
+		if _, ok := seen[g.Channel]; ok {
+			return fmt.Errorf("duplicate channel group: %s", g.Channel)
+		}
+		seen[g.Channel] = struct{}{}
+		if err := c.validateSources(g.Sources); err != nil {
+			return fmt.Errorf("%w: %s", err, g.Channel)
+		}
+	}
+	return nil
+}
+
+func (c Config) validateSources(sources []string) error {
+	if len(sources) == 0 || len(sources) > MaxSources {
+		return ErrInvalidSources
+	}
+	for _, source := range sources {
+		if source == "" || (c.IsGroup != nil && c.IsGroup(source)) {
+			return ErrInvalidSources
+		}
+	}
+	return nil
+}
+
+// Manager of channel groups. Manager wraps Broker of node: when node
+// subscribes to channel group Manager subscribes node to all source channels
+// of group, publications of source channels delivered to local subscribers of
+// channel group without stream position. Publication data is an envelope
+// {"channel": <source channel>, "data": <payload>} if payload is JSON,
+// other payloads delivered as is.
+type Manager struct {
+	node   *centrifuge.Node
+	config Config
+	static map[string][]string
+	broker centrifuge.Broker
+
+	// subMu orders broker subscriptions: changes of channel groups are
+	// exclusive, other subscriptions can run concurrently.
+	subMu sync.RWMutex
+	// mu protects maps below and never held while calling broker, so
+	// delivery of publications is not blocked by subscriptions.
+	mu sync.RWMutex
+	// active contains sources of channel groups node subscribed to.
+	active map[string][]string
+	// groups contains active channel groups of source channel.
+	groups map[string]map[string]struct{}
+}
+
+// NewManager creates Manager. Manager handles notifications of node about
+// changed channel groups.
+func NewManager(node *centrifuge.Node, config Config) *Manager {
+	m := &Manager{
+		node:   node,
+		config: config,
+		static: map[string][]string{},
+		active: map[string][]string{},
+		groups: map[string]map[string]struct{}{},
+	}
+	for _, g := range config.Groups {
+		m.static[g.Channel] = g.Sources
+	}
+	node.OnNotification(func(e centrifuge.NotificationEvent) {
+		if e.Op != notificationOp {
+			return
+		}
+		if err := m.refresh(string(e.Data)); err != nil {
+			m.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error refreshing channel group", map[string]interface{}{"channel": string(e.Data), "error": err.Error()}))
+		}
+	})
+	return m
+}
+
+// Broker wraps Broker of node to perform fan-in of channel groups. Returned
+// Broker must be set to node.
+func (m *Manager) Broker(b centrifuge.Broker) centrifuge.Broker {
+	m.broker = b
+	return &broker{Broker: b, manager: m}
+}
+
+func (m *Manager) isGroup(ch string) bool {
+	return m.config.IsGroup != nil && m.config.IsGroup(ch)
+}
+
+// ChannelGroup returns source channels of channel group.
+func (m *Manager) ChannelGroup(ch string) ([]string, error) {
+	if !m.isGroup(ch) {
+		return nil, ErrNotGroup
+	}
+	if sources, ok := m.static[ch]; ok {
+		return sources, nil
+	}
+	if m.config.Store == nil {
+		return nil, nil
+	}
+	return m.config.Store.ChannelGroup(ch)
+}
+
+// SetChannelGroup sets source channels of channel group and notifies all
+// nodes about it.
+func (m *Manager) SetChannelGroup(ch string, sources []string) error {
+	if err := m.checkChange(ch); err != nil {
+		return err
+	}
+	if err := m.config.validateSources(sources); err != nil {
+		return err
+	}
+	if err := m.config.Store.SetChannelGroup(ch, sources); err != nil {
+		return err
+	}
+	return m.node.Notify(notificationOp, []byte(ch), "")
+}
+
+// RemoveChannelGroup removes channel group and notifies all nodes about it.
+func (m *Manager) RemoveChannelGroup(ch string) error {
+	if err := m.checkChange(ch); err != nil {
+		return err
+	}
+	if err := m.config.Store.RemoveChannelGroup(ch); err != nil {
+		return err
+	}
+	return m.node.Notify(notificationOp, []byte(ch), "")
+}
+
+func (m *Manager) checkChange(ch string) error {
+	if !m.isGroup(ch) {
+		return ErrNotGroup
+	}
+	if _, ok := m.static[ch]; ok {
+		return ErrStaticGroup
+	}
+	if m.config.Store == nil {
+		return ErrNoStore
+	}
+	return nil
+}
+
+func (m *Manager) subscribeGroup(ch string) error {
+	sources, err := m.ChannelGroup(ch)
+	if err != nil {
+		return err
+	}
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	if err := m.broker.Subscribe(ch); err != nil {
+		return err
+	}
+	for i, source := range sources {
+		if err := m.broker.Subscribe(source); err != nil {
+			m.unsubscribeSources(ch, sources[:i])
+			_ = m.broker.Unsubscribe(ch)
+			return err
+		}
+		m.addSource(ch, source)
+	}
+	m.mu.Lock()
+	m.active[ch] = sources
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Manager) unsubscribeGroup(ch string) error {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	m.mu.Lock()
+	sources := m.active[ch]
+	delete(m.active, ch)
+	m.mu.Unlock()
+	m.unsubscribeSources(ch, sources)
+	return m.broker.Unsubscribe(ch)
+}
+
+// refresh updates subscriptions of active channel group after its sources
+// changed.
+func (m *Manager) refresh(ch string) error {
+	if !m.isGroup(ch) {
+		return nil
+	}
+	sources, err := m.ChannelGroup(ch)
+	if err != nil {
+		return err
+	}
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	m.mu.RLock()
+	current, ok := m.active[ch]
+	m.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	keep := make(map[string]struct{}, len(sources))
+	for _, source := range sources {
+		keep[source] = struct{}{}
+	}
+	existing := make(map[string]struct{}, len(current))
+	var removed []string
+	for _, source := range current {
+		existing[source] = struct{}{}
+		if _, ok := keep[source]; !ok {
+			removed = append(removed, source)
+		}
+	}
+	m.unsubscribeSources(ch, removed)
+
+	subscribed := make([]string, 0, len(sources))
+	defer func() {
+		m.mu.Lock()
+		m.active[ch] = subscribed
+		m.mu.Unlock()
+	}()
+	for _, source := range sources {
+		if _, ok := existing[source]; !ok {
+			if err := m.broker.Subscribe(source); err != nil {
+				return err
+			}
+			m.addSource(ch, source)
+		}
+		subscribed = append(subscribed, source)
+	}
+	return nil
+}
+
+func (m *Manager) addSource(ch string, source string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	groups, ok := m.groups[source]
+	if !ok {
+		groups = map[string]struct{}{}
+		m.groups[source] = groups
+	}
+	groups[ch] = struct{}{}
+}
+
+// unsubscribeSources unsubscribes node from sources of channel group which
+// are not used by other groups or subscribers of node. Must be called with
+// subMu locked.
+func (m *Manager) unsubscribeSources(ch string, sources []string) {
+	for _, source := range sources {
+		m.mu.Lock()
+		groups := m.groups[source]
+		delete(groups, ch)
+		used := len(groups) > 0
+		if !used {
+			delete(m.groups, source)
+		}
+		m.mu.Unlock()
+		if used || m.node.Hub().NumSubscribers(source) > 0 {
+			continue
+		}
+		if err := m.broker.Unsubscribe(source); err != nil {
+			m.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error unsubscribing from channel group source", map[string]interface{}{"channel": ch, "source": source, "error": err.Error()}))
+		}
+	}
+}
+
+func (m *Manager) sourceGroups(source string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	groups := m.groups[source]
+	if len(groups) == 0 {
+		return nil
+	}
+	result := make([]string, 0, len(groups))
+	for ch := range groups {
+		result = append(result, ch)
+	}
+	return result
+}
+
+type envelope struct {
+	Channel string          `json:"channel"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type broker struct {
+	centrifuge.Broker
+	manager *Manager
+}
+
+// Run wraps event handler to deliver publications of sources to channel
+// groups.
+func (b *broker) Run(h centrifuge.BrokerEventHandler) error {
+	return b.Broker.Run(&eventHandler{BrokerEventHandler: h, manager: b.manager})
+}
+
+// Subscribe node to channel.
+func (b *broker) Subscribe(ch string) error {
+	if b.manager.isGroup(ch) {
+		return b.manager.subscribeGroup(ch)
+	}
+	b.manager.subMu.RLock()
+	defer b.manager.subMu.RUnlock()
+	return b.Broker.Subscribe(ch)
+}
+
+// Unsubscribe node from channel. Node stays subscribed to channel while it's
+// a source of active channel group.
+func (b *broker) Unsubscribe(ch string) error {
+	if b.manager.isGroup(ch) {
+		return b.manager.unsubscribeGroup(ch)
+	}
+	b.manager.subMu.RLock()
+	defer b.manager.subMu.RUnlock()
+	if len(b.manager.sourceGroups(ch)) > 0 {
+		return nil
+	}
+	return b.Broker.Unsubscribe(ch)
+}
+
+// Close Broker if it supports closing.
+func (b *broker) Close(ctx context.Context) error {
+	if closer, ok := b.Broker.(centrifuge.Closer); ok {
+		return closer.Close(ctx)
+	}
+	return nil
+}
+
+type eventHandler struct {
+	centrifuge.BrokerEventHandler
+	manager *Manager
+}
+
+// HandlePublication delivers publication to channel and channel groups it
+// belongs to.
+func (h *eventHandler) HandlePublication(ch string, pub *centrifuge.Publication, sp centrifuge.StreamPosition) error {
+	err := h.BrokerEventHandler.HandlePublication(ch, pub, sp)
+	groups := h.manager.sourceGroups(ch)
+	if len(groups) == 0 {
+		return err
+	}
+	data := pub.Data
+	if json.Valid(data) {
+		data, _ = json.Marshal(envelope{Channel: ch, Data: pub.Data})
+	}
+	for _, group := range groups {
+		groupPub := &centrifuge.Publication{Data: data, Info: pub.Info}
+		if groupErr := h.BrokerEventHandler.HandlePublication(group, groupPub, centrifuge.StreamPosition{}); groupErr != nil && err == nil {
+			err = groupErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,190 @@
+package fanin
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+type testStore struct {
+	mu     sync.Mutex
+	groups map[string][]string
+}
+
+func (s *testStore) ChannelGroup(ch string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.groups[ch], nil
+}
+
+func (s *testStore) SetChannelGroup(ch string, sources []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groups[ch] = sources
+	return nil
+}
+
+func (s *testStore) RemoveChannelGroup(ch string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.groups, ch)
+	return nil
+}
+
+// testBroker keeps channels node subscribed to.
+type testBroker struct {
+	centrifuge.Broker
+	mu         sync.Mutex
+	subscribed map[string]struct{}
+}
+
+func (b *testBroker) Subscribe(ch string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribed[ch] = struct{}{}
+	return nil
+}
+
+func (b *testBroker) Unsubscribe(ch string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribed, ch)
+	return nil
+}
+
+func (b *testBroker) isSubscribed(ch string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.subscribed[ch]
+	return ok
+}
+
+type testTransport struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (t *testTransport) Name() string                         { return "test" }
+func (t *testTransport) Protocol() centrifuge.ProtocolType    { return centrifuge.ProtocolTypeJSON }
+func (t *testTransport) Unidirectional() bool                 { return false }
+func (t *testTransport) DisabledPushFlags() uint64            { return centrifuge.PushFlagDisconnect }
+func (t *testTransport) Write(data []byte) error              { return t.WriteMany(data) }
+func (t *testTransport) Close(_ *centrifuge.Disconnect) error { return nil }
+
+func (t *testTransport) WriteMany(messages ...[]byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, m := range messages {
+		t.messages = append(t.messages, string(m))
+	}
+	return nil
+}
+
+func (t *testTransport) written() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.Join(t.messages, "\n")
+}
+
+func isGroup(ch string) bool {
+	return strings.HasPrefix(ch, "group:")
+}
+
+func newTestManager(t *testing.T) (*centrifuge.Node, *Manager, *testBroker) {
+	node, err := centrifuge.New(centrifuge.DefaultConfig)
+	require.NoError(t, err)
+	memBroker, err := centrifuge.NewMemoryBroker(node, centrifuge.MemoryBrokerConfig{})
+	require.NoError(t, err)
+	b := &testBroker{Broker: memBroker, subscribed: map[string]struct{}{}}
+	m := NewManager(node, Config{
+		IsGroup: isGroup,
+		Groups:  []Group{{Channel: "group:static", Sources: []string{"a", "b"}}},
+		Store:   &testStore{groups: map[string][]string{}},
+	})
+	node.SetBroker(m.Broker(b))
+	node.OnConnecting(func(context.Context, centrifuge.ConnectEvent) (centrifuge.ConnectReply, error) {
+		return centrifuge.ConnectReply{Credentials: &centrifuge.Credentials{UserID: "42"}}, nil
+	})
+	require.NoError(t, node.Run())
+	t.Cleanup(func() { _ = node.Shutdown(context.Background()) })
+	return node, m, b
+}
+
+func TestConfigValidate(t *testing.T) {
+	require.NoError(t, Config{IsGroup: isGroup, Groups: []Group{{Channel: "group:1", Sources: []string{"a"}}}}.Validate())
+	require.ErrorIs(t, Config{IsGroup: isGroup, Groups: []Group{{Channel: "1", Sources: []string{"a"}}}}.Validate(), ErrNotGroup)
+	require.ErrorIs(t, Config{IsGroup: isGroup, Groups: []Group{{Channel: "group:1"}}}.Validate(), ErrInvalidSources)
+	require.ErrorIs(t, Config{IsGroup: isGroup, Groups: []Group{{Channel: "group:1", Sources: []string{"group:2"}}}}.Validate(), ErrInvalidSources)
+	require.Error(t, Config{IsGroup: isGroup, Groups: []Group{
+		{Channel: "group:1", Sources: []string{"a"}},
+		{Channel: "group:1", Sources: []string{"b"}},
+	}}.Validate())
+}
+
+func TestManagerChannelGroup(t *testing.T) {
+	_, m, _ := newTestManager(t)
+
+	require.Equal(t, ErrNotGroup, m.SetChannelGroup("a", []string{"b"}))
+	require.Equal(t, ErrStaticGroup, m.SetChannelGroup("group:static", []string{"c"}))
+	require.Equal(t, ErrStaticGroup, m.RemoveChannelGroup("group:static"))
+	require.Equal(t, ErrInvalidSources, m.SetChannelGroup("group:1", nil))
+	require.Equal(t, ErrInvalidSources, m.SetChannelGroup("group:1", []string{"group:2"}))
+
+	sources, err := m.ChannelGroup("group:static")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, sources)
+
+	require.NoError(t, m.SetChannelGroup("group:1", []string{"c"}))
+	sources, err = m.ChannelGroup("group:1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"c"}, sources)
+	require.NoError(t, m.RemoveChannelGroup("group:1"))
+	sources, err = m.ChannelGroup("group:1")
+	require.NoError(t, err)
+	require.Nil(t, sources)
+}
+
+func TestManagerFanIn(t *testing.T) {
+	node, m, b := newTestManager(t)
+
+	transport := &testTransport{}
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, transport)
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{})
+
+	require.NoError(t, m.SetChannelGroup("group:devices", []string{"device1", "device2"}))
+	require.NoError(t, client.Subscribe("group:devices"))
+	require.True(t, b.isSubscribed("device1"))
+	require.True(t, b.isSubscribed("device2"))
+
+	_, err = node.Publish("device1", []byte(`{"value":1}`))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return strings.Contains(transport.written(), `"channel":"group:devices","data":{"data":{"channel":"device1","data":{"value":1}}}`)
+	}, time.Second, 10*time.Millisecond)
+
+	// Source removed from group.
+	require.NoError(t, m.SetChannelGroup("group:devices", []string{"device2", "device3"}))
+	require.False(t, b.isSubscribed("device1"))
+	require.True(t, b.isSubscribed("device2"))
+	require.True(t, b.isSubscribed("device3"))
+
+	// Direct subscriber keeps node subscribed to source. Node unsubscribes
+	// from channels with delay after last subscriber left.
+	require.NoError(t, client.Subscribe("device2"))
+	require.NoError(t, client.Unsubscribe("group:devices"))
+	require.Eventually(t, func() bool {
+		return !b.isSubscribed("group:devices") && !b.isSubscribed("device3")
+	}, 3*time.Second, 50*time.Millisecond)
+	require.True(t, b.isSubscribed("device2"))
+	require.NoError(t, client.Unsubscribe("device2"))
+	require.Eventually(t, func() bool {
+		return !b.isSubscribed("device2")
+	}, 3*time.Second, 50*time.Millisecond)
+}
@@ -0,0 +1,22 @@
+package geoip
+
+import "context"
+
+type contextKey struct{}
+
+// SetToContext puts client Location to context.
+func SetToContext(ctx context.Context, loc Location) context.Context {
+	return context.WithValue(ctx, contextKey{}, loc)
+}
+
+// FromContext returns client Location from context.
+func FromContext(ctx context.Context) (Location, bool) {
+	if ctx == nil {
+		return Location{}, false
+	}
+	if val := ctx.Value(contextKey{}); val != nil {
+		loc, ok := val.(Location)
+		return loc, ok
+	}
+	return Location{}, false
+}
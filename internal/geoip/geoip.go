@@ -0,0 +1,100 @@
+// Package geoip resolves client IP addresses to location using MaxMind DB
+// (GeoIP2/GeoLite2 Country or City databases).
+package geoip
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+)
+
+// InfoKey is a key of connection info JSON object client location added under.
+const InfoKey = "geo"
+
+// Location of client.
+type Location struct {
+	// Country is ISO 3166-1 country code.
+	Country string
+	// Region is ISO 3166-2 code of the most general subdivision, only set
+	// when City database used.
+	Region string
+}
+
+// Resolver resolves IP addresses to Location.
+type Resolver struct {
+	db *mmdb
+}
+
+// Open loads MaxMind DB from file.
+func Open(path string) (*Resolver, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(buf)
+}
+
+// New creates Resolver from MaxMind DB content.
+func New(buf []byte) (*Resolver, error) {
+	db, err := newMMDB(buf)
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{db: db}, nil
+}
+
+// Lookup returns Location of IP address. Returns false if IP is not valid or
+// there is no location for it in database.
+func (r *Resolver) Lookup(ip string) (Location, bool) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return Location{}, false
+	}
+	record, err := r.db.lookup(parsed)
+	if err != nil || record == nil {
+		return Location{}, false
+	}
+	m, ok := record.(map[string]interface{})
+	if !ok {
+		return Location{}, false
+	}
+	var loc Location
+	if country, ok := m["country"].(map[string]interface{}); ok {
+		loc.Country, _ = country["iso_code"].(string)
+	}
+	if subdivisions, ok := m["subdivisions"].([]interface{}); ok && len(subdivisions) > 0 {
+		if subdivision, ok := subdivisions[0].(map[string]interface{}); ok {
+			loc.Region, _ = subdivision["iso_code"].(string)
+		}
+	}
+	if loc.Country == "" {
+		return Location{}, false
+	}
+	return loc, true
+}
+
+type jsonLocation struct {
+	Country string `json:"country"`
+	Region  string `json:"region,omitempty"`
+}
+
+// AddToInfo returns connection info with client location added under InfoKey.
+// Info which is not empty and not JSON object returned as is, existing key not
+// overwritten. Passed info is never modified.
+func AddToInfo(info []byte, loc Location) []byte {
+	fields := map[string]json.RawMessage{}
+	if len(info) > 0 {
+		if err := json.Unmarshal(info, &fields); err != nil || fields == nil {
+			return info
+		}
+	}
+	if _, ok := fields[InfoKey]; ok {
+		return info
+	}
+	fields[InfoKey], _ = json.Marshal(jsonLocation{Country: loc.Country, Region: loc.Region})
+	result, err := json.Marshal(fields)
+	if err != nil {
+		return info
+	}
+	return result
+}
@@ -0,0 +1,143 @@
+package geoip
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func encString(s string) []byte {
+	return append([]byte{typeString<<5 | byte(len(s))}, s...)
+}
+
+func encMap(size int) []byte {
+	return []byte{typeMap<<5 | byte(size)}
+}
+
+func encArray(size int) []byte {
+	return []byte{byte(size), typeArray - 7}
+}
+
+func encUint32(v uint32) []byte {
+	return []byte{typeUint32<<5 | 4, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+func writeRecord(tree []byte, node int, bit int, recordSize int, value uint32) {
+	b := tree[node*recordSize/4:]
+	switch recordSize {
+	case 24:
+		b = b[bit*3:]
+		b[0], b[1], b[2] = byte(value>>16), byte(value>>8), byte(value)
+	case 28:
+		if bit == 0 {
+			b[0], b[1], b[2] = byte(value>>16), byte(value>>8), byte(value)
+			b[3] = b[3]&0x0F | byte(value>>20)&0xF0
+		} else {
+			b[4], b[5], b[6] = byte(value>>16), byte(value>>8), byte(value)
+			b[3] = b[3]&0xF0 | byte(value>>24)&0x0F
+		}
+	}
+}
+
+// buildDB builds MaxMind DB with one network which maps to record with
+// country and subdivision.
+func buildDB(ipVersion int, recordSize int, network string) []byte {
+	_, ipNet, err := net.ParseCIDR(network)
+	if err != nil {
+		panic(err)
+	}
+	ip := ipNet.IP
+	ones, _ := ipNet.Mask.Size()
+	var path []int
+	if ipVersion == 6 && len(ip) == net.IPv4len {
+		path = make([]int, 96)
+	}
+	for i := 0; i < ones; i++ {
+		path = append(path, int(ip[i/8]>>(7-uint(i%8)))&1)
+	}
+	nodeCount := len(path)
+	tree := make([]byte, nodeCount*recordSize/4)
+	for i, bit := range path {
+		next := uint32(i + 1)
+		if i == len(path)-1 {
+			// Pointer to the first record in data section.
+			next = uint32(nodeCount + dataSectionSeparatorSize)
+		}
+		writeRecord(tree, i, bit, recordSize, next)
+		writeRecord(tree, i, 1-bit, recordSize, uint32(nodeCount))
+	}
+
+	var data []byte
+	data = append(data, encMap(2)...)
+	data = append(data, encString("country")...)
+	data = append(data, encMap(1)...)
+	data = append(data, encString("iso_code")...)
+	data = append(data, encString("DE")...)
+	data = append(data, encString("subdivisions")...)
+	data = append(data, encArray(1)...)
+	data = append(data, encMap(1)...)
+	data = append(data, encString("iso_code")...)
+	data = append(data, encString("BE")...)
+
+	var meta []byte
+	meta = append(meta, encMap(3)...)
+	meta = append(meta, encString("node_count")...)
+	meta = append(meta, encUint32(uint32(nodeCount))...)
+	meta = append(meta, encString("record_size")...)
+	meta = append(meta, encUint32(uint32(recordSize))...)
+	meta = append(meta, encString("ip_version")...)
+	meta = append(meta, encUint32(uint32(ipVersion))...)
+
+	var buf []byte
+	buf = append(buf, tree...)
+	buf = append(buf, make([]byte, dataSectionSeparatorSize)...)
+	buf = append(buf, data...)
+	buf = append(buf, metadataStartMarker...)
+	buf = append(buf, meta...)
+	return buf
+}
+
+func TestResolverLookup(t *testing.T) {
+	for _, tc := range []struct {
+		ipVersion  int
+		recordSize int
+	}{{4, 24}, {6, 24}, {6, 28}} {
+		r, err := New(buildDB(tc.ipVersion, tc.recordSize, "1.2.3.0/24"))
+		require.NoError(t, err)
+
+		loc, ok := r.Lookup("1.2.3.4")
+		require.True(t, ok)
+		require.Equal(t, Location{Country: "DE", Region: "BE"}, loc)
+
+		_, ok = r.Lookup("1.2.4.4")
+		require.False(t, ok)
+		_, ok = r.Lookup("2001:db8::1")
+		require.False(t, ok)
+		_, ok = r.Lookup("invalid")
+		require.False(t, ok)
+	}
+}
+
+func TestResolverIPv6(t *testing.T) {
+	r, err := New(buildDB(6, 28, "2001:db8::/32"))
+	require.NoError(t, err)
+	loc, ok := r.Lookup("2001:db8::1")
+	require.True(t, ok)
+	require.Equal(t, "DE", loc.Country)
+	_, ok = r.Lookup("2001:db9::1")
+	require.False(t, ok)
+}
+
+func TestNewInvalidDatabase(t *testing.T) {
+	_, err := New([]byte("not a database"))
+	require.Error(t, err)
+}
+
+func TestAddToInfo(t *testing.T) {
+	loc := Location{Country: "SE", Region: "AB"}
+	require.JSONEq(t, `{"geo":{"country":"SE","region":"AB"}}`, string(AddToInfo(nil, loc)))
+	require.JSONEq(t, `{"geo":{"country":"SE"},"name":"Alex"}`, string(AddToInfo([]byte(`{"name":"Alex"}`), Location{Country: "SE"})))
+	require.Equal(t, `{"geo":"app"}`, string(AddToInfo([]byte(`{"geo":"app"}`), loc)))
+	require.Equal(t, `[1,2]`, string(AddToInfo([]byte(`[1,2]`), loc)))
+}
@@ -0,0 +1,24 @@
+package geoip
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var numClientsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "centrifugo",
+	Subsystem: "geoip",
+	Name:      "num_clients",
+	Help:      "Number of clients connected to node by country.",
+}, []string{"country"})
+
+func init() {
+	prometheus.MustRegister(numClientsGauge)
+}
+
+// IncClients increments number of clients in Location.
+func IncClients(loc Location) {
+	numClientsGauge.WithLabelValues(loc.Country).Inc()
+}
+
+// DecClients decrements number of clients in Location.
+func DecClients(loc Location) {
+	numClientsGauge.WithLabelValues(loc.Country).Dec()
+}
@@ -0,0 +1,306 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+)
+
+// Minimal reader of MaxMind DB format, see
+// https://maxmind.github.io/MaxMind-DB/ for format specification.
+
+var metadataStartMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+const dataSectionSeparatorSize = 16
+
+var errInvalidDatabase = errors.New("invalid MaxMind DB")
+
+type mmdb struct {
+	buf        []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	treeSize   uint
+	ipv4Start  uint
+}
+
+func newMMDB(buf []byte) (*mmdb, error) {
+	i := bytes.LastIndex(buf, metadataStartMarker)
+	if i == -1 {
+		return nil, errInvalidDatabase
+	}
+	metaStart := i + len(metadataStartMarker)
+	meta, _, err := (&decoder{buf: buf[metaStart:]}).decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding metadata: %w", err)
+	}
+	metaMap, ok := meta.(map[string]interface{})
+	if !ok {
+		return nil, errInvalidDatabase
+	}
+	db := &mmdb{buf: buf}
+	db.nodeCount, ok = toUint(metaMap["node_count"])
+	if !ok {
+		return nil, errInvalidDatabase
+	}
+	db.recordSize, ok = toUint(metaMap["record_size"])
+	if !ok || (db.recordSize != 24 && db.recordSize != 28 && db.recordSize != 32) {
+		return nil, errInvalidDatabase
+	}
+	db.ipVersion, ok = toUint(metaMap["ip_version"])
+	if !ok || (db.ipVersion != 4 && db.ipVersion != 6) {
+		return nil, errInvalidDatabase
+	}
+	db.treeSize = db.nodeCount * db.recordSize / 4
+	if db.treeSize+dataSectionSeparatorSize > uint(i) {
+		return nil, errInvalidDatabase
+	}
+	if db.ipVersion == 6 {
+		// IPv4 addresses are in ::/96 subtree of IPv6 database.
+		node := uint(0)
+		for j := 0; j < 96 && node < db.nodeCount; j++ {
+			node, err = db.readNode(node, 0)
+			if err != nil {
+				return nil, err
+			}
+		}
+		db.ipv4Start = node
+	}
+	return db, nil
+}
+
+func toUint(v interface{}) (uint, bool) {
+	switch val := v.(type) {
+	case uint64:
+		return uint(val), true
+	case uint32:
+		return uint(val), true
+	case uint16:
+		return uint(val), true
+	default:
+		return 0, false
+	}
+}
+
+func (db *mmdb) readNode(node uint, bit uint) (uint, error) {
+	offset := node * db.recordSize / 4
+	if offset+db.recordSize/4 > uint(len(db.buf)) {
+		return 0, errInvalidDatabase
+	}
+	b := db.buf[offset:]
+	switch db.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+	case 28:
+		if bit == 0 {
+			return (uint(b[3])&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+		}
+		return (uint(b[3])&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6]), nil
+	default:
+		b = b[bit*4:]
+		return uint(binary.BigEndian.Uint32(b)), nil
+	}
+}
+
+// lookup returns decoded data record for IP, nil if there is no record.
+func (db *mmdb) lookup(ip net.IP) (interface{}, error) {
+	node := uint(0)
+	bitCount := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bitCount = 32
+		node = db.ipv4Start
+	} else if db.ipVersion == 4 {
+		return nil, nil
+	}
+	for i := 0; i < bitCount && node < db.nodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-uint(i%8))) & 1
+		var err error
+		node, err = db.readNode(node, bit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if node <= db.nodeCount {
+		// Equal to node count means there is no data for IP.
+		return nil, nil
+	}
+	dataStart := db.treeSize + dataSectionSeparatorSize
+	offset := node - db.nodeCount - dataSectionSeparatorSize
+	d := &decoder{buf: db.buf[dataStart:]}
+	value, _, err := d.decode(offset)
+	return value, err
+}
+
+// Data types of MaxMind DB data section.
+const (
+	typeExtended = 0
+	typePointer  = 1
+	typeString   = 2
+	typeDouble   = 3
+	typeBytes    = 4
+	typeUint16   = 5
+	typeUint32   = 6
+	typeMap      = 7
+	typeInt32    = 8
+	typeUint64   = 9
+	typeUint128  = 10
+	typeArray    = 11
+	typeBool     = 14
+	typeFloat    = 15
+)
+
+type decoder struct {
+	buf []byte
+}
+
+func (d *decoder) bytes(offset uint, size uint) ([]byte, error) {
+	if offset+size > uint(len(d.buf)) {
+		return nil, errInvalidDatabase
+	}
+	return d.buf[offset : offset+size], nil
+}
+
+func (d *decoder) uintValue(offset uint, size uint) (uint64, error) {
+	b, err := d.bytes(offset, size)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// decode decodes value at offset and returns offset after value.
+func (d *decoder) decode(offset uint) (interface{}, uint, error) {
+	b, err := d.bytes(offset, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	ctrl := b[0]
+	offset++
+	typeNum := uint(ctrl >> 5)
+	if typeNum == typeExtended {
+		b, err := d.bytes(offset, 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		typeNum = 7 + uint(b[0])
+		offset++
+	}
+	if typeNum == typePointer {
+		pointer, next, err := d.pointer(uint(ctrl), offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(pointer)
+		return value, next, err
+	}
+	size := uint(ctrl & 0x1F)
+	if size >= 29 {
+		n := size - 28
+		v, err := d.uintValue(offset, n)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset += n
+		switch n {
+		case 1:
+			size = 29 + uint(v)
+		case 2:
+			size = 285 + uint(v)
+		default:
+			size = 65821 + uint(v)
+		}
+	}
+	switch typeNum {
+	case typeString:
+		b, err := d.bytes(offset, size)
+		return string(b), offset + size, err
+	case typeBytes:
+		b, err := d.bytes(offset, size)
+		return b, offset + size, err
+	case typeDouble:
+		v, err := d.uintValue(offset, 8)
+		return math.Float64frombits(v), offset + 8, err
+	case typeFloat:
+		v, err := d.uintValue(offset, 4)
+		return math.Float32frombits(uint32(v)), offset + 4, err
+	case typeUint16:
+		v, err := d.uintValue(offset, size)
+		return uint16(v), offset + size, err
+	case typeUint32:
+		v, err := d.uintValue(offset, size)
+		return uint32(v), offset + size, err
+	case typeInt32:
+		v, err := d.uintValue(offset, size)
+		return int32(v), offset + size, err
+	case typeUint64:
+		v, err := d.uintValue(offset, size)
+		return v, offset + size, err
+	case typeUint128:
+		b, err := d.bytes(offset, size)
+		return b, offset + size, err
+	case typeBool:
+		return size != 0, offset, nil
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, errInvalidDatabase
+			}
+			value, next, err := d.decode(next)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[k] = value
+			offset = next
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := d.decode(offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported MaxMind DB data type: %d", typeNum)
+	}
+}
+
+func (d *decoder) pointer(ctrl uint, offset uint) (uint, uint, error) {
+	size := ((ctrl >> 3) & 0x3) + 1
+	v, err := d.uintValue(offset, size)
+	if err != nil {
+		return 0, 0, err
+	}
+	prefix := uint64(ctrl & 0x7)
+	var pointer uint64
+	switch size {
+	case 1:
+		pointer = prefix<<8 | v
+	case 2:
+		pointer = (prefix<<16 | v) + 2048
+	case 3:
+		pointer = (prefix<<24 | v) + 526336
+	default:
+		pointer = v
+	}
+	return uint(pointer), offset + size, nil
+}
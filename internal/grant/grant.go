@@ -0,0 +1,122 @@
+// Package grant contains per-connection channel permission grants. Grants are
+// issued by application backend in connection JWT or in connect proxy result
+// and evaluated at subscribe time: grant allows subscribing on channels
+// matching its pattern even if channel options require roles or protected
+// namespace, and can override subscription options. Private channels still
+// require subscription token.
+package grant
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/gobwas/glob"
+)
+
+// BoolValue allows override boolean option.
+type BoolValue struct {
+	Value bool `json:"value,omitempty"`
+}
+
+// Override of subscribe options. Options with nil value are not overridden.
+type Override struct {
+	Presence  *BoolValue `json:"presence,omitempty"`
+	JoinLeave *BoolValue `json:"join_leave,omitempty"`
+	Recover   *BoolValue `json:"recover,omitempty"`
+	Position  *BoolValue `json:"position,omitempty"`
+}
+
+// Grant allows connection to subscribe on channels matching Channel glob
+// pattern. Grant has the same JSON form in connection token claims and in
+// connect proxy result.
+type Grant struct {
+	// Channel is a channel glob pattern, ex. "chat:*". Wildcards do not match
+	// channel namespace boundary, "**" should be used to match across it.
+	Channel string `json:"channel"`
+	// Override of channel subscribe options, optional.
+	Override *Override `json:"override,omitempty"`
+}
+
+// Apply overrides subscribe options with ones set in Grant.
+func (g Grant) Apply(opts *centrifuge.SubscribeOptions) {
+	o := g.Override
+	if o == nil {
+		return
+	}
+	if o.Presence != nil {
+		opts.Presence = o.Presence.Value
+	}
+	if o.JoinLeave != nil {
+		opts.JoinLeave = o.JoinLeave.Value
+	}
+	if o.Recover != nil {
+		opts.Recover = o.Recover.Value
+	}
+	if o.Position != nil {
+		opts.Position = o.Position.Value
+	}
+}
+
+type compiledGrant struct {
+	Grant
+	glob glob.Glob
+}
+
+// Grants are compiled connection grants.
+type Grants struct {
+	grants []compiledGrant
+}
+
+// Compile grants using channel namespace boundary as glob separator. Nil
+// returned if there are no grants.
+func Compile(grants []Grant, boundary string) (*Grants, error) {
+	if len(grants) == 0 {
+		return nil, nil
+	}
+	separators := []rune(boundary)
+	compiled := make([]compiledGrant, 0, len(grants))
+	for _, g := range grants {
+		if g.Channel == "" {
+			return nil, fmt.Errorf("empty grant channel pattern")
+		}
+		gl, err := glob.Compile(g.Channel, separators...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid grant channel pattern %s: %w", g.Channel, err)
+		}
+		compiled = append(compiled, compiledGrant{Grant: g, glob: gl})
+	}
+	return &Grants{grants: compiled}, nil
+}
+
+// Match returns first grant which pattern matches channel.
+func (g *Grants) Match(channel string) (Grant, bool) {
+	if g == nil {
+		return Grant{}, false
+	}
+	for _, cg := range g.grants {
+		if cg.glob.Match(channel) {
+			return cg.Grant, true
+		}
+	}
+	return Grant{}, false
+}
+
+type contextKey struct{}
+
+// SetToContext puts connection grants to context.
+func SetToContext(ctx context.Context, grants *Grants) context.Context {
+	return context.WithValue(ctx, contextKey{}, grants)
+}
+
+// FromContext returns connection grants from context.
+func FromContext(ctx context.Context) (*Grants, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	if val := ctx.Value(contextKey{}); val != nil {
+		grants, ok := val.(*Grants)
+		return grants, ok
+	}
+	return nil, false
+}
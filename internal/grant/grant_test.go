@@ -0,0 +1,78 @@
+package grant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	grants, err := Compile(nil, ":")
+	require.NoError(t, err)
+	require.Nil(t, grants)
+	_, ok := grants.Match("chat:index")
+	require.False(t, ok)
+
+	_, err = Compile([]Grant{{Channel: ""}}, ":")
+	require.Error(t, err)
+	_, err = Compile([]Grant{{Channel: "chat:[a"}}, ":")
+	require.Error(t, err)
+}
+
+func TestGrantsMatch(t *testing.T) {
+	grants, err := Compile([]Grant{
+		{Channel: "chat:room_*", Override: &Override{Presence: &BoolValue{Value: true}}},
+		{Channel: "chat:*", Override: &Override{Recover: &BoolValue{Value: true}, Position: &BoolValue{Value: false}}},
+	}, ":")
+	require.NoError(t, err)
+
+	g, ok := grants.Match("chat:room_1")
+	require.True(t, ok)
+	require.Equal(t, "chat:room_*", g.Channel)
+
+	g, ok = grants.Match("chat:index")
+	require.True(t, ok)
+	require.Equal(t, "chat:*", g.Channel)
+
+	_, ok = grants.Match("news:index")
+	require.False(t, ok)
+
+	opts := centrifuge.SubscribeOptions{Position: true, JoinLeave: true}
+	g.Apply(&opts)
+	require.Equal(t, centrifuge.SubscribeOptions{Recover: true, JoinLeave: true}, opts)
+}
+
+func TestGrantsMatchBoundary(t *testing.T) {
+	grants, err := Compile([]Grant{{Channel: "chat:*"}, {Channel: "news:**"}}, ":")
+	require.NoError(t, err)
+
+	_, ok := grants.Match("chat:index")
+	require.True(t, ok)
+	_, ok = grants.Match("chat:index:admin")
+	require.False(t, ok, "wildcard must not match across namespace boundary")
+	_, ok = grants.Match("news:index:admin")
+	require.True(t, ok)
+
+	grants, err = Compile([]Grant{{Channel: "chat/*"}}, "/")
+	require.NoError(t, err)
+	_, ok = grants.Match("chat/index/admin")
+	require.False(t, ok)
+}
+
+func TestGrantApplyNoOverride(t *testing.T) {
+	opts := centrifuge.SubscribeOptions{Presence: true}
+	Grant{Channel: "*"}.Apply(&opts)
+	require.Equal(t, centrifuge.SubscribeOptions{Presence: true}, opts)
+}
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	require.False(t, ok)
+	grants, err := Compile([]Grant{{Channel: "*"}}, ":")
+	require.NoError(t, err)
+	got, ok := FromContext(SetToContext(context.Background(), grants))
+	require.True(t, ok)
+	require.Equal(t, grants, got)
+}
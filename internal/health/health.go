@@ -1,7 +1,12 @@
 package health
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/centrifugal/centrifuge"
 )
@@ -28,3 +33,118 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_, _ = w.Write([]byte(`{}`))
 }
+
+// Check is a named connectivity check of component required to serve traffic
+// (for example a single engine shard).
+type Check struct {
+	// Name of check, shown in readiness response.
+	Name string
+	// Func must return nil if component is available. It should respect
+	// context deadline. Error wrapping ErrDegraded reports that component
+	// works with reduced capacity.
+	Func func(ctx context.Context) error
+}
+
+// ErrDegraded should be wrapped by check errors when component is available
+// but does not work in full (for example catches up after reconnect). Node
+// with degraded checks is still considered ready.
+var ErrDegraded = errors.New("degraded")
+
+// DefaultReadyTimeout used when ReadyConfig.Timeout not set.
+const DefaultReadyTimeout = time.Second
+
+// ReadyConfig of readiness handler.
+type ReadyConfig struct {
+	// Checks to run on every readiness request. All checks run concurrently.
+	Checks []Check
+	// Timeout for running all checks.
+	Timeout time.Duration
+}
+
+// ReadyHandler handles readiness endpoint. Node considered ready when all
+// configured checks passed.
+type ReadyHandler struct {
+	node   *centrifuge.Node
+	config ReadyConfig
+}
+
+// NewReadyHandler creates new ReadyHandler.
+func NewReadyHandler(n *centrifuge.Node, c ReadyConfig) *ReadyHandler {
+	h := &ReadyHandler{
+		node:   n,
+		config: c,
+	}
+	return h
+}
+
+const (
+	statusOK       = "ok"
+	statusDegraded = "degraded"
+	statusError    = "error"
+)
+
+type checkResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type readyResult struct {
+	Status string        `json:"status"`
+	Checks []checkResult `json:"checks"`
+}
+
+func (h *ReadyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	timeout := h.config.Timeout
+	if timeout == 0 {
+		timeout = DefaultReadyTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	result := readyResult{
+		Status: statusOK,
+		Checks: make([]checkResult, len(h.config.Checks)),
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(h.config.Checks))
+	for i, check := range h.config.Checks {
+		go func(i int, check Check) {
+			defer wg.Done()
+			res := checkResult{Name: check.Name, Status: statusOK}
+			if err := check.Func(ctx); err != nil {
+				res.Status = statusError
+				if errors.Is(err, ErrDegraded) {
+					res.Status = statusDegraded
+				}
+				res.Error = err.Error()
+			}
+			result.Checks[i] = res
+		}(i, check)
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	for _, res := range result.Checks {
+		switch res.Status {
+		case statusDegraded:
+			if result.Status == statusOK {
+				result.Status = statusDegraded
+			}
+		case statusError:
+			result.Status = statusError
+			code = http.StatusServiceUnavailable
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "readiness check failed", map[string]interface{}{"check": res.Name, "error": res.Error}))
+		}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
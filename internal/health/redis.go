@@ -0,0 +1,171 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/redisengine"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/FZambia/sentinel"
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisShardChecks returns a list of checks to PING every Redis shard. In case
+// of Redis Cluster every configured cluster address is checked separately.
+func RedisShardChecks(shardConfigs []redisengine.ShardConfig) []Check {
+	var checks []Check
+	for _, conf := range shardConfigs {
+		conf := conf
+		switch {
+		case len(conf.ClusterAddresses) > 0:
+			for _, address := range conf.ClusterAddresses {
+				address := address
+				checks = append(checks, Check{
+					Name: "redis_cluster " + tools.StripPassword(address),
+					Func: func(ctx context.Context) error {
+						return pingRedis(ctx, "tcp", address, conf)
+					},
+				})
+			}
+		case len(conf.SentinelAddresses) > 0:
+			checks = append(checks, Check{
+				Name: "redis_sentinel " + conf.SentinelMasterName,
+				Func: func(ctx context.Context) error {
+					return pingRedisSentinelMaster(ctx, conf)
+				},
+			})
+		default:
+			checks = append(checks, Check{
+				Name: "redis " + tools.StripPassword(conf.Address),
+				Func: func(ctx context.Context) error {
+					network, address, shardConf, err := parseRedisAddress(conf.Address, conf)
+					if err != nil {
+						return err
+					}
+					return pingRedis(ctx, network, address, shardConf)
+				},
+			})
+		}
+	}
+	return checks
+}
+
+// RedisResubscribeCheck returns a check which reports node degraded while
+// Redis broker resubscribes to channels after PUB/SUB reconnect – node does
+// not receive publications of some channels during this time.
+func RedisResubscribeCheck(broker *redisengine.Broker) Check {
+	return Check{
+		Name: "redis_resubscribe",
+		Func: func(_ context.Context) error {
+			if pending := broker.ResubscribePending(); pending > 0 {
+				return fmt.Errorf("%w: %d channels waiting for resubscribe", ErrDegraded, pending)
+			}
+			return nil
+		},
+	}
+}
+
+// parseRedisAddress extracts network and address to dial from address in
+// host:port or tcp://, redis://, unix:// URL formats. Password and DB from URL
+// override ones from shard config.
+func parseRedisAddress(address string, conf redisengine.ShardConfig) (string, string, redisengine.ShardConfig, error) {
+	if !strings.HasPrefix(address, "tcp://") && !strings.HasPrefix(address, "redis://") && !strings.HasPrefix(address, "unix://") {
+		if host, port, err := net.SplitHostPort(address); err == nil && host != "" && port != "" {
+			return "tcp", address, conf, nil
+		}
+		return "", "", conf, errors.New("malformed connection address")
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", "", conf, errors.New("malformed connection address")
+	}
+	var network string
+	switch u.Scheme {
+	case "tcp", "redis":
+		network = "tcp"
+		address = u.Host
+		if u.Path != "" {
+			db, err := strconv.Atoi(strings.TrimPrefix(u.Path, "/"))
+			if err != nil {
+				return "", "", conf, fmt.Errorf("can't parse Redis DB number from connection address")
+			}
+			conf.DB = db
+		}
+	default:
+		network = "unix"
+		address = u.Path
+	}
+	if u.User != nil {
+		if user := u.User.Username(); user != "" {
+			conf.User = user
+		}
+		if pass, ok := u.User.Password(); ok {
+			conf.Password = pass
+		}
+	}
+	return network, address, conf, nil
+}
+
+func redisDialOptions(ctx context.Context, conf redisengine.ShardConfig) []redis.DialOption {
+	timeout := DefaultReadyTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	opts := []redis.DialOption{
+		redis.DialReadTimeout(timeout),
+		redis.DialWriteTimeout(timeout),
+	}
+	if conf.Password != "" {
+		if conf.User != "" {
+			opts = append(opts, redis.DialUsername(conf.User))
+		}
+		opts = append(opts, redis.DialPassword(conf.Password))
+	}
+	if conf.DB != 0 {
+		opts = append(opts, redis.DialDatabase(conf.DB))
+	}
+	if conf.UseTLS {
+		opts = append(opts, redis.DialUseTLS(true))
+		if conf.TLSConfig != nil {
+			opts = append(opts, redis.DialTLSConfig(conf.TLSConfig))
+		}
+		if conf.TLSSkipVerify {
+			opts = append(opts, redis.DialTLSSkipVerify(true))
+		}
+	}
+	return opts
+}
+
+func pingRedis(ctx context.Context, network string, address string, conf redisengine.ShardConfig) error {
+	c, err := redis.DialContext(ctx, network, address, redisDialOptions(ctx, conf)...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.Close() }()
+	_, err = c.Do("PING")
+	return err
+}
+
+func pingRedisSentinelMaster(ctx context.Context, conf redisengine.ShardConfig) error {
+	sntnl := &sentinel.Sentinel{
+		Addrs:      conf.SentinelAddresses,
+		MasterName: conf.SentinelMasterName,
+		Dial: func(addr string) (redis.Conn, error) {
+			opts := redisDialOptions(ctx, redisengine.ShardConfig{User: conf.SentinelUser, Password: conf.SentinelPassword})
+			return redis.DialContext(ctx, "tcp", addr, opts...)
+		},
+	}
+	defer func() { _ = sntnl.Close() }()
+	masterAddr, err := sntnl.MasterAddr()
+	if err != nil {
+		return err
+	}
+	return pingRedis(ctx, "tcp", masterAddr, conf)
+}
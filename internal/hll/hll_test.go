@@ -0,0 +1,43 @@
+package hll
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSketch(t *testing.T) {
+	s := New()
+	require.Equal(t, uint64(0), s.Count())
+
+	for i := 0; i < 10; i++ {
+		s.Add("channel")
+	}
+	require.Equal(t, uint64(1), s.Count())
+
+	for _, n := range []int{1000, 100000} {
+		s := New()
+		for i := 0; i < n; i++ {
+			s.Add("channel_" + strconv.Itoa(i))
+		}
+		require.InDelta(t, n, s.Count(), float64(n)*0.05)
+	}
+}
+
+func TestSketchMerge(t *testing.T) {
+	s1 := New()
+	s2 := New()
+	for i := 0; i < 20000; i++ {
+		s1.Add("channel_" + strconv.Itoa(i))
+		// Half of channels exist on both nodes.
+		s2.Add("channel_" + strconv.Itoa(i+10000))
+	}
+	decoded, err := FromBytes(s2.Bytes())
+	require.NoError(t, err)
+	s1.Merge(decoded)
+	require.InDelta(t, 30000, s1.Count(), 30000*0.05)
+
+	_, err = FromBytes([]byte("x"))
+	require.Equal(t, ErrInvalidSketch, err)
+}
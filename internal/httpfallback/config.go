@@ -0,0 +1,37 @@
+package httpfallback
+
+import (
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/delta"
+	"github.com/centrifugal/centrifugo/v3/internal/throttle"
+)
+
+// Config of HTTP fallback transport.
+type Config struct {
+	// HandlerPrefix is a path prefix handler registered with.
+	HandlerPrefix string
+	// MaxRequestBodySize limits request body size.
+	MaxRequestBodySize int
+	// SessionTTL is a time session kept alive without attached receiver.
+	SessionTTL time.Duration
+	// PollTimeout is a max time poll request waits for messages. Client can
+	// ask for shorter timeout with timeout URL query parameter in seconds.
+	PollTimeout time.Duration
+	// PollBatchDelay is a time poll request waits for more messages after
+	// the first one arrived to deliver them in one response. Zero value means
+	// response sent as soon as any message arrived.
+	PollBatchDelay time.Duration
+	// PollMaxBatchSize is a max number of messages in poll response, messages
+	// above it are delivered by next poll requests. Zero value means no limit.
+	PollMaxBatchSize int
+	// PingInterval is an interval of empty frames sent in stream to keep it alive.
+	PingInterval time.Duration
+	// MaxQueueSize is a max number of messages waiting for receiver, client
+	// disconnected with DisconnectSlow when queue is full.
+	MaxQueueSize int
+	// Throttle configures rate limiting of messages sent to clients.
+	Throttle throttle.Config
+	// Delta allows sending publications as patches to clients which ask for it.
+	Delta *delta.Manager
+}
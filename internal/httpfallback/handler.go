@@ -0,0 +1,348 @@
+package httpfallback
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/pubtags"
+	"github.com/centrifugal/centrifugo/v3/internal/throttle"
+	"github.com/centrifugal/centrifugo/v3/internal/wshandler"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/google/uuid"
+)
+
+const (
+	// SessionHeader is a response header with session ID.
+	SessionHeader = "X-Centrifugo-Session"
+	// sessionParam is a URL query parameter with session ID.
+	sessionParam = "session"
+	// pollTimeoutParam is a URL query parameter with poll timeout in seconds.
+	pollTimeoutParam = "timeout"
+)
+
+const (
+	defaultSessionTTL   = 10 * time.Second
+	defaultPollTimeout  = 25 * time.Second
+	defaultPingInterval = 25 * time.Second
+	defaultMaxQueueSize = 1000
+)
+
+// Handler serves bidirectional connections over plain HTTP requests for
+// clients which can't use WebSocket. Connection state is kept in session
+// between requests, session messages can be received over HTTP-streaming or
+// XHR-polling and client commands sent with separate requests. All requests
+// use POST method, paths relative to handler prefix are:
+//
+//	/stream – receive messages over HTTP-streaming
+//	/poll – receive messages over long-polling
+//	/send – send commands
+//	/close – close session
+//
+// Poll request waits for messages up to poll timeout (or shorter timeout from
+// timeout query parameter in seconds) and delivers all queued messages in one
+// response, optionally waiting batch delay for more messages and limiting
+// number of messages in response.
+//
+// Stream or poll request without session query parameter creates new session,
+// its body may contain commands (usually connect command). Session ID returned
+// in SessionHeader response header. Messages in response are delimited with
+// new line for JSON protocol and prefixed with varint length for Protobuf
+// protocol (format=protobuf URL query parameter on session creation), empty
+// frames used as pings. Requests to closed session get 410 Gone response with
+// disconnect object in JSON body, requests to unknown session get 404.
+type Handler struct {
+	node   *centrifuge.Node
+	config Config
+	hub    *sessionHub
+}
+
+// NewHandler creates Handler.
+func NewHandler(n *centrifuge.Node, c Config) *Handler {
+	if c.SessionTTL <= 0 {
+		c.SessionTTL = defaultSessionTTL
+	}
+	if c.PollTimeout <= 0 {
+		c.PollTimeout = defaultPollTimeout
+	}
+	if c.PingInterval <= 0 {
+		c.PingInterval = defaultPingInterval
+	}
+	if c.MaxQueueSize <= 0 {
+		c.MaxQueueSize = defaultMaxQueueSize
+	}
+	h := &Handler{
+		node:   n,
+		config: c,
+		hub:    newSessionHub(),
+	}
+	go h.runExpiration()
+	return h
+}
+
+func (h *Handler) runExpiration() {
+	for {
+		time.Sleep(time.Second)
+		h.hub.expire(time.Now(), h.config.SessionTTL)
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, ok := h.readBody(w, r)
+	if !ok {
+		return
+	}
+
+	action := strings.TrimPrefix(r.URL.Path, h.config.HandlerPrefix)
+	switch action {
+	case "/stream", "/poll":
+	case "/send", "/close":
+		if r.URL.Query().Get(sessionParam) == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var s *session
+	if sessionID := r.URL.Query().Get(sessionParam); sessionID != "" {
+		s, ok = h.hub.get(sessionID)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if len(body) > 0 && action != "/send" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	} else {
+		var err error
+		s, err = h.newSession(r)
+		if err != nil {
+			h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error create client", map[string]interface{}{"error": err.Error(), "transport": transportName}))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set(SessionHeader, s.id)
+	w.Header().Set("Access-Control-Expose-Headers", SessionHeader)
+
+	switch action {
+	case "/stream":
+		h.handleStream(w, r, s, body)
+	case "/poll":
+		h.handlePoll(w, r, s, body)
+	case "/send":
+		h.handleSend(w, s, body)
+	case "/close":
+		_ = s.closeFn()
+		h.hub.remove(s.id)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *Handler) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	maxBytesSize := int64(h.config.MaxRequestBodySize)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytesSize)
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		if len(data) >= int(maxBytesSize) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error reading body", map[string]interface{}{"error": err.Error()}))
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, false
+	}
+	return data, true
+}
+
+func (h *Handler) newSession(r *http.Request) (*session, error) {
+	protoType := centrifuge.ProtocolTypeJSON
+	if r.URL.Query().Get("format") == "protobuf" || r.URL.Query().Get("protocol") == "protobuf" {
+		protoType = centrifuge.ProtocolTypeProtobuf
+	}
+	s := newSession(uuid.New().String(), protoType, h.config.MaxQueueSize)
+	// Client lives longer than request, so its context canceled only when
+	// session closed.
+	ctx := wshandler.NewCancelContext(r.Context(), s.closeCh)
+	c, closeFn, err := centrifuge.NewClient(ctx, h.node, pubtags.NewTransport(throttle.NewTransport(h.config.Delta.NewTransport(s, r), h.config.Throttle)))
+	if err != nil {
+		return nil, err
+	}
+	s.client = c
+	s.closeFn = closeFn
+	h.hub.add(s)
+	h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "client session created", map[string]interface{}{"transport": transportName, "client": c.ID(), "session": s.id}))
+	return s, nil
+}
+
+// handleClosed removes closed session which has no messages to deliver and
+// writes its disconnect.
+func (h *Handler) handleClosed(w http.ResponseWriter, s *session) {
+	h.hub.remove(s.id)
+	writeDisconnect(w, s)
+}
+
+func writeDisconnect(w http.ResponseWriter, s *session) {
+	data, _ := json.Marshal(s.closeDisconnect())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusGone)
+	_, _ = w.Write(data)
+}
+
+func setNoCacheHeaders(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "private, no-cache, no-store, must-revalidate, max-age=0")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Expire", "0")
+}
+
+func (h *Handler) handleStream(w http.ResponseWriter, r *http.Request, s *session, commands []byte) {
+	receiverCh := s.attach()
+	defer s.detach(receiverCh)
+
+	if len(commands) > 0 {
+		s.client.Handle(commands)
+	}
+
+	messages, closed := s.take()
+	if closed && len(messages) == 0 {
+		h.handleClosed(w, s)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	if r.ProtoMajor == 1 {
+		// An endpoint MUST NOT generate an HTTP/2 message containing connection-specific header fields.
+		// Source: RFC7540.
+		w.Header().Set("Connection", "keep-alive")
+	}
+	w.Header().Set("X-Accel-Buffering", "no")
+	setNoCacheHeaders(w)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	tick := time.NewTicker(h.config.PingInterval)
+	defer tick.Stop()
+
+	for {
+		for _, data := range messages {
+			if err := s.writeFrame(w, data); err != nil {
+				return
+			}
+		}
+		if len(messages) > 0 {
+			flusher.Flush()
+			tick.Reset(h.config.PingInterval)
+		}
+		if closed {
+			// Client gets disconnect reason on next request.
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-receiverCh:
+			return
+		case <-s.closeCh:
+		case <-s.notifyCh:
+		case <-tick.C:
+			if err := s.writeFrame(w, nil); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+		messages, closed = s.take()
+	}
+}
+
+func (h *Handler) handlePoll(w http.ResponseWriter, r *http.Request, s *session, commands []byte) {
+	receiverCh := s.attach()
+	defer s.detach(receiverCh)
+
+	if len(commands) > 0 {
+		s.client.Handle(commands)
+	}
+
+	messages, closed := s.takeLimit(h.config.PollMaxBatchSize)
+	if len(messages) == 0 && !closed {
+		timer := time.NewTimer(h.pollTimeout(r))
+		select {
+		case <-r.Context().Done():
+		case <-receiverCh:
+		case <-s.closeCh:
+		case <-s.notifyCh:
+			if h.config.PollBatchDelay > 0 {
+				// Wait a bit more to deliver following messages in the same
+				// response.
+				timer.Reset(h.config.PollBatchDelay)
+				select {
+				case <-r.Context().Done():
+				case <-receiverCh:
+				case <-s.closeCh:
+				case <-timer.C:
+				}
+			}
+		case <-timer.C:
+		}
+		timer.Stop()
+		messages, closed = s.takeLimit(h.config.PollMaxBatchSize)
+	}
+	if closed && len(messages) == 0 {
+		h.handleClosed(w, s)
+		return
+	}
+
+	setNoCacheHeaders(w)
+	w.WriteHeader(http.StatusOK)
+	for _, data := range messages {
+		if err := s.writeFrame(w, data); err != nil {
+			return
+		}
+	}
+}
+
+// pollTimeout returns time poll request waits for messages, client can ask
+// for timeout shorter than configured one.
+func (h *Handler) pollTimeout(r *http.Request) time.Duration {
+	seconds, err := strconv.Atoi(r.URL.Query().Get(pollTimeoutParam))
+	if err != nil || seconds <= 0 {
+		return h.config.PollTimeout
+	}
+	if timeout := time.Duration(seconds) * time.Second; timeout < h.config.PollTimeout {
+		return timeout
+	}
+	return h.config.PollTimeout
+}
+
+func (h *Handler) handleSend(w http.ResponseWriter, s *session, commands []byte) {
+	if s.isClosed() {
+		writeDisconnect(w, s)
+		return
+	}
+	if len(commands) > 0 {
+		s.client.Handle(commands)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,169 @@
+package httpfallback
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) (*centrifuge.Node, *httptest.Server) {
+	return newTestServerWithConfig(t, Config{
+		HandlerPrefix:      "/connection/http_fallback",
+		MaxRequestBodySize: 65536,
+		PollTimeout:        100 * time.Millisecond,
+	})
+}
+
+func newTestServerWithConfig(t *testing.T, c Config) (*centrifuge.Node, *httptest.Server) {
+	node := tools.NodeWithMemoryEngine()
+	node.OnConnecting(func(ctx context.Context, event centrifuge.ConnectEvent) (centrifuge.ConnectReply, error) {
+		return centrifuge.ConnectReply{
+			Credentials: &centrifuge.Credentials{UserID: "12"},
+		}, nil
+	})
+	h := NewHandler(node, c)
+	server := httptest.NewServer(h)
+	t.Cleanup(func() {
+		server.Close()
+		_ = node.Shutdown(context.Background())
+	})
+	return node, server
+}
+
+func post(t *testing.T, url string, body string) (*http.Response, string) {
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(data)
+}
+
+func TestHandlerPoll(t *testing.T) {
+	_, server := newTestServer(t)
+	prefix := server.URL + "/connection/http_fallback"
+
+	resp, body := post(t, prefix+"/poll", `{"id":1}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, body, `"id":1`)
+	require.Contains(t, body, `"client"`)
+	sessionID := resp.Header.Get(SessionHeader)
+	require.NotEmpty(t, sessionID)
+
+	resp, _ = post(t, prefix+"/send?session="+sessionID, `{"id":2,"method":1,"params":{"channel":"test"}}`)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, body = post(t, prefix+"/poll?session="+sessionID, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, body, `"id":2`)
+
+	resp, body = post(t, prefix+"/poll?session="+sessionID, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, body)
+
+	resp, _ = post(t, prefix+"/close?session="+sessionID, "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, _ = post(t, prefix+"/poll?session="+sessionID, "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestHandlerPollBatch(t *testing.T) {
+	node, server := newTestServerWithConfig(t, Config{
+		HandlerPrefix:      "/connection/http_fallback",
+		MaxRequestBodySize: 65536,
+		PollTimeout:        5 * time.Second,
+		PollBatchDelay:     100 * time.Millisecond,
+		PollMaxBatchSize:   2,
+	})
+	prefix := server.URL + "/connection/http_fallback"
+
+	resp, _ := post(t, prefix+"/poll", `{"id":1}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	sessionID := resp.Header.Get(SessionHeader)
+
+	resp, _ = post(t, prefix+"/send?session="+sessionID, `{"id":2,"method":1,"params":{"channel":"test"}}`)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, body := post(t, prefix+"/poll?session="+sessionID, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, body, `"id":2`)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		for _, data := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`} {
+			_, _ = node.Publish("test", []byte(data))
+		}
+	}()
+	// Messages published one by one delivered in one response limited by
+	// max batch size.
+	resp, body = post(t, prefix+"/poll?session="+sessionID, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, 2, strings.Count(body, "\n"))
+	require.Contains(t, body, `{"n":1}`)
+	require.Contains(t, body, `{"n":2}`)
+	require.NotContains(t, body, `{"n":3}`)
+
+	resp, body = post(t, prefix+"/poll?session="+sessionID, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, body, `{"n":3}`)
+
+	// Client asks for shorter timeout.
+	started := time.Now()
+	resp, body = post(t, prefix+"/poll?timeout=1&session="+sessionID, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, body)
+	require.Less(t, time.Since(started), 3*time.Second)
+}
+
+func TestHandlerStream(t *testing.T) {
+	node, server := newTestServer(t)
+	prefix := server.URL + "/connection/http_fallback"
+
+	resp, err := http.Post(prefix+"/stream", "application/json", strings.NewReader(`{"id":1}`))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	sessionID := resp.Header.Get(SessionHeader)
+	require.NotEmpty(t, sessionID)
+
+	readLine := func() string {
+		var line []byte
+		buf := make([]byte, 1)
+		for {
+			_, err := resp.Body.Read(buf)
+			require.NoError(t, err)
+			if buf[0] == '\n' {
+				return string(line)
+			}
+			line = append(line, buf[0])
+		}
+	}
+	require.Contains(t, readLine(), `"id":1`)
+
+	sendResp, _ := post(t, prefix+"/send?session="+sessionID, `{"id":2,"method":1,"params":{"channel":"test"}}`)
+	require.Equal(t, http.StatusNoContent, sendResp.StatusCode)
+	require.Contains(t, readLine(), `"id":2`)
+
+	_, err = node.Publish("test", []byte(`{"input":"hello"}`))
+	require.NoError(t, err)
+	require.Contains(t, readLine(), `"hello"`)
+
+	_ = node.Disconnect("12", centrifuge.WithDisconnect(centrifuge.DisconnectForceNoReconnect))
+	rest, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	// Disconnect push is the last message in stream.
+	require.Contains(t, string(rest), `"code":3012`)
+
+	closedResp, body := post(t, prefix+"/stream?session="+sessionID, "")
+	require.Equal(t, http.StatusGone, closedResp.StatusCode)
+	require.Contains(t, body, `"reconnect":false`)
+}
@@ -0,0 +1,245 @@
+package httpfallback
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+const transportName = "http_fallback"
+
+// session keeps connection state between HTTP requests. Session is a
+// bidirectional transport: replies are queued until one of receiver requests
+// (stream or poll) takes them.
+type session struct {
+	id           string
+	protoType    centrifuge.ProtocolType
+	maxQueueSize int
+	client       *centrifuge.Client
+	closeFn      centrifuge.ClientCloseFunc
+
+	mu         sync.Mutex
+	messages   [][]byte
+	notifyCh   chan struct{}
+	receiverCh chan struct{}
+	detachedAt time.Time
+	closed     bool
+	closedAt   time.Time
+	disconnect *centrifuge.Disconnect
+	closeCh    chan struct{}
+}
+
+func newSession(id string, protoType centrifuge.ProtocolType, maxQueueSize int) *session {
+	return &session{
+		id:           id,
+		protoType:    protoType,
+		maxQueueSize: maxQueueSize,
+		notifyCh:     make(chan struct{}, 1),
+		detachedAt:   time.Now(),
+		closeCh:      make(chan struct{}),
+	}
+}
+
+// Name returns transport name.
+func (s *session) Name() string {
+	return transportName
+}
+
+// Protocol returns transport protocol.
+func (s *session) Protocol() centrifuge.ProtocolType {
+	return s.protoType
+}
+
+// Unidirectional returns whether transport is unidirectional.
+func (s *session) Unidirectional() bool {
+	return false
+}
+
+// DisabledPushFlags ...
+func (s *session) DisabledPushFlags() uint64 {
+	return 0
+}
+
+// Write data to transport.
+func (s *session) Write(message []byte) error {
+	return s.WriteMany(message)
+}
+
+// WriteMany data to transport.
+func (s *session) WriteMany(messages ...[]byte) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	if len(s.messages)+len(messages) > s.maxQueueSize {
+		s.mu.Unlock()
+		go s.client.Disconnect(centrifuge.DisconnectSlow)
+		return nil
+	}
+	s.messages = append(s.messages, messages...)
+	s.mu.Unlock()
+	s.notify()
+	return nil
+}
+
+// Close closes transport. Messages queued before close still can be taken by
+// receiver.
+func (s *session) Close(disconnect *centrifuge.Disconnect) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.closedAt = time.Now()
+	s.disconnect = disconnect
+	close(s.closeCh)
+	return nil
+}
+
+func (s *session) notify() {
+	select {
+	case s.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+// attach makes caller the only receiver of session messages. Returned channel
+// closed when another receiver attached.
+func (s *session) attach() chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.receiverCh != nil {
+		close(s.receiverCh)
+	}
+	s.receiverCh = make(chan struct{})
+	return s.receiverCh
+}
+
+// detach must be called when receiver finished.
+func (s *session) detach(receiverCh chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.receiverCh == receiverCh {
+		s.receiverCh = nil
+		s.detachedAt = time.Now()
+	}
+}
+
+// take returns queued messages and whether session closed.
+func (s *session) take() ([][]byte, bool) {
+	return s.takeLimit(0)
+}
+
+// takeLimit returns at most limit queued messages (all if limit is zero) and
+// whether session closed. Messages above limit stay in queue.
+func (s *session) takeLimit(limit int) ([][]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages := s.messages
+	if limit > 0 && len(messages) > limit {
+		s.messages = append([][]byte(nil), messages[limit:]...)
+		return messages[:limit], s.closed
+	}
+	s.messages = nil
+	return messages, s.closed
+}
+
+// expired reports whether session must be removed. Sessions without receiver
+// are kept for ttl, closed sessions are kept for ttl to let client know
+// disconnect reason.
+func (s *session) expired(now time.Time, ttl time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return now.Sub(s.closedAt) > ttl
+	}
+	return s.receiverCh == nil && now.Sub(s.detachedAt) > ttl
+}
+
+func (s *session) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *session) closeDisconnect() *centrifuge.Disconnect {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.disconnect == nil {
+		return centrifuge.DisconnectNormal
+	}
+	return s.disconnect
+}
+
+var jsonDelimiter = []byte("\n")
+
+// writeFrame writes message to w. JSON messages delimited with new line,
+// Protobuf messages prefixed with varint length. Empty frame used as ping.
+func (s *session) writeFrame(w io.Writer, data []byte) error {
+	if s.protoType == centrifuge.ProtocolTypeJSON {
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		_, err := w.Write(jsonDelimiter)
+		return err
+	}
+	var lengthBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lengthBuf[:], uint64(len(data)))
+	if _, err := w.Write(lengthBuf[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// sessionHub keeps sessions of node.
+type sessionHub struct {
+	mu       sync.RWMutex
+	sessions map[string]*session
+}
+
+func newSessionHub() *sessionHub {
+	return &sessionHub{
+		sessions: make(map[string]*session),
+	}
+}
+
+func (h *sessionHub) add(s *session) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sessions[s.id] = s
+}
+
+func (h *sessionHub) get(id string) (*session, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	s, ok := h.sessions[id]
+	return s, ok
+}
+
+func (h *sessionHub) remove(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.sessions, id)
+}
+
+// expire removes expired sessions closing their clients.
+func (h *sessionHub) expire(now time.Time, ttl time.Duration) {
+	var expired []*session
+	h.mu.Lock()
+	for id, s := range h.sessions {
+		if s.expired(now, ttl) {
+			expired = append(expired, s)
+			delete(h.sessions, id)
+		}
+	}
+	h.mu.Unlock()
+	for _, s := range expired {
+		_ = s.closeFn()
+	}
+}
@@ -0,0 +1,98 @@
+// Package inbox contains types of per-user inbox. Publications to personal
+// channel of user sent while user has no connections are additionally kept in
+// user inbox. Inbox is read on next connect of user and its entries sent to
+// client in subscribe result data of personal channel, so client gets them
+// before any live publication.
+//
+// Inbox is bounded by size and expires after TTL since last addition. Entries
+// are removed from inbox only after connection which got them accepted, so
+// entries are not lost if connection rejected after reading inbox.
+package inbox
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// DefaultSize is a default max number of entries kept in inbox.
+const DefaultSize = 100
+
+// DefaultTTL is a default time inbox kept since last addition.
+const DefaultTTL = 24 * time.Hour
+
+// Entry of inbox.
+type Entry struct {
+	// Data of publication.
+	Data []byte
+	// Time of publication in Unix milliseconds.
+	Time int64
+}
+
+// Options of addition to inbox.
+type Options struct {
+	// Size is a max number of entries in inbox, oldest entries removed first.
+	Size int
+	// TTL of inbox since last addition.
+	TTL time.Duration
+}
+
+// Store keeps inboxes of users.
+type Store interface {
+	// AddToInbox adds entry to inbox of user.
+	AddToInbox(user string, e Entry, opts Options) error
+	// PeekInbox returns all entries of user inbox from oldest to newest
+	// without removing them from inbox.
+	PeekInbox(user string) ([]Entry, error)
+	// TrimInbox removes n oldest entries from inbox of user.
+	TrimInbox(user string, n int) error
+}
+
+type contextKey struct{}
+
+// SetToContext puts number of inbox entries sent to connection to context, so
+// they can be removed from inbox after connection accepted.
+func SetToContext(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, contextKey{}, n)
+}
+
+// FromContext returns number of inbox entries sent to connection.
+func FromContext(ctx context.Context) (int, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	n, ok := ctx.Value(contextKey{}).(int)
+	return n, ok
+}
+
+type jsonEntry struct {
+	Data    json.RawMessage `json:"data,omitempty"`
+	B64Data string          `json:"b64data,omitempty"`
+	Time    int64           `json:"time"`
+}
+
+type jsonInbox struct {
+	Inbox []jsonEntry `json:"inbox"`
+}
+
+// SubscribeData encodes entries to JSON object with inbox array sent in
+// subscribe result of personal channel:
+//
+//	{"inbox": [{"data": {"text": "hello"}, "time": 1625140800000}]}
+//
+// Entries with data which is not valid JSON have base64 encoded data in
+// b64data field instead of data.
+func SubscribeData(entries []Entry) ([]byte, error) {
+	result := jsonInbox{Inbox: make([]jsonEntry, 0, len(entries))}
+	for _, e := range entries {
+		entry := jsonEntry{Time: e.Time}
+		if json.Valid(e.Data) {
+			entry.Data = e.Data
+		} else {
+			entry.B64Data = base64.StdEncoding.EncodeToString(e.Data)
+		}
+		result.Inbox = append(result.Inbox, entry)
+	}
+	return json.Marshal(result)
+}
@@ -0,0 +1,20 @@
+package inbox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeData(t *testing.T) {
+	data, err := SubscribeData([]Entry{
+		{Data: []byte(`{"text":"hello"}`), Time: 1},
+		{Data: []byte{0xff, 0x01}, Time: 2},
+	})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"inbox":[{"data":{"text":"hello"},"time":1},{"b64data":"/wE=","time":2}]}`, string(data))
+
+	data, err = SubscribeData(nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"inbox":[]}`, string(data))
+}
@@ -0,0 +1,154 @@
+// Package interceptor contains API of compiled-in extensions which can
+// inspect, modify or deny publications and subscriptions in process without
+// going through network proxies.
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// Sources of publications.
+const (
+	SourceClient = "client"
+	SourceAPI    = "api"
+)
+
+// ErrUnknownInterceptor returned on registration of interceptor which does
+// not implement any of interceptor interfaces.
+var ErrUnknownInterceptor = errors.New("interceptor must implement PublishInterceptor or SubscribeInterceptor")
+
+// Interceptor is a named extension. It must also implement PublishInterceptor,
+// SubscribeInterceptor or both.
+type Interceptor interface {
+	Name() string
+}
+
+// Publication passed to publish interceptors.
+type Publication struct {
+	Channel string
+	// Data of publication, before hooks can replace it.
+	Data []byte
+	// Source is SourceClient or SourceAPI.
+	Source string
+	// Info of publisher, nil for publications from server API.
+	Info *centrifuge.ClientInfo
+}
+
+// Subscription passed to subscribe interceptors.
+type Subscription struct {
+	Channel string
+	Client  string
+	User    string
+	// Data sent to client in subscribe reply, hooks can replace it.
+	Data []byte
+}
+
+// PublishInterceptor is called around publishing. Error returned from
+// BeforePublish denies publication, *centrifuge.Error is passed to caller as
+// is (for example centrifuge.ErrorPermissionDenied), other errors result into
+// internal error.
+type PublishInterceptor interface {
+	Interceptor
+	BeforePublish(ctx context.Context, pub *Publication) error
+	// AfterPublish called with result of publishing, err is not nil if
+	// publishing failed.
+	AfterPublish(ctx context.Context, pub Publication, sp centrifuge.StreamPosition, err error)
+}
+
+// SubscribeInterceptor is called when subscription already allowed by
+// Centrifugo. Error returned from BeforeSubscribe denies subscription the
+// same way as error from BeforePublish denies publication.
+type SubscribeInterceptor interface {
+	Interceptor
+	BeforeSubscribe(ctx context.Context, sub *Subscription) error
+}
+
+// Chain calls registered interceptors in order of registration.
+type Chain struct {
+	mu        sync.RWMutex
+	publish   []PublishInterceptor
+	subscribe []SubscribeInterceptor
+}
+
+// NewChain creates empty Chain.
+func NewChain() *Chain {
+	return &Chain{}
+}
+
+// DefaultChain is a Chain used by Centrifugo server. Extensions compiled into
+// custom build usually register themselves in it from init function.
+var DefaultChain = NewChain()
+
+// Register adds interceptor to DefaultChain.
+func Register(i Interceptor) error {
+	return DefaultChain.Register(i)
+}
+
+// Register adds interceptor to Chain.
+func (c *Chain) Register(i Interceptor) error {
+	p, isPublish := i.(PublishInterceptor)
+	s, isSubscribe := i.(SubscribeInterceptor)
+	if !isPublish && !isSubscribe {
+		return ErrUnknownInterceptor
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if isPublish {
+		c.publish = append(c.publish, p)
+	}
+	if isSubscribe {
+		c.subscribe = append(c.subscribe, s)
+	}
+	return nil
+}
+
+// Empty reports whether Chain has no interceptors.
+func (c *Chain) Empty() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.publish) == 0 && len(c.subscribe) == 0
+}
+
+// BeforePublish calls BeforePublish of interceptors until one of them
+// returns error.
+func (c *Chain) BeforePublish(ctx context.Context, pub *Publication) error {
+	c.mu.RLock()
+	interceptors := c.publish
+	c.mu.RUnlock()
+	for _, i := range interceptors {
+		if err := i.BeforePublish(ctx, pub); err != nil {
+			interceptorDeniedCount.WithLabelValues(i.Name(), "publish").Inc()
+			return err
+		}
+	}
+	return nil
+}
+
+// AfterPublish calls AfterPublish of all interceptors.
+func (c *Chain) AfterPublish(ctx context.Context, pub Publication, sp centrifuge.StreamPosition, err error) {
+	c.mu.RLock()
+	interceptors := c.publish
+	c.mu.RUnlock()
+	for _, i := range interceptors {
+		i.AfterPublish(ctx, pub, sp, err)
+	}
+}
+
+// BeforeSubscribe calls BeforeSubscribe of interceptors until one of them
+// returns error.
+func (c *Chain) BeforeSubscribe(ctx context.Context, sub *Subscription) error {
+	c.mu.RLock()
+	interceptors := c.subscribe
+	c.mu.RUnlock()
+	for _, i := range interceptors {
+		if err := i.BeforeSubscribe(ctx, sub); err != nil {
+			interceptorDeniedCount.WithLabelValues(i.Name(), "subscribe").Inc()
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package interceptor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+type testInterceptor struct {
+	name      string
+	published []string
+}
+
+func (i *testInterceptor) Name() string {
+	return i.name
+}
+
+func (i *testInterceptor) BeforePublish(_ context.Context, pub *Publication) error {
+	if string(pub.Data) == `"deny"` {
+		return centrifuge.ErrorPermissionDenied
+	}
+	pub.Data = append(pub.Data[:len(pub.Data):len(pub.Data)], i.name...)
+	return nil
+}
+
+func (i *testInterceptor) AfterPublish(_ context.Context, pub Publication, _ centrifuge.StreamPosition, _ error) {
+	i.published = append(i.published, string(pub.Data))
+}
+
+type testSubscribeInterceptor struct{}
+
+func (testSubscribeInterceptor) Name() string {
+	return "sub"
+}
+
+func (testSubscribeInterceptor) BeforeSubscribe(_ context.Context, sub *Subscription) error {
+	if sub.User == "" {
+		return centrifuge.ErrorPermissionDenied
+	}
+	sub.Data = []byte(`{"welcome":true}`)
+	return nil
+}
+
+type noopInterceptor struct{}
+
+func (noopInterceptor) Name() string {
+	return "noop"
+}
+
+func TestChain(t *testing.T) {
+	c := NewChain()
+	require.True(t, c.Empty())
+	require.Equal(t, ErrUnknownInterceptor, c.Register(noopInterceptor{}))
+	require.True(t, c.Empty())
+
+	first := &testInterceptor{name: "1"}
+	second := &testInterceptor{name: "2"}
+	require.NoError(t, c.Register(first))
+	require.NoError(t, c.Register(second))
+	require.NoError(t, c.Register(testSubscribeInterceptor{}))
+	require.False(t, c.Empty())
+
+	pub := &Publication{Channel: "test", Data: []byte("x"), Source: SourceAPI}
+	require.NoError(t, c.BeforePublish(context.Background(), pub))
+	require.Equal(t, "x12", string(pub.Data))
+	c.AfterPublish(context.Background(), *pub, centrifuge.StreamPosition{}, nil)
+	require.Equal(t, []string{"x12"}, first.published)
+	require.Equal(t, []string{"x12"}, second.published)
+
+	err := c.BeforePublish(context.Background(), &Publication{Channel: "test", Data: []byte(`"deny"`)})
+	require.Equal(t, centrifuge.ErrorPermissionDenied, err)
+
+	sub := &Subscription{Channel: "test", User: "42"}
+	require.NoError(t, c.BeforeSubscribe(context.Background(), sub))
+	require.Equal(t, `{"welcome":true}`, string(sub.Data))
+	require.Equal(t, centrifuge.ErrorPermissionDenied, c.BeforeSubscribe(context.Background(), &Subscription{Channel: "test"}))
+}
@@ -0,0 +1,20 @@
+package interceptor
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricsNamespace = "centrifugo"
+
+var (
+	interceptorDeniedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "interceptor",
+		Name:      "denied",
+		Help:      "Number of publications and subscriptions denied by interceptors.",
+	}, []string{"interceptor", "op"})
+)
+
+func init() {
+	prometheus.MustRegister(interceptorDeniedCount)
+}
@@ -3,6 +3,8 @@ package jwtverify
 import (
 	"encoding/json"
 
+	"github.com/centrifugal/centrifugo/v3/internal/grant"
+
 	"github.com/centrifugal/centrifuge"
 )
 
@@ -32,6 +34,14 @@ type ConnectToken struct {
 	// more advanced version of Channels actually. If Subs map is not empty then we
 	// don't look at Channels at all.
 	Subs map[string]centrifuge.SubscribeOptions
+	// Roles of connection. Channel namespaces can restrict operations to
+	// connections with certain roles.
+	Roles []string
+	// Tenant of connection, empty if token issued without tenant claim.
+	Tenant string
+	// Grants of connection to subscribe on channels, nil if token has no
+	// grants claim.
+	Grants *grant.Grants
 }
 
 type SubscribeToken struct {
@@ -41,6 +51,11 @@ type SubscribeToken struct {
 	// Channel client wants to subscribe. Will be compared with channel in
 	// subscribe command.
 	Channel string
+	// Tenant which issued token, empty if token issued without tenant claim.
+	Tenant string
 	// Options for subscription.
 	Options centrifuge.SubscribeOptions
+	// Caps requested in token, nil if not requested. Options already narrowed
+	// to them, enabled capabilities sent to client by subscribe handler.
+	Caps []string
 }
@@ -8,16 +8,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strings"
 	"sync"
 	"time"
 
+	"github.com/centrifugal/centrifugo/v3/internal/grant"
 	"github.com/centrifugal/centrifugo/v3/internal/jwks"
 	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/subcaps"
 
 	"github.com/centrifugal/centrifuge"
 	"github.com/cristalhq/jwt/v3"
-	"github.com/rs/zerolog/log"
 )
 
 type VerifierConfig struct {
@@ -37,19 +37,103 @@ type VerifierConfig struct {
 	// tokens generated using rotating RSA public keys. Zero value means that JSON Web Key Sets
 	// extension won't be used.
 	JWKSPublicEndpoint string
+
+	// TenantHMACSecretKeys contains HMAC secret keys of tenants by tenant name.
+	// Tokens with tenant claim are verified only with key of this tenant.
+	TenantHMACSecretKeys map[string]string
+
+	// Audience if set must be present in aud claim of tokens, so tokens issued
+	// for other applications sharing the same keys are rejected.
+	Audience string
+
+	// Issuer if set must be equal to iss claim of tokens.
+	Issuer string
+
+	// ClockSkew is a time tolerated in exp and nbf claim checks to compensate
+	// clock difference between token issuer and Centrifugo.
+	ClockSkew time.Duration
+
+	// Keys are additional keys to verify tokens, used for zero-downtime key
+	// rotation. Token with kid header equal to key ID verified only with this
+	// key. Token without kid (or with unknown kid) verified with keys above
+	// first and then with every key from Keys in order.
+	Keys []Key
 }
 
+// Key is a set of keys identified by ID to verify tokens.
+type Key struct {
+	// ID of key matched with kid header of token.
+	ID string
+	// HMACSecretKey to verify tokens generated using HMAC.
+	HMACSecretKey string
+	// RSAPublicKey to verify tokens generated using RSA.
+	RSAPublicKey *rsa.PublicKey
+	// ECDSAPublicKey to verify tokens generated using ECDSA.
+	ECDSAPublicKey *ecdsa.PublicKey
+}
+
+type keyAlgorithms struct {
+	id         string
+	algorithms *algorithms
+}
+
+func newKeyAlgorithms(keys []Key) ([]keyAlgorithms, error) {
+	result := make([]keyAlgorithms, 0, len(keys))
+	ids := map[string]struct{}{}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("key ID required")
+		}
+		if _, ok := ids[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key ID: %s", key.ID)
+		}
+		ids[key.ID] = struct{}{}
+		alg, err := newAlgorithms(key.HMACSecretKey, key.RSAPublicKey, key.ECDSAPublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key.ID, err)
+		}
+		result = append(result, keyAlgorithms{id: key.ID, algorithms: alg})
+	}
+	return result, nil
+}
+
+// NewTokenVerifierJWT creates VerifierJWT and panics on invalid configuration.
+// Use NewVerifierJWT to get an error instead.
 func NewTokenVerifierJWT(config VerifierConfig, ruleContainer *rule.Container) *VerifierJWT {
+	verifier, err := NewVerifierJWT(config, ruleContainer)
+	if err != nil {
+		panic(err)
+	}
+	return verifier
+}
+
+// NewVerifierJWT creates VerifierJWT, returns an error on invalid configuration.
+func NewVerifierJWT(config VerifierConfig, ruleContainer *rule.Container) (*VerifierJWT, error) {
 	verifier := &VerifierJWT{
 		ruleContainer: ruleContainer,
+		audience:      config.Audience,
+		issuer:        config.Issuer,
+		clockSkew:     config.ClockSkew,
 	}
 
 	algorithms, err := newAlgorithms(config.HMACSecretKey, config.RSAPublicKey, config.ECDSAPublicKey)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	verifier.algorithms = algorithms
 
+	tenantAlgorithms, err := newTenantAlgorithms(config.TenantHMACSecretKeys)
+	if err != nil {
+		return nil, err
+	}
+	verifier.tenantAlgorithms = tenantAlgorithms
+
+	keys, err := newKeyAlgorithms(config.Keys)
+	if err != nil {
+		return nil, err
+	}
+	verifier.keys = keys
+
 	if config.JWKSPublicEndpoint != "" {
 		mng, err := jwks.NewManager(config.JWKSPublicEndpoint)
 		if err == nil {
@@ -57,7 +141,7 @@ func NewTokenVerifierJWT(config VerifierConfig, ruleContainer *rule.Container) *
 		}
 	}
 
-	return verifier
+	return verifier, nil
 }
 
 type VerifierJWT struct {
@@ -65,14 +149,29 @@ type VerifierJWT struct {
 	jwksManager   *jwksManager
 	algorithms    *algorithms
 	ruleContainer *rule.Container
+	// tenantAlgorithms used to verify tokens with tenant claim.
+	tenantAlgorithms map[string]*algorithms
+	// keys used to verify tokens in addition to algorithms.
+	keys      []keyAlgorithms
+	audience  string
+	issuer    string
+	clockSkew time.Duration
 }
 
 var (
-	ErrTokenExpired         = errors.New("token expired")
-	ErrInvalidToken         = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenNotYetValid returned for token which nbf claim is in future.
+	ErrTokenNotYetValid = fmt.Errorf("%w: token not yet valid", ErrInvalidToken)
+	// ErrInvalidAudience returned for token without expected audience.
+	ErrInvalidAudience = fmt.Errorf("%w: audience mismatch", ErrInvalidToken)
+	// ErrInvalidIssuer returned for token with unexpected issuer.
+	ErrInvalidIssuer = fmt.Errorf("%w: issuer mismatch", ErrInvalidToken)
+
 	errPublicKeyInvalid     = errors.New("public key is invalid")
 	errUnsupportedAlgorithm = errors.New("unsupported JWT algorithm")
 	errDisabledAlgorithm    = errors.New("disabled JWT algorithm")
+	errUnknownTenant        = errors.New("unknown tenant")
 )
 
 // BoolValue allows override boolean option.
@@ -112,6 +211,10 @@ type SubscribeOptions struct {
 	Base64Data string `json:"b64data,omitempty"`
 	// Override channel options can contain channel options overrides.
 	Override *SubscribeOptionOverride `json:"override,omitempty"`
+	// Caps are capabilities requested for subscription, see subcaps package.
+	// If set then options not listed in caps turned off and enabled
+	// capabilities sent to client in subscribe data.
+	Caps []string `json:"caps,omitempty"`
 }
 
 type ConnectTokenClaims struct {
@@ -121,6 +224,9 @@ type ConnectTokenClaims struct {
 	Channels   []string                    `json:"channels,omitempty"`
 	Subs       map[string]SubscribeOptions `json:"subs,omitempty"`
 	Meta       json.RawMessage             `json:"meta,omitempty"`
+	Roles      []string                    `json:"roles,omitempty"`
+	Tenant     string                      `json:"tenant,omitempty"`
+	Grants     []grant.Grant               `json:"grants,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -130,6 +236,11 @@ type SubscribeTokenClaims struct {
 	Client   string `json:"client,omitempty"`
 	Channel  string `json:"channel,omitempty"`
 	ExpireAt *int64 `json:"expire_at,omitempty"`
+	Tenant   string `json:"tenant,omitempty"`
+}
+
+type tenantClaims struct {
+	Tenant string `json:"tenant,omitempty"`
 }
 
 type jwksManager struct{ *jwks.Manager }
@@ -174,6 +285,8 @@ type algorithms struct {
 	ES256 jwt.Verifier
 	ES384 jwt.Verifier
 	ES512 jwt.Verifier
+
+	names []string
 }
 
 func newAlgorithms(tokenHMACSecretKey string, rsaPubKey *rsa.PublicKey, ecdsaPubKey *ecdsa.PublicKey) (*algorithms, error) {
@@ -257,13 +370,25 @@ func newAlgorithms(tokenHMACSecretKey string, rsaPubKey *rsa.PublicKey, ecdsaPub
 		}
 	}
 
-	if len(algorithms) > 0 {
-		log.Info().Str("algorithms", strings.Join(algorithms, ", ")).Msg("enabled JWT verifiers")
-	}
-
+	alg.names = algorithms
 	return alg, nil
 }
 
+func newTenantAlgorithms(tenantHMACSecretKeys map[string]string) (map[string]*algorithms, error) {
+	tenantAlgorithms := make(map[string]*algorithms, len(tenantHMACSecretKeys))
+	for name, key := range tenantHMACSecretKeys {
+		if key == "" {
+			continue
+		}
+		alg, err := newAlgorithms(key, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", name, err)
+		}
+		tenantAlgorithms[name] = alg
+	}
+	return tenantAlgorithms, nil
+}
+
 func (s *algorithms) verify(token *jwt.Token) error {
 	var verifier jwt.Verifier
 	switch token.Header().Algorithm {
@@ -294,11 +419,47 @@ func (s *algorithms) verify(token *jwt.Token) error {
 	return verifier.Verify(token.Payload(), token.Signature())
 }
 
+// verifySignature verifies token with key matching kid header if any,
+// otherwise with globally configured keys and then with all additional keys.
 func (verifier *VerifierJWT) verifySignature(token *jwt.Token) error {
 	verifier.mu.RLock()
 	defer verifier.mu.RUnlock()
 
-	return verifier.algorithms.verify(token)
+	if alg, ok := verifier.keyAlgorithms(token.Header().KeyID); ok {
+		return alg.verify(token)
+	}
+	err := verifier.algorithms.verify(token)
+	if err == nil {
+		return nil
+	}
+	for _, key := range verifier.keys {
+		if key.algorithms.verify(token) == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// keyAlgorithms returns algorithms of additional key with ID, must be called
+// with mu held.
+func (verifier *VerifierJWT) keyAlgorithms(id string) (*algorithms, bool) {
+	if id == "" {
+		return nil, false
+	}
+	for _, key := range verifier.keys {
+		if key.id == id {
+			return key.algorithms, true
+		}
+	}
+	return nil, false
+}
+
+// hasKey reports whether additional key with ID configured.
+func (verifier *VerifierJWT) hasKey(id string) bool {
+	verifier.mu.RLock()
+	defer verifier.mu.RUnlock()
+	_, ok := verifier.keyAlgorithms(id)
+	return ok
 }
 
 func (verifier *VerifierJWT) verifySignatureByJWK(token *jwt.Token) error {
@@ -308,19 +469,61 @@ func (verifier *VerifierJWT) verifySignatureByJWK(token *jwt.Token) error {
 	return verifier.jwksManager.verify(token)
 }
 
+func (verifier *VerifierJWT) verifySignatureByTenant(token *jwt.Token, tenant string) error {
+	verifier.mu.RLock()
+	defer verifier.mu.RUnlock()
+
+	alg, ok := verifier.tenantAlgorithms[tenant]
+	if !ok {
+		return fmt.Errorf("%w: %s", errUnknownTenant, tenant)
+	}
+	return alg.verify(token)
+}
+
+// verifyTokenSignature verifies token with key of tenant if token has tenant
+// claim, otherwise with globally configured keys.
+func (verifier *VerifierJWT) verifyTokenSignature(token *jwt.Token) error {
+	var tc tenantClaims
+	if err := json.Unmarshal(token.RawClaims(), &tc); err != nil {
+		return err
+	}
+	if tc.Tenant != "" {
+		return verifier.verifySignatureByTenant(token, tc.Tenant)
+	}
+	if verifier.jwksManager != nil && !verifier.hasKey(token.Header().KeyID) {
+		return verifier.verifySignatureByJWK(token)
+	}
+	return verifier.verifySignature(token)
+}
+
+// validateClaims checks time, audience and issuer claims of token.
+func (verifier *VerifierJWT) validateClaims(claims *jwt.StandardClaims, now time.Time) error {
+	verifier.mu.RLock()
+	audience, issuer, clockSkew := verifier.audience, verifier.issuer, verifier.clockSkew
+	verifier.mu.RUnlock()
+
+	if !claims.IsValidExpiresAt(now.Add(-clockSkew)) {
+		return ErrTokenExpired
+	}
+	if !claims.IsValidNotBefore(now.Add(clockSkew)) {
+		return ErrTokenNotYetValid
+	}
+	if audience != "" && !claims.IsForAudience(audience) {
+		return ErrInvalidAudience
+	}
+	if issuer != "" && !claims.IsIssuer(issuer) {
+		return ErrInvalidIssuer
+	}
+	return nil
+}
+
 func (verifier *VerifierJWT) VerifyConnectToken(t string) (ConnectToken, error) {
 	token, err := jwt.Parse([]byte(t))
 	if err != nil {
 		return ConnectToken{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
 
-	if verifier.jwksManager != nil {
-		err = verifier.verifySignatureByJWK(token)
-	} else {
-		err = verifier.verifySignature(token)
-	}
-
-	if err != nil {
+	if err = verifier.verifyTokenSignature(token); err != nil {
 		return ConnectToken{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
 
@@ -329,9 +532,8 @@ func (verifier *VerifierJWT) VerifyConnectToken(t string) (ConnectToken, error)
 		return ConnectToken{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
 
-	now := time.Now()
-	if !claims.IsValidExpiresAt(now) || !claims.IsValidNotBefore(now) {
-		return ConnectToken{}, ErrTokenExpired
+	if err := verifier.validateClaims(&claims.StandardClaims, time.Now()); err != nil {
+		return ConnectToken{}, err
 	}
 
 	subs := map[string]centrifuge.SubscribeOptions{}
@@ -381,7 +583,7 @@ func (verifier *VerifierJWT) VerifyConnectToken(t string) (ConnectToken, error)
 			if v.Override != nil && v.Override.Position != nil {
 				position = v.Override.Position.Value
 			}
-			subs[ch] = centrifuge.SubscribeOptions{
+			opts := centrifuge.SubscribeOptions{
 				ChannelInfo: info,
 				Presence:    presence,
 				JoinLeave:   joinLeave,
@@ -389,6 +591,17 @@ func (verifier *VerifierJWT) VerifyConnectToken(t string) (ConnectToken, error)
 				Position:    position,
 				Data:        data,
 			}
+			if v.Caps != nil {
+				// Server-side subscriptions have no subscribe request to
+				// answer, so capabilities sent in subscribe data right away.
+				if err := subcaps.Narrow(&opts, v.Caps); err != nil {
+					return ConnectToken{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+				}
+				if err := subcaps.Wrap(&opts); err != nil {
+					return ConnectToken{}, err
+				}
+			}
+			subs[ch] = opts
 		}
 	} else if len(claims.Channels) > 0 {
 		for _, ch := range claims.Channels {
@@ -430,12 +643,20 @@ func (verifier *VerifierJWT) VerifyConnectToken(t string) (ConnectToken, error)
 		info = claims.Info
 	}
 
+	grants, err := grant.Compile(claims.Grants, verifier.ruleContainer.Config().ChannelNamespaceBoundary)
+	if err != nil {
+		return ConnectToken{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
 	ct := ConnectToken{
 		UserID:   claims.StandardClaims.Subject,
 		Info:     info,
 		Subs:     subs,
 		ExpireAt: expireAt,
 		Meta:     claims.Meta,
+		Roles:    claims.Roles,
+		Tenant:   claims.Tenant,
+		Grants:   grants,
 	}
 
 	return ct, nil
@@ -447,12 +668,7 @@ func (verifier *VerifierJWT) VerifySubscribeToken(t string) (SubscribeToken, err
 		return SubscribeToken{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
 
-	if verifier.jwksManager != nil {
-		err = verifier.verifySignatureByJWK(token)
-	} else {
-		err = verifier.verifySignature(token)
-	}
-	if err != nil {
+	if err = verifier.verifyTokenSignature(token); err != nil {
 		return SubscribeToken{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
 
@@ -461,9 +677,8 @@ func (verifier *VerifierJWT) VerifySubscribeToken(t string) (SubscribeToken, err
 		return SubscribeToken{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
 
-	now := time.Now()
-	if !claims.IsValidExpiresAt(now) || !claims.IsValidNotBefore(now) {
-		return SubscribeToken{}, ErrTokenExpired
+	if err := verifier.validateClaims(&claims.StandardClaims, time.Now()); err != nil {
+		return SubscribeToken{}, err
 	}
 
 	chOpts, found, err := verifier.ruleContainer.ChannelOptions(claims.Channel)
@@ -524,6 +739,8 @@ func (verifier *VerifierJWT) VerifySubscribeToken(t string) (SubscribeToken, err
 	st := SubscribeToken{
 		Client:  claims.Client,
 		Channel: claims.Channel,
+		Tenant:  claims.Tenant,
+		Caps:    claims.Caps,
 		Options: centrifuge.SubscribeOptions{
 			ExpireAt:    expireAt,
 			ChannelInfo: info,
@@ -534,9 +751,35 @@ func (verifier *VerifierJWT) VerifySubscribeToken(t string) (SubscribeToken, err
 			Data:        data,
 		},
 	}
+	if claims.Caps != nil {
+		if err := subcaps.Narrow(&st.Options, claims.Caps); err != nil {
+			return SubscribeToken{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+		}
+	}
 	return st, nil
 }
 
+// Algorithms returns names of algorithms enabled to verify tokens without
+// tenant claim.
+func (verifier *VerifierJWT) Algorithms() []string {
+	verifier.mu.RLock()
+	defer verifier.mu.RUnlock()
+	names := append([]string(nil), verifier.algorithms.names...)
+	seen := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		seen[name] = struct{}{}
+	}
+	for _, key := range verifier.keys {
+		for _, name := range key.algorithms.names {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
 func (verifier *VerifierJWT) Reload(config VerifierConfig) error {
 	verifier.mu.Lock()
 	defer verifier.mu.Unlock()
@@ -544,6 +787,19 @@ func (verifier *VerifierJWT) Reload(config VerifierConfig) error {
 	if err != nil {
 		return err
 	}
+	tenantAlgorithms, err := newTenantAlgorithms(config.TenantHMACSecretKeys)
+	if err != nil {
+		return err
+	}
+	keys, err := newKeyAlgorithms(config.Keys)
+	if err != nil {
+		return err
+	}
 	verifier.algorithms = alg
+	verifier.tenantAlgorithms = tenantAlgorithms
+	verifier.keys = keys
+	verifier.audience = config.Audience
+	verifier.issuer = config.Issuer
+	verifier.clockSkew = config.ClockSkew
 	return nil
 }
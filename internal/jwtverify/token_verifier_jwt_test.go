@@ -16,7 +16,9 @@ import (
 	"time"
 
 	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/centrifugo/v3/internal/grant"
 	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/subcaps"
 
 	"github.com/cristalhq/jwt/v3"
 	"github.com/stretchr/testify/require"
@@ -199,7 +201,7 @@ func Test_tokenVerifierJWT_Signer(t *testing.T) {
 func Test_tokenVerifierJWT_Valid(t *testing.T) {
 	ruleConfig := rule.DefaultConfig
 	ruleContainer := rule.NewContainer(ruleConfig)
-	verifier := NewTokenVerifierJWT(VerifierConfig{"secret", nil, nil, ""}, ruleContainer)
+	verifier := NewTokenVerifierJWT(VerifierConfig{"secret", nil, nil, "", nil, "", "", 0, nil}, ruleContainer)
 	ct, err := verifier.VerifyConnectToken(jwtValid)
 	require.NoError(t, err)
 	require.Equal(t, "2694", ct.UserID)
@@ -210,7 +212,7 @@ func Test_tokenVerifierJWT_Valid(t *testing.T) {
 func Test_tokenVerifierJWT_Expired(t *testing.T) {
 	ruleConfig := rule.DefaultConfig
 	ruleContainer := rule.NewContainer(ruleConfig)
-	verifier := NewTokenVerifierJWT(VerifierConfig{"secret", nil, nil, ""}, ruleContainer)
+	verifier := NewTokenVerifierJWT(VerifierConfig{"secret", nil, nil, "", nil, "", "", 0, nil}, ruleContainer)
 	_, err := verifier.VerifyConnectToken(jwtExpired)
 	require.Error(t, err)
 	require.Equal(t, ErrTokenExpired, err)
@@ -219,7 +221,7 @@ func Test_tokenVerifierJWT_Expired(t *testing.T) {
 func Test_tokenVerifierJWT_DisabledAlgorithm(t *testing.T) {
 	ruleConfig := rule.DefaultConfig
 	ruleContainer := rule.NewContainer(ruleConfig)
-	verifier := NewTokenVerifierJWT(VerifierConfig{"", nil, nil, ""}, ruleContainer)
+	verifier := NewTokenVerifierJWT(VerifierConfig{"", nil, nil, "", nil, "", "", 0, nil}, ruleContainer)
 	_, err := verifier.VerifyConnectToken(jwtExpired)
 	require.Error(t, err)
 	require.True(t, errors.Is(err, ErrInvalidToken), err.Error())
@@ -228,7 +230,7 @@ func Test_tokenVerifierJWT_DisabledAlgorithm(t *testing.T) {
 func Test_tokenVerifierJWT_InvalidSignature(t *testing.T) {
 	ruleConfig := rule.DefaultConfig
 	ruleContainer := rule.NewContainer(ruleConfig)
-	verifier := NewTokenVerifierJWT(VerifierConfig{"secret", nil, nil, ""}, ruleContainer)
+	verifier := NewTokenVerifierJWT(VerifierConfig{"secret", nil, nil, "", nil, "", "", 0, nil}, ruleContainer)
 	_, err := verifier.VerifyConnectToken(jwtInvalidSignature)
 	require.Error(t, err)
 }
@@ -236,7 +238,7 @@ func Test_tokenVerifierJWT_InvalidSignature(t *testing.T) {
 func Test_tokenVerifierJWT_WithNotBefore(t *testing.T) {
 	ruleConfig := rule.DefaultConfig
 	ruleContainer := rule.NewContainer(ruleConfig)
-	verifier := NewTokenVerifierJWT(VerifierConfig{"secret", nil, nil, ""}, ruleContainer)
+	verifier := NewTokenVerifierJWT(VerifierConfig{"secret", nil, nil, "", nil, "", "", 0, nil}, ruleContainer)
 	_, err := verifier.VerifyConnectToken(jwtNotBefore)
 	require.Error(t, err)
 }
@@ -244,7 +246,7 @@ func Test_tokenVerifierJWT_WithNotBefore(t *testing.T) {
 func Test_tokenVerifierJWT_StringAudience(t *testing.T) {
 	ruleConfig := rule.DefaultConfig
 	ruleContainer := rule.NewContainer(ruleConfig)
-	verifier := NewTokenVerifierJWT(VerifierConfig{"secret", nil, nil, ""}, ruleContainer)
+	verifier := NewTokenVerifierJWT(VerifierConfig{"secret", nil, nil, "", nil, "", "", 0, nil}, ruleContainer)
 	ct, err := verifier.VerifyConnectToken(jwtStringAud)
 	require.NoError(t, err)
 	require.Equal(t, "2694", ct.UserID)
@@ -253,12 +255,203 @@ func Test_tokenVerifierJWT_StringAudience(t *testing.T) {
 func Test_tokenVerifierJWT_ArrayAudience(t *testing.T) {
 	ruleConfig := rule.DefaultConfig
 	ruleContainer := rule.NewContainer(ruleConfig)
-	verifier := NewTokenVerifierJWT(VerifierConfig{"secret", nil, nil, ""}, ruleContainer)
+	verifier := NewTokenVerifierJWT(VerifierConfig{"secret", nil, nil, "", nil, "", "", 0, nil}, ruleContainer)
 	ct, err := verifier.VerifyConnectToken(jwtArrayAud)
 	require.NoError(t, err)
 	require.Equal(t, "2694", ct.UserID)
 }
 
+func Test_tokenVerifierJWT_NotBeforeError(t *testing.T) {
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	verifier := NewTokenVerifierJWT(VerifierConfig{HMACSecretKey: "secret"}, ruleContainer)
+	_, err := verifier.VerifyConnectToken(jwtNotBefore)
+	require.ErrorIs(t, err, ErrTokenNotYetValid)
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func Test_tokenVerifierJWT_AudienceIssuer(t *testing.T) {
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	verifier := NewTokenVerifierJWT(VerifierConfig{HMACSecretKey: "secret", Audience: "bar", Issuer: "app"}, ruleContainer)
+
+	_, err := verifier.VerifyConnectToken(jwtStringAud)
+	require.ErrorIs(t, err, ErrInvalidAudience)
+
+	claims := ConnectTokenClaims{StandardClaims: jwt.StandardClaims{Subject: "2694", Audience: []string{"foo", "bar"}}}
+	_, err = verifier.VerifyConnectToken(getHMACToken(t, "secret", claims))
+	require.ErrorIs(t, err, ErrInvalidIssuer)
+
+	claims.Issuer = "app"
+	ct, err := verifier.VerifyConnectToken(getHMACToken(t, "secret", claims))
+	require.NoError(t, err)
+	require.Equal(t, "2694", ct.UserID)
+
+	subClaims := SubscribeTokenClaims{Channel: "test", Client: "client", StandardClaims: jwt.StandardClaims{Issuer: "app", Audience: []string{"foo"}}}
+	_, err = verifier.VerifySubscribeToken(getHMACToken(t, "secret", subClaims))
+	require.ErrorIs(t, err, ErrInvalidAudience)
+
+	require.NoError(t, verifier.Reload(VerifierConfig{HMACSecretKey: "secret", Audience: "foo"}))
+	_, err = verifier.VerifySubscribeToken(getHMACToken(t, "secret", subClaims))
+	require.NoError(t, err)
+}
+
+func getHMACTokenWithKeyID(t *testing.T, secret string, kid string, claims interface{}) string {
+	signer, err := jwt.NewSignerHS(jwt.HS256, []byte(secret))
+	require.NoError(t, err)
+	token, err := jwt.NewBuilder(signer, jwt.WithKeyID(kid)).Build(claims)
+	require.NoError(t, err)
+	return token.String()
+}
+
+func Test_tokenVerifierJWT_Keys(t *testing.T) {
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	rsaPrivateKey, rsaPublicKey := generateTestRSAKeys(t)
+	verifier := NewTokenVerifierJWT(VerifierConfig{
+		HMACSecretKey: "current",
+		Keys: []Key{
+			{ID: "previous", HMACSecretKey: "previous"},
+			{ID: "rsa", RSAPublicKey: rsaPublicKey},
+		},
+	}, ruleContainer)
+	require.Equal(t, []string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512"}, verifier.Algorithms())
+
+	claims := ConnectTokenClaims{StandardClaims: jwt.StandardClaims{Subject: "2694"}}
+
+	// Without kid all keys tried.
+	for _, secret := range []string{"current", "previous"} {
+		ct, err := verifier.VerifyConnectToken(getHMACToken(t, secret, claims))
+		require.NoError(t, err)
+		require.Equal(t, "2694", ct.UserID)
+	}
+	_, err := verifier.VerifyConnectToken(getHMACToken(t, "unknown", claims))
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	// With kid only matching key used.
+	_, err = verifier.VerifyConnectToken(getHMACTokenWithKeyID(t, "previous", "previous", claims))
+	require.NoError(t, err)
+	_, err = verifier.VerifyConnectToken(getHMACTokenWithKeyID(t, "current", "previous", claims))
+	require.ErrorIs(t, err, ErrInvalidToken)
+	// Unknown kid verified as token without kid.
+	_, err = verifier.VerifyConnectToken(getHMACTokenWithKeyID(t, "current", "unknown", claims))
+	require.NoError(t, err)
+
+	rsaToken, err := getRSATokenBuilder(rsaPrivateKey, jwt.WithKeyID("rsa")).Build(SubscribeTokenClaims{Channel: "test", Client: "client"})
+	require.NoError(t, err)
+	_, err = verifier.VerifySubscribeToken(rsaToken.String())
+	require.NoError(t, err)
+
+	// Previous key removed on reload.
+	require.NoError(t, verifier.Reload(VerifierConfig{HMACSecretKey: "current"}))
+	_, err = verifier.VerifyConnectToken(getHMACToken(t, "previous", claims))
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	require.Error(t, verifier.Reload(VerifierConfig{Keys: []Key{{ID: "a", HMACSecretKey: "a"}, {ID: "a", HMACSecretKey: "b"}}}))
+	require.Error(t, verifier.Reload(VerifierConfig{Keys: []Key{{HMACSecretKey: "a"}}}))
+}
+
+func Test_tokenVerifierJWT_ClockSkew(t *testing.T) {
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	verifier := NewTokenVerifierJWT(VerifierConfig{HMACSecretKey: "secret", ClockSkew: time.Minute}, ruleContainer)
+
+	now := time.Now()
+	claims := ConnectTokenClaims{StandardClaims: jwt.StandardClaims{
+		Subject:   "2694",
+		ExpiresAt: jwt.NewNumericDate(now.Add(-30 * time.Second)),
+		NotBefore: jwt.NewNumericDate(now.Add(30 * time.Second)),
+	}}
+	_, err := verifier.VerifyConnectToken(getHMACToken(t, "secret", claims))
+	require.NoError(t, err)
+
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(-2 * time.Minute))
+	_, err = verifier.VerifyConnectToken(getHMACToken(t, "secret", claims))
+	require.Equal(t, ErrTokenExpired, err)
+
+	claims.ExpiresAt = nil
+	claims.NotBefore = jwt.NewNumericDate(now.Add(2 * time.Minute))
+	_, err = verifier.VerifyConnectToken(getHMACToken(t, "secret", claims))
+	require.ErrorIs(t, err, ErrTokenNotYetValid)
+}
+
+func getHMACToken(t *testing.T, secret string, claims interface{}) string {
+	signer, err := jwt.NewSignerHS(jwt.HS256, []byte(secret))
+	require.NoError(t, err)
+	token, err := jwt.NewBuilder(signer).Build(claims)
+	require.NoError(t, err)
+	return token.String()
+}
+
+func Test_tokenVerifierJWT_Tenant(t *testing.T) {
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Namespaces = []rule.ChannelNamespace{{Name: "app1"}}
+	ruleContainer := rule.NewContainer(ruleConfig)
+	verifier := NewTokenVerifierJWT(VerifierConfig{
+		HMACSecretKey:        "secret",
+		TenantHMACSecretKeys: map[string]string{"app1": "app1_secret"},
+	}, ruleContainer)
+
+	token := getHMACToken(t, "app1_secret", ConnectTokenClaims{Tenant: "app1", StandardClaims: jwt.StandardClaims{Subject: "2694"}})
+	ct, err := verifier.VerifyConnectToken(token)
+	require.NoError(t, err)
+	require.Equal(t, "app1", ct.Tenant)
+
+	// Global key can't be used to issue tokens of tenant.
+	token = getHMACToken(t, "secret", ConnectTokenClaims{Tenant: "app1", StandardClaims: jwt.StandardClaims{Subject: "2694"}})
+	_, err = verifier.VerifyConnectToken(token)
+	require.True(t, errors.Is(err, ErrInvalidToken))
+
+	// Tenant key can't be used to issue tokens without tenant claim.
+	token = getHMACToken(t, "app1_secret", ConnectTokenClaims{StandardClaims: jwt.StandardClaims{Subject: "2694"}})
+	_, err = verifier.VerifyConnectToken(token)
+	require.True(t, errors.Is(err, ErrInvalidToken))
+
+	token = getHMACToken(t, "app1_secret", ConnectTokenClaims{Tenant: "app2", StandardClaims: jwt.StandardClaims{Subject: "2694"}})
+	_, err = verifier.VerifyConnectToken(token)
+	require.True(t, errors.Is(err, ErrInvalidToken))
+
+	token = getHMACToken(t, "app1_secret", SubscribeTokenClaims{Tenant: "app1", Channel: "app1:test", Client: "client"})
+	st, err := verifier.VerifySubscribeToken(token)
+	require.NoError(t, err)
+	require.Equal(t, "app1", st.Tenant)
+
+	err = verifier.Reload(VerifierConfig{HMACSecretKey: "secret"})
+	require.NoError(t, err)
+	_, err = verifier.VerifySubscribeToken(token)
+	require.True(t, errors.Is(err, ErrInvalidToken))
+}
+
+func Test_tokenVerifierJWT_Grants(t *testing.T) {
+	verifier := NewTokenVerifierJWT(VerifierConfig{HMACSecretKey: "secret"}, rule.NewContainer(rule.DefaultConfig))
+
+	token := getHMACToken(t, "secret", ConnectTokenClaims{StandardClaims: jwt.StandardClaims{Subject: "2694"}})
+	ct, err := verifier.VerifyConnectToken(token)
+	require.NoError(t, err)
+	require.Nil(t, ct.Grants)
+
+	token = getHMACToken(t, "secret", ConnectTokenClaims{
+		Grants: []grant.Grant{
+			{Channel: "chat:*", Override: &grant.Override{Recover: &grant.BoolValue{Value: true}}},
+		},
+		StandardClaims: jwt.StandardClaims{Subject: "2694"},
+	})
+	ct, err = verifier.VerifyConnectToken(token)
+	require.NoError(t, err)
+	g, ok := ct.Grants.Match("chat:index")
+	require.True(t, ok)
+	require.NotNil(t, g.Override.Recover)
+	require.True(t, g.Override.Recover.Value)
+	require.Nil(t, g.Override.Presence)
+	_, ok = ct.Grants.Match("chat:index:admin")
+	require.False(t, ok)
+	_, ok = ct.Grants.Match("news:index")
+	require.False(t, ok)
+
+	token = getHMACToken(t, "secret", ConnectTokenClaims{
+		Grants:         []grant.Grant{{Channel: "chat:[a"}},
+		StandardClaims: jwt.StandardClaims{Subject: "2694"},
+	})
+	_, err = verifier.VerifyConnectToken(token)
+	require.True(t, errors.Is(err, ErrInvalidToken))
+}
+
 func Test_tokenVerifierJWT_VerifyConnectToken(t *testing.T) {
 	type args struct {
 		token string
@@ -270,7 +463,7 @@ func Test_tokenVerifierJWT_VerifyConnectToken(t *testing.T) {
 	ruleConfig := rule.DefaultConfig
 	ruleContainer := rule.NewContainer(ruleConfig)
 
-	verifierJWT := NewTokenVerifierJWT(VerifierConfig{"secret", rsaPubKey, ecdsaPubKey, ""}, ruleContainer)
+	verifierJWT := NewTokenVerifierJWT(VerifierConfig{"secret", rsaPubKey, ecdsaPubKey, "", nil, "", "", 0, nil}, ruleContainer)
 	_time := time.Now()
 	tests := []struct {
 		name     string
@@ -429,7 +622,7 @@ func Test_tokenVerifierJWT_VerifyConnectTokenWithJWK(t *testing.T) {
 			ruleConfig := rule.DefaultConfig
 			ruleContainer := rule.NewContainer(ruleConfig)
 
-			verifier := NewTokenVerifierJWT(VerifierConfig{"", nil, nil, ts.URL}, ruleContainer)
+			verifier := NewTokenVerifierJWT(VerifierConfig{"", nil, nil, ts.URL, nil, "", "", 0, nil}, ruleContainer)
 			token := getRSAConnToken(tt.token.user, tt.token.exp, privKey, jwt.WithKeyID(tt.jwk.kid))
 
 			got, err := verifier.VerifyConnectToken(token)
@@ -460,7 +653,7 @@ func Test_tokenVerifierJWT_VerifySubscribeToken(t *testing.T) {
 	ruleConfig := rule.DefaultConfig
 	ruleContainer := rule.NewContainer(ruleConfig)
 
-	verifierJWT := NewTokenVerifierJWT(VerifierConfig{"secret", rsaPubKey, ecdsaPubKey, ""}, ruleContainer)
+	verifierJWT := NewTokenVerifierJWT(VerifierConfig{"secret", rsaPubKey, ecdsaPubKey, "", nil, "", "", 0, nil}, ruleContainer)
 	_time := time.Now()
 	tests := []struct {
 		name     string
@@ -565,7 +758,7 @@ func Test_tokenVerifierJWT_VerifySubscribeToken(t *testing.T) {
 func BenchmarkConnectTokenVerify_Valid(b *testing.B) {
 	ruleConfig := rule.DefaultConfig
 	ruleContainer := rule.NewContainer(ruleConfig)
-	verifierJWT := NewTokenVerifierJWT(VerifierConfig{"secret", nil, nil, ""}, ruleContainer)
+	verifierJWT := NewTokenVerifierJWT(VerifierConfig{"secret", nil, nil, "", nil, "", "", 0, nil}, ruleContainer)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		_, err := verifierJWT.VerifyConnectToken(jwtValid)
@@ -580,7 +773,7 @@ func BenchmarkConnectTokenVerify_Valid(b *testing.B) {
 func BenchmarkConnectTokenVerify_Expired(b *testing.B) {
 	ruleConfig := rule.DefaultConfig
 	ruleContainer := rule.NewContainer(ruleConfig)
-	verifier := NewTokenVerifierJWT(VerifierConfig{"secret", nil, nil, ""}, ruleContainer)
+	verifier := NewTokenVerifierJWT(VerifierConfig{"secret", nil, nil, "", nil, "", "", 0, nil}, ruleContainer)
 	for i := 0; i < b.N; i++ {
 		_, err := verifier.VerifyConnectToken(jwtExpired)
 		if err != ErrTokenExpired {
@@ -588,3 +781,57 @@ func BenchmarkConnectTokenVerify_Expired(b *testing.B) {
 		}
 	}
 }
+
+func Test_tokenVerifierJWT_SubscribeCapabilities(t *testing.T) {
+	ruleConfig := rule.DefaultConfig
+	ruleConfig.Presence = true
+	ruleConfig.JoinLeave = true
+	ruleConfig.HistorySize = 10
+	ruleConfig.HistoryTTL = 300
+	ruleConfig.Position = true
+	ruleContainer := rule.NewContainer(ruleConfig)
+	verifier := NewTokenVerifierJWT(VerifierConfig{HMACSecretKey: "secret"}, ruleContainer)
+
+	claims := SubscribeTokenClaims{Channel: "test", Client: "client"}
+	claims.Caps = []string{subcaps.Presence, subcaps.Position, subcaps.Recover}
+	claims.Data = json.RawMessage(`{"custom":true}`)
+	st, err := verifier.VerifySubscribeToken(getHMACToken(t, "secret", claims))
+	require.NoError(t, err)
+	require.Equal(t, claims.Caps, st.Caps)
+	require.True(t, st.Options.Presence)
+	require.True(t, st.Options.Position)
+	require.False(t, st.Options.JoinLeave)
+	// Recover not enabled in channel options so can't be requested.
+	require.False(t, st.Options.Recover)
+	// Enabled capabilities sent to client by subscribe handler.
+	require.JSONEq(t, `{"custom":true}`, string(st.Options.Data))
+
+	claims.Caps = []string{subcaps.Recover}
+	_, err = verifier.VerifySubscribeToken(getHMACToken(t, "secret", claims))
+	require.True(t, errors.Is(err, ErrInvalidToken))
+
+	claims.Caps = []string{"unknown"}
+	_, err = verifier.VerifySubscribeToken(getHMACToken(t, "secret", claims))
+	require.True(t, errors.Is(err, ErrInvalidToken))
+
+	// Without caps channel options used as is.
+	claims = SubscribeTokenClaims{Channel: "test", Client: "client"}
+	st, err = verifier.VerifySubscribeToken(getHMACToken(t, "secret", claims))
+	require.NoError(t, err)
+	require.Nil(t, st.Caps)
+	require.True(t, st.Options.Presence)
+	require.True(t, st.Options.JoinLeave)
+	require.Nil(t, st.Options.Data)
+
+	connClaims := ConnectTokenClaims{
+		Subs: map[string]SubscribeOptions{
+			"test": {Caps: []string{subcaps.JoinLeave}, Base64Data: base64.StdEncoding.EncodeToString([]byte{0xff, 0x00})},
+		},
+		StandardClaims: jwt.StandardClaims{Subject: "2694"},
+	}
+	ct, err := verifier.VerifyConnectToken(getHMACToken(t, "secret", connClaims))
+	require.NoError(t, err)
+	require.True(t, ct.Subs["test"].JoinLeave)
+	require.False(t, ct.Subs["test"].Presence)
+	require.JSONEq(t, `{"caps":["join_leave"],"b64data":"/wA="}`, string(ct.Subs["test"].Data))
+}
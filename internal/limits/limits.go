@@ -0,0 +1,64 @@
+// Package limits contains limits which can be changed with configuration
+// reload without node restart. Limits are enforced by Centrifugo itself, so
+// corresponding limits of Centrifuge node must be turned off when Container
+// used.
+package limits
+
+import (
+	"errors"
+	"sync"
+)
+
+// Config of limits. Zero value of every limit means no limit.
+type Config struct {
+	// ClientChannelLimit is a max number of channels client can be
+	// subscribed to.
+	ClientChannelLimit int `json:"client_channel_limit"`
+	// ClientUserConnectionLimit is a max number of connections of one user
+	// to node.
+	ClientUserConnectionLimit int `json:"client_user_connection_limit"`
+	// APIPresenceLimit is a max number of entries returned by presence
+	// server API method.
+	APIPresenceLimit int `json:"api_presence_limit"`
+}
+
+// Validate Config.
+func (c Config) Validate() error {
+	if c.ClientChannelLimit < 0 || c.ClientUserConnectionLimit < 0 || c.APIPresenceLimit < 0 {
+		return errors.New("limits can't be negative")
+	}
+	return nil
+}
+
+// Container keeps current limits.
+type Container struct {
+	mu     sync.RWMutex
+	config Config
+}
+
+// NewContainer creates Container.
+func NewContainer(c Config) *Container {
+	return &Container{config: c}
+}
+
+// Config returns current limits. Safe to call on nil Container, no limits
+// returned in this case.
+func (c *Container) Config() Config {
+	if c == nil {
+		return Config{}
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config
+}
+
+// Reload sets new limits.
+func (c *Container) Reload(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config = config
+	return nil
+}
@@ -0,0 +1,21 @@
+package limits
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainer(t *testing.T) {
+	var nilContainer *Container
+	require.Equal(t, Config{}, nilContainer.Config())
+
+	c := NewContainer(Config{ClientChannelLimit: 128})
+	require.Equal(t, 128, c.Config().ClientChannelLimit)
+
+	require.Error(t, c.Reload(Config{APIPresenceLimit: -1}))
+	require.Equal(t, Config{ClientChannelLimit: 128}, c.Config())
+
+	require.NoError(t, c.Reload(Config{ClientUserConnectionLimit: 1}))
+	require.Equal(t, Config{ClientUserConnectionLimit: 1}, c.Config())
+}
@@ -0,0 +1,181 @@
+// Package loadshed tracks heap usage of node and switches node into load
+// shedding mode when heap exceeds configured soft limit. While shedding node
+// rejects new connections with retry advice, new subscriptions, client
+// publications and history reads, so memory can be reclaimed before process
+// is killed by OOM killer during traffic spikes. Outgoing traffic of already
+// established connections is not slowed down since queueing it would only
+// increase memory usage.
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/disconnect"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// DefaultCheckInterval is a default interval of heap usage checks.
+const DefaultCheckInterval = time.Second
+
+// recoverRatio of HeapLimit below which shedding stops. Gap between
+// watermarks prevents flapping when heap stays around limit.
+const recoverRatio = 0.9
+
+// Config of Shedder.
+type Config struct {
+	// HeapLimit is a soft limit of heap in bytes. Shedding starts when heap in
+	// use exceeds it.
+	HeapLimit uint64
+	// CheckInterval is an interval of heap usage checks. By default
+	// DefaultCheckInterval used.
+	CheckInterval time.Duration
+	// RetryMinDelay and RetryMaxDelay set a range of random delay rejected
+	// clients advised to wait before next connection attempt.
+	RetryMinDelay time.Duration
+	RetryMaxDelay time.Duration
+}
+
+// Validate config.
+func (c Config) Validate() error {
+	if c.HeapLimit == 0 {
+		return errors.New("heap limit must be positive")
+	}
+	if c.CheckInterval < 0 || c.RetryMinDelay < 0 {
+		return errors.New("check interval and retry delay can't be negative")
+	}
+	if c.RetryMaxDelay < c.RetryMinDelay {
+		return errors.New("retry max delay must not be less than min delay")
+	}
+	return nil
+}
+
+// Shedder checks heap usage periodically and reports whether node should shed
+// load.
+type Shedder struct {
+	config   Config
+	heap     func() uint64
+	shedding int32
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// New creates Shedder.
+func New(c Config) (*Shedder, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	if c.CheckInterval == 0 {
+		c.CheckInterval = DefaultCheckInterval
+	}
+	return &Shedder{
+		config: c,
+		heap:   heapInUse,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// heapSamples are runtime metrics which sum is equal to HeapInuse of
+// runtime.MemStats. Reading them does not stop the world.
+var heapSamples = []string{
+	"/memory/classes/heap/objects:bytes",
+	"/memory/classes/heap/unused:bytes",
+}
+
+func heapInUse() uint64 {
+	samples := make([]metrics.Sample, len(heapSamples))
+	for i, name := range heapSamples {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	var total uint64
+	for _, sample := range samples {
+		if sample.Value.Kind() == metrics.KindUint64 {
+			total += sample.Value.Uint64()
+		}
+	}
+	return total
+}
+
+// Run checks heap usage until ctx done.
+func (s *Shedder) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.check()
+		}
+	}
+}
+
+func (s *Shedder) check() {
+	heap := s.heap()
+	heapInUseGauge.Set(float64(heap))
+	if !s.Shedding() && heap > s.config.HeapLimit {
+		atomic.StoreInt32(&s.shedding, 1)
+		sheddingGauge.Set(1)
+		sheddingStarts.Inc()
+	} else if s.Shedding() && float64(heap) < float64(s.config.HeapLimit)*recoverRatio {
+		atomic.StoreInt32(&s.shedding, 0)
+		sheddingGauge.Set(0)
+	}
+}
+
+// Shedding reports whether node currently sheds load.
+func (s *Shedder) Shedding() bool {
+	return atomic.LoadInt32(&s.shedding) == 1
+}
+
+// AllowConnect returns Disconnect with code disconnect.MemoryLimit and retry
+// advice in reason ("memory limit, retry in <N>ms") if node sheds load.
+func (s *Shedder) AllowConnect() *centrifuge.Disconnect {
+	if !s.Shedding() {
+		return nil
+	}
+	rejected.WithLabelValues("connect").Inc()
+	delay := s.config.RetryMinDelay
+	if s.config.RetryMaxDelay > s.config.RetryMinDelay {
+		s.mu.Lock()
+		delay += time.Duration(s.rand.Int63n(int64(s.config.RetryMaxDelay - s.config.RetryMinDelay)))
+		s.mu.Unlock()
+	}
+	return &centrifuge.Disconnect{
+		Code:      disconnect.MemoryLimit.Code,
+		Reason:    fmt.Sprintf("%s, retry in %dms", disconnect.MemoryLimit.Reason, delay.Milliseconds()),
+		Reconnect: disconnect.MemoryLimit.Reconnect,
+	}
+}
+
+// AllowHistory returns false if history reads paused.
+func (s *Shedder) AllowHistory() bool {
+	return s.allow("history")
+}
+
+// AllowSubscribe returns false if new subscriptions rejected.
+func (s *Shedder) AllowSubscribe() bool {
+	return s.allow("subscribe")
+}
+
+// AllowPublish returns false if client publications rejected.
+func (s *Shedder) AllowPublish() bool {
+	return s.allow("publish")
+}
+
+func (s *Shedder) allow(op string) bool {
+	if !s.Shedding() {
+		return true
+	}
+	rejected.WithLabelValues(op).Inc()
+	return false
+}
@@ -0,0 +1,56 @@
+package loadshed
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/disconnect"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	require.Error(t, Config{}.Validate())
+	require.Error(t, Config{HeapLimit: 100, RetryMinDelay: time.Second}.Validate())
+	require.NoError(t, Config{HeapLimit: 100}.Validate())
+}
+
+func TestShedderWatermarks(t *testing.T) {
+	s, err := New(Config{HeapLimit: 100, RetryMinDelay: time.Second, RetryMaxDelay: 2 * time.Second})
+	require.NoError(t, err)
+	var heap uint64
+	s.heap = func() uint64 { return heap }
+
+	heap = 50
+	s.check()
+	require.False(t, s.Shedding())
+	require.Nil(t, s.AllowConnect())
+	require.True(t, s.AllowHistory())
+	require.True(t, s.AllowSubscribe())
+	require.True(t, s.AllowPublish())
+
+	heap = 101
+	s.check()
+	require.True(t, s.Shedding())
+	d := s.AllowConnect()
+	require.NotNil(t, d)
+	require.Equal(t, disconnect.MemoryLimit.Code, d.Code)
+	require.True(t, d.Reconnect)
+	require.True(t, strings.HasPrefix(d.Reason, "memory limit, retry in "))
+	require.False(t, s.AllowHistory())
+	require.False(t, s.AllowSubscribe())
+	require.False(t, s.AllowPublish())
+
+	// Still shedding until heap goes below recover watermark.
+	heap = 95
+	s.check()
+	require.True(t, s.Shedding())
+	heap = 89
+	s.check()
+	require.False(t, s.Shedding())
+}
+
+func TestHeapInUse(t *testing.T) {
+	require.True(t, heapInUse() > 0)
+}
@@ -0,0 +1,41 @@
+package loadshed
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricsNamespace = "centrifugo"
+
+var (
+	sheddingGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "load_shedding",
+		Name:      "active",
+		Help:      "Whether node currently sheds load due to heap soft limit (1) or not (0).",
+	})
+	heapInUseGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "load_shedding",
+		Name:      "heap_inuse_bytes",
+		Help:      "Heap in use observed by last check of load shedder.",
+	})
+	sheddingStarts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "load_shedding",
+		Name:      "starts_total",
+		Help:      "Number of times node started shedding load.",
+	})
+	rejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "load_shedding",
+		Name:      "rejected_total",
+		Help:      "Number of client operations rejected while shedding load.",
+	}, []string{"operation"})
+)
+
+func init() {
+	prometheus.MustRegister(sheddingGauge)
+	prometheus.MustRegister(heapInUseGauge)
+	prometheus.MustRegister(sheddingStarts)
+	prometheus.MustRegister(rejected)
+}
@@ -0,0 +1,162 @@
+// Package memengine contains in-memory Broker for Centrifuge library which
+// keeps channel history in ring buffers.
+package memengine
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// Broker is an in-memory Broker for running single Centrifugo node. Unlike
+// default memory broker of Centrifuge library it keeps history of each channel
+// in a ring buffer so publishing into a channel with full history does not
+// allocate and reading history only allocates a resulting slice. Memory used
+// by history of all channels can be limited with BrokerConfig.HistoryMaxBytes.
+type Broker struct {
+	node         *centrifuge.Node
+	historyHub   *historyHub
+	eventHandler centrifuge.BrokerEventHandler
+
+	// pubLocks synchronize access to publishing. We have to sync publish
+	// to handle publications in the order of offset.
+	pubLocks []sync.Mutex
+}
+
+var _ centrifuge.Broker = (*Broker)(nil)
+
+// BrokerConfig is a config for memory Broker.
+type BrokerConfig struct {
+	// HistoryMetaTTL sets a time of inactive stream meta information expiration.
+	// At moment works with seconds precision.
+	HistoryMetaTTL time.Duration
+	// HistoryMaxBytes limits approximate memory used by publications kept in
+	// history of all channels. When limit reached the oldest publications of
+	// least recently used channels (by publish or history read) are evicted
+	// first. Zero value means no limit.
+	HistoryMaxBytes int64
+}
+
+const numPubLocks = 4096
+
+// NewBroker initializes memory Broker.
+func NewBroker(n *centrifuge.Node, config BrokerConfig) (*Broker, error) {
+	b := &Broker{
+		node:       n,
+		historyHub: newHistoryHub(config.HistoryMetaTTL, config.HistoryMaxBytes),
+		pubLocks:   make([]sync.Mutex, numPubLocks),
+	}
+	return b, nil
+}
+
+// Run runs memory broker.
+func (b *Broker) Run(h centrifuge.BrokerEventHandler) error {
+	b.eventHandler = h
+	b.historyHub.runCleanups()
+	return nil
+}
+
+// Close stops history cleanups.
+func (b *Broker) Close(_ context.Context) error {
+	b.historyHub.close()
+	return nil
+}
+
+// index chooses bucket number in range [0, numBuckets).
+func index(s string, numBuckets int) int {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(s))
+	return int(hash.Sum64() % uint64(numBuckets))
+}
+
+// Publish adds publication into history hub and calls node method to handle it.
+func (b *Broker) Publish(ch string, data []byte, opts centrifuge.PublishOptions) (centrifuge.StreamPosition, error) {
+	mu := &b.pubLocks[index(ch, numPubLocks)]
+	mu.Lock()
+	defer mu.Unlock()
+
+	pub := &centrifuge.Publication{
+		Data: data,
+		Info: opts.ClientInfo,
+	}
+	if opts.HistorySize > 0 && opts.HistoryTTL > 0 {
+		streamTop := b.historyHub.add(ch, pub, opts)
+		return streamTop, b.eventHandler.HandlePublication(ch, pub, streamTop)
+	}
+	return centrifuge.StreamPosition{}, b.eventHandler.HandlePublication(ch, pub, centrifuge.StreamPosition{})
+}
+
+// PublishJoin - see centrifuge.Broker interface description.
+func (b *Broker) PublishJoin(ch string, info *centrifuge.ClientInfo) error {
+	return b.eventHandler.HandleJoin(ch, info)
+}
+
+// PublishLeave - see centrifuge.Broker interface description.
+func (b *Broker) PublishLeave(ch string, info *centrifuge.ClientInfo) error {
+	return b.eventHandler.HandleLeave(ch, info)
+}
+
+// PublishControl - see centrifuge.Broker interface description.
+func (b *Broker) PublishControl(data []byte, _, _ string) error {
+	return b.eventHandler.HandleControl(data)
+}
+
+// Subscribe is noop here.
+func (b *Broker) Subscribe(_ string) error {
+	return nil
+}
+
+// Unsubscribe is noop here.
+func (b *Broker) Unsubscribe(_ string) error {
+	return nil
+}
+
+// History - see centrifuge.Broker interface description.
+func (b *Broker) History(ch string, filter centrifuge.HistoryFilter) ([]*centrifuge.Publication, centrifuge.StreamPosition, error) {
+	return b.historyHub.get(ch, filter)
+}
+
+// HistoryFromTime returns publications added to channel history at or after
+// from time, oldest first. Limit has the same meaning as in HistoryFilter:
+// negative value means no limit, zero value means only stream position
+// returned.
+func (b *Broker) HistoryFromTime(ch string, from time.Time, limit int) ([]*centrifuge.Publication, centrifuge.StreamPosition, error) {
+	return b.historyHub.getFromTime(ch, from, limit)
+}
+
+// HistoryMeta describes state of channel history stream.
+type HistoryMeta struct {
+	// NumPublications is a number of publications currently kept in history.
+	NumPublications int
+	// OldestOffset is an offset of the oldest publication in history, zero
+	// if history is empty.
+	OldestOffset uint64
+	// Offset is a top offset of stream.
+	Offset uint64
+	// Epoch of stream, empty if stream was never used.
+	Epoch string
+	// TTL is a remaining time until history expires, zero if history is empty.
+	TTL time.Duration
+}
+
+// HistoryMeta returns history state of channel without fetching publications.
+func (b *Broker) HistoryMeta(ch string) (HistoryMeta, error) {
+	return b.historyHub.meta(ch), nil
+}
+
+// CompactHistory removes publication previously published into channel with
+// the same key from history. Publication at stream position sp becomes the
+// latest one for key. Compaction happens synchronously.
+func (b *Broker) CompactHistory(ch string, key string, sp centrifuge.StreamPosition) error {
+	b.historyHub.compact(ch, key, sp)
+	return nil
+}
+
+// RemoveHistory - see centrifuge.Broker interface description.
+func (b *Broker) RemoveHistory(ch string) error {
+	b.historyHub.remove(ch)
+	return nil
+}
@@ -0,0 +1,382 @@
+package memengine
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/enginetest"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+type testBrokerEventHandler struct{}
+
+func (h *testBrokerEventHandler) HandlePublication(_ string, _ *centrifuge.Publication, _ centrifuge.StreamPosition) error {
+	return nil
+}
+
+func (h *testBrokerEventHandler) HandleJoin(_ string, _ *centrifuge.ClientInfo) error {
+	return nil
+}
+
+func (h *testBrokerEventHandler) HandleLeave(_ string, _ *centrifuge.ClientInfo) error {
+	return nil
+}
+
+func (h *testBrokerEventHandler) HandleControl(_ []byte) error {
+	return nil
+}
+
+func newTestBroker(tb testing.TB, config BrokerConfig) *Broker {
+	n, _ := centrifuge.New(centrifuge.DefaultConfig)
+	b, err := NewBroker(n, config)
+	require.NoError(tb, err)
+	require.NoError(tb, b.Run(&testBrokerEventHandler{}))
+	return b
+}
+
+func TestBrokerPublishHistory(t *testing.T) {
+	b := newTestBroker(t, BrokerConfig{})
+
+	sp, err := b.Publish("channel", []byte("{}"), centrifuge.PublishOptions{})
+	require.NoError(t, err)
+	require.Equal(t, centrifuge.StreamPosition{}, sp)
+
+	pubs, _, err := b.History("channel", centrifuge.HistoryFilter{Limit: -1})
+	require.NoError(t, err)
+	require.Len(t, pubs, 0)
+
+	opts := centrifuge.PublishOptions{HistorySize: 2, HistoryTTL: time.Minute}
+	for i := 0; i < 5; i++ {
+		sp, err = b.Publish("channel", []byte(strconv.Itoa(i)), opts)
+		require.NoError(t, err)
+		require.Equal(t, uint64(i+1), sp.Offset)
+	}
+
+	pubs, sp, err = b.History("channel", centrifuge.HistoryFilter{Limit: -1})
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), sp.Offset)
+	require.Len(t, pubs, 2)
+	require.Equal(t, uint64(4), pubs[0].Offset)
+	require.Equal(t, []byte("4"), pubs[1].Data)
+
+	pubs, _, err = b.History("channel", centrifuge.HistoryFilter{Limit: -1, Reverse: true})
+	require.NoError(t, err)
+	require.Len(t, pubs, 2)
+	require.Equal(t, uint64(5), pubs[0].Offset)
+
+	pubs, _, err = b.History("channel", centrifuge.HistoryFilter{Limit: 0})
+	require.NoError(t, err)
+	require.Len(t, pubs, 0)
+
+	require.NoError(t, b.RemoveHistory("channel"))
+	pubs, sp2, err := b.History("channel", centrifuge.HistoryFilter{Limit: -1})
+	require.NoError(t, err)
+	require.Len(t, pubs, 0)
+	require.Equal(t, sp, sp2)
+}
+
+func TestBrokerHistorySince(t *testing.T) {
+	b := newTestBroker(t, BrokerConfig{})
+
+	opts := centrifuge.PublishOptions{HistorySize: 10, HistoryTTL: time.Minute}
+	var sp centrifuge.StreamPosition
+	for i := 0; i < 8; i++ {
+		var err error
+		sp, err = b.Publish("channel", []byte(strconv.Itoa(i)), opts)
+		require.NoError(t, err)
+	}
+
+	pubs, _, err := b.History("channel", centrifuge.HistoryFilter{
+		Since: &centrifuge.StreamPosition{Offset: 5, Epoch: sp.Epoch},
+		Limit: -1,
+	})
+	require.NoError(t, err)
+	require.Len(t, pubs, 3)
+	require.Equal(t, uint64(6), pubs[0].Offset)
+
+	pubs, _, err = b.History("channel", centrifuge.HistoryFilter{
+		Since: &centrifuge.StreamPosition{Offset: 5, Epoch: sp.Epoch},
+		Limit: 2,
+	})
+	require.NoError(t, err)
+	require.Len(t, pubs, 2)
+	require.Equal(t, uint64(7), pubs[1].Offset)
+
+	pubs, _, err = b.History("channel", centrifuge.HistoryFilter{
+		Since:   &centrifuge.StreamPosition{Offset: 5, Epoch: sp.Epoch},
+		Limit:   -1,
+		Reverse: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, pubs, 4)
+	require.Equal(t, uint64(4), pubs[0].Offset)
+	require.Equal(t, uint64(1), pubs[3].Offset)
+
+	pubs, _, err = b.History("channel", centrifuge.HistoryFilter{
+		Since: &centrifuge.StreamPosition{Offset: 8, Epoch: sp.Epoch},
+		Limit: -1,
+	})
+	require.NoError(t, err)
+	require.Len(t, pubs, 0)
+}
+
+func TestBrokerHistoryFromTime(t *testing.T) {
+	b := newTestBroker(t, BrokerConfig{})
+
+	start := time.Now()
+	opts := centrifuge.PublishOptions{HistorySize: 10, HistoryTTL: time.Minute}
+	for i := 0; i < 3; i++ {
+		_, err := b.Publish("channel", []byte(strconv.Itoa(i)), opts)
+		require.NoError(t, err)
+	}
+
+	pubs, sp, err := b.HistoryFromTime("channel", start, -1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), sp.Offset)
+	require.Len(t, pubs, 3)
+	require.Equal(t, uint64(1), pubs[0].Offset)
+
+	pubs, _, err = b.HistoryFromTime("channel", start, 2)
+	require.NoError(t, err)
+	require.Len(t, pubs, 2)
+
+	pubs, sp, err = b.HistoryFromTime("channel", start, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), sp.Offset)
+	require.Len(t, pubs, 0)
+
+	pubs, _, err = b.HistoryFromTime("channel", time.Now().Add(time.Second), -1)
+	require.NoError(t, err)
+	require.Len(t, pubs, 0)
+
+	pubs, sp, err = b.HistoryFromTime("other", start, -1)
+	require.NoError(t, err)
+	require.Len(t, pubs, 0)
+	require.Equal(t, uint64(0), sp.Offset)
+}
+
+func TestStreamOffsetFromTime(t *testing.T) {
+	s := newStream()
+	for i := 0; i < 10; i++ {
+		s.addAt(&centrifuge.Publication{}, 5, int64(i*10))
+	}
+	// Stream keeps publications 6-10 published at 50-90.
+	require.Equal(t, uint64(6), s.offsetFromTime(0))
+	require.Equal(t, uint64(7), s.offsetFromTime(55))
+	require.Equal(t, uint64(7), s.offsetFromTime(60))
+	require.Equal(t, uint64(11), s.offsetFromTime(100))
+
+	// Time never decreases.
+	s.addAt(&centrifuge.Publication{}, 5, 0)
+	require.Equal(t, uint64(10), s.offsetFromTime(90))
+}
+
+func TestBrokerHistoryMaxBytes(t *testing.T) {
+	b := newTestBroker(t, BrokerConfig{HistoryMaxBytes: 10})
+
+	opts := centrifuge.PublishOptions{HistorySize: 10, HistoryTTL: time.Minute}
+	for i := 0; i < 5; i++ {
+		_, err := b.Publish("channel1", []byte("1234"), opts)
+		require.NoError(t, err)
+	}
+	pubs, sp, err := b.History("channel1", centrifuge.HistoryFilter{Limit: -1})
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), sp.Offset)
+	require.Len(t, pubs, 2)
+	require.Equal(t, int64(8), b.historyHub.numBytes)
+
+	// Publications of least recently used channel evicted first.
+	_, err = b.Publish("channel2", []byte("1234"), opts)
+	require.NoError(t, err)
+	pubs, _, err = b.History("channel2", centrifuge.HistoryFilter{Limit: -1})
+	require.NoError(t, err)
+	require.Len(t, pubs, 1)
+	pubs, _, err = b.History("channel1", centrifuge.HistoryFilter{Limit: -1})
+	require.NoError(t, err)
+	require.Len(t, pubs, 1)
+	require.Equal(t, int64(8), b.historyHub.numBytes)
+
+	// History read of channel1 above made channel2 least recently used.
+	_, err = b.Publish("channel3", []byte("1234"), opts)
+	require.NoError(t, err)
+	pubs, _, err = b.History("channel2", centrifuge.HistoryFilter{Limit: -1})
+	require.NoError(t, err)
+	require.Len(t, pubs, 0)
+	require.Equal(t, int64(8), b.historyHub.numBytes)
+
+	require.NoError(t, b.RemoveHistory("channel1"))
+	require.NoError(t, b.RemoveHistory("channel3"))
+	require.Equal(t, int64(0), b.historyHub.numBytes)
+	require.Equal(t, 0, b.historyHub.lru.Len())
+}
+
+func TestBrokerCloseStopsCleanups(t *testing.T) {
+	b := newTestBroker(t, BrokerConfig{})
+	require.NoError(t, b.Close(context.Background()))
+	require.NoError(t, b.Close(context.Background()))
+	select {
+	case <-b.historyHub.closeCh:
+	default:
+		require.Fail(t, "cleanups not stopped")
+	}
+}
+
+func TestBrokerHistoryMeta(t *testing.T) {
+	b := newTestBroker(t, BrokerConfig{})
+
+	meta, err := b.HistoryMeta("channel")
+	require.NoError(t, err)
+	require.Equal(t, HistoryMeta{}, meta)
+
+	opts := centrifuge.PublishOptions{HistorySize: 3, HistoryTTL: time.Minute}
+	var sp centrifuge.StreamPosition
+	for i := 0; i < 5; i++ {
+		sp, err = b.Publish("channel", []byte("{}"), opts)
+		require.NoError(t, err)
+	}
+	meta, err = b.HistoryMeta("channel")
+	require.NoError(t, err)
+	require.Equal(t, 3, meta.NumPublications)
+	require.Equal(t, uint64(3), meta.OldestOffset)
+	require.Equal(t, uint64(5), meta.Offset)
+	require.Equal(t, sp.Epoch, meta.Epoch)
+	require.True(t, meta.TTL > 0 && meta.TTL <= time.Minute)
+}
+
+func TestBrokerCompactHistory(t *testing.T) {
+	b := newTestBroker(t, BrokerConfig{})
+
+	opts := centrifuge.PublishOptions{HistorySize: 10, HistoryTTL: time.Minute}
+	for _, key := range []string{"a", "b", "a", "", "b", "a"} {
+		sp, err := b.Publish("channel", []byte(`"`+key+`"`), opts)
+		require.NoError(t, err)
+		if key != "" {
+			require.NoError(t, b.CompactHistory("channel", key, sp))
+		}
+	}
+	pubs, sp, err := b.History("channel", centrifuge.HistoryFilter{Limit: -1})
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), sp.Offset)
+	require.Len(t, pubs, 3)
+	require.Equal(t, []uint64{4, 5, 6}, []uint64{pubs[0].Offset, pubs[1].Offset, pubs[2].Offset})
+
+	pubs, _, err = b.History("channel", centrifuge.HistoryFilter{Limit: 1, Reverse: true})
+	require.NoError(t, err)
+	require.Len(t, pubs, 1)
+	require.Equal(t, uint64(6), pubs[0].Offset)
+
+	meta, err := b.HistoryMeta("channel")
+	require.NoError(t, err)
+	require.Equal(t, 3, meta.NumPublications)
+	require.Equal(t, uint64(4), meta.OldestOffset)
+
+	// Outdated position does not remove newer publication.
+	require.NoError(t, b.CompactHistory("channel", "a", centrifuge.StreamPosition{Offset: 1, Epoch: sp.Epoch}))
+	pubs, _, err = b.History("channel", centrifuge.HistoryFilter{Limit: -1})
+	require.NoError(t, err)
+	require.Len(t, pubs, 3)
+
+	var numBytes int64
+	for _, pub := range pubs {
+		numBytes += publicationSize(pub)
+	}
+	require.Equal(t, numBytes, b.historyHub.numBytes)
+}
+
+func TestHistoryHubExpiration(t *testing.T) {
+	h := newHistoryHub(time.Second, 0)
+	opts := centrifuge.PublishOptions{HistorySize: 10, HistoryTTL: time.Second}
+	sp := h.add("channel", &centrifuge.Publication{Data: []byte("1")}, opts)
+
+	now := time.Now().Unix()
+	h.cleanup(now + 2)
+	require.Len(t, h.streams, 0)
+	require.Equal(t, int64(0), h.numBytes)
+
+	_, sp2, err := h.get("channel", centrifuge.HistoryFilter{})
+	require.NoError(t, err)
+	require.NotEqual(t, sp.Epoch, sp2.Epoch)
+}
+
+func TestStreamGrow(t *testing.T) {
+	s := newStream()
+	for i := 0; i < 100; i++ {
+		s.add(&centrifuge.Publication{}, 40)
+	}
+	require.Len(t, s.buf, 40)
+	require.Equal(t, 40, s.length)
+	pubs := s.get(0, false, -1, false)
+	require.Len(t, pubs, 40)
+	for i, pub := range pubs {
+		require.Equal(t, uint64(61+i), pub.Offset)
+	}
+}
+
+func BenchmarkBrokerPublishHistory(b *testing.B) {
+	broker := newTestBroker(b, BrokerConfig{})
+	opts := centrifuge.PublishOptions{HistorySize: 100, HistoryTTL: time.Minute}
+	data := []byte(`{"input": "test"}`)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := broker.Publish("channel", data, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBrokerPublishHistoryParallel(b *testing.B) {
+	broker := newTestBroker(b, BrokerConfig{})
+	opts := centrifuge.PublishOptions{HistorySize: 100, HistoryTTL: time.Minute}
+	data := []byte(`{"input": "test"}`)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := broker.Publish("channel", data, opts)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkBrokerHistory(b *testing.B) {
+	broker := newTestBroker(b, BrokerConfig{})
+	opts := centrifuge.PublishOptions{HistorySize: 100, HistoryTTL: time.Minute}
+	data := []byte(`{"input": "test"}`)
+	for i := 0; i < 100; i++ {
+		_, _ = broker.Publish("channel", data, opts)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pubs, _, err := broker.History("channel", centrifuge.HistoryFilter{Limit: -1})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(pubs) != 100 {
+			b.Fatal("wrong history length")
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	enginetest.Run(t, func(t *testing.T) enginetest.Engine {
+		n, _ := centrifuge.New(centrifuge.DefaultConfig)
+		b, err := NewBroker(n, BrokerConfig{HistoryMetaTTL: time.Second})
+		require.NoError(t, err)
+		pm, err := centrifuge.NewMemoryPresenceManager(n, centrifuge.MemoryPresenceManagerConfig{})
+		require.NoError(t, err)
+		return enginetest.Engine{
+			Broker:          b,
+			PresenceManager: pm,
+			HistoryMetaTTL:  time.Second,
+		}
+	})
+}
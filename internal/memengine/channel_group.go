@@ -0,0 +1,43 @@
+package memengine
+
+import (
+	"sync"
+)
+
+// ChannelGroupStore keeps source channels of channel groups in process
+// memory.
+type ChannelGroupStore struct {
+	mu     sync.RWMutex
+	groups map[string][]string
+}
+
+// NewChannelGroupStore creates ChannelGroupStore.
+func NewChannelGroupStore() *ChannelGroupStore {
+	return &ChannelGroupStore{
+		groups: make(map[string][]string),
+	}
+}
+
+// ChannelGroup returns source channels of channel group, nil returned if
+// group not found.
+func (s *ChannelGroupStore) ChannelGroup(ch string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.groups[ch], nil
+}
+
+// SetChannelGroup sets source channels of channel group.
+func (s *ChannelGroupStore) SetChannelGroup(ch string, sources []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groups[ch] = append([]string(nil), sources...)
+	return nil
+}
+
+// RemoveChannelGroup removes channel group.
+func (s *ChannelGroupStore) RemoveChannelGroup(ch string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.groups, ch)
+	return nil
+}
@@ -0,0 +1,24 @@
+package memengine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelGroupStore(t *testing.T) {
+	s := NewChannelGroupStore()
+	sources, err := s.ChannelGroup("group")
+	require.NoError(t, err)
+	require.Nil(t, sources)
+
+	require.NoError(t, s.SetChannelGroup("group", []string{"a", "b"}))
+	sources, err = s.ChannelGroup("group")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, sources)
+
+	require.NoError(t, s.RemoveChannelGroup("group"))
+	sources, err = s.ChannelGroup("group")
+	require.NoError(t, err)
+	require.Nil(t, sources)
+}
@@ -0,0 +1,45 @@
+package memengine
+
+import (
+	"sync"
+)
+
+// ChannelOptionsStore keeps channel option overrides in process memory.
+type ChannelOptionsStore struct {
+	mu        sync.RWMutex
+	overrides map[string][]byte
+}
+
+// NewChannelOptionsStore creates ChannelOptionsStore.
+func NewChannelOptionsStore() *ChannelOptionsStore {
+	return &ChannelOptionsStore{
+		overrides: make(map[string][]byte),
+	}
+}
+
+// SetChannelOptionsOverride saves override of channel.
+func (s *ChannelOptionsStore) SetChannelOptionsOverride(ch string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides[ch] = append([]byte(nil), data...)
+	return nil
+}
+
+// DeleteChannelOptionsOverride removes override of channel.
+func (s *ChannelOptionsStore) DeleteChannelOptionsOverride(ch string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.overrides, ch)
+	return nil
+}
+
+// ChannelOptionsOverrides returns overrides of all channels.
+func (s *ChannelOptionsStore) ChannelOptionsOverrides() (map[string][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	overrides := make(map[string][]byte, len(s.overrides))
+	for ch, data := range s.overrides {
+		overrides[ch] = data
+	}
+	return overrides, nil
+}
@@ -0,0 +1,24 @@
+package memengine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelOptionsStore(t *testing.T) {
+	s := NewChannelOptionsStore()
+	overrides, err := s.ChannelOptionsOverrides()
+	require.NoError(t, err)
+	require.Len(t, overrides, 0)
+
+	require.NoError(t, s.SetChannelOptionsOverride("hot", []byte(`{"presence":true}`)))
+	overrides, err = s.ChannelOptionsOverrides()
+	require.NoError(t, err)
+	require.Equal(t, map[string][]byte{"hot": []byte(`{"presence":true}`)}, overrides)
+
+	require.NoError(t, s.DeleteChannelOptionsOverride("hot"))
+	overrides, err = s.ChannelOptionsOverrides()
+	require.NoError(t, err)
+	require.Len(t, overrides, 0)
+}
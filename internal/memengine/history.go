@@ -0,0 +1,304 @@
+package memengine
+
+import (
+	"container/heap"
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// expirer keeps expiration times of channels and allows to find expired ones
+// without iterating over all channels.
+type expirer struct {
+	queue     priorityQueue
+	expires   map[string]int64
+	nextCheck int64
+}
+
+func newExpirer() *expirer {
+	return &expirer{
+		expires: make(map[string]int64),
+	}
+}
+
+func (e *expirer) set(ch string, expireAt int64) {
+	if _, ok := e.expires[ch]; !ok {
+		heap.Push(&e.queue, &queueItem{channel: ch, priority: expireAt})
+	}
+	e.expires[ch] = expireAt
+	if e.nextCheck == 0 || e.nextCheck > expireAt {
+		e.nextCheck = expireAt
+	}
+}
+
+// expired calls fn for every channel which expired at the moment.
+func (e *expirer) expired(now int64, fn func(ch string)) {
+	if e.nextCheck == 0 || e.nextCheck > now {
+		return
+	}
+	var nextCheck int64
+	for e.queue.Len() > 0 {
+		item := heap.Pop(&e.queue).(*queueItem)
+		expireAt := item.priority
+		if expireAt > now {
+			heap.Push(&e.queue, item)
+			nextCheck = expireAt
+			break
+		}
+		exp, ok := e.expires[item.channel]
+		if !ok {
+			continue
+		}
+		if exp <= expireAt {
+			delete(e.expires, item.channel)
+			fn(item.channel)
+		} else {
+			heap.Push(&e.queue, &queueItem{channel: item.channel, priority: exp})
+		}
+	}
+	e.nextCheck = nextCheck
+}
+
+// historyHub keeps streams of all channels. Memory used by publications
+// in all streams can be limited with maxBytes, in this case the oldest
+// publications of least recently used channels evicted first.
+type historyHub struct {
+	mu             sync.Mutex
+	streams        map[string]*stream
+	historyMetaTTL time.Duration
+	maxBytes       int64
+	numBytes       int64
+	expires        *expirer
+	removes        *expirer
+	// lru keeps channels with publications in history from most to least
+	// recently used, only maintained when maxBytes set.
+	lru      *list.List
+	lruElems map[string]*list.Element
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+func newHistoryHub(historyMetaTTL time.Duration, maxBytes int64) *historyHub {
+	return &historyHub{
+		streams:        make(map[string]*stream),
+		historyMetaTTL: historyMetaTTL,
+		maxBytes:       maxBytes,
+		expires:        newExpirer(),
+		removes:        newExpirer(),
+		lru:            list.New(),
+		lruElems:       make(map[string]*list.Element),
+		closeCh:        make(chan struct{}),
+	}
+}
+
+func (h *historyHub) runCleanups() {
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.closeCh:
+				return
+			case <-ticker.C:
+				h.cleanup(time.Now().Unix())
+			}
+		}
+	}()
+}
+
+// close stops cleanups.
+func (h *historyHub) close() {
+	h.closeOnce.Do(func() {
+		close(h.closeCh)
+	})
+}
+
+// touch marks channel as the most recently used. Lock must be held outside.
+func (h *historyHub) touch(ch string) {
+	if h.maxBytes <= 0 {
+		return
+	}
+	if e, ok := h.lruElems[ch]; ok {
+		h.lru.MoveToFront(e)
+		return
+	}
+	h.lruElems[ch] = h.lru.PushFront(ch)
+}
+
+// forget removes channel from LRU list. Lock must be held outside.
+func (h *historyHub) forget(ch string) {
+	if e, ok := h.lruElems[ch]; ok {
+		h.lru.Remove(e)
+		delete(h.lruElems, ch)
+	}
+}
+
+// evict removes the oldest publications of least recently used channels until
+// memory used by history fits maxBytes. Lock must be held outside.
+func (h *historyHub) evict() {
+	for h.numBytes > h.maxBytes {
+		e := h.lru.Back()
+		if e == nil {
+			return
+		}
+		ch := e.Value.(string)
+		s, ok := h.streams[ch]
+		if !ok || s.length == 0 {
+			h.forget(ch)
+			continue
+		}
+		h.numBytes -= s.evictOldest()
+		if s.length == 0 {
+			h.forget(ch)
+		}
+	}
+}
+
+func (h *historyHub) cleanup(now int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.expires.expired(now, func(ch string) {
+		if s, ok := h.streams[ch]; ok {
+			h.numBytes -= s.clear()
+			h.forget(ch)
+		}
+	})
+	h.removes.expired(now, func(ch string) {
+		if s, ok := h.streams[ch]; ok {
+			h.numBytes -= s.clear()
+			h.forget(ch)
+			delete(h.streams, ch)
+		}
+	})
+}
+
+// Lock must be held outside.
+func (h *historyHub) touchMeta(ch string) {
+	if h.historyMetaTTL > 0 {
+		h.removes.set(ch, time.Now().Unix()+int64(h.historyMetaTTL.Seconds()))
+	}
+}
+
+func (h *historyHub) add(ch string, pub *centrifuge.Publication, opts centrifuge.PublishOptions) centrifuge.StreamPosition {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.expires.set(ch, time.Now().Unix()+int64(opts.HistoryTTL.Seconds()))
+	h.touchMeta(ch)
+
+	s, ok := h.streams[ch]
+	if !ok {
+		s = newStream()
+		h.streams[ch] = s
+	}
+	offset, delta := s.add(pub, opts.HistorySize)
+	h.numBytes += delta
+	if h.maxBytes > 0 {
+		h.touch(ch)
+		h.evict()
+	}
+	return centrifuge.StreamPosition{Offset: offset, Epoch: s.epoch}
+}
+
+func (h *historyHub) get(ch string, filter centrifuge.HistoryFilter) ([]*centrifuge.Publication, centrifuge.StreamPosition, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.touchMeta(ch)
+
+	s, ok := h.streams[ch]
+	if !ok {
+		s = newStream()
+		h.streams[ch] = s
+		return nil, centrifuge.StreamPosition{Offset: 0, Epoch: s.epoch}, nil
+	}
+	if s.length > 0 {
+		h.touch(ch)
+	}
+
+	streamPosition := centrifuge.StreamPosition{Offset: s.top, Epoch: s.epoch}
+
+	if filter.Since == nil {
+		return s.get(0, false, filter.Limit, filter.Reverse), streamPosition, nil
+	}
+
+	since := filter.Since
+
+	if !filter.Reverse {
+		if streamPosition.Offset == since.Offset && since.Epoch == s.epoch {
+			return nil, streamPosition, nil
+		}
+	}
+
+	streamOffset := since.Offset + 1
+	if filter.Reverse {
+		streamOffset = since.Offset - 1
+	}
+	return s.get(streamOffset, true, filter.Limit, filter.Reverse), streamPosition, nil
+}
+
+func (h *historyHub) getFromTime(ch string, from time.Time, limit int) ([]*centrifuge.Publication, centrifuge.StreamPosition, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.touchMeta(ch)
+
+	s, ok := h.streams[ch]
+	if !ok {
+		s = newStream()
+		h.streams[ch] = s
+		return nil, centrifuge.StreamPosition{Offset: 0, Epoch: s.epoch}, nil
+	}
+	if s.length > 0 {
+		h.touch(ch)
+	}
+
+	streamPosition := centrifuge.StreamPosition{Offset: s.top, Epoch: s.epoch}
+	return s.get(s.offsetFromTime(from.UnixNano()), true, limit, false), streamPosition, nil
+}
+
+func (h *historyHub) meta(ch string) HistoryMeta {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.streams[ch]
+	if !ok {
+		return HistoryMeta{}
+	}
+	meta := HistoryMeta{
+		NumPublications: s.length - s.holes,
+		Offset:          s.top,
+		Epoch:           s.epoch,
+	}
+	if meta.NumPublications > 0 {
+		meta.OldestOffset = s.oldestOffset()
+		if expireAt, ok := h.expires.expires[ch]; ok {
+			if ttl := time.Until(time.Unix(expireAt, 0)); ttl > 0 {
+				meta.TTL = ttl
+			}
+		}
+	}
+	return meta
+}
+
+func (h *historyHub) compact(ch string, key string, sp centrifuge.StreamPosition) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.streams[ch]
+	if !ok || s.epoch != sp.Epoch {
+		return
+	}
+	h.numBytes -= s.compact(key, sp.Offset)
+}
+
+func (h *historyHub) remove(ch string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.streams[ch]; ok {
+		h.numBytes -= s.clear()
+		h.forget(ch)
+	}
+}
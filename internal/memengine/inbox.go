@@ -0,0 +1,95 @@
+package memengine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/inbox"
+)
+
+type userInbox struct {
+	entries  []inbox.Entry
+	expireAt time.Time
+}
+
+// InboxStore keeps inboxes of users in process memory.
+type InboxStore struct {
+	mu      sync.Mutex
+	users   map[string]*userInbox
+	expires *expirer
+}
+
+// NewInboxStore creates InboxStore.
+func NewInboxStore() *InboxStore {
+	return &InboxStore{
+		users:   make(map[string]*userInbox),
+		expires: newExpirer(),
+	}
+}
+
+// Run starts removing expired inboxes.
+func (s *InboxStore) Run() {
+	go func() {
+		for {
+			time.Sleep(time.Second)
+			s.cleanup(time.Now())
+		}
+	}()
+}
+
+func (s *InboxStore) cleanup(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expires.expired(now.Unix(), func(user string) {
+		if in, ok := s.users[user]; ok && !in.expireAt.After(now) {
+			delete(s.users, user)
+		}
+	})
+}
+
+// AddToInbox adds entry to inbox of user.
+func (s *InboxStore) AddToInbox(user string, e inbox.Entry, opts inbox.Options) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	in, ok := s.users[user]
+	if !ok || !in.expireAt.After(now) {
+		in = &userInbox{}
+		s.users[user] = in
+	}
+	in.entries = append(in.entries, e)
+	if opts.Size > 0 && len(in.entries) > opts.Size {
+		in.entries = append(in.entries[:0:0], in.entries[len(in.entries)-opts.Size:]...)
+	}
+	in.expireAt = now.Add(opts.TTL)
+	// Expirer works with seconds precision, round up to not remove inbox early.
+	s.expires.set(user, in.expireAt.Add(time.Second-1).Unix())
+	return nil
+}
+
+// PeekInbox returns all entries of user inbox without removing them.
+func (s *InboxStore) PeekInbox(user string) ([]inbox.Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	in, ok := s.users[user]
+	if !ok || !in.expireAt.After(time.Now()) {
+		return nil, nil
+	}
+	return append([]inbox.Entry(nil), in.entries...), nil
+}
+
+// TrimInbox removes n oldest entries from inbox of user.
+func (s *InboxStore) TrimInbox(user string, n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	in, ok := s.users[user]
+	if !ok {
+		return nil
+	}
+	if n >= len(in.entries) {
+		delete(s.users, user)
+		return nil
+	}
+	in.entries = append(in.entries[:0:0], in.entries[n:]...)
+	return nil
+}
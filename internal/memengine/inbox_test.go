@@ -0,0 +1,49 @@
+package memengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/inbox"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInboxStore(t *testing.T) {
+	s := NewInboxStore()
+	opts := inbox.Options{Size: 2, TTL: time.Minute}
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, s.AddToInbox("user", inbox.Entry{Data: []byte("{}"), Time: int64(i)}, opts))
+	}
+	entries, err := s.PeekInbox("user")
+	require.NoError(t, err)
+	require.Equal(t, []inbox.Entry{{Data: []byte("{}"), Time: 2}, {Data: []byte("{}"), Time: 3}}, entries)
+
+	// Peek does not remove entries.
+	entries, err = s.PeekInbox("user")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	require.NoError(t, s.TrimInbox("user", 1))
+	entries, err = s.PeekInbox("user")
+	require.NoError(t, err)
+	require.Equal(t, []inbox.Entry{{Data: []byte("{}"), Time: 3}}, entries)
+
+	require.NoError(t, s.TrimInbox("user", 1))
+	entries, err = s.PeekInbox("user")
+	require.NoError(t, err)
+	require.Len(t, entries, 0)
+}
+
+func TestInboxStoreExpiration(t *testing.T) {
+	s := NewInboxStore()
+	require.NoError(t, s.AddToInbox("user", inbox.Entry{Data: []byte("{}")}, inbox.Options{TTL: time.Second}))
+	s.cleanup(time.Now().Add(3 * time.Second))
+	require.Len(t, s.users, 0)
+
+	require.NoError(t, s.AddToInbox("user", inbox.Entry{Data: []byte("{}")}, inbox.Options{TTL: time.Nanosecond}))
+	time.Sleep(time.Millisecond)
+	entries, err := s.PeekInbox("user")
+	require.NoError(t, err)
+	require.Len(t, entries, 0)
+}
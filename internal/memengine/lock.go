@@ -0,0 +1,77 @@
+package memengine
+
+import (
+	"sync"
+	"time"
+)
+
+type lock struct {
+	owner    string
+	expireAt time.Time
+}
+
+// Locker keeps locks in process memory. It only provides exclusion between
+// callers of a single Centrifugo node.
+type Locker struct {
+	mu      sync.Mutex
+	locks   map[string]*lock
+	expires *expirer
+}
+
+// NewLocker creates Locker.
+func NewLocker() *Locker {
+	return &Locker{
+		locks:   make(map[string]*lock),
+		expires: newExpirer(),
+	}
+}
+
+// Run starts removing expired locks.
+func (l *Locker) Run() {
+	go func() {
+		for {
+			time.Sleep(time.Second)
+			l.cleanup(time.Now())
+		}
+	}()
+}
+
+func (l *Locker) cleanup(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expires.expired(now.Unix(), func(name string) {
+		if lk, ok := l.locks[name]; ok && !lk.expireAt.After(now) {
+			delete(l.locks, name)
+		}
+	})
+}
+
+// AcquireLock acquires lock for owner for ttl. Lock already held by the same
+// owner extended for ttl. Returns false if lock held by another owner.
+func (l *Locker) AcquireLock(name string, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if lk, ok := l.locks[name]; ok && lk.owner != owner && lk.expireAt.After(now) {
+		return false, nil
+	}
+	expireAt := now.Add(ttl)
+	l.locks[name] = &lock{owner: owner, expireAt: expireAt}
+	// Expirer works with seconds precision, round up to not remove lock early.
+	l.expires.set(name, expireAt.Add(time.Second-1).Unix())
+	return true, nil
+}
+
+// ReleaseLock releases lock held by owner. Returns false if lock not held by
+// owner.
+func (l *Locker) ReleaseLock(name string, owner string) (bool, error) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lk, ok := l.locks[name]
+	if !ok || lk.owner != owner || !lk.expireAt.After(now) {
+		return false, nil
+	}
+	delete(l.locks, name)
+	return true, nil
+}
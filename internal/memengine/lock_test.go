@@ -0,0 +1,38 @@
+package memengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockerExtend(t *testing.T) {
+	l := NewLocker()
+	ok, err := l.AcquireLock("test", "a", time.Second)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = l.AcquireLock("test", "a", time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.True(t, l.locks["test"].expireAt.After(time.Now().Add(time.Second)))
+}
+
+func TestLockerExpire(t *testing.T) {
+	l := NewLocker()
+	ok, err := l.AcquireLock("test", "a", time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, ok)
+	time.Sleep(5 * time.Millisecond)
+
+	ok, err = l.ReleaseLock("test", "a")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	ok, err = l.AcquireLock("test", "b", time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	l.cleanup(time.Now().Add(2 * time.Second))
+	require.Len(t, l.locks, 0)
+}
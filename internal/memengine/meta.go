@@ -0,0 +1,123 @@
+package memengine
+
+import (
+	"sync"
+	"time"
+)
+
+type metaEntry struct {
+	value    []byte
+	expireAt time.Time // zero value means no expiration.
+}
+
+func (e *metaEntry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !e.expireAt.After(now)
+}
+
+// MetaStore keeps small key-value entries of channels in process memory.
+type MetaStore struct {
+	mu       sync.Mutex
+	channels map[string]map[string]*metaEntry
+	expires  *expirer
+}
+
+// NewMetaStore creates MetaStore.
+func NewMetaStore() *MetaStore {
+	return &MetaStore{
+		channels: make(map[string]map[string]*metaEntry),
+		expires:  newExpirer(),
+	}
+}
+
+// Run starts removing expired entries.
+func (m *MetaStore) Run() {
+	go func() {
+		for {
+			time.Sleep(time.Second)
+			m.cleanup(time.Now())
+		}
+	}()
+}
+
+func (m *MetaStore) cleanup(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expires.expired(now.Unix(), func(ch string) {
+		m.removeExpired(ch, now)
+	})
+}
+
+// removeExpired removes expired entries of channel and schedules next removal
+// if channel has entries with expiration. Lock must be held outside.
+func (m *MetaStore) removeExpired(ch string, now time.Time) {
+	entries, ok := m.channels[ch]
+	if !ok {
+		return
+	}
+	var next time.Time
+	for key, e := range entries {
+		if e.expired(now) {
+			delete(entries, key)
+			continue
+		}
+		if !e.expireAt.IsZero() && (next.IsZero() || e.expireAt.Before(next)) {
+			next = e.expireAt
+		}
+	}
+	if len(entries) == 0 {
+		delete(m.channels, ch)
+		return
+	}
+	if !next.IsZero() {
+		// Expirer works with seconds precision, round up to not remove entry early.
+		m.expires.set(ch, next.Add(time.Second-1).Unix())
+	}
+}
+
+// SetChannelMeta sets value of channel meta key. Zero ttl means that entry
+// never expires.
+func (m *MetaStore) SetChannelMeta(ch string, key string, value []byte, ttl time.Duration) error {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries, ok := m.channels[ch]
+	if !ok {
+		entries = make(map[string]*metaEntry)
+		m.channels[ch] = entries
+	}
+	e := &metaEntry{value: value}
+	if ttl > 0 {
+		e.expireAt = now.Add(ttl)
+	}
+	entries[key] = e
+	m.removeExpired(ch, now)
+	return nil
+}
+
+// ChannelMeta returns all not expired meta entries of channel.
+func (m *MetaStore) ChannelMeta(ch string) (map[string][]byte, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := m.channels[ch]
+	meta := make(map[string][]byte, len(entries))
+	for key, e := range entries {
+		if !e.expired(now) {
+			meta[key] = e.value
+		}
+	}
+	return meta, nil
+}
+
+// DeleteChannelMeta deletes channel meta key.
+func (m *MetaStore) DeleteChannelMeta(ch string, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if entries, ok := m.channels[ch]; ok {
+		delete(entries, key)
+		if len(entries) == 0 {
+			delete(m.channels, ch)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package memengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetaStore(t *testing.T) {
+	m := NewMetaStore()
+	require.NoError(t, m.SetChannelMeta("ch", "topic", []byte(`"news"`), 0))
+	require.NoError(t, m.SetChannelMeta("ch", "title", []byte(`"live"`), time.Minute))
+
+	meta, err := m.ChannelMeta("ch")
+	require.NoError(t, err)
+	require.Equal(t, map[string][]byte{"topic": []byte(`"news"`), "title": []byte(`"live"`)}, meta)
+
+	require.NoError(t, m.DeleteChannelMeta("ch", "topic"))
+	meta, err = m.ChannelMeta("ch")
+	require.NoError(t, err)
+	require.Len(t, meta, 1)
+
+	meta, err = m.ChannelMeta("other")
+	require.NoError(t, err)
+	require.Len(t, meta, 0)
+}
+
+func TestMetaStoreExpiration(t *testing.T) {
+	m := NewMetaStore()
+	require.NoError(t, m.SetChannelMeta("ch", "a", []byte(`1`), time.Millisecond))
+	require.NoError(t, m.SetChannelMeta("ch", "b", []byte(`2`), time.Minute))
+	time.Sleep(5 * time.Millisecond)
+
+	meta, err := m.ChannelMeta("ch")
+	require.NoError(t, err)
+	require.Equal(t, map[string][]byte{"b": []byte(`2`)}, meta)
+
+	m.cleanup(time.Now().Add(2 * time.Second))
+	require.Len(t, m.channels["ch"], 1)
+
+	m.cleanup(time.Now().Add(2 * time.Minute))
+	require.Len(t, m.channels, 0)
+}
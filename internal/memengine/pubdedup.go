@@ -0,0 +1,85 @@
+package memengine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/pubdedup"
+)
+
+type dedupEntry struct {
+	result   pubdedup.Result
+	expireAt time.Time
+}
+
+// PublishDedupStore keeps results of client publications with message IDs in
+// process memory.
+type PublishDedupStore struct {
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+	expires *expirer
+}
+
+// NewPublishDedupStore creates PublishDedupStore.
+func NewPublishDedupStore() *PublishDedupStore {
+	return &PublishDedupStore{
+		entries: make(map[string]*dedupEntry),
+		expires: newExpirer(),
+	}
+}
+
+// Run starts removing expired entries.
+func (s *PublishDedupStore) Run() {
+	go func() {
+		for {
+			time.Sleep(time.Second)
+			s.cleanup(time.Now())
+		}
+	}()
+}
+
+func (s *PublishDedupStore) cleanup(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expires.expired(now.Unix(), func(key string) {
+		if e, ok := s.entries[key]; ok && !e.expireAt.After(now) {
+			delete(s.entries, key)
+		}
+	})
+}
+
+func (s *PublishDedupStore) set(key string, r pubdedup.Result, now time.Time, ttl time.Duration) {
+	expireAt := now.Add(ttl)
+	s.entries[key] = &dedupEntry{result: r, expireAt: expireAt}
+	// Expirer works with seconds precision, round up to not remove entry early.
+	s.expires.set(key, expireAt.Add(time.Second-1).Unix())
+}
+
+// Reserve marks key as pending for ttl if key is unknown. Returns current
+// result and true if key is already known.
+func (s *PublishDedupStore) Reserve(key string, ttl time.Duration) (pubdedup.Result, bool, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok && e.expireAt.After(now) {
+		return e.result, true, nil
+	}
+	s.set(key, pubdedup.Result{Pending: true}, now, ttl)
+	return pubdedup.Result{}, false, nil
+}
+
+// Save sets result of publication for key for ttl.
+func (s *PublishDedupStore) Save(key string, r pubdedup.Result, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(key, r, time.Now(), ttl)
+	return nil
+}
+
+// Release removes key so publication can be retried.
+func (s *PublishDedupStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
@@ -0,0 +1,48 @@
+package memengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/pubdedup"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishDedupStore(t *testing.T) {
+	s := NewPublishDedupStore()
+	_, exists, err := s.Reserve("key", time.Minute)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	r, exists, err := s.Reserve("key", time.Minute)
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.True(t, r.Pending)
+
+	require.NoError(t, s.Save("key", pubdedup.Result{Offset: 1, Epoch: "xyz"}, time.Minute))
+	r, exists, err = s.Reserve("key", time.Minute)
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, pubdedup.Result{Offset: 1, Epoch: "xyz"}, r)
+
+	require.NoError(t, s.Release("key"))
+	_, exists, err = s.Reserve("key", time.Minute)
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestPublishDedupStoreExpiration(t *testing.T) {
+	s := NewPublishDedupStore()
+	_, _, err := s.Reserve("key", time.Second)
+	require.NoError(t, err)
+	s.cleanup(time.Now().Add(3 * time.Second))
+	require.Len(t, s.entries, 0)
+
+	_, _, err = s.Reserve("key", time.Nanosecond)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	_, exists, err := s.Reserve("key", time.Minute)
+	require.NoError(t, err)
+	require.False(t, exists)
+}
@@ -0,0 +1,59 @@
+package memengine
+
+import (
+	"sync"
+
+	"github.com/centrifugal/centrifugo/v3/internal/pushnotify"
+)
+
+// PushDeviceStore keeps push devices of users in process memory.
+type PushDeviceStore struct {
+	mu      sync.RWMutex
+	devices map[string]map[pushnotify.Device]struct{}
+}
+
+// NewPushDeviceStore creates PushDeviceStore.
+func NewPushDeviceStore() *PushDeviceStore {
+	return &PushDeviceStore{
+		devices: make(map[string]map[pushnotify.Device]struct{}),
+	}
+}
+
+// AddDevice registers device of user.
+func (s *PushDeviceStore) AddDevice(user string, d pushnotify.Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	devices, ok := s.devices[user]
+	if !ok {
+		devices = make(map[pushnotify.Device]struct{})
+		s.devices[user] = devices
+	}
+	devices[d] = struct{}{}
+	return nil
+}
+
+// RemoveDevice unregisters device of user.
+func (s *PushDeviceStore) RemoveDevice(user string, d pushnotify.Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	devices, ok := s.devices[user]
+	if !ok {
+		return nil
+	}
+	delete(devices, d)
+	if len(devices) == 0 {
+		delete(s.devices, user)
+	}
+	return nil
+}
+
+// Devices returns all registered devices of user.
+func (s *PushDeviceStore) Devices(user string) ([]pushnotify.Device, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	devices := make([]pushnotify.Device, 0, len(s.devices[user]))
+	for d := range s.devices[user] {
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
@@ -0,0 +1,25 @@
+package memengine
+
+import (
+	"testing"
+
+	"github.com/centrifugal/centrifugo/v3/internal/pushnotify"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushDeviceStore(t *testing.T) {
+	s := NewPushDeviceStore()
+	d := pushnotify.Device{Platform: pushnotify.PlatformFCM, Token: "token"}
+	require.NoError(t, s.AddDevice("user", d))
+	require.NoError(t, s.AddDevice("user", d))
+	devices, err := s.Devices("user")
+	require.NoError(t, err)
+	require.Equal(t, []pushnotify.Device{d}, devices)
+
+	require.NoError(t, s.RemoveDevice("user", d))
+	devices, err = s.Devices("user")
+	require.NoError(t, err)
+	require.Len(t, devices, 0)
+	require.NoError(t, s.RemoveDevice("user", d))
+}
@@ -0,0 +1,42 @@
+package memengine
+
+import "container/heap"
+
+type queueItem struct {
+	channel  string
+	priority int64
+	index    int
+}
+
+// priorityQueue is a min-heap of channels ordered by expiration time.
+type priorityQueue []*queueItem
+
+var _ heap.Interface = (*priorityQueue)(nil)
+
+func (pq priorityQueue) Len() int { return len(pq) }
+
+func (pq priorityQueue) Less(i, j int) bool {
+	return pq[i].priority < pq[j].priority
+}
+
+func (pq priorityQueue) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
+	pq[i].index = i
+	pq[j].index = j
+}
+
+func (pq *priorityQueue) Push(x interface{}) {
+	item := x.(*queueItem)
+	item.index = len(*pq)
+	*pq = append(*pq, item)
+}
+
+func (pq *priorityQueue) Pop() interface{} {
+	old := *pq
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*pq = old[0 : n-1]
+	return item
+}
@@ -0,0 +1,54 @@
+package memengine
+
+import (
+	"sync"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// ReadPositionStore keeps last read stream positions of users in channels in
+// process memory.
+type ReadPositionStore struct {
+	mu    sync.RWMutex
+	users map[string]map[string]centrifuge.StreamPosition
+}
+
+// NewReadPositionStore creates ReadPositionStore.
+func NewReadPositionStore() *ReadPositionStore {
+	return &ReadPositionStore{
+		users: make(map[string]map[string]centrifuge.StreamPosition),
+	}
+}
+
+// SetReadPosition sets last read position of user in channel. Position is
+// only moved forward within the same epoch, position with another epoch
+// replaces current one.
+func (s *ReadPositionStore) SetReadPosition(user string, ch string, sp centrifuge.StreamPosition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	positions, ok := s.users[user]
+	if !ok {
+		positions = make(map[string]centrifuge.StreamPosition)
+		s.users[user] = positions
+	}
+	if current, ok := positions[ch]; ok && current.Epoch == sp.Epoch && current.Offset >= sp.Offset {
+		return nil
+	}
+	positions[ch] = sp
+	return nil
+}
+
+// ReadPositions returns last read positions of user in channels. Channels
+// without position are not included into result.
+func (s *ReadPositionStore) ReadPositions(user string, channels []string) (map[string]centrifuge.StreamPosition, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make(map[string]centrifuge.StreamPosition, len(channels))
+	positions := s.users[user]
+	for _, ch := range channels {
+		if sp, ok := positions[ch]; ok {
+			result[ch] = sp
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,29 @@
+package memengine
+
+import (
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPositionStore(t *testing.T) {
+	s := NewReadPositionStore()
+	require.NoError(t, s.SetReadPosition("42", "ch1", centrifuge.StreamPosition{Offset: 5, Epoch: "a"}))
+	require.NoError(t, s.SetReadPosition("42", "ch1", centrifuge.StreamPosition{Offset: 3, Epoch: "a"}))
+	require.NoError(t, s.SetReadPosition("42", "ch2", centrifuge.StreamPosition{Offset: 7, Epoch: "b"}))
+	require.NoError(t, s.SetReadPosition("43", "ch1", centrifuge.StreamPosition{Offset: 1, Epoch: "a"}))
+
+	positions, err := s.ReadPositions("42", []string{"ch1", "ch2", "ch3"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]centrifuge.StreamPosition{
+		"ch1": {Offset: 5, Epoch: "a"},
+		"ch2": {Offset: 7, Epoch: "b"},
+	}, positions)
+
+	// Position with new epoch replaces current.
+	require.NoError(t, s.SetReadPosition("42", "ch1", centrifuge.StreamPosition{Offset: 1, Epoch: "c"}))
+	positions, err = s.ReadPositions("42", []string{"ch1"})
+	require.NoError(t, err)
+	require.Equal(t, centrifuge.StreamPosition{Offset: 1, Epoch: "c"}, positions["ch1"])
+}
@@ -0,0 +1,273 @@
+package memengine
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+var (
+	randomMu sync.Mutex
+	random   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+
+func genEpoch() string {
+	randomMu.Lock()
+	defer randomMu.Unlock()
+	b := make([]rune, 4)
+	for i := range b {
+		b[i] = letters[random.Intn(len(letters))]
+	}
+	return string(b)
+}
+
+// initialStreamCapacity is a number of slots allocated for a new stream. Stream
+// buffer grows twice until reaching history size so streams with a small number
+// of publications do not hold large buffers.
+const initialStreamCapacity = 16
+
+// stream is a non-thread safe ring buffer of publications. Publications
+// in stream always have sequential offsets so publication with offset
+// can be found without additional index. Compacted publications leave
+// empty slots in buffer.
+type stream struct {
+	buf      []*centrifuge.Publication
+	times    []int64 // times of publications in buf (Unix nanoseconds).
+	head     int     // position of the oldest publication in buf.
+	length   int     // number of publications in buf.
+	holes    int     // number of compacted slots in buf.
+	top      uint64
+	epoch    string
+	numBytes int64
+	keys     map[string]uint64 // offsets of latest publications by compaction key.
+}
+
+func newStream() *stream {
+	return &stream{
+		epoch: genEpoch(),
+	}
+}
+
+// publicationSize approximately estimates memory used by publication.
+func publicationSize(pub *centrifuge.Publication) int64 {
+	size := int64(len(pub.Data))
+	if pub.Info != nil {
+		size += int64(len(pub.Info.ClientID) + len(pub.Info.UserID) + len(pub.Info.ConnInfo) + len(pub.Info.ChanInfo))
+	}
+	return size
+}
+
+// add publication to stream keeping at most size publications. Returns new top
+// offset and a change of memory used by stream.
+func (s *stream) add(pub *centrifuge.Publication, size int) (uint64, int64) {
+	return s.addAt(pub, size, time.Now().UnixNano())
+}
+
+// addAt adds publication published at time t (Unix nanoseconds). Publication
+// times in stream never decrease even if clock moved backwards.
+func (s *stream) addAt(pub *centrifuge.Publication, size int, t int64) (uint64, int64) {
+	var delta int64
+	for s.length > 0 && s.length >= size {
+		delta -= s.evictOldest()
+	}
+	if s.length == len(s.buf) {
+		s.grow(size)
+	}
+	s.top++
+	pub.Offset = s.top
+	if s.length > 0 {
+		if last := s.times[(s.head+s.length-1)%len(s.buf)]; t < last {
+			t = last
+		}
+	}
+	s.buf[(s.head+s.length)%len(s.buf)] = pub
+	s.times[(s.head+s.length)%len(s.buf)] = t
+	s.length++
+	pubSize := publicationSize(pub)
+	s.numBytes += pubSize
+	delta += pubSize
+	return s.top, delta
+}
+
+func (s *stream) grow(size int) {
+	newCap := 2 * len(s.buf)
+	if newCap < initialStreamCapacity {
+		newCap = initialStreamCapacity
+	}
+	if newCap > size {
+		newCap = size
+	}
+	buf := make([]*centrifuge.Publication, newCap)
+	times := make([]int64, newCap)
+	for i := 0; i < s.length; i++ {
+		buf[i] = s.buf[(s.head+i)%len(s.buf)]
+		times[i] = s.times[(s.head+i)%len(s.buf)]
+	}
+	s.buf = buf
+	s.times = times
+	s.head = 0
+}
+
+// evictOldest removes the oldest publication from stream and returns
+// memory freed.
+func (s *stream) evictOldest() int64 {
+	if s.length == 0 {
+		return 0
+	}
+	pub := s.buf[s.head]
+	s.buf[s.head] = nil
+	s.head = (s.head + 1) % len(s.buf)
+	s.length--
+	if pub == nil {
+		s.holes--
+		return 0
+	}
+	size := publicationSize(pub)
+	s.numBytes -= size
+	return size
+}
+
+// clear removes all publications from stream keeping top offset and epoch.
+// Returns memory freed.
+func (s *stream) clear() int64 {
+	freed := s.numBytes
+	s.buf = nil
+	s.times = nil
+	s.head = 0
+	s.length = 0
+	s.holes = 0
+	s.numBytes = 0
+	s.keys = nil
+	return freed
+}
+
+// compact keeps publication with offset as the latest one for key and removes
+// publication previously kept for key. Returns memory freed.
+func (s *stream) compact(key string, offset uint64) int64 {
+	prev, ok := s.keys[key]
+	if ok && prev >= offset {
+		if prev == offset {
+			return 0
+		}
+		return s.remove(offset)
+	}
+	if s.keys == nil {
+		s.keys = make(map[string]uint64)
+	}
+	s.keys[key] = offset
+	if len(s.keys) > 2*s.length {
+		// Forget keys of publications already evicted from stream.
+		first := s.top - uint64(s.length) + 1
+		for k, o := range s.keys {
+			if o < first {
+				delete(s.keys, k)
+			}
+		}
+	}
+	if !ok {
+		return 0
+	}
+	return s.remove(prev)
+}
+
+// remove publication with offset from stream leaving empty slot in buffer.
+// Returns memory freed.
+func (s *stream) remove(offset uint64) int64 {
+	if s.length == 0 || offset > s.top || offset < s.top-uint64(s.length)+1 {
+		return 0
+	}
+	i := (s.head + int(offset-(s.top-uint64(s.length)+1))) % len(s.buf)
+	pub := s.buf[i]
+	if pub == nil {
+		return 0
+	}
+	s.buf[i] = nil
+	s.holes++
+	size := publicationSize(pub)
+	s.numBytes -= size
+	return size
+}
+
+// oldestOffset returns offset of the oldest publication kept in stream, zero
+// if stream is empty.
+func (s *stream) oldestOffset() uint64 {
+	for i := 0; i < s.length; i++ {
+		if s.at(i) != nil {
+			return s.top - uint64(s.length) + 1 + uint64(i)
+		}
+	}
+	return 0
+}
+
+// at returns i-th publication starting from the oldest one.
+func (s *stream) at(i int) *centrifuge.Publication {
+	return s.buf[(s.head+i)%len(s.buf)]
+}
+
+// offsetFromTime returns offset of the oldest publication in stream published
+// at or after time t (Unix nanoseconds). If there is no such publication then
+// offset next to top returned.
+func (s *stream) offsetFromTime(t int64) uint64 {
+	i := sort.Search(s.length, func(i int) bool {
+		return s.times[(s.head+i)%len(s.buf)] >= t
+	})
+	return s.top - uint64(s.length) + 1 + uint64(i)
+}
+
+// get publications from stream. If useOffset is false then publications returned
+// from stream beginning (or from stream end in reverse case). Negative limit means
+// no limit.
+func (s *stream) get(offset uint64, useOffset bool, limit int, reverse bool) []*centrifuge.Publication {
+	if s.length == 0 || limit == 0 {
+		return nil
+	}
+	if useOffset && offset >= s.top+1 {
+		return nil
+	}
+
+	first := s.top - uint64(s.length) + 1
+
+	var start int
+	if useOffset {
+		if offset < first {
+			if reverse {
+				return nil
+			}
+			start = 0
+		} else {
+			start = int(offset - first)
+		}
+	} else if reverse {
+		start = s.length - 1
+	}
+
+	var n int
+	if reverse {
+		n = start + 1
+	} else {
+		n = s.length - start
+	}
+	capacity := n
+	if limit > 0 && limit < capacity {
+		capacity = limit
+	}
+
+	pubs := make([]*centrifuge.Publication, 0, capacity)
+	for i := 0; i < n && len(pubs) < capacity; i++ {
+		var pub *centrifuge.Publication
+		if reverse {
+			pub = s.at(start - i)
+		} else {
+			pub = s.at(start + i)
+		}
+		if pub != nil {
+			pubs = append(pubs, pub)
+		}
+	}
+	return pubs
+}
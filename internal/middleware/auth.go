@@ -4,34 +4,50 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/rs/zerolog/log"
+	"github.com/centrifugal/centrifugo/v3/internal/tenant"
+
+	"github.com/centrifugal/centrifuge"
 )
 
 // APIKeyAuth middleware authorizes request using API key authorization.
 // It first tries to use Authorization header to extract API key
 // (Authorization: apikey <KEY>), then checks for api_key URL query parameter.
 // If key not found or invalid then 401 response code is returned.
-func APIKeyAuth(key string, h http.Handler) http.Handler {
+func APIKeyAuth(n *centrifuge.Node, key string, h http.Handler) http.Handler {
+	return TenantAPIKeyAuth(n, key, nil, h)
+}
+
+// TenantAPIKeyAuth works like APIKeyAuth but additionally accepts API keys of
+// tenants. Name of tenant which key was used is set to request context.
+func TenantAPIKeyAuth(n *centrifuge.Node, key string, tenants *tenant.Registry, h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if key == "" {
-			log.Error().Msg("API key is empty")
+		if key == "" && !tenants.Enabled() {
+			n.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "API key is empty", nil))
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
-		authValid := false
+		var candidates []string
 		authHeader := r.Header.Get("Authorization")
 		if authHeader != "" {
 			parts := strings.Fields(authHeader)
-			authValid = len(parts) == 2 && strings.ToLower(parts[0]) == "apikey" && parts[1] == key
+			if len(parts) == 2 && strings.ToLower(parts[0]) == "apikey" {
+				candidates = append(candidates, parts[1])
+			}
 		}
-		if !authValid && r.URL.RawQuery != "" {
+		if r.URL.RawQuery != "" {
 			// Check URL param.
-			authValid = r.URL.Query().Get("api_key") == key
+			candidates = append(candidates, r.URL.Query().Get("api_key"))
 		}
-		if !authValid {
-			w.WriteHeader(http.StatusUnauthorized)
-			return
+		for _, candidate := range candidates {
+			if key != "" && candidate == key {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if name, ok := tenants.ByAPIKey(candidate); ok {
+				h.ServeHTTP(w, r.WithContext(tenant.SetToContext(r.Context(), name)))
+				return
+			}
 		}
-		h.ServeHTTP(w, r)
+		w.WriteHeader(http.StatusUnauthorized)
 	})
 }
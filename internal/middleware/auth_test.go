@@ -5,16 +5,25 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/centrifugal/centrifugo/v3/internal/tenant"
+
+	"github.com/centrifugal/centrifuge"
 	"github.com/stretchr/testify/require"
 )
 
+func testNode(t *testing.T) *centrifuge.Node {
+	n, err := centrifuge.New(centrifuge.DefaultConfig)
+	require.NoError(t, err)
+	return n
+}
+
 func testHandler() http.Handler {
 	fn := func(rw http.ResponseWriter, req *http.Request) {}
 	return http.HandlerFunc(fn)
 }
 
 func TestAPIKeyAuthEmptyKey(t *testing.T) {
-	ts := httptest.NewServer(APIKeyAuth("", testHandler()))
+	ts := httptest.NewServer(APIKeyAuth(testNode(t), "", testHandler()))
 	defer ts.Close()
 
 	res, err := http.Get(ts.URL)
@@ -23,7 +32,7 @@ func TestAPIKeyAuthEmptyKey(t *testing.T) {
 }
 
 func TestAPIKeyAuthMissingAuthKey(t *testing.T) {
-	ts := httptest.NewServer(APIKeyAuth("test", testHandler()))
+	ts := httptest.NewServer(APIKeyAuth(testNode(t), "test", testHandler()))
 	defer ts.Close()
 
 	res, err := http.Get(ts.URL)
@@ -32,7 +41,7 @@ func TestAPIKeyAuthMissingAuthKey(t *testing.T) {
 }
 
 func TestAPIKeyAuthAuthorizationHeader(t *testing.T) {
-	ts := httptest.NewServer(APIKeyAuth("test", testHandler()))
+	ts := httptest.NewServer(APIKeyAuth(testNode(t), "test", testHandler()))
 	defer ts.Close()
 
 	req, err := http.NewRequest("POST", ts.URL, nil)
@@ -65,7 +74,7 @@ func TestAPIKeyAuthAuthorizationHeader(t *testing.T) {
 }
 
 func TestAPIKeyAuthQueryParam(t *testing.T) {
-	ts := httptest.NewServer(APIKeyAuth("test", testHandler()))
+	ts := httptest.NewServer(APIKeyAuth(testNode(t), "test", testHandler()))
 	defer ts.Close()
 
 	res, err := http.Post(ts.URL+"?api_key=t", "application/json", nil)
@@ -76,3 +85,33 @@ func TestAPIKeyAuthQueryParam(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, res.StatusCode, http.StatusOK)
 }
+
+func TestTenantAPIKeyAuth(t *testing.T) {
+	tenants, err := tenant.NewRegistry([]tenant.Tenant{
+		{Name: "app1", APIKey: "app1_key", Namespaces: []string{"app1"}},
+	}, func(string) string { return "" })
+	require.NoError(t, err)
+
+	var tenantName string
+	ts := httptest.NewServer(TenantAPIKeyAuth(testNode(t), "test", tenants, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		tenantName, _ = tenant.FromContext(req.Context())
+	})))
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"?api_key=app1_key", "application/json", nil)
+	require.NoError(t, err)
+	require.Equal(t, res.StatusCode, http.StatusOK)
+	require.Equal(t, "app1", tenantName)
+
+	req, err := http.NewRequest("POST", ts.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "apikey test")
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, res.StatusCode, http.StatusOK)
+	require.Equal(t, "", tenantName)
+
+	res, err = http.Post(ts.URL+"?api_key=app2_key", "application/json", nil)
+	require.NoError(t, err)
+	require.Equal(t, res.StatusCode, http.StatusUnauthorized)
+}
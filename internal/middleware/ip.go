@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type contextClientIPKey struct{}
+
+// GetClientIPFromContext returns client IP address from context.
+func GetClientIPFromContext(ctx context.Context) (string, bool) {
+	if val := ctx.Value(contextClientIPKey{}); val != nil {
+		ip, ok := val.(string)
+		return ip, ok
+	}
+	return "", false
+}
+
+func SetClientIPToContext(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, contextClientIPKey{}, ip)
+}
+
+// ClientIPToContext puts client IP address to request context. Address taken
+// from X-Real-IP or X-Forwarded-For headers only if request came from one of
+// trustedProxies, otherwise headers are ignored since client can set them.
+func ClientIPToContext(trustedProxies []*net.IPNet, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(SetClientIPToContext(r.Context(), clientIP(r, trustedProxies)))
+		h.ServeHTTP(w, r)
+	})
+}
+
+func clientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrustedProxy(host, trustedProxies) {
+		return host
+	}
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		// Every proxy appends address it got request from, so rightmost
+		// address not belonging to trusted proxy is a client address.
+		addresses := strings.Split(forwarded, ",")
+		for i := len(addresses) - 1; i >= 0; i-- {
+			ip := strings.TrimSpace(addresses[i])
+			if net.ParseIP(ip) == nil {
+				break
+			}
+			host = ip
+			if !isTrustedProxy(ip, trustedProxies) {
+				break
+			}
+		}
+	}
+	return host
+}
+
+func isTrustedProxy(host string, trustedProxies []*net.IPNet) bool {
+	if len(trustedProxies) == 0 {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIPToContext(t *testing.T) {
+	_, trusted, err := net.ParseCIDR("10.0.0.0/24")
+	require.NoError(t, err)
+
+	var ip string
+	h := ClientIPToContext([]*net.IPNet{trusted}, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ip, _ = GetClientIPFromContext(req.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, "10.0.0.1", ip)
+
+	req.Header.Set("X-Forwarded-For", "192.168.0.1, 192.168.0.2, 10.0.0.3")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, "192.168.0.2", ip)
+
+	req.Header.Set("X-Real-IP", "192.168.0.4")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, "192.168.0.4", ip)
+
+	// Headers of request not from trusted proxy ignored.
+	req.RemoteAddr = "192.168.0.5:1234"
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, "192.168.0.5", ip)
+}
+
+func TestClientIPToContextNoTrustedProxies(t *testing.T) {
+	var ip string
+	h := ClientIPToContext(nil, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ip, _ = GetClientIPFromContext(req.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Real-IP", "10.0.0.4")
+	req.Header.Set("X-Forwarded-For", "10.0.0.2")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, "10.0.0.1", ip)
+}
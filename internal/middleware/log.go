@@ -7,14 +7,13 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
+	"github.com/centrifugal/centrifuge"
 )
 
-// LogRequest middleware logs details of request.
-func LogRequest(h http.Handler) http.Handler {
+// LogRequest middleware logs details of request with node debug log level.
+func LogRequest(n *centrifuge.Node, h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if zerolog.GlobalLevel() <= zerolog.DebugLevel {
+		if n.LogEnabled(centrifuge.LogLevelDebug) {
 			start := time.Now()
 			lrw := &logResponseWriter{w, 0}
 			h.ServeHTTP(lrw, r)
@@ -25,7 +24,7 @@ func LogRequest(h http.Handler) http.Handler {
 					addr = r.RemoteAddr
 				}
 			}
-			log.Debug().Str("method", r.Method).Int("status", lrw.Status()).Str("path", r.URL.Path).Str("addr", addr).Str("duration", time.Since(start).String()).Msg("http request")
+			n.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "http request", map[string]interface{}{"method": r.Method, "status": lrw.Status(), "path": r.URL.Path, "addr": addr, "duration": time.Since(start).String()}))
 		} else {
 			h.ServeHTTP(w, r)
 		}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogRequest(t *testing.T) {
+	var entries []centrifuge.LogEntry
+	n, err := centrifuge.New(centrifuge.Config{
+		LogLevel: centrifuge.LogLevelDebug,
+		LogHandler: func(entry centrifuge.LogEntry) {
+			entries = append(entries, entry)
+		},
+	})
+	require.NoError(t, err)
+	entries = nil
+
+	h := LogRequest(n, http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusAccepted)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test", nil))
+	require.Len(t, entries, 1)
+	require.Equal(t, "http request", entries[0].Message)
+	require.Equal(t, http.StatusAccepted, entries[0].Fields["status"])
+	require.Equal(t, "/test", entries[0].Fields["path"])
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// TraceIDHeader is a header with trace ID of request. Trace ID taken from
+// request header if set, otherwise generated. It's sent back in response and
+// passed to proxy requests.
+const TraceIDHeader = "X-Request-Id"
+
+// maxTraceIDLength limits length of trace ID accepted from client.
+const maxTraceIDLength = 128
+
+type contextTraceIDKey struct{}
+
+// GetTraceIDFromContext returns trace ID from context.
+func GetTraceIDFromContext(ctx context.Context) (string, bool) {
+	if val := ctx.Value(contextTraceIDKey{}); val != nil {
+		traceID, ok := val.(string)
+		return traceID, ok
+	}
+	return "", false
+}
+
+func SetTraceIDToContext(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, contextTraceIDKey{}, traceID)
+}
+
+// TraceIDToContext puts trace ID to request context.
+func TraceIDToContext(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID := r.Header.Get(TraceIDHeader)
+		if !validTraceID(traceID) {
+			traceID = uuid.New().String()
+		}
+		w.Header().Set(TraceIDHeader, traceID)
+		r = r.WithContext(SetTraceIDToContext(r.Context(), traceID))
+		h.ServeHTTP(w, r)
+	})
+}
+
+func validTraceID(traceID string) bool {
+	if traceID == "" || len(traceID) > maxTraceIDLength {
+		return false
+	}
+	for i := 0; i < len(traceID); i++ {
+		if traceID[i] < 0x21 || traceID[i] > 0x7E {
+			return false
+		}
+	}
+	return true
+}
+
+// WithTraceID adds trace ID from context to log entry fields.
+func WithTraceID(ctx context.Context, fields map[string]interface{}) map[string]interface{} {
+	if ctx == nil {
+		return fields
+	}
+	if traceID, ok := GetTraceIDFromContext(ctx); ok {
+		fields["trace_id"] = traceID
+	}
+	return fields
+}
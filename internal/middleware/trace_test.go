@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceIDToContext(t *testing.T) {
+	var traceID string
+	h := TraceIDToContext(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		traceID, _ = GetTraceIDFromContext(req.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.NotEmpty(t, traceID)
+	require.Equal(t, traceID, rec.Header().Get(TraceIDHeader))
+
+	req.Header.Set(TraceIDHeader, "abc-123")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, "abc-123", traceID)
+
+	req.Header.Set(TraceIDHeader, "bad value")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	require.NotEqual(t, "bad value", traceID)
+
+	fields := WithTraceID(SetTraceIDToContext(req.Context(), "abc"), map[string]interface{}{"client": "1"})
+	require.Equal(t, "abc", fields["trace_id"])
+}
@@ -0,0 +1,117 @@
+// Package migrate keeps track of client connections of node and asks them to
+// move to other nodes before node maintenance.
+package migrate
+
+import (
+	"encoding/json"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// NoticeType is a type of message sent to clients asked to migrate.
+const NoticeType = "migrate"
+
+// Notice sent to client in async message before client disconnected. Client
+// may close connection and reconnect after ReconnectInMs itself, otherwise it
+// is disconnected by server at that moment.
+type Notice struct {
+	Type          string `json:"type"`
+	ReconnectInMs int64  `json:"reconnect_in_ms"`
+}
+
+// Registry of node client connections.
+type Registry struct {
+	mu      sync.Mutex
+	clients map[*centrifuge.Client]struct{}
+	rand    *rand.Rand
+}
+
+// NewRegistry creates empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		clients: make(map[*centrifuge.Client]struct{}),
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Add client to Registry.
+func (r *Registry) Add(c *centrifuge.Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clients[c] = struct{}{}
+}
+
+// Remove client from Registry.
+func (r *Registry) Remove(c *centrifuge.Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, c)
+}
+
+// NumClients returns number of clients in Registry.
+func (r *Registry) NumClients() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.clients)
+}
+
+// Clients returns snapshot of clients in Registry.
+func (r *Registry) Clients() []*centrifuge.Client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clients := make([]*centrifuge.Client, 0, len(r.clients))
+	for c := range r.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
+// UnsubscribeChannel unsubscribes all clients in Registry from channel.
+// Returns number of unsubscribed clients.
+func (r *Registry) UnsubscribeChannel(ch string) int {
+	var numClients int
+	for _, c := range r.Clients() {
+		if !c.IsSubscribed(ch) {
+			continue
+		}
+		if err := c.Unsubscribe(ch); err == nil {
+			numClients++
+		}
+	}
+	return numClients
+}
+
+// Migrate sends Notice to all clients and disconnects every client with
+// reconnect advice after random delay between minDelay and maxDelay, so
+// clients reconnect to other nodes gradually instead of all at once.
+// Returns number of clients asked to migrate.
+func (r *Registry) Migrate(minDelay, maxDelay time.Duration) int {
+	if maxDelay < minDelay {
+		maxDelay = minDelay
+	}
+	r.mu.Lock()
+	clients := make([]*centrifuge.Client, 0, len(r.clients))
+	delays := make([]time.Duration, 0, len(r.clients))
+	for c := range r.clients {
+		clients = append(clients, c)
+		delay := minDelay
+		if maxDelay > minDelay {
+			delay += time.Duration(r.rand.Int63n(int64(maxDelay - minDelay)))
+		}
+		delays = append(delays, delay)
+	}
+	r.mu.Unlock()
+
+	for i, c := range clients {
+		c, delay := c, delays[i]
+		data, _ := json.Marshal(Notice{Type: NoticeType, ReconnectInMs: delay.Milliseconds()})
+		_ = c.Send(data)
+		time.AfterFunc(delay, func() {
+			c.Disconnect(centrifuge.DisconnectShutdown)
+		})
+	}
+	return len(clients)
+}
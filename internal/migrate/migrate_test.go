@@ -0,0 +1,61 @@
+package migrate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+type testTransport struct {
+	*tools.TestTransport
+	closed chan *centrifuge.Disconnect
+}
+
+func (t *testTransport) Close(d *centrifuge.Disconnect) error {
+	t.closed <- d
+	return t.TestTransport.Close(d)
+}
+
+func TestRegistryMigrate(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	r := NewRegistry()
+	closed := make(chan *centrifuge.Disconnect, 3)
+	for i := 0; i < 3; i++ {
+		transport := &testTransport{TestTransport: tools.NewTestTransport(), closed: closed}
+		client, _, err := centrifuge.NewClient(context.Background(), node, transport)
+		require.NoError(t, err)
+		r.Add(client)
+	}
+	require.Equal(t, 3, r.NumClients())
+
+	require.Equal(t, 3, r.Migrate(0, 20*time.Millisecond))
+	for i := 0; i < 3; i++ {
+		select {
+		case d := <-closed:
+			require.Equal(t, centrifuge.DisconnectShutdown, d)
+		case <-time.After(time.Second):
+			require.Fail(t, "timeout waiting for disconnect")
+		}
+	}
+}
+
+func TestRegistryRemove(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	r := NewRegistry()
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	r.Add(client)
+	r.Remove(client)
+	require.Equal(t, 0, r.NumClients())
+	require.Equal(t, 0, r.Migrate(0, 0))
+}
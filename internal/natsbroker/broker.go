@@ -3,6 +3,7 @@ package natsbroker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -91,6 +92,14 @@ func (b *NatsBroker) Run(h centrifuge.BrokerEventHandler) error {
 	return nil
 }
 
+// Check returns error if connection to Nats server is not established.
+func (b *NatsBroker) Check(_ context.Context) error {
+	if b.nc == nil || !b.nc.IsConnected() {
+		return errors.New("not connected to Nats")
+	}
+	return nil
+}
+
 // Close is not implemented.
 func (b *NatsBroker) Close(_ context.Context) error {
 	return nil
@@ -0,0 +1,53 @@
+// Package nodeinfo contains data every node attaches to its periodic node
+// info control message. Other nodes use it to find out which commands node
+// understands, so nodes of different Centrifugo versions can run in one
+// cluster during rolling upgrade.
+package nodeinfo
+
+import (
+	"encoding/json"
+)
+
+// Data attached to node info.
+type Data struct {
+	// ControlVersion is a version of Centrifugo-level control commands
+	// understood by node.
+	ControlVersion uint32 `json:"control_version,omitempty"`
+	// SurveyOps are survey operations node can respond to.
+	SurveyOps []string `json:"survey_ops,omitempty"`
+	// Role of node, see noderole package.
+	Role string `json:"role,omitempty"`
+	// ConfigVersion is a version of configuration reloaded over cluster
+	// which is applied on node, zero if node uses configuration it was
+	// started with.
+	ConfigVersion uint64 `json:"config_version,omitempty"`
+}
+
+// Encode Data to JSON.
+func (d Data) Encode() []byte {
+	data, _ := json.Marshal(d)
+	return data
+}
+
+// Decode Data of node info. False returned for nodes which do not attach
+// Data to node info, i.e. nodes of older Centrifugo versions.
+func Decode(data []byte) (Data, bool) {
+	var d Data
+	if len(data) == 0 {
+		return d, false
+	}
+	if err := json.Unmarshal(data, &d); err != nil {
+		return d, false
+	}
+	return d, true
+}
+
+// HasSurveyOp reports whether node responds to survey operation.
+func (d Data) HasSurveyOp(op string) bool {
+	for _, o := range d.SurveyOps {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package nodeinfo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestData(t *testing.T) {
+	d := Data{ControlVersion: 1, SurveyOps: []string{"channels"}, ConfigVersion: 2}
+	decoded, ok := Decode(d.Encode())
+	require.True(t, ok)
+	require.Equal(t, d, decoded)
+	require.True(t, decoded.HasSurveyOp("channels"))
+	require.False(t, decoded.HasSurveyOp("channels_count"))
+
+	_, ok = Decode(nil)
+	require.False(t, ok)
+	_, ok = Decode([]byte("not json"))
+	require.False(t, ok)
+	// Unknown fields of newer versions ignored.
+	decoded, ok = Decode([]byte(`{"control_version":2,"new_field":true}`))
+	require.True(t, ok)
+	require.Equal(t, uint32(2), decoded.ControlVersion)
+}
@@ -0,0 +1,44 @@
+// Package noderole defines roles of nodes, so connection termination and
+// API processing can be scaled independently. Gateway nodes serve client
+// connections and forward publications via engine, worker nodes serve server
+// API. Nodes of all roles must share the same engine.
+package noderole
+
+import (
+	"fmt"
+)
+
+// Role of node.
+type Role string
+
+// Known roles.
+const (
+	// All serves both client connections and server API.
+	All Role = "all"
+	// Gateway serves client connections only.
+	Gateway Role = "gateway"
+	// Worker serves server API only.
+	Worker Role = "worker"
+)
+
+// Parse role, empty string means All.
+func Parse(s string) (Role, error) {
+	switch Role(s) {
+	case "", All:
+		return All, nil
+	case Gateway, Worker:
+		return Role(s), nil
+	default:
+		return "", fmt.Errorf("unknown node role: %s", s)
+	}
+}
+
+// ServesClients reports whether node accepts client connections.
+func (r Role) ServesClients() bool {
+	return r != Worker
+}
+
+// ServesAPI reports whether node serves server API.
+func (r Role) ServesAPI() bool {
+	return r != Gateway
+}
@@ -0,0 +1,28 @@
+package noderole
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	r, err := Parse("")
+	require.NoError(t, err)
+	require.Equal(t, All, r)
+	require.True(t, r.ServesClients())
+	require.True(t, r.ServesAPI())
+
+	r, err = Parse("gateway")
+	require.NoError(t, err)
+	require.True(t, r.ServesClients())
+	require.False(t, r.ServesAPI())
+
+	r, err = Parse("worker")
+	require.NoError(t, err)
+	require.False(t, r.ServesClients())
+	require.True(t, r.ServesAPI())
+
+	_, err = Parse("edge")
+	require.Error(t, err)
+}
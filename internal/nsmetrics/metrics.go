@@ -0,0 +1,36 @@
+package nsmetrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricsNamespace = "centrifugo"
+
+var (
+	operationsCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "namespace",
+		Name:      "operations_total",
+		Help:      "Number of operations with channels by channel namespace, source and operation.",
+	}, []string{"namespace", "source", "op"})
+
+	operationErrorsCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "namespace",
+		Name:      "operation_errors_total",
+		Help:      "Number of failed operations with channels by channel namespace, source and operation.",
+	}, []string{"namespace", "source", "op"})
+
+	publishedBytesCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "namespace",
+		Name:      "published_bytes_total",
+		Help:      "Size of publication data published by channel namespace and source.",
+	}, []string{"namespace", "source"})
+)
+
+func init() {
+	prometheus.MustRegister(operationsCount)
+	prometheus.MustRegister(operationErrorsCount)
+	prometheus.MustRegister(publishedBytesCount)
+}
@@ -0,0 +1,71 @@
+// Package nsmetrics counts client and API operations by channel namespace,
+// so load can be attributed to product features. To keep metric cardinality
+// bounded only namespaces from allowlist get own label value, channels of
+// other namespaces are counted under OtherLabel. Channels of top-level
+// namespace have empty label value.
+package nsmetrics
+
+// OtherLabel is a label value of namespaces not in allowlist.
+const OtherLabel = "other"
+
+// Sources of operations.
+const (
+	SourceClient = "client"
+	SourceAPI    = "api"
+)
+
+// Observer counts operations by namespace. All Observer methods can be safely
+// called on nil Observer.
+type Observer struct {
+	namespaceOf func(ch string) string
+	allowed     map[string]struct{}
+}
+
+// New creates Observer. Function namespaceOf extracts namespace name from
+// channel, allowlist is a list of namespace names labelled separately.
+func New(namespaceOf func(ch string) string, allowlist []string) *Observer {
+	allowed := make(map[string]struct{}, len(allowlist))
+	for _, ns := range allowlist {
+		allowed[ns] = struct{}{}
+	}
+	return &Observer{
+		namespaceOf: namespaceOf,
+		allowed:     allowed,
+	}
+}
+
+// Label returns namespace label value for channel.
+func (o *Observer) Label(ch string) string {
+	ns := o.namespaceOf(ch)
+	if ns == "" {
+		return ""
+	}
+	if _, ok := o.allowed[ns]; ok {
+		return ns
+	}
+	return OtherLabel
+}
+
+// Observe counts operation with channel made from source, err is a result of
+// operation.
+func (o *Observer) Observe(source string, op string, ch string, err error) {
+	if o == nil {
+		return
+	}
+	ns := o.Label(ch)
+	operationsCount.WithLabelValues(ns, source, op).Inc()
+	if err != nil {
+		operationErrorsCount.WithLabelValues(ns, source, op).Inc()
+	}
+}
+
+// ObservePublish counts publication of data to channel made from source.
+func (o *Observer) ObservePublish(source string, ch string, data []byte, err error) {
+	if o == nil {
+		return
+	}
+	o.Observe(source, "publish", ch, err)
+	if err == nil {
+		publishedBytesCount.WithLabelValues(o.Label(ch), source).Add(float64(len(data)))
+	}
+}
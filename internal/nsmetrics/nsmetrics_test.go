@@ -0,0 +1,43 @@
+package nsmetrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func namespaceOf(ch string) string {
+	if i := strings.IndexByte(ch, ':'); i >= 0 {
+		return ch[:i]
+	}
+	return ""
+}
+
+func TestObserverLabel(t *testing.T) {
+	o := New(namespaceOf, []string{"chat"})
+	require.Equal(t, "chat", o.Label("chat:1"))
+	require.Equal(t, OtherLabel, o.Label("feed:1"))
+	require.Equal(t, "", o.Label("news"))
+}
+
+func TestObserver(t *testing.T) {
+	o := New(namespaceOf, []string{"chat"})
+	ops := testutil.ToFloat64(operationsCount.WithLabelValues("chat", SourceClient, "subscribe"))
+	errs := testutil.ToFloat64(operationErrorsCount.WithLabelValues(OtherLabel, SourceAPI, "publish"))
+	bytes := testutil.ToFloat64(publishedBytesCount.WithLabelValues("chat", SourceAPI))
+
+	o.Observe(SourceClient, "subscribe", "chat:1", nil)
+	o.ObservePublish(SourceAPI, "feed:1", []byte("{}"), errors.New("boom"))
+	o.ObservePublish(SourceAPI, "chat:1", []byte("{}"), nil)
+
+	require.Equal(t, ops+1, testutil.ToFloat64(operationsCount.WithLabelValues("chat", SourceClient, "subscribe")))
+	require.Equal(t, errs+1, testutil.ToFloat64(operationErrorsCount.WithLabelValues(OtherLabel, SourceAPI, "publish")))
+	require.Equal(t, bytes+2, testutil.ToFloat64(publishedBytesCount.WithLabelValues("chat", SourceAPI)))
+
+	var nilObserver *Observer
+	nilObserver.Observe(SourceClient, "subscribe", "chat:1", nil)
+	nilObserver.ObservePublish(SourceClient, "chat:1", nil, nil)
+}
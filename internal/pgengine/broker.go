@@ -0,0 +1,503 @@
+package pgengine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/protocol"
+	"github.com/lib/pq"
+)
+
+// messageRetention is a time rows of message table kept for nodes to read
+// them after notification.
+const messageRetention = time.Minute
+
+// Listener reconnect backoff bounds.
+const (
+	listenerMinReconnectInterval = 100 * time.Millisecond
+	listenerMaxReconnectInterval = 10 * time.Second
+)
+
+// BrokerConfig of Broker.
+type BrokerConfig struct {
+	// DB to keep history streams in and to listen notifications over.
+	DB *DB
+	// HistoryMetaTTL sets a time of stream meta expiration. Stream meta
+	// contains top offset in channel and epoch value. By default stream meta
+	// does not expire.
+	HistoryMetaTTL time.Duration
+	// CleanupInterval is an interval to remove expired history and stream
+	// meta rows, DefaultCleanupInterval used if zero.
+	CleanupInterval time.Duration
+}
+
+// Broker uses PostgreSQL LISTEN/NOTIFY to implement centrifuge.Broker, with
+// history streams kept in tables.
+type Broker struct {
+	node   *centrifuge.Node
+	config BrokerConfig
+	db     *DB
+
+	listener     *pq.Listener
+	eventHandler centrifuge.BrokerEventHandler
+
+	subsMu sync.RWMutex
+	subs   map[string]struct{}
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+var _ centrifuge.Broker = (*Broker)(nil)
+
+// NewBroker creates Broker.
+func NewBroker(n *centrifuge.Node, config BrokerConfig) (*Broker, error) {
+	if config.DB == nil {
+		return nil, errors.New("no PostgreSQL DB provided in configuration")
+	}
+	return &Broker{
+		node:    n,
+		config:  config,
+		db:      config.DB,
+		subs:    make(map[string]struct{}),
+		closeCh: make(chan struct{}),
+	}, nil
+}
+
+// Run runs broker after node initialized.
+func (b *Broker) Run(h centrifuge.BrokerEventHandler) error {
+	b.eventHandler = h
+	listener := pq.NewListener(b.db.config.DSN, listenerMinReconnectInterval, listenerMaxReconnectInterval, b.handleListenerEvent)
+	for _, ch := range []string{b.db.clientChannel(), b.db.controlChannel()} {
+		if err := listener.Listen(ch); err != nil {
+			_ = listener.Close()
+			return fmt.Errorf("error listening %s: %w", ch, err)
+		}
+	}
+	b.listener = listener
+	go b.runListener()
+	go runCleanup(b.config.CleanupInterval, b.closeCh, b.cleanup, func(err error) {
+		b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error cleaning up PostgreSQL history", map[string]interface{}{"error": err.Error()}))
+	})
+	b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "PostgreSQL Broker running", map[string]interface{}{"prefix": b.db.prefix}))
+	return nil
+}
+
+func (b *Broker) handleListenerEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventDisconnected, pq.ListenerEventConnectionAttemptFailed:
+		if err != nil {
+			b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "PostgreSQL listener connection error", map[string]interface{}{"error": err.Error()}))
+		}
+	case pq.ListenerEventReconnected:
+		b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "PostgreSQL listener reconnected"))
+	}
+}
+
+func (b *Broker) runListener() {
+	for {
+		select {
+		case <-b.closeCh:
+			return
+		case n, ok := <-b.listener.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				// Listener reconnected, notifications sent meanwhile are lost.
+				// Clients recover missed publications from history.
+				continue
+			}
+			if err := b.handleNotification(n.Extra); err != nil {
+				b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error handling PostgreSQL notification", map[string]interface{}{"channel": n.Channel, "error": err.Error()}))
+			}
+		}
+	}
+}
+
+// Check returns error if listener has no connection to PostgreSQL.
+func (b *Broker) Check(_ context.Context) error {
+	if b.listener == nil {
+		return errors.New("PostgreSQL listener not running")
+	}
+	return b.listener.Ping()
+}
+
+// Close stops listener and cleanup of broker.
+func (b *Broker) Close(_ context.Context) error {
+	var err error
+	b.closeOnce.Do(func() {
+		close(b.closeCh)
+		if b.listener != nil {
+			err = b.listener.Close()
+		}
+	})
+	return err
+}
+
+func (b *Broker) handleNotification(payload string) error {
+	data, id, err := parsePayload(payload)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		data, err = b.loadMessage(id)
+		if err != nil {
+			return err
+		}
+	}
+	m, err := decodeMessage(data)
+	if err != nil {
+		return err
+	}
+	switch m.kind {
+	case messagePush:
+		return b.handlePush(m.header, m.body)
+	case messageControl:
+		if m.header != "" && m.header != b.node.ID() {
+			return nil
+		}
+		return b.eventHandler.HandleControl(m.body)
+	default:
+		return errMalformedMessage
+	}
+}
+
+func (b *Broker) loadMessage(id int64) ([]byte, error) {
+	var data []byte
+	err := b.db.db.QueryRow("SELECT data FROM "+b.db.messageTable()+" WHERE id = $1", id).Scan(&data)
+	if err != nil {
+		return nil, fmt.Errorf("error loading message %d: %w", id, err)
+	}
+	return data, nil
+}
+
+func (b *Broker) handlePush(epoch string, data []byte) error {
+	var push protocol.Push
+	if err := push.UnmarshalVT(data); err != nil {
+		return err
+	}
+	if !b.subscribed(push.Channel) {
+		return nil
+	}
+	switch push.Type {
+	case protocol.Push_PUBLICATION:
+		var pub protocol.Publication
+		if err := pub.UnmarshalVT(push.Data); err != nil {
+			return err
+		}
+		return b.eventHandler.HandlePublication(push.Channel, pubFromProto(&pub), centrifuge.StreamPosition{Offset: pub.Offset, Epoch: epoch})
+	case protocol.Push_JOIN:
+		var info protocol.ClientInfo
+		if err := info.UnmarshalVT(push.Data); err != nil {
+			return err
+		}
+		return b.eventHandler.HandleJoin(push.Channel, infoFromProto(&info))
+	case protocol.Push_LEAVE:
+		var info protocol.ClientInfo
+		if err := info.UnmarshalVT(push.Data); err != nil {
+			return err
+		}
+		return b.eventHandler.HandleLeave(push.Channel, infoFromProto(&info))
+	default:
+		return nil
+	}
+}
+
+func (b *Broker) subscribed(ch string) bool {
+	b.subsMu.RLock()
+	defer b.subsMu.RUnlock()
+	_, ok := b.subs[ch]
+	return ok
+}
+
+// Subscribe - see Broker interface description. All nodes receive
+// notifications of all channels, subscription only makes node handle ones
+// of channel.
+func (b *Broker) Subscribe(ch string) error {
+	b.subsMu.Lock()
+	defer b.subsMu.Unlock()
+	b.subs[ch] = struct{}{}
+	return nil
+}
+
+// Unsubscribe - see Broker interface description.
+func (b *Broker) Unsubscribe(ch string) error {
+	b.subsMu.Lock()
+	defer b.subsMu.Unlock()
+	delete(b.subs, ch)
+	return nil
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// notify sends message to notification channel. Message which does not fit
+// into notification payload saved to message table. When called inside
+// transaction notification delivered on commit.
+func (b *Broker) notify(ctx context.Context, q queryer, channel string, m message) error {
+	data := encodeMessage(m)
+	payload, ok := inlinePayload(data)
+	if !ok {
+		var id int64
+		err := q.QueryRowContext(ctx, "INSERT INTO "+b.db.messageTable()+" (data) VALUES ($1) RETURNING id", data).Scan(&id)
+		if err != nil {
+			return err
+		}
+		payload = refPayload(id)
+	}
+	_, err := q.ExecContext(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	return err
+}
+
+func (b *Broker) publishPush(ctx context.Context, q queryer, pushType protocol.Push_PushType, ch string, epoch string, data []byte) error {
+	push := &protocol.Push{
+		Type:    pushType,
+		Channel: ch,
+		Data:    data,
+	}
+	byteMessage, err := push.MarshalVT()
+	if err != nil {
+		return err
+	}
+	return b.notify(ctx, q, b.db.clientChannel(), message{kind: messagePush, header: epoch, body: byteMessage})
+}
+
+// Publish - see Broker interface description.
+func (b *Broker) Publish(ch string, data []byte, opts centrifuge.PublishOptions) (centrifuge.StreamPosition, error) {
+	pub := &protocol.Publication{
+		Data: data,
+		Info: infoToProto(opts.ClientInfo),
+	}
+	if opts.HistorySize <= 0 || opts.HistoryTTL <= 0 {
+		byteMessage, err := pub.MarshalVT()
+		if err != nil {
+			return centrifuge.StreamPosition{}, err
+		}
+		return centrifuge.StreamPosition{}, b.publishPush(context.Background(), b.db.db, protocol.Push_PUBLICATION, ch, "", byteMessage)
+	}
+	return b.publishWithHistory(context.Background(), ch, pub, opts)
+}
+
+// metaTTL returns history meta TTL in seconds to set expires_at of stream,
+// nil makes expires_at NULL.
+func (b *Broker) metaTTL() interface{} {
+	if b.config.HistoryMetaTTL <= 0 {
+		return nil
+	}
+	return b.config.HistoryMetaTTL.Seconds()
+}
+
+// publishWithHistory saves publication to stream and sends notification in
+// one transaction. Row lock on stream meta serializes concurrent publishes
+// to channel, so notifications are delivered in offset order.
+func (b *Broker) publishWithHistory(ctx context.Context, ch string, pub *protocol.Publication, opts centrifuge.PublishOptions) (centrifuge.StreamPosition, error) {
+	tx, err := b.db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return centrifuge.StreamPosition{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var epoch string
+	var top int64
+	var historyExpired, metaExpired bool
+	err = tx.QueryRowContext(ctx, `INSERT INTO `+b.db.streamTable()+` (channel, epoch, top_offset, history_expires_at, expires_at)
+		VALUES ($1, $2, 0, now(), NULL)
+		ON CONFLICT (channel) DO UPDATE SET channel = EXCLUDED.channel
+		RETURNING epoch, top_offset, history_expires_at < now(), COALESCE(expires_at < now(), false)`,
+		ch, genEpoch(),
+	).Scan(&epoch, &top, &historyExpired, &metaExpired)
+	if err != nil {
+		return centrifuge.StreamPosition{}, err
+	}
+	if metaExpired {
+		epoch = genEpoch()
+		top = 0
+		historyExpired = true
+	}
+	if historyExpired {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+b.db.historyTable()+" WHERE channel = $1", ch); err != nil {
+			return centrifuge.StreamPosition{}, err
+		}
+	}
+	top++
+	pub.Offset = uint64(top)
+
+	_, err = tx.ExecContext(ctx, `UPDATE `+b.db.streamTable()+` SET
+		epoch = $2,
+		top_offset = $3,
+		history_expires_at = now() + $4::float8 * interval '1 second',
+		expires_at = now() + $5::float8 * interval '1 second'
+		WHERE channel = $1`,
+		ch, epoch, top, opts.HistoryTTL.Seconds(), b.metaTTL(),
+	)
+	if err != nil {
+		return centrifuge.StreamPosition{}, err
+	}
+
+	byteMessage, err := pub.MarshalVT()
+	if err != nil {
+		return centrifuge.StreamPosition{}, err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO `+b.db.historyTable()+` (channel, pub_offset, data) VALUES ($1, $2, $3)
+		ON CONFLICT (channel, pub_offset) DO UPDATE SET data = EXCLUDED.data`,
+		ch, top, byteMessage,
+	)
+	if err != nil {
+		return centrifuge.StreamPosition{}, err
+	}
+	if top > int64(opts.HistorySize) {
+		_, err = tx.ExecContext(ctx, "DELETE FROM "+b.db.historyTable()+" WHERE channel = $1 AND pub_offset <= $2", ch, top-int64(opts.HistorySize))
+		if err != nil {
+			return centrifuge.StreamPosition{}, err
+		}
+	}
+
+	if err := b.publishPush(ctx, tx, protocol.Push_PUBLICATION, ch, epoch, byteMessage); err != nil {
+		return centrifuge.StreamPosition{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return centrifuge.StreamPosition{}, err
+	}
+	return centrifuge.StreamPosition{Offset: uint64(top), Epoch: epoch}, nil
+}
+
+// PublishJoin - see Broker interface description.
+func (b *Broker) PublishJoin(ch string, info *centrifuge.ClientInfo) error {
+	data, err := infoToProto(info).MarshalVT()
+	if err != nil {
+		return err
+	}
+	return b.publishPush(context.Background(), b.db.db, protocol.Push_JOIN, ch, "", data)
+}
+
+// PublishLeave - see Broker interface description.
+func (b *Broker) PublishLeave(ch string, info *centrifuge.ClientInfo) error {
+	data, err := infoToProto(info).MarshalVT()
+	if err != nil {
+		return err
+	}
+	return b.publishPush(context.Background(), b.db.db, protocol.Push_LEAVE, ch, "", data)
+}
+
+// PublishControl - see Broker interface description.
+func (b *Broker) PublishControl(data []byte, nodeID, _ string) error {
+	return b.notify(context.Background(), b.db.db, b.db.controlChannel(), message{kind: messageControl, header: nodeID, body: data})
+}
+
+// History - see Broker interface description. Reading history prolongs
+// stream meta and creates it for channel without stream.
+func (b *Broker) History(ch string, filter centrifuge.HistoryFilter) ([]*centrifuge.Publication, centrifuge.StreamPosition, error) {
+	ctx := context.Background()
+	var epoch string
+	var top int64
+	var historyAlive bool
+	err := b.db.db.QueryRowContext(ctx, `INSERT INTO `+b.db.streamTable()+` AS s (channel, epoch, top_offset, history_expires_at, expires_at)
+		VALUES ($1, $2, 0, now(), now() + $3::float8 * interval '1 second')
+		ON CONFLICT (channel) DO UPDATE SET
+			epoch = CASE WHEN s.expires_at < now() THEN EXCLUDED.epoch ELSE s.epoch END,
+			top_offset = CASE WHEN s.expires_at < now() THEN 0 ELSE s.top_offset END,
+			history_expires_at = CASE WHEN s.expires_at < now() THEN now() ELSE s.history_expires_at END,
+			expires_at = EXCLUDED.expires_at
+		RETURNING epoch, top_offset, history_expires_at > now()`,
+		ch, genEpoch(), b.metaTTL(),
+	).Scan(&epoch, &top, &historyAlive)
+	if err != nil {
+		return nil, centrifuge.StreamPosition{}, err
+	}
+	sp := centrifuge.StreamPosition{Offset: uint64(top), Epoch: epoch}
+	if !historyAlive {
+		return nil, sp, nil
+	}
+	query, args, ok := historyQuery(b.db.historyTable(), ch, filter, sp)
+	if !ok {
+		return nil, sp, nil
+	}
+	rows, err := b.db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, centrifuge.StreamPosition{}, err
+	}
+	defer func() { _ = rows.Close() }()
+	var pubs []*centrifuge.Publication
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, centrifuge.StreamPosition{}, err
+		}
+		var pub protocol.Publication
+		if err := pub.UnmarshalVT(data); err != nil {
+			return nil, centrifuge.StreamPosition{}, err
+		}
+		pubs = append(pubs, pubFromProto(&pub))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, centrifuge.StreamPosition{}, err
+	}
+	return pubs, sp, nil
+}
+
+// historyQuery builds query of publications matching filter. False returned
+// if no publications can match.
+func historyQuery(table string, ch string, filter centrifuge.HistoryFilter, sp centrifuge.StreamPosition) (string, []interface{}, bool) {
+	if filter.Limit == 0 || sp.Offset == 0 {
+		return "", nil, false
+	}
+	query := "SELECT data FROM " + table + " WHERE channel = $1 AND pub_offset <= $2"
+	args := []interface{}{ch, int64(sp.Offset)}
+	if since := filter.Since; since != nil {
+		if filter.Reverse {
+			query += " AND pub_offset < $3"
+		} else {
+			if since.Offset == sp.Offset && since.Epoch == sp.Epoch {
+				return "", nil, false
+			}
+			query += " AND pub_offset > $3"
+		}
+		args = append(args, int64(since.Offset))
+	}
+	if filter.Reverse {
+		query += " ORDER BY pub_offset DESC"
+	} else {
+		query += " ORDER BY pub_offset ASC"
+	}
+	if filter.Limit > 0 {
+		query += " LIMIT " + strconv.Itoa(filter.Limit)
+	}
+	return query, args, true
+}
+
+// RemoveHistory - see Broker interface description. Stream position kept.
+func (b *Broker) RemoveHistory(ch string) error {
+	_, err := b.db.db.Exec("DELETE FROM "+b.db.historyTable()+" WHERE channel = $1", ch)
+	return err
+}
+
+func (b *Broker) cleanup(ctx context.Context) error {
+	statements := []string{
+		`DELETE FROM ` + b.db.historyTable() + ` h USING ` + b.db.streamTable() + ` s
+			WHERE h.channel = s.channel AND (s.history_expires_at < now() OR s.expires_at < now())`,
+		`DELETE FROM ` + b.db.streamTable() + ` WHERE expires_at < now()`,
+		`DELETE FROM ` + b.db.messageTable() + ` WHERE created_at < now() - $1::float8 * interval '1 second'`,
+	}
+	for i, stmt := range statements {
+		var err error
+		if i == len(statements)-1 {
+			_, err = b.db.db.ExecContext(ctx, stmt, messageRetention.Seconds())
+		} else {
+			_, err = b.db.db.ExecContext(ctx, stmt)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package pgengine
+
+import (
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryQuery(t *testing.T) {
+	sp := centrifuge.StreamPosition{Offset: 10, Epoch: "abcd"}
+
+	_, _, ok := historyQuery("h", "ch", centrifuge.HistoryFilter{Limit: 0}, sp)
+	require.False(t, ok)
+	_, _, ok = historyQuery("h", "ch", centrifuge.HistoryFilter{Limit: -1}, centrifuge.StreamPosition{Epoch: "abcd"})
+	require.False(t, ok)
+	_, _, ok = historyQuery("h", "ch", centrifuge.HistoryFilter{Limit: -1, Since: &sp}, sp)
+	require.False(t, ok)
+
+	query, args, ok := historyQuery("h", "ch", centrifuge.HistoryFilter{Limit: -1}, sp)
+	require.True(t, ok)
+	require.Equal(t, "SELECT data FROM h WHERE channel = $1 AND pub_offset <= $2 ORDER BY pub_offset ASC", query)
+	require.Equal(t, []interface{}{"ch", int64(10)}, args)
+
+	query, args, ok = historyQuery("h", "ch", centrifuge.HistoryFilter{
+		Limit: 2, Since: &centrifuge.StreamPosition{Offset: 5, Epoch: "abcd"},
+	}, sp)
+	require.True(t, ok)
+	require.Equal(t, "SELECT data FROM h WHERE channel = $1 AND pub_offset <= $2 AND pub_offset > $3 ORDER BY pub_offset ASC LIMIT 2", query)
+	require.Equal(t, []interface{}{"ch", int64(10), int64(5)}, args)
+
+	query, _, ok = historyQuery("h", "ch", centrifuge.HistoryFilter{
+		Limit: -1, Reverse: true, Since: &centrifuge.StreamPosition{Offset: 5, Epoch: "abcd"},
+	}, sp)
+	require.True(t, ok)
+	require.Equal(t, "SELECT data FROM h WHERE channel = $1 AND pub_offset <= $2 AND pub_offset < $3 ORDER BY pub_offset DESC", query)
+}
+
+type testEventHandler struct {
+	pubs     []string
+	controls int
+}
+
+func (h *testEventHandler) HandlePublication(ch string, _ *centrifuge.Publication, _ centrifuge.StreamPosition) error {
+	h.pubs = append(h.pubs, ch)
+	return nil
+}
+
+func (h *testEventHandler) HandleJoin(_ string, _ *centrifuge.ClientInfo) error {
+	return nil
+}
+
+func (h *testEventHandler) HandleLeave(_ string, _ *centrifuge.ClientInfo) error {
+	return nil
+}
+
+func (h *testEventHandler) HandleControl(_ []byte) error {
+	h.controls++
+	return nil
+}
+
+func testPayload(t *testing.T, m message) string {
+	payload, ok := inlinePayload(encodeMessage(m))
+	require.True(t, ok)
+	return payload
+}
+
+func TestBrokerHandleNotification(t *testing.T) {
+	n, _ := centrifuge.New(centrifuge.DefaultConfig)
+	b, err := NewBroker(n, BrokerConfig{DB: &DB{prefix: DefaultPrefix}})
+	require.NoError(t, err)
+	h := &testEventHandler{}
+	b.eventHandler = h
+	require.NoError(t, b.Subscribe("subscribed"))
+
+	pub, err := (&protocol.Publication{Data: []byte("{}"), Offset: 1}).MarshalVT()
+	require.NoError(t, err)
+	for _, ch := range []string{"subscribed", "other"} {
+		push, err := (&protocol.Push{Type: protocol.Push_PUBLICATION, Channel: ch, Data: pub}).MarshalVT()
+		require.NoError(t, err)
+		require.NoError(t, b.handleNotification(testPayload(t, message{kind: messagePush, header: "abcd", body: push})))
+	}
+	require.Equal(t, []string{"subscribed"}, h.pubs)
+
+	for _, nodeID := range []string{"", n.ID(), "other"} {
+		require.NoError(t, b.handleNotification(testPayload(t, message{kind: messageControl, header: nodeID, body: []byte("x")})))
+	}
+	require.Equal(t, 2, h.controls)
+}
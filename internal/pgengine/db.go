@@ -0,0 +1,189 @@
+// Package pgengine contains Broker and PresenceManager on top of PostgreSQL.
+// Publications fan out to nodes over LISTEN/NOTIFY, history streams and
+// presence are kept in tables, expired rows removed by periodic cleanup.
+//
+// Every node receives notifications of all channels and drops ones of
+// channels it has no subscribers in, and every publication with history is
+// a transaction of several statements. So engine suits setups which already
+// run highly available PostgreSQL and accept lower throughput than Redis
+// engine provides.
+package pgengine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	// Register PostgreSQL driver for database/sql.
+	_ "github.com/lib/pq"
+)
+
+// DefaultPrefix is a default value for DBConfig.Prefix.
+const DefaultPrefix = "centrifugo"
+
+// DefaultCleanupInterval is a default interval of expired rows cleanup.
+const DefaultCleanupInterval = 5 * time.Second
+
+// maxPrefixLength keeps names of tables, indexes and notification channels
+// within PostgreSQL identifier length limit of 63 bytes.
+const maxPrefixLength = 40
+
+var prefixRe = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// DBConfig of PostgreSQL connection.
+type DBConfig struct {
+	// DSN is a connection string in URL or key=value format, see
+	// https://pkg.go.dev/github.com/lib/pq for supported parameters.
+	DSN string
+	// Prefix of tables and notification channels, DefaultPrefix used if
+	// empty. Must contain only lowercase letters, digits and underscores.
+	Prefix string
+	// MaxOpenConns limits number of open connections, zero means no limit.
+	MaxOpenConns int
+}
+
+// DB is a PostgreSQL connection pool shared by Broker and PresenceManager.
+type DB struct {
+	config DBConfig
+	prefix string
+	db     *sql.DB
+}
+
+// NewDB opens connection pool and creates tables of engine if they do not
+// exist yet.
+func NewDB(config DBConfig) (*DB, error) {
+	if config.DSN == "" {
+		return nil, errors.New("no PostgreSQL DSN provided in configuration")
+	}
+	prefix := config.Prefix
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	if err := validatePrefix(prefix); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("postgres", config.DSN)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	d := &DB{config: config, prefix: prefix, db: db}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := d.createSchema(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("error creating schema: %w", err)
+	}
+	return d, nil
+}
+
+func validatePrefix(prefix string) error {
+	if len(prefix) > maxPrefixLength {
+		return fmt.Errorf("PostgreSQL prefix must not be longer than %d characters", maxPrefixLength)
+	}
+	if !prefixRe.MatchString(prefix) {
+		return fmt.Errorf("malformed PostgreSQL prefix %q: only lowercase letters, digits and underscores allowed", prefix)
+	}
+	return nil
+}
+
+// Ping checks connection to PostgreSQL.
+func (d *DB) Ping(ctx context.Context) error {
+	return d.db.PingContext(ctx)
+}
+
+// Close closes connection pool.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+func (d *DB) streamTable() string {
+	return d.prefix + "_stream"
+}
+
+func (d *DB) historyTable() string {
+	return d.prefix + "_history"
+}
+
+func (d *DB) presenceTable() string {
+	return d.prefix + "_presence"
+}
+
+func (d *DB) messageTable() string {
+	return d.prefix + "_message"
+}
+
+func (d *DB) clientChannel() string {
+	return d.prefix + "_client"
+}
+
+func (d *DB) controlChannel() string {
+	return d.prefix + "_control"
+}
+
+func (d *DB) createSchema(ctx context.Context) error {
+	statements := []string{
+		// Stream meta. Publications of stream visible while history_expires_at
+		// not passed, meta itself dropped after expires_at (NULL if history
+		// meta TTL not set).
+		`CREATE TABLE IF NOT EXISTS ` + d.streamTable() + ` (
+			channel TEXT PRIMARY KEY,
+			epoch TEXT NOT NULL,
+			top_offset BIGINT NOT NULL,
+			history_expires_at TIMESTAMPTZ NOT NULL,
+			expires_at TIMESTAMPTZ
+		)`,
+		`CREATE TABLE IF NOT EXISTS ` + d.historyTable() + ` (
+			channel TEXT NOT NULL,
+			pub_offset BIGINT NOT NULL,
+			data BYTEA NOT NULL,
+			PRIMARY KEY (channel, pub_offset)
+		)`,
+		`CREATE TABLE IF NOT EXISTS ` + d.presenceTable() + ` (
+			channel TEXT NOT NULL,
+			client_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			info BYTEA NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (channel, client_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + d.presenceTable() + `_expires_at ON ` + d.presenceTable() + ` (expires_at)`,
+		// Messages too large for notification payload, notification carries
+		// id of row then.
+		`CREATE TABLE IF NOT EXISTS ` + d.messageTable() + ` (
+			id BIGSERIAL PRIMARY KEY,
+			data BYTEA NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`,
+	}
+	for _, stmt := range statements {
+		if _, err := d.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runCleanup calls fn every interval until closeCh closed.
+func runCleanup(interval time.Duration, closeCh chan struct{}, fn func(ctx context.Context) error, onError func(err error)) {
+	if interval <= 0 {
+		interval = DefaultCleanupInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closeCh:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			if err := fn(ctx); err != nil {
+				onError(err)
+			}
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package pgengine
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+// Kinds of messages sent over notifications.
+const (
+	// messagePush carries protocol.Push of client channel, header is an
+	// epoch of publication stream (empty for join, leave and publications
+	// without history).
+	messagePush byte = 'p'
+	// messageControl carries control data, header is an ID of target node
+	// (empty to deliver to all nodes).
+	messageControl byte = 'c'
+)
+
+// Notification payload is either inline message encoded to base64 or a
+// reference to row of message table.
+const (
+	payloadInline byte = 'i'
+	payloadRef    byte = 'r'
+)
+
+// maxInlinePayload is a max length of inline notification payload. NOTIFY
+// payload must be shorter than 8000 bytes by default.
+const maxInlinePayload = 7900
+
+var errMalformedMessage = errors.New("malformed message")
+
+// message sent over notifications.
+type message struct {
+	kind   byte
+	header string
+	body   []byte
+}
+
+func encodeMessage(m message) []byte {
+	buf := make([]byte, 0, 1+binary.MaxVarintLen64+len(m.header)+len(m.body))
+	buf = append(buf, m.kind)
+	buf = appendUvarint(buf, uint64(len(m.header)))
+	buf = append(buf, m.header...)
+	return append(buf, m.body...)
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func decodeMessage(data []byte) (message, error) {
+	if len(data) < 2 {
+		return message{}, errMalformedMessage
+	}
+	kind := data[0]
+	headerLen, n := binary.Uvarint(data[1:])
+	if n <= 0 || uint64(len(data)-1-n) < headerLen {
+		return message{}, errMalformedMessage
+	}
+	start := 1 + n
+	end := start + int(headerLen)
+	return message{kind: kind, header: string(data[start:end]), body: data[end:]}, nil
+}
+
+// inlinePayload returns notification payload with encoded message and true,
+// or false if message does not fit into notification.
+func inlinePayload(data []byte) (string, bool) {
+	if 1+base64.StdEncoding.EncodedLen(len(data)) > maxInlinePayload {
+		return "", false
+	}
+	return string(payloadInline) + base64.StdEncoding.EncodeToString(data), true
+}
+
+func refPayload(id int64) string {
+	return string(payloadRef) + strconv.FormatInt(id, 10)
+}
+
+// parsePayload returns message data of inline payload or id of message row.
+func parsePayload(payload string) (data []byte, id int64, err error) {
+	if len(payload) < 2 {
+		return nil, 0, errMalformedMessage
+	}
+	switch payload[0] {
+	case payloadInline:
+		data, err = base64.StdEncoding.DecodeString(payload[1:])
+		return data, 0, err
+	case payloadRef:
+		id, err = strconv.ParseInt(payload[1:], 10, 64)
+		return nil, id, err
+	default:
+		return nil, 0, errMalformedMessage
+	}
+}
@@ -0,0 +1,56 @@
+package pgengine
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageEncodeDecode(t *testing.T) {
+	for _, m := range []message{
+		{kind: messagePush, header: "abcd", body: []byte("data")},
+		{kind: messagePush, header: "", body: []byte("data")},
+		{kind: messageControl, header: "node", body: nil},
+	} {
+		decoded, err := decodeMessage(encodeMessage(m))
+		require.NoError(t, err)
+		require.Equal(t, m.kind, decoded.kind)
+		require.Equal(t, m.header, decoded.header)
+		require.True(t, bytes.Equal(m.body, decoded.body))
+	}
+}
+
+func TestDecodeMalformedMessage(t *testing.T) {
+	for _, data := range [][]byte{nil, {messagePush}, {messagePush, 10, 'a'}} {
+		_, err := decodeMessage(data)
+		require.ErrorIs(t, err, errMalformedMessage)
+	}
+}
+
+func TestPayload(t *testing.T) {
+	payload, ok := inlinePayload([]byte("data"))
+	require.True(t, ok)
+	data, _, err := parsePayload(payload)
+	require.NoError(t, err)
+	require.Equal(t, []byte("data"), data)
+
+	_, ok = inlinePayload([]byte(strings.Repeat("x", maxInlinePayload)))
+	require.False(t, ok)
+
+	data, id, err := parsePayload(refPayload(42))
+	require.NoError(t, err)
+	require.Nil(t, data)
+	require.Equal(t, int64(42), id)
+
+	_, _, err = parsePayload("x1")
+	require.ErrorIs(t, err, errMalformedMessage)
+}
+
+func TestValidatePrefix(t *testing.T) {
+	require.NoError(t, validatePrefix("centrifugo_1"))
+	require.Error(t, validatePrefix("1centrifugo"))
+	require.Error(t, validatePrefix("centrifugo; DROP TABLE x"))
+	require.Error(t, validatePrefix(strings.Repeat("a", maxPrefixLength+1)))
+}
@@ -0,0 +1,141 @@
+package pgengine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/protocol"
+)
+
+// DefaultPresenceTTL is a default value for presence TTL in PostgreSQL.
+const DefaultPresenceTTL = 60 * time.Second
+
+// PresenceManagerConfig is a config for PostgreSQL-based PresenceManager.
+type PresenceManagerConfig struct {
+	// DB to keep presence in.
+	DB *DB
+	// PresenceTTL is an interval how long to consider presence info
+	// valid after receiving presence update. This allows to automatically
+	// clean up unnecessary presence entries after TTL passed.
+	PresenceTTL time.Duration
+	// CleanupInterval is an interval to remove expired presence rows,
+	// DefaultCleanupInterval used if zero.
+	CleanupInterval time.Duration
+}
+
+var _ centrifuge.PresenceManager = (*PresenceManager)(nil)
+
+// PresenceManager uses PostgreSQL to implement centrifuge.PresenceManager
+// functionality. Expired rows are filtered out on read and removed by
+// periodic cleanup.
+type PresenceManager struct {
+	node   *centrifuge.Node
+	config PresenceManagerConfig
+	db     *DB
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+// NewPresenceManager initializes PostgreSQL-based centrifuge.PresenceManager
+// and starts cleanup of expired presence.
+func NewPresenceManager(n *centrifuge.Node, config PresenceManagerConfig) (*PresenceManager, error) {
+	if config.DB == nil {
+		return nil, errors.New("no PostgreSQL DB provided in configuration")
+	}
+	m := &PresenceManager{
+		node:    n,
+		config:  config,
+		db:      config.DB,
+		closeCh: make(chan struct{}),
+	}
+	go runCleanup(config.CleanupInterval, m.closeCh, m.cleanup, func(err error) {
+		n.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error cleaning up PostgreSQL presence", map[string]interface{}{"error": err.Error()}))
+	})
+	return m, nil
+}
+
+func (m *PresenceManager) presenceTTL() time.Duration {
+	if m.config.PresenceTTL > 0 {
+		return m.config.PresenceTTL
+	}
+	return DefaultPresenceTTL
+}
+
+// Presence - see PresenceManager interface description.
+func (m *PresenceManager) Presence(ch string) (map[string]*centrifuge.ClientInfo, error) {
+	rows, err := m.db.db.Query("SELECT info FROM "+m.db.presenceTable()+" WHERE channel = $1 AND expires_at > now()", ch)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	presence := make(map[string]*centrifuge.ClientInfo)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var info protocol.ClientInfo
+		if err := info.UnmarshalVT(data); err != nil {
+			return nil, err
+		}
+		presence[info.Client] = infoFromProto(&info)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return presence, nil
+}
+
+// PresenceStats - see PresenceManager interface description.
+func (m *PresenceManager) PresenceStats(ch string) (centrifuge.PresenceStats, error) {
+	var numClients, numUsers int
+	err := m.db.db.QueryRow(
+		"SELECT count(*), count(DISTINCT user_id) FROM "+m.db.presenceTable()+" WHERE channel = $1 AND expires_at > now()", ch,
+	).Scan(&numClients, &numUsers)
+	if err != nil {
+		return centrifuge.PresenceStats{}, err
+	}
+	return centrifuge.PresenceStats{NumClients: numClients, NumUsers: numUsers}, nil
+}
+
+// AddPresence - see PresenceManager interface description.
+func (m *PresenceManager) AddPresence(ch string, clientID string, info *centrifuge.ClientInfo) error {
+	data, err := infoToProto(info).MarshalVT()
+	if err != nil {
+		return err
+	}
+	var userID string
+	if info != nil {
+		userID = info.UserID
+	}
+	_, err = m.db.db.Exec(`INSERT INTO `+m.db.presenceTable()+` (channel, client_id, user_id, info, expires_at)
+		VALUES ($1, $2, $3, $4, now() + $5::float8 * interval '1 second')
+		ON CONFLICT (channel, client_id) DO UPDATE SET
+			user_id = EXCLUDED.user_id, info = EXCLUDED.info, expires_at = EXCLUDED.expires_at`,
+		ch, clientID, userID, data, m.presenceTTL().Seconds(),
+	)
+	return err
+}
+
+// RemovePresence - see PresenceManager interface description.
+func (m *PresenceManager) RemovePresence(ch string, clientID string) error {
+	_, err := m.db.db.Exec("DELETE FROM "+m.db.presenceTable()+" WHERE channel = $1 AND client_id = $2", ch, clientID)
+	return err
+}
+
+// Close stops cleanup of expired presence.
+func (m *PresenceManager) Close(_ context.Context) error {
+	m.closeOnce.Do(func() {
+		close(m.closeCh)
+	})
+	return nil
+}
+
+func (m *PresenceManager) cleanup(ctx context.Context) error {
+	_, err := m.db.db.ExecContext(ctx, "DELETE FROM "+m.db.presenceTable()+" WHERE expires_at < now()")
+	return err
+}
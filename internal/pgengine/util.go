@@ -0,0 +1,72 @@
+package pgengine
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/protocol"
+)
+
+var (
+	randomMu sync.Mutex
+	random   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+
+func genEpoch() string {
+	randomMu.Lock()
+	defer randomMu.Unlock()
+	b := make([]rune, 4)
+	for i := range b {
+		b[i] = letters[random.Intn(len(letters))]
+	}
+	return string(b)
+}
+
+func infoToProto(v *centrifuge.ClientInfo) *protocol.ClientInfo {
+	if v == nil {
+		return nil
+	}
+	info := &protocol.ClientInfo{
+		Client: v.ClientID,
+		User:   v.UserID,
+	}
+	if len(v.ConnInfo) > 0 {
+		info.ConnInfo = v.ConnInfo
+	}
+	if len(v.ChanInfo) > 0 {
+		info.ChanInfo = v.ChanInfo
+	}
+	return info
+}
+
+func infoFromProto(v *protocol.ClientInfo) *centrifuge.ClientInfo {
+	if v == nil {
+		return nil
+	}
+	info := &centrifuge.ClientInfo{
+		ClientID: v.GetClient(),
+		UserID:   v.GetUser(),
+	}
+	if len(v.ConnInfo) > 0 {
+		info.ConnInfo = v.ConnInfo
+	}
+	if len(v.ChanInfo) > 0 {
+		info.ChanInfo = v.ChanInfo
+	}
+	return info
+}
+
+func pubFromProto(pub *protocol.Publication) *centrifuge.Publication {
+	if pub == nil {
+		return nil
+	}
+	return &centrifuge.Publication{
+		Offset: pub.GetOffset(),
+		Data:   pub.Data,
+		Info:   infoFromProto(pub.GetInfo()),
+	}
+}
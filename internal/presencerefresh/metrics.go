@@ -0,0 +1,18 @@
+package presencerefresh
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricsNamespace = "centrifugo"
+
+var updatesSkipped = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: metricsNamespace,
+	Subsystem: "presence_refresh",
+	Name:      "skipped_updates_total",
+	Help:      "Number of unchanged presence updates not written to engine.",
+})
+
+func init() {
+	prometheus.MustRegister(updatesSkipped)
+}
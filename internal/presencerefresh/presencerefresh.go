@@ -0,0 +1,107 @@
+// Package presencerefresh reduces presence write volume of idle connections.
+// Centrifuge refreshes presence of every connection in every channel on each
+// client presence update interval driven by node, even if nothing changed.
+// Manager writes such unchanged updates to engine only once in refresh
+// interval, so presence entry lives while node keeps connection alive and
+// expires with engine presence TTL if node stops refreshing it.
+package presencerefresh
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+type entryKey struct {
+	channel string
+	client  string
+}
+
+type entry struct {
+	info      centrifuge.ClientInfo
+	writtenAt time.Time
+}
+
+// Manager wraps centrifuge.PresenceManager and skips presence updates which
+// do not change presence info until refresh interval passed since the last
+// write of entry. Engine presence TTL must be greater than refresh interval
+// plus client presence update interval, otherwise entries of idle
+// connections expire.
+type Manager struct {
+	centrifuge.PresenceManager
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[entryKey]entry
+}
+
+// New creates Manager.
+func New(pm centrifuge.PresenceManager, interval time.Duration) *Manager {
+	return &Manager{
+		PresenceManager: pm,
+		interval:        interval,
+		now:             time.Now,
+		entries:         make(map[entryKey]entry),
+	}
+}
+
+// AddPresence see centrifuge.PresenceManager.
+func (m *Manager) AddPresence(ch string, clientID string, info *centrifuge.ClientInfo) error {
+	key := entryKey{channel: ch, client: clientID}
+	now := m.now()
+	m.mu.Lock()
+	e, ok := m.entries[key]
+	if ok && now.Sub(e.writtenAt) < m.interval && sameInfo(&e.info, info) {
+		m.mu.Unlock()
+		updatesSkipped.Inc()
+		return nil
+	}
+	if !ok {
+		// Reserve entry before writing, RemovePresence called meanwhile
+		// drops reservation.
+		m.entries[key] = entry{}
+	}
+	m.mu.Unlock()
+
+	err := m.PresenceManager.AddPresence(ch, clientID, info)
+
+	m.mu.Lock()
+	e, ok = m.entries[key]
+	if !ok {
+		m.mu.Unlock()
+		// Presence removed while written, remove written entry from engine
+		// too so it does not live until presence TTL.
+		if err != nil {
+			return err
+		}
+		return m.PresenceManager.RemovePresence(ch, clientID)
+	}
+	if err != nil {
+		if e.writtenAt.IsZero() {
+			delete(m.entries, key)
+		}
+		m.mu.Unlock()
+		return err
+	}
+	m.entries[key] = entry{info: *info, writtenAt: now}
+	m.mu.Unlock()
+	return nil
+}
+
+// RemovePresence see centrifuge.PresenceManager.
+func (m *Manager) RemovePresence(ch string, clientID string) error {
+	m.mu.Lock()
+	delete(m.entries, entryKey{channel: ch, client: clientID})
+	m.mu.Unlock()
+	return m.PresenceManager.RemovePresence(ch, clientID)
+}
+
+func sameInfo(a *centrifuge.ClientInfo, b *centrifuge.ClientInfo) bool {
+	return a.ClientID == b.ClientID &&
+		a.UserID == b.UserID &&
+		bytes.Equal(a.ConnInfo, b.ConnInfo) &&
+		bytes.Equal(a.ChanInfo, b.ChanInfo)
+}
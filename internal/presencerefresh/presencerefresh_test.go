@@ -0,0 +1,102 @@
+package presencerefresh
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+type countingPresenceManager struct {
+	centrifuge.PresenceManager
+	adds int
+}
+
+func (m *countingPresenceManager) AddPresence(string, string, *centrifuge.ClientInfo) error {
+	m.adds++
+	return nil
+}
+
+func (m *countingPresenceManager) RemovePresence(string, string) error {
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	pm := &countingPresenceManager{}
+	m := New(pm, time.Minute)
+	now := time.Unix(1000, 0)
+	m.now = func() time.Time { return now }
+
+	info := &centrifuge.ClientInfo{ClientID: "client1", UserID: "42", ChanInfo: []byte(`{}`)}
+	require.NoError(t, m.AddPresence("test", "client1", info))
+	require.Equal(t, 1, pm.adds)
+
+	// Unchanged updates skipped until refresh interval passed.
+	now = now.Add(25 * time.Second)
+	require.NoError(t, m.AddPresence("test", "client1", info))
+	require.Equal(t, 1, pm.adds)
+	now = now.Add(25 * time.Second)
+	require.NoError(t, m.AddPresence("test", "client1", info))
+	require.Equal(t, 1, pm.adds)
+	now = now.Add(25 * time.Second)
+	require.NoError(t, m.AddPresence("test", "client1", info))
+	require.Equal(t, 2, pm.adds)
+
+	// Changed info written immediately.
+	require.NoError(t, m.AddPresence("test", "client1", &centrifuge.ClientInfo{ClientID: "client1", UserID: "42", ChanInfo: []byte(`{"a":1}`)}))
+	require.Equal(t, 3, pm.adds)
+
+	// Other channel has own entry.
+	require.NoError(t, m.AddPresence("other", "client1", info))
+	require.Equal(t, 4, pm.adds)
+
+	// Entry written again after removal.
+	require.NoError(t, m.RemovePresence("other", "client1"))
+	require.NoError(t, m.AddPresence("other", "client1", info))
+	require.Equal(t, 5, pm.adds)
+}
+
+type blockingPresenceManager struct {
+	centrifuge.PresenceManager
+	adding  chan struct{}
+	release chan struct{}
+
+	mu      sync.Mutex
+	removes int
+}
+
+func (m *blockingPresenceManager) AddPresence(string, string, *centrifuge.ClientInfo) error {
+	close(m.adding)
+	<-m.release
+	return nil
+}
+
+func (m *blockingPresenceManager) RemovePresence(string, string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removes++
+	return nil
+}
+
+func TestManagerRemoveDuringAdd(t *testing.T) {
+	pm := &blockingPresenceManager{adding: make(chan struct{}), release: make(chan struct{})}
+	m := New(pm, time.Minute)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- m.AddPresence("test", "client1", &centrifuge.ClientInfo{ClientID: "client1"})
+	}()
+	<-pm.adding
+	require.NoError(t, m.RemovePresence("test", "client1"))
+	close(pm.release)
+	require.NoError(t, <-errCh)
+
+	m.mu.Lock()
+	require.Len(t, m.entries, 0, "removed entry must not be stored")
+	m.mu.Unlock()
+	pm.mu.Lock()
+	require.Equal(t, 2, pm.removes, "entry written after removal must be removed from engine")
+	pm.mu.Unlock()
+}
@@ -0,0 +1,140 @@
+// Package presencestate keeps small custom state of connections (for example
+// typing status or cursor position) in channel presence.
+package presencestate
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// InfoKey is a key of connection state in channel info JSON object of
+// presence entry.
+const InfoKey = "state"
+
+// MaxStateSize is a maximum size of connection state in bytes.
+const MaxStateSize = 1024
+
+var (
+	// ErrNotFound returned when connection has no presence in channel on
+	// this node.
+	ErrNotFound = errors.New("connection presence not found")
+	// ErrIncompatibleInfo returned when channel info of connection is not a
+	// JSON object, so state can't be added to it.
+	ErrIncompatibleInfo = errors.New("channel info is not JSON object")
+)
+
+type entryKey struct {
+	channel string
+	client  string
+}
+
+type entry struct {
+	info  *centrifuge.ClientInfo
+	state []byte
+}
+
+// Manager wraps centrifuge.PresenceManager and adds state of connection to
+// channel info of every presence update, so state survives periodic presence
+// updates made by Centrifuge. Presence info of connections in channels with
+// state enabled is kept in memory of node the connection belongs to.
+type Manager struct {
+	centrifuge.PresenceManager
+	enabled func(ch string) bool
+
+	mu      sync.Mutex
+	entries map[entryKey]*entry
+}
+
+// New creates Manager. Connection state only kept for channels for which
+// enabled returns true.
+func New(pm centrifuge.PresenceManager, enabled func(ch string) bool) *Manager {
+	return &Manager{
+		PresenceManager: pm,
+		enabled:         enabled,
+		entries:         make(map[entryKey]*entry),
+	}
+}
+
+// AddPresence see centrifuge.PresenceManager.
+func (m *Manager) AddPresence(ch string, clientID string, info *centrifuge.ClientInfo) error {
+	if m.enabled(ch) {
+		key := entryKey{channel: ch, client: clientID}
+		m.mu.Lock()
+		e, ok := m.entries[key]
+		if !ok {
+			e = &entry{}
+			m.entries[key] = e
+		}
+		e.info = info
+		state := e.state
+		m.mu.Unlock()
+		if state != nil {
+			if withState, err := infoWithState(info, state); err == nil {
+				info = withState
+			}
+		}
+	}
+	return m.PresenceManager.AddPresence(ch, clientID, info)
+}
+
+// RemovePresence see centrifuge.PresenceManager.
+func (m *Manager) RemovePresence(ch string, clientID string) error {
+	m.mu.Lock()
+	delete(m.entries, entryKey{channel: ch, client: clientID})
+	m.mu.Unlock()
+	return m.PresenceManager.RemovePresence(ch, clientID)
+}
+
+// SetState sets state of connection in channel presence. Connection must
+// have presence in channel on this node. Empty or null state removes state.
+func (m *Manager) SetState(ch string, clientID string, state []byte) error {
+	if len(state) == 0 || string(state) == "null" {
+		state = nil
+	}
+	key := entryKey{channel: ch, client: clientID}
+	m.mu.Lock()
+	e, ok := m.entries[key]
+	if !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	info, err := infoWithState(e.info, state)
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	e.state = state
+	m.mu.Unlock()
+	return m.PresenceManager.AddPresence(ch, clientID, info)
+}
+
+func infoWithState(info *centrifuge.ClientInfo, state []byte) (*centrifuge.ClientInfo, error) {
+	fields := map[string]json.RawMessage{}
+	if len(info.ChanInfo) > 0 {
+		if err := json.Unmarshal(info.ChanInfo, &fields); err != nil || fields == nil {
+			return nil, ErrIncompatibleInfo
+		}
+	}
+	if state != nil {
+		fields[InfoKey] = state
+	} else {
+		delete(fields, InfoKey)
+	}
+	var chanInfo []byte
+	if len(fields) > 0 {
+		var err error
+		chanInfo, err = json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &centrifuge.ClientInfo{
+		ClientID: info.ClientID,
+		UserID:   info.UserID,
+		ConnInfo: info.ConnInfo,
+		ChanInfo: chanInfo,
+	}, nil
+}
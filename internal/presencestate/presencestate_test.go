@@ -0,0 +1,63 @@
+package presencestate
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManager(t *testing.T) *Manager {
+	node := tools.NodeWithMemoryEngine()
+	t.Cleanup(func() { _ = node.Shutdown(context.Background()) })
+	pm, err := centrifuge.NewMemoryPresenceManager(node, centrifuge.MemoryPresenceManagerConfig{})
+	require.NoError(t, err)
+	return New(pm, func(ch string) bool {
+		return strings.HasPrefix(ch, "state:")
+	})
+}
+
+func TestManagerSetState(t *testing.T) {
+	m := newTestManager(t)
+
+	require.Equal(t, ErrNotFound, m.SetState("state:test", "client1", []byte(`"typing"`)))
+
+	info := &centrifuge.ClientInfo{ClientID: "client1", UserID: "42", ChanInfo: []byte(`{"color":"red"}`)}
+	require.NoError(t, m.AddPresence("state:test", "client1", info))
+	require.NoError(t, m.SetState("state:test", "client1", []byte(`"typing"`)))
+
+	presence, err := m.Presence("state:test")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"color":"red","state":"typing"}`, string(presence["client1"].ChanInfo))
+
+	// Periodic presence update keeps state.
+	require.NoError(t, m.AddPresence("state:test", "client1", info))
+	presence, err = m.Presence("state:test")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"color":"red","state":"typing"}`, string(presence["client1"].ChanInfo))
+
+	require.NoError(t, m.SetState("state:test", "client1", nil))
+	presence, err = m.Presence("state:test")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"color":"red"}`, string(presence["client1"].ChanInfo))
+
+	require.NoError(t, m.RemovePresence("state:test", "client1"))
+	require.Equal(t, ErrNotFound, m.SetState("state:test", "client1", []byte(`"typing"`)))
+}
+
+func TestManagerSetStateIncompatibleInfo(t *testing.T) {
+	m := newTestManager(t)
+	info := &centrifuge.ClientInfo{ClientID: "client1", ChanInfo: []byte(`"text"`)}
+	require.NoError(t, m.AddPresence("state:test", "client1", info))
+	require.Equal(t, ErrIncompatibleInfo, m.SetState("state:test", "client1", []byte(`{}`)))
+}
+
+func TestManagerStateDisabled(t *testing.T) {
+	m := newTestManager(t)
+	require.NoError(t, m.AddPresence("test", "client1", &centrifuge.ClientInfo{ClientID: "client1"}))
+	require.Equal(t, ErrNotFound, m.SetState("test", "client1", []byte(`"typing"`)))
+}
@@ -2,8 +2,6 @@ package proxy
 
 import (
 	"context"
-	"fmt"
-	"time"
 
 	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
 
@@ -12,31 +10,21 @@ import (
 
 // GRPCConnectProxy ...
 type GRPCConnectProxy struct {
-	proxy  Proxy
-	client proxyproto.CentrifugoProxyClient
+	proxy   Proxy
+	clients *grpcClientPool
 }
 
 var _ ConnectProxy = (*GRPCConnectProxy)(nil)
 
 // NewGRPCConnectProxy ...
 func NewGRPCConnectProxy(p Proxy) (*GRPCConnectProxy, error) {
-	host, err := getGrpcHost(p.Endpoint)
+	clients, err := getGrpcClientPool(p)
 	if err != nil {
-		return nil, fmt.Errorf("error getting grpc host: %v", err)
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.Timeout))
-	defer cancel()
-	dialOpts, err := getDialOpts(p)
-	if err != nil {
-		return nil, fmt.Errorf("error creating GRPC dial options: %v", err)
-	}
-	conn, err := grpc.DialContext(ctx, host, dialOpts...)
-	if err != nil {
-		return nil, fmt.Errorf("error connecting to GRPC proxy server: %v", err)
+		return nil, err
 	}
 	return &GRPCConnectProxy{
-		proxy:  p,
-		client: proxyproto.NewCentrifugoProxyClient(conn),
+		proxy:   p,
+		clients: clients,
 	}, nil
 }
 
@@ -52,7 +40,7 @@ func (p *GRPCConnectProxy) UseBase64() bool {
 
 // ProxyConnect proxies connect control to application backend.
 func (p *GRPCConnectProxy) ProxyConnect(ctx context.Context, req *proxyproto.ConnectRequest) (*proxyproto.ConnectResponse, error) {
-	ctx, cancel := context.WithTimeout(ctx, time.Duration(p.proxy.Timeout))
+	ctx, cancel := proxyContext(ctx, p.proxy)
 	defer cancel()
-	return p.client.Connect(grpcRequestContext(ctx, p.proxy), req, grpc.ForceCodec(grpcCodec))
+	return p.clients.next().Connect(grpcRequestContext(ctx, p.proxy), req, grpc.ForceCodec(grpcCodec))
 }
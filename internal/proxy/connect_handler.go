@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/centrifugal/centrifugo/v3/internal/clientcontext"
+	"github.com/centrifugal/centrifugo/v3/internal/grant"
+	"github.com/centrifugal/centrifugo/v3/internal/middleware"
 	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
 	"github.com/centrifugal/centrifugo/v3/internal/rule"
 
@@ -51,6 +53,7 @@ func (h *ConnectHandler) Handle(node *centrifuge.Node) centrifuge.ConnectingHand
 			Name:      e.Name,
 			Version:   e.Version,
 			Channels:  e.Channels,
+			Location:  clientLocation(ctx),
 		}
 		if !h.config.Proxy.UseBase64() {
 			req.Data = e.Data
@@ -70,11 +73,16 @@ func (h *ConnectHandler) Handle(node *centrifuge.Node) centrifuge.ConnectingHand
 			h.summary.Observe(duration)
 			h.histogram.Observe(duration)
 			h.errors.Inc()
-			node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error proxying connect", map[string]interface{}{"client": e.ClientID, "error": err.Error()}))
-			return centrifuge.ConnectReply{}, centrifuge.ErrorInternal
+			node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error proxying connect", middleware.WithTraceID(ctx, map[string]interface{}{"client": e.ClientID, "error": err.Error()})))
+			if !isFailOpen(h.config.Proxy) {
+				return centrifuge.ConnectReply{}, centrifuge.ErrorInternal
+			}
+			// Fail open – proceed as if proxy returned empty result.
+			connectRep = &proxyproto.ConnectResponse{}
+		} else {
+			h.summary.Observe(duration)
+			h.histogram.Observe(duration)
 		}
-		h.summary.Observe(duration)
-		h.histogram.Observe(duration)
 		if connectRep.Disconnect != nil {
 			return centrifuge.ConnectReply{}, proxyproto.DisconnectFromProto(connectRep.Disconnect)
 		}
@@ -91,7 +99,7 @@ func (h *ConnectHandler) Handle(node *centrifuge.Node) centrifuge.ConnectingHand
 		if result.B64Info != "" {
 			decodedInfo, err := base64.StdEncoding.DecodeString(result.B64Info)
 			if err != nil {
-				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding base64 info", map[string]interface{}{"client": e.ClientID, "error": err.Error()}))
+				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding base64 info", middleware.WithTraceID(ctx, map[string]interface{}{"client": e.ClientID, "error": err.Error()})))
 				return centrifuge.ConnectReply{}, centrifuge.ErrorInternal
 			}
 			info = decodedInfo
@@ -103,7 +111,7 @@ func (h *ConnectHandler) Handle(node *centrifuge.Node) centrifuge.ConnectingHand
 		if result.B64Data != "" {
 			decodedData, err := base64.StdEncoding.DecodeString(result.B64Data)
 			if err != nil {
-				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding base64 data", map[string]interface{}{"client": e.ClientID, "error": err.Error()}))
+				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding base64 data", middleware.WithTraceID(ctx, map[string]interface{}{"client": e.ClientID, "error": err.Error()})))
 				return centrifuge.ConnectReply{}, centrifuge.ErrorInternal
 			}
 			data = decodedData
@@ -140,10 +148,23 @@ func (h *ConnectHandler) Handle(node *centrifuge.Node) centrifuge.ConnectingHand
 			reply.Subscriptions = subscriptions
 		}
 		if result.Meta != nil {
-			newCtx := clientcontext.SetContextConnectionMeta(ctx, clientcontext.ConnectionMeta{
+			ctx = clientcontext.SetContextConnectionMeta(ctx, clientcontext.ConnectionMeta{
 				Meta: json.RawMessage(result.Meta),
 			})
-			reply.Context = newCtx
+			reply.Context = ctx
+		}
+		if len(result.Roles) > 0 {
+			ctx = clientcontext.SetContextConnectionRoles(ctx, result.Roles)
+			reply.Context = ctx
+		}
+		if len(result.Grants) > 0 {
+			grants, err := grant.Compile(proxyproto.GrantsFromProto(result.Grants), h.ruleContainer.Config().ChannelNamespaceBoundary)
+			if err != nil {
+				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error compiling connection grants", middleware.WithTraceID(ctx, map[string]interface{}{"client": e.ClientID, "error": err.Error()})))
+				return centrifuge.ConnectReply{}, centrifuge.ErrorInternal
+			}
+			ctx = grant.SetToContext(ctx, grants)
+			reply.Context = ctx
 		}
 
 		return reply, nil
@@ -10,6 +10,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/centrifugal/centrifugo/v3/internal/grant"
+	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
 	"github.com/centrifugal/centrifugo/v3/internal/rule"
 	"github.com/centrifugal/centrifugo/v3/internal/tools"
 
@@ -289,6 +291,38 @@ func TestHandleConnectWithSubscription(t *testing.T) {
 
 }
 
+func TestHandleConnectWithGrants(t *testing.T) {
+	opts := proxyGRPCTestServerOptions{
+		User: "56",
+		Grants: []*proxyproto.ChannelGrant{
+			{Channel: "chat:*", Override: &proxyproto.SubscribeOptionOverride{Recover: &proxyproto.BoolValue{Value: true}}},
+		},
+	}
+	grpcTestCase := newConnHandleGRPCTestCase(context.Background(), newProxyGRPCTestServer("grants", opts))
+	defer grpcTestCase.Teardown()
+
+	httpTestCase := newConnHandleHTTPTestCase(context.Background(), "/proxy")
+	httpTestCase.Mux.HandleFunc("/proxy", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(`{"result": {"user": "56", "grants": [{"channel": "chat:*", "override": {"recover": {"value": true}}}]}}`))
+	})
+	defer httpTestCase.Teardown()
+
+	cases := newConnHandleTestCases(httpTestCase, grpcTestCase)
+	for _, c := range cases {
+		reply, err := c.invokeHandle(context.Background())
+		require.NoError(t, err, c.protocol)
+		grants, ok := grant.FromContext(reply.Context)
+		require.True(t, ok, c.protocol)
+		g, ok := grants.Match("chat:index")
+		require.True(t, ok, c.protocol)
+		require.NotNil(t, g.Override.Recover, c.protocol)
+		require.True(t, g.Override.Recover.Value, c.protocol)
+		require.Nil(t, g.Override.Presence, c.protocol)
+		_, ok = grants.Match("news:index")
+		require.False(t, ok, c.protocol)
+	}
+}
+
 func TestHandleConnectWithSubscriptionError(t *testing.T) {
 	opts := proxyGRPCTestServerOptions{
 		User:     "56",
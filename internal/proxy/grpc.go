@@ -2,10 +2,17 @@ package proxy
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/centrifugal/centrifugo/v3/internal/middleware"
 	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
@@ -46,6 +53,39 @@ func getGrpcHost(endpoint string) (string, error) {
 	return host, nil
 }
 
+// getGrpcTLSConfig returns TLS config to connect to GRPC proxy server, nil
+// returned if TLS not used. GrpcCertFile sets CA certificate to verify server
+// (system roots used if not set), client certificate is sent to server for
+// mutual TLS if GrpcClientCertFile and GrpcClientKeyFile set.
+func getGrpcTLSConfig(p Proxy) (*tls.Config, error) {
+	useTLS := p.GrpcTLS || p.GrpcCertFile != "" || p.GrpcClientCertFile != ""
+	if !useTLS {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		ServerName: p.GrpcServerName,
+	}
+	if p.GrpcCertFile != "" {
+		caCert, err := ioutil.ReadFile(p.GrpcCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA certificate: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("failed to append CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if p.GrpcClientCertFile != "" || p.GrpcClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(p.GrpcClientCertFile, p.GrpcClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
 func getDialOpts(p Proxy) ([]grpc.DialOption, error) {
 	var dialOpts []grpc.DialOption
 	if p.GrpcCredentialsKey != "" {
@@ -54,12 +94,12 @@ func getDialOpts(p Proxy) ([]grpc.DialOption, error) {
 			value: p.GrpcCredentialsValue,
 		}))
 	}
-	if p.GrpcCertFile != "" {
-		cred, err := credentials.NewClientTLSFromFile(p.GrpcCertFile, "")
-		if err != nil {
-			return nil, fmt.Errorf("failed to create TLS credentials %v", err)
-		}
-		dialOpts = append(dialOpts, grpc.WithTransportCredentials(cred))
+	tlsConfig, err := getGrpcTLSConfig(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TLS credentials %v", err)
+	}
+	if tlsConfig != nil {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
 	} else {
 		dialOpts = append(dialOpts, grpc.WithInsecure())
 	}
@@ -72,6 +112,77 @@ func getDialOpts(p Proxy) ([]grpc.DialOption, error) {
 	return dialOpts, nil
 }
 
+// grpcClientPool is a pool of GRPC connections to proxy server. Requests
+// are spread over connections in round-robin manner.
+type grpcClientPool struct {
+	counter uint64
+	clients []proxyproto.CentrifugoProxyClient
+}
+
+func (p *grpcClientPool) next() proxyproto.CentrifugoProxyClient {
+	if len(p.clients) == 1 {
+		return p.clients[0]
+	}
+	return p.clients[atomic.AddUint64(&p.counter, 1)%uint64(len(p.clients))]
+}
+
+var (
+	grpcClientPoolsMu sync.Mutex
+	grpcClientPools   = map[string]*grpcClientPool{}
+)
+
+// getGrpcClientPool returns pool of connections to GRPC proxy server. Proxies
+// with the same endpoint and connection options share one pool.
+func getGrpcClientPool(p Proxy) (*grpcClientPool, error) {
+	key := fmt.Sprintf("%s|%t|%s|%s|%s|%s|%s|%s|%d", p.Endpoint, p.GrpcTLS, p.GrpcCertFile, p.GrpcClientCertFile,
+		p.GrpcClientKeyFile, p.GrpcServerName, p.GrpcCredentialsKey, p.GrpcCredentialsValue, p.GrpcPoolSize)
+	cacheable := p.testGrpcDialer == nil
+
+	grpcClientPoolsMu.Lock()
+	defer grpcClientPoolsMu.Unlock()
+	if pool, ok := grpcClientPools[key]; ok && cacheable {
+		return pool, nil
+	}
+
+	host, err := getGrpcHost(p.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("error getting grpc host: %v", err)
+	}
+	dialOpts, err := getDialOpts(p)
+	if err != nil {
+		return nil, fmt.Errorf("error creating GRPC dial options: %v", err)
+	}
+	poolSize := p.GrpcPoolSize
+	if poolSize <= 0 {
+		poolSize = 1
+	}
+	pool := &grpcClientPool{}
+	for i := 0; i < poolSize; i++ {
+		ctx, cancel := proxyContext(context.Background(), p)
+		conn, err := grpc.DialContext(ctx, host, dialOpts...)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("error connecting to GRPC proxy server: %v", err)
+		}
+		pool.clients = append(pool.clients, proxyproto.NewCentrifugoProxyClient(conn))
+	}
+	if cacheable {
+		grpcClientPools[key] = pool
+	}
+	return pool, nil
+}
+
+// proxyContext returns context of proxy request limited by proxy timeout.
+// Earlier deadline of parent context is kept. GRPC propagates deadline to
+// proxy server so backend can stop processing request which is not needed
+// anymore.
+func proxyContext(ctx context.Context, p Proxy) (context.Context, context.CancelFunc) {
+	if p.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(p.Timeout))
+}
+
 func grpcRequestContext(ctx context.Context, proxy Proxy) context.Context {
 	md := requestMetadata(ctx, proxy.HttpHeaders, proxy.GrpcMetadata)
 	return metadata.NewOutgoingContext(ctx, md)
@@ -83,6 +194,9 @@ func httpRequestHeaders(ctx context.Context, proxy Proxy) http.Header {
 
 func requestMetadata(ctx context.Context, allowedHeaders []string, allowedMetaKeys []string) metadata.MD {
 	requestMD := metadata.MD{}
+	if traceID, ok := middleware.GetTraceIDFromContext(ctx); ok {
+		requestMD.Set(middleware.TraceIDHeader, traceID)
+	}
 	if headers, ok := middleware.GetHeadersFromContext(ctx); ok {
 		for k, vv := range headers {
 			if stringInSlice(k, allowedHeaders) {
@@ -102,15 +216,19 @@ func requestMetadata(ctx context.Context, allowedHeaders []string, allowedMetaKe
 
 func requestHeaders(ctx context.Context, allowedHeaders []string, allowedMetaKeys []string) http.Header {
 	headers := http.Header{}
-	if headers, ok := middleware.GetHeadersFromContext(ctx); ok {
-		return getProxyHeader(headers, allowedHeaders)
-	}
-	headers.Set("Content-Type", "application/json")
-	md, _ := metadata.FromIncomingContext(ctx)
-	for k, vv := range md {
-		if stringInSlice(k, allowedMetaKeys) {
-			headers[k] = vv
+	if allHeaders, ok := middleware.GetHeadersFromContext(ctx); ok {
+		headers = getProxyHeader(allHeaders, allowedHeaders)
+	} else {
+		headers.Set("Content-Type", "application/json")
+		md, _ := metadata.FromIncomingContext(ctx)
+		for k, vv := range md {
+			if stringInSlice(k, allowedMetaKeys) {
+				headers[k] = vv
+			}
 		}
 	}
+	if traceID, ok := middleware.GetTraceIDFromContext(ctx); ok {
+		headers.Set(middleware.TraceIDHeader, traceID)
+	}
 	return headers
 }
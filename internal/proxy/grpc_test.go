@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestGetGrpcTLSConfig(t *testing.T) {
+	tlsConfig, err := getGrpcTLSConfig(Proxy{})
+	require.NoError(t, err)
+	require.Nil(t, tlsConfig)
+
+	tlsConfig, err = getGrpcTLSConfig(Proxy{GrpcTLS: true, GrpcServerName: "example.com"})
+	require.NoError(t, err)
+	require.NotNil(t, tlsConfig)
+	require.Equal(t, "example.com", tlsConfig.ServerName)
+	require.Nil(t, tlsConfig.RootCAs)
+
+	_, err = getGrpcTLSConfig(Proxy{GrpcCertFile: "not_existing.pem"})
+	require.Error(t, err)
+
+	_, err = getGrpcTLSConfig(Proxy{GrpcTLS: true, GrpcClientCertFile: "not_existing.pem"})
+	require.Error(t, err)
+}
+
+func TestGrpcClientPool(t *testing.T) {
+	commonProxyTestCase := tools.NewCommonGRPCProxyTestCase(context.Background(), newProxyGRPCTestServer("result", proxyGRPCTestServerOptions{}))
+	defer commonProxyTestCase.Teardown()
+
+	p := getTestGrpcProxy(commonProxyTestCase)
+	p.GrpcPoolSize = 3
+	pool, err := getGrpcClientPool(p)
+	require.NoError(t, err)
+	require.Len(t, pool.clients, 3)
+
+	used := map[proxyproto.CentrifugoProxyClient]struct{}{}
+	for i := 0; i < 3; i++ {
+		client := pool.next()
+		used[client] = struct{}{}
+		_, err := client.RPC(context.Background(), &proxyproto.RPCRequest{}, grpc.ForceCodec(grpcCodec))
+		require.NoError(t, err)
+	}
+	require.Len(t, used, 3)
+}
+
+func TestProxyContext(t *testing.T) {
+	ctx, cancel := proxyContext(context.Background(), Proxy{})
+	defer cancel()
+	_, ok := ctx.Deadline()
+	require.False(t, ok)
+
+	ctx, cancel = proxyContext(context.Background(), Proxy{Timeout: tools.Duration(time.Second)})
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	require.True(t, time.Until(deadline) <= time.Second)
+
+	// Earlier deadline of parent context kept.
+	parent, parentCancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer parentCancel()
+	parentDeadline, _ := parent.Deadline()
+	ctx, cancel = proxyContext(parent, Proxy{Timeout: tools.Duration(time.Second)})
+	defer cancel()
+	deadline, _ = ctx.Deadline()
+	require.Equal(t, parentDeadline, deadline)
+}
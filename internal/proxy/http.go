@@ -30,8 +30,9 @@ type HTTPCaller interface {
 }
 
 type httpCaller struct {
-	Endpoint   string
-	HTTPClient *http.Client
+	Endpoint     string
+	HTTPClient   *http.Client
+	signatureKey []byte
 }
 
 // NewHTTPCaller creates new HTTPCaller.
@@ -41,6 +42,15 @@ func NewHTTPCaller(httpClient *http.Client) HTTPCaller {
 	}
 }
 
+// newProxyHTTPCaller creates HTTPCaller for proxy which signs requests if
+// proxy has signature key.
+func newProxyHTTPCaller(p Proxy) HTTPCaller {
+	return &httpCaller{
+		HTTPClient:   proxyHTTPClient(time.Duration(p.Timeout)),
+		signatureKey: []byte(p.SignatureKey),
+	}
+}
+
 func proxyHTTPClient(timeout time.Duration) *http.Client {
 	return &http.Client{
 		Transport: &http.Transport{
@@ -64,6 +74,11 @@ func (c *httpCaller) CallHTTP(ctx context.Context, endpoint string, header http.
 		return nil, fmt.Errorf("error constructing HTTP request: %w", err)
 	}
 	req.Header = header
+	if len(c.signatureKey) > 0 {
+		if err := signRequest(req.Header, c.signatureKey, reqData, time.Now()); err != nil {
+			return nil, fmt.Errorf("error signing HTTP request: %w", err)
+		}
+	}
 	resp, err := c.HTTPClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("HTTP request error: %w", err)
@@ -48,6 +48,25 @@ var (
 		Name:      "errors",
 		Help:      "Granular proxy call error count.",
 	}, []string{"type", "name"})
+
+	endpointRequestsCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "proxy_endpoint",
+		Name:      "requests_total",
+		Help:      "Number of calls to proxy endpoint with retries and circuit breaker by result: ok, error or rejected by circuit breaker.",
+	}, []string{"endpoint", "result"})
+	endpointRetriesCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "proxy_endpoint",
+		Name:      "retries_total",
+		Help:      "Number of retried calls to proxy endpoint.",
+	}, []string{"endpoint"})
+	endpointCircuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "proxy_endpoint",
+		Name:      "circuit_state",
+		Help:      "State of proxy circuit breaker: 0 – closed, 1 – open, 2 – half-open.",
+	}, []string{"type", "name", "endpoint"})
 )
 
 func init() {
@@ -57,4 +76,7 @@ func init() {
 	prometheus.MustRegister(granularProxyCallDurationSummary)
 	prometheus.MustRegister(granularProxyCallDurationHistogram)
 	prometheus.MustRegister(granularProxyCallErrorCount)
+	prometheus.MustRegister(endpointRequestsCount)
+	prometheus.MustRegister(endpointRetriesCount)
+	prometheus.MustRegister(endpointCircuitState)
 }
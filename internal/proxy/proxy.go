@@ -5,6 +5,8 @@ import (
 	"net"
 	"strings"
 
+	"github.com/centrifugal/centrifugo/v3/internal/geoip"
+	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
 	"github.com/centrifugal/centrifugo/v3/internal/tools"
 )
 
@@ -30,12 +32,50 @@ type Proxy struct {
 	// IncludeConnectionMeta to each proxy request (except connect where it's obtained).
 	IncludeConnectionMeta bool `mapstructure:"include_connection_meta" json:"include_connection_meta,omitempty"`
 
+	// SignatureKey turns on signing of HTTP proxy requests with HMAC-SHA256, so
+	// backend can authenticate requests and reject replays, see Sign.
+	SignatureKey string `mapstructure:"signature_key" json:"signature_key,omitempty"`
+
+	// RetryMaxAttempts is a max number of proxy call attempts. Only one attempt
+	// made by default. Retries stop when client context is done. Applied to
+	// connect, refresh and subscribe proxies only: publish and RPC calls are
+	// not idempotent and never retried.
+	RetryMaxAttempts int `mapstructure:"retry_max_attempts" json:"retry_max_attempts,omitempty"`
+	// RetryBackoffMin is a min delay before retry, grows exponentially with jitter.
+	RetryBackoffMin tools.Duration `mapstructure:"retry_backoff_min" json:"retry_backoff_min,omitempty"`
+	// RetryBackoffMax is a max delay before retry.
+	RetryBackoffMax tools.Duration `mapstructure:"retry_backoff_max" json:"retry_backoff_max,omitempty"`
+	// CircuitBreakerFailures is a number of consecutive failed calls to endpoint
+	// after which calls rejected without reaching endpoint. Zero turns off
+	// circuit breaker. Every proxy has own circuit breaker.
+	CircuitBreakerFailures int `mapstructure:"circuit_breaker_failures" json:"circuit_breaker_failures,omitempty"`
+	// CircuitBreakerOpenTimeout is a time after which opened circuit breaker
+	// lets one probe call through.
+	CircuitBreakerOpenTimeout tools.Duration `mapstructure:"circuit_breaker_open_timeout" json:"circuit_breaker_open_timeout,omitempty"`
+	// FailOpen makes event allowed with default behaviour when proxy call
+	// failed, instead of returning internal error to client.
+	FailOpen bool `mapstructure:"fail_open" json:"fail_open,omitempty"`
+
 	// GrpcCertFile is a path to GRPC cert file on disk.
 	GrpcCertFile string `mapstructure:"grpc_cert_file" json:"grpc_cert_file,omitempty"`
 	// GrpcCredentialsKey is a custom key to add into per-RPC credentials.
 	GrpcCredentialsKey string `mapstructure:"grpc_credentials_key" json:"grpc_credentials_key,omitempty"`
 	// GrpcCredentialsValue is a custom value for GrpcCredentialsKey.
 	GrpcCredentialsValue string `mapstructure:"grpc_credentials_value" json:"grpc_credentials_value,omitempty"`
+	// GrpcTLS turns on TLS for GRPC connection. Server certificate verified
+	// using system roots unless GrpcCertFile set. Setting GrpcCertFile or
+	// GrpcClientCertFile also turns on TLS.
+	GrpcTLS bool `mapstructure:"grpc_tls" json:"grpc_tls,omitempty"`
+	// GrpcClientCertFile is a path to client certificate for mutual TLS.
+	GrpcClientCertFile string `mapstructure:"grpc_client_cert_file" json:"grpc_client_cert_file,omitempty"`
+	// GrpcClientKeyFile is a path to client certificate key for mutual TLS.
+	GrpcClientKeyFile string `mapstructure:"grpc_client_key_file" json:"grpc_client_key_file,omitempty"`
+	// GrpcServerName overrides server name used to verify server certificate.
+	GrpcServerName string `mapstructure:"grpc_server_name" json:"grpc_server_name,omitempty"`
+	// GrpcPoolSize is a number of GRPC connections to proxy server, requests
+	// spread over connections in round-robin manner. One connection used by
+	// default. Proxies with the same endpoint and GRPC options share connections.
+	GrpcPoolSize int `mapstructure:"grpc_pool_size" json:"grpc_pool_size,omitempty"`
 
 	testGrpcDialer func(context.Context, string) (net.Conn, error)
 }
@@ -52,36 +92,81 @@ func isHttpEndpoint(endpoint string) bool {
 }
 
 func GetConnectProxy(p Proxy) (ConnectProxy, error) {
+	var cp ConnectProxy
+	var err error
 	if isHttpEndpoint(p.Endpoint) {
-		return NewHTTPConnectProxy(p)
+		cp, err = NewHTTPConnectProxy(p)
+	} else {
+		cp, err = NewGRPCConnectProxy(p)
+	}
+	if err != nil || !needGuard(proxyTypeConnect, p) {
+		return cp, err
 	}
-	return NewGRPCConnectProxy(p)
+	return &guardedConnectProxy{cp, newGuard(proxyTypeConnect, p)}, nil
 }
 
 func GetRefreshProxy(p Proxy) (RefreshProxy, error) {
+	var rp RefreshProxy
+	var err error
 	if isHttpEndpoint(p.Endpoint) {
-		return NewHTTPRefreshProxy(p)
+		rp, err = NewHTTPRefreshProxy(p)
+	} else {
+		rp, err = NewGRPCRefreshProxy(p)
 	}
-	return NewGRPCRefreshProxy(p)
+	if err != nil || !needGuard(proxyTypeRefresh, p) {
+		return rp, err
+	}
+	return &guardedRefreshProxy{rp, newGuard(proxyTypeRefresh, p)}, nil
 }
 
 func GetRpcProxy(p Proxy) (RPCProxy, error) {
+	var rp RPCProxy
+	var err error
 	if isHttpEndpoint(p.Endpoint) {
-		return NewHTTPRPCProxy(p)
+		rp, err = NewHTTPRPCProxy(p)
+	} else {
+		rp, err = NewGRPCRPCProxy(p)
+	}
+	if err != nil || !needGuard(proxyTypeRPC, p) {
+		return rp, err
 	}
-	return NewGRPCRPCProxy(p)
+	return &guardedRPCProxy{rp, newGuard(proxyTypeRPC, p)}, nil
 }
 
 func GetPublishProxy(p Proxy) (PublishProxy, error) {
+	var pp PublishProxy
+	var err error
 	if isHttpEndpoint(p.Endpoint) {
-		return NewHTTPPublishProxy(p)
+		pp, err = NewHTTPPublishProxy(p)
+	} else {
+		pp, err = NewGRPCPublishProxy(p)
 	}
-	return NewGRPCPublishProxy(p)
+	if err != nil || !needGuard(proxyTypePublish, p) {
+		return pp, err
+	}
+	return &guardedPublishProxy{pp, newGuard(proxyTypePublish, p)}, nil
 }
 
 func GetSubscribeProxy(p Proxy) (SubscribeProxy, error) {
+	var sp SubscribeProxy
+	var err error
 	if isHttpEndpoint(p.Endpoint) {
-		return NewHTTPSubscribeProxy(p)
+		sp, err = NewHTTPSubscribeProxy(p)
+	} else {
+		sp, err = NewGRPCSubscribeProxy(p)
+	}
+	if err != nil || !needGuard(proxyTypeSubscribe, p) {
+		return sp, err
+	}
+	return &guardedSubscribeProxy{sp, newGuard(proxyTypeSubscribe, p)}, nil
+}
+
+// clientLocation returns location of client to pass in proxy request, nil if
+// location of client unknown.
+func clientLocation(ctx context.Context) *proxyproto.ClientLocation {
+	loc, ok := geoip.FromContext(ctx)
+	if !ok {
+		return nil
 	}
-	return NewGRPCSubscribeProxy(p)
+	return &proxyproto.ClientLocation{Country: loc.Country, Region: loc.Region}
 }
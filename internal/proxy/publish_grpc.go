@@ -2,8 +2,6 @@ package proxy
 
 import (
 	"context"
-	"fmt"
-	"time"
 
 	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
 
@@ -12,39 +10,29 @@ import (
 
 // GRPCPublishProxy ...
 type GRPCPublishProxy struct {
-	proxy  Proxy
-	client proxyproto.CentrifugoProxyClient
+	proxy   Proxy
+	clients *grpcClientPool
 }
 
 var _ PublishProxy = (*GRPCPublishProxy)(nil)
 
 // NewGRPCPublishProxy ...
 func NewGRPCPublishProxy(p Proxy) (*GRPCPublishProxy, error) {
-	host, err := getGrpcHost(p.Endpoint)
+	clients, err := getGrpcClientPool(p)
 	if err != nil {
-		return nil, fmt.Errorf("error getting grpc host: %v", err)
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.Timeout))
-	defer cancel()
-	dialOpts, err := getDialOpts(p)
-	if err != nil {
-		return nil, fmt.Errorf("error creating GRPC dial options: %v", err)
-	}
-	conn, err := grpc.DialContext(ctx, host, dialOpts...)
-	if err != nil {
-		return nil, fmt.Errorf("error connecting to GRPC proxy server: %v", err)
+		return nil, err
 	}
 	return &GRPCPublishProxy{
-		proxy:  p,
-		client: proxyproto.NewCentrifugoProxyClient(conn),
+		proxy:   p,
+		clients: clients,
 	}, nil
 }
 
 // ProxyPublish proxies Publish to application backend.
 func (p *GRPCPublishProxy) ProxyPublish(ctx context.Context, req *proxyproto.PublishRequest) (*proxyproto.PublishResponse, error) {
-	ctx, cancel := context.WithTimeout(ctx, time.Duration(p.proxy.Timeout))
+	ctx, cancel := proxyContext(ctx, p.proxy)
 	defer cancel()
-	return p.client.Publish(grpcRequestContext(ctx, p.proxy), req, grpc.ForceCodec(grpcCodec))
+	return p.clients.next().Publish(grpcRequestContext(ctx, p.proxy), req, grpc.ForceCodec(grpcCodec))
 }
 
 // Protocol ...
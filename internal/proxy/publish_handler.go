@@ -5,7 +5,10 @@ import (
 	"time"
 
 	"github.com/centrifugal/centrifugo/v3/internal/clientcontext"
+	"github.com/centrifugal/centrifugo/v3/internal/middleware"
 	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
+	"github.com/centrifugal/centrifugo/v3/internal/publisher"
+	"github.com/centrifugal/centrifugo/v3/internal/puborigin"
 	"github.com/centrifugal/centrifugo/v3/internal/rule"
 
 	"github.com/centrifugal/centrifuge"
@@ -16,6 +19,9 @@ import (
 type PublishHandlerConfig struct {
 	Proxies           map[string]PublishProxy
 	GranularProxyMode bool
+	// Publisher to wrap and publish data returned by proxy with, Publisher
+	// without ID generator, inbox and push notifications used if not set.
+	Publisher *publisher.Publisher
 }
 
 // PublishHandler ...
@@ -63,6 +69,10 @@ type PublishHandlerFunc func(*centrifuge.Client, centrifuge.PublishEvent, rule.C
 
 // Handle Publish.
 func (h *PublishHandler) Handle(node *centrifuge.Node) PublishHandlerFunc {
+	pub := h.config.Publisher
+	if pub == nil {
+		pub = publisher.New(node, nil)
+	}
 	return func(client *centrifuge.Client, e centrifuge.PublishEvent, chOpts rule.ChannelOptions) (centrifuge.PublishReply, error) {
 		started := time.Now()
 
@@ -74,7 +84,7 @@ func (h *PublishHandler) Handle(node *centrifuge.Node) PublishHandlerFunc {
 		if h.config.GranularProxyMode {
 			proxyName := chOpts.PublishProxyName
 			if proxyName == "" {
-				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "publish proxy not configured for a channel", map[string]interface{}{"channel": e.Channel}))
+				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "publish proxy not configured for a channel", middleware.WithTraceID(client.Context(), map[string]interface{}{"channel": e.Channel})))
 				return centrifuge.PublishReply{}, centrifuge.ErrorNotAvailable
 			}
 			p = h.config.Proxies[proxyName]
@@ -93,6 +103,7 @@ func (h *PublishHandler) Handle(node *centrifuge.Node) PublishHandlerFunc {
 			Protocol:  string(client.Transport().Protocol()),
 			Transport: client.Transport().Name(),
 			Encoding:  getEncoding(p.UseBase64()),
+			Location:  clientLocation(client.Context()),
 
 			User:    client.UserID(),
 			Channel: e.Channel,
@@ -120,11 +131,16 @@ func (h *PublishHandler) Handle(node *centrifuge.Node) PublishHandlerFunc {
 			summary.Observe(duration)
 			histogram.Observe(duration)
 			errors.Inc()
-			node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error proxying publish", map[string]interface{}{"error": err.Error()}))
-			return centrifuge.PublishReply{}, centrifuge.ErrorInternal
+			node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error proxying publish", middleware.WithTraceID(client.Context(), map[string]interface{}{"error": err.Error()})))
+			if !isFailOpen(p) {
+				return centrifuge.PublishReply{}, centrifuge.ErrorInternal
+			}
+			// Fail open – proceed as if proxy returned empty result.
+			publishRep = &proxyproto.PublishResponse{}
+		} else {
+			summary.Observe(duration)
+			histogram.Observe(duration)
 		}
-		summary.Observe(duration)
-		histogram.Observe(duration)
 
 		if publishRep.Disconnect != nil {
 			return centrifuge.PublishReply{}, proxyproto.DisconnectFromProto(publishRep.Disconnect)
@@ -143,7 +159,7 @@ func (h *PublishHandler) Handle(node *centrifuge.Node) PublishHandlerFunc {
 			} else if publishRep.Result.B64Data != "" {
 				decodedData, err := base64.StdEncoding.DecodeString(publishRep.Result.B64Data)
 				if err != nil {
-					node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding base64 data", map[string]interface{}{"client": client.ID(), "error": err.Error()}))
+					node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding base64 data", middleware.WithTraceID(client.Context(), map[string]interface{}{"client": client.ID(), "error": err.Error()})))
 					return centrifuge.PublishReply{}, centrifuge.ErrorInternal
 				}
 				data = decodedData
@@ -155,7 +171,16 @@ func (h *PublishHandler) Handle(node *centrifuge.Node) PublishHandlerFunc {
 			}
 		}
 
-		result, err := node.Publish(
+		var tags map[string]string
+		if publishRep.Result != nil && chOpts.PublicationTags {
+			tags = publishRep.Result.Tags
+		}
+		data, _, err = pub.Wrap(chOpts, data, puborigin.Origin{Type: puborigin.TypeProxy, User: client.UserID(), Client: client.ID()}, tags)
+		if err != nil {
+			return centrifuge.PublishReply{}, centrifuge.ErrorBadRequest
+		}
+
+		result, err := pub.Publish(
 			e.Channel, data,
 			centrifuge.WithClientInfo(e.ClientInfo),
 			centrifuge.WithHistory(historySize, time.Duration(historyTTL)),
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/centrifugal/centrifugo/v3/internal/clientcontext"
+	"github.com/centrifugal/centrifugo/v3/internal/middleware"
 	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
 
 	"github.com/centrifugal/centrifuge"
@@ -46,6 +47,7 @@ func (h *RefreshHandler) Handle(node *centrifuge.Node) RefreshHandlerFunc {
 			Protocol:  string(client.Transport().Protocol()),
 			Transport: client.Transport().Name(),
 			Encoding:  getEncoding(h.config.Proxy.UseBase64()),
+			Location:  clientLocation(client.Context()),
 
 			User: client.UserID(),
 		}
@@ -66,7 +68,7 @@ func (h *RefreshHandler) Handle(node *centrifuge.Node) RefreshHandlerFunc {
 			h.summary.Observe(duration)
 			h.histogram.Observe(duration)
 			h.errors.Inc()
-			node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error proxying refresh", map[string]interface{}{"error": err.Error()}))
+			node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error proxying refresh", middleware.WithTraceID(client.Context(), map[string]interface{}{"error": err.Error()})))
 			// In case of an error give connection one more minute to live and
 			// then try to check again. This way we gracefully handle temporary
 			// problems on application backend side.
@@ -82,7 +84,7 @@ func (h *RefreshHandler) Handle(node *centrifuge.Node) RefreshHandlerFunc {
 		credentials := refreshRep.Result
 		if credentials == nil {
 			// User will be disconnected.
-			node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "no refresh credentials found", map[string]interface{}{}))
+			node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "no refresh credentials found", middleware.WithTraceID(client.Context(), map[string]interface{}{})))
 			return centrifuge.RefreshReply{
 				Expired: true,
 			}, nil
@@ -98,7 +100,7 @@ func (h *RefreshHandler) Handle(node *centrifuge.Node) RefreshHandlerFunc {
 		if credentials.B64Info != "" {
 			decodedInfo, err := base64.StdEncoding.DecodeString(credentials.B64Info)
 			if err != nil {
-				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding base64 info", map[string]interface{}{"client": client.ID(), "error": err.Error()}))
+				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding base64 info", middleware.WithTraceID(client.Context(), map[string]interface{}{"client": client.ID(), "error": err.Error()})))
 				return centrifuge.RefreshReply{}, centrifuge.ErrorInternal
 			}
 			info = decodedInfo
@@ -2,7 +2,6 @@ package proxy
 
 import (
 	"context"
-	"time"
 
 	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
 )
@@ -26,7 +25,7 @@ var _ RefreshProxy = (*HTTPRefreshProxy)(nil)
 func NewHTTPRefreshProxy(p Proxy) (*HTTPRefreshProxy, error) {
 	return &HTTPRefreshProxy{
 		proxy:      p,
-		httpCaller: NewHTTPCaller(proxyHTTPClient(time.Duration(p.Timeout))),
+		httpCaller: newProxyHTTPCaller(p),
 	}, nil
 }
 
@@ -0,0 +1,298 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
+)
+
+// Defaults of proxy resilience options.
+const (
+	DefaultRetryBackoffMin           = 50 * time.Millisecond
+	DefaultRetryBackoffMax           = time.Second
+	DefaultCircuitBreakerOpenTimeout = 10 * time.Second
+)
+
+// ErrCircuitOpen returned instead of calling proxy endpoint while circuit
+// breaker of proxy is open.
+var ErrCircuitOpen = errors.New("proxy circuit breaker open")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// breaker is a circuit breaker of proxy. It opens after configured
+// number of consecutive failures and rejects calls until open timeout passed,
+// then lets one probe call through: success closes breaker, failure opens it
+// again.
+type breaker struct {
+	// labels of circuit state metric: proxy type, name and endpoint.
+	labels      []string
+	failures    int
+	openTimeout time.Duration
+
+	mu          sync.Mutex
+	state       circuitState
+	consecutive int
+	openedAt    time.Time
+	probing     bool
+}
+
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case circuitOpen:
+		if now.Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.setState(circuitHalfOpen)
+		b.probing = true
+		return true
+	case circuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *breaker) done(now time.Time, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if err == nil {
+		b.consecutive = 0
+		b.setState(circuitClosed)
+		return
+	}
+	b.consecutive++
+	if b.state == circuitHalfOpen || b.consecutive >= b.failures {
+		b.openedAt = now
+		b.setState(circuitOpen)
+	}
+}
+
+// setState must be called with mu held.
+func (b *breaker) setState(state circuitState) {
+	b.state = state
+	endpointCircuitState.WithLabelValues(b.labels...).Set(float64(state))
+}
+
+// newBreaker creates closed breaker. Every proxy has own breaker, so failures
+// of one proxy do not reject calls of other proxies with the same endpoint.
+func newBreaker(labels []string, failures int, openTimeout time.Duration) *breaker {
+	b := &breaker{labels: labels, failures: failures, openTimeout: openTimeout}
+	endpointCircuitState.WithLabelValues(labels...).Set(float64(circuitClosed))
+	return b
+}
+
+// endpointLabel returns endpoint without user info and query which may
+// contain secrets.
+func endpointLabel(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return endpoint
+	}
+	return u.Scheme + "://" + u.Host + u.Path
+}
+
+// guard calls proxy endpoint with retries and circuit breaker.
+type guard struct {
+	endpoint    string
+	maxAttempts int
+	backoffMin  time.Duration
+	backoffMax  time.Duration
+	breaker     *breaker
+	failOpen    bool
+}
+
+// Proxy types used in metrics.
+const (
+	proxyTypeConnect   = "connect"
+	proxyTypeRefresh   = "refresh"
+	proxyTypeSubscribe = "subscribe"
+	proxyTypePublish   = "publish"
+	proxyTypeRPC       = "rpc"
+)
+
+// retryable reports whether calls of proxy type can be retried. Publish and
+// RPC calls are not idempotent – backend could process request which failed
+// on the way back.
+func retryable(proxyType string) bool {
+	return proxyType != proxyTypePublish && proxyType != proxyTypeRPC
+}
+
+func newGuard(proxyType string, p Proxy) *guard {
+	g := &guard{
+		endpoint:    endpointLabel(p.Endpoint),
+		maxAttempts: p.RetryMaxAttempts,
+		backoffMin:  time.Duration(p.RetryBackoffMin),
+		backoffMax:  time.Duration(p.RetryBackoffMax),
+		failOpen:    p.FailOpen,
+	}
+	if g.maxAttempts < 1 || !retryable(proxyType) {
+		g.maxAttempts = 1
+	}
+	if g.backoffMin == 0 {
+		g.backoffMin = DefaultRetryBackoffMin
+	}
+	if g.backoffMax == 0 {
+		g.backoffMax = DefaultRetryBackoffMax
+	}
+	if p.CircuitBreakerFailures > 0 {
+		openTimeout := time.Duration(p.CircuitBreakerOpenTimeout)
+		if openTimeout == 0 {
+			openTimeout = DefaultCircuitBreakerOpenTimeout
+		}
+		g.breaker = newBreaker([]string{proxyType, p.Name, g.endpoint}, p.CircuitBreakerFailures, openTimeout)
+	}
+	return g
+}
+
+// backoff returns delay before retry attempt (starting from 1), exponential
+// with full jitter.
+func (g *guard) backoff(attempt int) time.Duration {
+	d := g.backoffMin << uint(attempt-1)
+	if d > g.backoffMax || d <= 0 {
+		d = g.backoffMax
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// call runs fn until success, attempts exhausted or context done.
+func (g *guard) call(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < g.maxAttempts; attempt++ {
+		if attempt > 0 {
+			endpointRetriesCount.WithLabelValues(g.endpoint).Inc()
+			timer := time.NewTimer(g.backoff(attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+		if g.breaker != nil && !g.breaker.allow(time.Now()) {
+			endpointRequestsCount.WithLabelValues(g.endpoint, "rejected").Inc()
+			return ErrCircuitOpen
+		}
+		err = fn()
+		if g.breaker != nil {
+			g.breaker.done(time.Now(), err)
+		}
+		if err == nil {
+			endpointRequestsCount.WithLabelValues(g.endpoint, "ok").Inc()
+			return nil
+		}
+		endpointRequestsCount.WithLabelValues(g.endpoint, "error").Inc()
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// FailOpen reports whether event must be allowed when proxy call failed.
+func (g *guard) FailOpen() bool {
+	return g.failOpen
+}
+
+// isFailOpen reports whether proxy configured to allow events when call
+// failed.
+func isFailOpen(p interface{}) bool {
+	f, ok := p.(interface{ FailOpen() bool })
+	return ok && f.FailOpen()
+}
+
+func needGuard(proxyType string, p Proxy) bool {
+	return (p.RetryMaxAttempts > 1 && retryable(proxyType)) || p.CircuitBreakerFailures > 0 || p.FailOpen
+}
+
+type guardedConnectProxy struct {
+	ConnectProxy
+	*guard
+}
+
+func (p *guardedConnectProxy) ProxyConnect(ctx context.Context, req *proxyproto.ConnectRequest) (*proxyproto.ConnectResponse, error) {
+	var resp *proxyproto.ConnectResponse
+	err := p.call(ctx, func() error {
+		var err error
+		resp, err = p.ConnectProxy.ProxyConnect(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+type guardedRefreshProxy struct {
+	RefreshProxy
+	*guard
+}
+
+func (p *guardedRefreshProxy) ProxyRefresh(ctx context.Context, req *proxyproto.RefreshRequest) (*proxyproto.RefreshResponse, error) {
+	var resp *proxyproto.RefreshResponse
+	err := p.call(ctx, func() error {
+		var err error
+		resp, err = p.RefreshProxy.ProxyRefresh(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+type guardedRPCProxy struct {
+	RPCProxy
+	*guard
+}
+
+func (p *guardedRPCProxy) ProxyRPC(ctx context.Context, req *proxyproto.RPCRequest) (*proxyproto.RPCResponse, error) {
+	var resp *proxyproto.RPCResponse
+	err := p.call(ctx, func() error {
+		var err error
+		resp, err = p.RPCProxy.ProxyRPC(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+type guardedPublishProxy struct {
+	PublishProxy
+	*guard
+}
+
+func (p *guardedPublishProxy) ProxyPublish(ctx context.Context, req *proxyproto.PublishRequest) (*proxyproto.PublishResponse, error) {
+	var resp *proxyproto.PublishResponse
+	err := p.call(ctx, func() error {
+		var err error
+		resp, err = p.PublishProxy.ProxyPublish(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+type guardedSubscribeProxy struct {
+	SubscribeProxy
+	*guard
+}
+
+func (p *guardedSubscribeProxy) ProxySubscribe(ctx context.Context, req *proxyproto.SubscribeRequest) (*proxyproto.SubscribeResponse, error) {
+	var resp *proxyproto.SubscribeResponse
+	err := p.call(ctx, func() error {
+		var err error
+		resp, err = p.SubscribeProxy.ProxySubscribe(ctx, req)
+		return err
+	})
+	return resp, err
+}
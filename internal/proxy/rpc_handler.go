@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/centrifugal/centrifugo/v3/internal/clientcontext"
+	"github.com/centrifugal/centrifugo/v3/internal/middleware"
 	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
 	"github.com/centrifugal/centrifugo/v3/internal/rule"
 
@@ -74,16 +75,16 @@ func (h *RPCHandler) Handle(node *centrifuge.Node) RPCHandlerFunc {
 		if h.config.GranularProxyMode {
 			rpcOpts, ok, err := ruleContainer.RpcOptions(e.Method)
 			if err != nil {
-				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error getting RPC options", map[string]interface{}{"method": e.Method, "error": err.Error()}))
+				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error getting RPC options", middleware.WithTraceID(client.Context(), map[string]interface{}{"method": e.Method, "error": err.Error()})))
 				return centrifuge.RPCReply{}, centrifuge.ErrorInternal
 			}
 			if !ok {
-				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "rpc options not found", map[string]interface{}{"method": e.Method}))
+				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "rpc options not found", middleware.WithTraceID(client.Context(), map[string]interface{}{"method": e.Method})))
 				return centrifuge.RPCReply{}, centrifuge.ErrorMethodNotFound
 			}
 			proxyName := rpcOpts.RpcProxyName
 			if proxyName == "" {
-				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "rpc proxy not configured for a method", map[string]interface{}{"method": e.Method}))
+				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "rpc proxy not configured for a method", middleware.WithTraceID(client.Context(), map[string]interface{}{"method": e.Method})))
 				return centrifuge.RPCReply{}, centrifuge.ErrorNotAvailable
 			}
 			p = h.config.Proxies[proxyName]
@@ -102,6 +103,7 @@ func (h *RPCHandler) Handle(node *centrifuge.Node) RPCHandlerFunc {
 			Protocol:  string(client.Transport().Protocol()),
 			Transport: client.Transport().Name(),
 			Encoding:  getEncoding(p.UseBase64()),
+			Location:  clientLocation(client.Context()),
 
 			User:   client.UserID(),
 			Method: e.Method,
@@ -129,7 +131,7 @@ func (h *RPCHandler) Handle(node *centrifuge.Node) RPCHandlerFunc {
 			summary.Observe(duration)
 			histogram.Observe(duration)
 			errors.Inc()
-			node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error proxying RPC", map[string]interface{}{"error": err.Error()}))
+			node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error proxying RPC", middleware.WithTraceID(client.Context(), map[string]interface{}{"error": err.Error()})))
 			return centrifuge.RPCReply{}, centrifuge.ErrorInternal
 		}
 		summary.Observe(duration)
@@ -147,7 +149,7 @@ func (h *RPCHandler) Handle(node *centrifuge.Node) RPCHandlerFunc {
 			if rpcData.B64Data != "" {
 				decodedData, err := base64.StdEncoding.DecodeString(rpcData.B64Data)
 				if err != nil {
-					node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding base64 data", map[string]interface{}{"client": client.ID(), "error": err.Error()}))
+					node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding base64 data", middleware.WithTraceID(client.Context(), map[string]interface{}{"client": client.ID(), "error": err.Error()})))
 					return centrifuge.RPCReply{}, centrifuge.ErrorInternal
 				}
 				data = decodedData
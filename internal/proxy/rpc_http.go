@@ -2,7 +2,6 @@ package proxy
 
 import (
 	"context"
-	"time"
 
 	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
 )
@@ -19,7 +18,7 @@ var _ RPCProxy = (*HTTPRPCProxy)(nil)
 func NewHTTPRPCProxy(p Proxy) (*HTTPRPCProxy, error) {
 	return &HTTPRPCProxy{
 		proxy:      p,
-		httpCaller: NewHTTPCaller(proxyHTTPClient(time.Duration(p.Timeout))),
+		httpCaller: newProxyHTTPCaller(p),
 	}, nil
 }
 
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySignature(t *testing.T) {
+	key := []byte("secret")
+	body := []byte(`{"client":"1"}`)
+	now := time.Now()
+	header := http.Header{}
+	require.NoError(t, signRequest(header, key, body, now))
+
+	require.NoError(t, VerifySignature(key, header, body, now, time.Minute))
+	require.Equal(t, ErrSignatureInvalid, VerifySignature([]byte("other"), header, body, now, time.Minute))
+	require.Equal(t, ErrSignatureInvalid, VerifySignature(key, header, []byte(`{"client":"2"}`), now, time.Minute))
+	require.Equal(t, ErrSignatureExpired, VerifySignature(key, header, body, now.Add(2*time.Minute), time.Minute))
+	require.Equal(t, ErrSignatureMissing, VerifySignature(key, http.Header{}, body, now, time.Minute))
+
+	// Timestamp is covered by signature.
+	tampered := header.Clone()
+	tampered.Set(TimestampHeader, strconv.FormatInt(now.Add(time.Hour).Unix(), 10))
+	require.Equal(t, ErrSignatureInvalid, VerifySignature(key, tampered, body, now.Add(time.Hour), time.Minute))
+
+	// Nonce differs between requests.
+	other := http.Header{}
+	require.NoError(t, signRequest(other, key, body, now))
+	require.NotEqual(t, header.Get(NonceHeader), other.Get(NonceHeader))
+}
+
+func TestHTTPProxySignature(t *testing.T) {
+	key := "secret"
+	verifyErrs := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErrs <- VerifySignature([]byte(key), r.Header, body, time.Now(), time.Minute)
+		_, _ = w.Write([]byte(`{"result": {"user": "56"}}`))
+	}))
+	defer server.Close()
+
+	connectProxy, err := NewHTTPConnectProxy(Proxy{
+		Endpoint:     server.URL,
+		Timeout:      tools.Duration(5 * time.Second),
+		SignatureKey: key,
+	})
+	require.NoError(t, err)
+	resp, err := connectProxy.ProxyConnect(context.Background(), &proxyproto.ConnectRequest{Client: "1"})
+	require.NoError(t, err)
+	require.Equal(t, "56", resp.Result.User)
+	require.NoError(t, <-verifyErrs)
+
+	connectProxy, err = NewHTTPConnectProxy(Proxy{
+		Endpoint: server.URL,
+		Timeout:  tools.Duration(5 * time.Second),
+	})
+	require.NoError(t, err)
+	_, err = connectProxy.ProxyConnect(context.Background(), &proxyproto.ConnectRequest{Client: "1"})
+	require.NoError(t, err)
+	require.Equal(t, ErrSignatureMissing, <-verifyErrs)
+}
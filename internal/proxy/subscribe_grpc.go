@@ -2,8 +2,6 @@ package proxy
 
 import (
 	"context"
-	"fmt"
-	"time"
 
 	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
 
@@ -12,39 +10,29 @@ import (
 
 // GRPCSubscribeProxy ...
 type GRPCSubscribeProxy struct {
-	proxy  Proxy
-	client proxyproto.CentrifugoProxyClient
+	proxy   Proxy
+	clients *grpcClientPool
 }
 
 var _ SubscribeProxy = (*GRPCSubscribeProxy)(nil)
 
 // NewGRPCSubscribeProxy ...
 func NewGRPCSubscribeProxy(p Proxy) (*GRPCSubscribeProxy, error) {
-	host, err := getGrpcHost(p.Endpoint)
+	clients, err := getGrpcClientPool(p)
 	if err != nil {
-		return nil, fmt.Errorf("error getting grpc host: %v", err)
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.Timeout))
-	defer cancel()
-	dialOpts, err := getDialOpts(p)
-	if err != nil {
-		return nil, fmt.Errorf("error creating GRPC dial options: %v", err)
-	}
-	conn, err := grpc.DialContext(ctx, host, dialOpts...)
-	if err != nil {
-		return nil, fmt.Errorf("error connecting to GRPC proxy server: %v", err)
+		return nil, err
 	}
 	return &GRPCSubscribeProxy{
-		proxy:  p,
-		client: proxyproto.NewCentrifugoProxyClient(conn),
+		proxy:   p,
+		clients: clients,
 	}, nil
 }
 
 // ProxySubscribe proxies Subscribe to application backend.
 func (p *GRPCSubscribeProxy) ProxySubscribe(ctx context.Context, req *proxyproto.SubscribeRequest) (*proxyproto.SubscribeResponse, error) {
-	ctx, cancel := context.WithTimeout(ctx, time.Duration(p.proxy.Timeout))
+	ctx, cancel := proxyContext(ctx, p.proxy)
 	defer cancel()
-	return p.client.Subscribe(grpcRequestContext(ctx, p.proxy), req, grpc.ForceCodec(grpcCodec))
+	return p.clients.next().Subscribe(grpcRequestContext(ctx, p.proxy), req, grpc.ForceCodec(grpcCodec))
 }
 
 // Protocol ...
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/centrifugal/centrifugo/v3/internal/clientcontext"
+	"github.com/centrifugal/centrifugo/v3/internal/middleware"
 	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
 	"github.com/centrifugal/centrifugo/v3/internal/rule"
 
@@ -74,7 +75,7 @@ func (h *SubscribeHandler) Handle(node *centrifuge.Node) SubscribeHandlerFunc {
 		if h.config.GranularProxyMode {
 			proxyName := chOpts.SubscribeProxyName
 			if proxyName == "" {
-				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "subscribe proxy not configured for a channel", map[string]interface{}{"channel": e.Channel}))
+				node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "subscribe proxy not configured for a channel", middleware.WithTraceID(client.Context(), map[string]interface{}{"channel": e.Channel})))
 				return centrifuge.SubscribeReply{}, centrifuge.ErrorNotAvailable
 			}
 			p = h.config.Proxies[proxyName]
@@ -93,6 +94,7 @@ func (h *SubscribeHandler) Handle(node *centrifuge.Node) SubscribeHandlerFunc {
 			Protocol:  string(client.Transport().Protocol()),
 			Transport: client.Transport().Name(),
 			Encoding:  getEncoding(p.UseBase64()),
+			Location:  clientLocation(client.Context()),
 
 			User:    client.UserID(),
 			Channel: e.Channel,
@@ -115,11 +117,16 @@ func (h *SubscribeHandler) Handle(node *centrifuge.Node) SubscribeHandlerFunc {
 			summary.Observe(duration)
 			histogram.Observe(duration)
 			errors.Inc()
-			node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error proxying subscribe", map[string]interface{}{"error": err.Error()}))
-			return centrifuge.SubscribeReply{}, centrifuge.ErrorInternal
+			node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error proxying subscribe", middleware.WithTraceID(client.Context(), map[string]interface{}{"error": err.Error()})))
+			if !isFailOpen(p) {
+				return centrifuge.SubscribeReply{}, centrifuge.ErrorInternal
+			}
+			// Fail open – proceed as if proxy returned empty result.
+			subscribeRep = &proxyproto.SubscribeResponse{}
+		} else {
+			summary.Observe(duration)
+			histogram.Observe(duration)
 		}
-		summary.Observe(duration)
-		histogram.Observe(duration)
 
 		if subscribeRep.Disconnect != nil {
 			return centrifuge.SubscribeReply{}, proxyproto.DisconnectFromProto(subscribeRep.Disconnect)
@@ -139,7 +146,7 @@ func (h *SubscribeHandler) Handle(node *centrifuge.Node) SubscribeHandlerFunc {
 			if subscribeRep.Result.B64Info != "" {
 				decodedInfo, err := base64.StdEncoding.DecodeString(subscribeRep.Result.B64Info)
 				if err != nil {
-					node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding base64 info", map[string]interface{}{"client": client.ID(), "error": err.Error()}))
+					node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding base64 info", middleware.WithTraceID(client.Context(), map[string]interface{}{"client": client.ID(), "error": err.Error()})))
 					return centrifuge.SubscribeReply{}, centrifuge.ErrorInternal
 				}
 				info = decodedInfo
@@ -149,7 +156,7 @@ func (h *SubscribeHandler) Handle(node *centrifuge.Node) SubscribeHandlerFunc {
 			if subscribeRep.Result.B64Data != "" {
 				decodedData, err := base64.StdEncoding.DecodeString(subscribeRep.Result.B64Data)
 				if err != nil {
-					node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding base64 data", map[string]interface{}{"client": client.ID(), "error": err.Error()}))
+					node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error decoding base64 data", middleware.WithTraceID(client.Context(), map[string]interface{}{"client": client.ID(), "error": err.Error()})))
 					return centrifuge.SubscribeReply{}, centrifuge.ErrorInternal
 				}
 				data = decodedData
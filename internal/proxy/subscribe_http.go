@@ -2,7 +2,6 @@ package proxy
 
 import (
 	"context"
-	"time"
 
 	"github.com/centrifugal/centrifugo/v3/internal/proxyproto"
 )
@@ -19,7 +18,7 @@ var _ SubscribeProxy = (*HTTPSubscribeProxy)(nil)
 func NewHTTPSubscribeProxy(p Proxy) (*HTTPSubscribeProxy, error) {
 	return &HTTPSubscribeProxy{
 		proxy:      p,
-		httpCaller: NewHTTPCaller(proxyHTTPClient(time.Duration(p.Timeout))),
+		httpCaller: newProxyHTTPCaller(p),
 	}, nil
 }
 
@@ -18,6 +18,7 @@ type proxyGRPCTestServerOptions struct {
 	B64Data  string
 	Channels []string
 	Data     []byte
+	Grants   []*proxyproto.ChannelGrant
 }
 
 func newProxyGRPCTestServer(flag string, opts proxyGRPCTestServerOptions) proxyGRPCTestServer {
@@ -45,6 +46,13 @@ func (p proxyGRPCTestServer) Connect(_ context.Context, _ *proxyproto.ConnectReq
 			},
 		}, nil
 
+	case "grants":
+		return &proxyproto.ConnectResponse{
+			Result: &proxyproto.ConnectResult{
+				User:   p.opts.User,
+				Grants: p.opts.Grants,
+			},
+		}, nil
 	case "subscription error":
 		return &proxyproto.ConnectResponse{
 			Result: &proxyproto.ConnectResult{
@@ -1,11 +1,22 @@
 package proxyproto
 
-import "github.com/centrifugal/centrifuge"
+import (
+	"github.com/centrifugal/centrifugo/v3/internal/disconnect"
+	"github.com/centrifugal/centrifugo/v3/internal/grant"
 
+	"github.com/centrifugal/centrifuge"
+)
+
+// DisconnectFromProto converts Disconnect returned by proxy. Standard codes
+// without reason get reason of registered code.
 func DisconnectFromProto(s *Disconnect) *centrifuge.Disconnect {
+	reason := s.Reason
+	if c, ok := disconnect.Lookup(s.Code); ok && reason == "" {
+		reason = c.Reason
+	}
 	return &centrifuge.Disconnect{
 		Code:      s.Code,
-		Reason:    s.Reason,
+		Reason:    reason,
 		Reconnect: s.Reconnect,
 	}
 }
@@ -16,3 +27,28 @@ func ErrorFromProto(s *Error) *centrifuge.Error {
 		Message: s.Message,
 	}
 }
+
+// GrantsFromProto converts channel grants returned by connect proxy.
+func GrantsFromProto(s []*ChannelGrant) []grant.Grant {
+	grants := make([]grant.Grant, 0, len(s))
+	for _, cg := range s {
+		g := grant.Grant{Channel: cg.Channel}
+		if o := cg.Override; o != nil {
+			g.Override = &grant.Override{
+				Presence:  boolValueFromProto(o.Presence),
+				JoinLeave: boolValueFromProto(o.JoinLeave),
+				Recover:   boolValueFromProto(o.Recover),
+				Position:  boolValueFromProto(o.Position),
+			}
+		}
+		grants = append(grants, g)
+	}
+	return grants
+}
+
+func boolValueFromProto(v *BoolValue) *grant.BoolValue {
+	if v == nil {
+		return nil
+	}
+	return &grant.BoolValue{Value: v.Value}
+}
@@ -0,0 +1,263 @@
+// Package proxyprotocol implements HAProxy PROXY protocol (v1 and v2) support
+// for listeners, so connections accepted behind TCP load balancers report
+// real client address.
+package proxyprotocol
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHeaderTimeout is a default time to wait for PROXY protocol header.
+const DefaultHeaderTimeout = 5 * time.Second
+
+var (
+	// ErrInvalidHeader returned when connection started with malformed
+	// PROXY protocol header.
+	ErrInvalidHeader = errors.New("proxyprotocol: invalid header")
+	// ErrHeaderRequired returned when header required but not sent by peer.
+	ErrHeaderRequired = errors.New("proxyprotocol: header required")
+)
+
+var (
+	v1Prefix    = []byte("PROXY ")
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// v1 header max length including CRLF.
+const v1MaxLength = 107
+
+// Config of PROXY protocol listener.
+type Config struct {
+	// TrustedNetworks from which PROXY protocol header accepted. Headers from
+	// connections of other networks are not parsed. Empty means that no
+	// network trusted.
+	TrustedNetworks []*net.IPNet
+	// Required makes header mandatory for connections from trusted networks.
+	Required bool
+	// HeaderTimeout limits time to wait for header. By default
+	// DefaultHeaderTimeout used.
+	HeaderTimeout time.Duration
+}
+
+// ParseNetworks parses CIDR strings. Single IP addresses are also allowed.
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address: %s", v)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Listener wraps net.Listener to read PROXY protocol header of accepted
+// connections.
+type Listener struct {
+	net.Listener
+	config Config
+}
+
+// NewListener creates Listener.
+func NewListener(l net.Listener, c Config) *Listener {
+	if c.HeaderTimeout <= 0 {
+		c.HeaderTimeout = DefaultHeaderTimeout
+	}
+	return &Listener{Listener: l, config: c}
+}
+
+// Accept waits for and returns the next connection. Header read lazily on
+// first Read or RemoteAddr call to not block accepting loop.
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.trusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &Conn{
+		Conn:   conn,
+		reader: bufio.NewReader(conn),
+		config: l.config,
+	}, nil
+}
+
+func (l *Listener) trusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range l.config.TrustedNetworks {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a connection with source address taken from PROXY protocol header.
+type Conn struct {
+	net.Conn
+	reader *bufio.Reader
+	config Config
+
+	once       sync.Once
+	err        error
+	remoteAddr net.Addr
+}
+
+// Read reads data from connection after PROXY protocol header.
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns source address from PROXY protocol header if it was
+// sent, otherwise address of peer.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *Conn) readHeader() {
+	_ = c.Conn.SetReadDeadline(time.Now().Add(c.config.HeaderTimeout))
+	defer func() { _ = c.Conn.SetReadDeadline(time.Time{}) }()
+
+	first, err := c.reader.Peek(1)
+	if err != nil {
+		if err != io.EOF {
+			c.err = err
+		}
+		return
+	}
+	switch first[0] {
+	case v1Prefix[0]:
+		c.remoteAddr, c.err = readV1(c.reader)
+	case v2Signature[0]:
+		c.remoteAddr, c.err = readV2(c.reader)
+	default:
+		if c.config.Required {
+			c.err = ErrHeaderRequired
+		}
+	}
+}
+
+// readV1 reads text header like "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n".
+func readV1(r *bufio.Reader) (net.Addr, error) {
+	prefix, err := r.Peek(len(v1Prefix))
+	if err != nil || !bytes.Equal(prefix, v1Prefix) {
+		return nil, ErrInvalidHeader
+	}
+	var line []byte
+	for len(line) < v1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, ErrInvalidHeader
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrInvalidHeader
+	}
+	parts := strings.Split(string(line[:len(line)-2]), " ")
+	if len(parts) >= 2 && parts[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(parts) != 6 || (parts[1] != "TCP4" && parts[1] != "TCP6") {
+		return nil, ErrInvalidHeader
+	}
+	ip := net.ParseIP(parts[2])
+	if ip == nil || (parts[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, ErrInvalidHeader
+	}
+	port, err := strconv.ParseUint(parts[4], 10, 16)
+	if err != nil {
+		return nil, ErrInvalidHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+const (
+	v2CommandLocal = 0x0
+	v2CommandProxy = 0x1
+
+	v2FamilyInet  = 0x1
+	v2FamilyInet6 = 0x2
+)
+
+// readV2 reads binary header.
+func readV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, len(v2Signature)+4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrInvalidHeader
+	}
+	if !bytes.Equal(header[:len(v2Signature)], v2Signature) {
+		return nil, ErrInvalidHeader
+	}
+	versionCommand := header[12]
+	if versionCommand>>4 != 2 {
+		return nil, ErrInvalidHeader
+	}
+	family := header[13] >> 4
+	length := binary.BigEndian.Uint16(header[14:16])
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, ErrInvalidHeader
+	}
+	switch versionCommand & 0x0F {
+	case v2CommandLocal:
+		// Health checks of proxy itself, connection address used.
+		return nil, nil
+	case v2CommandProxy:
+	default:
+		return nil, ErrInvalidHeader
+	}
+	switch family {
+	case v2FamilyInet:
+		if len(payload) < 12 {
+			return nil, ErrInvalidHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case v2FamilyInet6:
+		if len(payload) < 36 {
+			return nil, ErrInvalidHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	default:
+		// Unix sockets and unspecified family – keep connection address.
+		return nil, nil
+	}
+}
@@ -0,0 +1,104 @@
+package proxyprotocol
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func acceptWith(t *testing.T, config Config, header []byte) (net.Conn, net.Conn) {
+	if config.TrustedNetworks == nil {
+		// Test client connects from loopback.
+		config.TrustedNetworks = []*net.IPNet{{IP: net.IPv4(127, 0, 0, 1), Mask: net.CIDRMask(32, 32)}}
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l := NewListener(ln, config)
+	t.Cleanup(func() { _ = l.Close() })
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	_, err = client.Write(append(header, []byte("hello")...))
+	require.NoError(t, err)
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return client, conn
+}
+
+func readHello(t *testing.T, conn net.Conn) {
+	buf := make([]byte, 5)
+	_, err := io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+}
+
+func TestListenerV1(t *testing.T) {
+	_, conn := acceptWith(t, Config{}, []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"))
+	require.Equal(t, "192.168.0.1:56324", conn.RemoteAddr().String())
+	readHello(t, conn)
+}
+
+func TestListenerV1Unknown(t *testing.T) {
+	client, conn := acceptWith(t, Config{}, []byte("PROXY UNKNOWN\r\n"))
+	require.Equal(t, client.LocalAddr().String(), conn.RemoteAddr().String())
+	readHello(t, conn)
+}
+
+func TestListenerV2(t *testing.T) {
+	header := append([]byte{}, v2Signature...)
+	header = append(header, 0x21, 0x11, 0, 12)
+	header = append(header, 10, 0, 0, 1, 10, 0, 0, 2)
+	ports := make([]byte, 4)
+	binary.BigEndian.PutUint16(ports[0:2], 4000)
+	binary.BigEndian.PutUint16(ports[2:4], 443)
+	header = append(header, ports...)
+	_, conn := acceptWith(t, Config{}, header)
+	require.Equal(t, "10.0.0.1:4000", conn.RemoteAddr().String())
+	readHello(t, conn)
+}
+
+func TestListenerNoHeader(t *testing.T) {
+	client, conn := acceptWith(t, Config{}, nil)
+	require.Equal(t, client.LocalAddr().String(), conn.RemoteAddr().String())
+	readHello(t, conn)
+
+	_, conn = acceptWith(t, Config{Required: true, HeaderTimeout: time.Second}, nil)
+	_, err := conn.Read(make([]byte, 5))
+	require.Equal(t, ErrHeaderRequired, err)
+}
+
+func TestListenerInvalidHeader(t *testing.T) {
+	_, conn := acceptWith(t, Config{}, []byte("PROXY TCP4 bad\r\n"))
+	_, err := conn.Read(make([]byte, 5))
+	require.Equal(t, ErrInvalidHeader, err)
+}
+
+func TestListenerUntrusted(t *testing.T) {
+	networks, err := ParseNetworks([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	_, conn := acceptWith(t, Config{TrustedNetworks: networks}, []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"))
+	require.NotEqual(t, "192.168.0.1:56324", conn.RemoteAddr().String())
+}
+
+func TestListenerNoTrustedNetworks(t *testing.T) {
+	_, conn := acceptWith(t, Config{TrustedNetworks: []*net.IPNet{}}, []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"))
+	require.NotEqual(t, "192.168.0.1:56324", conn.RemoteAddr().String())
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks([]string{"10.0.0.0/8", "127.0.0.1", "::1"})
+	require.NoError(t, err)
+	require.Len(t, networks, 3)
+	require.True(t, networks[1].Contains(net.ParseIP("127.0.0.1")))
+	require.False(t, networks[1].Contains(net.ParseIP("127.0.0.2")))
+
+	_, err = ParseNetworks([]string{"bad"})
+	require.Error(t, err)
+}
@@ -0,0 +1,57 @@
+// Package pubdedup contains types of client publish deduplication. Clients
+// can publish with client-generated message ID, node remembers result of
+// publication with ID for TTL and returns it on retries with the same ID
+// instead of publishing again. So publication retried by client after
+// connection loss is delivered to subscribers only once.
+package pubdedup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// DefaultTTL is a default time publication result kept for message ID.
+const DefaultTTL = 5 * time.Minute
+
+// PendingTTLMargin is added to publish timeout to get time publication is
+// marked as pending, it covers publishing to broker after publish proxy
+// request. Pending mark expires after that so message ID is not blocked for
+// full TTL if node failed before saving result.
+const PendingTTLMargin = 5 * time.Second
+
+// MaxIDLength is a max length of client-generated message ID.
+const MaxIDLength = 128
+
+// Result of publication with message ID.
+type Result struct {
+	// Pending is true while publication is still in progress.
+	Pending bool
+	// Offset of publication in channel stream.
+	Offset uint64
+	// Epoch of channel stream.
+	Epoch string
+}
+
+// Store keeps results of publications by deduplication keys.
+type Store interface {
+	// Reserve marks key as pending for ttl if key is unknown. Returns current
+	// result and true if key is already known. Pending ttl should be short
+	// since key can't be published while pending.
+	Reserve(key string, ttl time.Duration) (Result, bool, error)
+	// Save sets result of publication for key for ttl replacing pending mark.
+	Save(key string, r Result, ttl time.Duration) error
+	// Release removes key so publication can be retried.
+	Release(key string) error
+}
+
+// Key returns deduplication key of publication with message ID sent to
+// channel by publisher (user ID or client ID for anonymous connections).
+func Key(publisher string, channel string, id string) string {
+	hash := sha256.New()
+	for _, part := range []string{publisher, channel, id} {
+		_, _ = hash.Write([]byte(part))
+		_, _ = hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,15 @@
+package pubdedup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKey(t *testing.T) {
+	key := Key("42", "chat:index", "msg1")
+	require.Len(t, key, 64)
+	require.Equal(t, key, Key("42", "chat:index", "msg1"))
+	require.NotEqual(t, key, Key("42", "chat:index", "msg2"))
+	require.NotEqual(t, Key("4", "2chat:index", "msg1"), key)
+}
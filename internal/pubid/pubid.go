@@ -0,0 +1,98 @@
+// Package pubid contains generators of unique publication IDs.
+//
+// Publication ID is put into publication envelope (see publisher.Envelope),
+// so subscribers and history readers can correlate publication with ID
+// returned to publisher.
+package pubid
+
+import (
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Generator generates unique publication IDs.
+type Generator interface {
+	// Generate returns new ID. Must be safe for concurrent use.
+	Generate() string
+}
+
+// UUIDGenerator generates random UUID v4 IDs.
+type UUIDGenerator struct{}
+
+// Generate ...
+func (UUIDGenerator) Generate() string {
+	return uuid.New().String()
+}
+
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+	// MaxNodeID is a max node ID which can be used in SnowflakeGenerator.
+	MaxNodeID   = 1<<nodeBits - 1
+	maxSequence = 1<<sequenceBits - 1
+	timeShift   = nodeBits + sequenceBits
+	nodeIDShift = sequenceBits
+)
+
+// snowflakeEpoch is a custom epoch to start counting milliseconds from
+// (2021-01-01 UTC), this gives about 69 years of IDs.
+var snowflakeEpoch = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SnowflakeGenerator generates 63-bit IDs made of millisecond timestamp, node
+// ID and sequence number within millisecond. IDs generated by nodes with
+// different node IDs never collide, IDs are roughly ordered by time.
+type SnowflakeGenerator struct {
+	mu       sync.Mutex
+	nodeID   int64
+	lastMs   int64
+	sequence int64
+	now      func() time.Time
+}
+
+// NewSnowflakeGenerator creates SnowflakeGenerator with node ID in range
+// [0, MaxNodeID].
+func NewSnowflakeGenerator(nodeID int64) (*SnowflakeGenerator, error) {
+	if nodeID < 0 || nodeID > MaxNodeID {
+		return nil, errors.New("snowflake node ID must be in range [0, " + strconv.Itoa(MaxNodeID) + "]")
+	}
+	return &SnowflakeGenerator{nodeID: nodeID, now: time.Now}, nil
+}
+
+// NodeIDFromString derives snowflake node ID from string (for example from
+// node name). Unlike explicitly configured node IDs derived ones can collide.
+func NodeIDFromString(s string) int64 {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(s))
+	return int64(hash.Sum32() % (MaxNodeID + 1))
+}
+
+// Generate ...
+func (g *SnowflakeGenerator) Generate() string {
+	return strconv.FormatInt(g.next(), 10)
+}
+
+func (g *SnowflakeGenerator) next() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ms := g.now().Sub(snowflakeEpoch).Milliseconds()
+	if ms < g.lastMs {
+		// Clock moved backwards – keep generating from last timestamp.
+		ms = g.lastMs
+	}
+	if ms == g.lastMs {
+		g.sequence = (g.sequence + 1) & maxSequence
+		if g.sequence == 0 {
+			// Sequence exhausted within millisecond, borrow next one.
+			ms++
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastMs = ms
+	return ms<<timeShift | g.nodeID<<nodeIDShift | g.sequence
+}
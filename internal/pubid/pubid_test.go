@@ -0,0 +1,67 @@
+package pubid
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUUIDGenerator(t *testing.T) {
+	g := UUIDGenerator{}
+	require.NotEqual(t, g.Generate(), g.Generate())
+}
+
+func TestNewSnowflakeGeneratorInvalidNodeID(t *testing.T) {
+	_, err := NewSnowflakeGenerator(-1)
+	require.Error(t, err)
+	_, err = NewSnowflakeGenerator(MaxNodeID + 1)
+	require.Error(t, err)
+}
+
+func TestSnowflakeGenerator(t *testing.T) {
+	g, err := NewSnowflakeGenerator(5)
+	require.NoError(t, err)
+	now := snowflakeEpoch.Add(time.Second)
+	g.now = func() time.Time { return now }
+
+	id := g.next()
+	require.Equal(t, int64(1000), id>>timeShift)
+	require.Equal(t, int64(5), id>>nodeIDShift&MaxNodeID)
+	require.Equal(t, int64(0), id&maxSequence)
+
+	require.Equal(t, id+1, g.next())
+
+	// Clock moved backwards.
+	now = now.Add(-time.Millisecond)
+	require.Equal(t, id+2, g.next())
+}
+
+func TestSnowflakeGeneratorSequenceOverflow(t *testing.T) {
+	g, err := NewSnowflakeGenerator(1)
+	require.NoError(t, err)
+	now := snowflakeEpoch.Add(time.Second)
+	g.now = func() time.Time { return now }
+
+	var prev int64
+	for i := 0; i <= maxSequence+1; i++ {
+		id := g.next()
+		require.True(t, id > prev)
+		prev = id
+	}
+	require.Equal(t, int64(1001), prev>>timeShift)
+}
+
+func TestSnowflakeGeneratorGenerate(t *testing.T) {
+	g, err := NewSnowflakeGenerator(MaxNodeID)
+	require.NoError(t, err)
+	id, err := strconv.ParseInt(g.Generate(), 10, 64)
+	require.NoError(t, err)
+	require.Equal(t, int64(MaxNodeID), id>>nodeIDShift&MaxNodeID)
+}
+
+func TestNodeIDFromString(t *testing.T) {
+	require.Equal(t, NodeIDFromString("node1"), NodeIDFromString("node1"))
+	require.True(t, NodeIDFromString("node1") <= MaxNodeID)
+}
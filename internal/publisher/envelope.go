@@ -0,0 +1,65 @@
+package publisher
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/centrifugal/centrifugo/v3/internal/puborigin"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+)
+
+var (
+	// ErrInvalidData returned when publication data must be put into envelope
+	// but is not valid JSON.
+	ErrInvalidData = errors.New("publication data must be valid JSON")
+	// ErrTagsNotAllowed returned when tags set for publication to channel
+	// without publication tags on.
+	ErrTagsNotAllowed = errors.New("publication tags not allowed in channel")
+)
+
+// Envelope of publication data. Publication data is put into envelope when
+// publication IDs are configured or channel has publication origin or
+// publication tags on:
+//
+//	{"id": "<id>", "origin": {...}, "tags": {...}, "data": <payload>}
+//
+// Fields not used for publication are omitted, so there is always exactly one
+// envelope around payload regardless of publication source.
+type Envelope struct {
+	ID     string            `json:"id,omitempty"`
+	Origin *puborigin.Origin `json:"origin,omitempty"`
+	Tags   map[string]string `json:"tags,omitempty"`
+	Data   json.RawMessage   `json:"data"`
+}
+
+// Wrap returns publication data in envelope and ID generated for publication.
+// ID is empty if IDs are not configured. Origin added for channels with
+// publication origin on (Node of origin set by Publisher), tags for channels
+// with publication tags on. Data returned as is if no envelope required.
+func (p *Publisher) Wrap(chOpts rule.ChannelOptions, data []byte, origin puborigin.Origin, tags map[string]string) ([]byte, string, error) {
+	if !chOpts.PublicationTags && len(tags) > 0 {
+		return nil, "", ErrTagsNotAllowed
+	}
+	if p.ids == nil && !chOpts.PublicationOrigin && !chOpts.PublicationTags {
+		return data, "", nil
+	}
+	if !json.Valid(data) {
+		return nil, "", ErrInvalidData
+	}
+	e := Envelope{Data: data}
+	if p.ids != nil {
+		e.ID = p.ids.Generate()
+	}
+	if chOpts.PublicationOrigin {
+		origin.Node = p.node.ID()
+		e.Origin = &origin
+	}
+	if chOpts.PublicationTags {
+		e.Tags = tags
+	}
+	wrapped, err := json.Marshal(e)
+	if err != nil {
+		return nil, "", err
+	}
+	return wrapped, e.ID, nil
+}
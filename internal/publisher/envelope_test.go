@@ -0,0 +1,52 @@
+package publisher
+
+import (
+	"testing"
+
+	"github.com/centrifugal/centrifugo/v3/internal/puborigin"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testIDGenerator struct{}
+
+func (testIDGenerator) Generate() string {
+	return "1"
+}
+
+func TestWrap(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	p := New(node, nil)
+	origin := puborigin.Origin{Type: puborigin.TypeClient, User: "42", Client: "c1"}
+
+	// No envelope required.
+	data, id, err := p.Wrap(rule.ChannelOptions{}, []byte("text"), origin, nil)
+	require.NoError(t, err)
+	require.Equal(t, "", id)
+	require.Equal(t, "text", string(data))
+
+	_, _, err = p.Wrap(rule.ChannelOptions{}, []byte(`{}`), origin, map[string]string{"region": "eu"})
+	require.Equal(t, ErrTagsNotAllowed, err)
+
+	_, _, err = p.Wrap(rule.ChannelOptions{PublicationOrigin: true}, []byte(`{`), origin, nil)
+	require.Equal(t, ErrInvalidData, err)
+
+	data, _, err = p.Wrap(rule.ChannelOptions{PublicationTags: true}, []byte(`{"a":1}`), origin, nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"data":{"a":1}}`, string(data))
+
+	// Single envelope with all fields.
+	p.SetIDGenerator(testIDGenerator{})
+	chOpts := rule.ChannelOptions{PublicationOrigin: true, PublicationTags: true}
+	data, id, err = p.Wrap(chOpts, []byte(`{"a":1}`), origin, map[string]string{"region": "eu"})
+	require.NoError(t, err)
+	require.Equal(t, "1", id)
+	require.JSONEq(t, `{"id":"1","origin":{"type":"client","user":"42","client":"c1","node":"`+node.ID()+`"},"tags":{"region":"eu"},"data":{"a":1}}`, string(data))
+
+	data, id, err = p.Wrap(rule.ChannelOptions{}, []byte(`"text"`), origin, nil)
+	require.NoError(t, err)
+	require.Equal(t, "1", id)
+	require.JSONEq(t, `{"id":"1","data":"text"}`, string(data))
+}
@@ -0,0 +1,103 @@
+// Package publisher contains publishing path shared by server API, client
+// publications and publish proxy, so publications get the same treatment
+// regardless of source.
+package publisher
+
+import (
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/inbox"
+	"github.com/centrifugal/centrifugo/v3/internal/pubid"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// PushNotifier sends publications to devices of offline users.
+type PushNotifier interface {
+	// Notify sends data to all devices of user.
+	Notify(user string, data []byte)
+}
+
+// Publisher puts publication data into envelope, publishes data to channels
+// and handles publications to personal channels of offline users.
+type Publisher struct {
+	node          *centrifuge.Node
+	ids           pubid.Generator
+	ruleContainer *rule.Container
+	inbox         inbox.Store
+	inboxOpts     inbox.Options
+	pushNotifier  PushNotifier
+}
+
+// New creates Publisher.
+func New(n *centrifuge.Node, ruleContainer *rule.Container) *Publisher {
+	return &Publisher{
+		node:          n,
+		ruleContainer: ruleContainer,
+	}
+}
+
+// SetIDGenerator sets generator of publication IDs. ID is added to envelope
+// of every publication, payload of publications must be JSON in this case.
+func (p *Publisher) SetIDGenerator(g pubid.Generator) {
+	p.ids = g
+}
+
+// SetInbox sets Store to keep publications to personal channels of offline
+// users in.
+func (p *Publisher) SetInbox(s inbox.Store, opts inbox.Options) {
+	p.inbox = s
+	p.inboxOpts = opts
+}
+
+// SetPushNotifier sets PushNotifier to send publications to personal channels
+// of offline users as push notifications.
+func (p *Publisher) SetPushNotifier(n PushNotifier) {
+	p.pushNotifier = n
+}
+
+// Publish publishes data to channel over node.
+func (p *Publisher) Publish(ch string, data []byte, opts ...centrifuge.PublishOption) (centrifuge.PublishResult, error) {
+	result, err := p.node.Publish(ch, data, opts...)
+	if err == nil {
+		p.Published(ch, data)
+	}
+	return result, err
+}
+
+// Published must be called after data successfully published to channel
+// not over Publish, e.g. with priority publisher of engine.
+func (p *Publisher) Published(ch string, data []byte) {
+	p.handleOffline(ch, data)
+}
+
+// handleOffline adds publication to inbox of user and sends it as push
+// notification if channel is a personal channel of user who has no presence
+// in it.
+func (p *Publisher) handleOffline(ch string, data []byte) {
+	if p.inbox == nil && p.pushNotifier == nil {
+		return
+	}
+	user, ok := p.ruleContainer.PersonalChannelUser(ch)
+	if !ok {
+		return
+	}
+	stats, err := p.node.PresenceStats(ch)
+	if err != nil {
+		// Better to keep publication for online user than to lose it.
+		p.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error getting presence stats of personal channel", map[string]interface{}{"channel": ch, "error": err.Error()}))
+	} else if stats.NumClients > 0 {
+		return
+	}
+	if p.pushNotifier != nil {
+		p.pushNotifier.Notify(user, data)
+	}
+	if p.inbox == nil {
+		return
+	}
+	e := inbox.Entry{Data: data, Time: time.Now().UnixNano() / int64(time.Millisecond)}
+	if err := p.inbox.AddToInbox(user, e, p.inboxOpts); err != nil {
+		p.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error adding publication to user inbox", map[string]interface{}{"channel": ch, "user": user, "error": err.Error()}))
+	}
+}
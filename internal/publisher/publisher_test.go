@@ -0,0 +1,81 @@
+package publisher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/inbox"
+	"github.com/centrifugal/centrifugo/v3/internal/memengine"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisherInbox(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+	presenceManager, err := centrifuge.NewMemoryPresenceManager(node, centrifuge.MemoryPresenceManagerConfig{})
+	require.NoError(t, err)
+	node.SetPresenceManager(presenceManager)
+
+	store := memengine.NewInboxStore()
+	p := New(node, rule.NewContainer(rule.DefaultConfig))
+	p.SetInbox(store, inbox.Options{Size: 10, TTL: time.Minute})
+
+	_, err = p.Publish("#42", []byte(`{"a":1}`))
+	require.NoError(t, err)
+	_, err = p.Publish("test", []byte(`{"a":2}`))
+	require.NoError(t, err)
+	// Publications sent not over Publish.
+	p.Published("#42", []byte(`{"a":3}`))
+	entries, err := store.PeekInbox("42")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, []byte(`{"a":1}`), entries[0].Data)
+	require.Equal(t, []byte(`{"a":3}`), entries[1].Data)
+	require.NoError(t, store.TrimInbox("42", len(entries)))
+
+	// Online user does not get publications into inbox.
+	require.NoError(t, presenceManager.AddPresence("#42", "client", &centrifuge.ClientInfo{ClientID: "client", UserID: "42"}))
+	_, err = p.Publish("#42", []byte(`{}`))
+	require.NoError(t, err)
+	entries, err = store.PeekInbox("42")
+	require.NoError(t, err)
+	require.Len(t, entries, 0)
+}
+
+type testPushNotifier struct {
+	notified []string
+}
+
+func (n *testPushNotifier) Notify(user string, _ []byte) {
+	n.notified = append(n.notified, user)
+}
+
+func TestPublisherPush(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+	presenceManager, err := centrifuge.NewMemoryPresenceManager(node, centrifuge.MemoryPresenceManagerConfig{})
+	require.NoError(t, err)
+	node.SetPresenceManager(presenceManager)
+
+	notifier := &testPushNotifier{}
+	p := New(node, rule.NewContainer(rule.DefaultConfig))
+	p.SetPushNotifier(notifier)
+
+	_, err = p.Publish("#42", []byte(`{}`))
+	require.NoError(t, err)
+	_, err = p.Publish("test", []byte(`{}`))
+	require.NoError(t, err)
+	p.Published("#43", []byte(`{}`))
+	require.Equal(t, []string{"42", "43"}, notifier.notified)
+
+	// Online user does not get push notifications.
+	require.NoError(t, presenceManager.AddPresence("#42", "client", &centrifuge.ClientInfo{ClientID: "client", UserID: "42"}))
+	_, err = p.Publish("#42", []byte(`{}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"42", "43"}, notifier.notified)
+}
@@ -0,0 +1,46 @@
+// Package puborigin implements origin metadata of publications.
+//
+// In channels with publication origin on publication envelope (see
+// publisher.Envelope) has "origin" field, so subscribers can distinguish
+// publications sent over server API from ones published by clients without
+// application level payload conventions.
+package puborigin
+
+import (
+	"encoding/json"
+)
+
+// Origin types.
+const (
+	// TypeAPI is an origin of publications sent over server API.
+	TypeAPI = "api"
+	// TypeClient is an origin of publications sent by clients.
+	TypeClient = "client"
+	// TypeProxy is an origin of client publications passed through publish proxy.
+	TypeProxy = "proxy"
+)
+
+// Origin describes who published a publication.
+type Origin struct {
+	// Type is one of TypeAPI, TypeClient or TypeProxy.
+	Type string `json:"type"`
+	// User is an ID of user who published data, empty for server API.
+	User string `json:"user,omitempty"`
+	// Client is an ID of client connection which published data, empty for
+	// server API.
+	Client string `json:"client,omitempty"`
+	// Node is an ID of node on which publication was sent.
+	Node string `json:"node,omitempty"`
+}
+
+// Unwrap extracts origin and payload from publication envelope.
+func Unwrap(data []byte) (Origin, []byte, bool) {
+	var e struct {
+		Origin Origin          `json:"origin"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &e); err != nil || e.Origin.Type == "" {
+		return Origin{}, nil, false
+	}
+	return e.Origin, e.Data, true
+}
@@ -0,0 +1,20 @@
+package puborigin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnwrap(t *testing.T) {
+	origin, payload, ok := Unwrap([]byte(`{"id":"1","origin":{"type":"client","user":"42","client":"c1","node":"n1"},"tags":{"region":"eu"},"data":"text"}`))
+	require.True(t, ok)
+	require.Equal(t, Origin{Type: TypeClient, User: "42", Client: "c1", Node: "n1"}, origin)
+	require.Equal(t, `"text"`, string(payload))
+
+	_, _, ok = Unwrap([]byte(`{"data":1}`))
+	require.False(t, ok)
+
+	_, _, ok = Unwrap([]byte(`{`))
+	require.False(t, ok)
+}
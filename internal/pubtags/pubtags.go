@@ -0,0 +1,86 @@
+// Package pubtags implements key/value tags of publications and server-side
+// filters of publications by tags.
+//
+// In channels with publication tags on publication envelope (see
+// publisher.Envelope) has "tags" field, tags omitted if empty. Clients can set
+// filter for channel subscription, e.g. tags.region == "eu", so that
+// publications which do not match filter not sent to connection at all.
+package pubtags
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MaxFilterLength is a max length of filter expression.
+const MaxFilterLength = 512
+
+// ErrInvalidFilter returned for filter expressions which can't be parsed.
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Tags extracts tags from publication envelope.
+func Tags(data []byte) (map[string]string, bool) {
+	var e struct {
+		Tags map[string]string `json:"tags"`
+	}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, false
+	}
+	return e.Tags, true
+}
+
+type condition struct {
+	key   string
+	value string
+	equal bool
+}
+
+// Filter of publications by tags. All conditions of filter must be true for
+// publication to match.
+type Filter struct {
+	conditions []condition
+}
+
+var conditionRe = regexp.MustCompile(`^\s*tags\.([A-Za-z0-9_\-.:]+)\s*(==|!=)\s*("(?:[^"\\]|\\.)*")\s*`)
+
+// ParseFilter parses filter expression. Expression consists of conditions
+// tags.<key> == "<value>" or tags.<key> != "<value>" joined with &&.
+func ParseFilter(s string) (*Filter, error) {
+	if len(s) > MaxFilterLength {
+		return nil, ErrInvalidFilter
+	}
+	f := &Filter{}
+	rest := s
+	for {
+		m := conditionRe.FindStringSubmatch(rest)
+		if m == nil {
+			return nil, ErrInvalidFilter
+		}
+		value, err := strconv.Unquote(m[3])
+		if err != nil {
+			return nil, ErrInvalidFilter
+		}
+		f.conditions = append(f.conditions, condition{key: m[1], value: value, equal: m[2] == "=="})
+		rest = rest[len(m[0]):]
+		if rest == "" {
+			return f, nil
+		}
+		if !strings.HasPrefix(rest, "&&") {
+			return nil, ErrInvalidFilter
+		}
+		rest = rest[2:]
+	}
+}
+
+// Match reports whether tags match filter. Missing tag has empty value.
+func (f *Filter) Match(tags map[string]string) bool {
+	for _, c := range f.conditions {
+		if (tags[c.key] == c.value) != c.equal {
+			return false
+		}
+	}
+	return true
+}
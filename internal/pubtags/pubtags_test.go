@@ -0,0 +1,44 @@
+package pubtags
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTags(t *testing.T) {
+	tags, ok := Tags([]byte(`{"id":"1","origin":{"type":"api"},"tags":{"region":"eu"},"data":{"a":1}}`))
+	require.True(t, ok)
+	require.Equal(t, map[string]string{"region": "eu"}, tags)
+	tags, ok = Tags([]byte(`{"data":{"a":1}}`))
+	require.True(t, ok)
+	require.Empty(t, tags)
+	_, ok = Tags([]byte(`"string"`))
+	require.False(t, ok)
+}
+
+func TestParseFilter(t *testing.T) {
+	f, err := ParseFilter(`tags.region == "eu" && tags.type != "debug"`)
+	require.NoError(t, err)
+	require.True(t, f.Match(map[string]string{"region": "eu"}))
+	require.True(t, f.Match(map[string]string{"region": "eu", "type": "info"}))
+	require.False(t, f.Match(map[string]string{"region": "eu", "type": "debug"}))
+	require.False(t, f.Match(map[string]string{"region": "us"}))
+	require.False(t, f.Match(nil))
+
+	f, err = ParseFilter(`tags.name=="a \"&&\" b"`)
+	require.NoError(t, err)
+	require.True(t, f.Match(map[string]string{"name": `a "&&" b`}))
+
+	for _, s := range []string{
+		"",
+		`tags.region`,
+		`region == "eu"`,
+		`tags.region == eu`,
+		`tags.region == "eu" &&`,
+		`tags.region == "eu" || tags.region == "us"`,
+	} {
+		_, err := ParseFilter(s)
+		require.Equal(t, ErrInvalidFilter, err, s)
+	}
+}
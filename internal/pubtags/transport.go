@@ -0,0 +1,106 @@
+package pubtags
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/protocol"
+)
+
+// Transport wraps centrifuge.Transport and drops publication pushes which do
+// not match filter set for channel. Replies to client commands (including
+// publications recovered on subscribe) are never filtered.
+type Transport struct {
+	centrifuge.Transport
+
+	mu      sync.RWMutex
+	filters map[string]*Filter
+}
+
+// NewTransport wraps transport to filter publications by tags. Original
+// transport returned if filters can't be used with transport: only
+// bidirectional transports with JSON protocol supported.
+func NewTransport(t centrifuge.Transport) centrifuge.Transport {
+	if t.Unidirectional() || t.Protocol() != centrifuge.ProtocolTypeJSON {
+		return t
+	}
+	return &Transport{
+		Transport: t,
+		filters:   map[string]*Filter{},
+	}
+}
+
+// SetFilter sets filter of publications in channel, nil Filter removes it.
+func (t *Transport) SetFilter(channel string, f *Filter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if f == nil {
+		delete(t.filters, channel)
+		return
+	}
+	t.filters[channel] = f
+}
+
+// Write data to transport.
+func (t *Transport) Write(data []byte) error {
+	if t.skip(data) {
+		return nil
+	}
+	return t.Transport.Write(data)
+}
+
+// WriteMany data to transport.
+func (t *Transport) WriteMany(messages ...[]byte) error {
+	filtered := messages[:0:0]
+	for _, data := range messages {
+		if !t.skip(data) {
+			filtered = append(filtered, data)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil
+	}
+	return t.Transport.WriteMany(filtered...)
+}
+
+type jsonReply struct {
+	ID     uint32          `json:"id"`
+	Result json.RawMessage `json:"result"`
+}
+
+// skip returns true for publication push which does not match filter of
+// channel.
+func (t *Transport) skip(data []byte) bool {
+	t.mu.RLock()
+	empty := len(t.filters) == 0
+	t.mu.RUnlock()
+	if empty {
+		return false
+	}
+
+	var reply jsonReply
+	if err := json.Unmarshal(data, &reply); err != nil || reply.ID > 0 {
+		return false
+	}
+	pushDecoder := protocol.NewJSONPushDecoder()
+	push, err := pushDecoder.Decode(reply.Result)
+	if err != nil || push.Type != protocol.Push_PUBLICATION {
+		return false
+	}
+	t.mu.RLock()
+	f, ok := t.filters[push.Channel]
+	t.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	pub, err := pushDecoder.DecodePublication(push.Data)
+	if err != nil {
+		return false
+	}
+	tags, ok := Tags(pub.Data)
+	if !ok {
+		return false
+	}
+	return !f.Match(tags)
+}
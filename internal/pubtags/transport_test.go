@@ -0,0 +1,73 @@
+package pubtags
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+type testTransport struct {
+	mu           sync.Mutex
+	unidirection bool
+	protocol     centrifuge.ProtocolType
+	messages     [][]byte
+}
+
+func (t *testTransport) Name() string                         { return "test" }
+func (t *testTransport) Protocol() centrifuge.ProtocolType    { return t.protocol }
+func (t *testTransport) Unidirectional() bool                 { return t.unidirection }
+func (t *testTransport) DisabledPushFlags() uint64            { return 0 }
+func (t *testTransport) Write(data []byte) error              { return t.WriteMany(data) }
+func (t *testTransport) Close(_ *centrifuge.Disconnect) error { return nil }
+
+func (t *testTransport) WriteMany(messages ...[]byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = append(t.messages, messages...)
+	return nil
+}
+
+func (t *testTransport) written() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var result []string
+	for _, m := range t.messages {
+		result = append(result, string(m))
+	}
+	return result
+}
+
+func TestNewTransportNotSupported(t *testing.T) {
+	inner := &testTransport{protocol: centrifuge.ProtocolTypeProtobuf}
+	require.Equal(t, inner, NewTransport(inner))
+	inner = &testTransport{protocol: centrifuge.ProtocolTypeJSON, unidirection: true}
+	require.Equal(t, inner, NewTransport(inner))
+}
+
+func TestTransport(t *testing.T) {
+	inner := &testTransport{protocol: centrifuge.ProtocolTypeJSON}
+	tr := NewTransport(inner).(*Transport)
+
+	eu := `{"result":{"channel":"tags","data":{"data":{"tags":{"region":"eu"},"data":1}}}}`
+	us := `{"result":{"channel":"tags","data":{"data":{"tags":{"region":"us"},"data":2}}}}`
+	other := `{"result":{"channel":"other","data":{"data":{"tags":{"region":"us"},"data":3}}}}`
+	reply := `{"id":1,"result":{}}`
+
+	require.NoError(t, tr.Write([]byte(us)))
+
+	f, err := ParseFilter(`tags.region == "eu"`)
+	require.NoError(t, err)
+	tr.SetFilter("tags", f)
+
+	require.NoError(t, tr.Write([]byte(eu)))
+	require.NoError(t, tr.Write([]byte(us)))
+	require.NoError(t, tr.WriteMany([]byte(us), []byte(other), []byte(reply)))
+	require.NoError(t, tr.WriteMany([]byte(us)))
+
+	tr.SetFilter("tags", nil)
+	require.NoError(t, tr.Write([]byte(us)))
+
+	require.Equal(t, []string{us, eu, other, reply, us}, inner.written())
+}
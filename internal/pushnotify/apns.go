@@ -0,0 +1,196 @@
+package pushnotify
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cristalhq/jwt/v3"
+)
+
+// APNs endpoints.
+const (
+	APNsProductionEndpoint  = "https://api.push.apple.com"
+	APNsDevelopmentEndpoint = "https://api.sandbox.push.apple.com"
+)
+
+// Provider token must be refreshed not more often than once in 20 minutes
+// and not less often than once in an hour.
+const apnsTokenRefreshInterval = 50 * time.Minute
+
+// APNsConfig of APNsSender.
+type APNsConfig struct {
+	// Key is a PEM encoded .p8 token signing key.
+	Key []byte
+	// KeyID of signing key.
+	KeyID string
+	// TeamID of Apple developer account.
+	TeamID string
+	// Topic is a bundle ID of application.
+	Topic string
+	// Endpoint of APNs, by default APNsProductionEndpoint used.
+	Endpoint string
+	// HTTPClient to send requests, by default client with 10 seconds timeout
+	// used. APNs only accepts HTTP/2 requests.
+	HTTPClient *http.Client
+}
+
+type apnsClaims struct {
+	Issuer   string `json:"iss"`
+	IssuedAt int64  `json:"iat"`
+}
+
+// APNsSender sends background notifications over APNs using token based
+// authentication.
+type APNsSender struct {
+	config   APNsConfig
+	endpoint string
+	client   *http.Client
+	builder  *jwt.Builder
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+// NewAPNsSender creates APNsSender.
+func NewAPNsSender(c APNsConfig) (*APNsSender, error) {
+	if c.KeyID == "" || c.TeamID == "" || c.Topic == "" {
+		return nil, errors.New("APNs key id, team id and topic required")
+	}
+	key, err := parseECPrivateKey(c.Key)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing APNs key: %w", err)
+	}
+	signer, err := jwt.NewSignerES(jwt.ES256, key)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = APNsProductionEndpoint
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &APNsSender{
+		config:   c,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   client,
+		builder:  jwt.NewBuilder(signer, jwt.WithKeyID(c.KeyID)),
+	}, nil
+}
+
+func parseECPrivateKey(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an ECDSA private key")
+	}
+	return key, nil
+}
+
+type apnsPayload struct {
+	APS  apnsAPS         `json:"aps"`
+	Data json.RawMessage `json:"data,omitempty"`
+	// B64Data contains base64 encoded data if it's not a valid JSON.
+	B64Data []byte `json:"b64data,omitempty"`
+}
+
+type apnsAPS struct {
+	ContentAvailable int `json:"content-available"`
+}
+
+type apnsErrorResponse struct {
+	Reason string `json:"reason"`
+}
+
+// Send background notification to device token. Publication data passed to
+// application in data field of payload.
+func (s *APNsSender) Send(ctx context.Context, token string, data []byte) error {
+	payload := apnsPayload{APS: apnsAPS{ContentAvailable: 1}}
+	if json.Valid(data) {
+		payload.Data = data
+	} else {
+		payload.B64Data = data
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	providerToken, err := s.providerToken()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/3/device/"+url.PathEscape(token), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+providerToken)
+	req.Header.Set("apns-topic", s.config.Topic)
+	req.Header.Set("apns-push-type", "background")
+	req.Header.Set("apns-priority", "5")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var errResp apnsErrorResponse
+	_ = json.Unmarshal(respBody, &errResp)
+	switch {
+	case resp.StatusCode == http.StatusGone,
+		errResp.Reason == "BadDeviceToken",
+		errResp.Reason == "Unregistered",
+		errResp.Reason == "DeviceTokenNotForTopic":
+		return ErrInvalidToken
+	case errResp.Reason == "ExpiredProviderToken":
+		s.mu.Lock()
+		s.token = ""
+		s.mu.Unlock()
+	}
+	return fmt.Errorf("unexpected APNs response status %d: %s", resp.StatusCode, respBody)
+}
+
+func (s *APNsSender) providerToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.token != "" && now.Sub(s.issuedAt) < apnsTokenRefreshInterval {
+		return s.token, nil
+	}
+	token, err := s.builder.Build(apnsClaims{Issuer: s.config.TeamID, IssuedAt: now.Unix()})
+	if err != nil {
+		return "", err
+	}
+	s.token = token.String()
+	s.issuedAt = now
+	return s.token, nil
+}
@@ -0,0 +1,255 @@
+package pushnotify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cristalhq/jwt/v3"
+)
+
+// DefaultFCMEndpoint is a default value for FCMConfig.Endpoint.
+const DefaultFCMEndpoint = "https://fcm.googleapis.com"
+
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// FCMConfig of FCMSender.
+type FCMConfig struct {
+	// ServiceAccount is a JSON key of Google service account allowed to send
+	// messages of Firebase project.
+	ServiceAccount []byte
+	// ProjectID of Firebase project, by default project of service account
+	// used.
+	ProjectID string
+	// Endpoint of FCM HTTP v1 API. By default DefaultFCMEndpoint used.
+	Endpoint string
+	// HTTPClient to send requests, by default client with 10 seconds timeout
+	// used.
+	HTTPClient *http.Client
+}
+
+type serviceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+type fcmAssertionClaims struct {
+	Issuer    string `json:"iss"`
+	Scope     string `json:"scope"`
+	Audience  string `json:"aud"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// FCMSender sends data messages over FCM HTTP v1 API. Access token obtained
+// from Google OAuth2 server with service account key and cached until it
+// expires.
+type FCMSender struct {
+	account   serviceAccount
+	projectID string
+	endpoint  string
+	client    *http.Client
+	builder   *jwt.Builder
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewFCMSender creates FCMSender.
+func NewFCMSender(c FCMConfig) (*FCMSender, error) {
+	var account serviceAccount
+	if err := json.Unmarshal(c.ServiceAccount, &account); err != nil {
+		return nil, fmt.Errorf("error decoding service account: %w", err)
+	}
+	if account.ClientEmail == "" || account.TokenURI == "" {
+		return nil, errors.New("service account must contain client_email and token_uri")
+	}
+	key, err := parseRSAPrivateKey([]byte(account.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing service account private key: %w", err)
+	}
+	signer, err := jwt.NewSignerRS(jwt.RS256, key)
+	if err != nil {
+		return nil, err
+	}
+	projectID := c.ProjectID
+	if projectID == "" {
+		projectID = account.ProjectID
+	}
+	if projectID == "" {
+		return nil, errors.New("no FCM project id")
+	}
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultFCMEndpoint
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &FCMSender{
+		account:   account,
+		projectID: projectID,
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		client:    client,
+		builder:   jwt.NewBuilder(signer),
+	}, nil
+}
+
+func parseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not a RSA private key")
+	}
+	return key, nil
+}
+
+type fcmMessage struct {
+	Message fcmMessageBody `json:"message"`
+}
+
+type fcmMessageBody struct {
+	Token string            `json:"token"`
+	Data  map[string]string `json:"data"`
+}
+
+type fcmErrorResponse struct {
+	Error struct {
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// invalidToken reports whether FCM error response means device token must be
+// removed. Status code alone is not enough: FCM returns 404 for other reasons
+// too, ex. for unknown project.
+func (r fcmErrorResponse) invalidToken() bool {
+	for _, d := range r.Error.Details {
+		if d.ErrorCode == "UNREGISTERED" || d.ErrorCode == "INVALID_ARGUMENT" {
+			return true
+		}
+	}
+	return false
+}
+
+// Send data message to device token. Publication data passed to application
+// in data field of message.
+func (s *FCMSender) Send(ctx context.Context, token string, data []byte) error {
+	accessToken, err := s.getAccessToken(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(fcmMessage{Message: fcmMessageBody{
+		Token: token,
+		Data:  map[string]string{"data": string(data)},
+	}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/v1/projects/"+url.PathEscape(s.projectID)+"/messages:send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var errResp fcmErrorResponse
+	_ = json.Unmarshal(respBody, &errResp)
+	switch {
+	case errResp.invalidToken():
+		return ErrInvalidToken
+	case resp.StatusCode == http.StatusUnauthorized:
+		// Force access token refresh on next send.
+		s.mu.Lock()
+		s.accessToken = ""
+		s.mu.Unlock()
+	}
+	return fmt.Errorf("unexpected FCM response status %d: %s", resp.StatusCode, respBody)
+}
+
+type oauthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (s *FCMSender) getAccessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.accessToken != "" && now.Before(s.expiresAt) {
+		return s.accessToken, nil
+	}
+	assertion, err := s.builder.Build(fcmAssertionClaims{
+		Issuer:    s.account.ClientEmail,
+		Scope:     fcmScope,
+		Audience:  s.account.TokenURI,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return "", fmt.Errorf("unexpected OAuth2 token response status %d: %s", resp.StatusCode, respBody)
+	}
+	var token oauthTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("no access token in OAuth2 token response")
+	}
+	s.accessToken = token.AccessToken
+	// Refresh token a minute before it expires.
+	s.expiresAt = now.Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return s.accessToken, nil
+}
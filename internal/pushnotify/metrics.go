@@ -0,0 +1,27 @@
+package pushnotify
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricsNamespace = "centrifugo"
+
+var (
+	notificationsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "push_notifications",
+		Name:      "sent_total",
+		Help:      "Number of push notifications sent to devices by platform and result.",
+	}, []string{"platform", "result"})
+	notificationsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "push_notifications",
+		Name:      "dropped_total",
+		Help:      "Number of push notifications dropped due to full queue.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(notificationsSent)
+	prometheus.MustRegister(notificationsDropped)
+}
@@ -0,0 +1,163 @@
+// Package pushnotify sends publications to personal channel of offline user
+// as push notifications to mobile devices registered by user. Devices are
+// registered over server API, notifications are sent over FCM or APNs.
+package pushnotify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// Supported platforms of devices.
+const (
+	PlatformFCM  = "fcm"
+	PlatformAPNs = "apns"
+)
+
+// DefaultQueueSize is a default value for Config.QueueSize.
+const DefaultQueueSize = 4096
+
+// DefaultWorkers is a default value for Config.Workers.
+const DefaultWorkers = 4
+
+// ErrInvalidToken returned by Sender when device token is not valid anymore,
+// device removed from store in this case.
+var ErrInvalidToken = errors.New("invalid device token")
+
+// Device of user.
+type Device struct {
+	Platform string
+	Token    string
+}
+
+// Validate device.
+func (d Device) Validate() error {
+	if d.Platform != PlatformFCM && d.Platform != PlatformAPNs {
+		return fmt.Errorf("unknown platform: %s", d.Platform)
+	}
+	if d.Token == "" {
+		return errors.New("empty device token")
+	}
+	return nil
+}
+
+// DeviceStore keeps devices of users.
+type DeviceStore interface {
+	// AddDevice registers device of user. Adding already registered device
+	// is not an error.
+	AddDevice(user string, d Device) error
+	// RemoveDevice unregisters device of user.
+	RemoveDevice(user string, d Device) error
+	// Devices returns all registered devices of user.
+	Devices(user string) ([]Device, error)
+}
+
+// Sender sends push notification with data to device token.
+type Sender interface {
+	Send(ctx context.Context, token string, data []byte) error
+}
+
+// Config of Notifier.
+type Config struct {
+	Store DeviceStore
+	// Senders by platform. Devices of platforms without Sender skipped.
+	Senders map[string]Sender
+	// QueueSize is a max number of notifications waiting to be sent,
+	// notifications are dropped when queue is full. By default
+	// DefaultQueueSize used.
+	QueueSize int
+	// Workers is a number of goroutines sending notifications. By default
+	// DefaultWorkers used.
+	Workers int
+}
+
+type notification struct {
+	user string
+	data []byte
+}
+
+// Notifier sends push notifications to devices of users in background.
+type Notifier struct {
+	node   *centrifuge.Node
+	config Config
+	queue  chan notification
+}
+
+// NewNotifier creates Notifier.
+func NewNotifier(node *centrifuge.Node, c Config) *Notifier {
+	if c.QueueSize == 0 {
+		c.QueueSize = DefaultQueueSize
+	}
+	if c.Workers == 0 {
+		c.Workers = DefaultWorkers
+	}
+	return &Notifier{
+		node:   node,
+		config: c,
+		queue:  make(chan notification, c.QueueSize),
+	}
+}
+
+// Store returns DeviceStore of Notifier.
+func (n *Notifier) Store() DeviceStore {
+	return n.config.Store
+}
+
+// Run starts sending workers and blocks until ctx done.
+func (n *Notifier) Run(ctx context.Context) {
+	for i := 0; i < n.config.Workers; i++ {
+		go n.work(ctx)
+	}
+	<-ctx.Done()
+}
+
+// Notify queues notification with data to all devices of user. Never blocks,
+// notification is dropped if queue is full.
+func (n *Notifier) Notify(user string, data []byte) {
+	select {
+	case n.queue <- notification{user: user, data: data}:
+	default:
+		notificationsDropped.Inc()
+	}
+}
+
+func (n *Notifier) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case nt := <-n.queue:
+			n.send(ctx, nt)
+		}
+	}
+}
+
+func (n *Notifier) send(ctx context.Context, nt notification) {
+	devices, err := n.config.Store.Devices(nt.user)
+	if err != nil {
+		n.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error getting push devices of user", map[string]interface{}{"user": nt.user, "error": err.Error()}))
+		return
+	}
+	for _, d := range devices {
+		sender, ok := n.config.Senders[d.Platform]
+		if !ok {
+			continue
+		}
+		err := sender.Send(ctx, d.Token, nt.data)
+		switch {
+		case err == nil:
+			notificationsSent.WithLabelValues(d.Platform, "ok").Inc()
+		case errors.Is(err, ErrInvalidToken):
+			notificationsSent.WithLabelValues(d.Platform, "invalid_token").Inc()
+			if err := n.config.Store.RemoveDevice(nt.user, d); err != nil {
+				n.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error removing invalid push device", map[string]interface{}{"user": nt.user, "platform": d.Platform, "error": err.Error()}))
+			}
+		default:
+			notificationsSent.WithLabelValues(d.Platform, "error").Inc()
+			n.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error sending push notification", map[string]interface{}{"user": nt.user, "platform": d.Platform, "error": err.Error()}))
+		}
+	}
+}
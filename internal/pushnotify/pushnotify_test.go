@@ -0,0 +1,219 @@
+package pushnotify
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/cristalhq/jwt/v3"
+	"github.com/stretchr/testify/require"
+)
+
+type testStore struct {
+	mu      sync.Mutex
+	devices map[string][]Device
+}
+
+func (s *testStore) AddDevice(user string, d Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.devices[user] = append(s.devices[user], d)
+	return nil
+}
+
+func (s *testStore) RemoveDevice(user string, d Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var devices []Device
+	for _, existing := range s.devices[user] {
+		if existing != d {
+			devices = append(devices, existing)
+		}
+	}
+	s.devices[user] = devices
+	return nil
+}
+
+func (s *testStore) Devices(user string) ([]Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Device(nil), s.devices[user]...), nil
+}
+
+type testSender struct {
+	mu    sync.Mutex
+	sent  []string
+	errFn func(token string) error
+}
+
+func (s *testSender) Send(_ context.Context, token string, _ []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, token)
+	return s.errFn(token)
+}
+
+func (s *testSender) numSent() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sent)
+}
+
+func TestDeviceValidate(t *testing.T) {
+	require.NoError(t, Device{Platform: PlatformFCM, Token: "t"}.Validate())
+	require.NoError(t, Device{Platform: PlatformAPNs, Token: "t"}.Validate())
+	require.Error(t, Device{Platform: "web", Token: "t"}.Validate())
+	require.Error(t, Device{Platform: PlatformFCM}.Validate())
+}
+
+func TestNotifierRemovesInvalidTokens(t *testing.T) {
+	node, err := centrifuge.New(centrifuge.DefaultConfig)
+	require.NoError(t, err)
+	store := &testStore{devices: map[string][]Device{
+		"42": {{Platform: PlatformFCM, Token: "valid"}, {Platform: PlatformFCM, Token: "invalid"}, {Platform: PlatformAPNs, Token: "apns"}},
+	}}
+	sender := &testSender{errFn: func(token string) error {
+		if token == "invalid" {
+			return ErrInvalidToken
+		}
+		return nil
+	}}
+	n := NewNotifier(node, Config{Store: store, Senders: map[string]Sender{PlatformFCM: sender}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+
+	n.Notify("42", []byte(`{}`))
+	require.Eventually(t, func() bool {
+		return sender.numSent() == 2
+	}, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool {
+		devices, _ := store.Devices("42")
+		return len(devices) == 2
+	}, time.Second, 5*time.Millisecond)
+	devices, _ := store.Devices("42")
+	require.Equal(t, []Device{{Platform: PlatformFCM, Token: "valid"}, {Platform: PlatformAPNs, Token: "apns"}}, devices)
+}
+
+func TestFCMSender(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	var numTokenRequests int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		numTokenRequests++
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.PostForm.Get("grant_type"))
+		verifier, err := jwt.NewVerifierRS(jwt.RS256, &key.PublicKey)
+		require.NoError(t, err)
+		token, err := jwt.ParseAndVerifyString(r.PostForm.Get("assertion"), verifier)
+		require.NoError(t, err)
+		var claims fcmAssertionClaims
+		require.NoError(t, json.Unmarshal(token.RawClaims(), &claims))
+		require.Equal(t, "sender@example.com", claims.Issuer)
+		require.Equal(t, fcmScope, claims.Scope)
+		_, _ = w.Write([]byte(`{"access_token":"access","expires_in":3600}`))
+	})
+	mux.HandleFunc("/v1/projects/project/messages:send", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer access", r.Header.Get("Authorization"))
+		var msg fcmMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		switch msg.Message.Token {
+		case "unregistered":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"status":"NOT_FOUND","details":[{"errorCode":"UNREGISTERED"}]}}`))
+			return
+		case "invalid":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"status":"INVALID_ARGUMENT","details":[{"errorCode":"INVALID_ARGUMENT"}]}}`))
+			return
+		case "not_found":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"status":"NOT_FOUND","message":"Requested entity was not found."}}`))
+			return
+		}
+		require.Equal(t, "token", msg.Message.Token)
+		require.Equal(t, `{"text":"hi"}`, msg.Message.Data["data"])
+		_, _ = w.Write([]byte(`{"name":"projects/project/messages/1"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	serviceAccount, err := json.Marshal(serviceAccount{
+		ProjectID:   "project",
+		ClientEmail: "sender@example.com",
+		PrivateKey:  string(keyPEM),
+		TokenURI:    server.URL + "/token",
+	})
+	require.NoError(t, err)
+	s, err := NewFCMSender(FCMConfig{ServiceAccount: serviceAccount, Endpoint: server.URL})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Send(context.Background(), "token", []byte(`{"text":"hi"}`)))
+	require.NoError(t, s.Send(context.Background(), "token", []byte(`{"text":"hi"}`)))
+	require.True(t, errors.Is(s.Send(context.Background(), "unregistered", []byte(`{}`)), ErrInvalidToken))
+	require.True(t, errors.Is(s.Send(context.Background(), "invalid", []byte(`{}`)), ErrInvalidToken))
+	err = s.Send(context.Background(), "not_found", []byte(`{}`))
+	require.Error(t, err)
+	require.False(t, errors.Is(err, ErrInvalidToken), "404 without error code must not remove device")
+	// Access token cached.
+	require.Equal(t, 1, numTokenRequests)
+}
+
+func TestAPNsSender(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "com.example.app", r.Header.Get("apns-topic"))
+		require.Equal(t, "background", r.Header.Get("apns-push-type"))
+		verifier, err := jwt.NewVerifierES(jwt.ES256, &key.PublicKey)
+		require.NoError(t, err)
+		token, err := jwt.ParseAndVerifyString(r.Header.Get("Authorization")[len("bearer "):], verifier)
+		require.NoError(t, err)
+		require.Equal(t, "KEY", token.Header().KeyID)
+		switch r.URL.EscapedPath() {
+		case "/3/device/token":
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.JSONEq(t, `{"aps":{"content-available":1},"data":{"text":"hi"}}`, string(body))
+		case "/3/device/gone":
+			w.WriteHeader(http.StatusGone)
+			_, _ = w.Write([]byte(`{"reason":"Unregistered"}`))
+		case "/3/device/..%2Fescaped":
+			w.WriteHeader(http.StatusGone)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"reason":"BadTopic"}`))
+		}
+	}))
+	defer server.Close()
+
+	s, err := NewAPNsSender(APNsConfig{Key: keyPEM, KeyID: "KEY", TeamID: "TEAM", Topic: "com.example.app", Endpoint: server.URL})
+	require.NoError(t, err)
+	require.NoError(t, s.Send(context.Background(), "token", []byte(`{"text":"hi"}`)))
+	require.True(t, errors.Is(s.Send(context.Background(), "gone", []byte(`{}`)), ErrInvalidToken))
+	// Token escaped in URL path.
+	require.True(t, errors.Is(s.Send(context.Background(), "../escaped", []byte(`{}`)), ErrInvalidToken))
+	err = s.Send(context.Background(), "other", []byte(`{}`))
+	require.Error(t, err)
+	require.False(t, errors.Is(err, ErrInvalidToken))
+}
@@ -0,0 +1,252 @@
+package redisengine
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/gomodule/redigo/redis"
+	"github.com/mna/redisc"
+)
+
+const (
+	// DefaultAPIQueueName is a name of queue used when no queues configured.
+	DefaultAPIQueueName = "default"
+	// apiQueueBlockTimeout is a timeout (in seconds) of BLPOP waiting for
+	// commands in empty queues. Must be less than PUB/SUB read timeout as BLPOP
+	// uses dedicated connection.
+	apiQueueBlockTimeout = 1
+)
+
+// lpopManySource contains Lua script to pop up to ARGV[1] commands from queue.
+// KEYS[1] - queue list key
+// ARGV[1] - maximum number of commands to pop
+const lpopManySource = `
+local entries = redis.call("lrange", KEYS[1], 0, ARGV[1] - 1)
+if #entries > 0 then
+  redis.call("ltrim", KEYS[1], #entries, -1)
+end
+return entries
+`
+
+// APIQueueConfig describes Redis LIST queue commands are consumed from.
+type APIQueueConfig struct {
+	// Name of queue, commands must be pushed to LIST with key
+	// "<prefix>.api.<name>" ("<prefix>.{api}.<name>" in Redis Cluster).
+	Name string `mapstructure:"name" json:"name"`
+	// Priority of queue. Commands from queue with higher priority always
+	// consumed first, queue with lower priority only consumed when all queues
+	// with higher priority are empty or have no free workers.
+	Priority int `mapstructure:"priority" json:"priority"`
+	// Concurrency is a number of commands from queue processed concurrently.
+	// Default is 1 which means commands processed in order they were pushed.
+	Concurrency int `mapstructure:"concurrency" json:"concurrency"`
+}
+
+// APICommandHandler processes data popped from queue. Data has the same
+// format as HTTP API request body.
+type APICommandHandler func(ctx context.Context, data []byte) error
+
+// APIConsumerConfig is a config for APIConsumer.
+type APIConsumerConfig struct {
+	// Queues to consume. If not set then single queue named DefaultAPIQueueName
+	// used.
+	Queues []APIQueueConfig
+	// Handler for commands.
+	Handler APICommandHandler
+}
+
+// APIConsumer consumes API commands from Redis queues on every Redis shard.
+type APIConsumer struct {
+	node           *centrifuge.Node
+	broker         *Broker
+	config         APIConsumerConfig
+	lpopManyScript *redis.Script
+}
+
+// NewAPIConsumer creates APIConsumer which uses shards and prefix of Broker.
+func NewAPIConsumer(n *centrifuge.Node, b *Broker, config APIConsumerConfig) (*APIConsumer, error) {
+	if config.Handler == nil {
+		return nil, errors.New("api consumer: no command handler provided")
+	}
+	if len(config.Queues) == 0 {
+		config.Queues = []APIQueueConfig{{Name: DefaultAPIQueueName}}
+	}
+	seen := map[string]struct{}{}
+	for _, q := range config.Queues {
+		if q.Name == "" {
+			return nil, errors.New("api consumer: queue name required")
+		}
+		if _, ok := seen[q.Name]; ok {
+			return nil, errors.New("api consumer: duplicate queue name: " + q.Name)
+		}
+		seen[q.Name] = struct{}{}
+	}
+	c := &APIConsumer{
+		node:           n,
+		broker:         b,
+		config:         config,
+		lpopManyScript: redis.NewScript(1, lpopManySource),
+	}
+	b.registerScripts(c.lpopManyScript)
+	return c, nil
+}
+
+// Run starts consuming queues on all shards. Queues on shards added later
+// with Broker.AddShard are consumed too.
+func (c *APIConsumer) Run() {
+	c.broker.onShards(func(s *Shard) {
+		queues := c.newQueues(s)
+		go runForever(func() {
+			c.runShard(s, queues)
+		})
+	})
+}
+
+type apiQueue struct {
+	key     string
+	workers chan struct{}
+}
+
+// newQueues creates queue states for a shard sorted by priority.
+func (c *APIConsumer) newQueues(s *Shard) []*apiQueue {
+	configs := make([]APIQueueConfig, len(c.config.Queues))
+	copy(configs, c.config.Queues)
+	sort.SliceStable(configs, func(i, j int) bool {
+		return configs[i].Priority > configs[j].Priority
+	})
+	queues := make([]*apiQueue, 0, len(configs))
+	for _, q := range configs {
+		concurrency := q.Concurrency
+		if concurrency <= 0 {
+			concurrency = 1
+		}
+		queues = append(queues, &apiQueue{
+			key:     c.queueKey(s, q.Name),
+			workers: make(chan struct{}, concurrency),
+		})
+	}
+	return queues
+}
+
+func (c *APIConsumer) queueKey(s *Shard, name string) string {
+	if s.useCluster {
+		// All queue keys must belong to one slot to be used in BLPOP together.
+		return c.broker.config.Prefix + ".{api}." + name
+	}
+	return c.broker.config.Prefix + ".api." + name
+}
+
+func (c *APIConsumer) runShard(s *Shard, queues []*apiQueue) {
+	// released is notified when some worker finished processing command.
+	released := make(chan struct{}, 1)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	process := func(q *apiQueue, data []byte) {
+		q.workers <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				<-q.workers
+				select {
+				case released <- struct{}{}:
+				default:
+				}
+			}()
+			if err := c.config.Handler(context.Background(), data); err != nil {
+				c.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error processing API command from Redis queue", map[string]interface{}{"error": err.Error(), "queue": q.key}))
+			}
+		}()
+	}
+
+	for {
+		fetched, err := c.fetch(s, queues, process)
+		if err != nil {
+			c.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error fetching API commands from Redis", map[string]interface{}{"error": err.Error(), "shard": s.string()}))
+			return
+		}
+		if fetched {
+			continue
+		}
+		var keys []interface{}
+		keyQueues := map[string]*apiQueue{}
+		for _, q := range queues {
+			if len(q.workers) < cap(q.workers) {
+				keys = append(keys, q.key)
+				keyQueues[q.key] = q
+			}
+		}
+		if len(keys) == 0 {
+			// All workers busy – wait for one to finish.
+			select {
+			case <-released:
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		// Nothing in queues with free workers, wait for a command to appear in any
+		// of them. BLPOP checks keys in order so priority is preserved.
+		key, data, err := c.blockingPop(s, keys)
+		if err != nil {
+			c.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error waiting for API commands from Redis", map[string]interface{}{"error": err.Error(), "shard": s.string()}))
+			return
+		}
+		if data != nil {
+			process(keyQueues[key], data)
+		}
+	}
+}
+
+// fetch pops commands from queue with the highest priority which has commands
+// and free workers. Returns true if something was fetched.
+func (c *APIConsumer) fetch(s *Shard, queues []*apiQueue, process func(q *apiQueue, data []byte)) (bool, error) {
+	for _, q := range queues {
+		free := cap(q.workers) - len(q.workers)
+		if free == 0 {
+			continue
+		}
+		dr := s.newDataRequest("", c.lpopManyScript, channelID(q.key), []interface{}{q.key, free})
+		resp := s.getDataResponse(dr)
+		if resp.err != nil {
+			return false, resp.err
+		}
+		entries, err := redis.ByteSlices(resp.reply, nil)
+		if err != nil {
+			return false, err
+		}
+		for _, data := range entries {
+			process(q, data)
+		}
+		if len(entries) > 0 {
+			// Start from the highest priority queue again.
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *APIConsumer) blockingPop(s *Shard, keys []interface{}) (string, []byte, error) {
+	conn := s.pubSubPool.Get()
+	defer func() { _ = conn.Close() }()
+	if cc, ok := conn.(*redisc.Conn); ok {
+		if err := cc.Bind(keys[0].(string)); err != nil {
+			return "", nil, err
+		}
+	}
+	reply, err := redis.ByteSlices(conn.Do("BLPOP", append(keys, apiQueueBlockTimeout)...))
+	if err != nil {
+		if err == redis.ErrNil {
+			return "", nil, nil
+		}
+		return "", nil, err
+	}
+	if len(reply) != 2 {
+		return "", nil, errors.New("unexpected BLPOP reply")
+	}
+	return string(reply[0]), reply[1], nil
+}
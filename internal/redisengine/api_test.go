@@ -0,0 +1,49 @@
+package redisengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func noopAPICommandHandler(_ context.Context, _ []byte) error {
+	return nil
+}
+
+func TestAPIConsumerQueues(t *testing.T) {
+	n, _ := centrifuge.New(centrifuge.DefaultConfig)
+	b := &Broker{config: BrokerConfig{Prefix: "test"}}
+
+	_, err := NewAPIConsumer(n, b, APIConsumerConfig{})
+	require.Error(t, err)
+	_, err = NewAPIConsumer(n, b, APIConsumerConfig{
+		Queues:  []APIQueueConfig{{Name: "a"}, {Name: "a"}},
+		Handler: noopAPICommandHandler,
+	})
+	require.Error(t, err)
+
+	c, err := NewAPIConsumer(n, b, APIConsumerConfig{Handler: noopAPICommandHandler})
+	require.NoError(t, err)
+	queues := c.newQueues(&Shard{})
+	require.Len(t, queues, 1)
+	require.Equal(t, "test.api.default", queues[0].key)
+	require.Equal(t, 1, cap(queues[0].workers))
+
+	c, err = NewAPIConsumer(n, b, APIConsumerConfig{
+		Queues: []APIQueueConfig{
+			{Name: "bulk", Concurrency: 4},
+			{Name: "publish", Priority: 10},
+			{Name: "other"},
+		},
+		Handler: noopAPICommandHandler,
+	})
+	require.NoError(t, err)
+	queues = c.newQueues(&Shard{useCluster: true})
+	require.Len(t, queues, 3)
+	require.Equal(t, "test.{api}.publish", queues[0].key)
+	require.Equal(t, "test.{api}.bulk", queues[1].key)
+	require.Equal(t, 4, cap(queues[1].workers))
+	require.Equal(t, "test.{api}.other", queues[2].key)
+}
@@ -0,0 +1,91 @@
+package redisengine
+
+import (
+	"errors"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/gomodule/redigo/redis"
+)
+
+// DefaultAuditMaxLength is a default max number of records kept in audit list.
+const DefaultAuditMaxLength = 100000
+
+const (
+	// Append records to audit list and apply retention.
+	// KEYS[1] - audit list key
+	// ARGV[1] - max list length, 0 means no limit
+	// ARGV[2] - list TTL in seconds, 0 means no expiration
+	// ARGV[3:] - records
+	auditAppendSource = `
+redis.call("rpush", KEYS[1], unpack(ARGV, 3))
+if ARGV[1] ~= '0' then
+  redis.call("ltrim", KEYS[1], -tonumber(ARGV[1]), -1)
+end
+if ARGV[2] ~= '0' then
+  redis.call("expire", KEYS[1], ARGV[2])
+end
+return 1
+	`
+)
+
+// AuditLogConfig of AuditLog.
+type AuditLogConfig struct {
+	// MaxLength is a max number of records in list, the oldest records removed.
+	// By default DefaultAuditMaxLength used.
+	MaxLength int
+	// TTL of list, extended on every write. Zero means list never expires.
+	TTL time.Duration
+}
+
+// AuditLog keeps API audit records in Redis LIST with key "<prefix>.audit"
+// ("<prefix>.{audit}" in Redis Cluster) on one shard of Broker, oldest first.
+type AuditLog struct {
+	node         *centrifuge.Node
+	broker       *Broker
+	config       AuditLogConfig
+	appendScript *redis.Script
+}
+
+// NewAuditLog creates AuditLog which uses shards and prefix of Broker.
+func NewAuditLog(n *centrifuge.Node, b *Broker, config AuditLogConfig) (*AuditLog, error) {
+	if b == nil {
+		return nil, errors.New("audit log: no broker provided")
+	}
+	if config.MaxLength == 0 {
+		config.MaxLength = DefaultAuditMaxLength
+	}
+	l := &AuditLog{
+		node:         n,
+		broker:       b,
+		config:       config,
+		appendScript: redis.NewScript(1, auditAppendSource),
+	}
+	b.registerScripts(l.appendScript)
+	return l, nil
+}
+
+// Write appends records to audit list.
+func (l *AuditLog) Write(entries [][]byte) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	s := l.broker.getShard("audit")
+	key := l.auditKey(s)
+	args := make([]interface{}, 0, len(entries)+3)
+	args = append(args, key, l.config.MaxLength, int64(l.config.TTL.Seconds()))
+	for _, e := range entries {
+		args = append(args, e)
+	}
+	dr := s.newDataRequest("", l.appendScript, key, args)
+	resp := s.getDataResponse(dr)
+	return resp.err
+}
+
+func (l *AuditLog) auditKey(s *Shard) channelID {
+	name := "audit"
+	if s.useCluster {
+		name = "{" + name + "}"
+	}
+	return channelID(l.broker.config.Prefix + "." + name)
+}
@@ -0,0 +1,1665 @@
+package redisengine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+
+	"github.com/centrifugal/protocol"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// redisPubSubWorkerChannelSize sets buffer size of channel to which we send all
+	// messages received from Redis PUB/SUB connection to process in separate goroutine.
+	redisPubSubWorkerChannelSize = 512
+	// redisSubscribeBatchLimit is a maximum number of channels to include in a single
+	// batch subscribe call.
+	redisSubscribeBatchLimit = 512
+	// redisPublishBatchLimit is a maximum limit of publish requests one batched publish
+	// operation can contain.
+	redisPublishBatchLimit = 512
+	// redisPriorityPublishBatchLimit is a maximum limit of publish requests one
+	// batched publish operation of priority lane can contain. Smaller batches
+	// keep latency of priority publications low.
+	redisPriorityPublishBatchLimit = 32
+	// redisControlChannelSuffix is a suffix for control channel.
+	redisControlChannelSuffix = ".control"
+	// redisNodeChannelPrefix is a suffix for node channel.
+	redisNodeChannelPrefix = ".node."
+	// redisPingChannelSuffix is a suffix for ping channel.
+	redisPingChannelSuffix = ".ping"
+	// redisClientChannelPrefix is a prefix before channel name for client messages.
+	redisClientChannelPrefix = ".client."
+)
+
+var _ centrifuge.Broker = (*Broker)(nil)
+
+// Broker uses Redis to implement Broker functionality. This broker allows
+// scaling Centrifuge-based server to many instances and load balance client
+// connections between them.
+// Broker additionally supports Redis Sentinel, client-side consistent sharding
+// and can work with Redis Cluster (including client-side sharding between different
+// Redis Clusters to scale PUB/SUB).
+// By default Redis >= 5 required (due to the fact Broker uses STREAM data structure).
+type Broker struct {
+	controlRound           uint64 // Keep atomic on struct top for 32-bit architectures.
+	node                   *centrifuge.Node
+	config                 BrokerConfig
+	shardsMu               sync.RWMutex
+	sharding               bool
+	shards                 []*Shard
+	shardHandlers          []func(s *Shard)
+	addShardMu             sync.Mutex
+	eventHandler           centrifuge.BrokerEventHandler
+	epochBumpScript        *redis.Script
+	historyListScript      *redis.Script
+	historyStreamScript    *redis.Script
+	addHistoryListScript   *redis.Script
+	addHistoryStreamScript *redis.Script
+	historyFromTimeScript  *redis.Script
+	historyMetaScript      *redis.Script
+	markCompactionScript   *redis.Script
+	compactStreamScript    *redis.Script
+	orderingSeqScript      *redis.Script
+	ordering               *orderingChecker
+	messagePrefix          string
+	pingChannel            string
+	controlChannel         string
+	nodeChannel            string
+}
+
+// DefaultPrefix is a default value for BrokerConfig.Prefix.
+const DefaultPrefix = "centrifuge"
+
+type BrokerConfig struct {
+	// Prefix to use before every channel name and key in Redis. By default
+	// DefaultPrefix will be used.
+	Prefix string
+
+	// KeyPrefix allows to use custom prefix for history keys of channel. This
+	// is useful to isolate keys of different applications using the same Redis.
+	// By default Prefix used for all channels. PUB/SUB channels always use Prefix.
+	KeyPrefix func(ch string) string
+
+	// HistoryMetaTTL sets a time of stream meta key expiration in Redis. Stream
+	// meta key is a Redis HASH that contains top offset in channel and epoch value.
+	// By default stream meta keys do not expire.
+	//
+	// Though in some cases – when channels created for а short time and then
+	// not used anymore – created stream meta keys can stay in memory while
+	// not actually useful. For example you can have a personal user channel but
+	// after using your app for a while user left it forever. In long-term
+	// perspective this can be an unwanted memory leak. Setting a reasonable
+	// value to this option (usually much bigger than history retention period)
+	// can help. In this case unused channel stream meta data will eventually expire.
+	//
+	// TODO v1: since we have epoch, things should also properly work without meta
+	// information at all (but we loose possibility of long-term recover in stream
+	// without new messages). We can make this optional and disabled by default at
+	// least.
+	HistoryMetaTTL time.Duration
+
+	// UseLists allows enabling usage of Redis LIST instead of STREAM data
+	// structure to keep history. LIST support exist mostly for backward
+	// compatibility since STREAM seems superior. If you have a use case
+	// where you need to turn on this option in new setup - please share,
+	// otherwise LIST support can be removed at some point in the future.
+	// Iteration over history in reversed order not supported with lists.
+	UseLists bool
+
+	// PubSubNumWorkers sets how many PUB/SUB message processing workers will
+	// be started. By default runtime.NumCPU() workers used.
+	PubSubNumWorkers int
+
+	// PubSubMaxWorkers if greater than PubSubNumWorkers allows PUB/SUB worker
+	// pool to autoscale: new workers started when queues of all workers have
+	// messages and idle workers stopped until PubSubNumWorkers left.
+	PubSubMaxWorkers int
+
+	// PubSubHotChannelThreshold is a number of messages per second starting
+	// from which channel considered hot. Messages of hot channel decoded by
+	// all PUB/SUB workers concurrently and passed to node in order, instead of
+	// being processed by one worker. Zero value turns off hot channel detection.
+	PubSubHotChannelThreshold int
+
+	// PubSubResubscribeConcurrency is a number of channel batches sent for
+	// subscription concurrently when PUB/SUB connection (re)established. By
+	// default DefaultPubSubResubscribeConcurrency used.
+	PubSubResubscribeConcurrency int
+
+	// Shards is a list of Redis shards to use. At least one shard must be provided.
+	Shards []*Shard
+
+	// ShardMigrationDelay is a time to keep PUB/SUB subscriptions to channels
+	// on previous shards after new shard added with AddShard. Other nodes can
+	// still publish to previous shards until new shard added on them too. By
+	// default DefaultShardMigrationDelay used.
+	ShardMigrationDelay time.Duration
+
+	// HistoryCompactionInterval is an interval between rounds of removing
+	// publications superseded by compaction key (see CompactHistory) from
+	// history streams. By default DefaultHistoryCompactionInterval used.
+	HistoryCompactionInterval time.Duration
+
+	// OrderingCheck turns on per-channel ordering verification. Publications
+	// without history stamped with per-channel sequence (kept in Redis key
+	// which expires according to HistoryMetaTTL), publications with history
+	// already have stream offset. Node detects gaps and reorders of received
+	// publications, logs them and counts in metrics. Sequence is only used
+	// by the check, subscribers get such publications without offset.
+	//
+	// This reduces throughput of publications without history: instead of
+	// batched PUBLISH over publish pipeline every publication is a Lua script
+	// call (INCR, EXPIRE and PUBLISH) over data pipeline, and priority lane
+	// (see IsPriorityChannel) is not used. Meant for diagnostics rather than
+	// for always-on use under high publish rate.
+	OrderingCheck bool
+
+	// IsPriorityChannel if set marks channels which publications are sent to
+	// Redis over separate priority lane – a publish pipeline with smaller
+	// batches which never waits for more requests, so publications of such
+	// channels are not delayed by bulk traffic. Only publications without
+	// history use publish pipeline, publications with history (and all
+	// publications with OrderingCheck on) are sent with Lua script over data
+	// pipeline and are not affected.
+	IsPriorityChannel func(ch string) bool
+}
+
+// NewBroker initializes Redis Broker.
+func NewBroker(n *centrifuge.Node, config BrokerConfig) (*Broker, error) {
+	if len(config.Shards) == 0 {
+		return nil, errors.New("broker: no Redis shards provided in configuration")
+	}
+
+	if len(config.Shards) > 1 {
+		n.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, fmt.Sprintf("broker: Redis sharding enabled: %d shards", len(config.Shards))))
+	}
+
+	if config.Prefix == "" {
+		config.Prefix = DefaultPrefix
+	}
+
+	if config.ShardMigrationDelay == 0 {
+		config.ShardMigrationDelay = DefaultShardMigrationDelay
+	}
+
+	if config.HistoryCompactionInterval == 0 {
+		config.HistoryCompactionInterval = DefaultHistoryCompactionInterval
+	}
+
+	b := &Broker{
+		node:                   n,
+		shards:                 config.Shards,
+		config:                 config,
+		sharding:               len(config.Shards) > 1,
+		historyListScript:      redis.NewScript(2, historyListSource),
+		historyStreamScript:    redis.NewScript(2, historyStreamSource),
+		addHistoryListScript:   redis.NewScript(2, addHistorySource),
+		addHistoryStreamScript: redis.NewScript(2, addHistoryStreamSource),
+		historyFromTimeScript:  redis.NewScript(2, historyStreamFromTimeSource),
+		historyMetaScript:      redis.NewScript(2, historyMetaSource),
+		markCompactionScript:   redis.NewScript(3, markCompactionSource),
+		compactStreamScript:    redis.NewScript(2, compactStreamSource),
+		epochBumpScript:        redis.NewScript(2, epochBumpSource),
+		orderingSeqScript:      redis.NewScript(1, orderingSeqSource),
+	}
+
+	b.registerScripts(
+		b.historyListScript,
+		b.historyStreamScript,
+		b.addHistoryListScript,
+		b.addHistoryStreamScript,
+		b.historyFromTimeScript,
+		b.historyMetaScript,
+		b.markCompactionScript,
+		b.compactStreamScript,
+	)
+
+	if config.OrderingCheck {
+		b.ordering = newOrderingChecker()
+		b.registerScripts(b.orderingSeqScript)
+	}
+
+	b.messagePrefix = config.Prefix + redisClientChannelPrefix
+	b.pingChannel = config.Prefix + redisPingChannelSuffix
+	b.nodeChannel = string(b.nodeChannelID(n.ID()))
+	b.controlChannel = config.Prefix + redisControlChannelSuffix
+	return b, nil
+}
+
+const (
+	// Add to history and optionally publish.
+	// KEYS[1] - history list key
+	// KEYS[2] - sequence meta hash key
+	// ARGV[1] - message payload
+	// ARGV[2] - history size ltrim right bound
+	// ARGV[3] - history lifetime
+	// ARGV[4] - channel to publish message to if needed
+	// ARGV[5] - history meta key expiration time
+	addHistorySource = `
+redis.replicate_commands()
+local epoch
+if redis.call('exists', KEYS[2]) ~= 0 then
+  epoch = redis.call("hget", KEYS[2], "e")
+end
+if epoch == false or epoch == nil then
+  epoch = redis.call('time')[1]
+  redis.call("hset", KEYS[2], "e", epoch)
+end
+local offset = redis.call("hincrby", KEYS[2], "s", 1)
+if ARGV[5] ~= '0' then
+	redis.call("expire", KEYS[2], ARGV[5])
+end
+local payload = "__" .. "p1:" .. offset .. ":" .. epoch .. "__" .. ARGV[1]
+redis.call("lpush", KEYS[1], payload)
+redis.call("ltrim", KEYS[1], 0, ARGV[2])
+redis.call("expire", KEYS[1], ARGV[3])
+if ARGV[4] ~= '' then
+	redis.call("publish", ARGV[4], payload)
+end
+return {offset, epoch}
+		`
+
+	// addHistoryStreamSource contains Lua script to save data to Redis stream and
+	// publish it into channel.
+	// KEYS[1] - history stream key
+	// KEYS[2] - stream meta hash key
+	// ARGV[1] - message payload
+	// ARGV[2] - stream size
+	// ARGV[3] - stream lifetime
+	// ARGV[4] - channel to publish message to if needed
+	// ARGV[5] - history meta key expiration time
+	// Every stream entry keeps publication time in milliseconds (Redis server
+	// clock) in field t to support history from time queries.
+	addHistoryStreamSource = `
+redis.replicate_commands()
+local epoch
+if redis.call('exists', KEYS[2]) ~= 0 then
+  epoch = redis.call("hget", KEYS[2], "e")
+end
+if epoch == false or epoch == nil then
+  epoch = redis.call('time')[1]
+  redis.call("hset", KEYS[2], "e", epoch)
+end
+local offset = redis.call("hincrby", KEYS[2], "s", 1)
+if ARGV[5] ~= '0' then
+	redis.call("expire", KEYS[2], ARGV[5])
+end
+local now = redis.call('time')
+local ms = now[1] * 1000 + math.floor(now[2] / 1000)
+redis.call("xadd", KEYS[1], "MAXLEN", ARGV[2], offset, "d", ARGV[1], "t", ms)
+redis.call("expire", KEYS[1], ARGV[3])
+if ARGV[4] ~= '' then
+	local payload = "__" .. "p1:" .. offset .. ":" .. epoch .. "__" .. ARGV[1]
+	redis.call("publish", ARGV[4], payload)
+end
+return {offset, epoch}
+	`
+
+	// Retrieve channel history information.
+	// KEYS[1] - history list key
+	// KEYS[2] - list meta hash key
+	// ARGV[1] - include publications into response
+	// ARGV[2] - publications list right bound
+	// ARGV[3] - list meta hash key expiration time
+	historyListSource = `
+redis.replicate_commands()
+local offset = redis.call("hget", KEYS[2], "s")
+local epoch
+if redis.call('exists', KEYS[2]) ~= 0 then
+  epoch = redis.call("hget", KEYS[2], "e")
+end
+if epoch == false or epoch == nil then
+  epoch = redis.call('time')[1]
+  redis.call("hset", KEYS[2], "e", epoch)
+end
+if ARGV[3] ~= '0' then
+	redis.call("expire", KEYS[2], ARGV[3])
+end
+local pubs = nil
+if ARGV[1] ~= "0" then
+	pubs = redis.call("lrange", KEYS[1], 0, ARGV[2])
+end
+return {offset, epoch, pubs}
+	`
+
+	// historyStreamSource ...
+	// KEYS[1] - history stream key
+	// KEYS[2] - stream meta hash key
+	// ARGV[1] - include publications into response
+	// ARGV[2] - offset
+	// ARGV[3] - limit
+	// ARGV[4] - reverse
+	// ARGV[5] - stream meta hash key expiration time
+	historyStreamSource = `
+redis.replicate_commands()
+local offset = redis.call("hget", KEYS[2], "s")
+local epoch
+if redis.call('exists', KEYS[2]) ~= 0 then
+  epoch = redis.call("hget", KEYS[2], "e")
+end
+if epoch == false or epoch == nil then
+  epoch = redis.call('time')[1]
+  redis.call("hset", KEYS[2], "e", epoch)
+end
+if ARGV[5] ~= '0' then
+	redis.call("expire", KEYS[2], ARGV[5])
+end
+local pubs = nil
+if ARGV[1] ~= "0" then
+  if ARGV[3] ~= "0" then
+	if ARGV[4] == '0' then
+    	pubs = redis.call("xrange", KEYS[1], ARGV[2], "+", "COUNT", ARGV[3])
+	else
+		local getOffset = offset
+		if tonumber(ARGV[2]) ~= 0 then
+			getOffset = tonumber(ARGV[2])
+		end
+		pubs = redis.call("xrevrange", KEYS[1], getOffset, "-", "COUNT", ARGV[3])
+	end
+  else
+	if ARGV[4] == '0' then
+		pubs = redis.call("xrange", KEYS[1], ARGV[2], "+")
+	else
+		local getOffset = offset
+		if tonumber(ARGV[2]) ~= 0 then
+			getOffset = tonumber(ARGV[2])
+		end
+		pubs = redis.call("xrevrange", KEYS[1], getOffset, "-")
+	end
+  end
+end
+return {offset, epoch, pubs}
+	`
+)
+
+const (
+	// Get publications from stream added at or after time.
+	// KEYS[1] - history stream key
+	// KEYS[2] - stream meta hash key
+	// ARGV[1] - time in milliseconds
+	// ARGV[2] - limit, 0 means no limit
+	// ARGV[3] - history meta key expiration time
+	// Entries added before publication time was saved to stream considered
+	// to be older than any time.
+	historyStreamFromTimeSource = `
+redis.replicate_commands()
+local offset = redis.call("hget", KEYS[2], "s")
+local epoch
+if redis.call('exists', KEYS[2]) ~= 0 then
+  epoch = redis.call("hget", KEYS[2], "e")
+end
+if epoch == false or epoch == nil then
+  epoch = redis.call('time')[1]
+  redis.call("hset", KEYS[2], "e", epoch)
+end
+if ARGV[3] ~= '0' then
+	redis.call("expire", KEYS[2], ARGV[3])
+end
+local first = redis.call("xrange", KEYS[1], "-", "+", "COUNT", 1)
+if offset == false or #first == 0 then
+  return {offset, epoch, {}}
+end
+local function entryOffset(entry)
+  return tonumber(string.match(entry[1], "^(%d+)"))
+end
+local function entryTime(entry)
+  local fields = entry[2]
+  for i = 1, #fields, 2 do
+    if fields[i] == "t" then
+      return tonumber(fields[i + 1])
+    end
+  end
+  return 0
+end
+local since = tonumber(ARGV[1])
+local left = entryOffset(first[1])
+local right = tonumber(offset) + 1
+while left < right do
+  local mid = math.floor((left + right) / 2)
+  local entries = redis.call("xrange", KEYS[1], mid, "+", "COUNT", 1)
+  if #entries == 0 then
+    right = mid
+  elseif entryTime(entries[1]) >= since then
+    right = mid
+  else
+    left = entryOffset(entries[1]) + 1
+  end
+end
+local pubs
+if ARGV[2] ~= '0' then
+  pubs = redis.call("xrange", KEYS[1], left, "+", "COUNT", ARGV[2])
+else
+  pubs = redis.call("xrange", KEYS[1], left, "+")
+end
+return {offset, epoch, pubs}
+	`
+)
+
+func (b *Broker) getShard(channel string) *Shard {
+	b.shardsMu.RLock()
+	defer b.shardsMu.RUnlock()
+	if !b.sharding {
+		return b.shards[0]
+	}
+	return b.shards[consistentIndex(channel, len(b.shards))]
+}
+
+// getShards returns a copy of current shards.
+func (b *Broker) getShards() []*Shard {
+	b.shardsMu.RLock()
+	defer b.shardsMu.RUnlock()
+	shards := make([]*Shard, len(b.shards))
+	copy(shards, b.shards)
+	return shards
+}
+
+// onShards calls fn for every current shard and for every shard added later
+// with AddShard.
+func (b *Broker) onShards(fn func(s *Shard)) {
+	b.shardsMu.Lock()
+	defer b.shardsMu.Unlock()
+	b.shardHandlers = append(b.shardHandlers, fn)
+	for _, s := range b.shards {
+		fn(s)
+	}
+}
+
+// registerScripts registers scripts on all shards including shards added later.
+func (b *Broker) registerScripts(scripts ...*redis.Script) {
+	b.onShards(func(s *Shard) {
+		s.registerScripts(scripts...)
+	})
+}
+
+// Run – see Broker.Run.
+func (b *Broker) Run(h centrifuge.BrokerEventHandler) error {
+	b.addShardMu.Lock()
+	defer b.addShardMu.Unlock()
+	b.eventHandler = h
+	for _, shard := range b.getShards() {
+		err := b.runShard(shard, h)
+		if err != nil {
+			return err
+		}
+		if err := b.checkCapabilities(shard); err != nil {
+			return fmt.Errorf("capability error on %s: %v", shard.string(), err)
+		}
+	}
+	return nil
+}
+
+func (b *Broker) checkCapabilities(shard *Shard) error {
+	if b.config.UseLists {
+		return nil
+	}
+	// Check whether Redis Streams supported.
+	dr := shard.newDataRequest("XINFO", nil, "", []interface{}{"HELP"})
+	resp := shard.getDataResponse(dr)
+	if resp.err != nil {
+		if strings.Contains(resp.err.Error(), "ERR unknown command") {
+			return errors.New("STREAM only available since Redis >= 5, consider upgrading Redis or using LIST structure for history")
+		}
+		return resp.err
+	}
+	return nil
+}
+
+func (b *Broker) runShard(shard *Shard, h centrifuge.BrokerEventHandler) error {
+	go runForever(func() {
+		b.runPublishPipeline(shard, shard.pubCh, redisPublishBatchLimit, shard.config.PipelineMaxWait)
+	})
+	go runForever(func() {
+		b.runPublishPipeline(shard, shard.priorityPubCh, redisPriorityPublishBatchLimit, 0)
+	})
+	if !shard.config.Compatibility.PingCommand {
+		go runForever(func() {
+			b.runPubSubPing(shard)
+		})
+	}
+	for i := range shard.subChs {
+		i := i
+		go runForever(func() {
+			b.runPubSub(shard, h, i)
+		})
+	}
+	go runForever(func() {
+		b.runControlPubSub(shard, h)
+	})
+	if !b.config.UseLists {
+		go runForever(func() {
+			b.runHistoryCompaction(shard)
+		})
+	}
+	return nil
+}
+
+// Publish - see Broker.Publish.
+func (b *Broker) Publish(ch string, data []byte, opts centrifuge.PublishOptions) (centrifuge.StreamPosition, error) {
+	return b.publishWithRetry(ch, data, opts, b.config.IsPriorityChannel != nil && b.config.IsPriorityChannel(ch))
+}
+
+// PublishPriority publishes data to channel over priority lane regardless of
+// channel options, see BrokerConfig.IsPriorityChannel.
+func (b *Broker) PublishPriority(ch string, data []byte, opts centrifuge.PublishOptions) (centrifuge.StreamPosition, error) {
+	return b.publishWithRetry(ch, data, opts, true)
+}
+
+func (b *Broker) publishWithRetry(ch string, data []byte, opts centrifuge.PublishOptions, priority bool) (centrifuge.StreamPosition, error) {
+	s := b.getShard(ch)
+	started := time.Now()
+	var sp centrifuge.StreamPosition
+	err := s.withPublishRetry(func() error {
+		var err error
+		sp, err = b.publish(s, ch, data, opts, priority)
+		return err
+	})
+	s.metrics.observe(redisOpPublish, started, err)
+	return sp, err
+}
+
+func (b *Broker) publish(s *Shard, ch string, data []byte, opts centrifuge.PublishOptions, priority bool) (centrifuge.StreamPosition, error) {
+	protoPub := &protocol.Publication{
+		Data: data,
+		Info: infoToProto(opts.ClientInfo),
+	}
+	byteMessage, err := protoPub.MarshalVT()
+	if err != nil {
+		return centrifuge.StreamPosition{}, err
+	}
+
+	publishChannel := b.messageChannelID(ch)
+
+	if (opts.HistorySize <= 0 || opts.HistoryTTL <= 0) && b.ordering != nil {
+		seqKey := b.orderingSeqKey(s, ch)
+		dr := s.newDataRequest("", b.orderingSeqScript, seqKey, []interface{}{seqKey, byteMessage, publishChannel, int(b.config.HistoryMetaTTL.Seconds())})
+		resp := s.getDataResponse(dr)
+		return centrifuge.StreamPosition{}, resp.err
+	}
+
+	if opts.HistorySize <= 0 || opts.HistoryTTL <= 0 {
+		// Fast path – publish without history.
+		eChan := make(chan error, 1)
+
+		pr := pubRequest{
+			channel: publishChannel,
+			message: byteMessage,
+			err:     eChan,
+		}
+		pubCh := s.pubCh
+		if priority {
+			pubCh = s.priorityPubCh
+		}
+		select {
+		case pubCh <- pr:
+		default:
+			timer := AcquireTimer(s.readTimeout())
+			defer ReleaseTimer(timer)
+			select {
+			case pubCh <- pr:
+			case <-timer.C:
+				return centrifuge.StreamPosition{}, errRedisOpTimeout
+			}
+		}
+		return centrifuge.StreamPosition{}, <-eChan
+	}
+
+	historyMetaKey := b.historyMetaKey(s, ch)
+	historyMetaTTLSeconds := int(b.config.HistoryMetaTTL.Seconds())
+
+	var streamKey channelID
+	var size int
+	var script *redis.Script
+	if !b.config.UseLists {
+		streamKey = b.historyStreamKey(s, ch)
+		size = opts.HistorySize
+		script = b.addHistoryStreamScript
+	} else {
+		streamKey = b.historyListKey(s, ch)
+		size = opts.HistorySize - 1
+		script = b.addHistoryListScript
+	}
+	dr := s.newDataRequest("", script, streamKey, []interface{}{streamKey, historyMetaKey, byteMessage, size, int(opts.HistoryTTL.Seconds()), publishChannel, historyMetaTTLSeconds})
+	resp := s.getDataResponse(dr)
+	if resp.err != nil {
+		return centrifuge.StreamPosition{}, resp.err
+	}
+	replies, ok := resp.reply.([]interface{})
+	if !ok || len(replies) != 2 {
+		return centrifuge.StreamPosition{}, errors.New("wrong Redis reply")
+	}
+	index, err := redis.Uint64(replies[0], nil)
+	if err != nil {
+		return centrifuge.StreamPosition{}, errors.New("wrong Redis reply offset")
+	}
+	epoch, err := redis.String(replies[1], nil)
+	if err != nil {
+		return centrifuge.StreamPosition{}, errors.New("wrong Redis reply epoch")
+	}
+	return centrifuge.StreamPosition{Offset: index, Epoch: epoch}, nil
+}
+
+// PublishJoin - see Broker.PublishJoin.
+func (b *Broker) PublishJoin(ch string, info *centrifuge.ClientInfo) error {
+	return b.publishJoin(b.getShard(ch), ch, info)
+}
+
+func (b *Broker) publishJoin(s *Shard, ch string, info *centrifuge.ClientInfo) error {
+	eChan := make(chan error, 1)
+
+	byteMessage, err := infoToProto(info).MarshalVT()
+	if err != nil {
+		return err
+	}
+
+	chID := b.messageChannelID(ch)
+
+	pr := pubRequest{
+		channel: chID,
+		message: append(joinTypePrefix, byteMessage...),
+		err:     eChan,
+	}
+	select {
+	case s.pubCh <- pr:
+	default:
+		timer := AcquireTimer(s.readTimeout())
+		defer ReleaseTimer(timer)
+		select {
+		case s.pubCh <- pr:
+		case <-timer.C:
+			return errRedisOpTimeout
+		}
+	}
+	return <-eChan
+}
+
+// PublishLeave - see Broker.PublishLeave.
+func (b *Broker) PublishLeave(ch string, info *centrifuge.ClientInfo) error {
+	return b.publishLeave(b.getShard(ch), ch, info)
+}
+
+func (b *Broker) publishLeave(s *Shard, ch string, info *centrifuge.ClientInfo) error {
+	eChan := make(chan error, 1)
+
+	byteMessage, err := infoToProto(info).MarshalVT()
+	if err != nil {
+		return err
+	}
+
+	chID := b.messageChannelID(ch)
+
+	pr := pubRequest{
+		channel: chID,
+		message: append(leaveTypePrefix, byteMessage...),
+		err:     eChan,
+	}
+	select {
+	case s.pubCh <- pr:
+	default:
+		timer := AcquireTimer(s.readTimeout())
+		defer ReleaseTimer(timer)
+		select {
+		case s.pubCh <- pr:
+		case <-timer.C:
+			return errRedisOpTimeout
+		}
+	}
+	return <-eChan
+}
+
+// PublishControl - see Broker.PublishControl.
+func (b *Broker) PublishControl(data []byte, nodeID, _ string) error {
+	currentRound := atomic.AddUint64(&b.controlRound, 1)
+	b.shardsMu.RLock()
+	index := currentRound % uint64(len(b.shards))
+	s := b.shards[index]
+	b.shardsMu.RUnlock()
+	return b.publishControl(s, data, nodeID)
+}
+
+func (b *Broker) publishControl(s *Shard, data []byte, nodeID string) error {
+	eChan := make(chan error, 1)
+
+	var chID channelID
+	if nodeID == "" {
+		chID = channelID(b.controlChannel)
+	} else {
+		chID = b.nodeChannelID(nodeID)
+	}
+
+	pr := pubRequest{
+		channel: chID,
+		message: data,
+		err:     eChan,
+	}
+	select {
+	case s.pubCh <- pr:
+	default:
+		timer := AcquireTimer(s.readTimeout())
+		defer ReleaseTimer(timer)
+		select {
+		case s.pubCh <- pr:
+		case <-timer.C:
+			return errRedisOpTimeout
+		}
+	}
+	return <-eChan
+}
+
+// Subscribe - see Broker.Subscribe.
+func (b *Broker) Subscribe(ch string) error {
+	s := b.getShard(ch)
+	started := time.Now()
+	err := b.subscribe(s, ch)
+	s.metrics.observe(redisOpSubscribe, started, err)
+	return err
+}
+
+func (b *Broker) subscribe(s *Shard, ch string) error {
+	if b.node.LogEnabled(centrifuge.LogLevelDebug) {
+		b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "subscribe node on channel", map[string]interface{}{"channel": ch}))
+	}
+	r := newSubRequest([]channelID{b.messageChannelID(ch)}, true)
+	return s.sendSubscribe(r)
+}
+
+// Unsubscribe - see Broker.Unsubscribe.
+func (b *Broker) Unsubscribe(ch string) error {
+	s := b.getShard(ch)
+	started := time.Now()
+	err := b.unsubscribe(s, ch)
+	s.metrics.observe(redisOpUnsubscribe, started, err)
+	return err
+}
+
+func (b *Broker) unsubscribe(s *Shard, ch string) error {
+	if b.node.LogEnabled(centrifuge.LogLevelDebug) {
+		b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "unsubscribe node from channel", map[string]interface{}{"channel": ch}))
+	}
+	r := newSubRequest([]channelID{b.messageChannelID(ch)}, false)
+	err := s.sendSubscribe(r)
+	if err == nil && b.ordering != nil {
+		b.ordering.forget(ch)
+	}
+	return err
+}
+
+// History - see Broker.History.
+func (b *Broker) History(ch string, filter centrifuge.HistoryFilter) ([]*centrifuge.Publication, centrifuge.StreamPosition, error) {
+	s := b.getShard(ch)
+	started := time.Now()
+	pubs, sp, err := b.history(s, ch, filter)
+	s.metrics.observe(redisOpHistory, started, err)
+	return pubs, sp, err
+}
+
+func (b *Broker) history(s *Shard, ch string, filter centrifuge.HistoryFilter) ([]*centrifuge.Publication, centrifuge.StreamPosition, error) {
+	if !b.config.UseLists {
+		return b.historyStream(s, ch, filter)
+	}
+	return b.historyList(s, ch, filter)
+}
+
+// ErrHistoryFromTimeNotSupported returned from HistoryFromTime when history
+// kept in Redis lists.
+var ErrHistoryFromTimeNotSupported = errors.New("history from time not supported with lists")
+
+// HistoryFromTime returns publications added to channel history at or after
+// from time, oldest first. Limit has the same meaning as in HistoryFilter:
+// negative value means no limit, zero value means only stream position
+// returned. Time of publication is taken from Redis server clock when
+// publication added to history.
+func (b *Broker) HistoryFromTime(ch string, from time.Time, limit int) ([]*centrifuge.Publication, centrifuge.StreamPosition, error) {
+	if b.config.UseLists {
+		return nil, centrifuge.StreamPosition{}, ErrHistoryFromTimeNotSupported
+	}
+	if limit == 0 {
+		return b.History(ch, centrifuge.HistoryFilter{Limit: 0})
+	}
+	if limit < 0 {
+		limit = 0
+	}
+	s := b.getShard(ch)
+	started := time.Now()
+	historyKey := b.historyStreamKey(s, ch)
+	historyMetaKey := b.historyMetaKey(s, ch)
+	historyMetaTTLSeconds := int(b.config.HistoryMetaTTL.Seconds())
+	fromMs := from.UnixNano() / int64(time.Millisecond)
+
+	dr := s.newDataRequest("", b.historyFromTimeScript, historyKey, []interface{}{historyKey, historyMetaKey, fromMs, limit, historyMetaTTLSeconds})
+	resp := s.getDataResponse(dr)
+	s.metrics.observe(redisOpHistory, started, resp.err)
+	if resp.err != nil {
+		return nil, centrifuge.StreamPosition{}, resp.err
+	}
+	latestPosition, publications, err := extractHistoryResponse(resp.reply, true, true)
+	if err != nil {
+		return nil, centrifuge.StreamPosition{}, err
+	}
+	return publications, latestPosition, nil
+}
+
+// RemoveHistory - see Broker.RemoveHistory.
+func (b *Broker) RemoveHistory(ch string) error {
+	s := b.getShard(ch)
+	started := time.Now()
+	err := b.removeHistory(s, ch)
+	s.metrics.observe(redisOpRemoveHistory, started, err)
+	return err
+}
+
+func (b *Broker) removeHistory(s *Shard, ch string) error {
+	var key channelID
+	if !b.config.UseLists {
+		key = b.historyStreamKey(s, ch)
+	} else {
+		key = b.historyListKey(s, ch)
+	}
+	dr := s.newDataRequest("DEL", nil, key, []interface{}{key})
+	resp := s.getDataResponse(dr)
+	return resp.err
+}
+
+func (b *Broker) messageChannelID(ch string) channelID {
+	return channelID(b.messagePrefix + ch)
+}
+
+func (b *Broker) nodeChannelID(nodeID string) channelID {
+	return channelID(b.config.Prefix + redisNodeChannelPrefix + nodeID)
+}
+
+// keyPrefix returns prefix of channel keys.
+func (b *Broker) keyPrefix(ch string) string {
+	if b.config.KeyPrefix != nil {
+		return b.config.KeyPrefix(ch)
+	}
+	return b.config.Prefix
+}
+
+func (b *Broker) historyListKey(s *Shard, ch string) channelID {
+	prefix := b.keyPrefix(ch)
+	if s.useCluster {
+		ch = "{" + ch + "}"
+	}
+	return channelID(prefix + ".list." + ch)
+}
+
+func (b *Broker) historyStreamKey(s *Shard, ch string) channelID {
+	prefix := b.keyPrefix(ch)
+	if s.useCluster {
+		ch = "{" + ch + "}"
+	}
+	return channelID(prefix + ".stream." + ch)
+}
+
+func (b *Broker) historyMetaKey(s *Shard, ch string) channelID {
+	prefix := b.keyPrefix(ch)
+	if s.useCluster {
+		ch = "{" + ch + "}"
+	}
+	if !b.config.UseLists {
+		return channelID(prefix + ".stream.meta." + ch)
+	}
+	return channelID(prefix + ".list.meta." + ch)
+}
+
+// runPubSub runs PUB/SUB connection with index which receives messages of
+// channels belonging to it.
+func (b *Broker) runPubSub(s *Shard, eventHandler centrifuge.BrokerEventHandler, index int) {
+	numWorkers := b.config.PubSubNumWorkers
+	if numWorkers == 0 {
+		numWorkers = runtime.NumCPU()
+	}
+
+	b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, fmt.Sprintf("running Redis PUB/SUB, num workers: %d", numWorkers), map[string]interface{}{"shard": s.string(), "connection": index}))
+	defer func() {
+		b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "stopping Redis PUB/SUB", map[string]interface{}{"shard": s.string(), "connection": index}))
+	}()
+
+	subCh := s.subChs[index]
+
+	poolConn := s.pubSubPool.Get()
+	if poolConn.Err() != nil {
+		// At this moment test on borrow could already return an error,
+		// we can't work with broken connection.
+		_ = poolConn.Close()
+		return
+	}
+
+	conn := redis.PubSubConn{Conn: poolConn}
+
+	if b.ordering != nil {
+		b.ordering.reset()
+	}
+
+	done := make(chan struct{})
+	var doneOnce sync.Once
+	closeDoneOnce := func() {
+		doneOnce.Do(func() {
+			close(done)
+		})
+	}
+	defer closeDoneOnce()
+
+	// Run subscriber goroutine. It's the only goroutine which writes to
+	// PUB/SUB connection so it also sends PING commands if required.
+	go func() {
+		b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "starting Broker Subscriber", map[string]interface{}{"shard": s.string()}))
+		defer func() {
+			b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "stopping Broker Subscriber", map[string]interface{}{"shard": s.string()}))
+		}()
+		var pingCh <-chan time.Time
+		if s.config.Compatibility.PingCommand {
+			pingTicker := time.NewTicker(time.Second)
+			defer pingTicker.Stop()
+			pingCh = pingTicker.C
+		}
+		for {
+			select {
+			case <-done:
+				_ = conn.Close()
+				return
+			case <-pingCh:
+				if err := conn.Ping(""); err != nil {
+					// Close conn, this should cause Receive to return with err below
+					// and whole runPubSub method to restart.
+					_ = conn.Close()
+					return
+				}
+			case r := <-subCh:
+				isSubscribe := r.subscribe
+				channelBatch := []subRequest{r}
+
+				chIDs := make([]interface{}, 0, len(r.channels))
+				for _, ch := range r.channels {
+					chIDs = append(chIDs, ch)
+				}
+
+				var otherR *subRequest
+
+			loop:
+				for len(chIDs) < redisSubscribeBatchLimit {
+					select {
+					case r := <-subCh:
+						if r.subscribe != isSubscribe {
+							// We can not mix subscribe and unsubscribe request into one batch
+							// so must stop here. As we consumed a subRequest value from channel
+							// we should take care of it later.
+							otherR = &r
+							break loop
+						}
+						channelBatch = append(channelBatch, r)
+						for _, ch := range r.channels {
+							chIDs = append(chIDs, ch)
+						}
+					default:
+						break loop
+					}
+				}
+
+				var opErr error
+				if isSubscribe {
+					opErr = conn.Subscribe(chIDs...)
+				} else {
+					opErr = conn.Unsubscribe(chIDs...)
+				}
+
+				if opErr != nil {
+					for _, r := range channelBatch {
+						r.done(opErr)
+					}
+					if otherR != nil {
+						otherR.done(opErr)
+					}
+					// Close conn, this should cause Receive to return with err below
+					// and whole runPubSub method to restart.
+					_ = conn.Close()
+					return
+				}
+				for _, r := range channelBatch {
+					r.done(nil)
+				}
+				if otherR != nil {
+					chIDs := make([]interface{}, 0, len(otherR.channels))
+					for _, ch := range otherR.channels {
+						chIDs = append(chIDs, ch)
+					}
+					var opErr error
+					if otherR.subscribe {
+						opErr = conn.Subscribe(chIDs...)
+					} else {
+						opErr = conn.Unsubscribe(chIDs...)
+					}
+					if opErr != nil {
+						otherR.done(opErr)
+						// Close conn, this should cause Receive to return with err below
+						// and whole runPubSub method to restart.
+						_ = conn.Close()
+						return
+					}
+					otherR.done(nil)
+				}
+			}
+		}
+	}()
+
+	// Run workers to spread received message processing work over worker goroutines.
+	pool := newPubSubWorkerPool(numWorkers, b.config.PubSubMaxWorkers, b.config.PubSubHotChannelThreshold, func(n redis.Message) func() {
+		if n.Channel == b.pingChannel {
+			// Do nothing - this message just maintains connection open.
+			return nil
+		}
+		deliver, err := b.prepareRedisClientMessage(eventHandler, channelID(n.Channel), n.Data)
+		if err != nil {
+			b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error handling client message", map[string]interface{}{"error": err.Error()}))
+			return nil
+		}
+		return deliver
+	}, done, newPubSubPoolMetrics(s.pubSubString(index)))
+	go pool.runChecks()
+
+	go func() {
+		if err := b.resubscribe(s, index, done); err != nil {
+			b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error subscribing", map[string]interface{}{"error": err.Error()}))
+			closeDoneOnce()
+		}
+	}()
+
+	for {
+		switch n := conn.ReceiveWithTimeout(s.pubSubReadTimeout()).(type) {
+		case redis.Message:
+			// Add message to worker preserving message order - i.e. messages
+			// from the same channel will be processed in order.
+			pool.dispatch(n)
+		case redis.Subscription:
+		case error:
+			b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "Redis receiver error", map[string]interface{}{"error": n.Error()}))
+			s.reloadPipeline()
+			return
+		}
+	}
+}
+
+func (b *Broker) runControlPubSub(s *Shard, eventHandler centrifuge.BrokerEventHandler) {
+	numWorkers := runtime.NumCPU()
+
+	b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, fmt.Sprintf("running Redis control PUB/SUB, num workers: %d", numWorkers), map[string]interface{}{"shard": s.string()}))
+	defer func() {
+		b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "stopping Redis control PUB/SUB", map[string]interface{}{"shard": s.string()}))
+	}()
+
+	poolConn := s.pubSubPool.Get()
+	if poolConn.Err() != nil {
+		// At this moment test on borrow could already return an error,
+		// we can't work with broken connection.
+		_ = poolConn.Close()
+		return
+	}
+
+	conn := redis.PubSubConn{Conn: poolConn}
+
+	done := make(chan struct{})
+	var doneOnce sync.Once
+	closeDoneOnce := func() {
+		doneOnce.Do(func() {
+			close(done)
+		})
+	}
+	defer closeDoneOnce()
+
+	controlChannel := b.controlChannel
+	nodeChannel := b.nodeChannel
+	pingChannel := b.pingChannel
+
+	// Run workers to spread message processing work over worker goroutines.
+	workCh := make(chan redis.Message)
+	for i := 0; i < numWorkers; i++ {
+		go func() {
+			for {
+				select {
+				case <-done:
+					return
+				case n := <-workCh:
+					switch n.Channel {
+					case pingChannel:
+						// Do nothing - this message just maintains connection open.
+					default:
+						err := eventHandler.HandleControl(n.Data)
+						if err != nil {
+							b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error handling control message", map[string]interface{}{"error": err.Error()}))
+							continue
+						}
+					}
+				}
+			}
+		}()
+	}
+
+	err := conn.Subscribe(controlChannel, nodeChannel, pingChannel)
+	if err != nil {
+		b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "control channel subscribe error", map[string]interface{}{"error": err.Error()}))
+		return
+	}
+
+	if s.config.Compatibility.PingCommand {
+		go b.runPubSubPingCommand(conn, done)
+	}
+
+	for {
+		switch n := conn.ReceiveWithTimeout(s.pubSubReadTimeout()).(type) {
+		case redis.Message:
+			// Add message to worker channel preserving message order - i.b. messages
+			// from the same channel will be processed in the same worker.
+			workCh <- n
+		case redis.Subscription:
+		case error:
+			b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "Redis receiver error", map[string]interface{}{"error": n.Error()}))
+			return
+		}
+	}
+}
+
+func (b *Broker) extractChannel(chID channelID) string {
+	return strings.TrimPrefix(string(chID), b.messagePrefix)
+}
+
+// Define prefixes to distinguish Join and Leave messages coming from PUB/SUB.
+var (
+	joinTypePrefix  = []byte("__j__")
+	leaveTypePrefix = []byte("__l__")
+)
+
+// prepareRedisClientMessage decodes message received from PUB/SUB and returns
+// function which passes it to event handler. Decoding of messages can happen
+// concurrently while returned functions must be called in order of messages.
+func (b *Broker) prepareRedisClientMessage(eventHandler centrifuge.BrokerEventHandler, chID channelID, data []byte) (func(), error) {
+	pushData, pushType, sp, ok := extractPushData(data)
+	if !ok {
+		return nil, fmt.Errorf("malformed PUB/SUB data: %s", data)
+	}
+	channel := b.extractChannel(chID)
+	if pushType == pubPushType {
+		var pub protocol.Publication
+		err := pub.UnmarshalVT(pushData)
+		if err != nil {
+			return nil, err
+		}
+		orderingSP := sp
+		if isOrderingSeqData(data) {
+			sp = centrifuge.StreamPosition{}
+		}
+		if pub.Offset == 0 {
+			// When adding to history and publishing happens atomically in Broker
+			// position info is prepended to Publication payload. In this case we should attach
+			// it to unmarshalled Publication.
+			pub.Offset = sp.Offset
+		}
+		publication := pubFromProto(&pub)
+		return func() {
+			if b.ordering != nil {
+				b.checkOrdering(channel, orderingSP)
+			}
+			_ = eventHandler.HandlePublication(channel, publication, sp)
+		}, nil
+	} else if pushType == joinPushType {
+		var info protocol.ClientInfo
+		err := info.UnmarshalVT(pushData)
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			_ = eventHandler.HandleJoin(channel, infoFromProto(&info))
+		}, nil
+	} else if pushType == leavePushType {
+		var info protocol.ClientInfo
+		err := info.UnmarshalVT(pushData)
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			_ = eventHandler.HandleLeave(channel, infoFromProto(&info))
+		}, nil
+	}
+	return nil, nil
+}
+
+func (b *Broker) runPubSubPing(s *Shard) {
+	pingTicker := time.NewTicker(time.Second)
+	defer pingTicker.Stop()
+	for {
+		<-pingTicker.C
+		// Publish periodically to maintain PUB/SUB connection alive and allow
+		// PUB/SUB connection to close early if no data received for a period of time.
+		conn := s.pool.Get()
+		err := conn.Send("PUBLISH", b.pingChannel, nil)
+		if err != nil {
+			b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error publish ping to Redis channel", map[string]interface{}{"error": err.Error()}))
+			_ = conn.Close()
+			return
+		}
+		_ = conn.Close()
+	}
+}
+
+// runPubSubPingCommand periodically sends PING over PUB/SUB connection to
+// maintain it alive until done closed.
+func (b *Broker) runPubSubPingCommand(conn redis.PubSubConn, done chan struct{}) {
+	pingTicker := time.NewTicker(time.Second)
+	defer pingTicker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-pingTicker.C:
+			if err := conn.Ping(""); err != nil {
+				b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error sending ping to Redis PUB/SUB connection", map[string]interface{}{"error": err.Error()}))
+				_ = conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// runPublishPipeline sends publish requests from pubCh to Redis in batches of
+// up to batchLimit requests.
+func (b *Broker) runPublishPipeline(s *Shard, pubCh chan pubRequest, batchLimit int, maxWait time.Duration) {
+	var prs []pubRequest
+	batcher := newPipelineBatcher(maxWait)
+
+	for {
+		pr := <-pubCh
+		prs = append(prs, pr)
+
+	loop:
+		for len(prs) < batchLimit {
+			select {
+			case pr := <-pubCh:
+				prs = append(prs, pr)
+			default:
+				break loop
+			}
+		}
+		if wait, target := batcher.next(len(prs), batchLimit); wait > 0 {
+			timer := AcquireTimer(wait)
+		waitLoop:
+			for len(prs) < target {
+				select {
+				case pr := <-pubCh:
+					prs = append(prs, pr)
+				case <-timer.C:
+					break waitLoop
+				}
+			}
+			ReleaseTimer(timer)
+		}
+		batcher.flushed(len(prs))
+		conn := s.pool.Get()
+		for i := range prs {
+			_ = conn.Send("PUBLISH", prs[i].channel, prs[i].message)
+		}
+		err := conn.Flush()
+		if err != nil {
+			for i := range prs {
+				prs[i].done(err)
+			}
+			b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error flushing publish pipeline", map[string]interface{}{"error": err.Error()}))
+			_ = conn.Close()
+			return
+		}
+		for i := range prs {
+			_, err := conn.Receive()
+			prs[i].done(err)
+		}
+		if conn.Err() != nil {
+			_ = conn.Close()
+			return
+		}
+		_ = conn.Close()
+		prs = nil
+	}
+}
+
+// sendSubscribe sends request to PUB/SUB connections channels of request
+// belong to and waits for result.
+func (s *Shard) sendSubscribe(r subRequest) error {
+	if len(s.subChs) == 1 {
+		return s.sendSubRequest(0, r)
+	}
+	byIndex := map[int][]channelID{}
+	for _, ch := range r.channels {
+		index := s.pubSubIndex(ch)
+		byIndex[index] = append(byIndex[index], ch)
+	}
+	if len(byIndex) == 1 {
+		for index := range byIndex {
+			return s.sendSubRequest(index, r)
+		}
+	}
+	for index, chIDs := range byIndex {
+		if err := s.sendSubRequest(index, newSubRequest(chIDs, r.subscribe)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendSubRequest sends request to PUB/SUB connection with index and waits for
+// result.
+func (s *Shard) sendSubRequest(index int, r subRequest) error {
+	subCh := s.subChs[index]
+	select {
+	case subCh <- r:
+	default:
+		timer := AcquireTimer(s.readTimeout())
+		defer ReleaseTimer(timer)
+		select {
+		case subCh <- r:
+		case <-timer.C:
+			return errRedisOpTimeout
+		}
+	}
+	return r.result()
+}
+
+func extractHistoryResponse(reply interface{}, useStreams bool, includePubs bool) (centrifuge.StreamPosition, []*centrifuge.Publication, error) {
+	results := reply.([]interface{})
+
+	offset, err := redis.Uint64(results[0], nil)
+	if err != nil {
+		if err != redis.ErrNil {
+			return centrifuge.StreamPosition{}, nil, err
+		}
+		offset = 0
+	}
+
+	epoch, err := redis.String(results[1], nil)
+	if err != nil {
+		return centrifuge.StreamPosition{}, nil, err
+	}
+
+	streamPosition := centrifuge.StreamPosition{Offset: offset, Epoch: epoch}
+
+	if includePubs {
+		var publications []*centrifuge.Publication
+		if useStreams {
+			publications, err = sliceOfPubsStream(results[2], nil)
+		} else {
+			publications, err = sliceOfPubsList(results[2], nil)
+		}
+		if err != nil {
+			return centrifuge.StreamPosition{}, nil, err
+		}
+		return streamPosition, publications, nil
+	}
+
+	return streamPosition, nil, nil
+}
+
+func (b *Broker) historyStream(s *Shard, ch string, filter centrifuge.HistoryFilter) ([]*centrifuge.Publication, centrifuge.StreamPosition, error) {
+	historyKey := b.historyStreamKey(s, ch)
+	historyMetaKey := b.historyMetaKey(s, ch)
+
+	var includePubs = true
+	var offset uint64
+	if filter.Since != nil {
+		if filter.Reverse {
+			offset = filter.Since.Offset - 1
+			if offset == 0 {
+				includePubs = false
+			}
+		} else {
+			offset = filter.Since.Offset + 1
+		}
+	}
+	var limit int
+	if filter.Limit == 0 {
+		includePubs = false
+	}
+	if filter.Limit > 0 {
+		limit = filter.Limit
+	}
+
+	historyMetaTTLSeconds := int(b.config.HistoryMetaTTL.Seconds())
+
+	dr := s.newDataRequest("", b.historyStreamScript, historyKey, []interface{}{historyKey, historyMetaKey, includePubs, offset, limit, filter.Reverse, historyMetaTTLSeconds})
+	resp := s.getDataResponse(dr)
+	if resp.err != nil {
+		return nil, centrifuge.StreamPosition{}, resp.err
+	}
+
+	latestPosition, publications, err := extractHistoryResponse(resp.reply, !b.config.UseLists, includePubs)
+	if err != nil {
+		return nil, centrifuge.StreamPosition{}, err
+	}
+
+	return publications, latestPosition, nil
+}
+
+func (b *Broker) historyList(s *Shard, ch string, filter centrifuge.HistoryFilter) ([]*centrifuge.Publication, centrifuge.StreamPosition, error) {
+	historyKey := b.historyListKey(s, ch)
+	historyMetaKey := b.historyMetaKey(s, ch)
+
+	var includePubs = true
+	var rightBound = -1
+	if filter.Limit == 0 {
+		rightBound = 0
+		includePubs = false
+	}
+
+	historyMetaTTLSeconds := int(b.config.HistoryMetaTTL.Seconds())
+
+	dr := s.newDataRequest("", b.historyListScript, historyKey, []interface{}{historyKey, historyMetaKey, includePubs, rightBound, historyMetaTTLSeconds})
+	resp := s.getDataResponse(dr)
+	if resp.err != nil {
+		return nil, centrifuge.StreamPosition{}, resp.err
+	}
+
+	latestPosition, publications, err := extractHistoryResponse(resp.reply, !b.config.UseLists, includePubs)
+	if err != nil {
+		return nil, centrifuge.StreamPosition{}, err
+	}
+
+	since := filter.Since
+	if since == nil {
+		if filter.Limit >= 0 && len(publications) >= filter.Limit {
+			return publications[:filter.Limit], latestPosition, nil
+		}
+		return publications, latestPosition, nil
+	}
+
+	if latestPosition.Offset == since.Offset && since.Epoch == latestPosition.Epoch {
+		return nil, latestPosition, nil
+	}
+
+	if latestPosition.Offset < since.Offset {
+		return nil, latestPosition, nil
+	}
+
+	nextOffset := since.Offset + 1
+
+	position := -1
+
+	for i := 0; i < len(publications); i++ {
+		pub := publications[i]
+		if pub.Offset == since.Offset {
+			position = i + 1
+			break
+		}
+		if pub.Offset == nextOffset {
+			position = i
+			break
+		}
+	}
+
+	if position > -1 {
+		pubs := publications[position:]
+		if filter.Limit >= 0 {
+			limit := filter.Limit
+			if limit > len(pubs) {
+				limit = len(pubs)
+			}
+			return pubs[:limit], latestPosition, nil
+		}
+		return pubs, latestPosition, nil
+	}
+
+	if filter.Limit >= 0 {
+		limit := filter.Limit
+		if limit > len(publications) {
+			limit = len(publications)
+		}
+		return publications[:limit], latestPosition, nil
+	}
+	return publications, latestPosition, nil
+}
+
+type pushType int
+
+const (
+	pubPushType   pushType = 0
+	joinPushType  pushType = 1
+	leavePushType pushType = 2
+)
+
+var (
+	metaSep    = []byte("__")
+	contentSep = ":"
+)
+
+// See tests for supported format examples.
+func extractPushData(data []byte) ([]byte, pushType, centrifuge.StreamPosition, bool) {
+	var offset uint64
+	var epoch string
+	if !bytes.HasPrefix(data, metaSep) {
+		return data, pubPushType, centrifuge.StreamPosition{Epoch: epoch, Offset: offset}, true
+	}
+	nextMetaSepPos := bytes.Index(data[len(metaSep):], metaSep)
+	if nextMetaSepPos <= 0 {
+		return data, pubPushType, centrifuge.StreamPosition{Epoch: epoch, Offset: offset}, false
+	}
+	content := data[len(metaSep) : len(metaSep)+nextMetaSepPos]
+	contentType := content[0]
+
+	rest := data[len(metaSep)+nextMetaSepPos+len(metaSep):]
+
+	switch contentType {
+	case 'j':
+		return rest, joinPushType, centrifuge.StreamPosition{}, true
+	case 'l':
+		return rest, leavePushType, centrifuge.StreamPosition{}, true
+	}
+
+	stringContent := string(content)
+
+	if contentType == 'p' {
+		// new format p1:offset:epoch
+		stringContent = stringContent[3:] // offset:epoch
+		epochDelimiterPos := strings.Index(stringContent, contentSep)
+		if epochDelimiterPos <= 0 {
+			return rest, pubPushType, centrifuge.StreamPosition{Epoch: epoch, Offset: offset}, false
+		}
+		var err error
+		offset, err = strconv.ParseUint(stringContent[:epochDelimiterPos], 10, 64)
+		epoch = stringContent[epochDelimiterPos+1:]
+		return rest, pubPushType, centrifuge.StreamPosition{Epoch: epoch, Offset: offset}, err == nil
+	}
+
+	// old format with offset only: __offset__
+	var err error
+	offset, err = strconv.ParseUint(stringContent, 10, 64)
+	return rest, pubPushType, centrifuge.StreamPosition{Epoch: epoch, Offset: offset}, err == nil
+}
+
+func sliceOfPubsStream(result interface{}, err error) ([]*centrifuge.Publication, error) {
+	values, err := redis.Values(result, err)
+	if err != nil {
+		return nil, err
+	}
+	pubs := make([]*centrifuge.Publication, 0, len(values))
+
+	for i := 0; i < len(values); i++ {
+		streamElementValues, err := redis.Values(values[i], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(streamElementValues) != 2 {
+			return nil, errors.New("malformed reply: number of streamElementValues is not 2")
+		}
+
+		offsetStr, err := redis.String(streamElementValues[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		hyphenPos := strings.Index(offsetStr, "-") // ex. "4-0", 4 is our offset.
+		if hyphenPos <= 0 {
+			return nil, fmt.Errorf("unexpected offset format: %s", offsetStr)
+		}
+		offset, err := strconv.ParseUint(offsetStr[:hyphenPos], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		val := streamElementValues[1]
+		payloadElementValues, err := redis.Values(val, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(payloadElementValues) < 2 {
+			return nil, errors.New("malformed reply: number of payloadElementValues less than 2")
+		}
+
+		pushData, ok := payloadElementValues[1].([]byte)
+		if !ok {
+			return nil, errors.New("error getting []byte push data")
+		}
+
+		var pub protocol.Publication
+		err = pub.UnmarshalVT(pushData)
+		if err != nil {
+			return nil, fmt.Errorf("can not unmarshal value to Publication: %v", err)
+		}
+		pub.Offset = offset
+		pubs = append(pubs, pubFromProto(&pub))
+	}
+	return pubs, nil
+}
+
+func sliceOfPubsList(result interface{}, err error) ([]*centrifuge.Publication, error) {
+	values, err := redis.Values(result, err)
+	if err != nil {
+		return nil, err
+	}
+	pubs := make([]*centrifuge.Publication, 0, len(values))
+
+	for i := len(values) - 1; i >= 0; i-- {
+		value, okValue := values[i].([]byte)
+		if !okValue {
+			return nil, errors.New("error getting Message value")
+		}
+
+		pushData, _, sp, ok := extractPushData(value)
+		if !ok {
+			return nil, fmt.Errorf("malformed publication value: %s", value)
+		}
+
+		var pub protocol.Publication
+		err = pub.UnmarshalVT(pushData)
+		if err != nil {
+			return nil, fmt.Errorf("can not unmarshal value to Pub: %v", err)
+		}
+		pub.Offset = sp.Offset
+		pubs = append(pubs, pubFromProto(&pub))
+	}
+	return pubs, nil
+}
@@ -0,0 +1,72 @@
+package redisengine
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ChannelGroupStore keeps source channels of channel groups in Redis as JSON
+// arrays, groups sharded between Redis shards of Broker by channel.
+type ChannelGroupStore struct {
+	broker *Broker
+}
+
+// NewChannelGroupStore creates ChannelGroupStore which uses shards and prefix
+// of Broker.
+func NewChannelGroupStore(b *Broker) (*ChannelGroupStore, error) {
+	if b == nil {
+		return nil, errors.New("channel group store: no broker provided")
+	}
+	return &ChannelGroupStore{broker: b}, nil
+}
+
+// ChannelGroup returns source channels of channel group, nil returned if
+// group not found.
+func (s *ChannelGroupStore) ChannelGroup(ch string) ([]string, error) {
+	shard := s.broker.getShard(ch)
+	key := s.channelGroupKey(shard, ch)
+	dr := shard.newDataRequest("GET", nil, key, []interface{}{key})
+	resp := shard.getDataResponse(dr)
+	value, err := redis.Bytes(resp.reply, resp.err)
+	if err != nil {
+		if err == redis.ErrNil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var sources []string
+	if err := json.Unmarshal(value, &sources); err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// SetChannelGroup sets source channels of channel group.
+func (s *ChannelGroupStore) SetChannelGroup(ch string, sources []string) error {
+	value, err := json.Marshal(sources)
+	if err != nil {
+		return err
+	}
+	shard := s.broker.getShard(ch)
+	key := s.channelGroupKey(shard, ch)
+	dr := shard.newDataRequest("SET", nil, key, []interface{}{key, value})
+	return shard.getDataResponse(dr).err
+}
+
+// RemoveChannelGroup removes channel group.
+func (s *ChannelGroupStore) RemoveChannelGroup(ch string) error {
+	shard := s.broker.getShard(ch)
+	key := s.channelGroupKey(shard, ch)
+	dr := shard.newDataRequest("DEL", nil, key, []interface{}{key})
+	return shard.getDataResponse(dr).err
+}
+
+func (s *ChannelGroupStore) channelGroupKey(shard *Shard, ch string) channelID {
+	prefix := s.broker.keyPrefix(ch)
+	if shard.useCluster {
+		ch = "{" + ch + "}"
+	}
+	return channelID(prefix + ".group." + ch)
+}
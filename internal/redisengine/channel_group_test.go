@@ -0,0 +1,13 @@
+package redisengine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelGroupKey(t *testing.T) {
+	s := &ChannelGroupStore{broker: &Broker{config: BrokerConfig{Prefix: "centrifugo"}}}
+	require.Equal(t, channelID("centrifugo.group.devices"), s.channelGroupKey(&Shard{}, "devices"))
+	require.Equal(t, channelID("centrifugo.group.{devices}"), s.channelGroupKey(&Shard{useCluster: true}, "devices"))
+}
@@ -0,0 +1,69 @@
+package redisengine
+
+import (
+	"errors"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ChannelOptionsStore keeps channel option overrides in Redis. Overrides live
+// in one hash per Redis shard, channel override is set on shard of channel.
+// Overrides are read from and removed on all shards, so entries left on
+// previous shard of channel after resharding are still found.
+type ChannelOptionsStore struct {
+	broker *Broker
+}
+
+// NewChannelOptionsStore creates ChannelOptionsStore which uses shards and
+// prefix of Broker.
+func NewChannelOptionsStore(b *Broker) (*ChannelOptionsStore, error) {
+	if b == nil {
+		return nil, errors.New("channel options store: no broker provided")
+	}
+	return &ChannelOptionsStore{broker: b}, nil
+}
+
+// SetChannelOptionsOverride saves override of channel.
+func (s *ChannelOptionsStore) SetChannelOptionsOverride(ch string, data []byte) error {
+	shard := s.broker.getShard(ch)
+	key := s.channelOptionsKey()
+	dr := shard.newDataRequest("HSET", nil, key, []interface{}{key, ch, data})
+	return shard.getDataResponse(dr).err
+}
+
+// DeleteChannelOptionsOverride removes override of channel.
+func (s *ChannelOptionsStore) DeleteChannelOptionsOverride(ch string) error {
+	key := s.channelOptionsKey()
+	for _, shard := range s.broker.getShards() {
+		dr := shard.newDataRequest("HDEL", nil, key, []interface{}{key, ch})
+		if err := shard.getDataResponse(dr).err; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChannelOptionsOverrides returns overrides of all channels.
+func (s *ChannelOptionsStore) ChannelOptionsOverrides() (map[string][]byte, error) {
+	key := s.channelOptionsKey()
+	overrides := map[string][]byte{}
+	for _, shard := range s.broker.getShards() {
+		dr := shard.newDataRequest("HGETALL", nil, key, []interface{}{key})
+		resp := shard.getDataResponse(dr)
+		values, err := redis.ByteSlices(resp.reply, resp.err)
+		if err != nil {
+			return nil, err
+		}
+		if len(values)%2 != 0 {
+			return nil, errors.New("channel options store: wrong number of values in reply")
+		}
+		for i := 0; i < len(values); i += 2 {
+			overrides[string(values[i])] = values[i+1]
+		}
+	}
+	return overrides, nil
+}
+
+func (s *ChannelOptionsStore) channelOptionsKey() channelID {
+	return channelID(s.broker.config.Prefix + ".channel_options")
+}
@@ -0,0 +1,12 @@
+package redisengine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelOptionsKey(t *testing.T) {
+	s := &ChannelOptionsStore{broker: &Broker{config: BrokerConfig{Prefix: "centrifugo"}}}
+	require.Equal(t, channelID("centrifugo.channel_options"), s.channelOptionsKey())
+}
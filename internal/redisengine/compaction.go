@@ -0,0 +1,180 @@
+package redisengine
+
+import (
+	"errors"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/gomodule/redigo/redis"
+)
+
+// DefaultHistoryCompactionInterval is a default value for
+// BrokerConfig.HistoryCompactionInterval.
+const DefaultHistoryCompactionInterval = time.Second
+
+const (
+	// redisCompactionChannelsBatch is a maximum number of channels popped from
+	// set of channels waiting for compaction in one compaction round.
+	redisCompactionChannelsBatch = 64
+	// redisCompactionOffsetsBatch is a maximum number of publications removed
+	// from channel stream by one compaction script call.
+	redisCompactionOffsetsBatch = 512
+	// redisCompactionPendingSuffix is a suffix for set of channels waiting
+	// for compaction.
+	redisCompactionPendingSuffix = ".compaction.pending"
+)
+
+// ErrHistoryCompactionNotSupported returned from CompactHistory when history
+// kept in Redis lists.
+var ErrHistoryCompactionNotSupported = errors.New("history compaction not supported with lists")
+
+const (
+	// Remember offset of the latest publication for compaction key and put
+	// offset of superseded publication into index of offsets to remove from
+	// stream. Compaction keys kept in hash together with epoch of stream, hash
+	// and index cleared when epoch changes. Hash and index expire together
+	// with stream.
+	// KEYS[1] - history stream key
+	// KEYS[2] - compaction hash key
+	// KEYS[3] - compaction index sorted set key
+	// ARGV[1] - compaction key
+	// ARGV[2] - publication offset
+	// ARGV[3] - publication epoch
+	// Returns 1 if some publication must be removed from stream.
+	markCompactionSource = `
+local epoch = redis.call("hget", KEYS[2], "e")
+if epoch ~= ARGV[3] then
+  redis.call("del", KEYS[2], KEYS[3])
+  redis.call("hset", KEYS[2], "e", ARGV[3])
+end
+local field = "k:" .. ARGV[1]
+local offset = tonumber(ARGV[2])
+local superseded = false
+local prev = redis.call("hget", KEYS[2], field)
+if prev ~= false and tonumber(prev) >= offset then
+  if tonumber(prev) > offset then
+    superseded = offset
+  end
+else
+  redis.call("hset", KEYS[2], field, offset)
+  if prev ~= false then
+    superseded = tonumber(prev)
+  end
+end
+if superseded ~= false then
+  redis.call("zadd", KEYS[3], superseded, superseded)
+end
+local ttl = redis.call("pttl", KEYS[1])
+if ttl > 0 then
+  redis.call("pexpire", KEYS[2], ttl)
+  if superseded ~= false then
+    redis.call("pexpire", KEYS[3], ttl)
+  end
+end
+if superseded ~= false then
+  return 1
+end
+return 0
+	`
+
+	// Remove superseded publications from stream.
+	// KEYS[1] - history stream key
+	// KEYS[2] - compaction index sorted set key
+	// ARGV[1] - maximum number of publications to remove
+	// Returns number of publications still waiting for removal.
+	compactStreamSource = `
+local offsets = redis.call("zrange", KEYS[2], 0, tonumber(ARGV[1]) - 1)
+if #offsets > 0 then
+  local ids = {}
+  for i, offset in ipairs(offsets) do
+    ids[i] = offset .. "-0"
+  end
+  redis.call("xdel", KEYS[1], unpack(ids))
+  redis.call("zremrangebyrank", KEYS[2], 0, #offsets - 1)
+end
+return redis.call("zcard", KEYS[2])
+	`
+)
+
+// CompactHistory makes publication at stream position sp the latest one for
+// key in channel history. Publication previously published with the same key
+// is removed from history in background by a compaction loop running on every
+// shard, so history keeps only current value of every key. Compaction keys
+// of channel kept in Redis hash, offsets of superseded publications in sorted
+// set index.
+func (b *Broker) CompactHistory(ch string, key string, sp centrifuge.StreamPosition) error {
+	if b.config.UseLists {
+		return ErrHistoryCompactionNotSupported
+	}
+	s := b.getShard(ch)
+	streamKey := b.historyStreamKey(s, ch)
+	hashKey, indexKey := b.compactionKeys(s, ch)
+	dr := s.newDataRequest("", b.markCompactionScript, streamKey, []interface{}{streamKey, hashKey, indexKey, key, sp.Offset, sp.Epoch})
+	resp := s.getDataResponse(dr)
+	superseded, err := redis.Int(resp.reply, resp.err)
+	if err != nil || superseded == 0 {
+		return err
+	}
+	return b.addCompactionPending(s, ch)
+}
+
+func (b *Broker) compactionKeys(s *Shard, ch string) (channelID, channelID) {
+	prefix := b.keyPrefix(ch)
+	if s.useCluster {
+		ch = "{" + ch + "}"
+	}
+	return channelID(prefix + ".stream.compaction." + ch), channelID(prefix + ".stream.compaction.index." + ch)
+}
+
+func (b *Broker) compactionPendingKey() channelID {
+	return channelID(b.config.Prefix + redisCompactionPendingSuffix)
+}
+
+func (b *Broker) addCompactionPending(s *Shard, channels ...interface{}) error {
+	key := b.compactionPendingKey()
+	dr := s.newDataRequest("SADD", nil, key, append([]interface{}{key}, channels...))
+	return s.getDataResponse(dr).err
+}
+
+// runHistoryCompaction periodically removes superseded publications from
+// streams of channels waiting for compaction in shard. Every node runs
+// compaction loop, channels are popped from pending set so each channel is
+// compacted by one node at a time.
+func (b *Broker) runHistoryCompaction(s *Shard) {
+	ticker := time.NewTicker(b.config.HistoryCompactionInterval)
+	defer ticker.Stop()
+	for {
+		<-ticker.C
+		key := b.compactionPendingKey()
+		dr := s.newDataRequest("SPOP", nil, key, []interface{}{key, redisCompactionChannelsBatch})
+		resp := s.getDataResponse(dr)
+		channels, err := redis.Strings(resp.reply, resp.err)
+		if err != nil {
+			b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error getting channels for history compaction", map[string]interface{}{"error": err.Error()}))
+			continue
+		}
+		var unfinished []interface{}
+		for _, ch := range channels {
+			remaining, err := b.compactStream(s, ch)
+			if err != nil {
+				b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error compacting channel history", map[string]interface{}{"error": err.Error(), "channel": ch}))
+			}
+			if err != nil || remaining > 0 {
+				unfinished = append(unfinished, ch)
+			}
+		}
+		if len(unfinished) > 0 {
+			if err := b.addCompactionPending(s, unfinished...); err != nil {
+				b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error returning channels for history compaction", map[string]interface{}{"error": err.Error()}))
+			}
+		}
+	}
+}
+
+func (b *Broker) compactStream(s *Shard, ch string) (int, error) {
+	streamKey := b.historyStreamKey(s, ch)
+	_, indexKey := b.compactionKeys(s, ch)
+	dr := s.newDataRequest("", b.compactStreamScript, streamKey, []interface{}{streamKey, indexKey, redisCompactionOffsetsBatch})
+	resp := s.getDataResponse(dr)
+	return redis.Int(resp.reply, resp.err)
+}
@@ -0,0 +1,29 @@
+package redisengine
+
+import (
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactionKeys(t *testing.T) {
+	b := &Broker{config: BrokerConfig{Prefix: "test"}}
+
+	hashKey, indexKey := b.compactionKeys(&Shard{}, "ch")
+	require.Equal(t, channelID("test.stream.compaction.ch"), hashKey)
+	require.Equal(t, channelID("test.stream.compaction.index.ch"), indexKey)
+
+	// In cluster all channel keys must belong to the same slot.
+	hashKey, indexKey = b.compactionKeys(&Shard{useCluster: true}, "ch")
+	require.Equal(t, channelID("test.stream.compaction.{ch}"), hashKey)
+	require.Equal(t, channelID("test.stream.compaction.index.{ch}"), indexKey)
+
+	require.Equal(t, channelID("test.compaction.pending"), b.compactionPendingKey())
+}
+
+func TestCompactHistoryLists(t *testing.T) {
+	b := &Broker{config: BrokerConfig{UseLists: true}}
+	err := b.CompactHistory("ch", "key", centrifuge.StreamPosition{Offset: 1, Epoch: "xyz"})
+	require.Equal(t, ErrHistoryCompactionNotSupported, err)
+}
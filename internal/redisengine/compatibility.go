@@ -0,0 +1,40 @@
+package redisengine
+
+import "fmt"
+
+// Compatibility modes for Redis-compatible servers. Engine never relies on
+// PUBSUB CHANNELS command so it does not need a toggle.
+const (
+	CompatibilityRedis     = ""
+	CompatibilityKeyDB     = "keydb"
+	CompatibilityDragonfly = "dragonfly"
+)
+
+// CompatibilityConfig toggles engine behaviour for Redis-compatible servers
+// which do not support every command identically to Redis.
+type CompatibilityConfig struct {
+	// DisableScriptCache sends Lua script source with EVAL on every call
+	// instead of loading scripts with SCRIPT LOAD and calling EVALSHA in
+	// pipeline.
+	DisableScriptCache bool
+	// PingCommand keeps PUB/SUB connections alive with PING command sent over
+	// PUB/SUB connection instead of publishing to ping channel.
+	PingCommand bool
+	// AllowActiveReplica treats KeyDB active replicas, which accept writes,
+	// as masters when checking role of Sentinel discovered server.
+	AllowActiveReplica bool
+}
+
+// CompatibilityByName returns CompatibilityConfig for compatibility mode.
+func CompatibilityByName(name string) (CompatibilityConfig, error) {
+	switch name {
+	case CompatibilityRedis:
+		return CompatibilityConfig{}, nil
+	case CompatibilityKeyDB:
+		return CompatibilityConfig{AllowActiveReplica: true}, nil
+	case CompatibilityDragonfly:
+		return CompatibilityConfig{DisableScriptCache: true, PingCommand: true}, nil
+	default:
+		return CompatibilityConfig{}, fmt.Errorf("unknown compatibility mode: %s", name)
+	}
+}
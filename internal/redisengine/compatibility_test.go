@@ -0,0 +1,25 @@
+package redisengine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompatibilityByName(t *testing.T) {
+	c, err := CompatibilityByName(CompatibilityRedis)
+	require.NoError(t, err)
+	require.Equal(t, CompatibilityConfig{}, c)
+
+	c, err = CompatibilityByName(CompatibilityKeyDB)
+	require.NoError(t, err)
+	require.True(t, c.AllowActiveReplica)
+
+	c, err = CompatibilityByName(CompatibilityDragonfly)
+	require.NoError(t, err)
+	require.True(t, c.DisableScriptCache)
+	require.True(t, c.PingCommand)
+
+	_, err = CompatibilityByName("unknown")
+	require.Error(t, err)
+}
@@ -0,0 +1,39 @@
+//go:build integration
+// +build integration
+
+package redisengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/enginetest"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance requires Redis running on localhost:6379.
+func TestConformance(t *testing.T) {
+	enginetest.Run(t, func(t *testing.T) enginetest.Engine {
+		n, _ := centrifuge.New(centrifuge.DefaultConfig)
+		shard, err := NewShard(n, ShardConfig{Address: "127.0.0.1:6379"})
+		require.NoError(t, err)
+		b, err := NewBroker(n, BrokerConfig{
+			Shards:         []*Shard{shard},
+			Prefix:         "centrifugo_conformance",
+			HistoryMetaTTL: 2 * time.Second,
+		})
+		require.NoError(t, err)
+		pm, err := NewPresenceManager(n, PresenceManagerConfig{
+			Shards: []*Shard{shard},
+			Prefix: "centrifugo_conformance",
+		})
+		require.NoError(t, err)
+		return enginetest.Engine{
+			Broker:          b,
+			PresenceManager: pm,
+			HistoryMetaTTL:  2 * time.Second,
+		}
+	})
+}
@@ -0,0 +1,12 @@
+package redisengine
+
+import (
+	"github.com/centrifugal/centrifugo/v3/internal/errorcode"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+func init() {
+	errorcode.Map(ErrHistoryFromTimeNotSupported, centrifuge.ErrorNotAvailable)
+	errorcode.Map(ErrHistoryCompactionNotSupported, centrifuge.ErrorNotAvailable)
+}
@@ -0,0 +1,132 @@
+package redisengine
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Get channel history state without fetching publications. Script does not
+// modify keys so epoch is empty if history meta does not exist yet.
+// KEYS[1] - history list or stream key
+// KEYS[2] - history meta hash key
+// ARGV[1] - "1" if history kept in list
+const historyMetaSource = `
+local offset = redis.call("hget", KEYS[2], "s")
+local epoch = redis.call("hget", KEYS[2], "e")
+local size
+local oldest = false
+if ARGV[1] == "1" then
+  size = redis.call("llen", KEYS[1])
+  oldest = redis.call("lindex", KEYS[1], -1)
+else
+  size = redis.call("xlen", KEYS[1])
+  local first = redis.call("xrange", KEYS[1], "-", "+", "COUNT", 1)
+  if #first > 0 then
+    oldest = first[1][1]
+  end
+end
+local ttl = redis.call("pttl", KEYS[1])
+return {offset, epoch, size, oldest, ttl}
+	`
+
+// HistoryMeta describes state of channel history stream.
+type HistoryMeta struct {
+	// NumPublications is a number of publications currently kept in history.
+	NumPublications int
+	// OldestOffset is an offset of the oldest publication in history, zero
+	// if history is empty.
+	OldestOffset uint64
+	// Offset is a top offset of stream.
+	Offset uint64
+	// Epoch of stream, empty if stream was never used.
+	Epoch string
+	// TTL is a remaining time until history expires, zero if history is empty.
+	TTL time.Duration
+}
+
+// HistoryMeta returns history state of channel without fetching publications.
+func (b *Broker) HistoryMeta(ch string) (HistoryMeta, error) {
+	s := b.getShard(ch)
+	var historyKey channelID
+	if b.config.UseLists {
+		historyKey = b.historyListKey(s, ch)
+	} else {
+		historyKey = b.historyStreamKey(s, ch)
+	}
+	historyMetaKey := b.historyMetaKey(s, ch)
+	useLists := "0"
+	if b.config.UseLists {
+		useLists = "1"
+	}
+	dr := s.newDataRequest("", b.historyMetaScript, historyKey, []interface{}{historyKey, historyMetaKey, useLists})
+	resp := s.getDataResponse(dr)
+	if resp.err != nil {
+		return HistoryMeta{}, resp.err
+	}
+	return extractHistoryMeta(resp.reply, b.config.UseLists)
+}
+
+func extractHistoryMeta(reply interface{}, useLists bool) (HistoryMeta, error) {
+	results, err := redis.Values(reply, nil)
+	if err != nil {
+		return HistoryMeta{}, err
+	}
+	if len(results) != 5 {
+		return HistoryMeta{}, errors.New("malformed history meta reply")
+	}
+	var meta HistoryMeta
+	if results[0] != nil {
+		offset, err := redis.Uint64(results[0], nil)
+		if err != nil {
+			return HistoryMeta{}, err
+		}
+		meta.Offset = offset
+	}
+	if results[1] != nil {
+		epoch, err := redis.String(results[1], nil)
+		if err != nil {
+			return HistoryMeta{}, err
+		}
+		meta.Epoch = epoch
+	}
+	size, err := redis.Int(results[2], nil)
+	if err != nil {
+		return HistoryMeta{}, err
+	}
+	meta.NumPublications = size
+	if results[3] != nil {
+		oldest, err := redis.Bytes(results[3], nil)
+		if err != nil {
+			return HistoryMeta{}, err
+		}
+		if useLists {
+			_, _, sp, ok := extractPushData(oldest)
+			if !ok {
+				return HistoryMeta{}, errors.New("malformed oldest publication in history")
+			}
+			meta.OldestOffset = sp.Offset
+		} else {
+			id := string(oldest)
+			hyphenPos := strings.Index(id, "-") // ex. "4-0", 4 is our offset.
+			if hyphenPos <= 0 {
+				return HistoryMeta{}, errors.New("unexpected offset format: " + id)
+			}
+			meta.OldestOffset, err = strconv.ParseUint(id[:hyphenPos], 10, 64)
+			if err != nil {
+				return HistoryMeta{}, err
+			}
+		}
+	}
+	ttl, err := redis.Int64(results[4], nil)
+	if err != nil {
+		return HistoryMeta{}, err
+	}
+	if ttl > 0 {
+		meta.TTL = time.Duration(ttl) * time.Millisecond
+	}
+	return meta, nil
+}
@@ -0,0 +1,25 @@
+package redisengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractHistoryMeta(t *testing.T) {
+	meta, err := extractHistoryMeta([]interface{}{[]byte("10"), []byte("ABCD"), int64(3), []byte("8-0"), int64(5000)}, false)
+	require.NoError(t, err)
+	require.Equal(t, HistoryMeta{NumPublications: 3, OldestOffset: 8, Offset: 10, Epoch: "ABCD", TTL: 5 * time.Second}, meta)
+
+	meta, err = extractHistoryMeta([]interface{}{[]byte("10"), []byte("ABCD"), int64(2), []byte("__p1:9:ABCD__data"), int64(-1)}, true)
+	require.NoError(t, err)
+	require.Equal(t, HistoryMeta{NumPublications: 2, OldestOffset: 9, Offset: 10, Epoch: "ABCD"}, meta)
+
+	meta, err = extractHistoryMeta([]interface{}{nil, nil, int64(0), nil, int64(-2)}, false)
+	require.NoError(t, err)
+	require.Equal(t, HistoryMeta{}, meta)
+
+	_, err = extractHistoryMeta([]interface{}{nil, nil, int64(0), []byte("bad"), int64(-2)}, false)
+	require.Error(t, err)
+}
@@ -0,0 +1,102 @@
+package redisengine
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+
+	"github.com/centrifugal/centrifugo/v3/internal/inbox"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Add entry to user inbox list keeping only last entries.
+// KEYS[1] - inbox list key
+// ARGV[1] - entry
+// ARGV[2] - max size of inbox
+// ARGV[3] - inbox TTL in milliseconds
+const addToInboxSource = `
+redis.call("rpush", KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 then
+  redis.call("ltrim", KEYS[1], -tonumber(ARGV[2]), -1)
+end
+redis.call("pexpire", KEYS[1], ARGV[3])
+	`
+
+// InboxStore keeps inboxes of users in Redis. Inbox of user is a list of
+// "<time>:<data>" entries, users sharded between Redis shards of Broker.
+type InboxStore struct {
+	broker    *Broker
+	addScript *redis.Script
+}
+
+// NewInboxStore creates InboxStore which uses shards and prefix of Broker.
+func NewInboxStore(b *Broker) (*InboxStore, error) {
+	if b == nil {
+		return nil, errors.New("inbox store: no broker provided")
+	}
+	s := &InboxStore{
+		broker:    b,
+		addScript: redis.NewScript(1, addToInboxSource),
+	}
+	b.registerScripts(s.addScript)
+	return s, nil
+}
+
+// AddToInbox adds entry to inbox of user.
+func (s *InboxStore) AddToInbox(user string, e inbox.Entry, opts inbox.Options) error {
+	shard := s.broker.getShard(user)
+	key := s.inboxKey(shard, user)
+	entry := append(strconv.AppendInt(nil, e.Time, 10), ':')
+	entry = append(entry, e.Data...)
+	dr := shard.newDataRequest("", s.addScript, key, []interface{}{key, entry, opts.Size, opts.TTL.Milliseconds()})
+	return shard.getDataResponse(dr).err
+}
+
+// PeekInbox returns all entries of user inbox without removing them.
+func (s *InboxStore) PeekInbox(user string) ([]inbox.Entry, error) {
+	shard := s.broker.getShard(user)
+	key := s.inboxKey(shard, user)
+	dr := shard.newDataRequest("LRANGE", nil, key, []interface{}{key, 0, -1})
+	resp := shard.getDataResponse(dr)
+	values, err := redis.ByteSlices(resp.reply, resp.err)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]inbox.Entry, 0, len(values))
+	for _, value := range values {
+		e, err := parseInboxEntry(value)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// TrimInbox removes n oldest entries from inbox of user.
+func (s *InboxStore) TrimInbox(user string, n int) error {
+	shard := s.broker.getShard(user)
+	key := s.inboxKey(shard, user)
+	dr := shard.newDataRequest("LTRIM", nil, key, []interface{}{key, n, -1})
+	return shard.getDataResponse(dr).err
+}
+
+func parseInboxEntry(value []byte) (inbox.Entry, error) {
+	sep := bytes.IndexByte(value, ':')
+	if sep <= 0 {
+		return inbox.Entry{}, errors.New("malformed inbox entry")
+	}
+	t, err := strconv.ParseInt(string(value[:sep]), 10, 64)
+	if err != nil {
+		return inbox.Entry{}, err
+	}
+	return inbox.Entry{Data: value[sep+1:], Time: t}, nil
+}
+
+func (s *InboxStore) inboxKey(shard *Shard, user string) channelID {
+	if shard.useCluster {
+		user = "{" + user + "}"
+	}
+	return channelID(s.broker.config.Prefix + ".inbox." + user)
+}
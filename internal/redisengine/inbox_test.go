@@ -0,0 +1,25 @@
+package redisengine
+
+import (
+	"testing"
+
+	"github.com/centrifugal/centrifugo/v3/internal/inbox"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInboxEntry(t *testing.T) {
+	e, err := parseInboxEntry([]byte(`1625140800000:{"a":"b:c"}`))
+	require.NoError(t, err)
+	require.Equal(t, inbox.Entry{Data: []byte(`{"a":"b:c"}`), Time: 1625140800000}, e)
+	_, err = parseInboxEntry([]byte(`{}`))
+	require.Error(t, err)
+	_, err = parseInboxEntry([]byte(`x:{}`))
+	require.Error(t, err)
+}
+
+func TestInboxKey(t *testing.T) {
+	s := &InboxStore{broker: &Broker{config: BrokerConfig{Prefix: "centrifugo"}}}
+	require.Equal(t, channelID("centrifugo.inbox.42"), s.inboxKey(&Shard{}, "42"))
+	require.Equal(t, channelID("centrifugo.inbox.{42}"), s.inboxKey(&Shard{useCluster: true}, "42"))
+}
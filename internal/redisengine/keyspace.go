@@ -0,0 +1,271 @@
+package redisengine
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/gomodule/redigo/redis"
+)
+
+// DefaultKeyspaceReportInterval is a default value for
+// KeyspaceReporterConfig.Interval.
+const DefaultKeyspaceReportInterval = 5 * time.Minute
+
+// DefaultKeyspaceSampleKeys is a default value for
+// KeyspaceReporterConfig.SampleKeys.
+const DefaultKeyspaceSampleKeys = 1000
+
+// DefaultKeyspaceMemorySamples is a default value for
+// KeyspaceReporterConfig.MemorySamples.
+const DefaultKeyspaceMemorySamples = 5
+
+// keyspaceScanCount is a COUNT hint of SCAN iterations.
+const keyspaceScanCount = 100
+
+// Types of keys in keyspace report.
+const (
+	KeyTypeHistory  = "history"
+	KeyTypePresence = "presence"
+)
+
+// keyTypeMarkers map parts of key following prefix to key types. Keys with
+// memberSuffix have client ID after channel.
+var keyTypeMarkers = []struct {
+	marker       string
+	keyType      string
+	memberSuffix bool
+}{
+	{".presence.data.", KeyTypePresence, false},
+	{".presence.expire.", KeyTypePresence, false},
+	{".presence.index.", KeyTypePresence, true},
+	{".presence.member.", KeyTypePresence, true},
+	{".stream.compaction.index.", KeyTypeHistory, false},
+	{".stream.compaction.", KeyTypeHistory, false},
+	{".stream.", KeyTypeHistory, false},
+	{".list.", KeyTypeHistory, false},
+	{".meta.", KeyTypeHistory, false},
+}
+
+// KeyspaceReporterConfig of KeyspaceReporter.
+type KeyspaceReporterConfig struct {
+	// Namespace returns label of channel namespace. Channels of top-level
+	// namespace reported under empty label.
+	Namespace func(ch string) string
+	// Interval of reports. By default DefaultKeyspaceReportInterval used.
+	Interval time.Duration
+	// SampleKeys is a max number of keys sampled on every shard per report.
+	// By default DefaultKeyspaceSampleKeys used.
+	SampleKeys int
+	// MemorySamples is a number of nested values sampled by MEMORY USAGE.
+	// By default DefaultKeyspaceMemorySamples used.
+	MemorySamples int
+}
+
+// KeyspaceUsage is a memory used by sampled keys of one type in namespace.
+type KeyspaceUsage struct {
+	Namespace string `json:"namespace"`
+	Type      string `json:"type"`
+	Keys      int    `json:"keys"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// KeyspaceShardReport is a report of one Redis shard.
+type KeyspaceShardReport struct {
+	Shard string `json:"shard"`
+	// Complete is true when all keys of shard were sampled, otherwise usage
+	// contains only first SampleKeys keys returned by SCAN.
+	Complete bool            `json:"complete"`
+	Usage    []KeyspaceUsage `json:"usage"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// KeyspaceReport is a report of Redis memory used by history and presence
+// keys.
+type KeyspaceReport struct {
+	Time   time.Time             `json:"time"`
+	Shards []KeyspaceShardReport `json:"shards"`
+}
+
+// KeyspaceReporter periodically samples Redis memory used by history and
+// presence keys per channel namespace using SCAN and MEMORY USAGE, exports
+// sampled sizes as metrics and keeps last report. Keys with custom prefix set
+// by BrokerConfig.KeyPrefix reported too as long as it starts with Prefix.
+// Shards in Redis Cluster mode are skipped since SCAN only iterates over
+// keys of a single cluster node.
+type KeyspaceReporter struct {
+	broker *Broker
+	config KeyspaceReporterConfig
+
+	mu     sync.RWMutex
+	report *KeyspaceReport
+}
+
+// NewKeyspaceReporter creates KeyspaceReporter of Broker keys.
+func NewKeyspaceReporter(b *Broker, c KeyspaceReporterConfig) *KeyspaceReporter {
+	if c.Interval == 0 {
+		c.Interval = DefaultKeyspaceReportInterval
+	}
+	if c.SampleKeys == 0 {
+		c.SampleKeys = DefaultKeyspaceSampleKeys
+	}
+	if c.MemorySamples == 0 {
+		c.MemorySamples = DefaultKeyspaceMemorySamples
+	}
+	if c.Namespace == nil {
+		c.Namespace = func(string) string { return "" }
+	}
+	return &KeyspaceReporter{broker: b, config: c}
+}
+
+// Run reports until ctx done.
+func (r *KeyspaceReporter) Run(ctx context.Context) {
+	for {
+		r.Report()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.config.Interval):
+		}
+	}
+}
+
+// Report samples keys of all shards, updates metrics and last report.
+func (r *KeyspaceReporter) Report() *KeyspaceReport {
+	r.broker.shardsMu.RLock()
+	shards := make([]*Shard, len(r.broker.shards))
+	copy(shards, r.broker.shards)
+	r.broker.shardsMu.RUnlock()
+
+	report := &KeyspaceReport{Time: time.Now()}
+	for _, s := range shards {
+		if s.useCluster {
+			continue
+		}
+		shardReport, err := r.reportShard(s)
+		if err != nil {
+			r.broker.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error sampling Redis keyspace", map[string]interface{}{"shard": s.string(), "error": err.Error()}))
+			shardReport.Error = err.Error()
+		}
+		for _, u := range shardReport.Usage {
+			keyspaceKeysGauge.WithLabelValues(s.string(), u.Namespace, u.Type).Set(float64(u.Keys))
+			keyspaceBytesGauge.WithLabelValues(s.string(), u.Namespace, u.Type).Set(float64(u.Bytes))
+		}
+		report.Shards = append(report.Shards, shardReport)
+	}
+
+	r.mu.Lock()
+	r.report = report
+	r.mu.Unlock()
+	return report
+}
+
+// LastReport returns last report, false returned if no report made yet.
+func (r *KeyspaceReporter) LastReport() (*KeyspaceReport, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.report, r.report != nil
+}
+
+// KeyspaceReport returns last report ready for JSON encoding.
+func (r *KeyspaceReporter) KeyspaceReport() (interface{}, bool) {
+	return r.LastReport()
+}
+
+func (r *KeyspaceReporter) reportShard(s *Shard) (KeyspaceShardReport, error) {
+	shardReport := KeyspaceShardReport{Shard: s.string()}
+	conn := s.pool.Get()
+	defer func() { _ = conn.Close() }()
+
+	prefix := r.broker.config.Prefix
+	usage := map[[2]string]*KeyspaceUsage{}
+	var numKeys int
+	cursor := "0"
+	for {
+		reply, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", prefix+".*", "COUNT", keyspaceScanCount))
+		if err != nil {
+			return shardReport, err
+		}
+		var keys []string
+		if _, err := redis.Scan(reply, &cursor, &keys); err != nil {
+			return shardReport, err
+		}
+		for _, key := range keys {
+			if numKeys >= r.config.SampleKeys {
+				break
+			}
+			keyType, ch, ok := parseKeyspaceKey(prefix, key)
+			if !ok {
+				continue
+			}
+			size, err := redis.Int64(conn.Do("MEMORY", "USAGE", key, "SAMPLES", r.config.MemorySamples))
+			if err == redis.ErrNil {
+				// Key expired after SCAN.
+				continue
+			} else if err != nil {
+				return shardReport, err
+			}
+			numKeys++
+			ns := r.config.Namespace(ch)
+			u, ok := usage[[2]string{ns, keyType}]
+			if !ok {
+				u = &KeyspaceUsage{Namespace: ns, Type: keyType}
+				usage[[2]string{ns, keyType}] = u
+			}
+			u.Keys++
+			u.Bytes += size
+		}
+		if cursor == "0" {
+			shardReport.Complete = numKeys < r.config.SampleKeys
+			break
+		}
+		if numKeys >= r.config.SampleKeys {
+			break
+		}
+	}
+
+	for _, u := range usage {
+		shardReport.Usage = append(shardReport.Usage, *u)
+	}
+	sort.Slice(shardReport.Usage, func(i, j int) bool {
+		return shardReport.Usage[i].Bytes > shardReport.Usage[j].Bytes
+	})
+	return shardReport, nil
+}
+
+// parseKeyspaceKey extracts key type and channel from history or presence key.
+func parseKeyspaceKey(prefix string, key string) (string, string, bool) {
+	if !strings.HasPrefix(key, prefix) {
+		return "", "", false
+	}
+	rest := key[len(prefix):]
+	// Tenant key prefixes are placed between prefix and key type marker so
+	// the earliest marker found in key wins.
+	index := -1
+	var keyType, marker string
+	var memberSuffix bool
+	for _, m := range keyTypeMarkers {
+		i := strings.Index(rest, m.marker)
+		if i >= 0 && (index < 0 || i < index) {
+			index, keyType, marker, memberSuffix = i, m.keyType, m.marker, m.memberSuffix
+		}
+	}
+	if index < 0 {
+		return "", "", false
+	}
+	ch := rest[index+len(marker):]
+	if memberSuffix {
+		i := strings.LastIndex(ch, ".")
+		if i < 0 {
+			return "", "", false
+		}
+		ch = ch[:i]
+	}
+	if strings.HasPrefix(ch, "{") && strings.HasSuffix(ch, "}") {
+		ch = ch[1 : len(ch)-1]
+	}
+	return keyType, ch, true
+}
@@ -0,0 +1,36 @@
+package redisengine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeyspaceKey(t *testing.T) {
+	testCases := []struct {
+		key     string
+		keyType string
+		ch      string
+		ok      bool
+	}{
+		{"centrifugo.stream.chat:1", KeyTypeHistory, "chat:1", true},
+		{"centrifugo.list.chat:1", KeyTypeHistory, "chat:1", true},
+		{"centrifugo.meta.{chat:1}", KeyTypeHistory, "chat:1", true},
+		{"centrifugo.stream.compaction.index.chat", KeyTypeHistory, "chat", true},
+		{"centrifugo.presence.data.chat", KeyTypePresence, "chat", true},
+		{"centrifugo.presence.expire.chat", KeyTypePresence, "chat", true},
+		{"centrifugo.tenant.acme.presence.data.news", KeyTypePresence, "news", true},
+		{"centrifugo.presence.index.chat.3", KeyTypePresence, "chat", true},
+		{"centrifugo.presence.member.chat.a.b-c", KeyTypePresence, "chat.a", true},
+		{"centrifugo.presence.member.{chat}.b-c", KeyTypePresence, "chat", true},
+		{"centrifugo.tenant.acme.stream.a.list.b", KeyTypeHistory, "a.list.b", true},
+		{"centrifugo.lock.key", "", "", false},
+		{"other.stream.chat", "", "", false},
+	}
+	for _, tc := range testCases {
+		keyType, ch, ok := parseKeyspaceKey("centrifugo", tc.key)
+		require.Equal(t, tc.ok, ok, tc.key)
+		require.Equal(t, tc.keyType, keyType, tc.key)
+		require.Equal(t, tc.ch, ch, tc.key)
+	}
+}
@@ -0,0 +1,87 @@
+package redisengine
+
+import (
+	"errors"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// Acquire lock or extend lock already held by the same owner.
+	// KEYS[1] - lock key
+	// ARGV[1] - owner
+	// ARGV[2] - lock TTL in milliseconds
+	acquireLockSource = `
+if redis.call("set", KEYS[1], ARGV[1], "nx", "px", ARGV[2]) then
+  return 1
+end
+if redis.call("get", KEYS[1]) == ARGV[1] then
+  redis.call("pexpire", KEYS[1], ARGV[2])
+  return 1
+end
+return 0
+	`
+
+	// Release lock if it's held by owner.
+	// KEYS[1] - lock key
+	// ARGV[1] - owner
+	releaseLockSource = `
+if redis.call("get", KEYS[1]) == ARGV[1] then
+  return redis.call("del", KEYS[1])
+end
+return 0
+	`
+)
+
+// Locker keeps distributed locks in Redis. Locks are sharded between Redis
+// shards of Broker by lock name.
+type Locker struct {
+	node          *centrifuge.Node
+	broker        *Broker
+	acquireScript *redis.Script
+	releaseScript *redis.Script
+}
+
+// NewLocker creates Locker which uses shards and prefix of Broker.
+func NewLocker(n *centrifuge.Node, b *Broker) (*Locker, error) {
+	if b == nil {
+		return nil, errors.New("locker: no broker provided")
+	}
+	l := &Locker{
+		node:          n,
+		broker:        b,
+		acquireScript: redis.NewScript(1, acquireLockSource),
+		releaseScript: redis.NewScript(1, releaseLockSource),
+	}
+	b.registerScripts(l.acquireScript, l.releaseScript)
+	return l, nil
+}
+
+// AcquireLock acquires lock for owner for ttl. Lock already held by the same
+// owner extended for ttl. Returns false if lock held by another owner.
+func (l *Locker) AcquireLock(name string, owner string, ttl time.Duration) (bool, error) {
+	s := l.broker.getShard(name)
+	key := l.lockKey(s, name)
+	dr := s.newDataRequest("", l.acquireScript, key, []interface{}{key, owner, ttl.Milliseconds()})
+	resp := s.getDataResponse(dr)
+	return redis.Bool(resp.reply, resp.err)
+}
+
+// ReleaseLock releases lock held by owner. Returns false if lock not held by
+// owner.
+func (l *Locker) ReleaseLock(name string, owner string) (bool, error) {
+	s := l.broker.getShard(name)
+	key := l.lockKey(s, name)
+	dr := s.newDataRequest("", l.releaseScript, key, []interface{}{key, owner})
+	resp := s.getDataResponse(dr)
+	return redis.Bool(resp.reply, resp.err)
+}
+
+func (l *Locker) lockKey(s *Shard, name string) channelID {
+	if s.useCluster {
+		name = "{" + name + "}"
+	}
+	return channelID(l.broker.config.Prefix + ".lock." + name)
+}
@@ -0,0 +1,141 @@
+package redisengine
+
+import (
+	"errors"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// Set channel meta entry. Entries with TTL tracked in sorted set by
+	// expiration time, keys expire together with the longest living entry.
+	// KEYS[1] - meta hash key
+	// KEYS[2] - meta expiration sorted set key
+	// ARGV[1] - entry key
+	// ARGV[2] - entry value
+	// ARGV[3] - entry TTL in milliseconds, 0 means no expiration
+	setChannelMetaSource = `
+redis.replicate_commands()
+local function extend(key, existed, ttl)
+  local current = redis.call("pttl", key)
+  if not existed or (current >= 0 and current < ttl) then
+    redis.call("pexpire", key, ttl)
+  end
+end
+local hashExisted = redis.call("exists", KEYS[1]) == 1
+redis.call("hset", KEYS[1], ARGV[1], ARGV[2])
+local ttl = tonumber(ARGV[3])
+if ttl == 0 then
+  redis.call("zrem", KEYS[2], ARGV[1])
+  redis.call("persist", KEYS[1])
+  return 1
+end
+local now = redis.call("time")
+local ms = now[1] * 1000 + math.floor(now[2] / 1000)
+local zsetExisted = redis.call("exists", KEYS[2]) == 1
+redis.call("zadd", KEYS[2], ms + ttl, ARGV[1])
+extend(KEYS[1], hashExisted, ttl)
+extend(KEYS[2], zsetExisted, ttl)
+return 1
+	`
+
+	// Remove expired channel meta entries and return the rest.
+	// KEYS[1] - meta hash key
+	// KEYS[2] - meta expiration sorted set key
+	getChannelMetaSource = `
+redis.replicate_commands()
+local now = redis.call("time")
+local ms = now[1] * 1000 + math.floor(now[2] / 1000)
+local expired = redis.call("zrangebyscore", KEYS[2], "0", ms)
+if #expired > 0 then
+  redis.call("hdel", KEYS[1], unpack(expired))
+  redis.call("zremrangebyscore", KEYS[2], "0", ms)
+end
+return redis.call("hgetall", KEYS[1])
+	`
+
+	// Delete channel meta entry.
+	// KEYS[1] - meta hash key
+	// KEYS[2] - meta expiration sorted set key
+	// ARGV[1] - entry key
+	deleteChannelMetaSource = `
+redis.call("zrem", KEYS[2], ARGV[1])
+return redis.call("hdel", KEYS[1], ARGV[1])
+	`
+)
+
+// MetaStore keeps small key-value entries of channels in Redis. Entries of
+// channel live in one hash, channels sharded between Redis shards of Broker
+// the same way as channel data.
+type MetaStore struct {
+	node         *centrifuge.Node
+	broker       *Broker
+	setScript    *redis.Script
+	getScript    *redis.Script
+	deleteScript *redis.Script
+}
+
+// NewMetaStore creates MetaStore which uses shards and prefix of Broker.
+func NewMetaStore(n *centrifuge.Node, b *Broker) (*MetaStore, error) {
+	if b == nil {
+		return nil, errors.New("meta store: no broker provided")
+	}
+	m := &MetaStore{
+		node:         n,
+		broker:       b,
+		setScript:    redis.NewScript(2, setChannelMetaSource),
+		getScript:    redis.NewScript(2, getChannelMetaSource),
+		deleteScript: redis.NewScript(2, deleteChannelMetaSource),
+	}
+	b.registerScripts(m.setScript, m.getScript, m.deleteScript)
+	return m, nil
+}
+
+// SetChannelMeta sets value of channel meta key. Zero ttl means that entry
+// never expires.
+func (m *MetaStore) SetChannelMeta(ch string, key string, value []byte, ttl time.Duration) error {
+	s := m.broker.getShard(ch)
+	hashKey, zsetKey := m.metaKeys(s, ch)
+	dr := s.newDataRequest("", m.setScript, hashKey, []interface{}{hashKey, zsetKey, key, value, ttl.Milliseconds()})
+	resp := s.getDataResponse(dr)
+	return resp.err
+}
+
+// ChannelMeta returns all not expired meta entries of channel.
+func (m *MetaStore) ChannelMeta(ch string) (map[string][]byte, error) {
+	s := m.broker.getShard(ch)
+	hashKey, zsetKey := m.metaKeys(s, ch)
+	dr := s.newDataRequest("", m.getScript, hashKey, []interface{}{hashKey, zsetKey})
+	resp := s.getDataResponse(dr)
+	values, err := redis.ByteSlices(resp.reply, resp.err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("meta store: wrong number of values in reply")
+	}
+	meta := make(map[string][]byte, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		meta[string(values[i])] = values[i+1]
+	}
+	return meta, nil
+}
+
+// DeleteChannelMeta deletes channel meta key.
+func (m *MetaStore) DeleteChannelMeta(ch string, key string) error {
+	s := m.broker.getShard(ch)
+	hashKey, zsetKey := m.metaKeys(s, ch)
+	dr := s.newDataRequest("", m.deleteScript, hashKey, []interface{}{hashKey, zsetKey, key})
+	resp := s.getDataResponse(dr)
+	return resp.err
+}
+
+func (m *MetaStore) metaKeys(s *Shard, ch string) (channelID, channelID) {
+	prefix := m.broker.keyPrefix(ch) + ".meta."
+	if s.useCluster {
+		ch = "{" + ch + "}"
+	}
+	return channelID(prefix + "hash." + ch), channelID(prefix + "expire." + ch)
+}
@@ -0,0 +1,157 @@
+package redisengine
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricsNamespace = "centrifugo"
+
+var (
+	pubSubWorkersGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "redis_broker",
+		Name:      "pubsub_workers",
+		Help:      "Number of Redis PUB/SUB message processing workers.",
+	}, []string{"shard"})
+	pubSubWorkerSaturationGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "redis_broker",
+		Name:      "pubsub_worker_saturation",
+		Help:      "Queue fill ratio of the most loaded Redis PUB/SUB worker.",
+	}, []string{"shard"})
+	pubSubHotChannelsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "redis_broker",
+		Name:      "pubsub_hot_channels",
+		Help:      "Number of hot channels which messages processed by many Redis PUB/SUB workers.",
+	}, []string{"shard"})
+	pubSubWorkerQueueFullCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "redis_broker",
+		Name:      "pubsub_worker_queue_full",
+		Help:      "Number of times Redis PUB/SUB message waited for full worker queue.",
+	}, []string{"shard"})
+	resubscribePendingGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "redis_broker",
+		Name:      "resubscribe_pending_channels",
+		Help:      "Number of channels waiting for subscription after Redis PUB/SUB connection (re)established.",
+	}, []string{"shard"})
+	operationDurationHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "redis",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of Redis engine operations by shard including time spent in pipeline.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"shard", "op"})
+	operationErrorsCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "redis",
+		Name:      "operation_errors",
+		Help:      "Number of failed Redis engine operations by shard.",
+	}, []string{"shard", "op"})
+	publishRetriesCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "redis",
+		Name:      "publish_retries",
+		Help:      "Number of publish attempts retried due to Redis failover errors.",
+	}, []string{"shard"})
+	orderingViolationsCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "redis_broker",
+		Name:      "ordering_violations",
+		Help:      "Number of publications received from PUB/SUB out of channel order by violation type.",
+	}, []string{"type"})
+	keyspaceKeysGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "redis_keyspace",
+		Name:      "sampled_keys",
+		Help:      "Number of history and presence keys sampled by last keyspace report by shard, channel namespace and key type.",
+	}, []string{"shard", "namespace", "type"})
+	keyspaceBytesGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "redis_keyspace",
+		Name:      "sampled_bytes",
+		Help:      "Redis memory used by history and presence keys sampled by last keyspace report by shard, channel namespace and key type.",
+	}, []string{"shard", "namespace", "type"})
+)
+
+func init() {
+	prometheus.MustRegister(pubSubWorkersGauge)
+	prometheus.MustRegister(pubSubWorkerSaturationGauge)
+	prometheus.MustRegister(pubSubHotChannelsGauge)
+	prometheus.MustRegister(pubSubWorkerQueueFullCount)
+	prometheus.MustRegister(resubscribePendingGauge)
+	prometheus.MustRegister(operationDurationHistogram)
+	prometheus.MustRegister(operationErrorsCount)
+	prometheus.MustRegister(publishRetriesCount)
+	prometheus.MustRegister(orderingViolationsCount)
+	prometheus.MustRegister(keyspaceKeysGauge)
+	prometheus.MustRegister(keyspaceBytesGauge)
+}
+
+type pubSubPoolMetrics struct {
+	workers     prometheus.Gauge
+	saturation  prometheus.Gauge
+	hotChannels prometheus.Gauge
+	queueFull   prometheus.Counter
+}
+
+func newPubSubPoolMetrics(shard string) pubSubPoolMetrics {
+	return pubSubPoolMetrics{
+		workers:     pubSubWorkersGauge.WithLabelValues(shard),
+		saturation:  pubSubWorkerSaturationGauge.WithLabelValues(shard),
+		hotChannels: pubSubHotChannelsGauge.WithLabelValues(shard),
+		queueFull:   pubSubWorkerQueueFullCount.WithLabelValues(shard),
+	}
+}
+
+// Operations of Redis engine with metrics.
+const (
+	redisOpPublish        = "publish"
+	redisOpSubscribe      = "subscribe"
+	redisOpUnsubscribe    = "unsubscribe"
+	redisOpHistory        = "history"
+	redisOpRemoveHistory  = "remove_history"
+	redisOpAddPresence    = "add_presence"
+	redisOpRemovePresence = "remove_presence"
+	redisOpPresence       = "presence"
+)
+
+var redisOps = []string{
+	redisOpPublish, redisOpSubscribe, redisOpUnsubscribe, redisOpHistory,
+	redisOpRemoveHistory, redisOpAddPresence, redisOpRemovePresence, redisOpPresence,
+}
+
+type operationMetrics struct {
+	duration prometheus.Observer
+	errors   prometheus.Counter
+}
+
+// shardMetrics contains metrics of operations of shard, map is not modified
+// after creation.
+type shardMetrics map[string]operationMetrics
+
+func newShardMetrics(shard string) shardMetrics {
+	m := make(shardMetrics, len(redisOps))
+	for _, op := range redisOps {
+		m[op] = operationMetrics{
+			duration: operationDurationHistogram.WithLabelValues(shard, op),
+			errors:   operationErrorsCount.WithLabelValues(shard, op),
+		}
+	}
+	return m
+}
+
+func (m shardMetrics) observe(op string, started time.Time, err error) {
+	om, ok := m[op]
+	if !ok {
+		return
+	}
+	om.duration.Observe(time.Since(started).Seconds())
+	if err != nil {
+		om.errors.Inc()
+	}
+}
@@ -0,0 +1,25 @@
+package redisengine
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardMetricsObserve(t *testing.T) {
+	m := newShardMetrics("test_metrics:6379")
+	m.observe(redisOpPublish, time.Now(), nil)
+	m.observe(redisOpPublish, time.Now(), errors.New("boom"))
+	m.observe("unknown", time.Now(), errors.New("boom"))
+
+	require.Equal(t, float64(1), testutil.ToFloat64(operationErrorsCount.WithLabelValues("test_metrics:6379", redisOpPublish)))
+	require.Equal(t, float64(0), testutil.ToFloat64(operationErrorsCount.WithLabelValues("test_metrics:6379", redisOpHistory)))
+	require.True(t, testutil.CollectAndCount(operationDurationHistogram) > 0)
+
+	// Shards created in tests without NewShard have no metrics.
+	var empty shardMetrics
+	empty.observe(redisOpPublish, time.Now(), nil)
+}
@@ -0,0 +1,120 @@
+package redisengine
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// Redis PUB/SUB delivers messages published to one Redis instance in order
+// of publishing, so all publications of channel (which always belongs to one
+// shard) must be received by node in order of stream position. With
+// BrokerConfig.OrderingCheck broker verifies this: publications are stamped
+// with monotonically increasing per-channel sequence at publish time and node
+// detects gaps and reorders on receive.
+
+const (
+	// orderingSeqSource contains Lua script to increment channel sequence and
+	// publish message stamped with it.
+	// KEYS[1] - sequence key
+	// ARGV[1] - message payload
+	// ARGV[2] - channel to publish message to
+	// ARGV[3] - sequence key expiration time
+	orderingSeqSource = `
+local seq = redis.call("incr", KEYS[1])
+if ARGV[3] ~= '0' then
+	redis.call("expire", KEYS[1], ARGV[3])
+end
+redis.call("publish", ARGV[2], "__" .. "p1:" .. seq .. ":" .. "__" .. ARGV[1])
+return seq
+		`
+)
+
+type orderingViolation string
+
+const (
+	orderingOK      orderingViolation = ""
+	orderingGap     orderingViolation = "gap"
+	orderingReorder orderingViolation = "reorder"
+)
+
+// orderingChecker keeps last received stream position of channels.
+type orderingChecker struct {
+	mu        sync.Mutex
+	positions map[string]centrifuge.StreamPosition
+}
+
+func newOrderingChecker() *orderingChecker {
+	return &orderingChecker{positions: map[string]centrifuge.StreamPosition{}}
+}
+
+// check remembers position of publication received from channel and reports
+// whether it breaks order of positions. Publications without position are not
+// checked, new epoch starts new sequence.
+func (c *orderingChecker) check(ch string, sp centrifuge.StreamPosition) (orderingViolation, uint64) {
+	if sp.Offset == 0 {
+		return orderingOK, 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prev, ok := c.positions[ch]
+	if !ok || prev.Epoch != sp.Epoch {
+		c.positions[ch] = sp
+		return orderingOK, 0
+	}
+	if sp.Offset <= prev.Offset {
+		return orderingReorder, prev.Offset
+	}
+	c.positions[ch] = sp
+	if sp.Offset != prev.Offset+1 {
+		return orderingGap, prev.Offset
+	}
+	return orderingOK, prev.Offset
+}
+
+// forget removes channel position, called when node unsubscribes from channel.
+func (c *orderingChecker) forget(ch string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.positions, ch)
+}
+
+// reset removes all positions. Messages published while PUB/SUB connection was
+// broken are lost, so checking starts over on reconnect.
+func (c *orderingChecker) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.positions = map[string]centrifuge.StreamPosition{}
+}
+
+func (b *Broker) orderingSeqKey(s *Shard, ch string) channelID {
+	prefix := b.keyPrefix(ch)
+	if s.useCluster {
+		ch = "{" + ch + "}"
+	}
+	return channelID(prefix + ".seq." + ch)
+}
+
+// isOrderingSeqData reports whether PUB/SUB data of publication stamped with
+// ordering sequence (see orderingSeqSource). Such publications have "p1"
+// position with empty epoch, while publications with history always have
+// epoch. Sequence is internal to ordering check, so such publications must be
+// passed to subscribers without stream position.
+func isOrderingSeqData(data []byte) bool {
+	if !bytes.HasPrefix(data, []byte("__p1:")) {
+		return false
+	}
+	end := bytes.Index(data[2:], []byte("__"))
+	return end > 0 && data[2+end-1] == ':'
+}
+
+// checkOrdering checks position of publication received from PUB/SUB.
+func (b *Broker) checkOrdering(ch string, sp centrifuge.StreamPosition) {
+	violation, prevOffset := b.ordering.check(ch, sp)
+	if violation == orderingOK {
+		return
+	}
+	orderingViolationsCount.WithLabelValues(string(violation)).Inc()
+	b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "publication ordering violation", map[string]interface{}{"channel": ch, "type": string(violation), "offset": sp.Offset, "previous_offset": prevOffset, "epoch": sp.Epoch}))
+}
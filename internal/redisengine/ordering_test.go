@@ -0,0 +1,97 @@
+package redisengine
+
+import (
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/protocol"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderingChecker(t *testing.T) {
+	c := newOrderingChecker()
+
+	violation, _ := c.check("ch", centrifuge.StreamPosition{})
+	require.Equal(t, orderingOK, violation)
+
+	violation, _ = c.check("ch", centrifuge.StreamPosition{Offset: 5, Epoch: "e"})
+	require.Equal(t, orderingOK, violation)
+	violation, _ = c.check("ch", centrifuge.StreamPosition{Offset: 6, Epoch: "e"})
+	require.Equal(t, orderingOK, violation)
+
+	violation, prev := c.check("ch", centrifuge.StreamPosition{Offset: 8, Epoch: "e"})
+	require.Equal(t, orderingGap, violation)
+	require.Equal(t, uint64(6), prev)
+
+	violation, prev = c.check("ch", centrifuge.StreamPosition{Offset: 7, Epoch: "e"})
+	require.Equal(t, orderingReorder, violation)
+	require.Equal(t, uint64(8), prev)
+	violation, _ = c.check("ch", centrifuge.StreamPosition{Offset: 9, Epoch: "e"})
+	require.Equal(t, orderingOK, violation)
+
+	// Other channel and new epoch start new sequence.
+	violation, _ = c.check("other", centrifuge.StreamPosition{Offset: 100})
+	require.Equal(t, orderingOK, violation)
+	violation, _ = c.check("ch", centrifuge.StreamPosition{Offset: 1, Epoch: "new"})
+	require.Equal(t, orderingOK, violation)
+
+	c.forget("ch")
+	violation, _ = c.check("ch", centrifuge.StreamPosition{Offset: 1})
+	require.Equal(t, orderingOK, violation)
+
+	c.reset()
+	violation, _ = c.check("other", centrifuge.StreamPosition{Offset: 1})
+	require.Equal(t, orderingOK, violation)
+}
+
+func TestExtractPushDataSeq(t *testing.T) {
+	data, typ, sp, ok := extractPushData([]byte("__p1:12:__payload"))
+	require.True(t, ok)
+	require.Equal(t, pubPushType, typ)
+	require.Equal(t, centrifuge.StreamPosition{Offset: 12}, sp)
+	require.Equal(t, []byte("payload"), data)
+}
+
+type testOrderingEventHandler struct {
+	centrifuge.BrokerEventHandler
+	pub *centrifuge.Publication
+	sp  centrifuge.StreamPosition
+}
+
+func (h *testOrderingEventHandler) HandlePublication(_ string, pub *centrifuge.Publication, sp centrifuge.StreamPosition) error {
+	h.pub = pub
+	h.sp = sp
+	return nil
+}
+
+func TestPrepareRedisClientMessageOrderingSeq(t *testing.T) {
+	b := &Broker{messagePrefix: "centrifugo.client.", ordering: newOrderingChecker()}
+	payload, err := (&protocol.Publication{Data: []byte(`{}`)}).MarshalVT()
+	require.NoError(t, err)
+	h := &testOrderingEventHandler{}
+
+	// Sequence is not exposed to subscribers but used by ordering check.
+	fn, err := b.prepareRedisClientMessage(h, b.messageChannelID("ch"), append([]byte("__p1:12:__"), payload...))
+	require.NoError(t, err)
+	fn()
+	require.Equal(t, centrifuge.StreamPosition{}, h.sp)
+	require.Zero(t, h.pub.Offset)
+	violation, prev := b.ordering.check("ch", centrifuge.StreamPosition{Offset: 13})
+	require.Equal(t, orderingOK, violation)
+	require.Equal(t, uint64(12), prev)
+
+	// Stream position of publications with history kept.
+	fn, err = b.prepareRedisClientMessage(h, b.messageChannelID("ch"), append([]byte("__p1:5:epoch__"), payload...))
+	require.NoError(t, err)
+	fn()
+	require.Equal(t, centrifuge.StreamPosition{Offset: 5, Epoch: "epoch"}, h.sp)
+	require.Equal(t, uint64(5), h.pub.Offset)
+}
+
+func TestIsOrderingSeqData(t *testing.T) {
+	require.True(t, isOrderingSeqData([]byte("__p1:12:__payload")))
+	require.False(t, isOrderingSeqData([]byte("__p1:12:epoch__payload")))
+	require.False(t, isOrderingSeqData([]byte("__12__payload")))
+	require.False(t, isOrderingSeqData([]byte("__j__payload")))
+	require.False(t, isOrderingSeqData([]byte("__p1:")))
+}
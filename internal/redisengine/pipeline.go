@@ -0,0 +1,52 @@
+package redisengine
+
+import "time"
+
+// pipelineIdleFactor defines how many max wait intervals should pass since
+// previous flush to consider pipeline idle.
+const pipelineIdleFactor = 4
+
+// pipelineBatcher decides whether pipeline should wait for more requests
+// before flushing collected batch to Redis. Waiting is a trade of small latency
+// increase for fewer and bigger pipelines, it only makes sense when requests
+// come frequently – so for idle pipeline batch is flushed immediately. Batch
+// size to collect adapts to load: it's twice the size of previous batch,
+// so pipeline stops waiting as soon as it collected enough requests.
+type pipelineBatcher struct {
+	maxWait   time.Duration
+	lastFlush time.Time
+	lastSize  int
+}
+
+func newPipelineBatcher(maxWait time.Duration) *pipelineBatcher {
+	return &pipelineBatcher{maxWait: maxWait}
+}
+
+// next returns time to wait for more requests and batch size to wait for
+// given the current batch size. Zero duration means batch should be flushed
+// immediately.
+func (b *pipelineBatcher) next(size int, limit int) (time.Duration, int) {
+	if b.maxWait <= 0 || size >= limit {
+		return 0, size
+	}
+	if time.Since(b.lastFlush) > pipelineIdleFactor*b.maxWait {
+		return 0, size
+	}
+	target := 2 * b.lastSize
+	if target < 2 {
+		target = 2
+	}
+	if target > limit {
+		target = limit
+	}
+	if size >= target {
+		return 0, size
+	}
+	return b.maxWait, target
+}
+
+// flushed must be called with a size of every batch sent to Redis.
+func (b *pipelineBatcher) flushed(size int) {
+	b.lastFlush = time.Now()
+	b.lastSize = size
+}
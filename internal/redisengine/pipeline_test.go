@@ -0,0 +1,38 @@
+package redisengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineBatcher(t *testing.T) {
+	b := newPipelineBatcher(0)
+	wait, _ := b.next(1, 64)
+	require.Zero(t, wait)
+
+	b = newPipelineBatcher(time.Second)
+	// Idle pipeline should be flushed immediately.
+	wait, _ = b.next(1, 64)
+	require.Zero(t, wait)
+	b.flushed(1)
+
+	wait, target := b.next(1, 64)
+	require.Equal(t, time.Second, wait)
+	require.Equal(t, 2, target)
+	b.flushed(10)
+
+	wait, target = b.next(1, 64)
+	require.Equal(t, time.Second, wait)
+	require.Equal(t, 20, target)
+
+	wait, _ = b.next(20, 64)
+	require.Zero(t, wait)
+
+	b.flushed(64)
+	_, target = b.next(1, 64)
+	require.Equal(t, 64, target)
+	wait, _ = b.next(64, 64)
+	require.Zero(t, wait)
+}
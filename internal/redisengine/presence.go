@@ -0,0 +1,588 @@
+package redisengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+
+	"github.com/centrifugal/protocol"
+	"github.com/gomodule/redigo/redis"
+)
+
+var _ centrifuge.PresenceManager = (*PresenceManager)(nil)
+
+// PresenceManager keeps presence in Redis thus allows scaling nodes.
+type PresenceManager struct {
+	node               *centrifuge.Node
+	config             PresenceManagerConfig
+	shardsMu           sync.RWMutex
+	sharding           bool
+	shards             []*Shard
+	addPresenceScript  *redis.Script
+	remPresenceScript  *redis.Script
+	presenceScript     *redis.Script
+	presenceScanScript *redis.Script
+	memberScripts      presenceMemberScripts
+}
+
+// presenceMemberScripts used with member keys layout of presence.
+type presenceMemberScripts struct {
+	add  *redis.Script
+	rem  *redis.Script
+	ids  *redis.Script
+	scan *redis.Script
+}
+
+// presenceIndexBuckets is a number of index keys of channel with member keys
+// layout. Members spread over buckets by client ID so that index updates of
+// huge channel do not hit a single key.
+const presenceIndexBuckets = 16
+
+const (
+	// DefaultRedisPresenceTTL is a default value for presence TTL in Redis.
+	DefaultRedisPresenceTTL = 60 * time.Second
+	// DefaultRedisPresenceManagerPrefix is a default value for PresenceManagerConfig.Prefix.
+	DefaultRedisPresenceManagerPrefix = "centrifuge"
+)
+
+// PresenceManagerConfig is a config for PresenceManager.
+type PresenceManagerConfig struct {
+	// Prefix to use before every channel name and key in Redis. By default
+	// DefaultRedisPresenceManagerPrefix will be used.
+	Prefix string
+
+	// KeyPrefix allows to use custom prefix for presence keys of channel. By
+	// default Prefix used for all channels.
+	KeyPrefix func(ch string) string
+
+	// MemberKeys allows to keep presence of channel in separate key of every
+	// member with TTL and sorted set index of members split into buckets
+	// instead of a single hash. This spreads load of huge channels over many
+	// small keys which Redis expires natively. Entries of channel kept with another layout
+	// expire with PresenceTTL after layout of channel changed.
+	MemberKeys func(ch string) bool
+
+	// PresenceTTL is an interval how long to consider presence info
+	// valid after receiving presence update. This allows to automatically
+	// clean up unnecessary presence entries after TTL passed. Zero value
+	// means that DefaultRedisPresenceTTL will be used.
+	PresenceTTL time.Duration
+
+	// Shards is a list of Redis shards to use. At least one shard must be provided.
+	Shards []*Shard
+}
+
+const (
+	// Add/update client presence information.
+	// KEYS[1] - presence set key
+	// KEYS[2] - presence hash key
+	// ARGV[1] - key expire seconds
+	// ARGV[2] - expire at for set member
+	// ARGV[3] - client ID
+	// ARGV[4] - info payload
+	addPresenceSource = `
+redis.call("zadd", KEYS[1], ARGV[2], ARGV[3])
+redis.call("hset", KEYS[2], ARGV[3], ARGV[4])
+redis.call("expire", KEYS[1], ARGV[1])
+redis.call("expire", KEYS[2], ARGV[1])
+	`
+
+	// Remove client presence.
+	// KEYS[1] - presence set key
+	// KEYS[2] - presence hash key
+	// ARGV[1] - client ID
+	remPresenceSource = `
+redis.call("hdel", KEYS[2], ARGV[1])
+redis.call("zrem", KEYS[1], ARGV[1])
+	`
+
+	// Get presence information.
+	// KEYS[1] - presence set key
+	// KEYS[2] - presence hash key
+	// ARGV[1] - current timestamp in seconds
+	presenceSource = `
+local expired = redis.call("zrangebyscore", KEYS[1], "0", ARGV[1])
+if #expired > 0 then
+  for num = 1, #expired do
+    redis.call("hdel", KEYS[2], expired[num])
+  end
+  redis.call("zremrangebyscore", KEYS[1], "0", ARGV[1])
+end
+return redis.call("hgetall", KEYS[2])
+	`
+
+	// Get part of presence information. Expired entries removed when scan
+	// started.
+	// KEYS[1] - presence set key
+	// KEYS[2] - presence hash key
+	// ARGV[1] - current timestamp in seconds
+	// ARGV[2] - scan cursor
+	// ARGV[3] - scan count
+	presenceScanSource = `
+if ARGV[2] == "0" then
+  local expired = redis.call("zrangebyscore", KEYS[1], "0", ARGV[1])
+  if #expired > 0 then
+    for num = 1, #expired do
+      redis.call("hdel", KEYS[2], expired[num])
+    end
+    redis.call("zremrangebyscore", KEYS[1], "0", ARGV[1])
+  end
+end
+return redis.call("hscan", KEYS[2], ARGV[2], "count", ARGV[3])
+	`
+)
+
+const (
+	// Add/update client presence information with member keys layout.
+	// KEYS[1] - presence index bucket key
+	// KEYS[2] - presence member key
+	// ARGV[1] - key expire seconds
+	// ARGV[2] - expire at for index member
+	// ARGV[3] - client ID
+	// ARGV[4] - info payload
+	addPresenceMemberSource = `
+redis.call("zadd", KEYS[1], ARGV[2], ARGV[3])
+redis.call("expire", KEYS[1], ARGV[1])
+redis.call("set", KEYS[2], ARGV[4], "ex", ARGV[1])
+	`
+
+	// Remove client presence with member keys layout.
+	// KEYS[1] - presence index bucket key
+	// KEYS[2] - presence member key
+	// ARGV[1] - client ID
+	remPresenceMemberSource = `
+redis.call("del", KEYS[2])
+redis.call("zrem", KEYS[1], ARGV[1])
+	`
+
+	// Get client IDs from all index buckets of channel removing expired ones.
+	// Member values loaded with MGET of member keys after that.
+	// KEYS[1..presenceIndexBuckets] - presence index bucket keys
+	// ARGV[1] - current timestamp in seconds
+	presenceMemberIDsSource = `
+local result = {}
+for _, key in ipairs(KEYS) do
+  redis.call("zremrangebyscore", key, "0", ARGV[1])
+  local ids = redis.call("zrange", key, 0, -1)
+  for num = 1, #ids do
+    result[#result + 1] = ids[num]
+  end
+end
+return result
+	`
+
+	// Get part of client IDs of index bucket. Expired entries removed from
+	// bucket when scan of bucket started.
+	// KEYS[1] - presence index bucket key
+	// ARGV[1] - current timestamp in seconds
+	// ARGV[2] - scan cursor
+	// ARGV[3] - scan count
+	presenceMemberScanSource = `
+if ARGV[2] == "0" then
+  redis.call("zremrangebyscore", KEYS[1], "0", ARGV[1])
+end
+local reply = redis.call("zscan", KEYS[1], ARGV[2], "count", ARGV[3])
+local members = reply[2]
+local ids = {}
+for num = 1, #members, 2 do
+  ids[#ids + 1] = members[num]
+end
+return {reply[1], ids}
+	`
+)
+
+// presenceScanCount is a number of presence entries requested from Redis
+// at once when reading presence with limit or deadline.
+const presenceScanCount = 1000
+
+// NewPresenceManager creates new PresenceManager.
+func NewPresenceManager(n *centrifuge.Node, config PresenceManagerConfig) (*PresenceManager, error) {
+	if len(config.Shards) == 0 {
+		return nil, errors.New("presence: no Redis shards provided in configuration")
+	}
+
+	if len(config.Shards) > 1 {
+		n.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, fmt.Sprintf("presence: Redis sharding enabled: %d shards", len(config.Shards))))
+	}
+
+	if config.Prefix == "" {
+		config.Prefix = DefaultRedisPresenceManagerPrefix
+	}
+
+	if config.PresenceTTL == 0 {
+		config.PresenceTTL = DefaultRedisPresenceTTL
+	}
+
+	m := &PresenceManager{
+		node:               n,
+		shards:             config.Shards,
+		config:             config,
+		sharding:           len(config.Shards) > 1,
+		addPresenceScript:  redis.NewScript(2, addPresenceSource),
+		remPresenceScript:  redis.NewScript(2, remPresenceSource),
+		presenceScript:     redis.NewScript(2, presenceSource),
+		presenceScanScript: redis.NewScript(2, presenceScanSource),
+		memberScripts: presenceMemberScripts{
+			add:  redis.NewScript(2, addPresenceMemberSource),
+			rem:  redis.NewScript(2, remPresenceMemberSource),
+			ids:  redis.NewScript(presenceIndexBuckets, presenceMemberIDsSource),
+			scan: redis.NewScript(1, presenceMemberScanSource),
+		},
+	}
+
+	for i := range config.Shards {
+		config.Shards[i].registerScripts(
+			m.addPresenceScript,
+			m.remPresenceScript,
+			m.presenceScript,
+			m.presenceScanScript,
+		)
+		m.memberScripts.register(config.Shards[i])
+	}
+
+	return m, nil
+}
+
+func (s presenceMemberScripts) register(shard *Shard) {
+	shard.registerScripts(s.add, s.rem, s.ids, s.scan)
+}
+
+// useMemberKeys reports whether presence of channel kept with member keys
+// layout.
+func (m *PresenceManager) useMemberKeys(ch string) bool {
+	return m.config.MemberKeys != nil && m.config.MemberKeys(ch)
+}
+
+func (m *PresenceManager) getShard(channel string) *Shard {
+	m.shardsMu.RLock()
+	defer m.shardsMu.RUnlock()
+	if !m.sharding {
+		return m.shards[0]
+	}
+	return m.shards[consistentIndex(channel, len(m.shards))]
+}
+
+// AddPresence - see PresenceManager interface description.
+func (m *PresenceManager) AddPresence(ch string, uid string, info *centrifuge.ClientInfo) error {
+	s := m.getShard(ch)
+	started := time.Now()
+	err := m.addPresence(s, ch, uid, info)
+	s.metrics.observe(redisOpAddPresence, started, err)
+	return err
+}
+
+func (m *PresenceManager) addPresence(s *Shard, ch string, uid string, info *centrifuge.ClientInfo) error {
+	expire := int(m.config.PresenceTTL.Seconds())
+	infoBytes, err := infoToProto(info).MarshalVT()
+	if err != nil {
+		return err
+	}
+	expireAt := time.Now().Unix() + int64(expire)
+	var dr *dataRequest
+	if m.useMemberKeys(ch) {
+		indexKey := m.presenceIndexKey(s, ch, presenceIndexBucket(uid))
+		memberKey := m.presenceMemberKey(s, ch, uid)
+		dr = s.newDataRequest("", m.memberScripts.add, indexKey, []interface{}{indexKey, memberKey, expire, expireAt, uid, infoBytes})
+	} else {
+		setKey, hashKey := m.presenceSetKey(s, ch), m.presenceHashKey(s, ch)
+		dr = s.newDataRequest("", m.addPresenceScript, setKey, []interface{}{setKey, hashKey, expire, expireAt, uid, infoBytes})
+	}
+	resp := s.getDataResponse(dr)
+	return resp.err
+}
+
+// RemovePresence - see PresenceManager interface description.
+func (m *PresenceManager) RemovePresence(ch string, uid string) error {
+	s := m.getShard(ch)
+	started := time.Now()
+	err := m.removePresence(s, ch, uid)
+	s.metrics.observe(redisOpRemovePresence, started, err)
+	return err
+}
+
+func (m *PresenceManager) removePresence(s *Shard, ch string, uid string) error {
+	var dr *dataRequest
+	if m.useMemberKeys(ch) {
+		indexKey := m.presenceIndexKey(s, ch, presenceIndexBucket(uid))
+		memberKey := m.presenceMemberKey(s, ch, uid)
+		dr = s.newDataRequest("", m.memberScripts.rem, indexKey, []interface{}{indexKey, memberKey, uid})
+	} else {
+		setKey, hashKey := m.presenceSetKey(s, ch), m.presenceHashKey(s, ch)
+		dr = s.newDataRequest("", m.remPresenceScript, setKey, []interface{}{setKey, hashKey, uid})
+	}
+	resp := s.getDataResponse(dr)
+	return resp.err
+}
+
+// Presence - see PresenceManager interface description.
+func (m *PresenceManager) Presence(ch string) (map[string]*centrifuge.ClientInfo, error) {
+	s := m.getShard(ch)
+	started := time.Now()
+	presence, err := m.presence(s, ch)
+	s.metrics.observe(redisOpPresence, started, err)
+	return presence, err
+}
+
+// Presence - see PresenceManager interface description.
+func (m *PresenceManager) presence(s *Shard, ch string) (map[string]*centrifuge.ClientInfo, error) {
+	now := int(time.Now().Unix())
+	if m.useMemberKeys(ch) {
+		args := make([]interface{}, 0, presenceIndexBuckets+1)
+		for i := 0; i < presenceIndexBuckets; i++ {
+			args = append(args, m.presenceIndexKey(s, ch, i))
+		}
+		args = append(args, now)
+		dr := s.newDataRequest("", m.memberScripts.ids, args[0].(channelID), args)
+		resp := s.getDataResponse(dr)
+		ids, err := redis.Strings(resp.reply, resp.err)
+		if err != nil {
+			return nil, err
+		}
+		return m.presenceMembers(s, ch, ids)
+	}
+	setKey, hashKey := m.presenceSetKey(s, ch), m.presenceHashKey(s, ch)
+	dr := s.newDataRequest("", m.presenceScript, setKey, []interface{}{setKey, hashKey, now})
+	resp := s.getDataResponse(dr)
+	if resp.err != nil {
+		return nil, resp.err
+	}
+	return mapStringClientInfo(resp.reply, nil)
+}
+
+// presenceMembers loads member keys of client IDs. Members which expired
+// after index read skipped.
+func (m *PresenceManager) presenceMembers(s *Shard, ch string, ids []string) (map[string]*centrifuge.ClientInfo, error) {
+	if len(ids) == 0 {
+		return map[string]*centrifuge.ClientInfo{}, nil
+	}
+	keys := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, m.presenceMemberKey(s, ch, id))
+	}
+	dr := s.newDataRequest("MGET", nil, keys[0].(channelID), keys)
+	resp := s.getDataResponse(dr)
+	values, err := redis.Values(resp.reply, resp.err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != len(ids) {
+		return nil, errors.New("wrong number of presence members in reply")
+	}
+	result := make([]interface{}, 0, 2*len(ids))
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		result = append(result, []byte(ids[i]), value)
+	}
+	return mapStringClientInfo(result, nil)
+}
+
+// PresenceLimitedResult is a result of PresenceLimited.
+type PresenceLimitedResult struct {
+	Presence map[string]*centrifuge.ClientInfo
+	// Truncated is true if channel has more entries than limit.
+	Truncated bool
+	// Partial is true if ctx done before all entries read.
+	Partial bool
+}
+
+// PresenceLimited returns at most limit presence entries of channel, zero
+// limit means no limit. Entries read from Redis in batches, so huge presence
+// does not block Redis with a single call. If partial is true entries read
+// before ctx done returned, otherwise ctx error returned in this case.
+func (m *PresenceManager) PresenceLimited(ctx context.Context, ch string, limit int, partial bool) (PresenceLimitedResult, error) {
+	s := m.getShard(ch)
+	started := time.Now()
+	result, err := m.presenceLimited(ctx, s, ch, limit, partial)
+	s.metrics.observe(redisOpPresence, started, err)
+	return result, err
+}
+
+func (m *PresenceManager) presenceLimited(ctx context.Context, s *Shard, ch string, limit int, partial bool) (PresenceLimitedResult, error) {
+	now := int(time.Now().Unix())
+	result := PresenceLimitedResult{Presence: map[string]*centrifuge.ClientInfo{}}
+	memberKeys := m.useMemberKeys(ch)
+	// Buckets of member keys layout scanned one by one, hash of other layout
+	// scanned as a single bucket.
+	numBuckets := 1
+	if memberKeys {
+		numBuckets = presenceIndexBuckets
+	}
+	bucket := 0
+	cursor := "0"
+	for {
+		var dr *dataRequest
+		if memberKeys {
+			indexKey := m.presenceIndexKey(s, ch, bucket)
+			dr = s.newDataRequest("", m.memberScripts.scan, indexKey, []interface{}{indexKey, now, cursor, presenceScanCount})
+		} else {
+			setKey, hashKey := m.presenceSetKey(s, ch), m.presenceHashKey(s, ch)
+			dr = s.newDataRequest("", m.presenceScanScript, setKey, []interface{}{setKey, hashKey, now, cursor, presenceScanCount})
+		}
+		resp := s.getDataResponse(dr)
+		if resp.err != nil {
+			return PresenceLimitedResult{}, resp.err
+		}
+		values, err := redis.Values(resp.reply, nil)
+		if err != nil {
+			return PresenceLimitedResult{}, err
+		}
+		if len(values) != 2 {
+			return PresenceLimitedResult{}, errors.New("wrong presence scan reply")
+		}
+		cursor, err = redis.String(values[0], nil)
+		if err != nil {
+			return PresenceLimitedResult{}, err
+		}
+		var entries map[string]*centrifuge.ClientInfo
+		if memberKeys {
+			var ids []string
+			ids, err = redis.Strings(values[1], nil)
+			if err == nil {
+				entries, err = m.presenceMembers(s, ch, ids)
+			}
+		} else {
+			entries, err = mapStringClientInfo(values[1], nil)
+		}
+		if err != nil {
+			return PresenceLimitedResult{}, err
+		}
+		// Scan can return the same entry several times, map removes
+		// duplicates.
+		for k, v := range entries {
+			if limit > 0 && len(result.Presence) >= limit {
+				if _, ok := result.Presence[k]; !ok {
+					result.Truncated = true
+				}
+				continue
+			}
+			result.Presence[k] = v
+		}
+		if cursor == "0" {
+			bucket++
+			if bucket == numBuckets {
+				return result, nil
+			}
+		}
+		if limit > 0 && len(result.Presence) >= limit {
+			// More entries may exist on following scan iterations.
+			result.Truncated = true
+			return result, nil
+		}
+		select {
+		case <-ctx.Done():
+			if !partial {
+				return PresenceLimitedResult{}, ctx.Err()
+			}
+			result.Partial = true
+			return result, nil
+		default:
+		}
+	}
+}
+
+func mapStringClientInfo(result interface{}, err error) (map[string]*centrifuge.ClientInfo, error) {
+	values, err := redis.Values(result, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("mapStringClientInfo expects even number of values result")
+	}
+	m := make(map[string]*centrifuge.ClientInfo, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		key, okKey := values[i].([]byte)
+		value, okValue := values[i+1].([]byte)
+		if !okKey || !okValue {
+			return nil, errors.New("scanMap key not a bulk string value")
+		}
+		var f protocol.ClientInfo
+		err = f.UnmarshalVT(value)
+		if err != nil {
+			return nil, errors.New("can not unmarshal value to ClientInfo")
+		}
+		m[string(key)] = infoFromProto(&f)
+	}
+	return m, nil
+}
+
+// PresenceStats - see PresenceManager interface description.
+func (m *PresenceManager) PresenceStats(ch string) (centrifuge.PresenceStats, error) {
+	presence, err := m.Presence(ch)
+	if err != nil {
+		return centrifuge.PresenceStats{}, err
+	}
+
+	numClients := len(presence)
+	numUsers := 0
+	uniqueUsers := map[string]struct{}{}
+
+	for _, info := range presence {
+		userID := info.UserID
+		if _, ok := uniqueUsers[userID]; !ok {
+			uniqueUsers[userID] = struct{}{}
+			numUsers++
+		}
+	}
+
+	return centrifuge.PresenceStats{
+		NumClients: numClients,
+		NumUsers:   numUsers,
+	}, nil
+}
+
+// keyPrefix returns prefix of channel keys.
+func (m *PresenceManager) keyPrefix(ch string) string {
+	if m.config.KeyPrefix != nil {
+		return m.config.KeyPrefix(ch)
+	}
+	return m.config.Prefix
+}
+
+func (m *PresenceManager) presenceHashKey(s *Shard, ch string) channelID {
+	prefix := m.keyPrefix(ch)
+	if s.useCluster {
+		ch = "{" + ch + "}"
+	}
+	return channelID(prefix + ".presence.data." + ch)
+}
+
+func (m *PresenceManager) presenceSetKey(s *Shard, ch string) channelID {
+	prefix := m.keyPrefix(ch)
+	if s.useCluster {
+		ch = "{" + ch + "}"
+	}
+	return channelID(prefix + ".presence.expire." + ch)
+}
+
+// presenceIndexBucket returns index bucket of client.
+func presenceIndexBucket(uid string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(uid))
+	return int(h.Sum32() % presenceIndexBuckets)
+}
+
+// presenceIndexKey returns key of index bucket. Index keys and member keys of
+// channel share hash tag in Redis Cluster.
+func (m *PresenceManager) presenceIndexKey(s *Shard, ch string, bucket int) channelID {
+	prefix := m.keyPrefix(ch)
+	if s.useCluster {
+		ch = "{" + ch + "}"
+	}
+	return channelID(prefix + ".presence.index." + ch + "." + strconv.Itoa(bucket))
+}
+
+func (m *PresenceManager) presenceMemberKey(s *Shard, ch string, uid string) channelID {
+	prefix := m.keyPrefix(ch)
+	if s.useCluster {
+		ch = "{" + ch + "}"
+	}
+	return channelID(prefix + ".presence.member." + ch + "." + uid)
+}
@@ -0,0 +1,94 @@
+//go:build integration
+// +build integration
+
+package redisengine
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMemberKeysPresenceManager requires Redis running on localhost:6379.
+func newTestMemberKeysPresenceManager(t *testing.T, ttl time.Duration) *PresenceManager {
+	n, _ := centrifuge.New(centrifuge.DefaultConfig)
+	shard, err := NewShard(n, ShardConfig{Address: "127.0.0.1:6379"})
+	require.NoError(t, err)
+	m, err := NewPresenceManager(n, PresenceManagerConfig{
+		Shards:      []*Shard{shard},
+		Prefix:      "centrifugo_presence_test_" + strconv.FormatInt(time.Now().UnixNano(), 10),
+		PresenceTTL: ttl,
+		MemberKeys:  func(string) bool { return true },
+	})
+	require.NoError(t, err)
+	return m
+}
+
+func TestPresenceMemberKeys(t *testing.T) {
+	m := newTestMemberKeysPresenceManager(t, time.Minute)
+
+	for i := 0; i < 50; i++ {
+		uid := "client" + strconv.Itoa(i)
+		require.NoError(t, m.AddPresence("huge", uid, &centrifuge.ClientInfo{ClientID: uid, UserID: "user" + strconv.Itoa(i%10)}))
+	}
+	presence, err := m.Presence("huge")
+	require.NoError(t, err)
+	require.Len(t, presence, 50)
+	require.Equal(t, "user3", presence["client13"].UserID)
+
+	stats, err := m.PresenceStats("huge")
+	require.NoError(t, err)
+	require.Equal(t, 50, stats.NumClients)
+	require.Equal(t, 10, stats.NumUsers)
+
+	require.NoError(t, m.RemovePresence("huge", "client13"))
+	presence, err = m.Presence("huge")
+	require.NoError(t, err)
+	require.Len(t, presence, 49)
+	require.NotContains(t, presence, "client13")
+
+	presence, err = m.Presence("empty")
+	require.NoError(t, err)
+	require.Len(t, presence, 0)
+}
+
+func TestPresenceMemberKeysLimited(t *testing.T) {
+	m := newTestMemberKeysPresenceManager(t, time.Minute)
+
+	for i := 0; i < 50; i++ {
+		uid := "client" + strconv.Itoa(i)
+		require.NoError(t, m.AddPresence("huge", uid, &centrifuge.ClientInfo{ClientID: uid, UserID: uid}))
+	}
+
+	result, err := m.PresenceLimited(context.Background(), "huge", 0, false)
+	require.NoError(t, err)
+	require.Len(t, result.Presence, 50)
+	require.False(t, result.Truncated)
+
+	result, err = m.PresenceLimited(context.Background(), "huge", 10, false)
+	require.NoError(t, err)
+	require.Len(t, result.Presence, 10)
+	require.True(t, result.Truncated)
+}
+
+func TestPresenceMemberKeysExpiration(t *testing.T) {
+	m := newTestMemberKeysPresenceManager(t, time.Second)
+
+	require.NoError(t, m.AddPresence("huge", "client", &centrifuge.ClientInfo{ClientID: "client"}))
+	presence, err := m.Presence("huge")
+	require.NoError(t, err)
+	require.Len(t, presence, 1)
+
+	time.Sleep(2100 * time.Millisecond)
+	presence, err = m.Presence("huge")
+	require.NoError(t, err)
+	require.Len(t, presence, 0)
+
+	result, err := m.PresenceLimited(context.Background(), "huge", 0, false)
+	require.NoError(t, err)
+	require.Len(t, result.Presence, 0)
+}
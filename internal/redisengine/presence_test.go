@@ -0,0 +1,42 @@
+package redisengine
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresenceKeys(t *testing.T) {
+	m := &PresenceManager{
+		config: PresenceManagerConfig{
+			Prefix: "centrifugo",
+			MemberKeys: func(ch string) bool {
+				return ch == "huge"
+			},
+		},
+	}
+
+	require.False(t, m.useMemberKeys("chat"))
+	require.True(t, m.useMemberKeys("huge"))
+
+	require.Equal(t, channelID("centrifugo.presence.expire.chat"), m.presenceSetKey(&Shard{}, "chat"))
+	require.Equal(t, channelID("centrifugo.presence.data.chat"), m.presenceHashKey(&Shard{}, "chat"))
+
+	require.Equal(t, channelID("centrifugo.presence.index.huge.3"), m.presenceIndexKey(&Shard{}, "huge", 3))
+	require.Equal(t, channelID("centrifugo.presence.member.huge.client"), m.presenceMemberKey(&Shard{}, "huge", "client"))
+
+	require.Equal(t, channelID("centrifugo.presence.index.{huge}.3"), m.presenceIndexKey(&Shard{useCluster: true}, "huge", 3))
+	require.Equal(t, channelID("centrifugo.presence.member.{huge}.client"), m.presenceMemberKey(&Shard{useCluster: true}, "huge", "client"))
+}
+
+func TestPresenceIndexBucket(t *testing.T) {
+	buckets := map[int]struct{}{}
+	for i := 0; i < 1000; i++ {
+		bucket := presenceIndexBucket(strconv.Itoa(i))
+		require.True(t, bucket >= 0 && bucket < presenceIndexBuckets)
+		buckets[bucket] = struct{}{}
+	}
+	require.Len(t, buckets, presenceIndexBuckets)
+	require.Equal(t, presenceIndexBucket("client"), presenceIndexBucket("client"))
+}
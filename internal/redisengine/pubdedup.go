@@ -0,0 +1,115 @@
+package redisengine
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/pubdedup"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// pendingDedupValue marks publication which is still in progress.
+const pendingDedupValue = "pending"
+
+// Reserve deduplication key if it does not exist.
+// KEYS[1] - deduplication key
+// ARGV[1] - pending value
+// ARGV[2] - key TTL in milliseconds
+const reserveDedupSource = `
+local current = redis.call("get", KEYS[1])
+if current ~= false then
+  return current
+end
+redis.call("set", KEYS[1], ARGV[1], "px", ARGV[2])
+return false
+	`
+
+// PublishDedupStore keeps results of client publications with message IDs in
+// Redis. Keys sharded between Redis shards of Broker.
+type PublishDedupStore struct {
+	broker        *Broker
+	reserveScript *redis.Script
+}
+
+// NewPublishDedupStore creates PublishDedupStore which uses shards and prefix
+// of Broker.
+func NewPublishDedupStore(b *Broker) (*PublishDedupStore, error) {
+	if b == nil {
+		return nil, errors.New("publish dedup store: no broker provided")
+	}
+	s := &PublishDedupStore{
+		broker:        b,
+		reserveScript: redis.NewScript(1, reserveDedupSource),
+	}
+	b.registerScripts(s.reserveScript)
+	return s, nil
+}
+
+// Reserve marks key as pending for ttl if key is unknown. Returns current
+// result and true if key is already known.
+func (s *PublishDedupStore) Reserve(key string, ttl time.Duration) (pubdedup.Result, bool, error) {
+	shard := s.broker.getShard(key)
+	dedupKey := s.dedupKey(shard, key)
+	dr := shard.newDataRequest("", s.reserveScript, dedupKey, []interface{}{dedupKey, pendingDedupValue, ttl.Milliseconds()})
+	resp := shard.getDataResponse(dr)
+	value, err := redis.String(resp.reply, resp.err)
+	if err == redis.ErrNil {
+		return pubdedup.Result{}, false, nil
+	}
+	if err != nil {
+		return pubdedup.Result{}, false, err
+	}
+	r, err := parseDedupResult(value)
+	if err != nil {
+		return pubdedup.Result{}, false, err
+	}
+	return r, true, nil
+}
+
+// Save sets result of publication for key for ttl.
+func (s *PublishDedupStore) Save(key string, r pubdedup.Result, ttl time.Duration) error {
+	shard := s.broker.getShard(key)
+	dedupKey := s.dedupKey(shard, key)
+	dr := shard.newDataRequest("SET", nil, dedupKey, []interface{}{dedupKey, formatDedupResult(r), "px", ttl.Milliseconds()})
+	return shard.getDataResponse(dr).err
+}
+
+// Release removes key so publication can be retried.
+func (s *PublishDedupStore) Release(key string) error {
+	shard := s.broker.getShard(key)
+	dedupKey := s.dedupKey(shard, key)
+	dr := shard.newDataRequest("DEL", nil, dedupKey, []interface{}{dedupKey})
+	return shard.getDataResponse(dr).err
+}
+
+func formatDedupResult(r pubdedup.Result) string {
+	if r.Pending {
+		return pendingDedupValue
+	}
+	return strconv.FormatUint(r.Offset, 10) + ":" + r.Epoch
+}
+
+func parseDedupResult(value string) (pubdedup.Result, error) {
+	if value == pendingDedupValue {
+		return pubdedup.Result{Pending: true}, nil
+	}
+	sep := strings.Index(value, ":")
+	if sep <= 0 {
+		return pubdedup.Result{}, errors.New("malformed publish dedup result: " + value)
+	}
+	offset, err := strconv.ParseUint(value[:sep], 10, 64)
+	if err != nil {
+		return pubdedup.Result{}, err
+	}
+	return pubdedup.Result{Offset: offset, Epoch: value[sep+1:]}, nil
+}
+
+func (s *PublishDedupStore) dedupKey(shard *Shard, key string) channelID {
+	if shard.useCluster {
+		key = "{" + key + "}"
+	}
+	return channelID(s.broker.config.Prefix + ".dedup." + key)
+}
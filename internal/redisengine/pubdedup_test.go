@@ -0,0 +1,27 @@
+package redisengine
+
+import (
+	"testing"
+
+	"github.com/centrifugal/centrifugo/v3/internal/pubdedup"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupResultFormat(t *testing.T) {
+	for _, r := range []pubdedup.Result{{Pending: true}, {Offset: 12, Epoch: "abc"}, {}} {
+		parsed, err := parseDedupResult(formatDedupResult(r))
+		require.NoError(t, err)
+		require.Equal(t, r, parsed)
+	}
+	_, err := parseDedupResult("abc")
+	require.Error(t, err)
+	_, err = parseDedupResult("x:abc")
+	require.Error(t, err)
+}
+
+func TestDedupKey(t *testing.T) {
+	s := &PublishDedupStore{broker: &Broker{config: BrokerConfig{Prefix: "centrifugo"}}}
+	require.Equal(t, channelID("centrifugo.dedup.k"), s.dedupKey(&Shard{}, "k"))
+	require.Equal(t, channelID("centrifugo.dedup.{k}"), s.dedupKey(&Shard{useCluster: true}, "k"))
+}
@@ -0,0 +1,81 @@
+package redisengine
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// DefaultPublishRetryTimeout is a default time publication retried
+	// when publish retry buffer enabled.
+	DefaultPublishRetryTimeout = 5 * time.Second
+
+	publishRetryMinBackoff = 50 * time.Millisecond
+	publishRetryMaxBackoff = time.Second
+)
+
+// Prefixes of Redis error replies which are returned while failover is in
+// progress.
+var failoverErrorPrefixes = []string{"READONLY", "LOADING", "MASTERDOWN", "TRYAGAIN", "CLUSTERDOWN"}
+
+// isFailoverError reports whether error looks like a transient error which
+// happens while Redis master is unavailable or being switched: Redis failover
+// error reply or failure to dial Redis. Publication was not processed by Redis
+// in these cases, so it's safe to retry. Timeouts and broken connections are
+// not retried since Redis could process publication before connection failed.
+func isFailoverError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		for _, prefix := range failoverErrorPrefixes {
+			if strings.HasPrefix(string(redisErr), prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withPublishRetry calls publish and retries it with exponential backoff on
+// failover errors while publish retry buffer has free slots and retry timeout
+// not reached.
+func (s *Shard) withPublishRetry(publish func() error) error {
+	err := publish()
+	if err == nil || s.publishRetrySem == nil || !isFailoverError(err) {
+		return err
+	}
+	select {
+	case s.publishRetrySem <- struct{}{}:
+	default:
+		// Buffer is full, fail fast.
+		return err
+	}
+	defer func() { <-s.publishRetrySem }()
+
+	timeout := s.config.PublishRetryTimeout
+	if timeout == 0 {
+		timeout = DefaultPublishRetryTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	backoff := publishRetryMinBackoff
+	for time.Now().Add(backoff).Before(deadline) {
+		time.Sleep(backoff)
+		s.publishRetries.Inc()
+		err = publish()
+		if err == nil || !isFailoverError(err) {
+			return err
+		}
+		backoff *= 2
+		if backoff > publishRetryMaxBackoff {
+			backoff = publishRetryMaxBackoff
+		}
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package redisengine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsFailoverError(t *testing.T) {
+	require.True(t, isFailoverError(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	require.True(t, isFailoverError(fmt.Errorf("get connection: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")})))
+	require.True(t, isFailoverError(redis.Error("READONLY You can't write against a read only replica.")))
+	require.True(t, isFailoverError(fmt.Errorf("publish: %w", redis.Error("LOADING Redis is loading the dataset in memory"))))
+	require.False(t, isFailoverError(redis.Error("ERR unknown command")))
+	require.False(t, isFailoverError(errors.New("boom")))
+	// Redis could process command before timeout or connection loss.
+	require.False(t, isFailoverError(errRedisOpTimeout))
+	require.False(t, isFailoverError(io.EOF))
+	require.False(t, isFailoverError(&net.OpError{Op: "read", Err: errors.New("i/o timeout")}))
+}
+
+func newRetryShard(bufferSize int, timeout time.Duration) *Shard {
+	return &Shard{
+		config:          ShardConfig{PublishRetryTimeout: timeout},
+		publishRetrySem: make(chan struct{}, bufferSize),
+		publishRetries:  publishRetriesCount.WithLabelValues("test_retry:6379"),
+	}
+}
+
+func TestShardWithPublishRetry(t *testing.T) {
+	s := newRetryShard(1, 5*time.Second)
+	numCalls := 0
+	err := s.withPublishRetry(func() error {
+		numCalls++
+		if numCalls < 3 {
+			return redis.Error("READONLY You can't write against a read only replica.")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, numCalls)
+	require.Equal(t, float64(2), testutil.ToFloat64(publishRetriesCount.WithLabelValues("test_retry:6379")))
+
+	// Non-failover errors not retried.
+	numCalls = 0
+	err = s.withPublishRetry(func() error {
+		numCalls++
+		return errors.New("boom")
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, numCalls)
+
+	// Timeouts not retried.
+	numCalls = 0
+	err = s.withPublishRetry(func() error {
+		numCalls++
+		return errRedisOpTimeout
+	})
+	require.Equal(t, errRedisOpTimeout, err)
+	require.Equal(t, 1, numCalls)
+}
+
+var errReadOnly = redis.Error("READONLY You can't write against a read only replica.")
+
+func TestShardWithPublishRetryTimeout(t *testing.T) {
+	s := newRetryShard(1, 200*time.Millisecond)
+	started := time.Now()
+	err := s.withPublishRetry(func() error {
+		return errReadOnly
+	})
+	require.Equal(t, errReadOnly, err)
+	require.Less(t, int64(time.Since(started)), int64(time.Second))
+}
+
+func TestShardWithPublishRetryBufferFull(t *testing.T) {
+	s := newRetryShard(1, 5*time.Second)
+	s.publishRetrySem <- struct{}{}
+	numCalls := 0
+	err := s.withPublishRetry(func() error {
+		numCalls++
+		return errReadOnly
+	})
+	require.Equal(t, errReadOnly, err)
+	require.Equal(t, 1, numCalls)
+
+	// Retries disabled.
+	s = &Shard{}
+	numCalls = 0
+	_ = s.withPublishRetry(func() error {
+		numCalls++
+		return errReadOnly
+	})
+	require.Equal(t, 1, numCalls)
+}
@@ -0,0 +1,356 @@
+package redisengine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// redisPubSubWorkerScaleUpDepth is a queue depth of the least loaded worker
+	// starting from which new worker added to pool for channels without
+	// messages in processing.
+	redisPubSubWorkerScaleUpDepth = 16
+	// redisPubSubWorkerIdleTimeout is a time after which worker without work
+	// removed from pool if pool has more than minimum number of workers.
+	redisPubSubWorkerIdleTimeout = 10 * time.Second
+	// redisPubSubPoolCheckInterval is an interval of removing idle workers,
+	// updating saturation metrics and detecting hot channels.
+	redisPubSubPoolCheckInterval = time.Second
+)
+
+// pubSubHandler prepares message for delivery (decodes it) and returns function
+// which delivers it. Returned function may be nil if there is nothing to deliver.
+type pubSubHandler func(redis.Message) func()
+
+type pubSubTask struct {
+	msg redis.Message
+	// seq is set for messages of hot channels, n is a sequence number of
+	// message in channel.
+	seq *pubSubSequencer
+	n   uint64
+}
+
+type pubSubWorker struct {
+	ch       chan pubSubTask
+	stop     chan struct{}
+	assigned int // number of channels with messages in worker queue.
+	hot      int // number of hot channel messages in worker queue.
+	idleAt   time.Time
+}
+
+func (w *pubSubWorker) idle() bool {
+	return w.assigned == 0 && w.hot == 0 && len(w.ch) == 0
+}
+
+type pubSubAssignment struct {
+	worker  *pubSubWorker
+	pending int
+}
+
+// pubSubSequencer delivers messages of hot channel prepared by different
+// workers in order of their sequence numbers.
+type pubSubSequencer struct {
+	mu       sync.Mutex
+	assigned uint64 // sequence number of the next dispatched message.
+	next     uint64 // sequence number of the next message to deliver.
+	blocked  int    // messages dispatched to assigned worker before channel became hot.
+	ready    map[uint64]func()
+	draining bool
+}
+
+func newPubSubSequencer(blocked int) *pubSubSequencer {
+	return &pubSubSequencer{
+		blocked: blocked,
+		ready:   make(map[uint64]func()),
+	}
+}
+
+func (s *pubSubSequencer) assign() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.assigned
+	s.assigned++
+	return n
+}
+
+func (s *pubSubSequencer) inFlight() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blocked > 0 || s.assigned != s.next
+}
+
+// done marks message with sequence number n prepared.
+func (s *pubSubSequencer) done(n uint64, deliver func()) {
+	s.mu.Lock()
+	s.ready[n] = deliver
+	s.drain()
+}
+
+// unblock called when message dispatched before channel became hot processed.
+func (s *pubSubSequencer) unblock() {
+	s.mu.Lock()
+	s.blocked--
+	s.drain()
+}
+
+// drain delivers prepared messages in order. Only one worker delivers at a
+// time, others just leave prepared messages. Lock must be held outside,
+// drain releases it.
+func (s *pubSubSequencer) drain() {
+	if s.draining || s.blocked > 0 {
+		s.mu.Unlock()
+		return
+	}
+	s.draining = true
+	for {
+		deliver, ok := s.ready[s.next]
+		if !ok {
+			break
+		}
+		delete(s.ready, s.next)
+		s.next++
+		if deliver != nil {
+			s.mu.Unlock()
+			deliver()
+			s.mu.Lock()
+		}
+	}
+	s.draining = false
+	s.mu.Unlock()
+}
+
+// pubSubWorkerPool processes messages received from PUB/SUB connection. Messages
+// of the same channel processed by one worker in order while channel has messages
+// in processing, once channel has no pending messages it can be assigned to
+// another worker. Channels are assigned to the least loaded worker, so channel
+// is not blocked behind a hot channel which happened to get the same worker.
+// Pool grows up to max workers when all workers have queued messages and
+// shrinks down to min workers when workers stay idle.
+//
+// Channels receiving at least hotThreshold messages per second are hot: their
+// messages are prepared by all workers in parallel and delivered in order by
+// channel sequencer, so one channel does not pin a single worker.
+type pubSubWorkerPool struct {
+	mu           sync.Mutex
+	min          int
+	max          int
+	hotThreshold int
+	workers      []*pubSubWorker
+	channels     map[string]*pubSubAssignment
+	counts       map[string]int
+	hot          map[string]*pubSubSequencer
+	handler      pubSubHandler
+	done         chan struct{}
+	metrics      pubSubPoolMetrics
+}
+
+func newPubSubWorkerPool(min, max, hotThreshold int, handler pubSubHandler, done chan struct{}, metrics pubSubPoolMetrics) *pubSubWorkerPool {
+	if max < min {
+		max = min
+	}
+	p := &pubSubWorkerPool{
+		min:          min,
+		max:          max,
+		hotThreshold: hotThreshold,
+		channels:     make(map[string]*pubSubAssignment),
+		counts:       make(map[string]int),
+		hot:          make(map[string]*pubSubSequencer),
+		handler:      handler,
+		done:         done,
+		metrics:      metrics,
+	}
+	p.mu.Lock()
+	for i := 0; i < min; i++ {
+		p.addWorker()
+	}
+	p.mu.Unlock()
+	return p
+}
+
+// Lock must be held outside.
+func (p *pubSubWorkerPool) addWorker() *pubSubWorker {
+	w := &pubSubWorker{
+		ch:     make(chan pubSubTask, redisPubSubWorkerChannelSize),
+		stop:   make(chan struct{}),
+		idleAt: time.Now(),
+	}
+	p.workers = append(p.workers, w)
+	p.metrics.workers.Set(float64(len(p.workers)))
+	go p.runWorker(w)
+	return w
+}
+
+func (p *pubSubWorkerPool) runWorker(w *pubSubWorker) {
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-w.stop:
+			return
+		case t := <-w.ch:
+			deliver := p.handler(t.msg)
+			if t.seq != nil {
+				t.seq.done(t.n, deliver)
+				p.processedHot(w)
+				continue
+			}
+			if deliver != nil {
+				deliver()
+			}
+			p.processed(w, t.msg.Channel)
+		}
+	}
+}
+
+func (p *pubSubWorkerPool) processed(w *pubSubWorker, ch string) {
+	p.mu.Lock()
+	seq := p.hot[ch]
+	if a, ok := p.channels[ch]; ok {
+		a.pending--
+		if a.pending == 0 {
+			delete(p.channels, ch)
+			w.assigned--
+			if w.idle() {
+				w.idleAt = time.Now()
+			}
+		}
+	}
+	p.mu.Unlock()
+	if seq != nil {
+		// Channel became hot while message was in worker queue.
+		seq.unblock()
+	}
+}
+
+func (p *pubSubWorkerPool) processedHot(w *pubSubWorker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w.hot--
+	if w.idle() {
+		w.idleAt = time.Now()
+	}
+}
+
+// Lock must be held outside.
+func (p *pubSubWorkerPool) leastLoaded() *pubSubWorker {
+	var least *pubSubWorker
+	for _, w := range p.workers {
+		if least == nil || len(w.ch) < len(least.ch) {
+			least = w
+		}
+	}
+	if len(least.ch) >= redisPubSubWorkerScaleUpDepth && len(p.workers) < p.max {
+		return p.addWorker()
+	}
+	return least
+}
+
+// dispatch sends message to worker. Must be called from one goroutine to keep
+// order of messages in channel.
+func (p *pubSubWorkerPool) dispatch(n redis.Message) {
+	p.mu.Lock()
+	a, assigned := p.channels[n.Channel]
+	seq, hot := p.hot[n.Channel]
+	if p.hotThreshold > 0 {
+		p.counts[n.Channel]++
+		if !hot && p.counts[n.Channel] >= p.hotThreshold {
+			var blocked int
+			if assigned {
+				blocked = a.pending
+			}
+			seq = newPubSubSequencer(blocked)
+			p.hot[n.Channel] = seq
+			hot = true
+			p.metrics.hotChannels.Set(float64(len(p.hot)))
+		}
+	}
+	var t pubSubTask
+	var w *pubSubWorker
+	if hot {
+		w = p.leastLoaded()
+		w.hot++
+		t = pubSubTask{msg: n, seq: seq, n: seq.assign()}
+	} else {
+		if !assigned {
+			w = p.leastLoaded()
+			w.assigned++
+			a = &pubSubAssignment{worker: w}
+			p.channels[n.Channel] = a
+		}
+		a.pending++
+		w = a.worker
+		t = pubSubTask{msg: n}
+	}
+	p.mu.Unlock()
+
+	select {
+	case w.ch <- t:
+	default:
+		p.metrics.queueFull.Inc()
+		select {
+		case w.ch <- t:
+		case <-p.done:
+		}
+	}
+}
+
+// check removes workers which stayed idle for redisPubSubWorkerIdleTimeout,
+// turns off hot mode for channels which cooled down and updates metrics.
+func (p *pubSubWorkerPool) check(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var maxDepth int
+	workers := p.workers[:0]
+	for i, w := range p.workers {
+		if len(w.ch) > maxDepth {
+			maxDepth = len(w.ch)
+		}
+		numLeft := len(workers) + len(p.workers) - i
+		if numLeft > p.min && w.idle() && now.Sub(w.idleAt) >= redisPubSubWorkerIdleTimeout {
+			close(w.stop)
+			continue
+		}
+		workers = append(workers, w)
+	}
+	for i := len(workers); i < len(p.workers); i++ {
+		p.workers[i] = nil
+	}
+	p.workers = workers
+
+	for ch, seq := range p.hot {
+		// Keep hot mode until rate drops twice below threshold to not switch
+		// modes back and forth.
+		if p.counts[ch] < p.hotThreshold/2 && !seq.inFlight() {
+			delete(p.hot, ch)
+		}
+	}
+	p.counts = make(map[string]int)
+
+	p.metrics.workers.Set(float64(len(p.workers)))
+	p.metrics.saturation.Set(float64(maxDepth) / float64(redisPubSubWorkerChannelSize))
+	p.metrics.hotChannels.Set(float64(len(p.hot)))
+}
+
+func (p *pubSubWorkerPool) runChecks() {
+	ticker := time.NewTicker(redisPubSubPoolCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			p.metrics.workers.Set(0)
+			p.metrics.saturation.Set(0)
+			p.metrics.hotChannels.Set(0)
+			return
+		case now := <-ticker.C:
+			p.check(now)
+		}
+	}
+}
+
+func (p *pubSubWorkerPool) numWorkers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.workers)
+}
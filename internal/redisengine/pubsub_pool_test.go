@@ -0,0 +1,131 @@
+package redisengine
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func testPubSubPoolMetrics() pubSubPoolMetrics {
+	return pubSubPoolMetrics{
+		workers:     prometheus.NewGauge(prometheus.GaugeOpts{Name: "workers"}),
+		saturation:  prometheus.NewGauge(prometheus.GaugeOpts{Name: "saturation"}),
+		hotChannels: prometheus.NewGauge(prometheus.GaugeOpts{Name: "hot_channels"}),
+		queueFull:   prometheus.NewCounter(prometheus.CounterOpts{Name: "queue_full"}),
+	}
+}
+
+func testPubSubPoolOrder(t *testing.T, hotThreshold int) {
+	done := make(chan struct{})
+	defer close(done)
+
+	var mu sync.Mutex
+	received := map[string][]string{}
+	var wg sync.WaitGroup
+	pool := newPubSubWorkerPool(2, 8, hotThreshold, func(n redis.Message) func() {
+		return func() {
+			mu.Lock()
+			received[n.Channel] = append(received[n.Channel], string(n.Data))
+			mu.Unlock()
+			wg.Done()
+		}
+	}, done, testPubSubPoolMetrics())
+
+	const numMessages = 1000
+	wg.Add(numMessages * 3)
+	for i := 0; i < numMessages; i++ {
+		for _, ch := range []string{"a", "b", "c"} {
+			pool.dispatch(redis.Message{Channel: ch, Data: []byte(strconv.Itoa(i))})
+		}
+	}
+	wg.Wait()
+
+	for _, ch := range []string{"a", "b", "c"} {
+		require.Len(t, received[ch], numMessages)
+		for i, data := range received[ch] {
+			require.Equal(t, strconv.Itoa(i), data)
+		}
+	}
+	pool.mu.Lock()
+	require.Len(t, pool.channels, 0)
+	pool.mu.Unlock()
+}
+
+func TestPubSubWorkerPoolOrder(t *testing.T) {
+	testPubSubPoolOrder(t, 0)
+}
+
+func TestPubSubWorkerPoolHotChannelOrder(t *testing.T) {
+	// Channels become hot in the middle of stream while having messages
+	// in worker queues.
+	testPubSubPoolOrder(t, 100)
+}
+
+func TestPubSubWorkerPoolHotChannel(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	var wg sync.WaitGroup
+	pool := newPubSubWorkerPool(4, 4, 10, func(n redis.Message) func() {
+		return wg.Done
+	}, done, testPubSubPoolMetrics())
+
+	wg.Add(20)
+	for i := 0; i < 20; i++ {
+		pool.dispatch(redis.Message{Channel: "hot"})
+	}
+	wg.Wait()
+
+	pool.mu.Lock()
+	require.Contains(t, pool.hot, "hot")
+	pool.mu.Unlock()
+	require.Eventually(t, func() bool {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return !pool.hot["hot"].inFlight()
+	}, time.Second, 10*time.Millisecond)
+
+	// Rate is still high.
+	pool.check(time.Now())
+	require.Contains(t, pool.hot, "hot")
+	// No messages during last interval so channel cooled down.
+	pool.check(time.Now())
+	require.NotContains(t, pool.hot, "hot")
+}
+
+func TestPubSubWorkerPoolScale(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	block := make(chan struct{})
+	pool := newPubSubWorkerPool(1, 3, 0, func(n redis.Message) func() {
+		if n.Channel == "hot" {
+			<-block
+		}
+		return nil
+	}, done, testPubSubPoolMetrics())
+
+	for i := 0; i < redisPubSubWorkerScaleUpDepth+1; i++ {
+		pool.dispatch(redis.Message{Channel: "hot"})
+	}
+	// Hot channel worker is busy so other channel gets new worker.
+	pool.dispatch(redis.Message{Channel: "other"})
+	require.Equal(t, 2, pool.numWorkers())
+
+	close(block)
+	require.Eventually(t, func() bool {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return len(pool.channels) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	pool.check(time.Now().Add(redisPubSubWorkerIdleTimeout))
+	require.Equal(t, 1, pool.numWorkers())
+	pool.check(time.Now().Add(redisPubSubWorkerIdleTimeout))
+	require.Equal(t, 1, pool.numWorkers())
+}
@@ -0,0 +1,78 @@
+package redisengine
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/centrifugal/centrifugo/v3/internal/pushnotify"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// PushDeviceStore keeps push devices of users in Redis. Devices of user is
+// a set of "<platform>:<token>" members, users sharded between Redis shards
+// of Broker.
+type PushDeviceStore struct {
+	broker *Broker
+}
+
+// NewPushDeviceStore creates PushDeviceStore which uses shards and prefix of
+// Broker.
+func NewPushDeviceStore(b *Broker) (*PushDeviceStore, error) {
+	if b == nil {
+		return nil, errors.New("push device store: no broker provided")
+	}
+	return &PushDeviceStore{broker: b}, nil
+}
+
+// AddDevice registers device of user.
+func (s *PushDeviceStore) AddDevice(user string, d pushnotify.Device) error {
+	shard := s.broker.getShard(user)
+	key := s.devicesKey(shard, user)
+	dr := shard.newDataRequest("SADD", nil, key, []interface{}{key, d.Platform + ":" + d.Token})
+	return shard.getDataResponse(dr).err
+}
+
+// RemoveDevice unregisters device of user.
+func (s *PushDeviceStore) RemoveDevice(user string, d pushnotify.Device) error {
+	shard := s.broker.getShard(user)
+	key := s.devicesKey(shard, user)
+	dr := shard.newDataRequest("SREM", nil, key, []interface{}{key, d.Platform + ":" + d.Token})
+	return shard.getDataResponse(dr).err
+}
+
+// Devices returns all registered devices of user.
+func (s *PushDeviceStore) Devices(user string) ([]pushnotify.Device, error) {
+	shard := s.broker.getShard(user)
+	key := s.devicesKey(shard, user)
+	dr := shard.newDataRequest("SMEMBERS", nil, key, []interface{}{key})
+	resp := shard.getDataResponse(dr)
+	members, err := redis.Strings(resp.reply, resp.err)
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]pushnotify.Device, 0, len(members))
+	for _, member := range members {
+		d, ok := parsePushDevice(member)
+		if !ok {
+			continue
+		}
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+func parsePushDevice(member string) (pushnotify.Device, bool) {
+	parts := strings.SplitN(member, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return pushnotify.Device{}, false
+	}
+	return pushnotify.Device{Platform: parts[0], Token: parts[1]}, true
+}
+
+func (s *PushDeviceStore) devicesKey(shard *Shard, user string) channelID {
+	if shard.useCluster {
+		user = "{" + user + "}"
+	}
+	return channelID(s.broker.config.Prefix + ".push.devices." + user)
+}
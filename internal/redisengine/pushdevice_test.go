@@ -0,0 +1,25 @@
+package redisengine
+
+import (
+	"testing"
+
+	"github.com/centrifugal/centrifugo/v3/internal/pushnotify"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePushDevice(t *testing.T) {
+	d, ok := parsePushDevice("apns:abc:def")
+	require.True(t, ok)
+	require.Equal(t, pushnotify.Device{Platform: "apns", Token: "abc:def"}, d)
+	_, ok = parsePushDevice("fcm:")
+	require.False(t, ok)
+	_, ok = parsePushDevice("fcm")
+	require.False(t, ok)
+}
+
+func TestPushDevicesKey(t *testing.T) {
+	s := &PushDeviceStore{broker: &Broker{config: BrokerConfig{Prefix: "centrifugo"}}}
+	require.Equal(t, channelID("centrifugo.push.devices.42"), s.devicesKey(&Shard{}, "42"))
+	require.Equal(t, channelID("centrifugo.push.devices.{42}"), s.devicesKey(&Shard{useCluster: true}, "42"))
+}
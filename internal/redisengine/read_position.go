@@ -0,0 +1,112 @@
+package redisengine
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Set last read position of user in channel. Position kept as "offset:epoch"
+// and only moved forward within the same epoch.
+// KEYS[1] - user read positions hash key
+// ARGV[1] - channel
+// ARGV[2] - offset
+// ARGV[3] - epoch
+const setReadPositionSource = `
+local current = redis.call("hget", KEYS[1], ARGV[1])
+if current ~= false then
+  local sep = string.find(current, ":", 1, true)
+  if sep ~= nil and string.sub(current, sep + 1) == ARGV[3] and tonumber(string.sub(current, 1, sep - 1)) >= tonumber(ARGV[2]) then
+    return 0
+  end
+end
+redis.call("hset", KEYS[1], ARGV[1], ARGV[2] .. ":" .. ARGV[3])
+return 1
+	`
+
+// ReadPositionStore keeps last read stream positions of users in channels in
+// Redis. Positions of user live in one hash, users sharded between Redis
+// shards of Broker.
+type ReadPositionStore struct {
+	broker    *Broker
+	setScript *redis.Script
+}
+
+// NewReadPositionStore creates ReadPositionStore which uses shards and prefix
+// of Broker.
+func NewReadPositionStore(b *Broker) (*ReadPositionStore, error) {
+	if b == nil {
+		return nil, errors.New("read position store: no broker provided")
+	}
+	s := &ReadPositionStore{
+		broker:    b,
+		setScript: redis.NewScript(1, setReadPositionSource),
+	}
+	b.registerScripts(s.setScript)
+	return s, nil
+}
+
+// SetReadPosition sets last read position of user in channel. Position is
+// only moved forward within the same epoch, position with another epoch
+// replaces current one.
+func (s *ReadPositionStore) SetReadPosition(user string, ch string, sp centrifuge.StreamPosition) error {
+	shard := s.broker.getShard(user)
+	key := s.readPositionsKey(shard, user)
+	dr := shard.newDataRequest("", s.setScript, key, []interface{}{key, ch, sp.Offset, sp.Epoch})
+	return shard.getDataResponse(dr).err
+}
+
+// ReadPositions returns last read positions of user in channels. Channels
+// without position are not included into result.
+func (s *ReadPositionStore) ReadPositions(user string, channels []string) (map[string]centrifuge.StreamPosition, error) {
+	result := make(map[string]centrifuge.StreamPosition, len(channels))
+	if len(channels) == 0 {
+		return result, nil
+	}
+	shard := s.broker.getShard(user)
+	key := s.readPositionsKey(shard, user)
+	args := make([]interface{}, 0, len(channels)+1)
+	args = append(args, key)
+	for _, ch := range channels {
+		args = append(args, ch)
+	}
+	dr := shard.newDataRequest("HMGET", nil, key, args)
+	resp := shard.getDataResponse(dr)
+	values, err := redis.Strings(resp.reply, resp.err)
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		if value == "" || i >= len(channels) {
+			continue
+		}
+		sp, err := parseReadPosition(value)
+		if err != nil {
+			return nil, err
+		}
+		result[channels[i]] = sp
+	}
+	return result, nil
+}
+
+func parseReadPosition(value string) (centrifuge.StreamPosition, error) {
+	sep := strings.Index(value, ":")
+	if sep <= 0 {
+		return centrifuge.StreamPosition{}, errors.New("malformed read position: " + value)
+	}
+	offset, err := strconv.ParseUint(value[:sep], 10, 64)
+	if err != nil {
+		return centrifuge.StreamPosition{}, err
+	}
+	return centrifuge.StreamPosition{Offset: offset, Epoch: value[sep+1:]}, nil
+}
+
+func (s *ReadPositionStore) readPositionsKey(shard *Shard, user string) channelID {
+	if shard.useCluster {
+		user = "{" + user + "}"
+	}
+	return channelID(s.broker.config.Prefix + ".read." + user)
+}
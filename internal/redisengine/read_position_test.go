@@ -0,0 +1,29 @@
+package redisengine
+
+import (
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReadPosition(t *testing.T) {
+	sp, err := parseReadPosition("12:abc")
+	require.NoError(t, err)
+	require.Equal(t, centrifuge.StreamPosition{Offset: 12, Epoch: "abc"}, sp)
+
+	sp, err = parseReadPosition("3:")
+	require.NoError(t, err)
+	require.Equal(t, centrifuge.StreamPosition{Offset: 3}, sp)
+
+	_, err = parseReadPosition("abc")
+	require.Error(t, err)
+	_, err = parseReadPosition("x:abc")
+	require.Error(t, err)
+}
+
+func TestReadPositionsKey(t *testing.T) {
+	s := &ReadPositionStore{broker: &Broker{config: BrokerConfig{Prefix: "centrifugo"}}}
+	require.Equal(t, channelID("centrifugo.read.42"), s.readPositionsKey(&Shard{}, "42"))
+	require.Equal(t, channelID("centrifugo.read.{42}"), s.readPositionsKey(&Shard{useCluster: true}, "42"))
+}
@@ -0,0 +1,396 @@
+package redisengine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/gomodule/redigo/redis"
+)
+
+// DefaultShardMigrationDelay is a default value for BrokerConfig.ShardMigrationDelay.
+const DefaultShardMigrationDelay = 30 * time.Second
+
+// redisShardMigrationConcurrency is a number of concurrent epoch bump requests
+// sent to new shard while migrating channels.
+const redisShardMigrationConcurrency = 16
+
+// redisShardMigrationScanCount is a COUNT hint of SCAN iterations over keys
+// of previous shards while copying keys to new shard.
+const redisShardMigrationScanCount = 100
+
+// historyMetaMigratedField is set in history meta hash copied from previous
+// shard so that epoch bump of another node does not remove copied history.
+const historyMetaMigratedField = "m"
+
+// Remove channel history created before time so that new epoch will be
+// generated on next history access. History copied from previous shard kept.
+// KEYS[1] - history list or stream key
+// KEYS[2] - history meta hash key
+// ARGV[1] - unix time in seconds
+const epochBumpSource = `
+local epoch = redis.call("hget", KEYS[2], "e")
+if epoch ~= false and tonumber(epoch) < tonumber(ARGV[1]) and redis.call("hexists", KEYS[2], "m") == 0 then
+  redis.call("del", KEYS[1], KEYS[2])
+  return 1
+end
+return 0
+	`
+
+// shardKeyMarker is a part of key following prefix which is followed by value
+// used to choose shard of key. Keys with memberSuffix have client ID after it.
+type shardKeyMarker struct {
+	marker       string
+	memberSuffix bool
+}
+
+// brokerShardKeyMarkers contain markers of all Broker keys sharded by channel,
+// user or other name.
+var brokerShardKeyMarkers = []shardKeyMarker{
+	{".stream.compaction.index.", false},
+	{".stream.compaction.", false},
+	{".stream.meta.", false},
+	{".list.meta.", false},
+	{".stream.", false},
+	{".list.", false},
+	{".meta.hash.", false},
+	{".meta.expire.", false},
+	{".group.", false},
+	{".seq.", false},
+	{".lock.", false},
+	{".inbox.", false},
+	{".dedup.", false},
+	{".push.devices.", false},
+	{".read.", false},
+}
+
+// presenceShardKeyMarkers contain markers of PresenceManager keys.
+var presenceShardKeyMarkers = []shardKeyMarker{
+	{".presence.data.", false},
+	{".presence.expire.", false},
+	{".presence.index.", true},
+	{".presence.member.", true},
+}
+
+// parseShardKey extracts value used to choose shard of key. Custom key
+// prefixes are placed between prefix and marker so the earliest marker found
+// in key wins, the longest one if several markers found at the same position.
+func parseShardKey(prefix string, key string, markers []shardKeyMarker) (string, bool) {
+	if !strings.HasPrefix(key, prefix) {
+		return "", false
+	}
+	rest := key[len(prefix):]
+	index := -1
+	var found shardKeyMarker
+	for _, m := range markers {
+		i := strings.Index(rest, m.marker)
+		if i < 0 {
+			continue
+		}
+		if index < 0 || i < index || (i == index && len(m.marker) > len(found.marker)) {
+			index, found = i, m
+		}
+	}
+	if index < 0 {
+		return "", false
+	}
+	name := rest[index+len(found.marker):]
+	if found.memberSuffix {
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			return "", false
+		}
+		name = name[:i]
+	}
+	if strings.HasPrefix(name, "{") && strings.HasSuffix(name, "}") {
+		name = name[1 : len(name)-1]
+	}
+	return name, name != ""
+}
+
+// migratedChannels returns channels which move to a new shard when shard added
+// to numShards existing ones. Consistent hashing guarantees that channels only
+// move to a new shard so other channels keep their shards.
+func migratedChannels(channels []string, numShards int) []string {
+	var migrated []string
+	for _, ch := range channels {
+		if consistentIndex(ch, numShards+1) == numShards {
+			migrated = append(migrated, ch)
+		}
+	}
+	return migrated
+}
+
+// AddShard adds new shard to Broker at runtime. Channels of node which belong
+// to new shard after ring recalculation are resubscribed on new shard, their
+// subscriptions on previous shards kept for ShardMigrationDelay since other
+// nodes can still publish there until shard added on them too.
+//
+// History of node channels in new shard created before since is removed, so
+// clients get new epoch on recovery and can't recover from stale stream left
+// in new shard. Then all keys moved to new shard (history, channel meta,
+// channel groups, locks, inboxes, etc.) are copied from previous shards.
+// Keys already existing in new shard are not overwritten, so copy is safe to
+// run concurrently on all nodes and AddShard can be called again to resume
+// migration if it failed. Keys are left in previous shards, so addition can be
+// reverted by restarting nodes without new shard. Keys of Redis Cluster shards
+// are not copied since SCAN iterates over a single cluster node only.
+//
+// Returns number of node channels migrated to new shard.
+func (b *Broker) AddShard(s *Shard, since time.Time) (int, error) {
+	b.addShardMu.Lock()
+	defer b.addShardMu.Unlock()
+
+	prevShards := b.getShards()
+	for _, shard := range prevShards {
+		if shard.string() == s.string() {
+			return 0, fmt.Errorf("shard %s already added", s.string())
+		}
+	}
+	if s.useCluster != prevShards[0].useCluster {
+		return 0, errors.New("can't mix Redis Cluster and standalone shards")
+	}
+
+	if err := b.checkCapabilities(s); err != nil {
+		return 0, fmt.Errorf("capability error on %s: %v", s.string(), err)
+	}
+
+	migrated := migratedChannels(b.node.Hub().Channels(), len(prevShards))
+
+	// Scripts registered directly since shard not used by Broker yet.
+	s.registerScripts(b.epochBumpScript)
+	if err := b.bumpEpochs(s, migrated, since); err != nil {
+		return 0, fmt.Errorf("error bumping epochs on %s: %v", s.string(), err)
+	}
+	numKeys, err := copyShardKeys(prevShards, s, b.config.Prefix, brokerShardKeyMarkers, b.isHistoryMetaKey)
+	if err != nil {
+		return 0, fmt.Errorf("error copying keys to %s: %v", s.string(), err)
+	}
+
+	b.shardsMu.Lock()
+	for _, fn := range b.shardHandlers {
+		fn(s)
+	}
+	// Shards slice can be shared with PresenceManager so never append in place.
+	shards := make([]*Shard, len(prevShards)+1)
+	copy(shards, prevShards)
+	shards[len(prevShards)] = s
+	b.shards = shards
+	b.sharding = true
+	b.shardsMu.Unlock()
+
+	b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "broker: Redis shard added", map[string]interface{}{"shard": s.string(), "num_shards": len(prevShards) + 1, "num_migrated_channels": len(migrated), "num_copied_keys": numKeys}))
+
+	if b.eventHandler == nil {
+		// Broker not running (for example when separate broker used and only
+		// data structures of Broker needed) – no PUB/SUB to migrate.
+		return len(migrated), nil
+	}
+
+	// Migrated channels subscribed on new shard on PUB/SUB start.
+	if err := b.runShard(s, b.eventHandler); err != nil {
+		return 0, err
+	}
+
+	go func() {
+		time.Sleep(b.config.ShardMigrationDelay)
+		b.unsubscribeMigrated(prevShards, migrated)
+	}()
+
+	return len(migrated), nil
+}
+
+func (b *Broker) isHistoryMetaKey(key string) bool {
+	marker := ".stream.meta."
+	if b.config.UseLists {
+		marker = ".list.meta."
+	}
+	return strings.Contains(key, marker)
+}
+
+// copyShardKeys copies keys of previous shards with prefix which belong to new
+// shard s after it added. Keys existing in new shard are skipped. isHistoryMeta
+// reports whether key is a history meta hash which must be marked as copied.
+// Returns number of copied keys.
+func copyShardKeys(prevShards []*Shard, s *Shard, prefix string, markers []shardKeyMarker, isHistoryMeta func(key string) bool) (int, error) {
+	numShards := len(prevShards) + 1
+	var numCopied int
+	for _, prevShard := range prevShards {
+		if prevShard.useCluster {
+			continue
+		}
+		n, err := copyKeysFromShard(prevShard, s, prefix, markers, numShards, isHistoryMeta)
+		numCopied += n
+		if err != nil {
+			return numCopied, fmt.Errorf("from %s: %w", prevShard.string(), err)
+		}
+	}
+	return numCopied, nil
+}
+
+func copyKeysFromShard(from *Shard, to *Shard, prefix string, markers []shardKeyMarker, numShards int, isHistoryMeta func(key string) bool) (int, error) {
+	fromConn := from.pool.Get()
+	defer func() { _ = fromConn.Close() }()
+	toConn := to.pool.Get()
+	defer func() { _ = toConn.Close() }()
+
+	var numCopied int
+	cursor := "0"
+	for {
+		reply, err := redis.Values(fromConn.Do("SCAN", cursor, "MATCH", prefix+".*", "COUNT", redisShardMigrationScanCount))
+		if err != nil {
+			return numCopied, err
+		}
+		var keys []string
+		if _, err := redis.Scan(reply, &cursor, &keys); err != nil {
+			return numCopied, err
+		}
+		for _, key := range keys {
+			name, ok := parseShardKey(prefix, key, markers)
+			if !ok || consistentIndex(name, numShards) != numShards-1 {
+				continue
+			}
+			copied, err := copyKey(fromConn, toConn, key, isHistoryMeta(key))
+			if err != nil {
+				return numCopied, err
+			}
+			if copied {
+				numCopied++
+			}
+		}
+		if cursor == "0" {
+			return numCopied, nil
+		}
+	}
+}
+
+// copyKey copies key with its TTL using DUMP and RESTORE. Returns false if key
+// expired in source or already exists in destination.
+func copyKey(fromConn redis.Conn, toConn redis.Conn, key string, historyMeta bool) (bool, error) {
+	_ = fromConn.Send("PTTL", key)
+	_ = fromConn.Send("DUMP", key)
+	if err := fromConn.Flush(); err != nil {
+		return false, err
+	}
+	ttl, err := redis.Int64(fromConn.Receive())
+	if err != nil {
+		return false, err
+	}
+	data, err := redis.Bytes(fromConn.Receive())
+	if err == redis.ErrNil || ttl == -2 {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if ttl < 0 {
+		// Key without expiration.
+		ttl = 0
+	}
+	if _, err := toConn.Do("RESTORE", key, ttl, data); err != nil {
+		if strings.HasPrefix(err.Error(), "BUSYKEY") {
+			return false, nil
+		}
+		return false, err
+	}
+	if historyMeta {
+		if _, err := toConn.Do("HSET", key, historyMetaMigratedField, 1); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+func (b *Broker) bumpEpochs(s *Shard, channels []string, since time.Time) error {
+	sem := make(chan struct{}, redisShardMigrationConcurrency)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var bumpErr error
+
+	for _, ch := range channels {
+		ch := ch
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			var historyKey channelID
+			if b.config.UseLists {
+				historyKey = b.historyListKey(s, ch)
+			} else {
+				historyKey = b.historyStreamKey(s, ch)
+			}
+			metaKey := b.historyMetaKey(s, ch)
+			dr := s.newDataRequest("", b.epochBumpScript, historyKey, []interface{}{historyKey, metaKey, since.Unix()})
+			resp := s.getDataResponse(dr)
+			if _, err := redis.Int(resp.reply, resp.err); err != nil {
+				errOnce.Do(func() { bumpErr = err })
+			}
+		}()
+	}
+	wg.Wait()
+	return bumpErr
+}
+
+// unsubscribeMigrated unsubscribes channels moved to new shard from previous
+// shards in batches.
+func (b *Broker) unsubscribeMigrated(prevShards []*Shard, channels []string) {
+	byShard := map[*Shard][]channelID{}
+	for _, ch := range channels {
+		s := prevShards[consistentIndex(ch, len(prevShards))]
+		byShard[s] = append(byShard[s], b.messageChannelID(ch))
+	}
+	for s, chIDs := range byShard {
+		for len(chIDs) > 0 {
+			n := redisSubscribeBatchLimit
+			if n > len(chIDs) {
+				n = len(chIDs)
+			}
+			r := newSubRequest(chIDs[:n], false)
+			if err := s.sendSubscribe(r); err != nil {
+				// Not critical: shard PUB/SUB connection resubscribes only to
+				// channels it owns upon reconnect.
+				b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error unsubscribing migrated channels", map[string]interface{}{"shard": s.string(), "error": err.Error()}))
+				break
+			}
+			chIDs = chIDs[n:]
+		}
+	}
+	b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "broker: channels migration finished", map[string]interface{}{"num_migrated_channels": len(channels)}))
+}
+
+// AddShard adds new shard to PresenceManager at runtime. Presence keys of
+// channels moved to new shard copied from previous shards the same way as
+// Broker.AddShard does, copies left in previous shards expire with presence
+// TTL.
+func (m *PresenceManager) AddShard(s *Shard) error {
+	m.shardsMu.RLock()
+	prevShards := m.shards
+	m.shardsMu.RUnlock()
+	for _, shard := range prevShards {
+		if shard.string() == s.string() {
+			return fmt.Errorf("shard %s already added", s.string())
+		}
+	}
+	// Copied without holding lock, keys already in new shard are never
+	// overwritten so presence added concurrently is not lost.
+	if _, err := copyShardKeys(prevShards, s, m.config.Prefix, presenceShardKeyMarkers, func(string) bool { return false }); err != nil {
+		return fmt.Errorf("error copying presence keys to %s: %v", s.string(), err)
+	}
+	m.shardsMu.Lock()
+	defer m.shardsMu.Unlock()
+	s.registerScripts(
+		m.addPresenceScript,
+		m.remPresenceScript,
+		m.presenceScript,
+		m.presenceScanScript,
+	)
+	m.memberScripts.register(s)
+	shards := make([]*Shard, len(m.shards)+1)
+	copy(shards, m.shards)
+	shards[len(m.shards)] = s
+	m.shards = shards
+	m.sharding = true
+	return nil
+}
@@ -0,0 +1,63 @@
+package redisengine
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigratedChannels(t *testing.T) {
+	channels := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		channels = append(channels, "channel"+strconv.Itoa(i))
+	}
+	migrated := migratedChannels(channels, 3)
+	require.NotEmpty(t, migrated)
+	require.Less(t, len(migrated), len(channels)/2)
+
+	isMigrated := map[string]bool{}
+	for _, ch := range migrated {
+		require.Equal(t, 3, consistentIndex(ch, 4))
+		isMigrated[ch] = true
+	}
+	for _, ch := range channels {
+		if !isMigrated[ch] {
+			// Channels not migrated keep their shard.
+			require.Equal(t, consistentIndex(ch, 3), consistentIndex(ch, 4))
+		}
+	}
+	require.Empty(t, migratedChannels(nil, 3))
+}
+
+func TestParseShardKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		markers []shardKeyMarker
+		name    string
+		ok      bool
+	}{
+		{"centrifugo.stream.chat", brokerShardKeyMarkers, "chat", true},
+		{"centrifugo.stream.meta.chat", brokerShardKeyMarkers, "chat", true},
+		{"centrifugo.list.meta.{chat}", brokerShardKeyMarkers, "chat", true},
+		{"centrifugo.stream.compaction.index.chat", brokerShardKeyMarkers, "chat", true},
+		{"centrifugo.tenant.t1.meta.hash.chat:1", brokerShardKeyMarkers, "chat:1", true},
+		{"centrifugo.lock.name", brokerShardKeyMarkers, "name", true},
+		{"centrifugo.push.devices.42", brokerShardKeyMarkers, "42", true},
+		{"centrifugo.stream.a.list.b", brokerShardKeyMarkers, "a.list.b", true},
+		{"centrifugo.compaction.pending", brokerShardKeyMarkers, "", false},
+		{"other.stream.chat", brokerShardKeyMarkers, "", false},
+		{"centrifugo.presence.member.chat.client", presenceShardKeyMarkers, "chat", true},
+		{"centrifugo.presence.member.{chat}.client", presenceShardKeyMarkers, "chat", true},
+		{"centrifugo.presence.data.chat", presenceShardKeyMarkers, "chat", true},
+		{"centrifugo.presence.index.chat.15", presenceShardKeyMarkers, "chat", true},
+		{"centrifugo.presence.member.chat", presenceShardKeyMarkers, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			name, ok := parseShardKey("centrifugo", tt.key, tt.markers)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.name, name)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package redisengine
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// DefaultPubSubResubscribeConcurrency is a default value for
+// BrokerConfig.PubSubResubscribeConcurrency.
+const DefaultPubSubResubscribeConcurrency = 8
+
+// resubscribe subscribes PUB/SUB connection of shard with index to ping channel
+// and all node channels which belong to it after connection (re)established.
+// Batches of channels sent concurrently so subscriber goroutine always has the
+// next batch ready. Number of channels waiting for subscription is available
+// over ResubscribePending and in metrics until resubscribe finished.
+func (b *Broker) resubscribe(s *Shard, index int, done chan struct{}) error {
+	chIDs := []channelID{channelID(b.pingChannel)}
+	for _, ch := range b.node.Hub().Channels() {
+		if b.getShard(ch) != s {
+			continue
+		}
+		chID := b.messageChannelID(ch)
+		if s.pubSubIndex(chID) == index {
+			chIDs = append(chIDs, chID)
+		}
+	}
+
+	started := time.Now()
+	s.setResubscribePending(index, len(chIDs))
+
+	batches := make(chan []channelID)
+	go func() {
+		defer close(batches)
+		for i := 0; i < len(chIDs); i += redisSubscribeBatchLimit {
+			end := i + redisSubscribeBatchLimit
+			if end > len(chIDs) {
+				end = len(chIDs)
+			}
+			select {
+			case batches <- chIDs[i:end]:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	concurrency := b.config.PubSubResubscribeConcurrency
+	if concurrency == 0 {
+		concurrency = DefaultPubSubResubscribeConcurrency
+	}
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var resubscribeErr error
+	failed := make(chan struct{})
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				select {
+				case <-failed:
+					continue
+				default:
+				}
+				if err := s.sendSubRequest(index, newSubRequest(batch, true)); err != nil {
+					errOnce.Do(func() {
+						resubscribeErr = err
+						close(failed)
+					})
+					continue
+				}
+				s.addResubscribePending(index, -len(batch))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if resubscribeErr != nil {
+		return resubscribeErr
+	}
+	if len(chIDs) > 1 {
+		b.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "resubscribed to Redis channels", map[string]interface{}{"shard": s.string(), "connection": index, "num_channels": len(chIDs) - 1, "elapsed": time.Since(started).String()}))
+	}
+	return nil
+}
+
+func (s *Shard) setResubscribePending(index int, n int) {
+	atomic.StoreInt64(&s.resubscribePending[index], int64(n))
+	s.resubscribePendingGauge.Set(float64(s.pendingResubscribe()))
+}
+
+func (s *Shard) addResubscribePending(index int, delta int) {
+	atomic.AddInt64(&s.resubscribePending[index], int64(delta))
+	s.resubscribePendingGauge.Set(float64(s.pendingResubscribe()))
+}
+
+// pendingResubscribe returns number of channels waiting for subscription on
+// all PUB/SUB connections of shard.
+func (s *Shard) pendingResubscribe() int64 {
+	var pending int64
+	for i := range s.resubscribePending {
+		pending += atomic.LoadInt64(&s.resubscribePending[i])
+	}
+	return pending
+}
+
+// ResubscribePending returns number of channels waiting for subscription on
+// PUB/SUB connections of all shards after connections (re)established. While
+// it's not zero node does not receive publications of some channels.
+func (b *Broker) ResubscribePending() int {
+	b.shardsMu.RLock()
+	defer b.shardsMu.RUnlock()
+	var pending int64
+	for _, s := range b.shards {
+		pending += s.pendingResubscribe()
+	}
+	return int(pending)
+}
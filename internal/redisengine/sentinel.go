@@ -0,0 +1,138 @@
+package redisengine
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	sentinelSwitchMasterChannel = "+switch-master"
+	sentinelPingInterval        = 5 * time.Second
+	sentinelReconnectDelay      = time.Second
+)
+
+// masterConns keeps connections dialed to Redis masters discovered from
+// Sentinel, so they can be closed as soon as Sentinel switches master – without
+// waiting for read timeout on connections to the old master.
+type masterConns struct {
+	mu    sync.Mutex
+	conns map[*masterConn]struct{}
+}
+
+func newMasterConns() *masterConns {
+	return &masterConns{conns: map[*masterConn]struct{}{}}
+}
+
+// track returns connection which is removed from set when closed.
+func (m *masterConns) track(c redis.Conn, addr string) redis.Conn {
+	mc := &masterConn{Conn: c, addr: addr, conns: m}
+	m.mu.Lock()
+	m.conns[mc] = struct{}{}
+	m.mu.Unlock()
+	return mc
+}
+
+// closeAddr closes all connections to addr and returns number of closed
+// connections.
+func (m *masterConns) closeAddr(addr string) int {
+	var toClose []*masterConn
+	m.mu.Lock()
+	for c := range m.conns {
+		if c.addr == addr {
+			toClose = append(toClose, c)
+		}
+	}
+	m.mu.Unlock()
+	for _, c := range toClose {
+		_ = c.Close()
+	}
+	return len(toClose)
+}
+
+type masterConn struct {
+	redis.Conn
+	addr  string
+	conns *masterConns
+}
+
+func (c *masterConn) Close() error {
+	c.conns.mu.Lock()
+	delete(c.conns.conns, c)
+	c.conns.mu.Unlock()
+	return c.Conn.Close()
+}
+
+func (c *masterConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	return redis.DoWithTimeout(c.Conn, timeout, cmd, args...)
+}
+
+func (c *masterConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return redis.ReceiveWithTimeout(c.Conn, timeout)
+}
+
+// parseSwitchMaster parses +switch-master message of Sentinel which has
+// format "<master name> <old ip> <old port> <new ip> <new port>".
+func parseSwitchMaster(data []byte, masterName string) (string, string, bool) {
+	parts := strings.Split(string(data), " ")
+	if len(parts) != 5 || parts[0] != masterName {
+		return "", "", false
+	}
+	return net.JoinHostPort(parts[1], parts[2]), net.JoinHostPort(parts[3], parts[4]), true
+}
+
+// watchSwitchMaster subscribes to +switch-master channel of Sentinels and
+// calls onSwitch when master with masterName changed. Sentinels are tried in
+// turn if connection lost.
+func watchSwitchMaster(n *centrifuge.Node, addrs []string, masterName string, dial func(addr string) (redis.Conn, error), onSwitch func(oldAddr, newAddr string)) {
+	for i := 0; ; i++ {
+		addr := addrs[i%len(addrs)]
+		err := receiveSwitchMaster(addr, masterName, dial, onSwitch)
+		n.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error receiving Sentinel master switch events", map[string]interface{}{"error": err.Error(), "addr": addr}))
+		time.Sleep(sentinelReconnectDelay)
+	}
+}
+
+func receiveSwitchMaster(addr string, masterName string, dial func(addr string) (redis.Conn, error), onSwitch func(oldAddr, newAddr string)) error {
+	c, err := dial(addr)
+	if err != nil {
+		return err
+	}
+	psc := redis.PubSubConn{Conn: c}
+	defer func() { _ = psc.Close() }()
+	if err := psc.Subscribe(sentinelSwitchMasterChannel); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(sentinelPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := psc.Ping(""); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		switch m := psc.ReceiveWithTimeout(3 * sentinelPingInterval).(type) {
+		case redis.Message:
+			if oldAddr, newAddr, ok := parseSwitchMaster(m.Data, masterName); ok {
+				onSwitch(oldAddr, newAddr)
+			}
+		case error:
+			return m
+		}
+	}
+}
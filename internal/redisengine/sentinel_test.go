@@ -0,0 +1,52 @@
+package redisengine
+
+import (
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSwitchMaster(t *testing.T) {
+	oldAddr, newAddr, ok := parseSwitchMaster([]byte("mymaster 127.0.0.1 6379 127.0.0.2 6380"), "mymaster")
+	require.True(t, ok)
+	require.Equal(t, "127.0.0.1:6379", oldAddr)
+	require.Equal(t, "127.0.0.2:6380", newAddr)
+
+	_, _, ok = parseSwitchMaster([]byte("other 127.0.0.1 6379 127.0.0.2 6380"), "mymaster")
+	require.False(t, ok)
+	_, _, ok = parseSwitchMaster([]byte("mymaster 127.0.0.1 6379"), "mymaster")
+	require.False(t, ok)
+}
+
+type closeTestConn struct {
+	redis.Conn
+	closed bool
+}
+
+func (c *closeTestConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestMasterConnsCloseAddr(t *testing.T) {
+	conns := newMasterConns()
+	c1 := &closeTestConn{}
+	c2 := &closeTestConn{}
+	c3 := &closeTestConn{}
+	tracked1 := conns.track(c1, "127.0.0.1:6379")
+	_ = conns.track(c2, "127.0.0.1:6379")
+	_ = conns.track(c3, "127.0.0.2:6379")
+
+	// Closed connections not tracked anymore.
+	require.NoError(t, tracked1.Close())
+	require.True(t, c1.closed)
+	c1.closed = false
+
+	require.Equal(t, 1, conns.closeAddr("127.0.0.1:6379"))
+	require.False(t, c1.closed)
+	require.True(t, c2.closed)
+	require.False(t, c3.closed)
+	require.Equal(t, 0, conns.closeAddr("127.0.0.1:6379"))
+	require.Len(t, conns.conns, 1)
+}
@@ -0,0 +1,80 @@
+package redisengine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/protocol"
+)
+
+func infoToProto(v *centrifuge.ClientInfo) *protocol.ClientInfo {
+	if v == nil {
+		return nil
+	}
+	info := &protocol.ClientInfo{
+		Client: v.ClientID,
+		User:   v.UserID,
+	}
+	if len(v.ConnInfo) > 0 {
+		info.ConnInfo = v.ConnInfo
+	}
+	if len(v.ChanInfo) > 0 {
+		info.ChanInfo = v.ChanInfo
+	}
+	return info
+}
+
+func infoFromProto(v *protocol.ClientInfo) *centrifuge.ClientInfo {
+	if v == nil {
+		return nil
+	}
+	info := &centrifuge.ClientInfo{
+		ClientID: v.GetClient(),
+		UserID:   v.GetUser(),
+	}
+	if len(v.ConnInfo) > 0 {
+		info.ConnInfo = v.ConnInfo
+	}
+	if len(v.ChanInfo) > 0 {
+		info.ChanInfo = v.ChanInfo
+	}
+	return info
+}
+
+func pubFromProto(pub *protocol.Publication) *centrifuge.Publication {
+	if pub == nil {
+		return nil
+	}
+	return &centrifuge.Publication{
+		Offset: pub.GetOffset(),
+		Data:   pub.Data,
+		Info:   infoFromProto(pub.GetInfo()),
+	}
+}
+
+var timerPool sync.Pool
+
+// AcquireTimer from pool.
+func AcquireTimer(d time.Duration) *time.Timer {
+	v := timerPool.Get()
+	if v == nil {
+		return time.NewTimer(d)
+	}
+
+	tm := v.(*time.Timer)
+	if tm.Reset(d) {
+		panic("Received an active timer from the pool!")
+	}
+	return tm
+}
+
+// ReleaseTimer to pool.
+func ReleaseTimer(tm *time.Timer) {
+	if !tm.Stop() {
+		// Do not reuse timer that has been already stopped.
+		// See https://groups.google.com/forum/#!topic/golang-nuts/-8O3AknKpwk
+		return
+	}
+	timerPool.Put(tm)
+}
@@ -0,0 +1,82 @@
+package remoteconfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// consulWaitTime is a max duration of Consul blocking query.
+const consulWaitTime = "5m"
+
+// consulSource loads configuration using Consul KV HTTP API, modifications
+// are watched with blocking queries.
+type consulSource struct {
+	config Config
+}
+
+type consulKV struct {
+	Value       []byte `json:"Value"`
+	ModifyIndex uint64 `json:"ModifyIndex"`
+}
+
+func (s *consulSource) Get(ctx context.Context) (Value, error) {
+	value, _, err := s.get(ctx, 0)
+	return value, err
+}
+
+func (s *consulSource) Wait(ctx context.Context, version uint64) (Value, error) {
+	index := version
+	for {
+		value, newIndex, err := s.get(ctx, index)
+		if err != nil {
+			return Value{}, err
+		}
+		if value.Version != version {
+			return value, nil
+		}
+		if newIndex < index {
+			// Index went backwards, Consul recommends to reset it.
+			newIndex = 0
+		}
+		index = newIndex
+	}
+}
+
+// get requests key, blocks until index changes if index is not zero.
+func (s *consulSource) get(ctx context.Context, index uint64) (Value, uint64, error) {
+	u := s.config.Endpoint + "/v1/kv/" + s.config.Key
+	if index > 0 {
+		u += "?" + url.Values{"index": {strconv.FormatUint(index, 10)}, "wait": {consulWaitTime}}.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return Value{}, 0, err
+	}
+	if s.config.Token != "" {
+		req.Header.Set("X-Consul-Token", s.config.Token)
+	}
+	resp, err := s.config.Client.Do(req)
+	if err != nil {
+		return Value{}, 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if resp.StatusCode == http.StatusNotFound {
+		return Value{}, newIndex, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Value{}, newIndex, fmt.Errorf("%w: %d", errUnexpectedStatusCode, resp.StatusCode)
+	}
+	var kvs []consulKV
+	if err := json.NewDecoder(resp.Body).Decode(&kvs); err != nil {
+		return Value{}, newIndex, err
+	}
+	if len(kvs) == 0 {
+		return Value{}, newIndex, ErrNotFound
+	}
+	return Value{Data: kvs[0].Value, Version: kvs[0].ModifyIndex}, newIndex, nil
+}
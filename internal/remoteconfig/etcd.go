@@ -0,0 +1,129 @@
+package remoteconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// etcdSource loads configuration using etcd v3 JSON gateway, modifications
+// are watched with streaming watch request.
+type etcdSource struct {
+	config Config
+}
+
+// etcd gateway encodes 64-bit integers as strings and bytes as base64.
+type etcdKV struct {
+	Value       []byte `json:"value"`
+	ModRevision string `json:"mod_revision"`
+}
+
+type etcdRangeResponse struct {
+	KVs []etcdKV `json:"kvs"`
+}
+
+type etcdWatchResponse struct {
+	Result struct {
+		Canceled        bool   `json:"canceled"`
+		CancelReason    string `json:"cancel_reason"`
+		CompactRevision string `json:"compact_revision"`
+		Events          []struct {
+			Type string `json:"type"`
+			KV   etcdKV `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (kv etcdKV) value() Value {
+	revision, _ := strconv.ParseUint(kv.ModRevision, 10, 64)
+	return Value{Data: kv.Value, Version: revision}
+}
+
+func (s *etcdSource) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.Endpoint+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.config.Token != "" {
+		req.Header.Set("Authorization", s.config.Token)
+	}
+	resp, err := s.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%w: %d", errUnexpectedStatusCode, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (s *etcdSource) Get(ctx context.Context) (Value, error) {
+	resp, err := s.post(ctx, "/v3/kv/range", map[string]interface{}{
+		"key": []byte(s.config.Key),
+	})
+	if err != nil {
+		return Value{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var rangeResp etcdRangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rangeResp); err != nil {
+		return Value{}, err
+	}
+	if len(rangeResp.KVs) == 0 {
+		return Value{}, ErrNotFound
+	}
+	return rangeResp.KVs[0].value(), nil
+}
+
+func (s *etcdSource) Wait(ctx context.Context, version uint64) (Value, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	resp, err := s.post(ctx, "/v3/watch", map[string]interface{}{
+		"create_request": map[string]interface{}{
+			"key":            []byte(s.config.Key),
+			"start_revision": strconv.FormatUint(version+1, 10),
+		},
+	})
+	if err != nil {
+		return Value{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var watchResp etcdWatchResponse
+		if err := decoder.Decode(&watchResp); err != nil {
+			return Value{}, err
+		}
+		if watchResp.Error != nil {
+			return Value{}, fmt.Errorf("remoteconfig: etcd watch error: %s", watchResp.Error.Message)
+		}
+		result := watchResp.Result
+		if result.CompactRevision != "" && result.CompactRevision != "0" {
+			// Revisions after version compacted, load current configuration.
+			return s.Get(ctx)
+		}
+		if result.Canceled {
+			return Value{}, fmt.Errorf("remoteconfig: etcd watch canceled: %s", result.CancelReason)
+		}
+		if len(result.Events) == 0 {
+			continue
+		}
+		event := result.Events[len(result.Events)-1]
+		if event.Type == "DELETE" {
+			return Value{}, ErrNotFound
+		}
+		return event.KV.value(), nil
+	}
+}
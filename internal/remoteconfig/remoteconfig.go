@@ -0,0 +1,117 @@
+// Package remoteconfig loads configuration from remote key-value storages
+// (etcd or Consul) and watches it for changes. Configuration is stored under
+// a single key as JSON object in the same format as configuration file.
+package remoteconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound returned when configuration key does not exist in storage.
+	ErrNotFound = errors.New("remoteconfig: configuration key not found")
+
+	errUnexpectedStatusCode = errors.New("remoteconfig: unexpected status code")
+)
+
+const (
+	watchBackoffMin = 500 * time.Millisecond
+	watchBackoffMax = 30 * time.Second
+)
+
+// Value is a configuration loaded from storage.
+type Value struct {
+	// Data is a raw JSON configuration.
+	Data []byte
+	// Version changes every time configuration modified.
+	Version uint64
+}
+
+// Source is a remote storage to load configuration from.
+type Source interface {
+	// Get returns current configuration.
+	Get(ctx context.Context) (Value, error)
+	// Wait blocks until configuration modified after version and returns
+	// new configuration.
+	Wait(ctx context.Context, version uint64) (Value, error)
+}
+
+// Config of Source.
+type Config struct {
+	// Endpoint is an address of storage HTTP API, ex. http://127.0.0.1:2379 for
+	// etcd or http://127.0.0.1:8500 for Consul.
+	Endpoint string
+	// Key under which configuration stored.
+	Key string
+	// Token for storage auth: ACL token for Consul, auth token for etcd.
+	Token string
+	// Client used for requests, client without timeout used if not set since
+	// watch requests are long-living.
+	Client *http.Client
+}
+
+// New creates Source for provider which can be "etcd" or "consul".
+func New(provider string, c Config) (Source, error) {
+	if c.Endpoint == "" {
+		return nil, errors.New("remoteconfig: endpoint required")
+	}
+	if c.Key == "" {
+		return nil, errors.New("remoteconfig: key required")
+	}
+	c.Endpoint = strings.TrimSuffix(c.Endpoint, "/")
+	if c.Client == nil {
+		c.Client = &http.Client{}
+	}
+	switch provider {
+	case "etcd":
+		return &etcdSource{config: c}, nil
+	case "consul":
+		return &consulSource{config: c}, nil
+	default:
+		return nil, fmt.Errorf("remoteconfig: unknown provider: %s", provider)
+	}
+}
+
+// Parse decodes configuration into map of top-level keys.
+func Parse(data []byte) (map[string]interface{}, error) {
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("remoteconfig: malformed configuration: %w", err)
+	}
+	return config, nil
+}
+
+// Watch calls apply for every configuration modification after version until
+// ctx done. Storage errors passed to onError, waiting retried with exponential
+// backoff.
+func Watch(ctx context.Context, s Source, version uint64, apply func(Value), onError func(error)) {
+	backoff := watchBackoffMin
+	for {
+		value, err := s.Wait(ctx, version)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			onError(err)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			backoff *= 2
+			if backoff > watchBackoffMax {
+				backoff = watchBackoffMax
+			}
+			continue
+		}
+		backoff = watchBackoffMin
+		version = value.Version
+		apply(value)
+	}
+}
@@ -0,0 +1,170 @@
+package remoteconfig
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKV is a key-value storage modifications of which can be awaited.
+type fakeKV struct {
+	mu      sync.Mutex
+	value   []byte
+	version uint64
+	changed chan struct{}
+}
+
+func newFakeKV() *fakeKV {
+	return &fakeKV{changed: make(chan struct{})}
+}
+
+func (kv *fakeKV) set(value string) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.value = []byte(value)
+	kv.version++
+	close(kv.changed)
+	kv.changed = make(chan struct{})
+}
+
+func (kv *fakeKV) get() ([]byte, uint64, chan struct{}) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return kv.value, kv.version, kv.changed
+}
+
+func newFakeConsul(t *testing.T, kv *fakeKV) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/kv/centrifugo/config", r.URL.Path)
+		require.Equal(t, "secret", r.Header.Get("X-Consul-Token"))
+		value, version, changed := kv.get()
+		if index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); index > 0 && index == version {
+			select {
+			case <-changed:
+			case <-r.Context().Done():
+				return
+			}
+			value, version, _ = kv.get()
+		}
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(version, 10))
+		if version == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode([]consulKV{{Value: value, ModifyIndex: version}})
+	}))
+}
+
+func newFakeEtcd(t *testing.T, kv *fakeKV) *httptest.Server {
+	encodedKey := base64.StdEncoding.EncodeToString([]byte("centrifugo/config"))
+	kvJSON := func(value []byte, version uint64) string {
+		return fmt.Sprintf(`{"key":%q,"value":%q,"mod_revision":"%d"}`, encodedKey, base64.StdEncoding.EncodeToString(value), version)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Key           []byte `json:"key"`
+			CreateRequest struct {
+				Key           []byte `json:"key"`
+				StartRevision string `json:"start_revision"`
+			} `json:"create_request"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		value, version, changed := kv.get()
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			require.Equal(t, "centrifugo/config", string(req.Key))
+			if version == 0 {
+				_, _ = w.Write([]byte(`{"header":{"revision":"1"}}`))
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"header":{"revision":"%d"},"kvs":[%s],"count":"1"}`, version, kvJSON(value, version))
+		case "/v3/watch":
+			require.Equal(t, "centrifugo/config", string(req.CreateRequest.Key))
+			startRevision, _ := strconv.ParseUint(req.CreateRequest.StartRevision, 10, 64)
+			_, _ = w.Write([]byte(`{"result":{"header":{},"created":true}}` + "\n"))
+			w.(http.Flusher).Flush()
+			for version < startRevision {
+				select {
+				case <-changed:
+				case <-r.Context().Done():
+					return
+				}
+				value, version, changed = kv.get()
+			}
+			_, _ = fmt.Fprintf(w, `{"result":{"header":{},"events":[{"kv":%s}]}}`+"\n", kvJSON(value, version))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			require.Fail(t, "unexpected path "+r.URL.Path)
+		}
+	}))
+}
+
+func testSource(t *testing.T, provider string, newServer func(*testing.T, *fakeKV) *httptest.Server) {
+	kv := newFakeKV()
+	server := newServer(t, kv)
+	defer server.Close()
+
+	s, err := New(provider, Config{Endpoint: server.URL + "/", Key: "centrifugo/config", Token: "secret"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = s.Get(ctx)
+	require.Equal(t, ErrNotFound, err)
+
+	kv.set(`{"namespaces":[]}`)
+	value, err := s.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, `{"namespaces":[]}`, string(value.Data))
+	require.Equal(t, uint64(1), value.Version)
+
+	values := make(chan Value)
+	go Watch(ctx, s, value.Version, func(v Value) { values <- v }, func(err error) {
+		require.Fail(t, err.Error())
+	})
+	kv.set(`{"presence":true}`)
+	select {
+	case value = <-values:
+	case <-ctx.Done():
+		require.Fail(t, "timeout waiting configuration change")
+	}
+	require.Equal(t, `{"presence":true}`, string(value.Data))
+	require.Equal(t, uint64(2), value.Version)
+}
+
+func TestConsulSource(t *testing.T) {
+	testSource(t, "consul", newFakeConsul)
+}
+
+func TestEtcdSource(t *testing.T) {
+	testSource(t, "etcd", newFakeEtcd)
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("zookeeper", Config{Endpoint: "http://localhost", Key: "config"})
+	require.Error(t, err)
+	_, err = New("etcd", Config{Key: "config"})
+	require.Error(t, err)
+	_, err = New("consul", Config{Endpoint: "http://localhost"})
+	require.Error(t, err)
+}
+
+func TestParse(t *testing.T) {
+	config, err := Parse([]byte(`{"presence":true,"namespaces":[{"name":"chat"}]}`))
+	require.NoError(t, err)
+	require.Equal(t, true, config["presence"])
+	require.Len(t, config["namespaces"], 1)
+	_, err = Parse([]byte(`[]`))
+	require.Error(t, err)
+}
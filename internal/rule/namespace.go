@@ -7,6 +7,16 @@ type ChannelNamespace struct {
 	// Name is a unique namespace name.
 	Name string `mapstructure:"name" json:"name"`
 
+	// ChannelPattern is a glob pattern, ex. "room-*" or "*.chat". Channels
+	// matching it belong to namespace without having namespace name prefix.
+	// Pattern matched against channel name without private prefix. Namespaces
+	// with patterns are checked in configuration order before usual
+	// namespace boundary resolution.
+	ChannelPattern string `mapstructure:"channel_pattern" json:"channel_pattern"`
+
+	// ChannelRegex is like ChannelPattern but contains a regular expression.
+	ChannelRegex string `mapstructure:"channel_regex" json:"channel_regex"`
+
 	// Options for namespace determine channel options for channels
 	// belonging to this namespace.
 	ChannelOptions `mapstructure:",squash"`
@@ -19,6 +29,24 @@ type ChannelOptions struct {
 	// information about all clients currently subscribed to a channel.
 	Presence bool `mapstructure:"presence" json:"presence"`
 
+	// PresenceState allows clients to set small custom state of connection
+	// (for example typing status) kept in channel presence. State is set by
+	// client with $presence_state RPC and returned in presence as "state" key
+	// of channel info JSON object.
+	PresenceState bool `mapstructure:"presence_state" json:"presence_state"`
+
+	// PresenceStateBroadcast turns on broadcasting connection state changes
+	// to channel subscribers as publications {"presence_state": {...}} made
+	// on behalf of client, so they are wrapped and saved in history like other
+	// client publications of channel.
+	PresenceStateBroadcast bool `mapstructure:"presence_state_broadcast" json:"presence_state_broadcast"`
+
+	// PresenceMemberKeys keeps presence of channel in Redis in separate key
+	// of every member with TTL and index of members instead of a single hash.
+	// This helps with channels with huge number of subscribers where single
+	// presence hash becomes a hot giant key. Only used by Redis engine.
+	PresenceMemberKeys bool `mapstructure:"presence_member_keys" json:"presence_member_keys"`
+
 	// JoinLeave turns on join/leave messages for a channel.
 	// When client subscribes on a channel join message sent to all
 	// subscribers in this channel (including current client). When client
@@ -47,6 +75,13 @@ type ChannelOptions struct {
 	// HistorySize and HistoryTTL configuration.
 	Recover bool `mapstructure:"recover" json:"recover"`
 
+	// HistoryCompaction turns on compaction of channel history by key. Publications
+	// published over server API with compaction key replace previous publication
+	// with the same key in history, so history keeps current state of keys instead
+	// of full event log. Publications without key are kept as usual. Recovery may
+	// report not recovered state if the first missed publication was compacted.
+	HistoryCompaction bool `mapstructure:"history_compaction" json:"history_compaction"`
+
 	// Position enables client positioning.
 	Position bool `mapstructure:"position" json:"position"`
 
@@ -74,6 +109,46 @@ type ChannelOptions struct {
 	// Turn on this option and use empty string as user ID.
 	Anonymous bool `mapstructure:"anonymous" json:"anonymous"`
 
+	// Public makes channels available for restricted anonymous connections
+	// (see ClientAnonymousRestricted option). Such connections have read-only
+	// access to public channels and no access to other channels.
+	Public bool `mapstructure:"public" json:"public"`
+
+	// CoalescePublications marks channels where only the latest publication
+	// matters (for example cursor positions). When outbound client throttling
+	// is on, publication waiting for delivery in such channel is replaced by a
+	// newer one instead of queueing both.
+	CoalescePublications bool `mapstructure:"coalesce_publications" json:"coalesce_publications"`
+
+	// DeltaPublications turns on sending publications as JSON Merge Patch
+	// against the previous publication in channel. Only applied to JSON
+	// connections which asked for it with delta=merge-patch URL param, for
+	// such connections publication data is {"data": ...} or {"patch": ...}.
+	DeltaPublications bool `mapstructure:"delta_publications" json:"delta_publications"`
+
+	// PublicationTags turns on key/value tags of publications set over server
+	// API or by publish proxy. Publication data in such channels is an envelope
+	// {"tags": {...}, "data": <payload>}, payload must be JSON. Clients can set
+	// subscription filter by tags with $subscription_filter RPC to receive only
+	// matching publications.
+	PublicationTags bool `mapstructure:"publication_tags" json:"publication_tags"`
+
+	// PublicationOrigin turns on attaching origin metadata to publications:
+	// publisher type (api, client or proxy), user ID, client ID and node ID.
+	// Publication data in such channels is an envelope {"origin": {...},
+	// "data": <payload>}, payload must be JSON.
+	PublicationOrigin bool `mapstructure:"publication_origin" json:"publication_origin"`
+
+	// ChannelGroup makes channels composite: subscribers of channel receive
+	// publications of source channels of group set in configuration or over
+	// server API. Publications of sources are not kept in channel history and
+	// have no stream position, so recovery and positioning not supported.
+	ChannelGroup bool `mapstructure:"channel_group" json:"channel_group"`
+
+	// ChannelMetaOnSubscribe turns on sending channel meta entries (set over
+	// server API) to clients in subscribe result data as JSON object.
+	ChannelMetaOnSubscribe bool `mapstructure:"channel_meta_on_subscribe" json:"channel_meta_on_subscribe"`
+
 	// PresenceDisableForClient prevents presence to be asked by clients.
 	// In this case it's available only over server-side presence call.
 	PresenceDisableForClient bool `mapstructure:"presence_disable_for_client" json:"presence_disable_for_client"`
@@ -95,4 +170,41 @@ type ChannelOptions struct {
 
 	// PublishProxyName of proxy to use for publish operations in namespace.
 	PublishProxyName string `mapstructure:"publish_proxy_name" json:"publish_proxy_name"`
+
+	// PublicationSchema is a JSON Schema document client publications must
+	// match. Publications which are not valid JSON or do not match schema
+	// rejected with BadRequest error before reaching subscribers. Publications
+	// made over server API are not validated.
+	PublicationSchema string `mapstructure:"publication_schema" json:"publication_schema,omitempty"`
+
+	// PublicationSizeLimit is a max size in bytes of publication data clients
+	// can publish. Larger publications rejected with LimitExceeded error.
+	// Zero value means no limit (transport message size limit still applied).
+	// Publications made over server API are not limited.
+	PublicationSizeLimit int `mapstructure:"publication_size_limit" json:"publication_size_limit,omitempty"`
+
+	// PriorityPublications marks channels with critical publications (for
+	// example alerts). With Redis engine such publications are sent over
+	// separate publish pipeline with small batches, so they are not delayed
+	// by bulk traffic in other channels. Publications made over server API
+	// can also be marked as priority individually with priority flag.
+	PriorityPublications bool `mapstructure:"priority_publications" json:"priority_publications"`
+
+	// SubscribeRoles if set only allows connections having at least one of
+	// listed roles to subscribe on channels. Roles are set in connection JWT
+	// or connect proxy result. Role checks are applied on top of other options
+	// – i.e. roles can't grant permission which is not given otherwise.
+	SubscribeRoles []string `mapstructure:"subscribe_roles" json:"subscribe_roles,omitempty"`
+
+	// PublishRoles if set only allows connections having at least one of
+	// listed roles to publish into channels.
+	PublishRoles []string `mapstructure:"publish_roles" json:"publish_roles,omitempty"`
+
+	// HistoryRoles if set only allows connections having at least one of
+	// listed roles to read channel history.
+	HistoryRoles []string `mapstructure:"history_roles" json:"history_roles,omitempty"`
+
+	// PresenceRoles if set only allows connections having at least one of
+	// listed roles to ask channel presence and presence stats.
+	PresenceRoles []string `mapstructure:"presence_roles" json:"presence_roles,omitempty"`
 }
@@ -0,0 +1,136 @@
+package rule
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+)
+
+// ErrUnknownNamespace returned when channel belongs to namespace not defined
+// in configuration.
+var ErrUnknownNamespace = errors.New("unknown channel namespace")
+
+// ChannelOptionsOverride contains channel options which can be set for a single
+// channel at runtime on top of options defined by channel namespace. Only non-nil
+// fields override namespace options.
+type ChannelOptionsOverride struct {
+	Presence    *bool           `json:"presence,omitempty"`
+	JoinLeave   *bool           `json:"join_leave,omitempty"`
+	HistorySize *int            `json:"history_size,omitempty"`
+	HistoryTTL  *tools.Duration `json:"history_ttl,omitempty"`
+	Recover     *bool           `json:"recover,omitempty"`
+}
+
+func (o ChannelOptionsOverride) apply(opts *ChannelOptions) {
+	if o.Presence != nil {
+		opts.Presence = *o.Presence
+	}
+	if o.JoinLeave != nil {
+		opts.JoinLeave = *o.JoinLeave
+	}
+	if o.HistorySize != nil {
+		opts.HistorySize = *o.HistorySize
+	}
+	if o.HistoryTTL != nil {
+		opts.HistoryTTL = *o.HistoryTTL
+	}
+	if o.Recover != nil {
+		opts.Recover = *o.Recover
+	}
+}
+
+// ValidateChannelOptionsOverride checks that override can be applied to channel.
+// Error returned if channel namespace not found or resulting channel options are
+// invalid.
+func (n *Container) ValidateChannelOptionsOverride(ch string, override ChannelOptionsOverride) error {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.validateChannelOptionsOverride(ch, override)
+}
+
+func (n *Container) validateChannelOptionsOverride(ch string, override ChannelOptionsOverride) error {
+	opts, found, err := n.config.channelOpts(n.namespaceName(ch))
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrUnknownNamespace
+	}
+	override.apply(&opts)
+	return ValidateChannelOptions(opts)
+}
+
+// SetChannelOptionsOverride sets options override for a channel. Nil override
+// removes existing channel override so namespace options are used again.
+func (n *Container) SetChannelOptionsOverride(ch string, override *ChannelOptionsOverride) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if override == nil {
+		delete(n.overrides, ch)
+		return nil
+	}
+	if err := n.validateChannelOptionsOverride(ch, *override); err != nil {
+		return err
+	}
+	n.overrides[ch] = *override
+	return nil
+}
+
+// ChannelOptionsOverrides returns a copy of all channel option overrides.
+func (n *Container) ChannelOptionsOverrides() map[string]ChannelOptionsOverride {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	overrides := make(map[string]ChannelOptionsOverride, len(n.overrides))
+	for ch, o := range n.overrides {
+		overrides[ch] = o
+	}
+	return overrides
+}
+
+// OverrideStore keeps channel option overrides in engine, so overrides are
+// shared with nodes started later and survive node restarts. Overrides are
+// kept encoded to JSON.
+type OverrideStore interface {
+	// SetChannelOptionsOverride saves override of channel.
+	SetChannelOptionsOverride(ch string, data []byte) error
+	// DeleteChannelOptionsOverride removes override of channel.
+	DeleteChannelOptionsOverride(ch string) error
+	// ChannelOptionsOverrides returns overrides of all channels.
+	ChannelOptionsOverrides() (map[string][]byte, error)
+}
+
+// SaveChannelOptionsOverride saves override of channel to store. Nil override
+// removes channel override from store.
+func SaveChannelOptionsOverride(s OverrideStore, ch string, override *ChannelOptionsOverride) error {
+	if override == nil {
+		return s.DeleteChannelOptionsOverride(ch)
+	}
+	data, err := json.Marshal(override)
+	if err != nil {
+		return err
+	}
+	return s.SetChannelOptionsOverride(ch, data)
+}
+
+// LoadChannelOptionsOverrides sets all overrides kept in store. Overrides which
+// can't be applied with current configuration (for example when channel
+// namespace was removed) are skipped, their channels returned.
+func (n *Container) LoadChannelOptionsOverrides(s OverrideStore) ([]string, error) {
+	overrides, err := s.ChannelOptionsOverrides()
+	if err != nil {
+		return nil, err
+	}
+	var skipped []string
+	for ch, data := range overrides {
+		var override ChannelOptionsOverride
+		if err := json.Unmarshal(data, &override); err != nil {
+			skipped = append(skipped, ch)
+			continue
+		}
+		if err := n.SetChannelOptionsOverride(ch, &override); err != nil {
+			skipped = append(skipped, ch)
+		}
+	}
+	return skipped, nil
+}
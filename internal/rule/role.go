@@ -0,0 +1,28 @@
+package rule
+
+import "errors"
+
+// RolesAllowed returns true if roles contain at least one of allowed roles.
+// Empty allowed roles mean no restriction.
+func RolesAllowed(allowedRoles []string, roles []string) bool {
+	if len(allowedRoles) == 0 {
+		return true
+	}
+	for _, allowed := range allowedRoles {
+		for _, role := range roles {
+			if role == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func validateRoles(roles []string) error {
+	for _, role := range roles {
+		if role == "" {
+			return errors.New("empty role name")
+		}
+	}
+	return nil
+}
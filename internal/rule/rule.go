@@ -6,6 +6,9 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+
+	"github.com/gobwas/glob"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 )
 
 // Config ...
@@ -13,57 +16,72 @@ type Config struct {
 	// ChannelOptions embedded on top level.
 	ChannelOptions
 	// Namespaces – list of namespaces for custom channel options.
-	Namespaces []ChannelNamespace
+	Namespaces []ChannelNamespace `json:"namespaces"`
 	// RpcOptions embedded on top level.
 	RpcOptions
 	// RpcNamespaces - list of rpc namespace for custom rpc options.
-	RpcNamespaces []RpcNamespace
+	RpcNamespaces []RpcNamespace `json:"rpc_namespaces"`
 	// RpcNamespaceBoundary is a string separator which must be put after
 	// rpc namespace part in rpc method.
-	RpcNamespaceBoundary string
+	RpcNamespaceBoundary string `json:"rpc_namespace_boundary"`
 	// ChannelUserBoundary is a string separator which must be set before
 	// allowed users part in channel name.
 	// ChannelPrivatePrefix is a prefix in channel name which indicates that
 	// channel is private.
-	ChannelPrivatePrefix string
+	ChannelPrivatePrefix string `json:"channel_private_prefix"`
 	// ChannelNamespaceBoundary is a string separator which must be put after
 	// namespace part in channel name.
-	ChannelNamespaceBoundary string
+	ChannelNamespaceBoundary string `json:"channel_namespace_boundary"`
 	// ChannelUserBoundary is a string separator which must be set before
 	// allowed users part in channel name.
-	ChannelUserBoundary string
+	ChannelUserBoundary string `json:"channel_user_boundary"`
 	// ChannelUserSeparator separates allowed users in user part of channel name.
 	// So you can limit access to channel to limited set of users.
-	ChannelUserSeparator string
+	ChannelUserSeparator string `json:"channel_user_separator"`
 	// UserSubscribeToPersonal enables automatic subscribing to personal channel
 	// by user.  Only users with user ID defined will subscribe to personal
 	// channels, anonymous users are ignored.
-	UserSubscribeToPersonal bool
+	UserSubscribeToPersonal bool `json:"user_subscribe_to_personal"`
 	// UserPersonalChannelPrefix defines prefix to be added to user personal channel.
 	// This should match one of configured namespace names. By default no namespace
 	// used for personal channel.
-	UserPersonalChannelNamespace string
+	UserPersonalChannelNamespace string `json:"user_personal_channel_namespace"`
 	// UserPersonalSingleConnection turns on a mode in which Centrifugo will try to
 	// maintain only a single connection for each user in the same moment. As soon as
 	// user establishes a connection other connections from the same user will be closed
 	// with connection limit reason.
 	// This feature works with a help of presence information inside personal channel.
 	// So presence should be turned on in personal channel.
-	UserPersonalSingleConnection bool
+	UserPersonalSingleConnection bool `json:"user_personal_single_connection"`
+	// UserPersonalInbox turns on keeping publications sent to personal channel
+	// while user is offline in user inbox. Inbox delivered to user on next
+	// connect. User considered offline when there is no presence in personal
+	// channel, so presence should be turned on in personal channel.
+	UserPersonalInbox bool `json:"user_personal_inbox"`
+	// UserPersonalPush turns on sending publications to personal channel as
+	// push notifications to registered devices of user while user is offline.
+	// Like UserPersonalInbox this requires presence in personal channel.
+	UserPersonalPush bool `json:"user_personal_push"`
 	// ClientInsecure turns on insecure mode for client connections - when it's
 	// turned on then no authentication required at all when connecting to Centrifugo,
 	// anonymous access and publish allowed for all channels, no connection expire
 	// performed. This can be suitable for demonstration or personal usage.
-	ClientInsecure bool
+	ClientInsecure bool `json:"client_insecure"`
 	// ClientAnonymous when set to true, allows connect requests without specifying
 	// a token or setting Credentials in authentication middleware. The resulting
 	// user will have empty string for user ID, meaning user can only subscribe
 	// to anonymous channels.
-	ClientAnonymous bool
+	ClientAnonymous bool `json:"client_anonymous"`
+	// ClientAnonymousRestricted when set to true, allows connect requests without
+	// token or Credentials like ClientAnonymous does but connection gets a generated
+	// ephemeral user ID and is restricted to read-only access to channels from
+	// namespaces with Public option on: it can subscribe, ask history and presence
+	// there, but can't publish or call RPC.
+	ClientAnonymousRestricted bool `json:"client_anonymous_restricted"`
 	// ClientConcurrency when set allows processing client commands concurrently
 	// with provided concurrency level. By default commands processed sequentially
 	// one after another.
-	ClientConcurrency int
+	ClientConcurrency int `json:"client_concurrency"`
 }
 
 // DefaultConfig has default config options.
@@ -93,12 +111,57 @@ func ValidateNamespace(ns ChannelNamespace) error {
 	if !match {
 		return fmt.Errorf("invalid namespace name – %s (must match %s regular expression)", name, namePattern)
 	}
+	if ns.ChannelPattern != "" && ns.ChannelRegex != "" {
+		return errors.New("channel_pattern and channel_regex can't be used together")
+	}
+	if _, err := compileNamespaceMatcher(ns); err != nil {
+		return err
+	}
 	if err := ValidateChannelOptions(ns.ChannelOptions); err != nil {
 		return err
 	}
 	return nil
 }
 
+// compileNamespaceMatcher returns function to match channels against namespace
+// channel pattern or regex. Nil returned if namespace has no pattern.
+func compileNamespaceMatcher(ns ChannelNamespace) (func(string) bool, error) {
+	if ns.ChannelPattern != "" {
+		g, err := glob.Compile(ns.ChannelPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid channel_pattern %s: %w", ns.ChannelPattern, err)
+		}
+		return g.Match, nil
+	}
+	if ns.ChannelRegex != "" {
+		re, err := regexp.Compile(ns.ChannelRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid channel_regex %s: %w", ns.ChannelRegex, err)
+		}
+		return re.MatchString, nil
+	}
+	return nil, nil
+}
+
+// namespaceMatcher resolves channels to namespace by pattern.
+type namespaceMatcher struct {
+	name  string
+	match func(string) bool
+}
+
+// compileNamespaceMatchers for namespaces with patterns keeping config order.
+func compileNamespaceMatchers(c Config) []namespaceMatcher {
+	var matchers []namespaceMatcher
+	for _, ns := range c.Namespaces {
+		match, err := compileNamespaceMatcher(ns)
+		if err != nil || match == nil {
+			continue
+		}
+		matchers = append(matchers, namespaceMatcher{name: ns.Name, match: match})
+	}
+	return matchers
+}
+
 func ValidateRpcNamespace(ns RpcNamespace) error {
 	name := ns.Name
 	match := nameRe.MatchString(name)
@@ -118,6 +181,34 @@ func ValidateChannelOptions(c ChannelOptions) error {
 	if c.Recover && (c.HistorySize == 0 || c.HistoryTTL == 0) {
 		return errors.New("both history size and history ttl required for recovery")
 	}
+	if c.HistoryCompaction && (c.HistorySize == 0 || c.HistoryTTL == 0) {
+		return errors.New("both history size and history ttl required for history compaction")
+	}
+	if c.PresenceState && !c.Presence {
+		return errors.New("presence required for presence state")
+	}
+	if c.PresenceStateBroadcast && !c.PresenceState {
+		return errors.New("presence state required for presence state broadcast")
+	}
+	if c.PresenceMemberKeys && !c.Presence {
+		return errors.New("presence required for presence member keys")
+	}
+	if c.ChannelGroup && (c.Recover || c.Position) {
+		return errors.New("recovery and positioning not supported for channel groups")
+	}
+	for _, roles := range [][]string{c.SubscribeRoles, c.PublishRoles, c.HistoryRoles, c.PresenceRoles} {
+		if err := validateRoles(roles); err != nil {
+			return err
+		}
+	}
+	if c.PublicationSizeLimit < 0 {
+		return errors.New("publication size limit can't be negative")
+	}
+	if c.PublicationSchema != "" {
+		if _, err := compilePublicationSchema(c.PublicationSchema); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -134,15 +225,33 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	if c.ClientAnonymousRestricted && (c.ClientAnonymous || c.ClientInsecure) {
+		return errors.New("client_anonymous_restricted can't be used together with client_anonymous or client_insecure")
+	}
+
 	usePersonalChannel := c.UserSubscribeToPersonal
 	personalChannelNamespace := c.UserPersonalChannelNamespace
 	personalSingleConnection := c.UserPersonalSingleConnection
+	personalInbox := c.UserPersonalInbox
+	personalPush := c.UserPersonalPush
+	if personalInbox && !usePersonalChannel {
+		return errors.New("user_personal_inbox requires user_subscribe_to_personal")
+	}
+	if personalPush && !usePersonalChannel {
+		return errors.New("user_personal_push requires user_subscribe_to_personal")
+	}
 	var validPersonalChannelNamespace bool
 	if !usePersonalChannel || personalChannelNamespace == "" {
 		validPersonalChannelNamespace = true
 		if personalSingleConnection && !c.Presence {
 			return fmt.Errorf("presence must be enabled on top level to maintain single connection")
 		}
+		if personalInbox && !c.Presence {
+			return fmt.Errorf("presence must be enabled on top level to use personal inbox")
+		}
+		if personalPush && !c.Presence {
+			return fmt.Errorf("presence must be enabled on top level to use personal push")
+		}
 	}
 
 	nss := make([]string, 0, len(c.Namespaces))
@@ -158,6 +267,12 @@ func (c *Config) Validate() error {
 			if personalSingleConnection && !n.Presence {
 				return fmt.Errorf("presence must be enabled for namespace %s to maintain single connection", n.Name)
 			}
+			if personalInbox && !n.Presence {
+				return fmt.Errorf("presence must be enabled for namespace %s to use personal inbox", n.Name)
+			}
+			if personalPush && !n.Presence {
+				return fmt.Errorf("presence must be enabled for namespace %s to use personal push", n.Name)
+			}
 		}
 		nss = append(nss, n.Name)
 	}
@@ -182,14 +297,24 @@ func (c *Config) Validate() error {
 
 // Container ...
 type Container struct {
-	mu     sync.RWMutex
-	config Config
+	mu        sync.RWMutex
+	config    Config
+	overrides map[string]ChannelOptionsOverride
+	// matchers resolve namespaces configured with channel patterns.
+	matchers []namespaceMatcher
+
+	schemaMu sync.RWMutex
+	// schemas caches compiled publication schemas by schema source.
+	schemas map[string]*jsonschema.Schema
 }
 
 // NewContainer ...
 func NewContainer(config Config) *Container {
 	return &Container{
-		config: config,
+		config:    config,
+		overrides: map[string]ChannelOptionsOverride{},
+		matchers:  compileNamespaceMatchers(config),
+		schemas:   map[string]*jsonschema.Schema{},
 	}
 }
 
@@ -201,12 +326,29 @@ func (n *Container) Reload(c Config) error {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 	n.config = c
+	n.matchers = compileNamespaceMatchers(c)
+	n.schemaMu.Lock()
+	n.schemas = map[string]*jsonschema.Schema{}
+	n.schemaMu.Unlock()
 	return nil
 }
 
+// ChannelNamespace returns namespace name of channel, empty string returned
+// for channels of top-level namespace.
+func (n *Container) ChannelNamespace(ch string) string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.namespaceName(ch)
+}
+
 // namespaceName returns namespace name from channel if exists.
 func (n *Container) namespaceName(ch string) string {
 	cTrim := strings.TrimPrefix(ch, n.config.ChannelPrivatePrefix)
+	for _, m := range n.matchers {
+		if m.match(cTrim) {
+			return m.name
+		}
+	}
 	if n.config.ChannelNamespaceBoundary != "" && strings.Contains(cTrim, n.config.ChannelNamespaceBoundary) {
 		parts := strings.SplitN(cTrim, n.config.ChannelNamespaceBoundary, 2)
 		return parts[0]
@@ -218,7 +360,14 @@ func (n *Container) namespaceName(ch string) string {
 func (n *Container) ChannelOptions(ch string) (ChannelOptions, bool, error) {
 	n.mu.RLock()
 	defer n.mu.RUnlock()
-	return n.config.channelOpts(n.namespaceName(ch))
+	opts, found, err := n.config.channelOpts(n.namespaceName(ch))
+	if err != nil || !found {
+		return opts, found, err
+	}
+	if override, ok := n.overrides[ch]; ok {
+		override.apply(&opts)
+	}
+	return opts, true, nil
 }
 
 // channelOpts searches for channel options for specified namespace key.
@@ -243,6 +392,16 @@ func (n *Container) PersonalChannel(user string) string {
 	return config.UserPersonalChannelNamespace + config.ChannelNamespaceBoundary + config.ChannelUserBoundary + user
 }
 
+// PersonalChannelUser returns user of personal channel, false returned if
+// channel is not a personal channel.
+func (n *Container) PersonalChannelUser(ch string) (string, bool) {
+	prefix := n.PersonalChannel("")
+	if len(ch) <= len(prefix) || !strings.HasPrefix(ch, prefix) {
+		return "", false
+	}
+	return ch[len(prefix):], true
+}
+
 // Config returns a copy of node Config.
 func (n *Container) Config() Config {
 	n.mu.RLock()
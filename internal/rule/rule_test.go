@@ -2,6 +2,9 @@ package rule
 
 import (
 	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
 
 	"github.com/stretchr/testify/require"
 )
@@ -64,6 +67,26 @@ func TestConfigValidatePersonalSingleConnectionMissingPresence(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestConfigValidatePersonalInbox(t *testing.T) {
+	c := DefaultConfig
+	c.UserPersonalInbox = true
+	require.Error(t, c.Validate())
+	c.UserSubscribeToPersonal = true
+	require.Error(t, c.Validate())
+	c.Presence = true
+	require.NoError(t, c.Validate())
+}
+
+func TestConfigValidatePersonalPush(t *testing.T) {
+	c := DefaultConfig
+	c.UserPersonalPush = true
+	require.Error(t, c.Validate())
+	c.UserSubscribeToPersonal = true
+	require.Error(t, c.Validate())
+	c.Presence = true
+	require.NoError(t, c.Validate())
+}
+
 func TestConfigValidatePersonalSingleConnectionOK(t *testing.T) {
 	c := DefaultConfig
 	c.Namespaces = []ChannelNamespace{}
@@ -121,6 +144,43 @@ func TestConfigValidateMalformedReceiverInNamespace(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestConfigValidateHistoryCompaction(t *testing.T) {
+	c := DefaultConfig
+	c.HistoryCompaction = true
+	require.Error(t, c.Validate())
+	c.HistorySize = 10
+	c.HistoryTTL = tools.Duration(time.Minute)
+	require.NoError(t, c.Validate())
+}
+
+func TestConfigValidatePresenceState(t *testing.T) {
+	c := DefaultConfig
+	c.PresenceState = true
+	require.Error(t, c.Validate())
+	c.Presence = true
+	require.NoError(t, c.Validate())
+	c.PresenceStateBroadcast = true
+	require.NoError(t, c.Validate())
+	c.PresenceState = false
+	require.Error(t, c.Validate())
+}
+
+func TestConfigValidatePresenceMemberKeys(t *testing.T) {
+	c := DefaultConfig
+	c.PresenceMemberKeys = true
+	require.Error(t, c.Validate())
+	c.Presence = true
+	require.NoError(t, c.Validate())
+}
+
+func TestConfigValidateChannelGroup(t *testing.T) {
+	c := DefaultConfig
+	c.ChannelGroup = true
+	require.NoError(t, c.Validate())
+	c.Position = true
+	require.Error(t, c.Validate())
+}
+
 func TestUserAllowed(t *testing.T) {
 	rules := NewContainer(DefaultConfig)
 	require.True(t, rules.UserAllowed("channel#1", "1"))
@@ -138,3 +198,185 @@ func TestIsUserLimited(t *testing.T) {
 	rules.config.ChannelUserBoundary = ""
 	require.False(t, rules.IsUserLimited("#12"))
 }
+
+func TestPersonalChannelUser(t *testing.T) {
+	rules := NewContainer(DefaultConfig)
+	user, ok := rules.PersonalChannelUser("#12")
+	require.True(t, ok)
+	require.Equal(t, "12", user)
+	_, ok = rules.PersonalChannelUser("#")
+	require.False(t, ok)
+	_, ok = rules.PersonalChannelUser("test#12")
+	require.False(t, ok)
+
+	rules.config.UserPersonalChannelNamespace = "user"
+	user, ok = rules.PersonalChannelUser("user:#12")
+	require.True(t, ok)
+	require.Equal(t, "12", user)
+	_, ok = rules.PersonalChannelUser("#12")
+	require.False(t, ok)
+}
+
+func TestChannelOptionsOverride(t *testing.T) {
+	c := DefaultConfig
+	c.Namespaces = []ChannelNamespace{
+		{
+			Name:           "ns",
+			ChannelOptions: ChannelOptions{JoinLeave: true},
+		},
+	}
+	container := NewContainer(c)
+
+	presence := true
+	err := container.SetChannelOptionsOverride("ns:hot", &ChannelOptionsOverride{Presence: &presence})
+	require.NoError(t, err)
+
+	opts, found, err := container.ChannelOptions("ns:hot")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, opts.Presence)
+	require.True(t, opts.JoinLeave)
+
+	opts, _, _ = container.ChannelOptions("ns:other")
+	require.False(t, opts.Presence)
+
+	historySize := 10
+	err = container.SetChannelOptionsOverride("ns:hot", &ChannelOptionsOverride{HistorySize: &historySize})
+	require.Error(t, err)
+
+	err = container.SetChannelOptionsOverride("unknown:hot", &ChannelOptionsOverride{Presence: &presence})
+	require.Equal(t, ErrUnknownNamespace, err)
+
+	err = container.SetChannelOptionsOverride("ns:hot", nil)
+	require.NoError(t, err)
+	opts, _, _ = container.ChannelOptions("ns:hot")
+	require.False(t, opts.Presence)
+	require.Len(t, container.ChannelOptionsOverrides(), 0)
+}
+
+type testOverrideStore map[string][]byte
+
+func (s testOverrideStore) SetChannelOptionsOverride(ch string, data []byte) error {
+	s[ch] = data
+	return nil
+}
+
+func (s testOverrideStore) DeleteChannelOptionsOverride(ch string) error {
+	delete(s, ch)
+	return nil
+}
+
+func (s testOverrideStore) ChannelOptionsOverrides() (map[string][]byte, error) {
+	return s, nil
+}
+
+func TestLoadChannelOptionsOverrides(t *testing.T) {
+	c := DefaultConfig
+	c.Namespaces = []ChannelNamespace{{Name: "ns"}}
+
+	store := testOverrideStore{}
+	presence := true
+	require.NoError(t, SaveChannelOptionsOverride(store, "ns:hot", &ChannelOptionsOverride{Presence: &presence}))
+	require.NoError(t, SaveChannelOptionsOverride(store, "ns:cold", &ChannelOptionsOverride{Presence: &presence}))
+	require.NoError(t, SaveChannelOptionsOverride(store, "ns:cold", nil))
+	require.NoError(t, SaveChannelOptionsOverride(store, "unknown:hot", &ChannelOptionsOverride{Presence: &presence}))
+	require.Len(t, store, 2)
+
+	container := NewContainer(c)
+	skipped, err := container.LoadChannelOptionsOverrides(store)
+	require.NoError(t, err)
+	require.Equal(t, []string{"unknown:hot"}, skipped)
+	opts, _, _ := container.ChannelOptions("ns:hot")
+	require.True(t, opts.Presence)
+	opts, _, _ = container.ChannelOptions("ns:cold")
+	require.False(t, opts.Presence)
+}
+
+func TestConfigValidateNamespaceChannelPattern(t *testing.T) {
+	c := DefaultConfig
+	c.Namespaces = []ChannelNamespace{{Name: "rooms", ChannelPattern: "room-[a"}}
+	require.Error(t, c.Validate())
+	c.Namespaces = []ChannelNamespace{{Name: "rooms", ChannelRegex: "room-(\\d+"}}
+	require.Error(t, c.Validate())
+	c.Namespaces = []ChannelNamespace{{Name: "rooms", ChannelPattern: "room-*", ChannelRegex: "^room-"}}
+	require.Error(t, c.Validate())
+	c.Namespaces = []ChannelNamespace{{Name: "rooms", ChannelPattern: "room-*"}}
+	require.NoError(t, c.Validate())
+}
+
+func TestChannelNamespacePattern(t *testing.T) {
+	c := DefaultConfig
+	c.Namespaces = []ChannelNamespace{
+		{Name: "rooms", ChannelPattern: "room-*", ChannelOptions: ChannelOptions{Presence: true}},
+		{Name: "orders", ChannelRegex: "^orders\\.\\d+$"},
+		{Name: "chat"},
+	}
+	container := NewContainer(c)
+
+	require.Equal(t, "rooms", container.ChannelNamespace("room-1"))
+	require.Equal(t, "rooms", container.ChannelNamespace("$room-1"))
+	require.Equal(t, "rooms", container.ChannelNamespace("room-chat:1"))
+	require.Equal(t, "orders", container.ChannelNamespace("orders.42"))
+	require.Equal(t, "", container.ChannelNamespace("orders.x"))
+	require.Equal(t, "chat", container.ChannelNamespace("chat:index"))
+
+	opts, found, err := container.ChannelOptions("room-1")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, opts.Presence)
+
+	c.Namespaces = []ChannelNamespace{{Name: "rooms"}}
+	require.NoError(t, container.Reload(c))
+	require.Equal(t, "", container.ChannelNamespace("room-1"))
+}
+
+func TestConfigValidateInvalidPublicationSchema(t *testing.T) {
+	c := DefaultConfig
+	c.Namespaces = []ChannelNamespace{
+		{
+			Name:           "name",
+			ChannelOptions: ChannelOptions{PublicationSchema: `{"type": 1}`},
+		},
+	}
+	err := c.Validate()
+	require.Error(t, err)
+}
+
+func TestConfigValidateNegativePublicationSizeLimit(t *testing.T) {
+	c := DefaultConfig
+	c.PublicationSizeLimit = -1
+	require.Error(t, c.Validate())
+}
+
+func TestValidatePublicationData(t *testing.T) {
+	c := NewContainer(DefaultConfig)
+	opts := ChannelOptions{PublicationSchema: `{"type": "object", "properties": {"text": {"type": "string"}}, "required": ["text"]}`}
+	require.NoError(t, c.ValidatePublicationData(opts, []byte(`{"text": "hello"}`)))
+	require.Error(t, c.ValidatePublicationData(opts, []byte(`{"text": 1}`)))
+	require.Error(t, c.ValidatePublicationData(opts, []byte(`{}`)))
+	require.Error(t, c.ValidatePublicationData(opts, []byte(`not json`)))
+	require.Error(t, c.ValidatePublicationData(opts, []byte(`{"text": "hello"} {}`)))
+	require.NoError(t, c.ValidatePublicationData(ChannelOptions{}, []byte(`not json`)))
+}
+
+func TestRolesAllowed(t *testing.T) {
+	require.True(t, RolesAllowed(nil, nil))
+	require.True(t, RolesAllowed(nil, []string{"admin"}))
+	require.False(t, RolesAllowed([]string{"admin"}, nil))
+	require.False(t, RolesAllowed([]string{"admin"}, []string{"reader"}))
+	require.True(t, RolesAllowed([]string{"admin", "reader"}, []string{"reader"}))
+}
+
+func TestConfigValidateEmptyRole(t *testing.T) {
+	c := DefaultConfig
+	c.PublishRoles = []string{""}
+	require.Error(t, c.Validate())
+}
+
+func TestConfigValidateAnonymousRestricted(t *testing.T) {
+	c := DefaultConfig
+	c.ClientAnonymousRestricted = true
+	require.NoError(t, c.Validate())
+	c.ClientAnonymous = true
+	require.Error(t, c.Validate())
+}
@@ -0,0 +1,62 @@
+package rule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// publicationSchemaURL is a resource name used to compile publication schemas,
+// it only appears in validation error messages.
+const publicationSchemaURL = "publication_schema.json"
+
+func compilePublicationSchema(source string) (*jsonschema.Schema, error) {
+	schema, err := jsonschema.CompileString(publicationSchemaURL, source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid publication schema: %w", err)
+	}
+	return schema, nil
+}
+
+// ValidatePublicationData checks that data matches publication schema set in
+// channel options. Nil error returned if channel options have no schema.
+// Compiled schemas are cached inside Container.
+func (n *Container) ValidatePublicationData(opts ChannelOptions, data []byte) error {
+	if opts.PublicationSchema == "" {
+		return nil
+	}
+	schema, err := n.publicationSchema(opts.PublicationSchema)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// Schema validator expects numbers as json.Number to validate them precisely.
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return fmt.Errorf("publication data is not a valid JSON: %w", err)
+	}
+	if decoder.More() {
+		return fmt.Errorf("publication data is not a valid JSON: unexpected data after top-level value")
+	}
+	return schema.Validate(v)
+}
+
+func (n *Container) publicationSchema(source string) (*jsonschema.Schema, error) {
+	n.schemaMu.RLock()
+	schema, ok := n.schemas[source]
+	n.schemaMu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+	schema, err := compilePublicationSchema(source)
+	if err != nil {
+		return nil, err
+	}
+	n.schemaMu.Lock()
+	n.schemas[source] = schema
+	n.schemaMu.Unlock()
+	return schema, nil
+}
@@ -0,0 +1,28 @@
+package sdkinfo
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metricsNamespace = "centrifugo"
+
+var (
+	connectionsCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "client",
+		Name:      "sdk_connections",
+		Help:      "Number of successful connections by SDK name and major.minor version.",
+	}, []string{"sdk", "version"})
+
+	featuresCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "client",
+		Name:      "sdk_features",
+		Help:      "Number of successful connections by negotiated SDK feature.",
+	}, []string{"feature"})
+)
+
+func init() {
+	prometheus.MustRegister(connectionsCount)
+	prometheus.MustRegister(featuresCount)
+}
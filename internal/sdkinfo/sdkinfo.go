@@ -0,0 +1,195 @@
+// Package sdkinfo implements exchange of client SDK capabilities on connect.
+//
+// SDK sends its name and version in connect command fields and a list of
+// supported features in connect data under DataKey:
+//
+//	{"sdk": {"features": ["delta", "recovery"]}}
+//
+// Server answers with features it supports too under the same key of connect
+// reply data, so both sides know which features can be used over connection.
+// SDKs which don't send features get no answer. Info is kept in connection
+// context and counted in metrics to track SDK adoption.
+package sdkinfo
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// DataKey is a key of SDK capabilities in connect data and connect reply data.
+const DataKey = "sdk"
+
+// Features known by server.
+const (
+	// FeatureDelta means SDK can apply delta publications.
+	FeatureDelta = "delta"
+	// FeatureRecovery means SDK can recover publications missed while
+	// reconnecting.
+	FeatureRecovery = "recovery"
+	// FeatureBinary means SDK can use Protobuf protocol.
+	FeatureBinary = "binary"
+	// FeatureSubscriptionCaps means SDK expects capabilities enabled for
+	// every subscription in subscribe data, see subcaps package.
+	FeatureSubscriptionCaps = "sub_caps"
+)
+
+// SupportedFeatures is a list of features supported by server.
+var SupportedFeatures = []string{FeatureDelta, FeatureRecovery, FeatureBinary, FeatureSubscriptionCaps}
+
+// maxFeatures is a max number of features kept from connect data.
+const maxFeatures = 32
+
+// Info about SDK of connection.
+type Info struct {
+	// Name of SDK, e.g. centrifuge-js.
+	Name string
+	// Version of SDK.
+	Version string
+	// Features supported by both SDK and server.
+	Features []string
+	// Negotiated is true if SDK sent features in connect data.
+	Negotiated bool
+}
+
+// has reports whether feature already in Info features.
+func (i Info) has(feature string) bool {
+	for _, f := range i.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Supports reports whether feature negotiated with SDK.
+func (i Info) Supports(feature string) bool {
+	return i.Negotiated && i.has(feature)
+}
+
+type capabilities struct {
+	Features []string `json:"features"`
+}
+
+// FromConnect extracts SDK Info from connect command fields and data. Data
+// which is not a JSON object or has no DataKey results in not negotiated Info.
+func FromConnect(name string, version string, data []byte) Info {
+	info := Info{Name: name, Version: version}
+	if len(data) == 0 || data[0] != '{' {
+		return info
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return info
+	}
+	raw, ok := fields[DataKey]
+	if !ok {
+		return info
+	}
+	var c capabilities
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return info
+	}
+	info.Negotiated = true
+	for i, f := range c.Features {
+		if i >= maxFeatures {
+			break
+		}
+		if isSupported(f) && !info.has(f) {
+			info.Features = append(info.Features, f)
+		}
+	}
+	return info
+}
+
+func isSupported(feature string) bool {
+	for _, f := range SupportedFeatures {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// AddToData adds features supported by server to connect reply data under
+// DataKey. Data which is not empty and not JSON object returned as is,
+// existing key not overwritten. Passed data is never modified and never
+// shared with result.
+func AddToData(data []byte) []byte {
+	fields := map[string]json.RawMessage{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+			return copyData(data)
+		}
+	}
+	if _, ok := fields[DataKey]; ok {
+		return copyData(data)
+	}
+	fields[DataKey], _ = json.Marshal(capabilities{Features: SupportedFeatures})
+	result, err := json.Marshal(fields)
+	if err != nil {
+		return copyData(data)
+	}
+	return result
+}
+
+func copyData(data []byte) []byte {
+	if data == nil {
+		return nil
+	}
+	return append([]byte{}, data...)
+}
+
+type infoContextKey struct{}
+
+// SetToContext puts SDK Info to context.
+func SetToContext(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, infoContextKey{}, info)
+}
+
+// FromContext returns SDK Info from context.
+func FromContext(ctx context.Context) (Info, bool) {
+	info, ok := ctx.Value(infoContextKey{}).(Info)
+	return info, ok
+}
+
+// knownNames is a list of official SDK names used as metric label values,
+// other names reported as "other" to keep metric cardinality bounded.
+var knownNames = map[string]struct{}{
+	"centrifuge-js":      {},
+	"centrifuge-go":      {},
+	"centrifuge-swift":   {},
+	"centrifuge-java":    {},
+	"centrifuge-android": {},
+	"centrifuge-dart":    {},
+	"centrifuge-python":  {},
+	"centrifuge-mobile":  {},
+}
+
+var versionRe = regexp.MustCompile(`^v?(\d{1,4})\.(\d{1,4})`)
+
+// metricLabels returns SDK name and version normalized for metric labels.
+// Version reduced to major.minor.
+func metricLabels(info Info) (string, string) {
+	name := strings.ToLower(info.Name)
+	if name == "" {
+		name = "unknown"
+	} else if _, ok := knownNames[name]; !ok {
+		name = "other"
+	}
+	version := "unknown"
+	if m := versionRe.FindStringSubmatch(info.Version); m != nil {
+		version = m[1] + "." + m[2]
+	}
+	return name, version
+}
+
+// Observe counts connection with SDK Info in metrics.
+func Observe(info Info) {
+	name, version := metricLabels(info)
+	connectionsCount.WithLabelValues(name, version).Inc()
+	for _, f := range info.Features {
+		featuresCount.WithLabelValues(f).Inc()
+	}
+}
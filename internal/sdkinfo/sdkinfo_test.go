@@ -0,0 +1,65 @@
+package sdkinfo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromConnect(t *testing.T) {
+	info := FromConnect("centrifuge-js", "2.8.1", nil)
+	require.False(t, info.Negotiated)
+	require.Equal(t, "centrifuge-js", info.Name)
+
+	info = FromConnect("centrifuge-js", "2.8.1", []byte(`{"sdk":{"features":["recovery","unknown","delta","delta"]}}`))
+	require.True(t, info.Negotiated)
+	require.Equal(t, []string{FeatureRecovery, FeatureDelta}, info.Features)
+	require.Contains(t, info.Features, FeatureDelta)
+	require.NotContains(t, info.Features, FeatureBinary)
+	require.True(t, info.Supports(FeatureDelta))
+	require.False(t, info.Supports(FeatureBinary))
+
+	require.False(t, FromConnect("", "", []byte(`{"user":"1"}`)).Negotiated)
+	require.False(t, FromConnect("", "", []byte(`{"sdk":"delta"}`)).Negotiated)
+	require.False(t, FromConnect("", "", []byte(`"sdk"`)).Negotiated)
+}
+
+func TestAddToData(t *testing.T) {
+	require.JSONEq(t, `{"sdk":{"features":["delta","recovery","binary","sub_caps"]}}`, string(AddToData(nil)))
+	require.JSONEq(t, `{"affinity":"node1","sdk":{"features":["delta","recovery","binary","sub_caps"]}}`, string(AddToData([]byte(`{"affinity":"node1"}`))))
+	require.Equal(t, `{"sdk":1}`, string(AddToData([]byte(`{"sdk":1}`))))
+	require.Equal(t, `[1]`, string(AddToData([]byte(`[1]`))))
+}
+
+func TestAddToDataDoesNotShareInput(t *testing.T) {
+	for _, in := range []string{`{"affinity":"node1"}`, `{"sdk":1}`, `[1]`} {
+		data := []byte(in)
+		result := AddToData(data)
+		for i := range result {
+			result[i] = 'x'
+		}
+		require.Equal(t, in, string(data))
+	}
+}
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	require.False(t, ok)
+	ctx := SetToContext(context.Background(), Info{Name: "centrifuge-go"})
+	info, ok := FromContext(ctx)
+	require.True(t, ok)
+	require.Equal(t, "centrifuge-go", info.Name)
+}
+
+func TestMetricLabels(t *testing.T) {
+	name, version := metricLabels(Info{Name: "centrifuge-js", Version: "2.8.1"})
+	require.Equal(t, "centrifuge-js", name)
+	require.Equal(t, "2.8", version)
+	name, version = metricLabels(Info{Name: "my-sdk", Version: "v1.0.0-beta"})
+	require.Equal(t, "other", name)
+	require.Equal(t, "1.0", version)
+	name, version = metricLabels(Info{})
+	require.Equal(t, "unknown", name)
+	require.Equal(t, "unknown", version)
+}
@@ -0,0 +1,177 @@
+// Package subcaps implements negotiation of subscription capabilities.
+//
+// Client requests capabilities of subscription to channel with RPC before
+// subscribing, application can also put them into caps claim of subscription
+// token. Capabilities only narrow options enabled for channel, so client
+// can't get capability disabled in channel options. Server answers with
+// capabilities actually enabled in subscribe data:
+//
+//	{"caps": ["position", "recover"], "data": {"custom": true}}
+//
+// Subscribe data of application is wrapped into data key (JSON) or b64data
+// key (binary), so it can't be confused with capabilities.
+package subcaps
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// Subscription capabilities.
+const (
+	Position  = "position"
+	Recover   = "recover"
+	Presence  = "presence"
+	JoinLeave = "join_leave"
+)
+
+// ErrRecoverWithoutPosition returned when recover requested without
+// position. Recovery tracks stream position of subscription, so it can't be
+// enabled without position.
+var ErrRecoverWithoutPosition = errors.New("recover capability requires position capability")
+
+// parse returns set of requested capabilities.
+func parse(caps []string) (map[string]bool, error) {
+	requested := make(map[string]bool, len(caps))
+	for _, c := range caps {
+		switch c {
+		case Position, Recover, Presence, JoinLeave:
+			requested[c] = true
+		default:
+			return nil, fmt.Errorf("unknown subscription capability: %s", c)
+		}
+	}
+	if requested[Recover] && !requested[Position] {
+		return nil, ErrRecoverWithoutPosition
+	}
+	return requested, nil
+}
+
+// Validate checks requested capabilities.
+func Validate(caps []string) error {
+	_, err := parse(caps)
+	return err
+}
+
+// Narrow turns off subscription options which were not requested in caps.
+func Narrow(opts *centrifuge.SubscribeOptions, caps []string) error {
+	requested, err := parse(caps)
+	if err != nil {
+		return err
+	}
+	opts.Position = opts.Position && requested[Position]
+	opts.Recover = opts.Recover && requested[Recover]
+	opts.Presence = opts.Presence && requested[Presence]
+	opts.JoinLeave = opts.JoinLeave && requested[JoinLeave]
+	return nil
+}
+
+// Enabled returns capabilities enabled in subscription options. Centrifuge
+// tracks stream position of subscription with recovery, so position is
+// reported enabled for recoverable subscription even if Position option
+// not set.
+func Enabled(opts centrifuge.SubscribeOptions) []string {
+	enabled := make([]string, 0, 4)
+	if opts.Position || opts.Recover {
+		enabled = append(enabled, Position)
+	}
+	if opts.Recover {
+		enabled = append(enabled, Recover)
+	}
+	if opts.Presence {
+		enabled = append(enabled, Presence)
+	}
+	if opts.JoinLeave {
+		enabled = append(enabled, JoinLeave)
+	}
+	return enabled
+}
+
+// envelope is sent to client in subscribe data to describe capabilities
+// enabled for subscription.
+type envelope struct {
+	Caps       []string        `json:"caps"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	Base64Data string          `json:"b64data,omitempty"`
+}
+
+// Wrap replaces subscribe data with envelope containing enabled capabilities
+// and original data: JSON data as is, binary data encoded to base64.
+func Wrap(opts *centrifuge.SubscribeOptions) error {
+	e := envelope{Caps: Enabled(*opts)}
+	if len(opts.Data) > 0 {
+		if json.Valid(opts.Data) {
+			e.Data = opts.Data
+		} else {
+			e.Base64Data = base64.StdEncoding.EncodeToString(opts.Data)
+		}
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	opts.Data = data
+	return nil
+}
+
+// maxChannelsPerClient is a max number of channels client can request
+// capabilities for.
+const maxChannelsPerClient = 128
+
+// Requests keeps capabilities requested by clients until client disconnects.
+type Requests struct {
+	mu      sync.RWMutex
+	clients map[string]map[string][]string
+}
+
+// NewRequests creates Requests.
+func NewRequests() *Requests {
+	return &Requests{clients: make(map[string]map[string][]string)}
+}
+
+// Set saves capabilities requested by client for channel, nil caps remove
+// request. False returned if client requested capabilities for too many
+// channels.
+func (r *Requests) Set(client string, channel string, caps []string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	channels, ok := r.clients[client]
+	if caps == nil {
+		if ok {
+			delete(channels, channel)
+			if len(channels) == 0 {
+				delete(r.clients, client)
+			}
+		}
+		return true
+	}
+	if !ok {
+		channels = make(map[string][]string)
+		r.clients[client] = channels
+	}
+	if _, ok := channels[channel]; !ok && len(channels) >= maxChannelsPerClient {
+		return false
+	}
+	channels[channel] = append([]string{}, caps...)
+	return true
+}
+
+// Get returns capabilities requested by client for channel.
+func (r *Requests) Get(client string, channel string) ([]string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	caps, ok := r.clients[client][channel]
+	return caps, ok
+}
+
+// Remove requests of client.
+func (r *Requests) Remove(client string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, client)
+}
@@ -0,0 +1,78 @@
+package subcaps
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNarrow(t *testing.T) {
+	opts := centrifuge.SubscribeOptions{Position: true, Presence: true, JoinLeave: true}
+	require.NoError(t, Narrow(&opts, []string{Presence, Recover, Position}))
+	require.True(t, opts.Position)
+	require.True(t, opts.Presence)
+	require.False(t, opts.JoinLeave)
+	// Recover not enabled in options so can't be requested.
+	require.False(t, opts.Recover)
+
+	opts = centrifuge.SubscribeOptions{Position: true, Recover: true}
+	require.NoError(t, Narrow(&opts, []string{}))
+	require.Equal(t, centrifuge.SubscribeOptions{}, opts)
+}
+
+func TestNarrowInvalid(t *testing.T) {
+	opts := centrifuge.SubscribeOptions{Position: true, Recover: true}
+	require.ErrorIs(t, Narrow(&opts, []string{Recover}), ErrRecoverWithoutPosition)
+	require.True(t, opts.Recover)
+	require.Error(t, Narrow(&opts, []string{"unknown"}))
+}
+
+func TestEnabled(t *testing.T) {
+	require.Equal(t, []string{}, Enabled(centrifuge.SubscribeOptions{}))
+	// Recovery implies position tracking.
+	require.Equal(t, []string{Position, Recover}, Enabled(centrifuge.SubscribeOptions{Recover: true}))
+	require.Equal(t, []string{Position, Presence, JoinLeave}, Enabled(centrifuge.SubscribeOptions{
+		Position: true, Presence: true, JoinLeave: true,
+	}))
+}
+
+func TestWrap(t *testing.T) {
+	opts := centrifuge.SubscribeOptions{Presence: true}
+	require.NoError(t, Wrap(&opts))
+	require.JSONEq(t, `{"caps":["presence"]}`, string(opts.Data))
+
+	// App data with caps key does not collide with capabilities.
+	opts = centrifuge.SubscribeOptions{Data: []byte(`{"caps":["presence"]}`)}
+	require.NoError(t, Wrap(&opts))
+	require.JSONEq(t, `{"caps":[],"data":{"caps":["presence"]}}`, string(opts.Data))
+
+	opts = centrifuge.SubscribeOptions{Position: true, Data: []byte{0xff, 0x00}}
+	require.NoError(t, Wrap(&opts))
+	require.JSONEq(t, `{"caps":["position"],"b64data":"/wA="}`, string(opts.Data))
+}
+
+func TestRequests(t *testing.T) {
+	r := NewRequests()
+	caps := []string{Presence}
+	require.True(t, r.Set("client", "test", caps))
+	caps[0] = JoinLeave
+	got, ok := r.Get("client", "test")
+	require.True(t, ok)
+	require.Equal(t, []string{Presence}, got)
+
+	require.True(t, r.Set("client", "test", nil))
+	_, ok = r.Get("client", "test")
+	require.False(t, ok)
+
+	for i := 0; i < maxChannelsPerClient; i++ {
+		require.True(t, r.Set("client", strconv.Itoa(i), []string{}))
+	}
+	require.False(t, r.Set("client", "test", []string{}))
+	require.True(t, r.Set("client", "0", []string{Presence}))
+
+	r.Remove("client")
+	_, ok = r.Get("client", "0")
+	require.False(t, ok)
+}
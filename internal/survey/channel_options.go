@@ -0,0 +1,72 @@
+package survey
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/centrifugal/centrifuge"
+	"google.golang.org/protobuf/proto"
+)
+
+const opSetChannelOptions = "set_channel_options"
+
+// ChannelOptionsOverrideFromProto converts API channel options override to
+// rule.ChannelOptionsOverride. Nil returned for nil override.
+func ChannelOptionsOverrideFromProto(o *apiproto.ChannelOptionsOverride) *rule.ChannelOptionsOverride {
+	if o == nil {
+		return nil
+	}
+	override := &rule.ChannelOptionsOverride{}
+	if o.Presence != nil {
+		override.Presence = &o.Presence.Value
+	}
+	if o.JoinLeave != nil {
+		override.JoinLeave = &o.JoinLeave.Value
+	}
+	if o.HistorySize != nil {
+		historySize := int(o.HistorySize.Value)
+		override.HistorySize = &historySize
+	}
+	if o.HistoryTtl != nil {
+		historyTTL := tools.Duration(time.Duration(o.HistoryTtl.Value) * time.Second)
+		override.HistoryTTL = &historyTTL
+	}
+	if o.Recover != nil {
+		override.Recover = &o.Recover.Value
+	}
+	return override
+}
+
+// SetChannelOptions sets channel options override on all running nodes.
+func (c *Caller) SetChannelOptions(ctx context.Context, cmd *apiproto.SetChannelOptionsRequest) error {
+	req, _ := proto.Marshal(cmd)
+	results, err := c.survey(ctx, opSetChannelOptions, req)
+	if err != nil {
+		return err
+	}
+	for nodeID, result := range results {
+		if result.Code > 0 {
+			return fmt.Errorf("non-zero code from node %s: %d", nodeID, result.Code)
+		}
+	}
+	return nil
+}
+
+func (c *Caller) respondSetChannelOptionsSurvey(node *centrifuge.Node, params []byte) centrifuge.SurveyReply {
+	var req apiproto.SetChannelOptionsRequest
+	err := proto.Unmarshal(params, &req)
+	if err != nil {
+		return centrifuge.SurveyReply{Code: InvalidRequest}
+	}
+	err = c.ruleContainer.SetChannelOptionsOverride(req.Channel, ChannelOptionsOverrideFromProto(req.Override))
+	if err != nil {
+		node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error setting channel options", map[string]interface{}{"channel": req.Channel, "error": err.Error()}))
+		return centrifuge.SurveyReply{Code: InvalidRequest}
+	}
+	return centrifuge.SurveyReply{}
+}
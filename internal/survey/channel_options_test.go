@@ -0,0 +1,39 @@
+package survey
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetChannelOptions(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	caller := NewCaller(node, ruleContainer, Config{})
+
+	err := caller.SetChannelOptions(context.Background(), &apiproto.SetChannelOptionsRequest{
+		Channel: "hot",
+		Override: &apiproto.ChannelOptionsOverride{
+			HistorySize: &apiproto.Int32Value{Value: 10},
+			HistoryTtl:  &apiproto.Int32Value{Value: 60},
+		},
+	})
+	require.NoError(t, err)
+	opts, _, err := ruleContainer.ChannelOptions("hot")
+	require.NoError(t, err)
+	require.Equal(t, 10, opts.HistorySize)
+	require.Equal(t, tools.Duration(time.Minute), opts.HistoryTTL)
+
+	err = caller.SetChannelOptions(context.Background(), &apiproto.SetChannelOptionsRequest{Channel: "hot"})
+	require.NoError(t, err)
+	opts, _, err = ruleContainer.ChannelOptions("hot")
+	require.NoError(t, err)
+	require.Zero(t, opts.HistorySize)
+}
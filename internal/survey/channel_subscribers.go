@@ -0,0 +1,50 @@
+package survey
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+
+	"github.com/centrifugal/centrifuge"
+	"google.golang.org/protobuf/proto"
+)
+
+const opChannelSubscribers = "channel_subscribers"
+
+// ChannelSubscribers returns number of local channel subscribers of every running node.
+func (c *Caller) ChannelSubscribers(ctx context.Context, cmd *apiproto.ChannelSubscribersRequest) (map[string]uint32, error) {
+	req, _ := proto.Marshal(cmd)
+	results, err := c.survey(ctx, opChannelSubscribers, req)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]uint32, len(results))
+	for nodeID, result := range results {
+		if result.Code > 0 {
+			return nil, fmt.Errorf("non-zero code from node %s: %d", nodeID, result.Code)
+		}
+		var nodeSubscribers apiproto.ChannelSubscribersResult
+		err := proto.Unmarshal(result.Data, &nodeSubscribers)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshaling data from node %s: %v", nodeID, err)
+		}
+		nodes[nodeID] = nodeSubscribers.NumClients
+	}
+	return nodes, nil
+}
+
+func respondChannelSubscribersSurvey(node *centrifuge.Node, params []byte) centrifuge.SurveyReply {
+	var req apiproto.ChannelSubscribersRequest
+	err := proto.Unmarshal(params, &req)
+	if err != nil {
+		return centrifuge.SurveyReply{Code: InvalidRequest}
+	}
+	data, err := proto.Marshal(&apiproto.ChannelSubscribersResult{
+		NumClients: uint32(node.Hub().NumSubscribers(req.Channel)),
+	})
+	if err != nil {
+		return centrifuge.SurveyReply{Code: InternalError}
+	}
+	return centrifuge.SurveyReply{Data: data}
+}
@@ -0,0 +1,38 @@
+package survey
+
+import (
+	"context"
+	"testing"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelSubscribers(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+	node.OnConnecting(func(ctx context.Context, _ centrifuge.ConnectEvent) (centrifuge.ConnectReply, error) {
+		return centrifuge.ConnectReply{
+			Credentials:   &centrifuge.Credentials{UserID: "42"},
+			Subscriptions: map[string]centrifuge.SubscribeOptions{"test": {}},
+		}, nil
+	})
+	caller := NewCaller(node, rule.NewContainer(rule.DefaultConfig), Config{})
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{})
+
+	nodes, err := caller.ChannelSubscribers(context.Background(), &apiproto.ChannelSubscribersRequest{Channel: "test"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint32{node.ID(): 1}, nodes)
+
+	nodes, err = caller.ChannelSubscribers(context.Background(), &apiproto.ChannelSubscribersRequest{Channel: "other"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint32{node.ID(): 0}, nodes)
+}
@@ -0,0 +1,93 @@
+package survey
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/hll"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/gobwas/glob"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/proto"
+)
+
+const opChannelsCount = "channels_count"
+
+var channelsCountGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "centrifugo",
+	Subsystem: "cluster",
+	Name:      "num_channels_approx",
+	Help:      "Approximate number of active channels on all nodes.",
+})
+
+func init() {
+	prometheus.MustRegister(channelsCountGauge)
+}
+
+// ChannelsCount returns approximate number of active channels on all running
+// nodes. Every node responds with HyperLogLog sketch of its channels, so result
+// does not depend on how many nodes share the same channel and response size
+// does not depend on number of channels.
+func (c *Caller) ChannelsCount(ctx context.Context, cmd *apiproto.ChannelsCountRequest) (uint64, error) {
+	req, _ := proto.Marshal(cmd)
+	results, err := c.survey(ctx, opChannelsCount, req)
+	if err != nil {
+		return 0, err
+	}
+	sketch := hll.New()
+	for nodeID, result := range results {
+		if result.Code > 0 {
+			return 0, fmt.Errorf("non-zero code from node %s: %d", nodeID, result.Code)
+		}
+		nodeSketch, err := hll.FromBytes(result.Data)
+		if err != nil {
+			return 0, fmt.Errorf("error decoding sketch from node %s: %v", nodeID, err)
+		}
+		sketch.Merge(nodeSketch)
+	}
+	return sketch.Count(), nil
+}
+
+// RunChannelsCountMetric periodically updates metric of approximate number of
+// active channels until context canceled.
+func (c *Caller) RunChannelsCountMetric(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+			count, err := c.ChannelsCount(ctx, &apiproto.ChannelsCountRequest{})
+			if err != nil {
+				c.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error counting channels", map[string]interface{}{"error": err.Error()}))
+				continue
+			}
+			channelsCountGauge.Set(float64(count))
+		}
+	}
+}
+
+func respondChannelsCountSurvey(node *centrifuge.Node, params []byte) centrifuge.SurveyReply {
+	var req apiproto.ChannelsCountRequest
+	err := proto.Unmarshal(params, &req)
+	if err != nil {
+		return centrifuge.SurveyReply{Code: InvalidRequest}
+	}
+	var g glob.Glob
+	if req.Pattern != "" {
+		g, err = glob.Compile(req.Pattern)
+		if err != nil {
+			return centrifuge.SurveyReply{Code: InvalidRequest}
+		}
+	}
+	sketch := hll.New()
+	for _, ch := range node.Hub().Channels() {
+		if g != nil && !g.Match(ch) {
+			continue
+		}
+		sketch.Add(ch)
+	}
+	return centrifuge.SurveyReply{Data: sketch.Bytes()}
+}
@@ -0,0 +1,43 @@
+package survey
+
+import (
+	"context"
+	"testing"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelsCount(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+	node.OnConnecting(func(ctx context.Context, _ centrifuge.ConnectEvent) (centrifuge.ConnectReply, error) {
+		return centrifuge.ConnectReply{
+			Credentials: &centrifuge.Credentials{UserID: "42"},
+			Subscriptions: map[string]centrifuge.SubscribeOptions{
+				"news:1": {}, "news:2": {}, "chat:1": {},
+			},
+		}, nil
+	})
+	caller := NewCaller(node, rule.NewContainer(rule.DefaultConfig), Config{})
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{})
+
+	count, err := caller.ChannelsCount(context.Background(), &apiproto.ChannelsCountRequest{})
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), count)
+
+	count, err = caller.ChannelsCount(context.Background(), &apiproto.ChannelsCountRequest{Pattern: "news:*"})
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), count)
+
+	_, err = caller.ChannelsCount(context.Background(), &apiproto.ChannelsCountRequest{Pattern: "[news"})
+	require.Error(t, err)
+}
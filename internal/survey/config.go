@@ -0,0 +1,334 @@
+package survey
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/limits"
+	"github.com/centrifugal/centrifugo/v3/internal/nodeinfo"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/google/uuid"
+)
+
+// Config reload is a two-phase process. First all nodes validate new configuration,
+// only if all of them succeeded configuration is applied on every node. If some node
+// failed to apply configuration then nodes which already applied it roll back to the
+// configuration which was used before reload.
+//
+// Every reload has a version greater than versions of reloads applied in cluster
+// before. Nodes advertise version of applied configuration in node info, so node
+// which missed reload (joined cluster or restarted after it) pulls the latest
+// configuration from other nodes with config_get op.
+const (
+	opConfigValidate = "config_validate"
+	opConfigApply    = "config_apply"
+	opConfigRollback = "config_rollback"
+	opConfigGet      = "config_get"
+)
+
+const defaultRollbackTimeout = 5 * time.Second
+
+// ProxyReloader rebuilds client proxies of node from reload configuration.
+type ProxyReloader interface {
+	// ValidateProxies checks that proxies can be built from configuration.
+	ValidateProxies(config []byte, ruleConfig rule.Config) error
+	// ReloadProxies rebuilds proxies from configuration and returns function
+	// which restores proxies used before reload.
+	ReloadProxies(config []byte, ruleConfig rule.Config) (func(), error)
+}
+
+type configReloadRequest struct {
+	// ID of reload, used to match rollback with applied configuration.
+	ID string `json:"id"`
+	// Version of configuration.
+	Version uint64 `json:"version,omitempty"`
+	// Config is a JSON object in configuration file format with channel
+	// options, limits and proxy options.
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+type configBackup struct {
+	id             string
+	config         rule.Config
+	limits         limits.Config
+	restoreProxies func()
+	version        uint64
+	data           json.RawMessage
+}
+
+// decodeRuleConfig decodes JSON configuration on top of rule.DefaultConfig and
+// validates it.
+func decodeRuleConfig(data []byte) (rule.Config, error) {
+	cfg := rule.DefaultConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// decodeConfig decodes and validates JSON configuration. Limits missing in
+// configuration keep current values.
+func (c *Caller) decodeConfig(data []byte) (rule.Config, limits.Config, error) {
+	ruleConfig, err := decodeRuleConfig(data)
+	if err != nil {
+		return ruleConfig, limits.Config{}, err
+	}
+	limitsConfig := c.config.Limits.Config()
+	if err := json.Unmarshal(data, &limitsConfig); err != nil {
+		return ruleConfig, limitsConfig, err
+	}
+	if err := limitsConfig.Validate(); err != nil {
+		return ruleConfig, limitsConfig, err
+	}
+	if c.config.ProxyReloader != nil {
+		if err := c.config.ProxyReloader.ValidateProxies(data, ruleConfig); err != nil {
+			return ruleConfig, limitsConfig, err
+		}
+	}
+	return ruleConfig, limitsConfig, nil
+}
+
+// ConfigVersion returns version of configuration reloaded over cluster which
+// is applied on node. Zero means node uses configuration it was started with.
+func (c *Caller) ConfigVersion() uint64 {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	return c.configVersion
+}
+
+// latestConfigVersion returns max configuration version among running nodes.
+func (c *Caller) latestConfigVersion() uint64 {
+	version := c.ConfigVersion()
+	info, err := c.node.Info()
+	if err != nil {
+		return version
+	}
+	for _, n := range info.Nodes {
+		if d, ok := nodeinfo.Decode(n.Data); ok && d.ConfigVersion > version {
+			version = d.ConfigVersion
+		}
+	}
+	return version
+}
+
+// ReloadConfig applies new configuration on all nodes running.
+func (c *Caller) ReloadConfig(ctx context.Context, cmd *apiproto.ReloadConfigRequest) error {
+	if _, _, err := c.decodeConfig(cmd.Config); err != nil {
+		c.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "invalid configuration for reload", map[string]interface{}{"error": err.Error()}))
+		return apiproto.ErrorBadRequest
+	}
+
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	req := configReloadRequest{
+		ID:      uuid.New().String(),
+		Version: c.latestConfigVersion() + 1,
+		Config:  json.RawMessage(cmd.Config),
+	}
+	data, _ := json.Marshal(req)
+
+	if err := c.configSurvey(ctx, opConfigValidate, data); err != nil {
+		c.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "configuration validation failed", map[string]interface{}{"error": err.Error()}))
+		return apiproto.ErrorBadRequest
+	}
+
+	if err := c.configSurvey(ctx, opConfigApply, data); err != nil {
+		c.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error applying configuration, rolling back", map[string]interface{}{"error": err.Error(), "reload_id": req.ID}))
+		// Use fresh context here since original one could be already expired.
+		rollbackCtx, cancel := context.WithTimeout(context.Background(), defaultRollbackTimeout)
+		defer cancel()
+		rollbackData, _ := json.Marshal(configReloadRequest{ID: req.ID})
+		if err := c.configSurvey(rollbackCtx, opConfigRollback, rollbackData); err != nil {
+			c.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error rolling back configuration", map[string]interface{}{"error": err.Error(), "reload_id": req.ID}))
+		}
+		return apiproto.ErrorInternal
+	}
+	c.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "configuration reloaded on all nodes", map[string]interface{}{"reload_id": req.ID, "version": req.Version}))
+	return nil
+}
+
+func (c *Caller) configSurvey(ctx context.Context, op string, data []byte) error {
+	results, err := c.survey(ctx, op, data)
+	if err != nil {
+		return err
+	}
+	for nodeID, result := range results {
+		if result.Code > 0 {
+			return fmt.Errorf("non-zero code from node %s: %d (%s)", nodeID, result.Code, result.Data)
+		}
+	}
+	return nil
+}
+
+// applyConfig applies decoded configuration and returns backup of
+// configuration used before. Configuration used before restored on error.
+// Must be called with configMu held.
+func (c *Caller) applyConfig(req configReloadRequest, ruleConfig rule.Config, limitsConfig limits.Config) (*configBackup, error) {
+	backup := &configBackup{
+		id:      req.ID,
+		config:  c.ruleContainer.Config(),
+		limits:  c.config.Limits.Config(),
+		version: c.configVersion,
+		data:    c.configData,
+	}
+	if c.config.ProxyReloader != nil {
+		restore, err := c.config.ProxyReloader.ReloadProxies(req.Config, ruleConfig)
+		if err != nil {
+			return nil, err
+		}
+		backup.restoreProxies = restore
+	}
+	if err := c.ruleContainer.Reload(ruleConfig); err != nil {
+		_ = c.restoreConfig(backup)
+		return nil, err
+	}
+	if c.config.Limits != nil {
+		if err := c.config.Limits.Reload(limitsConfig); err != nil {
+			_ = c.restoreConfig(backup)
+			return nil, err
+		}
+	}
+	c.configVersion = req.Version
+	c.configData = req.Config
+	return backup, nil
+}
+
+// restoreConfig restores configuration from backup. Must be called with
+// configMu held.
+func (c *Caller) restoreConfig(backup *configBackup) error {
+	if backup.restoreProxies != nil {
+		backup.restoreProxies()
+	}
+	if c.config.Limits != nil {
+		// Limits were valid before reload.
+		_ = c.config.Limits.Reload(backup.limits)
+	}
+	c.configVersion = backup.version
+	c.configData = backup.data
+	return c.ruleContainer.Reload(backup.config)
+}
+
+func (c *Caller) respondConfigValidateSurvey(_ *centrifuge.Node, params []byte) centrifuge.SurveyReply {
+	var req configReloadRequest
+	if err := json.Unmarshal(params, &req); err != nil {
+		return centrifuge.SurveyReply{Code: InvalidRequest}
+	}
+	if _, _, err := c.decodeConfig(req.Config); err != nil {
+		return centrifuge.SurveyReply{Code: InvalidRequest, Data: []byte(err.Error())}
+	}
+	return centrifuge.SurveyReply{}
+}
+
+func (c *Caller) respondConfigApplySurvey(node *centrifuge.Node, params []byte) centrifuge.SurveyReply {
+	var req configReloadRequest
+	if err := json.Unmarshal(params, &req); err != nil {
+		return centrifuge.SurveyReply{Code: InvalidRequest}
+	}
+	ruleConfig, limitsConfig, err := c.decodeConfig(req.Config)
+	if err != nil {
+		return centrifuge.SurveyReply{Code: InvalidRequest, Data: []byte(err.Error())}
+	}
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	backup, err := c.applyConfig(req, ruleConfig, limitsConfig)
+	if err != nil {
+		return centrifuge.SurveyReply{Code: InternalError, Data: []byte(err.Error())}
+	}
+	c.backup = backup
+	node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "configuration applied", map[string]interface{}{"reload_id": req.ID, "version": req.Version}))
+	return centrifuge.SurveyReply{}
+}
+
+func (c *Caller) respondConfigRollbackSurvey(node *centrifuge.Node, params []byte) centrifuge.SurveyReply {
+	var req configReloadRequest
+	if err := json.Unmarshal(params, &req); err != nil {
+		return centrifuge.SurveyReply{Code: InvalidRequest}
+	}
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	if c.backup == nil || c.backup.id != req.ID {
+		// Configuration of this reload was not applied on this node – nothing to do.
+		return centrifuge.SurveyReply{}
+	}
+	if err := c.restoreConfig(c.backup); err != nil {
+		return centrifuge.SurveyReply{Code: InternalError, Data: []byte(err.Error())}
+	}
+	c.backup = nil
+	node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "configuration rolled back", map[string]interface{}{"reload_id": req.ID}))
+	return centrifuge.SurveyReply{}
+}
+
+// respondConfigGetSurvey responds with configuration reloaded over cluster
+// which is applied on node.
+func (c *Caller) respondConfigGetSurvey(_ *centrifuge.Node, _ []byte) centrifuge.SurveyReply {
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	data, _ := json.Marshal(configReloadRequest{Version: c.configVersion, Config: c.configData})
+	return centrifuge.SurveyReply{Data: data}
+}
+
+// RunConfigSync periodically compares version of configuration applied on
+// node with versions other nodes advertise and pulls the latest configuration
+// if node missed configuration reload.
+func (c *Caller) RunConfigSync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.syncConfig(ctx); err != nil {
+				c.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error syncing configuration", map[string]interface{}{"error": err.Error()}))
+			}
+		}
+	}
+}
+
+// syncConfig pulls and applies the latest configuration reloaded over
+// cluster if other nodes have configuration of greater version.
+func (c *Caller) syncConfig(ctx context.Context) error {
+	if c.latestConfigVersion() <= c.ConfigVersion() {
+		return nil
+	}
+	results, err := c.survey(ctx, opConfigGet, nil)
+	if err != nil {
+		return err
+	}
+	var latest configReloadRequest
+	for _, result := range results {
+		if result.Code > 0 {
+			continue
+		}
+		var req configReloadRequest
+		if err := json.Unmarshal(result.Data, &req); err != nil {
+			continue
+		}
+		if req.Version > latest.Version {
+			latest = req
+		}
+	}
+	ruleConfig, limitsConfig, err := c.decodeConfig(latest.Config)
+	if err != nil {
+		return err
+	}
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+	if latest.Version <= c.configVersion {
+		return nil
+	}
+	if _, err := c.applyConfig(latest, ruleConfig, limitsConfig); err != nil {
+		return err
+	}
+	c.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "configuration synced from cluster", map[string]interface{}{"version": latest.Version}))
+	return nil
+}
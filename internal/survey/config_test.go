@@ -0,0 +1,194 @@
+package survey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/limits"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadConfig(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	caller := NewCaller(node, ruleContainer, Config{})
+
+	err := caller.ReloadConfig(context.Background(), &apiproto.ReloadConfigRequest{
+		Config: []byte(`{"namespaces": [{"name": "chat", "presence": true}]}`),
+	})
+	require.NoError(t, err)
+	opts, found, err := ruleContainer.ChannelOptions("chat:index")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, opts.Presence)
+	require.Equal(t, rule.DefaultConfig.ChannelNamespaceBoundary, ruleContainer.Config().ChannelNamespaceBoundary)
+
+	err = caller.ReloadConfig(context.Background(), &apiproto.ReloadConfigRequest{
+		Config: []byte(`{"namespaces": [{"name": "chat"}, {"name": "chat"}]}`),
+	})
+	require.Equal(t, apiproto.ErrorBadRequest, err)
+	require.Len(t, ruleContainer.Config().Namespaces, 1)
+}
+
+func TestConfigRollbackSurvey(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	caller := NewCaller(node, ruleContainer, Config{})
+
+	data, _ := json.Marshal(configReloadRequest{
+		ID:     "test",
+		Config: json.RawMessage(`{"presence": true}`),
+	})
+	reply := caller.respondConfigApplySurvey(node, data)
+	require.Zero(t, reply.Code)
+	require.True(t, ruleContainer.Config().Presence)
+
+	data, _ = json.Marshal(configReloadRequest{ID: "unknown"})
+	reply = caller.respondConfigRollbackSurvey(node, data)
+	require.Zero(t, reply.Code)
+	require.True(t, ruleContainer.Config().Presence)
+
+	data, _ = json.Marshal(configReloadRequest{ID: "test"})
+	reply = caller.respondConfigRollbackSurvey(node, data)
+	require.Zero(t, reply.Code)
+	require.False(t, ruleContainer.Config().Presence)
+}
+
+type testProxyReloader struct {
+	endpoint string
+}
+
+func (r *testProxyReloader) endpointFromConfig(config []byte) (string, error) {
+	var c struct {
+		Endpoint *string `json:"proxy_connect_endpoint"`
+	}
+	if err := json.Unmarshal(config, &c); err != nil {
+		return "", err
+	}
+	if c.Endpoint == nil {
+		return r.endpoint, nil
+	}
+	if *c.Endpoint == "invalid" {
+		return "", errors.New("invalid endpoint")
+	}
+	return *c.Endpoint, nil
+}
+
+func (r *testProxyReloader) ValidateProxies(config []byte, _ rule.Config) error {
+	_, err := r.endpointFromConfig(config)
+	return err
+}
+
+func (r *testProxyReloader) ReloadProxies(config []byte, _ rule.Config) (func(), error) {
+	endpoint, err := r.endpointFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	prev := r.endpoint
+	r.endpoint = endpoint
+	return func() { r.endpoint = prev }, nil
+}
+
+func TestReloadConfigLimitsAndProxies(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	limitsContainer := limits.NewContainer(limits.Config{ClientChannelLimit: 128, APIPresenceLimit: 10})
+	proxyReloader := &testProxyReloader{endpoint: "http://localhost:3000"}
+	caller := NewCaller(node, ruleContainer, Config{Limits: limitsContainer, ProxyReloader: proxyReloader})
+
+	err := caller.ReloadConfig(context.Background(), &apiproto.ReloadConfigRequest{
+		Config: []byte(`{"client_channel_limit": 10, "proxy_connect_endpoint": "http://localhost:3001"}`),
+	})
+	require.NoError(t, err)
+	// Limits missing in configuration keep current values.
+	require.Equal(t, limits.Config{ClientChannelLimit: 10, APIPresenceLimit: 10}, limitsContainer.Config())
+	require.Equal(t, "http://localhost:3001", proxyReloader.endpoint)
+	require.Equal(t, uint64(1), caller.ConfigVersion())
+	require.Equal(t, uint64(1), caller.NodeInfoData().ConfigVersion)
+
+	err = caller.ReloadConfig(context.Background(), &apiproto.ReloadConfigRequest{
+		Config: []byte(`{"client_channel_limit": -1}`),
+	})
+	require.Equal(t, apiproto.ErrorBadRequest, err)
+	err = caller.ReloadConfig(context.Background(), &apiproto.ReloadConfigRequest{
+		Config: []byte(`{"proxy_connect_endpoint": "invalid"}`),
+	})
+	require.Equal(t, apiproto.ErrorBadRequest, err)
+	require.Equal(t, 10, limitsContainer.Config().ClientChannelLimit)
+	require.Equal(t, "http://localhost:3001", proxyReloader.endpoint)
+	require.Equal(t, uint64(1), caller.ConfigVersion())
+}
+
+func TestConfigRollbackSurveyLimitsAndProxies(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+	limitsContainer := limits.NewContainer(limits.Config{})
+	proxyReloader := &testProxyReloader{endpoint: "http://localhost:3000"}
+	caller := NewCaller(node, rule.NewContainer(rule.DefaultConfig), Config{Limits: limitsContainer, ProxyReloader: proxyReloader})
+
+	data, _ := json.Marshal(configReloadRequest{
+		ID:      "test",
+		Version: 5,
+		Config:  json.RawMessage(`{"api_presence_limit": 1, "proxy_connect_endpoint": "http://localhost:3001"}`),
+	})
+	reply := caller.respondConfigApplySurvey(node, data)
+	require.Zero(t, reply.Code)
+	require.Equal(t, 1, limitsContainer.Config().APIPresenceLimit)
+	require.Equal(t, "http://localhost:3001", proxyReloader.endpoint)
+	require.Equal(t, uint64(5), caller.ConfigVersion())
+
+	data, _ = json.Marshal(configReloadRequest{ID: "test"})
+	reply = caller.respondConfigRollbackSurvey(node, data)
+	require.Zero(t, reply.Code)
+	require.Equal(t, 0, limitsContainer.Config().APIPresenceLimit)
+	require.Equal(t, "http://localhost:3000", proxyReloader.endpoint)
+	require.Equal(t, uint64(0), caller.ConfigVersion())
+}
+
+func TestSyncConfig(t *testing.T) {
+	node, err := centrifuge.New(centrifuge.DefaultConfig)
+	require.NoError(t, err)
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	// Node which missed configuration reload, e.g. restarted after it.
+	ruleContainer := rule.NewContainer(rule.DefaultConfig)
+	limitsContainer := limits.NewContainer(limits.Config{})
+	caller := NewCaller(node, ruleContainer, Config{Limits: limitsContainer})
+
+	// Node which applied configuration reload. It is created last so it
+	// responds to surveys.
+	upToDateCaller := NewCaller(node, rule.NewContainer(rule.DefaultConfig), Config{Limits: limits.NewContainer(limits.Config{})})
+	data, _ := json.Marshal(configReloadRequest{
+		ID:      "test",
+		Version: 3,
+		Config:  json.RawMessage(`{"presence": true, "client_user_connection_limit": 2}`),
+	})
+	require.Zero(t, upToDateCaller.respondConfigApplySurvey(node, data).Code)
+	node.OnNodeInfoSend(func() centrifuge.NodeInfoSendReply {
+		return centrifuge.NodeInfoSendReply{Data: upToDateCaller.NodeInfoData().Encode()}
+	})
+	require.NoError(t, node.Run())
+
+	require.Eventually(t, func() bool {
+		return caller.latestConfigVersion() == 3
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, caller.syncConfig(context.Background()))
+	require.Equal(t, uint64(3), caller.ConfigVersion())
+	require.True(t, ruleContainer.Config().Presence)
+	require.Equal(t, 2, limitsContainer.Config().ClientUserConnectionLimit)
+
+	// Nothing to sync.
+	require.NoError(t, caller.syncConfig(context.Background()))
+	require.Equal(t, uint64(3), caller.ConfigVersion())
+}
@@ -0,0 +1,78 @@
+package survey
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/connlog"
+
+	"github.com/centrifugal/centrifuge"
+	"google.golang.org/protobuf/proto"
+)
+
+const opConnectionEvents = "connection_events"
+
+// ConnectionEvents returns recent connection events from all running nodes
+// sorted by time, newest first.
+func (c *Caller) ConnectionEvents(ctx context.Context, cmd *apiproto.ConnectionEventsRequest) ([]*apiproto.ConnectionEvent, error) {
+	req, _ := proto.Marshal(cmd)
+	results, err := c.survey(ctx, opConnectionEvents, req)
+	if err != nil {
+		return nil, err
+	}
+	var events []*apiproto.ConnectionEvent
+	for nodeID, result := range results {
+		if result.Code > 0 {
+			return nil, fmt.Errorf("non-zero code from node %s: %d", nodeID, result.Code)
+		}
+		var nodeEvents apiproto.ConnectionEventsResult
+		err := proto.Unmarshal(result.Data, &nodeEvents)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshaling data from node %s: %v", nodeID, err)
+		}
+		events = append(events, nodeEvents.Events...)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].TimeMs > events[j].TimeMs
+	})
+	if cmd.Limit > 0 && len(events) > int(cmd.Limit) {
+		events = events[:cmd.Limit]
+	}
+	return events, nil
+}
+
+func (c *Caller) respondConnectionEventsSurvey(node *centrifuge.Node, params []byte) centrifuge.SurveyReply {
+	var req apiproto.ConnectionEventsRequest
+	err := proto.Unmarshal(params, &req)
+	if err != nil {
+		return centrifuge.SurveyReply{Code: InvalidRequest}
+	}
+	nodeEvents := c.config.ConnectionLog.Query(connlog.Filter{
+		User:   req.User,
+		Client: req.Client,
+		IP:     req.Ip,
+		Limit:  int(req.Limit),
+	})
+	events := make([]*apiproto.ConnectionEvent, 0, len(nodeEvents))
+	for _, e := range nodeEvents {
+		events = append(events, &apiproto.ConnectionEvent{
+			TimeMs:    e.Time.UnixNano() / int64(time.Millisecond),
+			Type:      string(e.Type),
+			Node:      node.ID(),
+			Client:    e.Client,
+			User:      e.User,
+			Ip:        e.IP,
+			Transport: e.Transport,
+			Code:      e.Code,
+			Reason:    e.Reason,
+		})
+	}
+	data, err := proto.Marshal(&apiproto.ConnectionEventsResult{Events: events})
+	if err != nil {
+		return centrifuge.SurveyReply{Code: InternalError}
+	}
+	return centrifuge.SurveyReply{Data: data}
+}
@@ -0,0 +1,52 @@
+package survey
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/connlog"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionEvents(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	connLog := connlog.New(10)
+	now := time.Now()
+	connLog.Add(connlog.Event{Time: now.Add(-time.Second), Type: connlog.EventConnect, Client: "1", User: "42", IP: "10.0.0.1"})
+	connLog.Add(connlog.Event{Time: now, Type: connlog.EventDisconnect, Client: "1", User: "42", IP: "10.0.0.1", Code: 3001, Reason: "shutdown"})
+	connLog.Add(connlog.Event{Time: now, Type: connlog.EventConnect, Client: "2", User: "43"})
+
+	caller := NewCaller(node, rule.NewContainer(rule.DefaultConfig), Config{ConnectionLog: connLog})
+
+	events, err := caller.ConnectionEvents(context.Background(), &apiproto.ConnectionEventsRequest{User: "42"})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "disconnect", events[0].Type)
+	require.Equal(t, uint32(3001), events[0].Code)
+	require.Equal(t, "shutdown", events[0].Reason)
+	require.Equal(t, node.ID(), events[0].Node)
+	require.Equal(t, "10.0.0.1", events[0].Ip)
+	require.Equal(t, "connect", events[1].Type)
+	require.True(t, events[0].TimeMs > events[1].TimeMs)
+
+	events, err = caller.ConnectionEvents(context.Background(), &apiproto.ConnectionEventsRequest{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+}
+
+func TestConnectionEventsNoLog(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	caller := NewCaller(node, rule.NewContainer(rule.DefaultConfig), Config{})
+	events, err := caller.ConnectionEvents(context.Background(), &apiproto.ConnectionEventsRequest{})
+	require.NoError(t, err)
+	require.Len(t, events, 0)
+}
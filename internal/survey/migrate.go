@@ -0,0 +1,66 @@
+package survey
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+
+	"github.com/centrifugal/centrifuge"
+	"google.golang.org/protobuf/proto"
+)
+
+const opMigrateConnections = "migrate_connections"
+
+// ConnectionMigrator asks connections of node to migrate to other nodes.
+type ConnectionMigrator interface {
+	// Migrate disconnects every connection with reconnect advice after random
+	// delay between minDelay and maxDelay. Returns number of connections.
+	Migrate(minDelay, maxDelay time.Duration) int
+}
+
+// MigrateConnections asks connections of node with ID cmd.Node to reconnect
+// to other nodes. Returns number of connections asked to migrate,
+// apiproto.ErrorBadRequest returned if node not found.
+func (c *Caller) MigrateConnections(ctx context.Context, cmd *apiproto.MigrateConnectionsRequest) (uint32, error) {
+	if c.config.ConnectionMigrator == nil {
+		return 0, apiproto.ErrorNotAvailable
+	}
+	req, _ := proto.Marshal(cmd)
+	results, err := c.survey(ctx, opMigrateConnections, req)
+	if err != nil {
+		return 0, err
+	}
+	result, ok := results[cmd.Node]
+	if !ok {
+		return 0, apiproto.ErrorBadRequest
+	}
+	if result.Code > 0 {
+		return 0, fmt.Errorf("non-zero code from node %s: %d", cmd.Node, result.Code)
+	}
+	n, err := strconv.ParseUint(string(result.Data), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing data from node %s: %v", cmd.Node, err)
+	}
+	return uint32(n), nil
+}
+
+func (c *Caller) respondMigrateConnectionsSurvey(node *centrifuge.Node, params []byte) centrifuge.SurveyReply {
+	var req apiproto.MigrateConnectionsRequest
+	if err := proto.Unmarshal(params, &req); err != nil {
+		return centrifuge.SurveyReply{Code: InvalidRequest}
+	}
+	if req.Node != node.ID() {
+		return centrifuge.SurveyReply{}
+	}
+	if c.config.ConnectionMigrator == nil {
+		return centrifuge.SurveyReply{Code: InvalidRequest}
+	}
+	minDelay := time.Duration(req.MinDelayMs) * time.Millisecond
+	maxDelay := time.Duration(req.MaxDelayMs) * time.Millisecond
+	numClients := c.config.ConnectionMigrator.Migrate(minDelay, maxDelay)
+	node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "migrating connections to other nodes", map[string]interface{}{"num_clients": numClients, "min_delay": minDelay.String(), "max_delay": maxDelay.String()}))
+	return centrifuge.SurveyReply{Data: []byte(strconv.Itoa(numClients))}
+}
@@ -0,0 +1,54 @@
+package survey
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testConnectionMigrator struct {
+	minDelay time.Duration
+	maxDelay time.Duration
+}
+
+func (m *testConnectionMigrator) Migrate(minDelay, maxDelay time.Duration) int {
+	m.minDelay = minDelay
+	m.maxDelay = maxDelay
+	return 2
+}
+
+func TestMigrateConnections(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	migrator := &testConnectionMigrator{}
+	caller := NewCaller(node, rule.NewContainer(rule.DefaultConfig), Config{ConnectionMigrator: migrator})
+
+	numClients, err := caller.MigrateConnections(context.Background(), &apiproto.MigrateConnectionsRequest{
+		Node:       node.ID(),
+		MinDelayMs: 1000,
+		MaxDelayMs: 5000,
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), numClients)
+	require.Equal(t, time.Second, migrator.minDelay)
+	require.Equal(t, 5*time.Second, migrator.maxDelay)
+
+	_, err = caller.MigrateConnections(context.Background(), &apiproto.MigrateConnectionsRequest{Node: "unknown"})
+	require.Equal(t, apiproto.ErrorBadRequest, err)
+}
+
+func TestMigrateConnectionsNotAvailable(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	caller := NewCaller(node, rule.NewContainer(rule.DefaultConfig), Config{})
+	_, err := caller.MigrateConnections(context.Background(), &apiproto.MigrateConnectionsRequest{Node: node.ID()})
+	require.Equal(t, apiproto.ErrorNotAvailable, err)
+}
@@ -0,0 +1,73 @@
+package survey
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+const opAddRedisShard = "add_redis_shard"
+
+// RedisShardAdder adds Redis shard to engine of node at runtime.
+type RedisShardAdder interface {
+	// AddRedisShard adds shard with address. History of channels migrated to
+	// new shard created before since is removed. Returns number of node
+	// channels migrated to new shard.
+	AddRedisShard(address string, since time.Time) (int, error)
+}
+
+type addRedisShardRequest struct {
+	Address string `json:"address"`
+	// Since is a Unix time in seconds, the same on all nodes.
+	Since int64 `json:"since"`
+}
+
+// AddRedisShard adds Redis shard on all running nodes. Returns total number of
+// channels migrated to new shard.
+func (c *Caller) AddRedisShard(ctx context.Context, cmd *apiproto.AddRedisShardRequest) (uint32, error) {
+	if c.config.RedisShardAdder == nil {
+		return 0, apiproto.ErrorNotAvailable
+	}
+	data, _ := json.Marshal(addRedisShardRequest{
+		Address: cmd.Address,
+		Since:   time.Now().Unix(),
+	})
+	results, err := c.survey(ctx, opAddRedisShard, data)
+	if err != nil {
+		return 0, err
+	}
+	var numMigrated uint32
+	for nodeID, result := range results {
+		if result.Code > 0 {
+			return 0, fmt.Errorf("non-zero code from node %s: %d (%s)", nodeID, result.Code, result.Data)
+		}
+		n, err := strconv.ParseUint(string(result.Data), 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing data from node %s: %v", nodeID, err)
+		}
+		numMigrated += uint32(n)
+	}
+	return numMigrated, nil
+}
+
+func (c *Caller) respondAddRedisShardSurvey(node *centrifuge.Node, params []byte) centrifuge.SurveyReply {
+	var req addRedisShardRequest
+	if err := json.Unmarshal(params, &req); err != nil || req.Address == "" {
+		return centrifuge.SurveyReply{Code: InvalidRequest}
+	}
+	if c.config.RedisShardAdder == nil {
+		return centrifuge.SurveyReply{Code: InvalidRequest, Data: []byte("Redis engine not used")}
+	}
+	numMigrated, err := c.config.RedisShardAdder.AddRedisShard(req.Address, time.Unix(req.Since, 0))
+	if err != nil {
+		node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error adding Redis shard", map[string]interface{}{"error": err.Error(), "address": req.Address}))
+		return centrifuge.SurveyReply{Code: InternalError, Data: []byte(err.Error())}
+	}
+	return centrifuge.SurveyReply{Data: []byte(strconv.Itoa(numMigrated))}
+}
@@ -0,0 +1,56 @@
+package survey
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testRedisShardAdder struct {
+	addresses []string
+	err       error
+}
+
+func (a *testRedisShardAdder) AddRedisShard(address string, _ time.Time) (int, error) {
+	if a.err != nil {
+		return 0, a.err
+	}
+	a.addresses = append(a.addresses, address)
+	return 3, nil
+}
+
+func TestAddRedisShard(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	adder := &testRedisShardAdder{}
+	caller := NewCaller(node, rule.NewContainer(rule.DefaultConfig), Config{RedisShardAdder: adder})
+
+	numMigrated, err := caller.AddRedisShard(context.Background(), &apiproto.AddRedisShardRequest{Address: "127.0.0.1:6380"})
+	require.NoError(t, err)
+	require.Equal(t, uint32(3), numMigrated)
+	require.Equal(t, []string{"127.0.0.1:6380"}, adder.addresses)
+
+	_, err = caller.AddRedisShard(context.Background(), &apiproto.AddRedisShardRequest{})
+	require.Error(t, err)
+
+	adder.err = errors.New("boom")
+	_, err = caller.AddRedisShard(context.Background(), &apiproto.AddRedisShardRequest{Address: "127.0.0.1:6381"})
+	require.Error(t, err)
+}
+
+func TestAddRedisShardNotAvailable(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+
+	caller := NewCaller(node, rule.NewContainer(rule.DefaultConfig), Config{})
+	_, err := caller.AddRedisShard(context.Background(), &apiproto.AddRedisShardRequest{Address: "127.0.0.1:6380"})
+	require.Equal(t, apiproto.ErrorNotAvailable, err)
+}
@@ -2,29 +2,86 @@ package survey
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/connlog"
+	"github.com/centrifugal/centrifugo/v3/internal/controlproto"
+	"github.com/centrifugal/centrifugo/v3/internal/limits"
+	"github.com/centrifugal/centrifugo/v3/internal/nodeinfo"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
 
 	"github.com/centrifugal/centrifuge"
 	"github.com/gobwas/glob"
+	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/protobuf/proto"
 )
 
 // Handler can handle survey.
 type Handler func(node *centrifuge.Node, data []byte) centrifuge.SurveyReply
 
+// Config of Caller.
+type Config struct {
+	// Timeout to wait for replies from all nodes. Zero value means
+	// that default survey timeout of Centrifuge library used.
+	Timeout time.Duration
+	// ConnectionLog of node to respond to connection events survey. Node
+	// responds with no events if not set.
+	ConnectionLog *connlog.Log
+	// RedisShardAdder of node to respond to Redis shard addition survey.
+	// Adding Redis shards is not available if not set.
+	RedisShardAdder RedisShardAdder
+	// ConnectionMigrator of node to respond to connection migration survey.
+	// Migrating connections is not available if not set.
+	ConnectionMigrator ConnectionMigrator
+	// ChannelUnsubscriber of node to respond to channel unsubscribe survey.
+	// Unsubscribing all channel clients is not available if not set.
+	ChannelUnsubscriber ChannelUnsubscriber
+	// Limits of node reloaded together with channel options. Limits in
+	// reload configuration ignored if not set.
+	Limits *limits.Container
+	// ProxyReloader of node to rebuild client proxies on configuration
+	// reload. Proxy options in reload configuration ignored if not set.
+	ProxyReloader ProxyReloader
+}
+
 type Caller struct {
-	node     *centrifuge.Node
-	handlers map[string]Handler
+	node          *centrifuge.Node
+	ruleContainer *rule.Container
+	config        Config
+	handlers      map[string]Handler
+
+	reloadMu      sync.Mutex
+	configMu      sync.Mutex
+	backup        *configBackup
+	configVersion uint64
+	configData    json.RawMessage
 }
 
-func NewCaller(node *centrifuge.Node) *Caller {
+func NewCaller(node *centrifuge.Node, ruleContainer *rule.Container, config Config) *Caller {
 	c := &Caller{
-		node: node,
-		handlers: map[string]Handler{
-			"channels": respondChannelsSurvey,
-		},
+		node:          node,
+		ruleContainer: ruleContainer,
+		config:        config,
+	}
+	c.handlers = map[string]Handler{
+		"channels":           respondChannelsSurvey,
+		opChannelsCount:      respondChannelsCountSurvey,
+		opUserConnections:    respondUserConnectionsSurvey,
+		opChannelSubscribers: respondChannelSubscribersSurvey,
+		opConfigValidate:     c.respondConfigValidateSurvey,
+		opConfigApply:        c.respondConfigApplySurvey,
+		opConfigRollback:     c.respondConfigRollbackSurvey,
+		opConfigGet:          c.respondConfigGetSurvey,
+		opSetChannelOptions:  c.respondSetChannelOptionsSurvey,
+		opConnectionEvents:   c.respondConnectionEventsSurvey,
+		opAddRedisShard:      c.respondAddRedisShardSurvey,
+		opMigrateConnections: c.respondMigrateConnectionsSurvey,
+		opUnsubscribeChannel: c.respondUnsubscribeChannelSurvey,
 	}
 	c.node.OnSurvey(func(event centrifuge.SurveyEvent, cb centrifuge.SurveyCallback) {
 		h, ok := c.handlers[event.Op]
@@ -32,7 +89,20 @@ func NewCaller(node *centrifuge.Node) *Caller {
 			cb(centrifuge.SurveyReply{Code: MethodNotFound})
 			return
 		}
-		cb(h(c.node, event.Data))
+		msg, err := controlproto.Decode(event.Data)
+		if err != nil {
+			cb(centrifuge.SurveyReply{Code: InvalidRequest})
+			return
+		}
+		if msg.Version > controlproto.Version {
+			cb(centrifuge.SurveyReply{Code: UnsupportedVersion})
+			return
+		}
+		reply := h(c.node, msg.Data)
+		// Reply encoded with version of request, so node of older version
+		// gets reply it understands.
+		reply.Data = controlproto.Encode(controlproto.Message{Version: msg.Version, Data: reply.Data})
+		cb(reply)
 	})
 	return c
 }
@@ -41,15 +111,115 @@ const (
 	InternalError  uint32 = 1
 	InvalidRequest uint32 = 2
 	MethodNotFound uint32 = 3
+	// UnsupportedVersion returned by node when survey request encoded with
+	// control protocol version newer than node supports.
+	UnsupportedVersion uint32 = 4
 )
 
-func (c *Caller) Channels(ctx context.Context, cmd *apiproto.ChannelsRequest) (map[string]*apiproto.ChannelInfo, error) {
-	return surveyChannels(ctx, c.node, cmd)
+// ControlVersion is a version of survey commands, see controlproto package.
+// Adding new survey op does not require incrementing it since nodes
+// advertise supported ops.
+const ControlVersion = controlproto.Version
+
+var skippedNodesCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "centrifugo",
+	Subsystem: "cluster",
+	Name:      "survey_skipped_nodes",
+	Help:      "Number of survey replies skipped since node does not support survey op.",
+}, []string{"op"})
+
+func init() {
+	prometheus.MustRegister(skippedNodesCount)
+}
+
+// NodeInfoData returns data attached to node info so other nodes know which
+// survey ops this node supports and which configuration version it uses.
+func (c *Caller) NodeInfoData() nodeinfo.Data {
+	ops := make([]string, 0, len(c.handlers))
+	for op := range c.handlers {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	return nodeinfo.Data{ControlVersion: ControlVersion, SurveyOps: ops, ConfigVersion: c.ConfigVersion()}
 }
 
-func surveyChannels(ctx context.Context, node *centrifuge.Node, cmd *apiproto.ChannelsRequest) (map[string]*apiproto.ChannelInfo, error) {
+// survey sends survey to all running nodes and collects replies within
+// configured timeout. Request is encoded with control protocol version all
+// running nodes support. Replies of nodes which don't support op or control
+// protocol version (nodes of older versions during rolling upgrade) are
+// skipped, so survey does not fail until all nodes upgraded.
+func (c *Caller) survey(ctx context.Context, op string, data []byte) (map[string]centrifuge.SurveyResult, error) {
+	if c.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.Timeout)
+		defer cancel()
+	}
+	version := c.controlVersion()
+	results, err := c.node.Survey(ctx, op, controlproto.Encode(controlproto.Message{Version: version, Data: data}))
+	if err != nil {
+		return nil, err
+	}
+	for nodeID, result := range results {
+		switch {
+		case result.Code == MethodNotFound && !c.nodeSupportsOp(nodeID, op):
+			delete(results, nodeID)
+			skippedNodesCount.WithLabelValues(op).Inc()
+			c.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "survey op not supported by node, skipping reply", map[string]interface{}{"op": op, "node": nodeID}))
+		case result.Code == UnsupportedVersion:
+			// Node joined with older version after version negotiated.
+			delete(results, nodeID)
+			skippedNodesCount.WithLabelValues(op).Inc()
+			c.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "control version not supported by node, skipping reply", map[string]interface{}{"op": op, "node": nodeID, "version": version}))
+		default:
+			msg, err := controlproto.DecodeReply(version, result.Data)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding reply from node %s: %v", nodeID, err)
+			}
+			result.Data = msg.Data
+			results[nodeID] = result
+		}
+	}
+	return results, nil
+}
+
+// controlVersion returns control protocol version supported by all running
+// nodes. Nodes which do not attach data to node info considered nodes of
+// version 0.
+func (c *Caller) controlVersion() uint32 {
+	info, err := c.node.Info()
+	if err != nil {
+		return 0
+	}
+	versions := make([]uint32, 0, len(info.Nodes))
+	for _, n := range info.Nodes {
+		if n.UID == c.node.ID() {
+			continue
+		}
+		d, _ := nodeinfo.Decode(n.Data)
+		versions = append(versions, d.ControlVersion)
+	}
+	return controlproto.Negotiate(versions...)
+}
+
+// nodeSupportsOp reports whether node advertises support of survey op.
+func (c *Caller) nodeSupportsOp(nodeID string, op string) bool {
+	info, err := c.node.Info()
+	if err != nil {
+		return false
+	}
+	for _, n := range info.Nodes {
+		if n.UID != nodeID {
+			continue
+		}
+		d, ok := nodeinfo.Decode(n.Data)
+		return ok && d.HasSurveyOp(op)
+	}
+	return false
+}
+
+func (c *Caller) Channels(ctx context.Context, cmd *apiproto.ChannelsRequest) (map[string]*apiproto.ChannelInfo, error) {
 	req, _ := proto.Marshal(cmd)
-	results, err := node.Survey(ctx, "channels", req)
+	results, err := c.survey(ctx, "channels", req)
 	if err != nil {
 		return nil, err
 	}
@@ -91,8 +261,21 @@ func respondChannelsSurvey(node *centrifuge.Node, params []byte) centrifuge.Surv
 		}
 	}
 	channels := node.Hub().Channels()
+	if req.Limit > 0 {
+		// Node responds with limit+1 channels following cursor, so caller
+		// can find out whether there are more channels after page.
+		sort.Strings(channels)
+		i := sort.SearchStrings(channels, req.Cursor)
+		if i < len(channels) && channels[i] == req.Cursor {
+			i++
+		}
+		channels = channels[i:]
+	}
 	channelsMap := make(map[string]*apiproto.ChannelInfo, len(channels))
 	for _, ch := range channels {
+		if req.Limit > 0 && len(channelsMap) > int(req.Limit) {
+			break
+		}
 		if g != nil && !g.Match(ch) {
 			continue
 		}
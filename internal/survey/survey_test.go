@@ -0,0 +1,71 @@
+package survey
+
+import (
+	"context"
+	"testing"
+
+	"github.com/centrifugal/centrifugo/v3/internal/controlproto"
+	"github.com/centrifugal/centrifugo/v3/internal/nodeinfo"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeInfoData(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+	caller := NewCaller(node, rule.NewContainer(rule.DefaultConfig), Config{})
+	d, ok := nodeinfo.Decode(caller.NodeInfoData().Encode())
+	require.True(t, ok)
+	require.Equal(t, ControlVersion, d.ControlVersion)
+	require.True(t, d.HasSurveyOp(opChannelsCount))
+	require.True(t, d.HasSurveyOp(opUnsubscribeChannel))
+}
+
+func TestSurveySkipsUnsupportedOp(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+	caller := NewCaller(node, rule.NewContainer(rule.DefaultConfig), Config{})
+	// Node does not advertise op, so its MethodNotFound reply is skipped.
+	results, err := caller.survey(context.Background(), "unknown", nil)
+	require.NoError(t, err)
+	require.Len(t, results, 0)
+	// Replies of supported ops kept.
+	results, err = caller.survey(context.Background(), opChannelsCount, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+}
+
+func TestSurveyControlVersion(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+	_ = NewCaller(node, rule.NewContainer(rule.DefaultConfig), Config{})
+
+	// Request of node of older version without envelope handled and
+	// replied without envelope.
+	results, err := node.Survey(context.Background(), opChannelsCount, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	for _, result := range results {
+		require.Zero(t, result.Code)
+		_, err := controlproto.DecodeReply(controlproto.Version, result.Data)
+		require.Equal(t, controlproto.ErrMalformed, err)
+	}
+
+	// Request of newer control version rejected instead of being
+	// misinterpreted.
+	data := controlproto.Encode(controlproto.Message{Version: controlproto.Version + 1})
+	results, err = node.Survey(context.Background(), opChannelsCount, data)
+	require.NoError(t, err)
+	for _, result := range results {
+		require.Equal(t, UnsupportedVersion, result.Code)
+	}
+
+	// Malformed envelope.
+	results, err = node.Survey(context.Background(), opChannelsCount, []byte{0x00, 0x08})
+	require.NoError(t, err)
+	for _, result := range results {
+		require.Equal(t, InvalidRequest, result.Code)
+	}
+}
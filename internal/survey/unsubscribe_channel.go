@@ -0,0 +1,63 @@
+package survey
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+
+	"github.com/centrifugal/centrifuge"
+	"google.golang.org/protobuf/proto"
+)
+
+const opUnsubscribeChannel = "unsubscribe_channel"
+
+// ChannelUnsubscriber unsubscribes node clients from channel.
+type ChannelUnsubscriber interface {
+	// UnsubscribeChannel unsubscribes all node clients from channel. Returns
+	// number of unsubscribed clients.
+	UnsubscribeChannel(ch string) int
+}
+
+// UnsubscribeChannel unsubscribes clients of every running node from channel.
+// Returns number of unsubscribed clients by node ID.
+func (c *Caller) UnsubscribeChannel(ctx context.Context, cmd *apiproto.UnsubscribeChannelRequest) (map[string]uint32, error) {
+	if c.config.ChannelUnsubscriber == nil {
+		return nil, apiproto.ErrorNotAvailable
+	}
+	req, _ := proto.Marshal(&apiproto.UnsubscribeChannelRequest{Channel: cmd.Channel})
+	results, err := c.survey(ctx, opUnsubscribeChannel, req)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]uint32, len(results))
+	for nodeID, result := range results {
+		if result.Code > 0 {
+			return nil, fmt.Errorf("non-zero code from node %s: %d", nodeID, result.Code)
+		}
+		var nodeResult apiproto.UnsubscribeChannelResult
+		err := proto.Unmarshal(result.Data, &nodeResult)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshaling data from node %s: %v", nodeID, err)
+		}
+		nodes[nodeID] = nodeResult.NumClients
+	}
+	return nodes, nil
+}
+
+func (c *Caller) respondUnsubscribeChannelSurvey(node *centrifuge.Node, params []byte) centrifuge.SurveyReply {
+	var req apiproto.UnsubscribeChannelRequest
+	if err := proto.Unmarshal(params, &req); err != nil || req.Channel == "" {
+		return centrifuge.SurveyReply{Code: InvalidRequest}
+	}
+	if c.config.ChannelUnsubscriber == nil {
+		return centrifuge.SurveyReply{Code: InvalidRequest}
+	}
+	numClients := c.config.ChannelUnsubscriber.UnsubscribeChannel(req.Channel)
+	node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "unsubscribed clients from channel", map[string]interface{}{"channel": req.Channel, "num_clients": numClients}))
+	data, err := proto.Marshal(&apiproto.UnsubscribeChannelResult{NumClients: uint32(numClients)})
+	if err != nil {
+		return centrifuge.SurveyReply{Code: InternalError}
+	}
+	return centrifuge.SurveyReply{Data: data}
+}
@@ -0,0 +1,49 @@
+package survey
+
+import (
+	"context"
+	"testing"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/migrate"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnsubscribeChannel(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+	node.OnConnecting(func(ctx context.Context, _ centrifuge.ConnectEvent) (centrifuge.ConnectReply, error) {
+		return centrifuge.ConnectReply{
+			Credentials:   &centrifuge.Credentials{UserID: "42"},
+			Subscriptions: map[string]centrifuge.SubscribeOptions{"test": {}, "other": {}},
+		}, nil
+	})
+	registry := migrate.NewRegistry()
+
+	caller := NewCaller(node, rule.NewContainer(rule.DefaultConfig), Config{})
+	_, err := caller.UnsubscribeChannel(context.Background(), &apiproto.UnsubscribeChannelRequest{Channel: "test"})
+	require.Equal(t, apiproto.ErrorNotAvailable, err)
+
+	caller = NewCaller(node, rule.NewContainer(rule.DefaultConfig), Config{ChannelUnsubscriber: registry})
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{})
+	registry.Add(client)
+
+	nodes, err := caller.UnsubscribeChannel(context.Background(), &apiproto.UnsubscribeChannelRequest{Channel: "test"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint32{node.ID(): 1}, nodes)
+	require.False(t, client.IsSubscribed("test"))
+	require.True(t, client.IsSubscribed("other"))
+	require.Equal(t, 0, node.Hub().NumSubscribers("test"))
+
+	nodes, err = caller.UnsubscribeChannel(context.Background(), &apiproto.UnsubscribeChannelRequest{Channel: "test"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint32{node.ID(): 0}, nodes)
+}
@@ -0,0 +1,79 @@
+package survey
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/clientcontext"
+	"github.com/centrifugal/centrifugo/v3/internal/geoip"
+	"github.com/centrifugal/centrifugo/v3/internal/sdkinfo"
+
+	"github.com/centrifugal/centrifuge"
+	"google.golang.org/protobuf/proto"
+)
+
+const opUserConnections = "user_connections"
+
+// UserConnections returns active connections of user from all running nodes.
+func (c *Caller) UserConnections(ctx context.Context, cmd *apiproto.UserConnectionsRequest) (map[string]*apiproto.UserConnectionInfo, error) {
+	req, _ := proto.Marshal(cmd)
+	results, err := c.survey(ctx, opUserConnections, req)
+	if err != nil {
+		return nil, err
+	}
+	connections := map[string]*apiproto.UserConnectionInfo{}
+	for nodeID, result := range results {
+		if result.Code > 0 {
+			return nil, fmt.Errorf("non-zero code from node %s: %d", nodeID, result.Code)
+		}
+		var nodeConnections apiproto.UserConnectionsResult
+		err := proto.Unmarshal(result.Data, &nodeConnections)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshaling data from node %s: %v", nodeID, err)
+		}
+		for clientID, info := range nodeConnections.Connections {
+			connections[clientID] = info
+		}
+	}
+	return connections, nil
+}
+
+func respondUserConnectionsSurvey(node *centrifuge.Node, params []byte) centrifuge.SurveyReply {
+	var req apiproto.UserConnectionsRequest
+	err := proto.Unmarshal(params, &req)
+	if err != nil {
+		return centrifuge.SurveyReply{Code: InvalidRequest}
+	}
+	userConnections := node.Hub().UserConnections(req.User)
+	connections := make(map[string]*apiproto.UserConnectionInfo, len(userConnections))
+	for clientID, client := range userConnections {
+		info := &apiproto.UserConnectionInfo{
+			Transport: client.Transport().Name(),
+			Protocol:  string(client.Transport().Protocol()),
+			Node:      node.ID(),
+			Channels:  client.Channels(),
+		}
+		if connectTime, ok := clientcontext.GetContextConnectTime(client.Context()); ok {
+			info.ConnectedAt = connectTime.Unix()
+		}
+		if connectionMeta, ok := clientcontext.GetContextConnectionMeta(client.Context()); ok {
+			info.Meta = apiproto.Raw(connectionMeta.Meta)
+		}
+		if sdk, ok := sdkinfo.FromContext(client.Context()); ok {
+			info.AppName = sdk.Name
+			info.AppVersion = sdk.Version
+			info.SdkFeatures = sdk.Features
+		}
+		if loc, ok := geoip.FromContext(client.Context()); ok {
+			info.Country = loc.Country
+			info.Region = loc.Region
+		}
+		connections[clientID] = info
+	}
+	data, err := proto.Marshal(&apiproto.UserConnectionsResult{Connections: connections})
+	if err != nil {
+		return centrifuge.SurveyReply{Code: InternalError}
+	}
+	return centrifuge.SurveyReply{Data: data}
+}
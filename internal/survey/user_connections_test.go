@@ -0,0 +1,52 @@
+package survey
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/clientcontext"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/sdkinfo"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserConnections(t *testing.T) {
+	node := tools.NodeWithMemoryEngine()
+	defer func() { _ = node.Shutdown(context.Background()) }()
+	connectTime := time.Now()
+	node.OnConnecting(func(ctx context.Context, _ centrifuge.ConnectEvent) (centrifuge.ConnectReply, error) {
+		return centrifuge.ConnectReply{
+			Credentials:   &centrifuge.Credentials{UserID: "42"},
+			Subscriptions: map[string]centrifuge.SubscribeOptions{"test": {}},
+			Context:       clientcontext.SetContextConnectTime(sdkinfo.SetToContext(ctx, sdkinfo.Info{Name: "centrifuge-go", Version: "0.8.0", Features: []string{sdkinfo.FeatureRecovery}}), connectTime),
+		}, nil
+	})
+	caller := NewCaller(node, rule.NewContainer(rule.DefaultConfig), Config{})
+
+	client, closeFn, err := centrifuge.NewClient(context.Background(), node, tools.NewTestTransport())
+	require.NoError(t, err)
+	defer func() { _ = closeFn() }()
+	client.Connect(centrifuge.ConnectRequest{})
+
+	connections, err := caller.UserConnections(context.Background(), &apiproto.UserConnectionsRequest{User: "42"})
+	require.NoError(t, err)
+	require.Len(t, connections, 1)
+	info, ok := connections[client.ID()]
+	require.True(t, ok)
+	require.Equal(t, node.ID(), info.Node)
+	require.Equal(t, "test_transport", info.Transport)
+	require.Equal(t, connectTime.Unix(), info.ConnectedAt)
+	require.Equal(t, []string{"test"}, info.Channels)
+	require.Equal(t, "centrifuge-go", info.AppName)
+	require.Equal(t, "0.8.0", info.AppVersion)
+	require.Equal(t, []string{sdkinfo.FeatureRecovery}, info.SdkFeatures)
+
+	connections, err = caller.UserConnections(context.Background(), &apiproto.UserConnectionsRequest{User: "43"})
+	require.NoError(t, err)
+	require.Len(t, connections, 0)
+}
@@ -0,0 +1,145 @@
+// Package tenant allows one Centrifugo cluster to serve several isolated
+// applications. Every tenant owns a set of channel namespaces, has its own API
+// key and its own secret to sign connection and subscription tokens.
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+)
+
+// Tenant is an isolated application.
+type Tenant struct {
+	// Name is a unique tenant identifier. It's used in JWT tenant claim and in
+	// Redis key prefix of tenant channels.
+	Name string `mapstructure:"name" json:"name"`
+	// APIKey of tenant. Server API requests with tenant API key can only
+	// operate with tenant channels.
+	APIKey string `mapstructure:"api_key" json:"api_key"`
+	// TokenHMACSecretKey is used to verify tokens with tenant claim.
+	TokenHMACSecretKey string `mapstructure:"token_hmac_secret_key" json:"token_hmac_secret_key"`
+	// Namespaces owned by tenant. Channels of these namespaces are only
+	// available to connections and API requests of tenant.
+	Namespaces []string `mapstructure:"namespaces" json:"namespaces"`
+}
+
+var namePattern = "^[-a-zA-Z0-9_]{2,}$"
+var nameRe = regexp.MustCompile(namePattern)
+
+// Registry keeps configured tenants. Nil Registry is valid and means that
+// multi-tenancy is off.
+type Registry struct {
+	tenants     map[string]Tenant
+	byAPIKey    map[string]string
+	byNamespace map[string]string
+	namespace   func(ch string) string
+}
+
+// NewRegistry validates tenants and creates Registry. Function which extracts
+// namespace name from channel must be provided.
+func NewRegistry(tenants []Tenant, namespace func(ch string) string) (*Registry, error) {
+	r := &Registry{
+		tenants:     make(map[string]Tenant, len(tenants)),
+		byAPIKey:    make(map[string]string, len(tenants)),
+		byNamespace: make(map[string]string),
+		namespace:   namespace,
+	}
+	for _, t := range tenants {
+		if !nameRe.MatchString(t.Name) {
+			return nil, fmt.Errorf("invalid tenant name – %s (must match %s regular expression)", t.Name, namePattern)
+		}
+		if _, ok := r.tenants[t.Name]; ok {
+			return nil, fmt.Errorf("tenant name must be unique: %s", t.Name)
+		}
+		if t.APIKey != "" {
+			if _, ok := r.byAPIKey[t.APIKey]; ok {
+				return nil, fmt.Errorf("tenant API key must be unique: %s", t.Name)
+			}
+			r.byAPIKey[t.APIKey] = t.Name
+		}
+		if len(t.Namespaces) == 0 {
+			return nil, fmt.Errorf("tenant must own at least one namespace: %s", t.Name)
+		}
+		for _, ns := range t.Namespaces {
+			if other, ok := r.byNamespace[ns]; ok {
+				return nil, fmt.Errorf("namespace %s belongs to both %s and %s tenants", ns, other, t.Name)
+			}
+			r.byNamespace[ns] = t.Name
+		}
+		r.tenants[t.Name] = t
+	}
+	return r, nil
+}
+
+// Enabled returns true if there are configured tenants.
+func (r *Registry) Enabled() bool {
+	return r != nil && len(r.tenants) > 0
+}
+
+// Get returns tenant by name.
+func (r *Registry) Get(name string) (Tenant, bool) {
+	if r == nil {
+		return Tenant{}, false
+	}
+	t, ok := r.tenants[name]
+	return t, ok
+}
+
+// Tenants returns all configured tenants.
+func (r *Registry) Tenants() []Tenant {
+	if r == nil {
+		return nil
+	}
+	tenants := make([]Tenant, 0, len(r.tenants))
+	for _, t := range r.tenants {
+		tenants = append(tenants, t)
+	}
+	return tenants
+}
+
+// ByAPIKey returns name of tenant with API key.
+func (r *Registry) ByAPIKey(key string) (string, bool) {
+	if r == nil || key == "" {
+		return "", false
+	}
+	name, ok := r.byAPIKey[key]
+	return name, ok
+}
+
+// ChannelTenant returns name of tenant owning channel, empty string returned
+// for channels not owned by any tenant.
+func (r *Registry) ChannelTenant(ch string) string {
+	if !r.Enabled() {
+		return ""
+	}
+	return r.byNamespace[r.namespace(ch)]
+}
+
+// ChannelAllowed reports whether channel can be used by tenant. Connections
+// without tenant (empty name) can only use channels not owned by tenants.
+func (r *Registry) ChannelAllowed(name string, ch string) bool {
+	if !r.Enabled() {
+		return true
+	}
+	return r.ChannelTenant(ch) == name
+}
+
+type contextKey struct{}
+
+// SetToContext puts tenant name to context.
+func SetToContext(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, contextKey{}, name)
+}
+
+// FromContext returns tenant name from context.
+func FromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	if val := ctx.Value(contextKey{}); val != nil {
+		name, ok := val.(string)
+		return name, ok
+	}
+	return "", false
+}
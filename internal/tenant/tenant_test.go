@@ -0,0 +1,73 @@
+package tenant
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func namespace(ch string) string {
+	if i := strings.Index(ch, ":"); i > 0 {
+		return ch[:i]
+	}
+	return ""
+}
+
+func TestRegistry(t *testing.T) {
+	r, err := NewRegistry([]Tenant{
+		{Name: "app1", APIKey: "key1", Namespaces: []string{"app1_chat", "app1_news"}},
+		{Name: "app2", APIKey: "key2", Namespaces: []string{"app2_chat"}},
+	}, namespace)
+	require.NoError(t, err)
+	require.True(t, r.Enabled())
+
+	name, ok := r.ByAPIKey("key2")
+	require.True(t, ok)
+	require.Equal(t, "app2", name)
+	_, ok = r.ByAPIKey("unknown")
+	require.False(t, ok)
+
+	require.Equal(t, "app1", r.ChannelTenant("app1_news:1"))
+	require.Equal(t, "", r.ChannelTenant("shared:1"))
+
+	require.True(t, r.ChannelAllowed("app1", "app1_chat:1"))
+	require.False(t, r.ChannelAllowed("app2", "app1_chat:1"))
+	require.False(t, r.ChannelAllowed("", "app1_chat:1"))
+	require.True(t, r.ChannelAllowed("", "shared:1"))
+	require.False(t, r.ChannelAllowed("app1", "shared:1"))
+}
+
+func TestRegistryNil(t *testing.T) {
+	var r *Registry
+	require.False(t, r.Enabled())
+	require.True(t, r.ChannelAllowed("", "app1_chat:1"))
+	_, ok := r.ByAPIKey("key1")
+	require.False(t, ok)
+}
+
+func TestRegistryValidation(t *testing.T) {
+	_, err := NewRegistry([]Tenant{{Name: "a.b", Namespaces: []string{"ns"}}}, namespace)
+	require.Error(t, err)
+	_, err = NewRegistry([]Tenant{{Name: "app1"}}, namespace)
+	require.Error(t, err)
+	_, err = NewRegistry([]Tenant{
+		{Name: "app1", Namespaces: []string{"ns"}},
+		{Name: "app2", Namespaces: []string{"ns"}},
+	}, namespace)
+	require.Error(t, err)
+	_, err = NewRegistry([]Tenant{
+		{Name: "app1", APIKey: "key", Namespaces: []string{"ns1"}},
+		{Name: "app2", APIKey: "key", Namespaces: []string{"ns2"}},
+	}, namespace)
+	require.Error(t, err)
+}
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	require.False(t, ok)
+	name, ok := FromContext(SetToContext(context.Background(), "app1"))
+	require.True(t, ok)
+	require.Equal(t, "app1", name)
+}
@@ -0,0 +1,323 @@
+package throttle
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/protocol"
+)
+
+// DefaultMaxQueueSize is a default max number of messages waiting for delivery.
+const DefaultMaxQueueSize = 1000
+
+// Config of outbound throttling.
+type Config struct {
+	// Rate is a number of push messages per second delivered to a client.
+	// Zero value means no rate limit, throttling is disabled in this case
+	// unless FairChannels is on.
+	Rate float64
+	// Burst is a number of push messages which can be delivered at once
+	// after a client was idle. By default 1.
+	Burst int
+	// MaxQueueSize is a max number of push messages waiting for delivery.
+	// Client disconnected with DisconnectSlow when queue is full.
+	// By default DefaultMaxQueueSize used.
+	MaxQueueSize int
+	// Coalesce reports whether only the latest publication in channel must be
+	// delivered. For such channels publication waiting in queue replaced by a
+	// newer one instead of queueing both.
+	Coalesce func(channel string) bool
+	// FairChannels enables separate queue for every channel. Queues drained
+	// in round-robin order, so channel flooding a connection does not delay
+	// messages of other channels. Order of messages inside channel kept.
+	//
+	// With zero Rate transport works as pure round-robin scheduler: every
+	// push message is queued and written by a separate goroutine as fast as
+	// connection accepts it. Queues only build up while connection write
+	// blocks, so fairness has effect on slow connections only, and every push
+	// message costs a hand-off to writing goroutine. MaxQueueSize still
+	// applies.
+	FairChannels bool
+}
+
+// Enabled returns true if throttling should be applied.
+func (c Config) Enabled() bool {
+	return c.Rate > 0 || c.FairChannels
+}
+
+// rateLimited returns true if push messages rate must be limited.
+func (c Config) rateLimited() bool {
+	return c.Rate > 0
+}
+
+type queueItem struct {
+	data []byte
+	// channel set for coalesced publications.
+	channel string
+	// key of sub-queue item belongs to.
+	key string
+}
+
+// transport wraps centrifuge.Transport and limits rate of push messages written
+// to it with a token bucket. Push messages which exceed the rate wait in queue
+// and written by a separate goroutine as soon as bucket has tokens. Replies to
+// client commands are never delayed. Queue consists of sub-queues (one per
+// channel with FairChannels on, single one otherwise) drained in round-robin
+// order. Without rate limit all push messages go through queue.
+type transport struct {
+	centrifuge.Transport
+	config Config
+
+	mu        sync.Mutex
+	tokens    float64
+	updatedAt time.Time
+	queues    map[string][]*queueItem
+	// order of keys of non-empty sub-queues to drain.
+	order  []string
+	queued int
+	// coalesced contains queued publications of channels with coalescing on.
+	coalesced map[string]*queueItem
+	draining  bool
+	closed    bool
+	closeCh   chan struct{}
+}
+
+// NewTransport wraps transport to throttle push messages. Original transport
+// returned if throttling not enabled in Config.
+func NewTransport(t centrifuge.Transport, config Config) centrifuge.Transport {
+	if !config.Enabled() {
+		return t
+	}
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+	if config.MaxQueueSize <= 0 {
+		config.MaxQueueSize = DefaultMaxQueueSize
+	}
+	return &transport{
+		Transport: t,
+		config:    config,
+		tokens:    float64(config.Burst),
+		updatedAt: time.Now(),
+		queues:    map[string][]*queueItem{},
+		coalesced: map[string]*queueItem{},
+		closeCh:   make(chan struct{}),
+	}
+}
+
+// refill must be called with mu held.
+func (t *transport) refill(now time.Time) {
+	t.tokens += now.Sub(t.updatedAt).Seconds() * t.config.Rate
+	if t.tokens > float64(t.config.Burst) {
+		t.tokens = float64(t.config.Burst)
+	}
+	t.updatedAt = now
+}
+
+// wait returns time to wait until next token available, must be called
+// with mu held.
+func (t *transport) wait() time.Duration {
+	return time.Duration((1 - t.tokens) / t.config.Rate * float64(time.Second))
+}
+
+// Write data to transport.
+func (t *transport) Write(data []byte) error {
+	ok, disconnect := t.process(data)
+	if disconnect != nil {
+		go func() { _ = t.Close(disconnect) }()
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	return t.Transport.Write(data)
+}
+
+// WriteMany data to transport.
+func (t *transport) WriteMany(messages ...[]byte) error {
+	allowed := messages[:0:0]
+	for _, data := range messages {
+		ok, disconnect := t.process(data)
+		if disconnect != nil {
+			go func() { _ = t.Close(disconnect) }()
+			return nil
+		}
+		if ok {
+			allowed = append(allowed, data)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	return t.Transport.WriteMany(allowed...)
+}
+
+// process returns true if data can be written immediately, otherwise data
+// added to queue.
+func (t *transport) process(data []byte) (bool, *centrifuge.Disconnect) {
+	channel, isPush, isPublication := parseMessage(t.Transport, data)
+	if !isPush {
+		return true, nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false, nil
+	}
+	if t.config.rateLimited() && t.queued == 0 {
+		t.refill(time.Now())
+		if t.tokens >= 1 {
+			t.tokens--
+			return true, nil
+		}
+	}
+	coalesce := isPublication && t.config.Coalesce != nil && t.config.Coalesce(channel)
+	if coalesce {
+		if item, ok := t.coalesced[channel]; ok {
+			// Replace waiting publication with the latest one.
+			item.data = data
+			return false, nil
+		}
+	}
+	if t.queued >= t.config.MaxQueueSize {
+		return false, centrifuge.DisconnectSlow
+	}
+	item := &queueItem{data: data}
+	if t.config.FairChannels {
+		item.key = channel
+	}
+	if coalesce {
+		item.channel = channel
+		t.coalesced[channel] = item
+	}
+	t.push(item)
+	if !t.draining {
+		t.draining = true
+		go t.drain()
+	}
+	return false, nil
+}
+
+// push adds item to its sub-queue, must be called with mu held.
+func (t *transport) push(item *queueItem) {
+	q, ok := t.queues[item.key]
+	if !ok {
+		t.order = append(t.order, item.key)
+	}
+	t.queues[item.key] = append(q, item)
+	t.queued++
+}
+
+// pop takes item from next sub-queue in round-robin order, must be called
+// with mu held and non-empty queue.
+func (t *transport) pop() *queueItem {
+	key := t.order[0]
+	t.order = t.order[1:]
+	q := t.queues[key]
+	item := q[0]
+	q[0] = nil
+	q = q[1:]
+	if len(q) == 0 {
+		delete(t.queues, key)
+	} else {
+		t.queues[key] = q
+		t.order = append(t.order, key)
+	}
+	t.queued--
+	return item
+}
+
+func (t *transport) drain() {
+	for {
+		t.mu.Lock()
+		if t.closed || t.queued == 0 {
+			t.draining = false
+			t.mu.Unlock()
+			return
+		}
+		if t.config.rateLimited() {
+			t.refill(time.Now())
+			if t.tokens < 1 {
+				wait := t.wait()
+				t.mu.Unlock()
+				tm := time.NewTimer(wait)
+				select {
+				case <-tm.C:
+				case <-t.closeCh:
+					tm.Stop()
+				}
+				continue
+			}
+			t.tokens--
+		}
+		item := t.pop()
+		if item.channel != "" {
+			delete(t.coalesced, item.channel)
+		}
+		t.mu.Unlock()
+		if err := t.Transport.Write(item.data); err != nil {
+			go func() { _ = t.Close(centrifuge.DisconnectWriteError) }()
+		}
+	}
+}
+
+// Close closes transport.
+func (t *transport) Close(disconnect *centrifuge.Disconnect) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	t.queues = nil
+	t.order = nil
+	t.coalesced = nil
+	close(t.closeCh)
+	t.mu.Unlock()
+	return t.Transport.Close(disconnect)
+}
+
+type jsonReply struct {
+	ID     uint32          `json:"id"`
+	Result json.RawMessage `json:"result"`
+}
+
+type jsonPush struct {
+	Type    protocol.Push_PushType `json:"type"`
+	Channel string                 `json:"channel"`
+}
+
+// parseMessage extracts push channel from data written to transport. Data
+// of bidirectional transports is a Reply, data of unidirectional transports
+// is a Push.
+func parseMessage(t centrifuge.TransportInfo, data []byte) (string, bool, bool) {
+	pushData := data
+	if t.Protocol() == centrifuge.ProtocolTypeJSON {
+		if !t.Unidirectional() {
+			var reply jsonReply
+			if err := json.Unmarshal(data, &reply); err != nil || reply.ID > 0 {
+				return "", false, false
+			}
+			pushData = reply.Result
+		}
+		var push jsonPush
+		if err := json.Unmarshal(pushData, &push); err != nil {
+			return "", false, false
+		}
+		return push.Channel, true, push.Type == protocol.Push_PUBLICATION
+	}
+	if !t.Unidirectional() {
+		var reply protocol.Reply
+		if err := reply.UnmarshalVT(data); err != nil || reply.Id > 0 {
+			return "", false, false
+		}
+		pushData = reply.Result
+	}
+	var push protocol.Push
+	if err := push.UnmarshalVT(pushData); err != nil {
+		return "", false, false
+	}
+	return push.Channel, true, push.Type == protocol.Push_PUBLICATION
+}
@@ -0,0 +1,175 @@
+package throttle
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+type testTransport struct {
+	mu           sync.Mutex
+	unidirection bool
+	messages     [][]byte
+	disconnect   *centrifuge.Disconnect
+	// unblock set to make writes block until it's closed.
+	unblock chan struct{}
+	// writing notified when write started.
+	writing chan struct{}
+}
+
+func (t *testTransport) Name() string                      { return "test" }
+func (t *testTransport) Protocol() centrifuge.ProtocolType { return centrifuge.ProtocolTypeJSON }
+func (t *testTransport) Unidirectional() bool              { return t.unidirection }
+func (t *testTransport) DisabledPushFlags() uint64         { return 0 }
+func (t *testTransport) Write(data []byte) error           { return t.WriteMany(data) }
+func (t *testTransport) Close(d *centrifuge.Disconnect) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.disconnect = d
+	return nil
+}
+
+func (t *testTransport) WriteMany(messages ...[]byte) error {
+	if t.unblock != nil {
+		t.writing <- struct{}{}
+		<-t.unblock
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = append(t.messages, messages...)
+	return nil
+}
+
+func (t *testTransport) written() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var result []string
+	for _, m := range t.messages {
+		result = append(result, string(m))
+	}
+	return result
+}
+
+func TestNewTransportDisabled(t *testing.T) {
+	inner := &testTransport{}
+	require.Equal(t, inner, NewTransport(inner, Config{}))
+}
+
+func TestTransportThrottle(t *testing.T) {
+	inner := &testTransport{unidirection: true}
+	tr := NewTransport(inner, Config{Rate: 100, Burst: 2})
+	for _, data := range []string{`{"channel":"a","data":1}`, `{"channel":"a","data":2}`, `{"channel":"a","data":3}`} {
+		require.NoError(t, tr.Write([]byte(data)))
+	}
+	// Only burst delivered immediately.
+	require.Len(t, inner.written(), 2)
+	require.Eventually(t, func() bool {
+		return len(inner.written()) == 3
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, `{"channel":"a","data":3}`, inner.written()[2])
+}
+
+func TestTransportCoalesce(t *testing.T) {
+	inner := &testTransport{unidirection: true}
+	tr := NewTransport(inner, Config{Rate: 10, Burst: 1, Coalesce: func(ch string) bool {
+		return ch == "cursor"
+	}})
+	for _, data := range []string{
+		`{"channel":"cursor","data":1}`,
+		`{"channel":"cursor","data":2}`,
+		`{"channel":"chat","data":1}`,
+		`{"channel":"cursor","data":3}`,
+	} {
+		require.NoError(t, tr.Write([]byte(data)))
+	}
+	require.Eventually(t, func() bool {
+		return len(inner.written()) == 3
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, []string{
+		`{"channel":"cursor","data":1}`,
+		`{"channel":"cursor","data":3}`,
+		`{"channel":"chat","data":1}`,
+	}, inner.written())
+}
+
+func TestTransportFairChannels(t *testing.T) {
+	inner := &testTransport{unidirection: true}
+	tr := NewTransport(inner, Config{Rate: 100, Burst: 1, FairChannels: true})
+	for _, data := range []string{
+		`{"channel":"flood","data":1}`,
+		`{"channel":"flood","data":2}`,
+		`{"channel":"flood","data":3}`,
+		`{"channel":"flood","data":4}`,
+		`{"channel":"chat","data":1}`,
+		`{"channel":"chat","data":2}`,
+	} {
+		require.NoError(t, tr.Write([]byte(data)))
+	}
+	require.Eventually(t, func() bool {
+		return len(inner.written()) == 6
+	}, time.Second, 5*time.Millisecond)
+	// First message written immediately, queued ones drained in round-robin
+	// order keeping order inside channel.
+	require.Equal(t, []string{
+		`{"channel":"flood","data":1}`,
+		`{"channel":"flood","data":2}`,
+		`{"channel":"chat","data":1}`,
+		`{"channel":"flood","data":3}`,
+		`{"channel":"chat","data":2}`,
+		`{"channel":"flood","data":4}`,
+	}, inner.written())
+}
+
+func TestTransportFairChannelsWithoutRate(t *testing.T) {
+	inner := &testTransport{unidirection: true, unblock: make(chan struct{}), writing: make(chan struct{}, 6)}
+	tr := NewTransport(inner, Config{FairChannels: true})
+	require.NotEqual(t, inner, tr)
+	require.NoError(t, tr.Write([]byte(`{"channel":"flood","data":1}`)))
+	// Messages queue up while connection write blocks.
+	<-inner.writing
+	for _, data := range []string{
+		`{"channel":"flood","data":2}`,
+		`{"channel":"flood","data":3}`,
+		`{"channel":"chat","data":1}`,
+		`{"channel":"chat","data":2}`,
+	} {
+		require.NoError(t, tr.Write([]byte(data)))
+	}
+	close(inner.unblock)
+	require.Eventually(t, func() bool {
+		return len(inner.written()) == 5
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, []string{
+		`{"channel":"flood","data":1}`,
+		`{"channel":"flood","data":2}`,
+		`{"channel":"chat","data":1}`,
+		`{"channel":"flood","data":3}`,
+		`{"channel":"chat","data":2}`,
+	}, inner.written())
+}
+
+func TestTransportRepliesNotThrottled(t *testing.T) {
+	inner := &testTransport{}
+	tr := NewTransport(inner, Config{Rate: 0.001, Burst: 1})
+	require.NoError(t, tr.Write([]byte(`{"result":{"channel":"a"}}`)))
+	require.NoError(t, tr.Write([]byte(`{"result":{"channel":"a"}}`)))
+	require.NoError(t, tr.WriteMany([]byte(`{"id":1,"result":{}}`), []byte(`{"id":2,"result":{}}`)))
+	require.Equal(t, []string{`{"result":{"channel":"a"}}`, `{"id":1,"result":{}}`, `{"id":2,"result":{}}`}, inner.written())
+	require.NoError(t, tr.Close(nil))
+}
+
+func TestTransportQueueOverflow(t *testing.T) {
+	inner := &testTransport{unidirection: true}
+	tr := NewTransport(inner, Config{Rate: 0.001, Burst: 1, MaxQueueSize: 1})
+	for i := 0; i < 3; i++ {
+		require.NoError(t, tr.Write([]byte(`{"channel":"a"}`)))
+	}
+	require.Eventually(t, func() bool {
+		inner.mu.Lock()
+		defer inner.mu.Unlock()
+		return inner.disconnect == centrifuge.DisconnectSlow
+	}, time.Second, 5*time.Millisecond)
+}
@@ -77,6 +77,21 @@ func (s *Shard) ExecTyped(request *tarantool.Request, result interface{}) error
 	return conn.ExecTyped(request, result)
 }
 
+// Ping checks that current leader of shard is available.
+func (s *Shard) Ping(ctx context.Context) error {
+	conn, err := s.mc.LeaderConn()
+	if err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, tarantool.Ping())
+	return err
+}
+
+// Addresses returns Tarantool addresses shard connects to.
+func (s *Shard) Addresses() []string {
+	return s.config.Addresses
+}
+
 func (s *Shard) pubSubConn() (*tarantool.Connection, func(), error) {
 	conn, err := s.mc.NewLeaderConn(tarantool.Opts{
 		ConnectTimeout: defaultConnectTimeout,
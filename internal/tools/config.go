@@ -32,21 +32,100 @@ var jsonConfigTemplate = `{
 }
 `
 
-var tomlConfigTemplate = `token_hmac_secret_key = "{{.TokenSecret}}"
+var tomlConfigTemplate = `# Secret key to verify HMAC SHA-256 connection and subscription JWTs,
+# generate tokens for testing with "centrifugo gentoken".
+token_hmac_secret_key = "{{.TokenSecret}}"
+
+# Admin web interface, enable with admin = true.
 admin_password = "{{.AdminPassword}}"
 admin_secret = "{{.AdminSecret}}"
+# admin = true
+
+# Key to authorize server API requests (Authorization: apikey <KEY>).
 api_key = "{{.APIKey}}"
+
+# Origins allowed to connect from browsers, ex. ["http://localhost:3000"].
 allowed_origins = []
+
+# Port and log level, same as --port and --log_level flags.
+# port = 8000
+# log_level = "info"
+
+# Engine: memory (single node), redis or tarantool.
+# engine = "memory"
+# redis_address = "redis://127.0.0.1:6379"
+
+# Default channel options, can be redefined in namespaces.
+# publish = false
+# presence = false
+# join_leave = false
+# history_size = 0
+# history_ttl = "0s"
+# recover = false
+
+# Max number of channels one client can subscribe to.
+# client_channel_limit = 128
+
+# Channel namespaces, channel "chat:index" belongs to namespace "chat".
+# [[namespaces]]
+# name = "chat"
+# presence = true
+# history_size = 10
+# history_ttl = "300s"
+#
+# Channels matching pattern belong to namespace without name prefix.
+# [[namespaces]]
+# name = "rooms"
+# channel_pattern = "room-*"
 `
 
-var yamlConfigTemplate = `token_hmac_secret_key: {{.TokenSecret}}
+var yamlConfigTemplate = `# Secret key to verify HMAC SHA-256 connection and subscription JWTs,
+# generate tokens for testing with "centrifugo gentoken".
+token_hmac_secret_key: {{.TokenSecret}}
+
+# Admin web interface, enable with admin: true.
 admin_password: {{.AdminPassword}}
 admin_secret: {{.AdminSecret}}
+# admin: true
+
+# Key to authorize server API requests (Authorization: apikey <KEY>).
 api_key: {{.APIKey}}
+
+# Origins allowed to connect from browsers, ex. ["http://localhost:3000"].
 allowed_origins: []
+
+# Port and log level, same as --port and --log_level flags.
+# port: 8000
+# log_level: info
+
+# Engine: memory (single node), redis or tarantool.
+# engine: memory
+# redis_address: redis://127.0.0.1:6379
+
+# Default channel options, can be redefined in namespaces.
+# publish: false
+# presence: false
+# join_leave: false
+# history_size: 0
+# history_ttl: 0s
+# recover: false
+
+# Max number of channels one client can subscribe to.
+# client_channel_limit: 128
+
+# Channel namespaces, channel "chat:index" belongs to namespace "chat".
+# namespaces:
+#   - name: chat
+#     presence: true
+#     history_size: 10
+#     history_ttl: 300s
+#   # Channels matching pattern belong to namespace without name prefix.
+#   - name: rooms
+#     channel_pattern: room-*
 `
 
-// GenerateConfig generates configuration file at provided path.
+// GenerateConfig generates configuration file at provided path. TOML and YAML
+// configurations contain comments with commonly used options.
 func GenerateConfig(f string) error {
 	exists, err := pathExists(f)
 	if err != nil {
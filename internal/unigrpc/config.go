@@ -1,3 +1,8 @@
 package unigrpc
 
-type Config struct{}
+import "github.com/centrifugal/centrifugo/v3/internal/throttle"
+
+type Config struct {
+	// Throttle configures rate limiting of messages sent to clients.
+	Throttle throttle.Config
+}
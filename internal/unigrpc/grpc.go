@@ -1,8 +1,10 @@
 package unigrpc
 
 import (
+	"context"
 	"time"
 
+	"github.com/centrifugal/centrifugo/v3/internal/throttle"
 	"github.com/centrifugal/centrifugo/v3/internal/unigrpc/unistream"
 
 	"github.com/centrifugal/centrifuge"
@@ -32,9 +34,11 @@ func NewService(n *centrifuge.Node, c Config) *Service {
 
 // Consume is a unidirectional server->client stream with real-time data.
 func (s *Service) Consume(req *unistream.ConnectRequest, stream unistream.CentrifugoUniStream_ConsumeServer) error {
-	streamDataCh := make(chan rawFrame)
-	transport := newGRPCTransport(stream, streamDataCh)
+	return s.consume(stream.Context(), req, newGRPCTransport("uni_grpc", stream))
+}
 
+// consume connects client over transport and blocks until connection closed.
+func (s *Service) consume(ctx context.Context, req *unistream.ConnectRequest, transport *grpcTransport) error {
 	connectRequest := centrifuge.ConnectRequest{
 		Token:   req.Token,
 		Data:    req.Data,
@@ -53,7 +57,7 @@ func (s *Service) Consume(req *unistream.ConnectRequest, stream unistream.Centri
 		connectRequest.Subs = subs
 	}
 
-	c, closeFn, err := centrifuge.NewClient(stream.Context(), s.node, transport)
+	c, closeFn, err := centrifuge.NewClient(ctx, s.node, throttle.NewTransport(transport, s.config.Throttle))
 	if err != nil {
 		return err
 	}
@@ -70,7 +74,7 @@ func (s *Service) Consume(req *unistream.ConnectRequest, stream unistream.Centri
 		select {
 		case <-transport.closeCh:
 			return nil
-		case <-stream.Context().Done():
+		case <-ctx.Done():
 			return nil
 		}
 	}
@@ -0,0 +1,143 @@
+package unigrpc
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// http2Preface starts every HTTP/2 connection with prior knowledge, this is
+// how gRPC clients connect without TLS.
+var http2Preface = []byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n")
+
+// prefaceReadTimeout limits time to read connection preface.
+const prefaceReadTimeout = 10 * time.Second
+
+// ServeWithWeb serves GRPC server and web handler on the same listener.
+//
+// With TLS config all connections are served by HTTP server which supports
+// HTTP/2 negotiated over ALPN, gRPC requests passed from handler to GRPC
+// server, so handler must be created with GRPC server in this case. Without
+// TLS connections starting with HTTP/2 preface are served by GRPC server
+// directly and other connections served by HTTP server.
+func ServeWithWeb(l net.Listener, grpcServer *grpc.Server, handler http.Handler, tlsConfig *tls.Config) error {
+	httpServer := &http.Server{Handler: handler}
+	if tlsConfig != nil {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+		return httpServer.Serve(tls.NewListener(l, tlsConfig))
+	}
+
+	grpcListener := newChanListener(l.Addr())
+	httpListener := newChanListener(l.Addr())
+	errCh := make(chan error, 2)
+	go func() { errCh <- grpcServer.Serve(grpcListener) }()
+	go func() { errCh <- httpServer.Serve(httpListener) }()
+	defer func() {
+		_ = grpcListener.Close()
+		_ = httpListener.Close()
+	}()
+
+	acceptErrCh := make(chan error, 1)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				acceptErrCh <- err
+				return
+			}
+			go dispatchConn(conn, grpcListener, httpListener)
+		}
+	}()
+	select {
+	case err := <-acceptErrCh:
+		return err
+	case err := <-errCh:
+		return err
+	}
+}
+
+// dispatchConn reads connection preface and passes connection to GRPC or
+// HTTP listener.
+func dispatchConn(conn net.Conn, grpcListener *chanListener, httpListener *chanListener) {
+	_ = conn.SetReadDeadline(time.Now().Add(prefaceReadTimeout))
+	preface := make([]byte, len(http2Preface))
+	n, err := io.ReadFull(conn, preface)
+	_ = conn.SetReadDeadline(time.Time{})
+	if err != nil && n == 0 {
+		_ = conn.Close()
+		return
+	}
+	c := &prefixConn{Conn: conn, prefix: preface[:n]}
+	if bytes.Equal(preface[:n], http2Preface) {
+		grpcListener.push(c)
+	} else {
+		httpListener.push(c)
+	}
+}
+
+// prefixConn returns already read prefix before reading from connection.
+type prefixConn struct {
+	net.Conn
+	prefix []byte
+}
+
+func (c *prefixConn) Read(b []byte) (int, error) {
+	if len(c.prefix) > 0 {
+		n := copy(b, c.prefix)
+		c.prefix = c.prefix[n:]
+		return n, nil
+	}
+	return c.Conn.Read(b)
+}
+
+var errListenerClosed = errors.New("listener closed")
+
+// chanListener is a net.Listener which accepts connections pushed to it.
+type chanListener struct {
+	addr    net.Addr
+	connCh  chan net.Conn
+	closeCh chan struct{}
+	once    sync.Once
+}
+
+func newChanListener(addr net.Addr) *chanListener {
+	return &chanListener{
+		addr:    addr,
+		connCh:  make(chan net.Conn),
+		closeCh: make(chan struct{}),
+	}
+}
+
+func (l *chanListener) push(conn net.Conn) {
+	select {
+	case l.connCh <- conn:
+	case <-l.closeCh:
+		_ = conn.Close()
+	}
+}
+
+func (l *chanListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.connCh:
+		return conn, nil
+	case <-l.closeCh:
+		return nil, errListenerClosed
+	}
+}
+
+func (l *chanListener) Close() error {
+	l.once.Do(func() { close(l.closeCh) })
+	return nil
+}
+
+func (l *chanListener) Addr() net.Addr {
+	return l.addr
+}
@@ -3,30 +3,33 @@ package unigrpc
 import (
 	"sync"
 
-	"github.com/centrifugal/centrifugo/v3/internal/unigrpc/unistream"
-
 	"github.com/centrifugal/centrifuge"
 )
 
+// messageSender sends messages of stream.
+type messageSender interface {
+	SendMsg(m interface{}) error
+}
+
 // grpcTransport wraps a stream.
 type grpcTransport struct {
-	mu           sync.RWMutex
-	stream       unistream.CentrifugoUniStream_ConsumeServer
-	closed       bool
-	closeCh      chan struct{}
-	streamDataCh chan rawFrame
+	mu      sync.RWMutex
+	name    string
+	stream  messageSender
+	closed  bool
+	closeCh chan struct{}
 }
 
-func newGRPCTransport(stream unistream.CentrifugoUniStream_ConsumeServer, streamDataCh chan rawFrame) *grpcTransport {
+func newGRPCTransport(name string, stream messageSender) *grpcTransport {
 	return &grpcTransport{
-		stream:       stream,
-		streamDataCh: streamDataCh,
-		closeCh:      make(chan struct{}),
+		name:    name,
+		stream:  stream,
+		closeCh: make(chan struct{}),
 	}
 }
 
 func (t *grpcTransport) Name() string {
-	return "uni_grpc"
+	return t.name
 }
 
 func (t *grpcTransport) Protocol() centrifuge.ProtocolType {
@@ -0,0 +1,264 @@
+package unigrpc
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/centrifugal/centrifugo/v3/internal/unigrpc/unistream"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ConsumePath is an HTTP path of Consume method.
+const ConsumePath = "/centrifugal.centrifugo.unistream.CentrifugoUniStream/Consume"
+
+// DefaultWebMaxRequestSize is a default value for WebConfig.MaxRequestSize.
+const DefaultWebMaxRequestSize = 64 * 1024
+
+// Content types of web protocols.
+const (
+	contentTypeGRPC        = "application/grpc"
+	contentTypeGRPCWeb     = "application/grpc-web"
+	contentTypeGRPCWebText = "application/grpc-web-text"
+	contentTypeConnect     = "application/connect+proto"
+)
+
+// Flags of message envelope.
+const (
+	flagData        byte = 0x00
+	flagEndStream   byte = 0x02
+	flagWebTrailers byte = 0x80
+)
+
+// Status codes sent in trailers.
+const (
+	grpcStatusOK              = 0
+	grpcStatusInvalidArgument = 3
+	grpcStatusInternal        = 13
+)
+
+var connectErrorCodes = map[int]string{
+	grpcStatusInvalidArgument: "invalid_argument",
+	grpcStatusInternal:        "internal",
+}
+
+type webProtocol int
+
+const (
+	protocolGRPCWeb webProtocol = iota + 1
+	protocolGRPCWebText
+	protocolConnect
+)
+
+// WebConfig of WebHandler.
+type WebConfig struct {
+	// MaxRequestSize is a max size of ConnectRequest. By default
+	// DefaultWebMaxRequestSize used.
+	MaxRequestSize int
+}
+
+// WebHandler serves Consume stream to browsers over gRPC-Web (binary and
+// text) and Connect streaming protocols, so unidirectional GRPC can be used
+// through standard HTTP/1.1 ingress without proxies translating gRPC-Web. Other
+// gRPC requests passed to GRPC server if set.
+type WebHandler struct {
+	service    *Service
+	grpcServer http.Handler
+	config     WebConfig
+}
+
+// NewWebHandler creates WebHandler.
+func NewWebHandler(service *Service, grpcServer http.Handler, c WebConfig) *WebHandler {
+	if c.MaxRequestSize == 0 {
+		c.MaxRequestSize = DefaultWebMaxRequestSize
+	}
+	return &WebHandler{service: service, grpcServer: grpcServer, config: c}
+}
+
+func detectWebProtocol(contentType string) (webProtocol, bool) {
+	switch {
+	case strings.HasPrefix(contentType, contentTypeGRPCWebText):
+		return protocolGRPCWebText, true
+	case strings.HasPrefix(contentType, contentTypeGRPCWeb):
+		return protocolGRPCWeb, true
+	case strings.HasPrefix(contentType, contentTypeConnect):
+		return protocolConnect, true
+	}
+	return 0, false
+}
+
+func (h *WebHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	contentType := r.Header.Get("Content-Type")
+	protocol, ok := detectWebProtocol(contentType)
+	if !ok {
+		if h.grpcServer != nil && r.ProtoMajor == 2 && strings.HasPrefix(contentType, contentTypeGRPC) {
+			h.grpcServer.ServeHTTP(w, r)
+			return
+		}
+		if r.Method == http.MethodOptions {
+			// CORS preflight request.
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if r.URL.Path != ConsumePath {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Expose-Headers", "grpc-status, grpc-message")
+	stream := &webStream{w: w, flusher: flusher, protocol: protocol}
+
+	req, err := h.readRequest(r, protocol)
+	if err != nil {
+		w.WriteHeader(http.StatusOK)
+		stream.finish(grpcStatusInvalidArgument, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	if err := h.service.consume(r.Context(), req, newGRPCTransport("uni_grpc_web", stream)); err != nil {
+		stream.finish(grpcStatusInternal, err.Error())
+		return
+	}
+	stream.finish(grpcStatusOK, "")
+}
+
+func (h *WebHandler) readRequest(r *http.Request, protocol webProtocol) (*unistream.ConnectRequest, error) {
+	var body io.Reader = io.LimitReader(r.Body, int64(h.config.MaxRequestSize)*2+5)
+	if protocol == protocolGRPCWebText {
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading request: %w", err)
+	}
+	message, err := decodeEnvelope(data, h.config.MaxRequestSize)
+	if err != nil {
+		return nil, err
+	}
+	var req unistream.ConnectRequest
+	if err := proto.Unmarshal(message, &req); err != nil {
+		return nil, fmt.Errorf("error decoding request: %w", err)
+	}
+	return &req, nil
+}
+
+// decodeEnvelope returns message of the first length-prefixed envelope.
+func decodeEnvelope(data []byte, maxSize int) ([]byte, error) {
+	if len(data) < 5 {
+		return nil, errors.New("malformed request envelope")
+	}
+	if data[0] != flagData {
+		return nil, errors.New("compressed requests not supported")
+	}
+	size := binary.BigEndian.Uint32(data[1:5])
+	if int(size) > maxSize {
+		return nil, errors.New("request too large")
+	}
+	if len(data) < 5+int(size) {
+		return nil, errors.New("malformed request envelope")
+	}
+	return data[5 : 5+size], nil
+}
+
+func encodeEnvelope(flag byte, message []byte) []byte {
+	frame := make([]byte, 5+len(message))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(message)))
+	copy(frame[5:], message)
+	return frame
+}
+
+// webStream writes enveloped messages to HTTP response.
+type webStream struct {
+	mu       sync.Mutex
+	w        io.Writer
+	flusher  http.Flusher
+	protocol webProtocol
+	finished bool
+}
+
+var errStreamFinished = errors.New("stream finished")
+
+// SendMsg writes rawFrame to response.
+func (s *webStream) SendMsg(m interface{}) error {
+	frame, ok := m.(rawFrame)
+	if !ok {
+		return fmt.Errorf("unexpected message type: %T", m)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		return errStreamFinished
+	}
+	return s.write(flagData, frame)
+}
+
+func (s *webStream) write(flag byte, message []byte) error {
+	frame := encodeEnvelope(flag, message)
+	if s.protocol == protocolGRPCWebText {
+		frame = []byte(base64.StdEncoding.EncodeToString(frame))
+	}
+	if _, err := s.w.Write(frame); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+type connectEndStream struct {
+	Error *connectError `json:"error,omitempty"`
+}
+
+type connectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// finish writes status of stream, gRPC-Web trailers or Connect end of stream
+// message.
+func (s *webStream) finish(status int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		return
+	}
+	s.finished = true
+	var data []byte
+	var flag byte
+	if s.protocol == protocolConnect {
+		flag = flagEndStream
+		var end connectEndStream
+		if status != grpcStatusOK {
+			end.Error = &connectError{Code: connectErrorCodes[status], Message: message}
+		}
+		data, _ = json.Marshal(end)
+	} else {
+		flag = flagWebTrailers
+		data = []byte(fmt.Sprintf("grpc-status: %d\r\ngrpc-message: %s\r\n", status, message))
+	}
+	_ = s.write(flag, data)
+}
@@ -0,0 +1,156 @@
+package unigrpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+	"github.com/centrifugal/centrifugo/v3/internal/unigrpc/unistream"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+func testWebNode(t *testing.T) *centrifuge.Node {
+	node := tools.NodeWithMemoryEngine()
+	node.OnConnecting(func(ctx context.Context, _ centrifuge.ConnectEvent) (centrifuge.ConnectReply, error) {
+		return centrifuge.ConnectReply{Credentials: &centrifuge.Credentials{UserID: "42"}}, nil
+	})
+	t.Cleanup(func() { _ = node.Shutdown(context.Background()) })
+	return node
+}
+
+func readEnvelope(t *testing.T, r io.Reader) (byte, []byte) {
+	header := make([]byte, 5)
+	_, err := io.ReadFull(r, header)
+	require.NoError(t, err)
+	data := make([]byte, binary.BigEndian.Uint32(header[1:]))
+	_, err = io.ReadFull(r, data)
+	require.NoError(t, err)
+	return header[0], data
+}
+
+func connectRequestBody(t *testing.T) []byte {
+	req, err := proto.Marshal(&unistream.ConnectRequest{Name: "test"})
+	require.NoError(t, err)
+	return encodeEnvelope(flagData, req)
+}
+
+func TestDecodeEnvelope(t *testing.T) {
+	data, err := decodeEnvelope(encodeEnvelope(flagData, []byte("test")), 10)
+	require.NoError(t, err)
+	require.Equal(t, []byte("test"), data)
+	_, err = decodeEnvelope(encodeEnvelope(flagData, []byte("test")), 2)
+	require.Error(t, err)
+	_, err = decodeEnvelope(encodeEnvelope(0x01, []byte("test")), 10)
+	require.Error(t, err)
+	_, err = decodeEnvelope([]byte{0, 0, 0, 0, 10, 1}, 10)
+	require.Error(t, err)
+}
+
+func TestWebHandlerGRPCWeb(t *testing.T) {
+	node := testWebNode(t)
+	server := httptest.NewServer(NewWebHandler(NewService(node, Config{}), nil, WebConfig{}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+ConsumePath, bytes.NewReader(connectRequestBody(t)))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	flag, data := readEnvelope(t, resp.Body)
+	require.Equal(t, flagData, flag)
+	var push unistream.Push
+	require.NoError(t, proto.Unmarshal(data, &push))
+	require.Equal(t, unistream.Push_CONNECT, push.Type)
+}
+
+func TestWebHandlerGRPCWebText(t *testing.T) {
+	node := testWebNode(t)
+	server := httptest.NewServer(NewWebHandler(NewService(node, Config{}), nil, WebConfig{}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	body := base64.StdEncoding.EncodeToString(connectRequestBody(t))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+ConsumePath, bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/grpc-web-text")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	flag, data := readEnvelope(t, base64.NewDecoder(base64.StdEncoding, bufio.NewReader(resp.Body)))
+	require.Equal(t, flagData, flag)
+	var push unistream.Push
+	require.NoError(t, proto.Unmarshal(data, &push))
+	require.Equal(t, unistream.Push_CONNECT, push.Type)
+}
+
+func TestWebHandlerConnectInvalidRequest(t *testing.T) {
+	node := testWebNode(t)
+	server := httptest.NewServer(NewWebHandler(NewService(node, Config{}), nil, WebConfig{}))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+ConsumePath, "application/connect+proto", bytes.NewReader([]byte{1, 2}))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	flag, data := readEnvelope(t, resp.Body)
+	require.Equal(t, flagEndStream, flag)
+	require.Contains(t, string(data), `"code":"invalid_argument"`)
+
+	resp, err = http.Post(server.URL+"/unknown", "application/grpc-web", bytes.NewReader(nil))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestServeWithWeb(t *testing.T) {
+	node := testWebNode(t)
+	service := NewService(node, Config{})
+	//nolint:staticcheck
+	grpcServer := grpc.NewServer(grpc.CustomCodec(&RawCodec{}))
+	require.NoError(t, RegisterService(grpcServer, service))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = l.Close() }()
+	go func() { _ = ServeWithWeb(l, grpcServer, NewWebHandler(service, grpcServer, WebConfig{}), nil) }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Native gRPC client.
+	conn, err := grpc.DialContext(ctx, l.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	stream, err := unistream.NewCentrifugoUniStreamClient(conn).Consume(ctx, &unistream.ConnectRequest{})
+	require.NoError(t, err)
+	push, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, unistream.Push_CONNECT, push.Type)
+
+	// gRPC-Web client on the same port.
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+l.Addr().String()+ConsumePath, bytes.NewReader(connectRequestBody(t)))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	flag, _ := readEnvelope(t, resp.Body)
+	require.Equal(t, flagData, flag)
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/centrifugal/centrifugo/v3/internal/throttle"
+
 	"github.com/centrifugal/centrifuge"
 	"github.com/centrifugal/protocol"
 )
@@ -21,6 +23,10 @@ func NewHandler(n *centrifuge.Node, c Config) *Handler {
 	}
 }
 
+// messageDelimiter separates messages in stream. Messages themselves are written
+// as is since their data is shared between all connections subscribed to a channel.
+var messageDelimiter = []byte("\n")
+
 func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodOptions {
 		w.WriteHeader(http.StatusOK)
@@ -52,8 +58,8 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	transport := newStreamTransport(r)
-	c, closeFn, err := centrifuge.NewClient(r.Context(), h.node, transport)
+	transport := newStreamTransport(r, h.config.WriteTimeout)
+	c, closeFn, err := centrifuge.NewClient(r.Context(), h.node, throttle.NewTransport(h.config.Delta.NewTransport(transport, r), h.config.Throttle))
 	if err != nil {
 		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error create client", map[string]interface{}{"error": err.Error(), "transport": "uni_http_stream"}))
 		return
@@ -127,7 +133,7 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			if err != nil {
 				return
 			}
-			_, err = w.Write([]byte("\n"))
+			_, err = w.Write(messageDelimiter)
 			if err != nil {
 				return
 			}
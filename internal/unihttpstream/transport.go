@@ -1,8 +1,10 @@
 package unihttpstream
 
 import (
+	"errors"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/centrifugal/centrifuge"
 )
@@ -14,10 +16,14 @@ type streamTransport struct {
 	disconnectCh chan *centrifuge.Disconnect
 	closedCh     chan struct{}
 	closed       bool
+	writeTimeout time.Duration
 }
 
-func newStreamTransport(req *http.Request) *streamTransport {
+var errWriteTimeout = errors.New("write timeout")
+
+func newStreamTransport(req *http.Request, writeTimeout time.Duration) *streamTransport {
 	return &streamTransport{
+		writeTimeout: writeTimeout,
 		messages:     make(chan []byte),
 		disconnectCh: make(chan *centrifuge.Disconnect),
 		closedCh:     make(chan struct{}),
@@ -53,11 +59,20 @@ func (t *streamTransport) WriteMany(messages ...[]byte) error {
 	if t.closed {
 		return nil
 	}
+	var timeoutCh <-chan time.Time
+	if t.writeTimeout > 0 {
+		timer := time.NewTimer(t.writeTimeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
 	for i := 0; i < len(messages); i++ {
 		select {
 		case t.messages <- messages[i]:
 		case <-t.closedCh:
 			return nil
+		case <-timeoutCh:
+			// Slow client, connection will be closed.
+			return errWriteTimeout
 		}
 	}
 	return nil
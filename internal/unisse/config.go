@@ -1,6 +1,20 @@
 package unisse
 
+import (
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/delta"
+	"github.com/centrifugal/centrifugo/v3/internal/throttle"
+)
+
 type Config struct {
 	// MaxRequestBodySize for POST requests when used.
 	MaxRequestBodySize int
+	// WriteTimeout is a max time of writing messages to connection. Slow client
+	// is disconnected. Zero means no timeout.
+	WriteTimeout time.Duration
+	// Throttle configures rate limiting of messages sent to clients.
+	Throttle throttle.Config
+	// Delta allows sending publications as patches to clients which ask for it.
+	Delta *delta.Manager
 }
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/centrifugal/centrifugo/v3/internal/throttle"
+
 	"github.com/centrifugal/centrifuge"
 	"github.com/centrifugal/protocol"
 )
@@ -63,8 +65,8 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	transport := newEventsourceTransport(r)
-	c, closeFn, err := centrifuge.NewClient(r.Context(), h.node, transport)
+	transport := newEventsourceTransport(r, h.config.WriteTimeout)
+	c, closeFn, err := centrifuge.NewClient(r.Context(), h.node, throttle.NewTransport(h.config.Delta.NewTransport(transport, r), h.config.Throttle))
 	if err != nil {
 		h.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error create client", map[string]interface{}{"error": err.Error(), "transport": "uni_sse"}))
 		return
@@ -140,7 +142,7 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			tick.Reset(pingInterval)
-			_, err = w.Write([]byte("data: " + string(data) + "\n\n"))
+			err = writeEvent(w, data)
 			if err != nil {
 				return
 			}
@@ -148,3 +150,22 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+var (
+	eventDataPrefix = []byte("data: ")
+	eventDataSuffix = []byte("\n\n")
+)
+
+// writeEvent writes message as SSE data event. Message data is shared between
+// all connections subscribed to a channel so it's written as is without copying
+// into per-connection buffer – ResponseWriter buffers output anyway.
+func writeEvent(w io.Writer, data []byte) error {
+	if _, err := w.Write(eventDataPrefix); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	_, err := w.Write(eventDataSuffix)
+	return err
+}
@@ -6,6 +6,9 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/delta"
+	"github.com/centrifugal/centrifugo/v3/internal/throttle"
 )
 
 // Defaults.
@@ -36,7 +39,9 @@ type Config struct {
 	WriteBufferSize int
 
 	// MessageSizeLimit sets the maximum size in bytes of allowed message from client.
-	// By default DefaultWebsocketMaxMessageSize will be used.
+	// Client sent larger message disconnected with disconnect.MessageSizeLimit.
+	// By default DefaultWebsocketMessageSizeLimit will be used, negative value
+	// turns off the limit.
 	MessageSizeLimit int
 
 	// CheckOrigin func to provide custom origin check logic.
@@ -60,6 +65,18 @@ type Config struct {
 
 	// UseWriteBufferPool enables using buffer pool for writes.
 	UseWriteBufferPool bool
+
+	// URLConnect allows clients to pass connect request in URL query instead of
+	// sending it as first message: either as JSON in cf_connect param, or as
+	// token and channel params (channel may be repeated). Client then does not
+	// need to write anything into connection.
+	URLConnect bool
+
+	// Throttle configures rate limiting of messages sent to clients.
+	Throttle throttle.Config
+
+	// Delta allows sending publications as patches to clients which ask for it.
+	Delta *delta.Manager
 }
 
 func sameHostOriginCheck() func(r *http.Request) bool {
@@ -6,6 +6,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/centrifugal/centrifugo/v3/internal/disconnect"
+	"github.com/centrifugal/centrifugo/v3/internal/throttle"
+
 	"github.com/centrifugal/centrifuge"
 	"github.com/centrifugal/protocol"
 	"github.com/gorilla/websocket"
@@ -60,7 +63,50 @@ type SubscribeRequest struct {
 	Offset  uint64 `json:"offset,omitempty"`
 }
 
+// URL query params to pass connect request when Config.URLConnect is on.
+const (
+	connectURLParam = "cf_connect"
+	tokenURLParam   = "token"
+	channelURLParam = "channel"
+)
+
+// connectRequestFromURL returns connect request passed in URL query, nil if
+// URL does not contain connect request.
+func connectRequestFromURL(r *http.Request) (*protocol.ConnectRequest, error) {
+	query := r.URL.Query()
+	if connectRequestString := query.Get(connectURLParam); connectRequestString != "" {
+		return protocol.NewJSONParamsDecoder().DecodeConnect([]byte(connectRequestString))
+	}
+	token := query.Get(tokenURLParam)
+	channels := query[channelURLParam]
+	if token == "" && len(channels) == 0 {
+		return nil, nil
+	}
+	req := &protocol.ConnectRequest{Token: token}
+	if len(channels) > 0 {
+		req.Subs = make(map[string]*protocol.SubscribeRequest, len(channels))
+		for _, ch := range channels {
+			if ch == "" {
+				continue
+			}
+			req.Subs[ch] = &protocol.SubscribeRequest{}
+		}
+	}
+	return req, nil
+}
+
 func (s *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	var urlConnectRequest *protocol.ConnectRequest
+	if s.config.URLConnect {
+		var err error
+		urlConnectRequest, err = connectRequestFromURL(r)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelInfo, "malformed connect request", map[string]interface{}{"error": err.Error()}))
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
 	compression := s.config.Compression
 	compressionLevel := s.config.CompressionLevel
 	compressionMinSize := s.config.CompressionMinSize
@@ -91,9 +137,6 @@ func (s *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		messageSizeLimit = DefaultWebsocketMessageSizeLimit
 	}
 
-	if messageSizeLimit > 0 {
-		conn.SetReadLimit(int64(messageSizeLimit))
-	}
 	if pingInterval > 0 {
 		pongWait := pingInterval * 10 / 9
 		_ = conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -124,7 +167,7 @@ func (s *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		ctxCh := make(chan struct{})
 		defer close(ctxCh)
 
-		c, closeFn, err := centrifuge.NewClient(NewCancelContext(r.Context(), ctxCh), s.node, transport)
+		c, closeFn, err := centrifuge.NewClient(NewCancelContext(r.Context(), ctxCh), s.node, throttle.NewTransport(s.config.Delta.NewTransport(transport, r), s.config.Throttle))
 		if err != nil {
 			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error creating client", map[string]interface{}{"transport": transport.Name()}))
 			return
@@ -136,14 +179,20 @@ func (s *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "client connection completed", map[string]interface{}{"client": c.ID(), "transport": transport.Name(), "duration": time.Since(started)}))
 		}(time.Now())
 
-		_, data, err := conn.ReadMessage()
-		if err != nil {
-			return
-		}
+		req := urlConnectRequest
+		if req == nil {
+			data, err := readMessage(conn, messageSizeLimit)
+			if err != nil {
+				if err == errMessageTooBig {
+					c.Disconnect(disconnect.MessageSizeLimit)
+				}
+				return
+			}
 
-		req, err := protocol.NewJSONParamsDecoder().DecodeConnect(data)
-		if err != nil {
-			return
+			req, err = protocol.NewJSONParamsDecoder().DecodeConnect(data)
+			if err != nil {
+				return
+			}
 		}
 
 		connectRequest := centrifuge.ConnectRequest{
@@ -167,7 +216,7 @@ func (s *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		c.Connect(connectRequest)
 
 		for {
-			_, _, err := conn.ReadMessage()
+			_, err := readMessage(conn, messageSizeLimit)
 			if err != nil {
 				break
 			}
@@ -0,0 +1,28 @@
+package uniws
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectRequestFromURL(t *testing.T) {
+	req, err := connectRequestFromURL(httptest.NewRequest("GET", "/connection/uni_websocket", nil))
+	require.NoError(t, err)
+	require.Nil(t, req)
+
+	req, err = connectRequestFromURL(httptest.NewRequest("GET", "/connection/uni_websocket?token=xxx&channel=a&channel=b&channel=", nil))
+	require.NoError(t, err)
+	require.Equal(t, "xxx", req.Token)
+	require.Len(t, req.Subs, 2)
+	require.Contains(t, req.Subs, "a")
+	require.Contains(t, req.Subs, "b")
+
+	req, err = connectRequestFromURL(httptest.NewRequest("GET", `/connection/uni_websocket?cf_connect=%7B%22token%22%3A%22yyy%22%7D`, nil))
+	require.NoError(t, err)
+	require.Equal(t, "yyy", req.Token)
+
+	_, err = connectRequestFromURL(httptest.NewRequest("GET", "/connection/uni_websocket?cf_connect=invalid", nil))
+	require.Error(t, err)
+}
@@ -0,0 +1,33 @@
+package uniws
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/gorilla/websocket"
+)
+
+var errMessageTooBig = errors.New("message too big")
+
+// readMessage reads next message from connection. Unlike read limit of
+// websocket.Conn which closes connection with 1009 close code messages larger
+// than limit result into errMessageTooBig so that client can be disconnected
+// with specific disconnect code. Zero or negative limit means no limit.
+func readMessage(conn *websocket.Conn, limit int) ([]byte, error) {
+	_, r, err := conn.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 {
+		r = io.LimitReader(r, int64(limit)+1)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(data) > limit {
+		return nil, errMessageTooBig
+	}
+	return data, nil
+}
@@ -0,0 +1,118 @@
+package wshandler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/affinity"
+	"github.com/centrifugal/centrifugo/v3/internal/delta"
+	"github.com/centrifugal/centrifugo/v3/internal/throttle"
+)
+
+// Defaults.
+const (
+	DefaultWebsocketPingInterval     = 25 * time.Second
+	DefaultWebsocketWriteTimeout     = 1 * time.Second
+	DefaultWebsocketMessageSizeLimit = 65536 // 64KB
+)
+
+// Config represents config for Handler.
+type Config struct {
+	// CompressionLevel sets a level for websocket compression.
+	// See possible value description at https://golang.org/pkg/compress/flate/#NewWriter
+	CompressionLevel int
+
+	// CompressionMinSize allows to set minimal limit in bytes for
+	// message to use compression when writing it into client connection.
+	// By default it's 0 - i.e. all messages will be compressed when
+	// WebsocketCompression enabled and compression negotiated with client.
+	CompressionMinSize int
+
+	// ReadBufferSize is a parameter that is used for raw websocket Upgrader.
+	// If set to zero reasonable default value will be used.
+	ReadBufferSize int
+
+	// WriteBufferSize is a parameter that is used for raw websocket Upgrader.
+	// If set to zero reasonable default value will be used.
+	WriteBufferSize int
+
+	// MessageSizeLimit sets the maximum size in bytes of allowed message from client.
+	// Client sent larger message disconnected with disconnect.MessageSizeLimit.
+	// By default DefaultWebsocketMessageSizeLimit will be used, negative value
+	// turns off the limit.
+	MessageSizeLimit int
+
+	// CheckOrigin func to provide custom origin check logic.
+	// nil means that sameHostOriginCheck function will be used which
+	// expects Origin host to match request Host.
+	CheckOrigin func(r *http.Request) bool
+
+	// PingInterval sets interval server will send ping messages to clients.
+	// By default DefaultPingInterval will be used.
+	PingInterval time.Duration
+
+	// WriteTimeout is maximum time of write message operation.
+	// Slow client will be disconnected.
+	// By default DefaultWebsocketWriteTimeout will be used.
+	WriteTimeout time.Duration
+
+	// Compression allows to enable websocket permessage-deflate
+	// compression support for raw websocket connections. It does
+	// not guarantee that compression will be used - i.e. it only
+	// says that server will try to negotiate it with client.
+	Compression bool
+
+	// UseWriteBufferPool enables using buffer pool for writes.
+	UseWriteBufferPool bool
+
+	// Throttle configures rate limiting of messages sent to clients.
+	Throttle throttle.Config
+
+	// Delta allows sending publications as patches to clients which ask for it.
+	Delta *delta.Manager
+
+	// DisconnectPush enables sending disconnect push frame with code, reason
+	// and reconnect flag before closing connection. By default disconnect
+	// information only passed in WebSocket close frame.
+	DisconnectPush bool
+
+	// Affinity if set makes handler set affinity cookie in upgrade response
+	// and observe affinity tokens clients pass in connection URL.
+	Affinity *affinity.Config
+
+	// TokenHeader allows clients to pass connection token in Authorization
+	// header of upgrade request (Authorization: Bearer <token>). Token from
+	// connect command has priority.
+	TokenHeader bool
+
+	// TokenSubProtocol allows clients to pass connection token as WebSocket
+	// subprotocol with centrifuge-token. prefix. Browser clients must also
+	// offer centrifuge-json or centrifuge-protobuf subprotocol since server
+	// never selects token subprotocol. Token from connect command has priority.
+	TokenSubProtocol bool
+}
+
+func sameHostOriginCheck() func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		err := checkSameHost(r)
+		return err == nil
+	}
+}
+
+func checkSameHost(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("failed to parse Origin header %q: %w", origin, err)
+	}
+	if strings.EqualFold(r.Host, u.Host) {
+		return nil
+	}
+	return fmt.Errorf("request Origin %q is not authorized for Host %q", origin, r.Host)
+}
@@ -0,0 +1,204 @@
+package wshandler
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/clientcontext"
+	"github.com/centrifugal/centrifugo/v3/internal/disconnect"
+	"github.com/centrifugal/centrifugo/v3/internal/pubtags"
+	"github.com/centrifugal/centrifugo/v3/internal/throttle"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	protobufSubProtocol    = "centrifuge-protobuf"
+	jsonSubProtocol        = "centrifuge-json"
+	tokenSubProtocolPrefix = "centrifuge-token."
+)
+
+// Handler handles bidirectional WebSocket client connections. It's an in-tree
+// version of centrifuge.WebsocketHandler which allows Centrifugo to control how
+// data is delivered to connections.
+type Handler struct {
+	node    *centrifuge.Node
+	upgrade *websocket.Upgrader
+	config  Config
+}
+
+var writeBufferPool = &sync.Pool{}
+
+// NewHandler creates new Handler.
+func NewHandler(n *centrifuge.Node, c Config) *Handler {
+	upgrade := &websocket.Upgrader{
+		ReadBufferSize:    c.ReadBufferSize,
+		EnableCompression: c.Compression,
+		Subprotocols:      []string{protobufSubProtocol, jsonSubProtocol},
+	}
+	if c.UseWriteBufferPool {
+		upgrade.WriteBufferPool = writeBufferPool
+	} else {
+		upgrade.WriteBufferSize = c.WriteBufferSize
+	}
+	if c.CheckOrigin != nil {
+		upgrade.CheckOrigin = c.CheckOrigin
+	} else {
+		upgrade.CheckOrigin = sameHostOriginCheck()
+	}
+	return &Handler{
+		node:    n,
+		config:  c,
+		upgrade: upgrade,
+	}
+}
+
+func (s *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	compression := s.config.Compression
+	compressionLevel := s.config.CompressionLevel
+	compressionMinSize := s.config.CompressionMinSize
+
+	var responseHeader http.Header
+	if s.config.Affinity != nil {
+		if s.config.Affinity.Observe(r) && s.node.LogEnabled(centrifuge.LogLevelDebug) {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "client with affinity to another node", nil))
+		}
+		responseHeader = s.config.Affinity.ResponseHeader()
+	}
+
+	ctx := r.Context()
+	if token := s.connectToken(r); token != "" {
+		ctx = clientcontext.SetContextConnectToken(ctx, token)
+	}
+
+	conn, err := s.upgrade.Upgrade(rw, r, responseHeader)
+	if err != nil {
+		s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "websocket upgrade error", map[string]interface{}{"error": err.Error()}))
+		return
+	}
+
+	if compression {
+		err := conn.SetCompressionLevel(compressionLevel)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "websocket error setting compression level", map[string]interface{}{"error": err.Error()}))
+		}
+	}
+
+	pingInterval := s.config.PingInterval
+	if pingInterval == 0 {
+		pingInterval = DefaultWebsocketPingInterval
+	}
+	writeTimeout := s.config.WriteTimeout
+	if writeTimeout == 0 {
+		writeTimeout = DefaultWebsocketWriteTimeout
+	}
+	messageSizeLimit := s.config.MessageSizeLimit
+	if messageSizeLimit == 0 {
+		messageSizeLimit = DefaultWebsocketMessageSizeLimit
+	}
+
+	if pingInterval > 0 {
+		pongWait := pingInterval * 10 / 9
+		_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error {
+			_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+			return nil
+		})
+	}
+
+	var protoType = centrifuge.ProtocolTypeJSON
+
+	subProtocol := conn.Subprotocol()
+	if subProtocol == protobufSubProtocol {
+		protoType = centrifuge.ProtocolTypeProtobuf
+	} else {
+		// This is a deprecated way to get a protocol type.
+		if r.URL.Query().Get("format") == "protobuf" || r.URL.Query().Get("protocol") == "protobuf" {
+			protoType = centrifuge.ProtocolTypeProtobuf
+		}
+	}
+
+	// Separate goroutine for better GC of caller's data.
+	go func() {
+		opts := websocketTransportOptions{
+			pingInterval:       pingInterval,
+			writeTimeout:       writeTimeout,
+			compressionMinSize: compressionMinSize,
+			protoType:          protoType,
+			disconnectPush:     s.config.DisconnectPush,
+		}
+
+		graceCh := make(chan struct{})
+		transport := pubtags.NewTransport(throttle.NewTransport(s.config.Delta.NewTransport(newWebsocketTransport(conn, opts, graceCh), r), s.config.Throttle))
+
+		select {
+		case <-s.node.NotifyShutdown():
+			_ = transport.Close(centrifuge.DisconnectShutdown)
+			return
+		default:
+		}
+
+		ctxCh := make(chan struct{})
+		defer close(ctxCh)
+
+		c, closeFn, err := centrifuge.NewClient(NewCancelContext(ctx, ctxCh), s.node, transport)
+		if err != nil {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelError, "error creating client", map[string]interface{}{"transport": transportName}))
+			return
+		}
+		defer func() { _ = closeFn() }()
+
+		s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "client connection established", map[string]interface{}{"client": c.ID(), "transport": transportName}))
+		defer func(started time.Time) {
+			s.node.Log(centrifuge.NewLogEntry(centrifuge.LogLevelDebug, "client connection completed", map[string]interface{}{"client": c.ID(), "transport": transportName, "duration": time.Since(started)}))
+		}(time.Now())
+
+		for {
+			data, err := readMessage(conn, messageSizeLimit)
+			if err != nil {
+				if err == errMessageTooBig {
+					c.Disconnect(disconnect.MessageSizeLimit)
+				}
+				break
+			}
+			closed := !c.Handle(data)
+			if closed {
+				break
+			}
+		}
+
+		// https://github.com/gorilla/websocket/issues/448
+		conn.SetPingHandler(nil)
+		conn.SetPongHandler(nil)
+		conn.SetCloseHandler(nil)
+		_ = conn.SetReadDeadline(time.Now().Add(closeFrameWait))
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				close(graceCh)
+				break
+			}
+		}
+	}()
+}
+
+// connectToken extracts connection token passed in upgrade request headers
+// if allowed by configuration.
+func (s *Handler) connectToken(r *http.Request) string {
+	if s.config.TokenHeader {
+		authorization := r.Header.Get("Authorization")
+		if parts := strings.Fields(authorization); len(parts) == 2 && strings.EqualFold(parts[0], "bearer") {
+			return parts[1]
+		}
+	}
+	if s.config.TokenSubProtocol {
+		for _, protocol := range websocket.Subprotocols(r) {
+			if strings.HasPrefix(protocol, tokenSubProtocolPrefix) {
+				return strings.TrimPrefix(protocol, tokenSubProtocolPrefix)
+			}
+		}
+	}
+	return ""
+}
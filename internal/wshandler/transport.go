@@ -0,0 +1,189 @@
+package wshandler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/centrifugal/protocol"
+	"github.com/gorilla/websocket"
+)
+
+const transportName = "websocket"
+
+// websocketTransport is a wrapper struct over websocket connection to fit session
+// interface so client will accept it.
+type websocketTransport struct {
+	mu        sync.RWMutex
+	writeMu   sync.Mutex // sync writes from client writer and throttled delivery.
+	conn      *websocket.Conn
+	closed    bool
+	closeCh   chan struct{}
+	graceCh   chan struct{}
+	opts      websocketTransportOptions
+	pingTimer *time.Timer
+}
+
+type websocketTransportOptions struct {
+	protoType          centrifuge.ProtocolType
+	pingInterval       time.Duration
+	writeTimeout       time.Duration
+	compressionMinSize int
+	disconnectPush     bool
+}
+
+func newWebsocketTransport(conn *websocket.Conn, opts websocketTransportOptions, graceCh chan struct{}) *websocketTransport {
+	transport := &websocketTransport{
+		conn:    conn,
+		closeCh: make(chan struct{}),
+		graceCh: graceCh,
+		opts:    opts,
+	}
+	if opts.pingInterval > 0 {
+		transport.addPing()
+	}
+	return transport
+}
+
+func (t *websocketTransport) ping() {
+	select {
+	case <-t.closeCh:
+		return
+	default:
+		deadline := time.Now().Add(t.opts.pingInterval / 2)
+		err := t.conn.WriteControl(websocket.PingMessage, nil, deadline)
+		if err != nil {
+			_ = t.Close(centrifuge.DisconnectWriteError)
+			return
+		}
+		t.addPing()
+	}
+}
+
+func (t *websocketTransport) addPing() {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	t.pingTimer = time.AfterFunc(t.opts.pingInterval, t.ping)
+	t.mu.Unlock()
+}
+
+// Name returns name of transport.
+func (t *websocketTransport) Name() string {
+	return transportName
+}
+
+// Protocol returns transport protocol.
+func (t *websocketTransport) Protocol() centrifuge.ProtocolType {
+	return t.opts.protoType
+}
+
+// Unidirectional returns whether transport is unidirectional.
+func (t *websocketTransport) Unidirectional() bool {
+	return false
+}
+
+// DisabledPushFlags ...
+func (t *websocketTransport) DisabledPushFlags() uint64 {
+	if t.opts.disconnectPush {
+		return 0
+	}
+	return centrifuge.PushFlagDisconnect
+}
+
+func (t *websocketTransport) writeData(data []byte) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if t.opts.compressionMinSize > 0 {
+		t.conn.EnableWriteCompression(len(data) > t.opts.compressionMinSize)
+	}
+	var messageType = websocket.TextMessage
+	if t.Protocol() == centrifuge.ProtocolTypeProtobuf {
+		messageType = websocket.BinaryMessage
+	}
+	if t.opts.writeTimeout > 0 {
+		_ = t.conn.SetWriteDeadline(time.Now().Add(t.opts.writeTimeout))
+	}
+	err := t.conn.WriteMessage(messageType, data)
+	if err != nil {
+		return err
+	}
+	if t.opts.writeTimeout > 0 {
+		_ = t.conn.SetWriteDeadline(time.Time{})
+	}
+	return nil
+}
+
+// Write data to transport.
+func (t *websocketTransport) Write(message []byte) error {
+	select {
+	case <-t.closeCh:
+		return nil
+	default:
+		protoType := protocol.Type(t.Protocol())
+		if protoType == protocol.TypeJSON {
+			// Fast path for one JSON message.
+			return t.writeData(message)
+		}
+		encoder := protocol.GetDataEncoder(protoType)
+		defer protocol.PutDataEncoder(protoType, encoder)
+		_ = encoder.Encode(message)
+		return t.writeData(encoder.Finish())
+	}
+}
+
+// WriteMany data to transport.
+func (t *websocketTransport) WriteMany(messages ...[]byte) error {
+	select {
+	case <-t.closeCh:
+		return nil
+	default:
+		protoType := protocol.Type(t.Protocol())
+		encoder := protocol.GetDataEncoder(protoType)
+		defer protocol.PutDataEncoder(protoType, encoder)
+		for i := range messages {
+			_ = encoder.Encode(messages[i])
+		}
+		return t.writeData(encoder.Finish())
+	}
+}
+
+const closeFrameWait = 5 * time.Second
+
+// Close closes transport.
+func (t *websocketTransport) Close(disconnect *centrifuge.Disconnect) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	if t.pingTimer != nil {
+		t.pingTimer.Stop()
+	}
+	close(t.closeCh)
+	t.mu.Unlock()
+
+	if disconnect != nil {
+		msg := websocket.FormatCloseMessage(int(disconnect.Code), disconnect.CloseText())
+		err := t.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		if err != nil {
+			return t.conn.Close()
+		}
+		select {
+		case <-t.graceCh:
+		default:
+			// Wait for closing handshake completion.
+			tm := time.NewTimer(closeFrameWait)
+			select {
+			case <-t.graceCh:
+			case <-tm.C:
+			}
+			tm.Stop()
+		}
+		return t.conn.Close()
+	}
+	return t.conn.Close()
+}
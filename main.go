@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	stdlog "log"
 	"net"
 	"net/http"
@@ -26,8 +27,10 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -35,21 +38,53 @@ import (
 	"time"
 
 	"github.com/centrifugal/centrifugo/v3/internal/admin"
+	"github.com/centrifugal/centrifugo/v3/internal/affinity"
+	"github.com/centrifugal/centrifugo/v3/internal/alert"
 	"github.com/centrifugal/centrifugo/v3/internal/api"
+	"github.com/centrifugal/centrifugo/v3/internal/apiaudit"
+	"github.com/centrifugal/centrifugo/v3/internal/bridge"
 	"github.com/centrifugal/centrifugo/v3/internal/build"
 	"github.com/centrifugal/centrifugo/v3/internal/cli"
 	"github.com/centrifugal/centrifugo/v3/internal/client"
+	"github.com/centrifugal/centrifugo/v3/internal/configcheck"
+	"github.com/centrifugal/centrifugo/v3/internal/connlog"
+	"github.com/centrifugal/centrifugo/v3/internal/connthrottle"
+	"github.com/centrifugal/centrifugo/v3/internal/controlguard"
+	"github.com/centrifugal/centrifugo/v3/internal/delta"
+	"github.com/centrifugal/centrifugo/v3/internal/fanin"
+	"github.com/centrifugal/centrifugo/v3/internal/geoip"
 	"github.com/centrifugal/centrifugo/v3/internal/health"
+	"github.com/centrifugal/centrifugo/v3/internal/httpfallback"
+	"github.com/centrifugal/centrifugo/v3/internal/inbox"
+	"github.com/centrifugal/centrifugo/v3/internal/interceptor"
 	"github.com/centrifugal/centrifugo/v3/internal/jwtutils"
 	"github.com/centrifugal/centrifugo/v3/internal/jwtverify"
+	"github.com/centrifugal/centrifugo/v3/internal/limits"
+	"github.com/centrifugal/centrifugo/v3/internal/loadshed"
 	"github.com/centrifugal/centrifugo/v3/internal/logutils"
+	"github.com/centrifugal/centrifugo/v3/internal/memengine"
 	"github.com/centrifugal/centrifugo/v3/internal/metrics/graphite"
 	"github.com/centrifugal/centrifugo/v3/internal/middleware"
+	"github.com/centrifugal/centrifugo/v3/internal/migrate"
 	"github.com/centrifugal/centrifugo/v3/internal/natsbroker"
+	"github.com/centrifugal/centrifugo/v3/internal/noderole"
+	"github.com/centrifugal/centrifugo/v3/internal/nsmetrics"
 	"github.com/centrifugal/centrifugo/v3/internal/origin"
+	"github.com/centrifugal/centrifugo/v3/internal/pgengine"
+	"github.com/centrifugal/centrifugo/v3/internal/presencerefresh"
+	"github.com/centrifugal/centrifugo/v3/internal/presencestate"
 	"github.com/centrifugal/centrifugo/v3/internal/proxy"
+	"github.com/centrifugal/centrifugo/v3/internal/proxyprotocol"
+	"github.com/centrifugal/centrifugo/v3/internal/pubdedup"
+	"github.com/centrifugal/centrifugo/v3/internal/pubid"
+	"github.com/centrifugal/centrifugo/v3/internal/publisher"
+	"github.com/centrifugal/centrifugo/v3/internal/pushnotify"
+	"github.com/centrifugal/centrifugo/v3/internal/redisengine"
+	"github.com/centrifugal/centrifugo/v3/internal/remoteconfig"
 	"github.com/centrifugal/centrifugo/v3/internal/rule"
 	"github.com/centrifugal/centrifugo/v3/internal/survey"
+	"github.com/centrifugal/centrifugo/v3/internal/tenant"
+	"github.com/centrifugal/centrifugo/v3/internal/throttle"
 	"github.com/centrifugal/centrifugo/v3/internal/tntengine"
 	"github.com/centrifugal/centrifugo/v3/internal/tools"
 	"github.com/centrifugal/centrifugo/v3/internal/unigrpc"
@@ -57,6 +92,7 @@ import (
 	"github.com/centrifugal/centrifugo/v3/internal/unisse"
 	"github.com/centrifugal/centrifugo/v3/internal/uniws"
 	"github.com/centrifugal/centrifugo/v3/internal/webui"
+	"github.com/centrifugal/centrifugo/v3/internal/wshandler"
 
 	"github.com/FZambia/viper-lite"
 	"github.com/centrifugal/centrifuge"
@@ -83,10 +119,15 @@ func bindCentrifugoConfig() {
 		"engine":     "memory",
 		"broker":     "",
 
+		"presence_manager": "",
+
 		"token_hmac_secret_key":      "",
 		"token_rsa_public_key":       "",
 		"token_ecdsa_public_key":     "",
 		"token_jwks_public_endpoint": "",
+		"token_audience":             "",
+		"token_issuer":               "",
+		"token_clock_skew":           0,
 
 		"protected":                   false,
 		"publish":                     false,
@@ -94,17 +135,32 @@ func bindCentrifugoConfig() {
 		"anonymous":                   false,
 		"presence":                    false,
 		"presence_disable_for_client": false,
+		"presence_state":              false,
+		"presence_state_broadcast":    false,
+		"presence_member_keys":        false,
 		"history_size":                0,
 		"history_ttl":                 0,
 		"history_disable_for_client":  false,
+		"history_compaction":          false,
 		"recover":                     false,
 		"position":                    false,
 		"proxy_subscribe":             false,
 		"proxy_publish":               false,
+		"public":                      false,
+		"coalesce_publications":       false,
+		"delta_publications":          false,
+		"channel_meta_on_subscribe":   false,
+		"publication_tags":            false,
+		"publication_origin":          false,
+		"channel_group":               false,
+		"publication_schema":          "",
+		"publication_size_limit":      0,
+		"priority_publications":       false,
 
 		"node_info_metrics_aggregate_interval": 60 * time.Second,
 
 		"client_anonymous":                    false,
+		"client_anonymous_restricted":         false,
 		"client_expired_close_delay":          25 * time.Second,
 		"client_expired_sub_close_delay":      25 * time.Second,
 		"client_stale_close_delay":            25 * time.Second,
@@ -113,7 +169,12 @@ func bindCentrifugoConfig() {
 		"client_presence_update_interval":     25 * time.Second,
 		"client_user_connection_limit":        0,
 		"client_concurrency":                  0,
+		"client_publish_dedup_ttl":            pubdedup.DefaultTTL,
 		"client_channel_position_check_delay": 40 * time.Second,
+		"client_throttle_rate":                0.0,
+		"client_throttle_burst":               0,
+		"client_throttle_max_queue_size":      0,
+		"client_throttle_fair_channels":       false,
 
 		"channel_max_length":         255,
 		"channel_private_prefix":     "$",
@@ -126,6 +187,20 @@ func bindCentrifugoConfig() {
 		"user_subscribe_to_personal":      false,
 		"user_personal_channel_namespace": "",
 		"user_personal_single_connection": false,
+		"user_personal_inbox":             false,
+		"user_personal_inbox_size":        inbox.DefaultSize,
+		"user_personal_inbox_ttl":         inbox.DefaultTTL,
+		"user_personal_push":              false,
+
+		"push_fcm_service_account_file": "",
+		"push_fcm_project_id":           "",
+		"push_apns_key_file":            "",
+		"push_apns_key_id":              "",
+		"push_apns_team_id":             "",
+		"push_apns_topic":               "",
+		"push_apns_development":         false,
+		"push_queue_size":               pushnotify.DefaultQueueSize,
+		"push_workers":                  pushnotify.DefaultWorkers,
 
 		"debug":      false,
 		"prometheus": false,
@@ -137,10 +212,27 @@ func bindCentrifugoConfig() {
 		"admin_insecure": false,
 		"admin_web_path": "",
 
+		"admin_debug":                        false,
+		"admin_debug_block_profile_rate":     0,
+		"admin_debug_mutex_profile_fraction": 0,
+		"admin_connections_export_interval":  admin.DefaultConnectionsExportInterval,
+
 		"sockjs":                 false,
 		"sockjs_url":             "https://cdn.jsdelivr.net/npm/sockjs-client@1/dist/sockjs.min.js",
 		"sockjs_heartbeat_delay": 25 * time.Second,
 
+		// Zero values mean websocket_* options used for SockJS websocket transport.
+		"sockjs_websocket_read_buffer_size":  0,
+		"sockjs_websocket_write_buffer_size": 0,
+		"sockjs_websocket_write_timeout":     0,
+
+		"http_fallback":                       false,
+		"http_fallback_max_request_body_size": 65536, // 64KB
+		"http_fallback_session_ttl":           10 * time.Second,
+		"http_fallback_poll_timeout":          25 * time.Second,
+		"http_fallback_poll_batch_delay":      0,
+		"http_fallback_poll_max_batch_size":   0,
+
 		"websocket_compression":           false,
 		"websocket_compression_min_size":  0,
 		"websocket_compression_level":     1,
@@ -150,6 +242,17 @@ func bindCentrifugoConfig() {
 		"websocket_ping_interval":         25 * time.Second,
 		"websocket_write_timeout":         time.Second,
 		"websocket_message_size_limit":    65536, // 64KB
+		"websocket_disconnect_push":       false,
+		"websocket_token_header":          false,
+		"websocket_token_subprotocol":     false,
+
+		"geoip_db_path":   "",
+		"geoip_conn_info": false,
+
+		"affinity":             false,
+		"affinity_token":       "",
+		"affinity_url_param":   affinity.DefaultURLParam,
+		"affinity_cookie_name": "",
 
 		"uni_websocket":                       false,
 		"uni_websocket_compression":           false,
@@ -161,10 +264,20 @@ func bindCentrifugoConfig() {
 		"uni_websocket_ping_interval":         25 * time.Second,
 		"uni_websocket_write_timeout":         time.Second,
 		"uni_websocket_message_size_limit":    65536, // 64KB
+		"uni_websocket_url_connect":           false,
 
 		"uni_http_stream_max_request_body_size": 65536, // 64KB
+		"uni_http_stream_write_timeout":         0,
 
 		"uni_sse_max_request_body_size": 65536, // 64KB
+		"uni_sse_write_timeout":         0,
+
+		"proxy_protocol":                  false,
+		"proxy_protocol_trusted_networks": []string{},
+		"proxy_protocol_required":         false,
+		"proxy_protocol_header_timeout":   5 * time.Second,
+
+		"client_ip_trusted_proxies": []string{},
 
 		"tls_autocert":                false,
 		"tls_autocert_host_whitelist": "",
@@ -180,9 +293,46 @@ func bindCentrifugoConfig() {
 		"redis_write_timeout":   time.Second,
 		"redis_idle_timeout":    0,
 
+		"redis_pubsub_read_timeout": 10 * time.Second,
+		"redis_pipeline_max_wait":   0,
+		"redis_pubsub_num_workers":  0,
+		"redis_pubsub_max_workers":  0,
+
+		"redis_pubsub_num_connections": 1,
+
+		"redis_pubsub_hot_channel_threshold":   0,
+		"redis_pubsub_resubscribe_concurrency": redisengine.DefaultPubSubResubscribeConcurrency,
+
+		"redis_publish_retry_buffer_size": 0,
+		"redis_compatibility":             redisengine.CompatibilityRedis,
+		"redis_publish_retry_timeout":     redisengine.DefaultPublishRetryTimeout,
+
+		"redis_api": false,
+
+		"redis_ordering_check": false,
+
+		"redis_shard_migration_delay": redisengine.DefaultShardMigrationDelay,
+
+		"redis_keyspace_report":                false,
+		"redis_keyspace_report_interval":       redisengine.DefaultKeyspaceReportInterval,
+		"redis_keyspace_report_sample_keys":    redisengine.DefaultKeyspaceSampleKeys,
+		"redis_keyspace_report_memory_samples": redisengine.DefaultKeyspaceMemorySamples,
+
+		"postgres_prefix":           pgengine.DefaultPrefix,
+		"postgres_max_open_conns":   0,
+		"postgres_cleanup_interval": pgengine.DefaultCleanupInterval,
+
 		"history_meta_ttl": 0,
 		"presence_ttl":     60 * time.Second,
 
+		"presence_refresh_interval": 0,
+
+		"control_rate_limit":          controlguard.DefaultRateLimit,
+		"control_duplicate_limit":     controlguard.DefaultDuplicateLimit,
+		"control_suppression_timeout": controlguard.DefaultSuppressionTimeout,
+
+		"memory_history_max_bytes": 0,
+
 		"grpc_api":         false,
 		"grpc_api_address": "",
 		"grpc_api_port":    10000,
@@ -204,10 +354,13 @@ func bindCentrifugoConfig() {
 
 		"websocket_disable": false,
 		"api_disable":       false,
+		"node_role":         string(noderole.All),
 
 		"websocket_handler_prefix": "/connection/websocket",
 		"sockjs_handler_prefix":    "/connection/sockjs",
 
+		"http_fallback_handler_prefix": "/connection/http_fallback",
+
 		"uni_websocket_handler_prefix":      "/connection/uni_websocket",
 		"uni_sse_handler_prefix":            "/connection/uni_sse",
 		"uni_http_stream_handler_prefix":    "/connection/uni_http_stream",
@@ -215,11 +368,29 @@ func bindCentrifugoConfig() {
 		"uni_grpc_address":                  "",
 		"uni_grpc_port":                     11000,
 		"uni_grpc_max_receive_message_size": 65536,
+		"uni_grpc_web":                      false,
 
 		"admin_handler_prefix":      "",
 		"api_handler_prefix":        "/api",
 		"prometheus_handler_prefix": "/metrics",
 		"health_handler_prefix":     "/health",
+		"ready_handler_prefix":      "/ready",
+
+		"ready_timeout": time.Second,
+
+		"survey_timeout": 10 * time.Second,
+
+		"channels_count_metric_interval": 0,
+		"config_sync_interval":           10 * time.Second,
+
+		"bridge_name":       "",
+		"bridge_channels":   []string{},
+		"bridge_queue_size": bridge.DefaultQueueSize,
+		"bridge_timeout":    bridge.DefaultTimeout,
+
+		"connection_log_size": 0,
+
+		"publication_id_generator": "",
 
 		"proxy_connect_timeout":   time.Second,
 		"proxy_rpc_timeout":       time.Second,
@@ -227,12 +398,65 @@ func bindCentrifugoConfig() {
 		"proxy_subscribe_timeout": time.Second,
 		"proxy_publish_timeout":   time.Second,
 
+		"proxy_retry_max_attempts":           1,
+		"proxy_retry_backoff_min":            proxy.DefaultRetryBackoffMin,
+		"proxy_retry_backoff_max":            proxy.DefaultRetryBackoffMax,
+		"proxy_circuit_breaker_failures":     0,
+		"proxy_circuit_breaker_open_timeout": proxy.DefaultCircuitBreakerOpenTimeout,
+		"proxy_connect_fail_open":            false,
+		"proxy_subscribe_fail_open":          false,
+		"proxy_publish_fail_open":            false,
+
 		"client_history_max_publication_limit":  300,
 		"client_recovery_max_publication_limit": 300,
+		"use_singleflight":                      false,
+
+		"client_connect_rate":            0,
+		"client_connect_burst":           0,
+		"client_connect_max_wait":        5 * time.Second,
+		"client_connect_retry_min_delay": time.Second,
+		"client_connect_retry_max_delay": 10 * time.Second,
+
+		"load_shedding_heap_limit":      0,
+		"load_shedding_check_interval":  loadshed.DefaultCheckInterval,
+		"load_shedding_retry_min_delay": time.Second,
+		"load_shedding_retry_max_delay": 10 * time.Second,
+
+		"alert_webhook_url":                "",
+		"alert_interval":                   alert.DefaultInterval,
+		"alert_cooldown":                   alert.DefaultCooldown,
+		"alert_engine_errors_threshold":    0,
+		"alert_auth_failures_threshold":    0,
+		"alert_slow_disconnects_threshold": 0,
+
+		"metrics_namespaces": []string{},
+
+		"api_audit":                  false,
+		"api_audit_sink":             "file",
+		"api_audit_queue_size":       apiaudit.DefaultQueueSize,
+		"api_audit_file_path":        "",
+		"api_audit_file_max_size":    apiaudit.DefaultFileMaxSize,
+		"api_audit_file_max_age":     0,
+		"api_audit_file_max_backups": 0,
+		"api_audit_redis_max_length": redisengine.DefaultAuditMaxLength,
+		"api_audit_redis_ttl":        0,
+
+		"api_presence_limit": 0,
+
+		"api_no_wait_workers":    api.DefaultNoWaitWorkers,
+		"api_no_wait_queue_size": api.DefaultNoWaitQueueSize,
+
+		"config_strict": false,
+
+		"config_provider":          "",
+		"config_provider_endpoint": "",
+		"config_provider_key":      "centrifugo/config",
+		"config_provider_token":    "",
 	}
 
 	for k, v := range defaults {
 		viper.SetDefault(k, v)
+		knownConfigKeys[k] = struct{}{}
 	}
 
 	replacer := strings.NewReplacer(".", "_")
@@ -248,6 +472,10 @@ func main() {
 		Short: "Centrifugo",
 		Long:  "Centrifugo – scalable real-time messaging server in language-agnostic way",
 		Run: func(cmd *cobra.Command, args []string) {
+			// Released after startup when configuration can be modified
+			// concurrently by remote configuration watcher.
+			viperMu.Lock()
+
 			bindCentrifugoConfig()
 
 			bindPFlags := []string{
@@ -255,9 +483,10 @@ func main() {
 				"admin_external", "client_insecure", "admin_insecure", "api_insecure",
 				"port", "address", "tls", "tls_cert", "tls_key", "tls_external", "internal_port",
 				"internal_address", "prometheus", "health", "redis_address", "tarantool_address",
-				"broker", "nats_url", "grpc_api", "grpc_api_tls", "grpc_api_tls_disable",
+				"broker", "presence_manager", "nats_url", "grpc_api", "grpc_api_tls", "grpc_api_tls_disable",
 				"grpc_api_tls_cert", "grpc_api_tls_key", "grpc_api_port", "sockjs", "uni_grpc",
 				"uni_grpc_port", "uni_websocket", "uni_sse", "uni_http_stream",
+				"http_fallback", "node_role", "config_strict",
 			}
 			for _, flag := range bindPFlags {
 				_ = viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
@@ -299,6 +528,21 @@ func main() {
 					"image tag in this case (at least to centrifugo/centrifugo:v2).")
 			}
 
+			remoteConfigSource, remoteConfigVersion, err := loadRemoteConfig()
+			if err != nil {
+				log.Fatal().Msgf("error loading remote configuration: %v", err)
+			}
+
+			if viper.GetBool("config_strict") {
+				problems := unknownConfigKeys(isRootFlag(cmd))
+				for _, p := range problems {
+					log.Error().Msg(p.String())
+				}
+				if len(problems) > 0 {
+					log.Fatal().Msg("unknown configuration keys found in strict mode")
+				}
+			}
+
 			if os.Getenv("GOMAXPROCS") == "" {
 				if viper.IsSet("gomaxprocs") && viper.GetInt("gomaxprocs") > 0 {
 					runtime.GOMAXPROCS(viper.GetInt("gomaxprocs"))
@@ -325,14 +569,31 @@ func main() {
 			}
 			ruleContainer := rule.NewContainer(ruleConfig)
 
+			nodeRole, err := noderole.Parse(viper.GetString("node_role"))
+			if err != nil {
+				log.Fatal().Msgf("error validating config: %v", err)
+			}
+
+			tenants, err := tenantRegistry(ruleConfig, ruleContainer)
+			if err != nil {
+				log.Fatal().Msgf("error validating tenants: %v", err)
+			}
+
 			granularProxyMode := viper.GetBool("granular_proxy_mode")
-			var proxyMap *client.ProxyMap
+			proxyMap := &client.ProxyMap{}
 			var proxyEnabled bool
-			if granularProxyMode {
-				proxyMap, proxyEnabled = granularProxyMapConfig(ruleConfig)
-				log.Info().Msg("using granular proxy configuration")
-			} else {
-				proxyMap, proxyEnabled = proxyMapConfig()
+			// Client proxies are only called for client connections, so worker
+			// node does not set them up.
+			if nodeRole.ServesClients() {
+				if granularProxyMode {
+					proxyMap, proxyEnabled, err = granularProxyMapConfig(viper.GetViper(), ruleConfig)
+					log.Info().Msg("using granular proxy configuration")
+				} else {
+					proxyMap, proxyEnabled, err = proxyMapConfig(viper.GetViper())
+				}
+				if err != nil {
+					log.Fatal().Msg(err.Error())
+				}
 			}
 
 			nodeConfig := nodeConfig(build.Version)
@@ -343,55 +604,418 @@ func main() {
 			}
 
 			brokerName := viper.GetString("broker")
-			if brokerName != "" && brokerName != "nats" {
+			if brokerName != "" && brokerName != "nats" && !isEngineName(brokerName) {
 				log.Fatal().Msgf("unknown broker: %s", brokerName)
 			}
+			presenceManagerName := viper.GetString("presence_manager")
+			if presenceManagerName == "" {
+				presenceManagerName = engineName
+			}
+			if !isEngineName(presenceManagerName) {
+				log.Fatal().Msgf("unknown presence manager: %s", presenceManagerName)
+			}
+			if !isEngineName(engineName) {
+				log.Fatal().Msgf("unknown engine: %s", engineName)
+			}
 
-			var broker centrifuge.Broker
-			var presenceManager centrifuge.PresenceManager
+			// Engine which broker keeps history. Engine set by engine option used
+			// when broker is not an engine (for example Nats broker), in this
+			// case history is not available.
+			dataEngineName := engineName
+			if isEngineName(brokerName) {
+				dataEngineName = brokerName
+			}
 
-			if engineName == "memory" {
-				broker, presenceManager, err = memoryEngine(node)
-			} else if engineName == "redis" {
-				broker, presenceManager, err = redisEngine(node)
-			} else if engineName == "tarantool" {
-				broker, presenceManager, err = tarantoolEngine(node)
-			} else {
-				log.Fatal().Msgf("unknown engine: %s", engineName)
+			engines := map[string]*engineParts{}
+			var readyChecks []health.Check
+			for _, name := range []string{dataEngineName, presenceManagerName} {
+				if _, ok := engines[name]; ok {
+					continue
+				}
+				parts, err := newEngine(node, name, ruleContainer, tenants)
+				if err != nil {
+					log.Fatal().Msgf("error creating %s engine: %v", name, err)
+				}
+				engines[name] = parts
+				readyChecks = append(readyChecks, parts.readyChecks...)
+			}
+
+			broker := engines[dataEngineName].broker
+			presenceManager := engines[presenceManagerName].presenceManager
+			if presenceManagerName != dataEngineName || brokerName == "nats" {
+				log.Info().Str("broker", brokerOrEngineName(brokerName, dataEngineName)).Str("presence_manager", presenceManagerName).Msg("using separate broker and presence manager")
 			}
+
+			presenceStates := presencestate.New(presenceRefreshManager(presenceManager), func(ch string) bool {
+				chOpts, found, err := ruleContainer.ChannelOptions(ch)
+				return err == nil && found && chOpts.PresenceState
+			})
+
+			tokenVerifier, err := jwtverify.NewVerifierJWT(jwtVerifierConfig(), ruleContainer)
 			if err != nil {
-				log.Fatal().Msgf("error creating engine: %v", err)
+				log.Fatal().Msgf("error creating token verifier: %v", err)
 			}
 
-			tokenVerifier := jwtverify.NewTokenVerifierJWT(jwtVerifierConfig(), ruleContainer)
+			if algorithms := tokenVerifier.Algorithms(); len(algorithms) > 0 {
+				log.Info().Str("algorithms", strings.Join(algorithms, ", ")).Msg("enabled JWT verifiers")
+			}
 
 			if viper.GetBool("use_unlimited_history_by_default") {
 				// See detailed comment about this by falling through to var definition.
 				client.UseUnlimitedHistoryByDefault = true
 			}
+			connLog := connlog.New(viper.GetInt("connection_log_size"))
+
+			metaStore, err := engineMetaStore(node, dataEngineName, brokerName, broker)
+			if err != nil {
+				log.Fatal().Msgf("error creating channel meta store: %v", err)
+			}
+
+			readPositionStore, err := engineReadPositionStore(dataEngineName, brokerName, broker)
+			if err != nil {
+				log.Fatal().Msgf("error creating read position store: %v", err)
+			}
+
+			channelOptionsStore, err := engineChannelOptionsStore(dataEngineName, brokerName, broker)
+			if err != nil {
+				log.Fatal().Msgf("error creating channel options store: %v", err)
+			}
+			if channelOptionsStore != nil {
+				skipped, err := ruleContainer.LoadChannelOptionsOverrides(channelOptionsStore)
+				if err != nil {
+					log.Fatal().Msgf("error loading channel options overrides: %v", err)
+				}
+				if len(skipped) > 0 {
+					log.Warn().Strs("channels", skipped).Msg("channel options overrides not applicable to current configuration skipped")
+				}
+			}
+
+			var publishDedupStore pubdedup.Store
+			if GetDuration("client_publish_dedup_ttl") > 0 {
+				publishDedupStore, err = enginePublishDedupStore(dataEngineName, brokerName, broker)
+				if err != nil {
+					log.Fatal().Msgf("error creating publish dedup store: %v", err)
+				}
+			}
+
+			var inboxStore inbox.Store
+			if ruleContainer.Config().UserPersonalInbox {
+				inboxStore, err = engineInboxStore(dataEngineName, brokerName, broker)
+				if err != nil {
+					log.Fatal().Msgf("error creating inbox store: %v", err)
+				}
+				if inboxStore == nil {
+					log.Fatal().Msgf("user personal inbox is not supported by %s engine", dataEngineName)
+				}
+			}
+
+			var pushNotifier *pushnotify.Notifier
+			if ruleContainer.Config().UserPersonalPush {
+				pushDeviceStore, err := enginePushDeviceStore(dataEngineName, brokerName, broker)
+				if err != nil {
+					log.Fatal().Msgf("error creating push device store: %v", err)
+				}
+				if pushDeviceStore == nil {
+					log.Fatal().Msgf("user personal push is not supported by %s engine", dataEngineName)
+				}
+				pushNotifier, err = pushNotifierFromConfig(node, pushDeviceStore)
+				if err != nil {
+					log.Fatal().Msgf("error creating push notifier: %v", err)
+				}
+				go pushNotifier.Run(context.Background())
+			}
+
+			pubIDGenerator, err := publicationIDGenerator()
+			if err != nil {
+				log.Fatal().Msgf("error creating publication ID generator: %v", err)
+			}
+
+			// Publisher shared by client handler and API executors, so client
+			// and API publications handled the same way.
+			pub := publisher.New(node, ruleContainer)
+			if pubIDGenerator != nil {
+				pub.SetIDGenerator(pubIDGenerator)
+			}
+			if inboxStore != nil {
+				pub.SetInbox(inboxStore, inbox.Options{
+					Size: viper.GetInt("user_personal_inbox_size"),
+					TTL:  GetDuration("user_personal_inbox_ttl"),
+				})
+			}
+			if pushNotifier != nil {
+				pub.SetPushNotifier(pushNotifier)
+			}
+
+			channelGroupStore, err := engineChannelGroupStore(dataEngineName, brokerName, broker)
+			if err != nil {
+				log.Fatal().Msgf("error creating channel group store: %v", err)
+			}
+			channelGroupConfig := fanin.Config{
+				IsGroup: func(ch string) bool {
+					chOpts, found, err := ruleContainer.ChannelOptions(ch)
+					return err == nil && found && chOpts.ChannelGroup
+				},
+				Groups: channelGroupsFromConfig(viper.GetViper()),
+				Store:  channelGroupStore,
+			}
+			if err := channelGroupConfig.Validate(); err != nil {
+				log.Fatal().Msgf("error validating channel groups: %v", err)
+			}
+			channelGroups := fanin.NewManager(node, channelGroupConfig)
+
+			if remotes := bridgeRemotesFromConfig(viper.GetViper()); len(remotes) > 0 {
+				b, err := bridge.New(node, bridge.Config{
+					Name:      viper.GetString("bridge_name"),
+					Channels:  viper.GetStringSlice("bridge_channels"),
+					Remotes:   remotes,
+					QueueSize: viper.GetInt("bridge_queue_size"),
+					Timeout:   GetDuration("bridge_timeout"),
+				})
+				if err != nil {
+					log.Fatal().Msgf("error creating bridge: %v", err)
+				}
+				if err := interceptor.Register(b); err != nil {
+					log.Fatal().Msgf("error registering bridge: %v", err)
+				}
+			}
+
+			var namespaceMetrics *nsmetrics.Observer
+			if namespaces := viper.GetStringSlice("metrics_namespaces"); len(namespaces) > 0 {
+				namespaceMetrics = nsmetrics.New(ruleContainer.ChannelNamespace, namespaces)
+			}
+
+			limitsConfig := limitsConfig()
+			if err := limitsConfig.Validate(); err != nil {
+				log.Fatal().Msgf("error validating limits: %v", err)
+			}
+			limitsContainer := limits.NewContainer(limitsConfig)
+
 			clientHandler := client.NewHandler(node, ruleContainer, tokenVerifier, proxyMap, granularProxyMode)
+			clientHandler.SetPublisher(pub)
+			clientHandler.SetLimits(limitsContainer)
+			clientHandler.SetConnectionLog(connLog)
+			migrationRegistry := migrate.NewRegistry()
+			clientHandler.SetMigrationRegistry(migrationRegistry)
+			clientHandler.SetPresenceStateManager(presenceStates)
+			clientHandler.SetTenants(tenants)
+			if cfg := affinityConfig(); cfg != nil {
+				clientHandler.SetAffinityToken(cfg.Token)
+			}
+			if namespaceMetrics != nil {
+				clientHandler.SetNamespaceMetrics(namespaceMetrics)
+			}
+			if rate := viper.GetInt("client_connect_rate"); rate > 0 {
+				connectThrottle, err := connthrottle.New(connthrottle.Config{
+					Rate:          rate,
+					Burst:         viper.GetInt("client_connect_burst"),
+					MaxWait:       GetDuration("client_connect_max_wait"),
+					RetryMinDelay: GetDuration("client_connect_retry_min_delay"),
+					RetryMaxDelay: GetDuration("client_connect_retry_max_delay"),
+				})
+				if err != nil {
+					log.Fatal().Msgf("error creating connect throttle: %v", err)
+				}
+				clientHandler.SetConnectThrottle(connectThrottle)
+			}
+			if limit := viper.GetInt64("load_shedding_heap_limit"); limit > 0 {
+				loadShedder, err := loadshed.New(loadshed.Config{
+					HeapLimit:     uint64(limit),
+					CheckInterval: GetDuration("load_shedding_check_interval"),
+					RetryMinDelay: GetDuration("load_shedding_retry_min_delay"),
+					RetryMaxDelay: GetDuration("load_shedding_retry_max_delay"),
+				})
+				if err != nil {
+					log.Fatal().Msgf("error creating load shedder: %v", err)
+				}
+				go loadShedder.Run(context.Background())
+				clientHandler.SetLoadShedder(loadShedder)
+			}
+			if webhookURL := viper.GetString("alert_webhook_url"); webhookURL != "" {
+				alerter, err := alert.New(node, alert.Config{
+					Rules: []alert.Rule{
+						alert.EngineErrorsRule(viper.GetFloat64("alert_engine_errors_threshold")),
+						alert.AuthFailuresRule(viper.GetFloat64("alert_auth_failures_threshold")),
+						alert.SlowDisconnectsRule(viper.GetFloat64("alert_slow_disconnects_threshold")),
+					},
+					Sender:   &alert.WebhookSender{URL: webhookURL},
+					NodeName: nodeConfig.Name,
+					Interval: GetDuration("alert_interval"),
+					Cooldown: GetDuration("alert_cooldown"),
+				})
+				if err != nil {
+					log.Fatal().Msgf("error creating alerter: %v", err)
+				}
+				go alerter.Run(context.Background())
+			}
+			if path := viper.GetString("geoip_db_path"); path != "" {
+				geoResolver, err := geoip.Open(path)
+				if err != nil {
+					log.Fatal().Msgf("error opening GeoIP database: %v", err)
+				}
+				clientHandler.SetGeoIP(geoResolver, viper.GetBool("geoip_conn_info"))
+			}
+			if metaStore != nil {
+				clientHandler.SetChannelMetaStore(metaStore)
+			}
+			if readPositionStore != nil {
+				clientHandler.SetReadPositionStore(readPositionStore)
+			}
+			if inboxStore != nil {
+				clientHandler.SetInbox(inboxStore)
+			}
+			if publishDedupStore != nil {
+				clientHandler.SetPublishDedupStore(publishDedupStore, GetDuration("client_publish_dedup_ttl"), GetDuration("proxy_publish_timeout")+pubdedup.PendingTTLMargin)
+			}
+			if !interceptor.DefaultChain.Empty() {
+				// Interceptors of custom builds registered from init functions.
+				clientHandler.SetInterceptors(interceptor.DefaultChain)
+			}
 			err = clientHandler.Setup()
 			if err != nil {
 				log.Fatal().Msgf("error setting up client handler: %v", err)
 			}
 
-			surveyCaller := survey.NewCaller(node)
+			throttleConfig := clientThrottleConfig(ruleContainer)
+			deltaManager := clientDeltaManager(ruleContainer)
 
-			httpAPIExecutor := api.NewExecutor(node, ruleContainer, surveyCaller, "http")
-			grpcAPIExecutor := api.NewExecutor(node, ruleContainer, surveyCaller, "grpc")
+			shardAdder, err := engineRedisShardAdder(node, dataEngineName, broker, presenceManager)
+			if err != nil {
+				log.Fatal().Msgf("error creating Redis shard adder: %v", err)
+			}
 
-			node.SetBroker(broker)
-			node.SetPresenceManager(presenceManager)
+			var proxyReloader survey.ProxyReloader
+			if nodeRole.ServesClients() {
+				proxyReloader = &clientProxyReloader{handler: clientHandler, granular: granularProxyMode}
+			}
 
-			var disableHistoryPresence bool
-			if engineName == "memory" && brokerName == "nats" {
-				// Presence and History won't work with Memory engine in distributed case.
-				disableHistoryPresence = true
-				node.SetPresenceManager(nil)
+			surveyCaller := survey.NewCaller(node, ruleContainer, survey.Config{
+				Timeout:             GetDuration("survey_timeout"),
+				ConnectionLog:       connLog,
+				RedisShardAdder:     shardAdder,
+				ConnectionMigrator:  migrationRegistry,
+				ChannelUnsubscriber: migrationRegistry,
+				Limits:              limitsContainer,
+				ProxyReloader:       proxyReloader,
+			})
+			node.OnNodeInfoSend(func() centrifuge.NodeInfoSendReply {
+				// Configuration version changes with reload, so data encoded
+				// every time.
+				nodeInfo := surveyCaller.NodeInfoData()
+				nodeInfo.Role = string(nodeRole)
+				return centrifuge.NodeInfoSendReply{Data: nodeInfo.Encode()}
+			})
+			if interval := GetDuration("config_sync_interval"); interval > 0 {
+				go surveyCaller.RunConfigSync(context.Background(), interval)
+			}
+
+			keyspaceReport, err := engineKeyspaceReport(broker, brokerName, ruleContainer)
+			if err != nil {
+				log.Fatal().Msgf("error creating Redis keyspace reporter: %v", err)
+			}
+
+			if interval := GetDuration("channels_count_metric_interval", true); interval > 0 {
+				go surveyCaller.RunChannelsCountMetric(context.Background(), interval)
+			}
+
+			locker, err := engineLocker(node, dataEngineName, brokerName, broker)
+			if err != nil {
+				log.Fatal().Msgf("error creating locker: %v", err)
+			}
+
+			var timeHistory api.TimeHistory
+			if th, ok := broker.(api.TimeHistory); ok && brokerName != "nats" {
+				// History is kept by engine broker only if separate broker not used.
+				timeHistory = th
+			}
+
+			historyMetaReader := engineHistoryMetaReader(broker, brokerName)
+			presenceLimiter := enginePresenceLimiter(presenceManager)
+
+			var historyCompactor api.HistoryCompactor
+			if hc, ok := broker.(api.HistoryCompactor); ok && brokerName != "nats" && !viper.GetBool("redis_use_lists") {
+				// Compaction only supported for history kept in Redis streams.
+				historyCompactor = hc
+			}
+
+			var priorityPublisher api.PriorityPublisher
+			if pp, ok := broker.(api.PriorityPublisher); ok && brokerName != "nats" {
+				priorityPublisher = pp
+			}
+
+			var auditLog *apiaudit.Log
+			if viper.GetBool("api_audit") {
+				auditLog, err = apiAuditLog(node, broker)
+				if err != nil {
+					log.Fatal().Msgf("error creating API audit log: %v", err)
+				}
+				go func() {
+					<-node.NotifyShutdown()
+					auditLog.Close()
+				}()
+			}
+
+			newAPIExecutor := func(protocol string) *api.Executor {
+				e := api.NewExecutor(node, ruleContainer, surveyCaller, protocol)
+				e.SetTenants(tenants)
+				if locker != nil {
+					e.SetLocker(locker)
+				}
+				if timeHistory != nil {
+					e.SetTimeHistory(timeHistory)
+				}
+				if historyMetaReader != nil {
+					e.SetHistoryMetaReader(historyMetaReader)
+				}
+				if presenceLimiter != nil {
+					e.SetPresenceLimiter(presenceLimiter)
+				}
+				e.SetLimits(limitsContainer)
+				e.SetNoWaitQueue(viper.GetInt("api_no_wait_workers"), viper.GetInt("api_no_wait_queue_size"))
+				if historyCompactor != nil {
+					e.SetHistoryCompactor(historyCompactor)
+				}
+				if priorityPublisher != nil {
+					e.SetPriorityPublisher(priorityPublisher)
+				}
+				if auditLog != nil {
+					e.SetAuditLog(auditLog)
+				}
+				if namespaceMetrics != nil {
+					e.SetNamespaceMetrics(namespaceMetrics)
+				}
+				if metaStore != nil {
+					e.SetChannelMetaStore(metaStore)
+				}
+				if readPositionStore != nil {
+					e.SetReadPositionStore(readPositionStore)
+				}
+				if channelOptionsStore != nil {
+					e.SetChannelOptionsStore(channelOptionsStore)
+				}
+				e.SetPublisher(pub)
+				if pushNotifier != nil {
+					e.SetPushNotifier(pushNotifier)
+				}
+				e.SetChannelGroupManager(channelGroups)
+				if !interceptor.DefaultChain.Empty() {
+					e.SetInterceptors(interceptor.DefaultChain)
+				}
+				return e
 			}
 
-			if disableHistoryPresence {
-				log.Warn().Msgf("presence, history and recovery disabled with Memory engine and Nats broker")
+			httpAPIExecutor := newAPIExecutor("http")
+			grpcAPIExecutor := newAPIExecutor("grpc")
+
+			node.SetBroker(controlguard.Broker(node, channelGroups.Broker(broker), controlGuardConfig()))
+			node.SetPresenceManager(presenceStates)
+
+			if presenceManagerName == "memory" && brokerOrEngineName(brokerName, dataEngineName) != "memory" {
+				// Presence won't work with Memory presence manager in distributed case.
+				node.SetPresenceManager(nil)
+				log.Warn().Msgf("presence disabled with Memory presence manager and %s broker", strings.Title(brokerOrEngineName(brokerName, dataEngineName)))
+			}
+			if dataEngineName == "memory" && brokerName == "nats" {
+				log.Warn().Msgf("history and recovery disabled with Memory engine and Nats broker")
 			}
 
 			if !configFound {
@@ -408,13 +1032,23 @@ func main() {
 				if err != nil {
 					log.Fatal().Msgf("Error creating broker: %v", err)
 				}
-				node.SetBroker(broker)
+				node.SetBroker(controlguard.Broker(node, channelGroups.Broker(broker), controlGuardConfig()))
+				readyChecks = append(readyChecks, health.Check{Name: "nats", Func: broker.Check})
 			}
 
 			if err = node.Run(); err != nil {
 				log.Fatal().Msgf("error running node: %v", err)
 			}
 
+			if dataEngineName == "redis" && viper.GetBool("redis_api") && !nodeRole.ServesAPI() {
+				log.Info().Msgf("Redis API not served by node with %s role", nodeRole)
+			} else if dataEngineName == "redis" && viper.GetBool("redis_api") {
+				redisAPIExecutor := newAPIExecutor("redis")
+				if err = runRedisAPIConsumer(node, broker, redisAPIExecutor); err != nil {
+					log.Fatal().Msgf("error running Redis API consumer: %v", err)
+				}
+			}
+
 			if viper.GetBool("client_insecure") {
 				log.Warn().Msg("INSECURE client mode enabled, make sure you understand risks")
 			}
@@ -427,10 +1061,17 @@ func main() {
 			if viper.GetBool("debug") {
 				log.Warn().Msg("DEBUG mode enabled, see /debug/pprof")
 			}
+			if viper.GetBool("admin") && viper.GetBool("admin_debug") {
+				// Block and mutex profiles are not collected unless sampling enabled.
+				runtime.SetBlockProfileRate(viper.GetInt("admin_debug_block_profile_rate"))
+				runtime.SetMutexProfileFraction(viper.GetInt("admin_debug_mutex_profile_fraction"))
+			}
 
 			var grpcAPIServer *grpc.Server
 			var grpcAPIAddr string
-			if viper.GetBool("grpc_api") {
+			if viper.GetBool("grpc_api") && !nodeRole.ServesAPI() {
+				log.Info().Msgf("GRPC API not served by node with %s role", nodeRole)
+			} else if viper.GetBool("grpc_api") {
 				grpcAPIAddr = net.JoinHostPort(viper.GetString("grpc_api_address"), viper.GetString("grpc_api_port"))
 				grpcAPIConn, err := net.Listen("tcp", grpcAPIAddr)
 				if err != nil {
@@ -440,8 +1081,11 @@ func main() {
 				var tlsConfig *tls.Config
 				var tlsErr error
 
-				if viper.GetString("grpc_api_key") != "" {
-					grpcOpts = append(grpcOpts, api.GRPCKeyAuth(viper.GetString("grpc_api_key")))
+				if viper.GetString("grpc_api_key") != "" || tenants.Enabled() {
+					grpcOpts = append(grpcOpts, api.GRPCTenantKeyAuth(viper.GetString("grpc_api_key"), tenants))
+				}
+				if auditLog != nil {
+					grpcOpts = append(grpcOpts, api.GRPCAudit(auditLog))
 				}
 				if viper.GetBool("grpc_api_tls") {
 					tlsConfig, tlsErr = tlsConfigForGRPC()
@@ -469,7 +1113,9 @@ func main() {
 
 			var grpcUniServer *grpc.Server
 			var grpcUniAddr string
-			if viper.GetBool("uni_grpc") {
+			if viper.GetBool("uni_grpc") && !nodeRole.ServesClients() {
+				log.Info().Msgf("unidirectional GRPC not served by node with %s role", nodeRole)
+			} else if viper.GetBool("uni_grpc") {
 				grpcUniAddr = net.JoinHostPort(viper.GetString("uni_grpc_address"), viper.GetString("uni_grpc_port"))
 				grpcUniConn, err := net.Listen("tcp", grpcUniAddr)
 				if err != nil {
@@ -490,7 +1136,9 @@ func main() {
 				if tlsErr != nil {
 					log.Fatal().Msgf("error getting TLS config: %v", tlsErr)
 				}
-				if tlsConfig != nil {
+				useWeb := viper.GetBool("uni_grpc_web")
+				if tlsConfig != nil && !useWeb {
+					// With gRPC-Web enabled TLS terminated by HTTP server.
 					grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
 				}
 				keepAliveEnforcementPolicy := keepalive.EnforcementPolicy{
@@ -503,9 +1151,20 @@ func main() {
 				grpcOpts = append(grpcOpts, grpc.KeepaliveEnforcementPolicy(keepAliveEnforcementPolicy))
 				grpcOpts = append(grpcOpts, grpc.KeepaliveParams(keepAliveServerParams))
 				grpcUniServer = grpc.NewServer(grpcOpts...)
-				_ = unigrpc.RegisterService(grpcUniServer, unigrpc.NewService(node, uniGRPCHandlerConfig()))
+				uniGRPCService := unigrpc.NewService(node, uniGRPCHandlerConfig(throttleConfig))
+				_ = unigrpc.RegisterService(grpcUniServer, uniGRPCService)
+				uniGRPCWebConfig := unigrpc.WebConfig{
+					MaxRequestSize: viper.GetInt("uni_grpc_max_receive_message_size"),
+				}
 				go func() {
-					if err := grpcUniServer.Serve(grpcUniConn); err != nil {
+					var err error
+					if useWeb {
+						webHandler := unigrpc.NewWebHandler(uniGRPCService, grpcUniServer, uniGRPCWebConfig)
+						err = unigrpc.ServeWithWeb(grpcUniConn, grpcUniServer, middleware.CORS(getCheckOrigin(), webHandler), tlsConfig)
+					} else {
+						err = grpcUniServer.Serve(grpcUniConn)
+					}
+					if err != nil {
 						log.Fatal().Msgf("serve uni GRPC: %v", err)
 					}
 				}()
@@ -515,7 +1174,7 @@ func main() {
 				log.Info().Msgf("serving unidirectional GRPC on %s", grpcUniAddr)
 			}
 
-			servers, err := runHTTPServers(node, httpAPIExecutor, proxyEnabled)
+			servers, err := runHTTPServers(node, httpAPIExecutor, tenants, migrationRegistry, keyspaceReport, throttleConfig, deltaManager, proxyEnabled, readyChecks, nodeRole)
 			if err != nil {
 				log.Fatal().Msgf("error running HTTP server: %v", err)
 			}
@@ -531,27 +1190,45 @@ func main() {
 				})
 			}
 
+			viperMu.Unlock()
+			if remoteConfigSource != nil {
+				go remoteconfig.Watch(context.Background(), remoteConfigSource, remoteConfigVersion, func(value remoteconfig.Value) {
+					log.Info().Uint64("version", value.Version).Msg("reloading remote configuration")
+					if err := reloadRemoteConfig(value.Data, ruleContainer, tokenVerifier); err != nil {
+						log.Error().Msgf("error reloading remote configuration: %v", err)
+						return
+					}
+					log.Info().Msg("remote configuration successfully reloaded")
+				}, func(err error) {
+					log.Error().Msgf("error watching remote configuration: %v", err)
+				})
+			}
+
 			handleSignals(configFile, node, ruleContainer, tokenVerifier, servers, grpcAPIServer, grpcUniServer, exporter)
 		},
 	}
 
 	rootCmd.Flags().StringVarP(&configFile, "config", "c", "config.json", "path to config file")
-	rootCmd.Flags().StringP("engine", "e", "memory", "engine to use: memory or redis")
-	rootCmd.Flags().StringP("broker", "", "", "custom broker to use: ex. nats")
+	rootCmd.Flags().StringP("engine", "e", "memory", "engine to use: memory, redis, tarantool or postgres")
+	rootCmd.Flags().StringP("broker", "", "", "custom broker to use: ex. nats or redis")
+	rootCmd.Flags().StringP("presence_manager", "", "", "custom presence manager to use: memory, redis, tarantool or postgres")
 	rootCmd.Flags().StringP("log_level", "", "info", "set the log level: trace, debug, info, error, fatal or none")
 	rootCmd.Flags().StringP("log_file", "", "", "optional log file - if not specified logs go to STDOUT")
 	rootCmd.Flags().StringP("pid_file", "", "", "optional path to create PID file")
 	rootCmd.Flags().StringP("name", "n", "", "unique node name")
+	rootCmd.Flags().StringP("node_role", "", "all", "node role: all, gateway (client connections only) or worker (server API only)")
 
 	rootCmd.Flags().BoolP("debug", "", false, "enable debug endpoints")
 	rootCmd.Flags().BoolP("admin", "", false, "enable admin web interface")
 	rootCmd.Flags().BoolP("admin_external", "", false, "enable admin web interface on external port")
 	rootCmd.Flags().BoolP("prometheus", "", false, "enable Prometheus metrics endpoint")
-	rootCmd.Flags().BoolP("health", "", false, "enable health check endpoint")
+	rootCmd.Flags().BoolP("health", "", false, "enable health check and readiness endpoints")
 	rootCmd.Flags().BoolP("sockjs", "", false, "enable SockJS endpoint")
 	rootCmd.Flags().BoolP("uni_websocket", "", false, "enable unidirectional websocket endpoint")
 	rootCmd.Flags().BoolP("uni_sse", "", false, "enable unidirectional SSE (EventSource) endpoint")
 	rootCmd.Flags().BoolP("uni_http_stream", "", false, "enable unidirectional HTTP-streaming endpoint")
+	rootCmd.Flags().BoolP("http_fallback", "", false, "enable HTTP-streaming and XHR-polling fallback endpoint")
+	rootCmd.Flags().BoolP("config_strict", "", false, "refuse to start if configuration contains unknown keys")
 
 	rootCmd.Flags().BoolP("client_insecure", "", false, "start in insecure client mode")
 	rootCmd.Flags().BoolP("api_insecure", "", false, "use insecure API mode")
@@ -591,6 +1268,8 @@ func main() {
 	}
 
 	var checkConfigFile string
+	var checkConfigStrict bool
+	var checkConfigProbe bool
 
 	var checkConfigCmd = &cobra.Command{
 		Use:   "checkconfig",
@@ -598,21 +1277,37 @@ func main() {
 		Long:  `Check Centrifugo configuration file`,
 		Run: func(cmd *cobra.Command, args []string) {
 			bindCentrifugoConfig()
-			err := validateConfig(checkConfigFile)
+			if !checkConfigStrict && !checkConfigProbe {
+				err := validateConfig(checkConfigFile)
+				if err != nil {
+					fmt.Printf("error: %v\n", err)
+					os.Exit(1)
+				}
+				return
+			}
+			problems, err := checkConfig(checkConfigFile, checkConfigStrict, checkConfigProbe, isRootFlag(rootCmd))
 			if err != nil {
 				fmt.Printf("error: %v\n", err)
 				os.Exit(1)
 			}
+			for _, p := range problems {
+				fmt.Printf("error: %s\n", p)
+			}
+			if len(problems) > 0 {
+				os.Exit(1)
+			}
 		},
 	}
 	checkConfigCmd.Flags().StringVarP(&checkConfigFile, "config", "c", "config.json", "path to config file to check")
+	checkConfigCmd.Flags().BoolVarP(&checkConfigStrict, "strict", "", false, "fully validate configuration and refuse unknown keys")
+	checkConfigCmd.Flags().BoolVarP(&checkConfigProbe, "probe", "", false, "check that Redis shards are reachable")
 
 	var outputConfigFile string
 
 	var genConfigCmd = &cobra.Command{
 		Use:   "genconfig",
 		Short: "Generate minimal configuration file to start with",
-		Long:  `Generate minimal configuration file to start with`,
+		Long:  `Generate minimal configuration file to start with, TOML and YAML files contain commented commonly used options`,
 		Run: func(cmd *cobra.Command, args []string) {
 			err := tools.GenerateConfig(outputConfigFile)
 			if err != nil {
@@ -633,11 +1328,14 @@ func main() {
 	var genTokenConfigFile string
 	var genTokenUser string
 	var genTokenTTL int64
+	var genTokenSecret string
+	var genTokenChannel string
+	var genTokenClient string
 
 	var genTokenCmd = &cobra.Command{
 		Use:   "gentoken",
-		Short: "Generate sample connection JWT for user",
-		Long:  `Generate sample connection JWT for user`,
+		Short: "Generate sample connection or subscription JWT",
+		Long:  `Generate sample connection JWT for user or subscription JWT for client and channel when channel set`,
 		Run: func(cmd *cobra.Command, args []string) {
 			bindCentrifugoConfig()
 			err := readConfig(genTokenConfigFile)
@@ -646,6 +1344,19 @@ func main() {
 				os.Exit(1)
 			}
 			jwtVerifierConfig := jwtVerifierConfig()
+			if genTokenSecret != "" {
+				jwtVerifierConfig.HMACSecretKey = genTokenSecret
+			}
+			ttl := time.Duration(genTokenTTL) * time.Second
+			if genTokenChannel != "" {
+				token, err := cli.GenerateSubscriptionToken(jwtVerifierConfig, genTokenClient, genTokenChannel, genTokenTTL)
+				if err != nil {
+					fmt.Printf("error: %v\n", err)
+					os.Exit(1)
+				}
+				fmt.Printf("HMAC SHA-256 subscription JWT for client %s to channel %s with expiration TTL %s:\n%s\n", genTokenClient, genTokenChannel, ttl, token)
+				return
+			}
 			token, err := cli.GenerateToken(jwtVerifierConfig, genTokenUser, genTokenTTL)
 			if err != nil {
 				fmt.Printf("error: %v\n", err)
@@ -655,12 +1366,15 @@ func main() {
 			if genTokenUser == "" {
 				user = "anonymous user"
 			}
-			fmt.Printf("HMAC SHA-256 JWT for %s with expiration TTL %s:\n%s\n", user, time.Duration(genTokenTTL)*time.Second, token)
+			fmt.Printf("HMAC SHA-256 JWT for %s with expiration TTL %s:\n%s\n", user, ttl, token)
 		},
 	}
 	genTokenCmd.Flags().StringVarP(&genTokenConfigFile, "config", "c", "config.json", "path to config file")
 	genTokenCmd.Flags().StringVarP(&genTokenUser, "user", "u", "", "user ID")
 	genTokenCmd.Flags().Int64VarP(&genTokenTTL, "ttl", "t", 3600*24*7, "token TTL in seconds")
+	genTokenCmd.Flags().StringVarP(&genTokenSecret, "secret", "s", "", "HMAC secret key to sign token with, token_hmac_secret_key from config used if not set")
+	genTokenCmd.Flags().StringVarP(&genTokenChannel, "channel", "", "", "channel to generate subscription token for")
+	genTokenCmd.Flags().StringVarP(&genTokenClient, "client", "", "", "client ID subscription token is bound to, required with --channel")
 
 	var checkTokenConfigFile string
 
@@ -694,6 +1408,31 @@ func main() {
 	}
 	checkTokenCmd.Flags().StringVarP(&checkTokenConfigFile, "config", "c", "config.json", "path to config file")
 
+	var reloadConfigFile string
+	var reloadConfigAPIEndpoint string
+
+	var reloadConfigCmd = &cobra.Command{
+		Use:   "reloadconfig",
+		Short: "Apply configuration on all running nodes",
+		Long:  `Send channel and rpc configuration, limits and proxy options from config file to running Centrifugo cluster over server API`,
+		Run: func(cmd *cobra.Command, args []string) {
+			bindCentrifugoConfig()
+			err := validateConfig(reloadConfigFile)
+			if err != nil {
+				fmt.Printf("error: %v\n", err)
+				os.Exit(1)
+			}
+			err = cli.ReloadConfig(reloadConfigAPIEndpoint, viper.GetString("api_key"), ruleConfig(), limitsConfig(), proxyConfigOptions())
+			if err != nil {
+				fmt.Printf("error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("configuration successfully applied\n")
+		},
+	}
+	reloadConfigCmd.Flags().StringVarP(&reloadConfigFile, "config", "c", "config.json", "path to config file to apply")
+	reloadConfigCmd.Flags().StringVarP(&reloadConfigAPIEndpoint, "api", "a", "http://localhost:8000/api", "server API endpoint")
+
 	var serveDir string
 	var servePort int
 	var serveAddr string
@@ -721,6 +1460,7 @@ func main() {
 	rootCmd.AddCommand(genConfigCmd)
 	rootCmd.AddCommand(genTokenCmd)
 	rootCmd.AddCommand(checkTokenCmd)
+	rootCmd.AddCommand(reloadConfigCmd)
 	_ = rootCmd.Execute()
 }
 
@@ -790,25 +1530,27 @@ func handleSignals(configFile string, n *centrifuge.Node, ruleContainer *rule.Co
 		case syscall.SIGHUP:
 			// reload application configuration on SIGHUP.
 			log.Info().Msg("reloading configuration")
+			viperMu.Lock()
 			err := validateConfig(configFile)
 			if err != nil {
+				viperMu.Unlock()
 				log.Error().Msgf("error parsing configuration: %s", err)
 				continue
 			}
-			ruleConfig := ruleConfig()
-			if err := tokenVerifier.Reload(jwtVerifierConfig()); err != nil {
-				log.Error().Msgf("error reloading: %v", err)
-				continue
-			}
-			if err := ruleContainer.Reload(ruleConfig); err != nil {
+			err = reloadConfig(ruleContainer, tokenVerifier)
+			viperMu.Unlock()
+			if err != nil {
 				log.Error().Msgf("error reloading: %v", err)
 				continue
 			}
 			log.Info().Msg("configuration successfully reloaded")
 		case syscall.SIGINT, os.Interrupt, syscall.SIGTERM:
 			log.Info().Msg("shutting down ...")
+			viperMu.Lock()
 			pidFile := viper.GetString("pid_file")
 			shutdownTimeout := GetDuration("shutdown_timeout")
+			shutdownTerminationDelay := GetDuration("shutdown_termination_delay")
+			viperMu.Unlock()
 			go time.AfterFunc(shutdownTimeout, func() {
 				if pidFile != "" {
 					_ = os.Remove(pidFile)
@@ -856,7 +1598,7 @@ func handleSignals(configFile string, n *centrifuge.Node, ruleContainer *rule.Co
 			if pidFile != "" {
 				_ = os.Remove(pidFile)
 			}
-			time.Sleep(GetDuration("shutdown_termination_delay"))
+			time.Sleep(shutdownTerminationDelay)
 			os.Exit(0)
 		}
 	}
@@ -864,6 +1606,27 @@ func handleSignals(configFile string, n *centrifuge.Node, ruleContainer *rule.Co
 
 var startHTTPChallengeServerOnce sync.Once
 
+// getProxyProtocolConfig returns config of PROXY protocol listener, nil
+// returned if PROXY protocol not enabled.
+func getProxyProtocolConfig() (*proxyprotocol.Config, error) {
+	v := viper.GetViper()
+	if !v.GetBool("proxy_protocol") {
+		return nil, nil
+	}
+	networks, err := proxyprotocol.ParseNetworks(v.GetStringSlice("proxy_protocol_trusted_networks"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing proxy_protocol_trusted_networks: %v", err)
+	}
+	if len(networks) == 0 {
+		return nil, errors.New("proxy_protocol_trusted_networks required when proxy_protocol enabled")
+	}
+	return &proxyprotocol.Config{
+		TrustedNetworks: networks,
+		Required:        v.GetBool("proxy_protocol_required"),
+		HeaderTimeout:   GetDuration("proxy_protocol_header_timeout"),
+	}, nil
+}
+
 func getTLSConfig() (*tls.Config, error) {
 	tlsEnabled := viper.GetBool("tls")
 	tlsCert := viper.GetString("tls_cert")
@@ -975,7 +1738,7 @@ func (w *httpErrorLogWriter) Write(data []byte) (int, error) {
 	return len(data), nil
 }
 
-func runHTTPServers(n *centrifuge.Node, apiExecutor *api.Executor, proxyEnabled bool) ([]*http.Server, error) {
+func runHTTPServers(n *centrifuge.Node, apiExecutor *api.Executor, tenants *tenant.Registry, connections admin.ConnectionSource, keyspaceReport admin.KeyspaceReportSource, throttleConfig throttle.Config, deltaManager *delta.Manager, proxyEnabled bool, readyChecks []health.Check, nodeRole noderole.Role) ([]*http.Server, error) {
 	debug := viper.GetBool("debug")
 	useAdmin := viper.GetBool("admin")
 	usePrometheus := viper.GetBool("prometheus")
@@ -1026,6 +1789,9 @@ func runHTTPServers(n *centrifuge.Node, apiExecutor *api.Executor, proxyEnabled
 	if viper.GetBool("uni_http_stream") {
 		portFlags |= HandlerUniHTTPStream
 	}
+	if viper.GetBool("http_fallback") {
+		portFlags |= HandlerHTTPFallback
+	}
 	addrToHandlerFlags[externalAddr] = portFlags
 
 	internalAddr := net.JoinHostPort(httpInternalAddress, httpInternalPort)
@@ -1055,13 +1821,22 @@ func runHTTPServers(n *centrifuge.Node, apiExecutor *api.Executor, proxyEnabled
 		log.Fatal().Msgf("can not get TLS config: %v", err)
 	}
 
+	proxyProtocolConfig, err := getProxyProtocolConfig()
+	if err != nil {
+		log.Fatal().Msgf("can not get PROXY protocol config: %v", err)
+	}
+
+	for addr, handlerFlags := range addrToHandlerFlags {
+		addrToHandlerFlags[addr] = nodeRoleHandlerFlags(nodeRole, handlerFlags)
+	}
+
 	// Iterate over port to flags mapping and start HTTP servers
 	// on separate ports serving handlers specified in flags.
 	for addr, handlerFlags := range addrToHandlerFlags {
 		if handlerFlags == 0 {
 			continue
 		}
-		mux := Mux(n, apiExecutor, handlerFlags, proxyEnabled)
+		mux := Mux(n, apiExecutor, tenants, connections, keyspaceReport, throttleConfig, deltaManager, handlerFlags, proxyEnabled, readyChecks)
 
 		log.Info().Msgf("serving %s endpoints on %s", handlerFlags, addr)
 
@@ -1078,15 +1853,25 @@ func runHTTPServers(n *centrifuge.Node, apiExecutor *api.Executor, proxyEnabled
 
 		servers = append(servers, server)
 
+		// PROXY protocol only accepted on client listener.
+		useProxyProtocol := proxyProtocolConfig != nil && addr == externalAddr
+
 		go func() {
+			ln, err := net.Listen("tcp", server.Addr)
+			if err != nil {
+				log.Fatal().Msgf("ListenAndServe: %v", err)
+			}
+			if useProxyProtocol {
+				ln = proxyprotocol.NewListener(ln, *proxyProtocolConfig)
+			}
 			if addrTLSConfig != nil {
-				if err := server.ListenAndServeTLS("", ""); err != nil {
+				if err := server.ServeTLS(ln, "", ""); err != nil {
 					if err != http.ErrServerClosed {
 						log.Fatal().Msgf("ListenAndServe: %v", err)
 					}
 				}
 			} else {
-				if err := server.ListenAndServe(); err != nil {
+				if err := server.Serve(ln); err != nil {
 					if err != http.ErrServerClosed {
 						log.Fatal().Msgf("ListenAndServe: %v", err)
 					}
@@ -1115,21 +1900,427 @@ func readConfig(f string) error {
 	return nil
 }
 
-// validateConfig validates config file located at provided path.
-func validateConfig(f string) error {
-	err := readConfig(f)
-	if err != nil {
-		return err
-	}
-	ruleConfig := ruleConfig()
-	if err := ruleConfig.Validate(); err != nil {
-		return err
+// viperMu serializes access to global viper configuration which is not safe
+// for concurrent use. It is held during startup, configuration reloads
+// initiated by SIGHUP and remote configuration changes.
+var viperMu sync.Mutex
+
+// reloadableConfigKeys contains options applied by reloadConfig without
+// restart.
+var reloadableConfigKeys = map[string]struct{}{}
+
+func init() {
+	for _, key := range []string{
+		"publish", "subscribe_to_publish", "anonymous", "presence", "presence_disable_for_client",
+		"presence_state", "presence_state_broadcast", "presence_member_keys", "join_leave",
+		"history_size", "history_ttl", "position", "recover", "history_disable_for_client",
+		"history_compaction", "protected", "proxy_subscribe", "proxy_publish", "public",
+		"coalesce_publications", "delta_publications", "channel_meta_on_subscribe",
+		"publication_tags", "publication_origin", "channel_group", "publication_schema",
+		"publication_size_limit", "priority_publications", "subscribe_roles", "publish_roles",
+		"history_roles", "presence_roles", "namespaces", "channel_private_prefix",
+		"channel_namespace_boundary", "channel_user_boundary", "channel_user_separator",
+		"user_subscribe_to_personal", "user_personal_single_connection",
+		"user_personal_channel_namespace", "client_insecure", "client_anonymous",
+		"client_anonymous_restricted", "client_concurrency", "rpc_namespace_boundary",
+		"rpc_proxy_name", "rpc_namespaces", "token_hmac_secret_key", "token_rsa_public_key",
+		"token_ecdsa_public_key", "token_jwks_public_endpoint", "token_audience", "token_issuer",
+		"token_clock_skew", "token_keys", "tenants",
+	} {
+		reloadableConfigKeys[key] = struct{}{}
 	}
-	return nil
 }
 
-func ruleConfig() rule.Config {
-	v := viper.GetViper()
+// nonReloadableChanges returns sorted keys of options which differ between
+// two configurations but can't be applied without restart.
+func nonReloadableChanges(prev, next map[string]interface{}) []string {
+	var keys []string
+	seen := make(map[string]struct{}, len(next))
+	check := func(key string) {
+		lowerKey := strings.ToLower(key)
+		if _, ok := seen[lowerKey]; ok {
+			return
+		}
+		seen[lowerKey] = struct{}{}
+		if _, ok := reloadableConfigKeys[lowerKey]; ok {
+			return
+		}
+		if !reflect.DeepEqual(prev[key], next[key]) {
+			keys = append(keys, lowerKey)
+		}
+	}
+	for key := range next {
+		check(key)
+	}
+	for key := range prev {
+		check(key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// reloadConfig applies channel options and token verification settings from
+// current configuration to running node.
+func reloadConfig(ruleContainer *rule.Container, tokenVerifier *jwtverify.VerifierJWT) error {
+	ruleConfig := ruleConfig()
+	if err := ruleConfig.Validate(); err != nil {
+		return err
+	}
+	if err := tokenVerifier.Reload(jwtVerifierConfig()); err != nil {
+		return err
+	}
+	return ruleContainer.Reload(ruleConfig)
+}
+
+// remoteConfigKeys contains keys set from remote configuration last time.
+var remoteConfigKeys map[string]struct{}
+
+// remoteConfigData contains last successfully applied remote configuration.
+var remoteConfigData []byte
+
+// applyRemoteConfig sets options from remote configuration. Remote options
+// take precedence over options from configuration file, environment and flags.
+// Options removed from remote configuration since previous call are unset.
+func applyRemoteConfig(data []byte) error {
+	config, err := remoteconfig.Parse(data)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]struct{}, len(config))
+	for key, value := range config {
+		key = strings.ToLower(key)
+		viper.Set(key, value)
+		keys[key] = struct{}{}
+	}
+	for key := range remoteConfigKeys {
+		if _, ok := keys[key]; !ok {
+			viper.Set(key, nil)
+		}
+	}
+	remoteConfigKeys = keys
+	return nil
+}
+
+// loadRemoteConfig loads configuration from remote source if config_provider
+// is set. Returned source and configuration version should be used to watch
+// modifications.
+func loadRemoteConfig() (remoteconfig.Source, uint64, error) {
+	provider := viper.GetString("config_provider")
+	if provider == "" {
+		return nil, 0, nil
+	}
+	source, err := remoteconfig.New(provider, remoteconfig.Config{
+		Endpoint: viper.GetString("config_provider_endpoint"),
+		Key:      viper.GetString("config_provider_key"),
+		Token:    viper.GetString("config_provider_token"),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	value, err := source.Get(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := applyRemoteConfig(value.Data); err != nil {
+		return nil, 0, err
+	}
+	remoteConfigData = value.Data
+	log.Info().Str("provider", provider).Str("key", viper.GetString("config_provider_key")).Uint64("version", value.Version).Msg("using remote configuration")
+	return source, value.Version, nil
+}
+
+// reloadRemoteConfig applies modified remote configuration. Only options which
+// can be reloaded without restart take effect: channel options, namespaces and
+// token verification settings. Previous remote configuration restored if new
+// one is invalid.
+func reloadRemoteConfig(data []byte, ruleContainer *rule.Container, tokenVerifier *jwtverify.VerifierJWT) error {
+	viperMu.Lock()
+	defer viperMu.Unlock()
+	prevConfig, _ := remoteconfig.Parse(remoteConfigData)
+	config, err := remoteconfig.Parse(data)
+	if err != nil {
+		return err
+	}
+	if ignored := nonReloadableChanges(prevConfig, config); len(ignored) > 0 {
+		log.Warn().Strs("keys", ignored).Msg("remote configuration options changed which require restart, ignored until restart")
+	}
+	if err := applyRemoteConfig(data); err != nil {
+		return err
+	}
+	if err := reloadConfig(ruleContainer, tokenVerifier); err != nil {
+		_ = applyRemoteConfig(remoteConfigData)
+		return err
+	}
+	remoteConfigData = data
+	return nil
+}
+
+// isProxyConfigKey reports whether option configures client proxies.
+func isProxyConfigKey(key string) bool {
+	switch key {
+	case "proxies", "connect_proxy_name", "refresh_proxy_name":
+		return true
+	}
+	return strings.HasPrefix(key, "proxy_") && !strings.HasPrefix(key, "proxy_protocol")
+}
+
+// proxyConfigOptions returns client proxy options of current configuration
+// to send with configuration reload.
+func proxyConfigOptions() map[string]interface{} {
+	options := map[string]interface{}{}
+	for _, key := range viper.AllKeys() {
+		if !isProxyConfigKey(key) {
+			continue
+		}
+		value := viper.Get(key)
+		if d, ok := value.(time.Duration); ok {
+			// Durations are configured as strings.
+			value = d.String()
+		}
+		options[key] = value
+	}
+	return options
+}
+
+// clientProxyReloader rebuilds proxies of client handler on configuration
+// reload over cluster. Reload configuration applied on top of node
+// configuration. Proxies not enabled on node start do not get request headers
+// since headers middleware is only set up when proxies enabled on start.
+type clientProxyReloader struct {
+	handler  *client.Handler
+	granular bool
+}
+
+func (r *clientProxyReloader) proxyMap(config []byte, ruleConfig rule.Config) (*client.ProxyMap, error) {
+	options, err := remoteconfig.Parse(config)
+	if err != nil {
+		return nil, err
+	}
+	v := viper.New()
+	viperMu.Lock()
+	for key, value := range viper.AllSettings() {
+		v.Set(key, value)
+	}
+	viperMu.Unlock()
+	for key, value := range options {
+		v.Set(strings.ToLower(key), value)
+	}
+	var proxyMap *client.ProxyMap
+	if r.granular {
+		proxyMap, _, err = granularProxyMapConfig(v, ruleConfig)
+	} else {
+		proxyMap, _, err = proxyMapConfig(v)
+	}
+	return proxyMap, err
+}
+
+// ValidateProxies ...
+func (r *clientProxyReloader) ValidateProxies(config []byte, ruleConfig rule.Config) error {
+	_, err := r.proxyMap(config, ruleConfig)
+	return err
+}
+
+// ReloadProxies ...
+func (r *clientProxyReloader) ReloadProxies(config []byte, ruleConfig rule.Config) (func(), error) {
+	proxyMap, err := r.proxyMap(config, ruleConfig)
+	if err != nil {
+		return nil, err
+	}
+	prev := r.handler.ReloadProxies(proxyMap)
+	return func() { r.handler.ReloadProxies(prev) }, nil
+}
+
+// validateConfig validates config file located at provided path.
+func validateConfig(f string) error {
+	err := readConfig(f)
+	if err != nil {
+		return err
+	}
+	ruleConfig := ruleConfig()
+	if err := ruleConfig.Validate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// knownConfigKeys contains keys of options with default values, filled by
+// bindCentrifugoConfig.
+var knownConfigKeys = map[string]struct{}{}
+
+// configKeysWithoutDefaults contains known keys of options which have no
+// default value.
+var configKeysWithoutDefaults = []string{
+	"allowed_origins", "api_key", "grpc_api_key", "join_leave", "namespaces", "rpc_namespaces",
+	"channel_groups", "tenants", "bridge_remotes", "token_keys", "publication_id_node_id",
+	"publish_roles", "subscribe_roles", "presence_roles", "history_roles",
+	"use_unlimited_history_by_default",
+	"granular_proxy_mode", "proxies", "connect_proxy_name", "refresh_proxy_name", "rpc_proxy_name",
+	"proxy_connect_endpoint", "proxy_refresh_endpoint", "proxy_rpc_endpoint",
+	"proxy_subscribe_endpoint", "proxy_publish_endpoint", "proxy_binary_encoding",
+	"proxy_include_connection_meta", "proxy_http_headers", "proxy_grpc_metadata",
+	"proxy_grpc_cert_file", "proxy_grpc_tls", "proxy_grpc_client_cert_file", "proxy_grpc_client_key_file",
+	"proxy_grpc_server_name", "proxy_grpc_credentials_key", "proxy_grpc_credentials_value",
+	"proxy_grpc_pool_size", "proxy_signature_key",
+	"redis_db", "redis_user", "redis_password", "redis_tls", "redis_tls_skip_verify", "redis_use_lists",
+	"redis_cluster_address", "redis_sentinel_address", "redis_sentinel_master_name",
+	"redis_sentinel_user", "redis_sentinel_password", "redis_api_queues",
+	"tarantool_mode", "tarantool_user", "tarantool_password",
+	"postgres_dsn",
+	"uni_grpc_tls", "uni_grpc_tls_disable", "uni_grpc_tls_cert", "uni_grpc_tls_key",
+}
+
+func isRootFlag(cmd *cobra.Command) func(key string) bool {
+	return func(key string) bool {
+		return cmd.Flags().Lookup(key) != nil
+	}
+}
+
+// unknownConfigKeys returns problems for configuration keys Centrifugo does
+// not know about – usually these are typos or options removed in new versions.
+func unknownConfigKeys(isFlag func(key string) bool) []configcheck.Problem {
+	known := make(map[string]struct{}, len(knownConfigKeys)+len(configKeysWithoutDefaults))
+	for k := range knownConfigKeys {
+		known[k] = struct{}{}
+	}
+	for _, k := range configKeysWithoutDefaults {
+		known[k] = struct{}{}
+	}
+	var keys []string
+	for _, key := range viper.AllKeys() {
+		if isFlag(key) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	c := &configcheck.Checker{}
+	c.UnknownKeys(keys, known)
+	return c.Problems()
+}
+
+// checkConfig validates configuration file located at provided path. Unlike
+// validateConfig it does not stop on first problem and also checks things
+// required to run a node: TLS files, proxy endpoints and Redis shard addresses.
+// In strict mode unknown configuration keys are reported. With probe Redis
+// shards are checked to be reachable. Error returned if configuration can't be
+// read at all.
+func checkConfig(f string, strict bool, probe bool, isFlag func(key string) bool) ([]configcheck.Problem, error) {
+	if err := readConfig(f); err != nil {
+		return nil, err
+	}
+	c := &configcheck.Checker{}
+	if strict {
+		for _, p := range unknownConfigKeys(isFlag) {
+			c.Addf(p.Key, p.Message)
+		}
+	}
+
+	ruleConfig := ruleConfig()
+	c.Error("", ruleConfig.Validate())
+	if _, err := noderole.Parse(viper.GetString("node_role")); err != nil {
+		c.Error("node_role", err)
+	}
+
+	checkTLSConfig(c, "tls", "tls_cert", "tls_key")
+	checkTLSConfig(c, "grpc_api_tls", "grpc_api_tls_cert", "grpc_api_tls_key")
+	checkTLSConfig(c, "uni_grpc_tls", "uni_grpc_tls_cert", "uni_grpc_tls_key")
+
+	if viper.GetBool("granular_proxy_mode") {
+		proxies, err := granularProxiesFromConfig(viper.GetViper())
+		c.Error("proxies", err)
+		for _, p := range proxies {
+			key := "proxies." + p.Name
+			c.Endpoint(key, p.Endpoint)
+			c.File(key+".grpc_cert_file", p.GrpcCertFile)
+			c.File(key+".grpc_client_cert_file", p.GrpcClientCertFile)
+			c.File(key+".grpc_client_key_file", p.GrpcClientKeyFile)
+		}
+	} else {
+		for _, key := range []string{
+			"proxy_connect_endpoint", "proxy_refresh_endpoint", "proxy_rpc_endpoint",
+			"proxy_subscribe_endpoint", "proxy_publish_endpoint",
+		} {
+			c.Endpoint(key, viper.GetString(key))
+		}
+		for _, key := range []string{"proxy_grpc_cert_file", "proxy_grpc_client_cert_file", "proxy_grpc_client_key_file"} {
+			c.File(key, viper.GetString(key))
+		}
+	}
+
+	engineName := viper.GetString("engine")
+	if !isEngineName(engineName) {
+		c.Addf("engine", "unknown engine: %s", engineName)
+	}
+	brokerName := viper.GetString("broker")
+	if brokerName != "" && brokerName != "nats" && !isEngineName(brokerName) {
+		c.Addf("broker", "unknown broker: %s", brokerName)
+	}
+	presenceManagerName := viper.GetString("presence_manager")
+	if presenceManagerName != "" && !isEngineName(presenceManagerName) {
+		c.Addf("presence_manager", "unknown presence manager: %s", presenceManagerName)
+	}
+	if engineName == "redis" || brokerName == "redis" || presenceManagerName == "redis" {
+		checkRedisConfig(c, probe)
+	}
+	return c.Problems(), nil
+}
+
+// checkTLSConfig checks certificate and key files when TLS enabled by option
+// with enabledKey.
+func checkTLSConfig(c *configcheck.Checker, enabledKey string, certKey string, keyKey string) {
+	if !viper.GetBool(enabledKey) {
+		return
+	}
+	certFile := viper.GetString(certKey)
+	keyFile := viper.GetString(keyKey)
+	if certFile == "" {
+		c.Addf(certKey, "must be set when %s enabled", enabledKey)
+	}
+	if keyFile == "" {
+		c.Addf(keyKey, "must be set when %s enabled", enabledKey)
+	}
+	numProblems := len(c.Problems())
+	c.File(certKey, certFile)
+	c.File(keyKey, keyFile)
+	if certFile == "" || keyFile == "" || len(c.Problems()) > numProblems {
+		return
+	}
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		c.Addf(certKey, "can't load X509 key pair: %v", err)
+	}
+}
+
+func checkRedisConfig(c *configcheck.Checker, probe bool) {
+	shardConfigs, err := getRedisShardConfigs()
+	if err != nil {
+		c.Error("redis_address", err)
+		return
+	}
+	c.Error("redis_address", validateRedisShardConfigs(shardConfigs))
+	for _, conf := range shardConfigs {
+		key := "redis_address"
+		if len(conf.ClusterAddresses) > 0 {
+			key = "redis_cluster_address"
+		} else if len(conf.SentinelAddresses) > 0 {
+			key = "redis_sentinel_address"
+		}
+		network, addresses, err := conf.Endpoints()
+		if err != nil {
+			c.Addf(key, "%s: %v", conf.Address, err)
+			continue
+		}
+		if !probe {
+			continue
+		}
+		for _, address := range addresses {
+			c.Dial(key, network, address, GetDuration("redis_connect_timeout"))
+		}
+	}
+}
+
+func ruleConfig() rule.Config {
+	v := viper.GetViper()
 	cfg := rule.Config{}
 
 	cfg.Publish = v.GetBool("publish")
@@ -1137,15 +2328,33 @@ func ruleConfig() rule.Config {
 	cfg.Anonymous = v.GetBool("anonymous")
 	cfg.Presence = v.GetBool("presence")
 	cfg.PresenceDisableForClient = v.GetBool("presence_disable_for_client")
+	cfg.PresenceState = v.GetBool("presence_state")
+	cfg.PresenceStateBroadcast = v.GetBool("presence_state_broadcast")
+	cfg.PresenceMemberKeys = v.GetBool("presence_member_keys")
 	cfg.JoinLeave = v.GetBool("join_leave")
 	cfg.HistorySize = v.GetInt("history_size")
 	cfg.HistoryTTL = tools.Duration(GetDuration("history_ttl", true))
 	cfg.Position = v.GetBool("position")
 	cfg.Recover = v.GetBool("recover")
 	cfg.HistoryDisableForClient = v.GetBool("history_disable_for_client")
+	cfg.HistoryCompaction = v.GetBool("history_compaction")
 	cfg.Protected = v.GetBool("protected")
 	cfg.ProxySubscribe = v.GetBool("proxy_subscribe")
 	cfg.ProxyPublish = v.GetBool("proxy_publish")
+	cfg.Public = v.GetBool("public")
+	cfg.CoalescePublications = v.GetBool("coalesce_publications")
+	cfg.DeltaPublications = v.GetBool("delta_publications")
+	cfg.ChannelMetaOnSubscribe = v.GetBool("channel_meta_on_subscribe")
+	cfg.PublicationTags = v.GetBool("publication_tags")
+	cfg.PublicationOrigin = v.GetBool("publication_origin")
+	cfg.ChannelGroup = v.GetBool("channel_group")
+	cfg.PublicationSchema = v.GetString("publication_schema")
+	cfg.PublicationSizeLimit = v.GetInt("publication_size_limit")
+	cfg.PriorityPublications = v.GetBool("priority_publications")
+	cfg.SubscribeRoles = v.GetStringSlice("subscribe_roles")
+	cfg.PublishRoles = v.GetStringSlice("publish_roles")
+	cfg.HistoryRoles = v.GetStringSlice("history_roles")
+	cfg.PresenceRoles = v.GetStringSlice("presence_roles")
 	cfg.Namespaces = namespacesFromConfig(v)
 	cfg.ChannelPrivatePrefix = v.GetString("channel_private_prefix")
 	cfg.ChannelNamespaceBoundary = v.GetString("channel_namespace_boundary")
@@ -1153,9 +2362,12 @@ func ruleConfig() rule.Config {
 	cfg.ChannelUserSeparator = v.GetString("channel_user_separator")
 	cfg.UserSubscribeToPersonal = v.GetBool("user_subscribe_to_personal")
 	cfg.UserPersonalSingleConnection = v.GetBool("user_personal_single_connection")
+	cfg.UserPersonalInbox = v.GetBool("user_personal_inbox")
+	cfg.UserPersonalPush = v.GetBool("user_personal_push")
 	cfg.UserPersonalChannelNamespace = v.GetString("user_personal_channel_namespace")
 	cfg.ClientInsecure = v.GetBool("client_insecure")
 	cfg.ClientAnonymous = v.GetBool("client_anonymous")
+	cfg.ClientAnonymousRestricted = v.GetBool("client_anonymous_restricted")
 	cfg.ClientConcurrency = v.GetInt("client_concurrency")
 	cfg.RpcNamespaceBoundary = v.GetString("rpc_namespace_boundary")
 	cfg.RpcProxyName = v.GetString("rpc_proxy_name")
@@ -1188,18 +2400,38 @@ func jwtVerifierConfig() jwtverify.VerifierConfig {
 	}
 
 	cfg.JWKSPublicEndpoint = v.GetString("token_jwks_public_endpoint")
+	cfg.Audience = v.GetString("token_audience")
+	cfg.Issuer = v.GetString("token_issuer")
+	cfg.ClockSkew = GetDuration("token_clock_skew", true)
+	cfg.Keys = tokenKeysFromConfig(v)
+
+	tenants := tenantsFromConfig(v)
+	if len(tenants) > 0 {
+		cfg.TenantHMACSecretKeys = make(map[string]string, len(tenants))
+		for _, t := range tenants {
+			cfg.TenantHMACSecretKeys[t.Name] = t.TokenHMACSecretKey
+		}
+	}
 
 	return cfg
 }
 
-func GetDuration(key string, secondsPrecision ...bool) time.Duration {
-	durationString := viper.GetString(key)
-	duration, err := time.ParseDuration(durationString)
+// durationFromConfig parses duration option of configuration.
+func durationFromConfig(v *viper.Viper, key string) (time.Duration, error) {
+	duration, err := time.ParseDuration(v.GetString(key))
 	if err != nil {
-		log.Fatal().Msgf("malformed duration for key '%s': %v", key, err)
+		return 0, fmt.Errorf("malformed duration for key '%s': %v", key, err)
 	}
 	if duration > 0 && duration < time.Millisecond {
-		log.Fatal().Msgf("malformed duration for key '%s': %s, minimal duration resolution is 1ms – make sure correct time unit set", key, duration)
+		return 0, fmt.Errorf("malformed duration for key '%s': %s, minimal duration resolution is 1ms – make sure correct time unit set", key, duration)
+	}
+	return duration, nil
+}
+
+func GetDuration(key string, secondsPrecision ...bool) time.Duration {
+	duration, err := durationFromConfig(viper.GetViper(), key)
+	if err != nil {
+		log.Fatal().Msg(err.Error())
 	}
 	if duration > 0 && duration < time.Second && len(secondsPrecision) > 0 && secondsPrecision[0] {
 		log.Fatal().Msgf("malformed duration for key '%s': %s, minimal duration resolution is 1s for this key", key, duration)
@@ -1210,8 +2442,17 @@ func GetDuration(key string, secondsPrecision ...bool) time.Duration {
 	return duration
 }
 
-func proxyMapConfig() (*client.ProxyMap, bool) {
-	v := viper.GetViper()
+// proxyMapConfig creates proxies configured with proxy_<event>_endpoint
+// options of configuration.
+func proxyMapConfig(v *viper.Viper) (*client.ProxyMap, bool, error) {
+	var durationErr error
+	getDuration := func(key string) time.Duration {
+		duration, err := durationFromConfig(v, key)
+		if err != nil && durationErr == nil {
+			durationErr = err
+		}
+		return duration
+	}
 	proxyMap := &client.ProxyMap{
 		SubscribeProxies: map[string]proxy.SubscribeProxy{},
 		PublishProxies:   map[string]proxy.PublishProxy{},
@@ -1225,25 +2466,40 @@ func proxyMapConfig() (*client.ProxyMap, bool) {
 	p.GrpcCertFile = v.GetString("proxy_grpc_cert_file")
 	p.GrpcCredentialsKey = v.GetString("proxy_grpc_credentials_key")
 	p.GrpcCredentialsValue = v.GetString("proxy_grpc_credentials_value")
+	p.GrpcTLS = v.GetBool("proxy_grpc_tls")
+	p.GrpcClientCertFile = v.GetString("proxy_grpc_client_cert_file")
+	p.GrpcClientKeyFile = v.GetString("proxy_grpc_client_key_file")
+	p.GrpcServerName = v.GetString("proxy_grpc_server_name")
+	p.GrpcPoolSize = v.GetInt("proxy_grpc_pool_size")
+	p.SignatureKey = v.GetString("proxy_signature_key")
+	p.RetryMaxAttempts = v.GetInt("proxy_retry_max_attempts")
+	p.RetryBackoffMin = tools.Duration(getDuration("proxy_retry_backoff_min"))
+	p.RetryBackoffMax = tools.Duration(getDuration("proxy_retry_backoff_max"))
+	p.CircuitBreakerFailures = v.GetInt("proxy_circuit_breaker_failures")
+	p.CircuitBreakerOpenTimeout = tools.Duration(getDuration("proxy_circuit_breaker_open_timeout"))
 
 	connectEndpoint := v.GetString("proxy_connect_endpoint")
-	connectTimeout := GetDuration("proxy_connect_timeout")
+	connectTimeout := getDuration("proxy_connect_timeout")
 	refreshEndpoint := v.GetString("proxy_refresh_endpoint")
-	refreshTimeout := GetDuration("proxy_refresh_timeout")
+	refreshTimeout := getDuration("proxy_refresh_timeout")
 	rpcEndpoint := v.GetString("proxy_rpc_endpoint")
-	rpcTimeout := GetDuration("proxy_rpc_timeout")
+	rpcTimeout := getDuration("proxy_rpc_timeout")
 	subscribeEndpoint := v.GetString("proxy_subscribe_endpoint")
-	subscribeTimeout := GetDuration("proxy_subscribe_timeout")
+	subscribeTimeout := getDuration("proxy_subscribe_timeout")
 	publishEndpoint := v.GetString("proxy_publish_endpoint")
-	publishTimeout := GetDuration("proxy_publish_timeout")
+	publishTimeout := getDuration("proxy_publish_timeout")
+	if durationErr != nil {
+		return nil, false, durationErr
+	}
 
 	if connectEndpoint != "" {
 		p.Endpoint = connectEndpoint
 		p.Timeout = tools.Duration(connectTimeout)
+		p.FailOpen = v.GetBool("proxy_connect_fail_open")
 		var err error
 		proxyMap.ConnectProxy, err = proxy.GetConnectProxy(p)
 		if err != nil {
-			log.Fatal().Msgf("error creating connect proxy: %v", err)
+			return nil, false, fmt.Errorf("error creating connect proxy: %w", err)
 		}
 		log.Info().Str("endpoint", connectEndpoint).Msg("connect proxy enabled")
 	}
@@ -1251,10 +2507,11 @@ func proxyMapConfig() (*client.ProxyMap, bool) {
 	if refreshEndpoint != "" {
 		p.Endpoint = refreshEndpoint
 		p.Timeout = tools.Duration(refreshTimeout)
+		p.FailOpen = false
 		var err error
 		proxyMap.RefreshProxy, err = proxy.GetRefreshProxy(p)
 		if err != nil {
-			log.Fatal().Msgf("error creating refresh proxy: %v", err)
+			return nil, false, fmt.Errorf("error creating refresh proxy: %w", err)
 		}
 		log.Info().Str("endpoint", refreshEndpoint).Msg("refresh proxy enabled")
 	}
@@ -1262,9 +2519,10 @@ func proxyMapConfig() (*client.ProxyMap, bool) {
 	if subscribeEndpoint != "" {
 		p.Endpoint = subscribeEndpoint
 		p.Timeout = tools.Duration(subscribeTimeout)
+		p.FailOpen = v.GetBool("proxy_subscribe_fail_open")
 		sp, err := proxy.GetSubscribeProxy(p)
 		if err != nil {
-			log.Fatal().Msgf("error creating subscribe proxy: %v", err)
+			return nil, false, fmt.Errorf("error creating subscribe proxy: %w", err)
 		}
 		proxyMap.SubscribeProxies[""] = sp
 		log.Info().Str("endpoint", subscribeEndpoint).Msg("subscribe proxy enabled")
@@ -1273,9 +2531,10 @@ func proxyMapConfig() (*client.ProxyMap, bool) {
 	if publishEndpoint != "" {
 		p.Endpoint = publishEndpoint
 		p.Timeout = tools.Duration(publishTimeout)
+		p.FailOpen = v.GetBool("proxy_publish_fail_open")
 		pp, err := proxy.GetPublishProxy(p)
 		if err != nil {
-			log.Fatal().Msgf("error creating publish proxy: %v", err)
+			return nil, false, fmt.Errorf("error creating publish proxy: %w", err)
 		}
 		proxyMap.PublishProxies[""] = pp
 		log.Info().Str("endpoint", publishEndpoint).Msg("publish proxy enabled")
@@ -1284,9 +2543,10 @@ func proxyMapConfig() (*client.ProxyMap, bool) {
 	if rpcEndpoint != "" {
 		p.Endpoint = rpcEndpoint
 		p.Timeout = tools.Duration(rpcTimeout)
+		p.FailOpen = false
 		rp, err := proxy.GetRpcProxy(p)
 		if err != nil {
-			log.Fatal().Msgf("error creating rpc proxy: %v", err)
+			return nil, false, fmt.Errorf("error creating rpc proxy: %w", err)
 		}
 		proxyMap.RpcProxies[""] = rp
 		log.Info().Str("endpoint", rpcEndpoint).Msg("RPC proxy enabled")
@@ -1295,16 +2555,21 @@ func proxyMapConfig() (*client.ProxyMap, bool) {
 	proxyEnabled := connectEndpoint != "" || refreshEndpoint != "" ||
 		rpcEndpoint != "" || subscribeEndpoint != "" || publishEndpoint != ""
 
-	return proxyMap, proxyEnabled
+	return proxyMap, proxyEnabled, nil
 }
 
-func granularProxyMapConfig(ruleConfig rule.Config) (*client.ProxyMap, bool) {
+// granularProxyMapConfig creates proxies from proxies option of configuration
+// referenced by proxy names.
+func granularProxyMapConfig(v *viper.Viper, ruleConfig rule.Config) (*client.ProxyMap, bool, error) {
 	proxyMap := &client.ProxyMap{
 		RpcProxies:       map[string]proxy.RPCProxy{},
 		PublishProxies:   map[string]proxy.PublishProxy{},
 		SubscribeProxies: map[string]proxy.SubscribeProxy{},
 	}
-	proxyList := granularProxiesFromConfig(viper.GetViper())
+	proxyList, err := granularProxiesFromConfig(v)
+	if err != nil {
+		return nil, false, err
+	}
 	proxies := make(map[string]proxy.Proxy)
 	for _, p := range proxyList {
 		proxies[p.Name] = p
@@ -1312,29 +2577,27 @@ func granularProxyMapConfig(ruleConfig rule.Config) (*client.ProxyMap, bool) {
 
 	var proxyEnabled bool
 
-	connectProxyName := viper.GetString("connect_proxy_name")
+	connectProxyName := v.GetString("connect_proxy_name")
 	if connectProxyName != "" {
 		p, ok := proxies[connectProxyName]
 		if !ok {
-			log.Fatal().Msgf("connect proxy not found: %s", connectProxyName)
+			return nil, false, fmt.Errorf("connect proxy not found: %s", connectProxyName)
 		}
-		var err error
 		proxyMap.ConnectProxy, err = proxy.GetConnectProxy(p)
 		if err != nil {
-			log.Fatal().Msgf("error creating connect proxy: %v", err)
+			return nil, false, fmt.Errorf("error creating connect proxy: %w", err)
 		}
 		proxyEnabled = true
 	}
-	refreshProxyName := viper.GetString("refresh_proxy_name")
+	refreshProxyName := v.GetString("refresh_proxy_name")
 	if refreshProxyName != "" {
 		p, ok := proxies[refreshProxyName]
 		if !ok {
-			log.Fatal().Msgf("refresh proxy not found: %s", refreshProxyName)
+			return nil, false, fmt.Errorf("refresh proxy not found: %s", refreshProxyName)
 		}
-		var err error
 		proxyMap.RefreshProxy, err = proxy.GetRefreshProxy(p)
 		if err != nil {
-			log.Fatal().Msgf("error creating refresh proxy: %v", err)
+			return nil, false, fmt.Errorf("error creating refresh proxy: %w", err)
 		}
 		proxyEnabled = true
 	}
@@ -1342,11 +2605,11 @@ func granularProxyMapConfig(ruleConfig rule.Config) (*client.ProxyMap, bool) {
 	if subscribeProxyName != "" {
 		p, ok := proxies[subscribeProxyName]
 		if !ok {
-			log.Fatal().Msgf("subscribe proxy not found: %s", subscribeProxyName)
+			return nil, false, fmt.Errorf("subscribe proxy not found: %s", subscribeProxyName)
 		}
 		sp, err := proxy.GetSubscribeProxy(p)
 		if err != nil {
-			log.Fatal().Msgf("error creating subscribe proxy: %v", err)
+			return nil, false, fmt.Errorf("error creating subscribe proxy: %w", err)
 		}
 		proxyMap.SubscribeProxies[subscribeProxyName] = sp
 		proxyEnabled = true
@@ -1356,11 +2619,11 @@ func granularProxyMapConfig(ruleConfig rule.Config) (*client.ProxyMap, bool) {
 	if publishProxyName != "" {
 		p, ok := proxies[publishProxyName]
 		if !ok {
-			log.Fatal().Msgf("publish proxy not found: %s", publishProxyName)
+			return nil, false, fmt.Errorf("publish proxy not found: %s", publishProxyName)
 		}
 		pp, err := proxy.GetPublishProxy(p)
 		if err != nil {
-			log.Fatal().Msgf("error creating publish proxy: %v", err)
+			return nil, false, fmt.Errorf("error creating publish proxy: %w", err)
 		}
 		proxyMap.PublishProxies[publishProxyName] = pp
 		proxyEnabled = true
@@ -1373,11 +2636,11 @@ func granularProxyMapConfig(ruleConfig rule.Config) (*client.ProxyMap, bool) {
 		if subscribeProxyName != "" {
 			p, ok := proxies[subscribeProxyName]
 			if !ok {
-				log.Fatal().Msgf("subscribe proxy not found: %s", subscribeProxyName)
+				return nil, false, fmt.Errorf("subscribe proxy not found: %s", subscribeProxyName)
 			}
 			sp, err := proxy.GetSubscribeProxy(p)
 			if err != nil {
-				log.Fatal().Msgf("error creating subscribe proxy: %v", err)
+				return nil, false, fmt.Errorf("error creating subscribe proxy: %w", err)
 			}
 			proxyMap.SubscribeProxies[subscribeProxyName] = sp
 			proxyEnabled = true
@@ -1386,11 +2649,11 @@ func granularProxyMapConfig(ruleConfig rule.Config) (*client.ProxyMap, bool) {
 		if publishProxyName != "" {
 			p, ok := proxies[publishProxyName]
 			if !ok {
-				log.Fatal().Msgf("publish proxy not found: %s", publishProxyName)
+				return nil, false, fmt.Errorf("publish proxy not found: %s", publishProxyName)
 			}
 			pp, err := proxy.GetPublishProxy(p)
 			if err != nil {
-				log.Fatal().Msgf("error creating publish proxy: %v", err)
+				return nil, false, fmt.Errorf("error creating publish proxy: %w", err)
 			}
 			proxyMap.PublishProxies[publishProxyName] = pp
 			proxyEnabled = true
@@ -1401,11 +2664,11 @@ func granularProxyMapConfig(ruleConfig rule.Config) (*client.ProxyMap, bool) {
 	if rpcProxyName != "" {
 		p, ok := proxies[rpcProxyName]
 		if !ok {
-			log.Fatal().Msgf("rpc proxy not found: %s", rpcProxyName)
+			return nil, false, fmt.Errorf("rpc proxy not found: %s", rpcProxyName)
 		}
 		rp, err := proxy.GetRpcProxy(p)
 		if err != nil {
-			log.Fatal().Msgf("error creating rpc proxy: %v", err)
+			return nil, false, fmt.Errorf("error creating rpc proxy: %w", err)
 		}
 		proxyMap.RpcProxies[rpcProxyName] = rp
 		proxyEnabled = true
@@ -1416,27 +2679,130 @@ func granularProxyMapConfig(ruleConfig rule.Config) (*client.ProxyMap, bool) {
 		if rpcProxyName != "" {
 			p, ok := proxies[rpcProxyName]
 			if !ok {
-				log.Fatal().Msgf("rpc proxy not found: %s", rpcProxyName)
+				return nil, false, fmt.Errorf("rpc proxy not found: %s", rpcProxyName)
 			}
 			rp, err := proxy.GetRpcProxy(p)
 			if err != nil {
-				log.Fatal().Msgf("error creating rpc proxy: %v", err)
+				return nil, false, fmt.Errorf("error creating rpc proxy: %w", err)
 			}
 			proxyMap.RpcProxies[rpcProxyName] = rp
 			proxyEnabled = true
 		}
 	}
 
-	return proxyMap, proxyEnabled
+	return proxyMap, proxyEnabled, nil
 }
 
 var proxyNamePattern = "^[-a-zA-Z0-9_.]{2,}$"
 var proxyNameRe = regexp.MustCompile(proxyNamePattern)
 
-func granularProxiesFromConfig(v *viper.Viper) []proxy.Proxy {
+// tokenKeyConfig is a configuration of additional key to verify tokens.
+type tokenKeyConfig struct {
+	ID             string `mapstructure:"kid" json:"kid"`
+	HMACSecretKey  string `mapstructure:"hmac_secret_key" json:"hmac_secret_key"`
+	RSAPublicKey   string `mapstructure:"rsa_public_key" json:"rsa_public_key"`
+	ECDSAPublicKey string `mapstructure:"ecdsa_public_key" json:"ecdsa_public_key"`
+}
+
+func tokenKeysFromConfig(v *viper.Viper) []jwtverify.Key {
+	var configs []tokenKeyConfig
+	if !v.IsSet("token_keys") {
+		return nil
+	}
+	var err error
+	switch val := v.Get("token_keys").(type) {
+	case string:
+		err = json.Unmarshal([]byte(val), &configs)
+	case []interface{}:
+		decoderCfg := tools.DecoderConfig(&configs)
+		decoder, newErr := mapstructure.NewDecoder(decoderCfg)
+		if newErr != nil {
+			log.Fatal().Msg(newErr.Error())
+			return nil
+		}
+		err = decoder.Decode(v.Get("token_keys"))
+	default:
+		err = fmt.Errorf("unknown token_keys type: %T", val)
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("malformed token_keys")
+	}
+	keys := make([]jwtverify.Key, 0, len(configs))
+	for _, c := range configs {
+		key := jwtverify.Key{ID: c.ID, HMACSecretKey: c.HMACSecretKey}
+		if c.RSAPublicKey != "" {
+			pubKey, err := jwtutils.ParseRSAPublicKeyFromPEM([]byte(c.RSAPublicKey))
+			if err != nil {
+				log.Fatal().Msgf("error parsing RSA public key of token key %s: %v", c.ID, err)
+			}
+			key.RSAPublicKey = pubKey
+		}
+		if c.ECDSAPublicKey != "" {
+			pubKey, err := jwtutils.ParseECDSAPublicKeyFromPEM([]byte(c.ECDSAPublicKey))
+			if err != nil {
+				log.Fatal().Msgf("error parsing ECDSA public key of token key %s: %v", c.ID, err)
+			}
+			key.ECDSAPublicKey = pubKey
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func tenantsFromConfig(v *viper.Viper) []tenant.Tenant {
+	var tenants []tenant.Tenant
+	if !v.IsSet("tenants") {
+		return tenants
+	}
+	var err error
+	switch val := v.Get("tenants").(type) {
+	case string:
+		err = json.Unmarshal([]byte(val), &tenants)
+	case []interface{}:
+		decoderCfg := tools.DecoderConfig(&tenants)
+		decoder, newErr := mapstructure.NewDecoder(decoderCfg)
+		if newErr != nil {
+			log.Fatal().Msg(newErr.Error())
+			return tenants
+		}
+		err = decoder.Decode(v.Get("tenants"))
+	default:
+		err = fmt.Errorf("unknown tenants type: %T", val)
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("malformed tenants")
+	}
+	return tenants
+}
+
+// tenantRegistry creates tenant Registry from configuration. Every namespace
+// of tenant must be defined in channel configuration.
+func tenantRegistry(ruleConfig rule.Config, ruleContainer *rule.Container) (*tenant.Registry, error) {
+	tenants := tenantsFromConfig(viper.GetViper())
+	if len(tenants) == 0 {
+		return nil, nil
+	}
+	namespaces := make(map[string]struct{}, len(ruleConfig.Namespaces))
+	for _, ns := range ruleConfig.Namespaces {
+		namespaces[ns.Name] = struct{}{}
+	}
+	for _, t := range tenants {
+		for _, ns := range t.Namespaces {
+			if _, ok := namespaces[ns]; !ok {
+				return nil, fmt.Errorf("namespace %s of tenant %s not found", ns, t.Name)
+			}
+		}
+		if t.APIKey == "" && t.TokenHMACSecretKey == "" {
+			log.Warn().Str("tenant", t.Name).Msg("tenant has no API key and no token HMAC secret key")
+		}
+	}
+	return tenant.NewRegistry(tenants, ruleContainer.ChannelNamespace)
+}
+
+func granularProxiesFromConfig(v *viper.Viper) ([]proxy.Proxy, error) {
 	var proxies []proxy.Proxy
 	if !v.IsSet("proxies") {
-		return proxies
+		return proxies, nil
 	}
 	var err error
 	switch val := v.Get("proxies").(type) {
@@ -1446,33 +2812,42 @@ func granularProxiesFromConfig(v *viper.Viper) []proxy.Proxy {
 		decoderCfg := tools.DecoderConfig(&proxies)
 		decoder, newErr := mapstructure.NewDecoder(decoderCfg)
 		if newErr != nil {
-			log.Fatal().Msg(newErr.Error())
-			return proxies
+			return nil, newErr
 		}
 		err = decoder.Decode(v.Get("proxies"))
 	default:
 		err = fmt.Errorf("unknown proxies type: %T", val)
 	}
 	if err != nil {
-		log.Fatal().Err(err).Msg("malformed proxies")
+		return nil, fmt.Errorf("malformed proxies: %w", err)
 	}
 	names := map[string]struct{}{}
 	for _, p := range proxies {
 		if !proxyNameRe.Match([]byte(p.Name)) {
-			log.Fatal().Msgf("invalid proxy name: %s, must match %s regular expression", p.Name, proxyNamePattern)
+			return nil, fmt.Errorf("invalid proxy name: %s, must match %s regular expression", p.Name, proxyNamePattern)
 		}
 		if _, ok := names[p.Name]; ok {
-			log.Fatal().Msgf("duplicate proxy name: %s", p.Name)
+			return nil, fmt.Errorf("duplicate proxy name: %s", p.Name)
 		}
 		if p.Timeout == 0 {
 			p.Timeout = tools.Duration(time.Second)
 		}
 		if p.Endpoint == "" {
-			log.Fatal().Msgf("no endpoint set for proxy %s", p.Name)
+			return nil, fmt.Errorf("no endpoint set for proxy %s", p.Name)
 		}
 		names[p.Name] = struct{}{}
 	}
-	return proxies
+	return proxies, nil
+}
+
+// limitsConfig returns limits which can be changed with configuration reload.
+func limitsConfig() limits.Config {
+	v := viper.GetViper()
+	return limits.Config{
+		ClientChannelLimit:        v.GetInt("client_channel_limit"),
+		ClientUserConnectionLimit: v.GetInt("client_user_connection_limit"),
+		APIPresenceLimit:          v.GetInt("api_presence_limit"),
+	}
 }
 
 func nodeConfig(version string) centrifuge.Config {
@@ -1487,12 +2862,17 @@ func nodeConfig(version string) centrifuge.Config {
 	cfg.ClientExpiredSubCloseDelay = GetDuration("client_expired_sub_close_delay")
 	cfg.ClientStaleCloseDelay = GetDuration("client_stale_close_delay")
 	cfg.ClientQueueMaxSize = v.GetInt("client_queue_max_size")
-	cfg.ClientChannelLimit = v.GetInt("client_channel_limit")
+	// Client channel limit and user connection limit enforced by client
+	// handler, so they can be changed with configuration reload, see
+	// limitsConfig.
 	cfg.ClientChannelPositionCheckDelay = GetDuration("client_channel_position_check_delay")
-	cfg.UserConnectionLimit = v.GetInt("client_user_connection_limit")
 	cfg.NodeInfoMetricsAggregateInterval = GetDuration("node_info_metrics_aggregate_interval")
 	cfg.HistoryMaxPublicationLimit = v.GetInt("client_history_max_publication_limit")
 	cfg.RecoveryMaxPublicationLimit = v.GetInt("client_recovery_max_publication_limit")
+	// Coalesce concurrent identical history (including recovery), presence
+	// and presence stats engine requests, e.g. when many clients recover in
+	// the same channel after network blip.
+	cfg.UseSingleFlight = v.GetBool("use_singleflight")
 
 	level, ok := logStringToLevel[strings.ToLower(v.GetString("log_level"))]
 	if !ok {
@@ -1586,9 +2966,9 @@ func rpcNamespacesFromConfig(v *viper.Viper) []rule.RpcNamespace {
 	return ns
 }
 
-func websocketHandlerConfig() centrifuge.WebsocketConfig {
+func websocketHandlerConfig(throttleConfig throttle.Config, deltaManager *delta.Manager) wshandler.Config {
 	v := viper.GetViper()
-	cfg := centrifuge.WebsocketConfig{}
+	cfg := wshandler.Config{}
 	cfg.Compression = v.GetBool("websocket_compression")
 	cfg.CompressionLevel = v.GetInt("websocket_compression_level")
 	cfg.CompressionMinSize = v.GetInt("websocket_compression_min_size")
@@ -1599,9 +2979,33 @@ func websocketHandlerConfig() centrifuge.WebsocketConfig {
 	cfg.WriteTimeout = GetDuration("websocket_write_timeout")
 	cfg.MessageSizeLimit = v.GetInt("websocket_message_size_limit")
 	cfg.CheckOrigin = getCheckOrigin()
+	cfg.Throttle = throttleConfig
+	cfg.Delta = deltaManager
+	cfg.DisconnectPush = v.GetBool("websocket_disconnect_push")
+	cfg.Affinity = affinityConfig()
+	cfg.TokenHeader = v.GetBool("websocket_token_header")
+	cfg.TokenSubProtocol = v.GetBool("websocket_token_subprotocol")
 	return cfg
 }
 
+// affinityConfig returns node affinity configuration, nil if affinity
+// not enabled. Token is node name by default.
+func affinityConfig() *affinity.Config {
+	v := viper.GetViper()
+	if !v.GetBool("affinity") {
+		return nil
+	}
+	token := v.GetString("affinity_token")
+	if token == "" {
+		token = applicationName()
+	}
+	return &affinity.Config{
+		Token:      token,
+		URLParam:   v.GetString("affinity_url_param"),
+		CookieName: v.GetString("affinity_cookie_name"),
+	}
+}
+
 var warnAllowedOriginsOnce sync.Once
 
 func getCheckOrigin() func(r *http.Request) bool {
@@ -1641,7 +3045,7 @@ func getCheckOrigin() func(r *http.Request) bool {
 	}
 }
 
-func uniWebsocketHandlerConfig() uniws.Config {
+func uniWebsocketHandlerConfig(throttleConfig throttle.Config, deltaManager *delta.Manager) uniws.Config {
 	v := viper.GetViper()
 	return uniws.Config{
 		Compression:        v.GetBool("uni_websocket_compression"),
@@ -1653,24 +3057,70 @@ func uniWebsocketHandlerConfig() uniws.Config {
 		PingInterval:       GetDuration("uni_websocket_ping_interval"),
 		WriteTimeout:       GetDuration("uni_websocket_write_timeout"),
 		MessageSizeLimit:   v.GetInt("uni_websocket_message_size_limit"),
+		URLConnect:         v.GetBool("uni_websocket_url_connect"),
 		CheckOrigin:        getCheckOrigin(),
+		Throttle:           throttleConfig,
+		Delta:              deltaManager,
 	}
 }
 
-func uniSSEHandlerConfig() unisse.Config {
+func uniSSEHandlerConfig(throttleConfig throttle.Config, deltaManager *delta.Manager) unisse.Config {
 	return unisse.Config{
 		MaxRequestBodySize: viper.GetInt("uni_sse_max_request_body_size"),
+		WriteTimeout:       GetDuration("uni_sse_write_timeout"),
+		Throttle:           throttleConfig,
+		Delta:              deltaManager,
 	}
 }
 
-func uniStreamHandlerConfig() unihttpstream.Config {
+func uniStreamHandlerConfig(throttleConfig throttle.Config, deltaManager *delta.Manager) unihttpstream.Config {
 	return unihttpstream.Config{
 		MaxRequestBodySize: viper.GetInt("uni_http_stream_max_request_body_size"),
+		WriteTimeout:       GetDuration("uni_http_stream_write_timeout"),
+		Throttle:           throttleConfig,
+		Delta:              deltaManager,
+	}
+}
+
+func httpFallbackHandlerConfig(throttleConfig throttle.Config, deltaManager *delta.Manager) httpfallback.Config {
+	return httpfallback.Config{
+		MaxRequestBodySize: viper.GetInt("http_fallback_max_request_body_size"),
+		SessionTTL:         GetDuration("http_fallback_session_ttl"),
+		PollTimeout:        GetDuration("http_fallback_poll_timeout"),
+		PollBatchDelay:     GetDuration("http_fallback_poll_batch_delay"),
+		PollMaxBatchSize:   viper.GetInt("http_fallback_poll_max_batch_size"),
+		Throttle:           throttleConfig,
+		Delta:              deltaManager,
+	}
+}
+
+func uniGRPCHandlerConfig(throttleConfig throttle.Config) unigrpc.Config {
+	return unigrpc.Config{
+		Throttle: throttleConfig,
+	}
+}
+
+func clientThrottleConfig(ruleContainer *rule.Container) throttle.Config {
+	v := viper.GetViper()
+	return throttle.Config{
+		Rate:         v.GetFloat64("client_throttle_rate"),
+		Burst:        v.GetInt("client_throttle_burst"),
+		MaxQueueSize: v.GetInt("client_throttle_max_queue_size"),
+		FairChannels: v.GetBool("client_throttle_fair_channels"),
+		Coalesce: func(channel string) bool {
+			chOpts, found, err := ruleContainer.ChannelOptions(channel)
+			return err == nil && found && chOpts.CoalescePublications
+		},
 	}
 }
 
-func uniGRPCHandlerConfig() unigrpc.Config {
-	return unigrpc.Config{}
+func clientDeltaManager(ruleContainer *rule.Container) *delta.Manager {
+	return delta.NewManager(delta.Config{
+		Enabled: func(channel string) bool {
+			chOpts, found, err := ruleContainer.ChannelOptions(channel)
+			return err == nil && found && chOpts.DeltaPublications
+		},
+	})
 }
 
 func sockjsHandlerConfig() centrifuge.SockjsConfig {
@@ -1682,12 +3132,21 @@ func sockjsHandlerConfig() centrifuge.SockjsConfig {
 	cfg.WebsocketWriteBufferSize = v.GetInt("websocket_write_buffer_size")
 	cfg.WebsocketUseWriteBufferPool = v.GetBool("websocket_use_write_buffer_pool")
 	cfg.WebsocketWriteTimeout = GetDuration("websocket_write_timeout")
+	if size := v.GetInt("sockjs_websocket_read_buffer_size"); size > 0 {
+		cfg.WebsocketReadBufferSize = size
+	}
+	if size := v.GetInt("sockjs_websocket_write_buffer_size"); size > 0 {
+		cfg.WebsocketWriteBufferSize = size
+	}
+	if timeout := GetDuration("sockjs_websocket_write_timeout"); timeout > 0 {
+		cfg.WebsocketWriteTimeout = timeout
+	}
 	cfg.CheckOrigin = getCheckOrigin()
 	cfg.WebsocketCheckOrigin = getCheckOrigin()
 	return cfg
 }
 
-func adminHandlerConfig() admin.Config {
+func adminHandlerConfig(connections admin.ConnectionSource, keyspaceReport admin.KeyspaceReportSource) admin.Config {
 	v := viper.GetViper()
 	cfg := admin.Config{}
 	cfg.WebFS = webui.FS
@@ -1696,32 +3155,86 @@ func adminHandlerConfig() admin.Config {
 	cfg.Secret = v.GetString("admin_secret")
 	cfg.Insecure = v.GetBool("admin_insecure")
 	cfg.Prefix = v.GetString("admin_handler_prefix")
+	cfg.Debug = v.GetBool("admin_debug")
+	cfg.Connections = connections
+	cfg.ConnectionsExportInterval = GetDuration("admin_connections_export_interval")
+	cfg.KeyspaceReport = keyspaceReport
 	return cfg
 }
 
-func memoryEngine(n *centrifuge.Node) (centrifuge.Broker, centrifuge.PresenceManager, error) {
+// engineParts are parts of engine used by node.
+type engineParts struct {
+	broker          centrifuge.Broker
+	presenceManager centrifuge.PresenceManager
+	readyChecks     []health.Check
+}
+
+func isEngineName(name string) bool {
+	switch name {
+	case "memory", "redis", "tarantool", "postgres":
+		return true
+	default:
+		return false
+	}
+}
+
+// brokerOrEngineName returns name of broker used by node.
+func brokerOrEngineName(brokerName string, engineName string) string {
+	if brokerName != "" {
+		return brokerName
+	}
+	return engineName
+}
+
+// newEngine creates broker and presence manager of engine. Broker and
+// presence manager of different engines can be used together.
+func newEngine(n *centrifuge.Node, name string, ruleContainer *rule.Container, tenants *tenant.Registry) (*engineParts, error) {
+	var broker centrifuge.Broker
+	var presenceManager centrifuge.PresenceManager
+	var readyChecks []health.Check
+	var err error
+	switch name {
+	case "memory":
+		broker, presenceManager, readyChecks, err = memoryEngine(n)
+	case "redis":
+		broker, presenceManager, readyChecks, err = redisEngine(n, ruleContainer, tenants)
+	case "tarantool":
+		broker, presenceManager, readyChecks, err = tarantoolEngine(n)
+	case "postgres":
+		broker, presenceManager, readyChecks, err = postgresEngine(n)
+	default:
+		return nil, fmt.Errorf("unknown engine: %s", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &engineParts{broker: broker, presenceManager: presenceManager, readyChecks: readyChecks}, nil
+}
+
+func memoryEngine(n *centrifuge.Node) (centrifuge.Broker, centrifuge.PresenceManager, []health.Check, error) {
 	brokerConf, err := memoryBrokerConfig()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
-	broker, err := centrifuge.NewMemoryBroker(n, *brokerConf)
+	broker, err := memengine.NewBroker(n, *brokerConf)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	presenceManagerConf, err := memoryPresenceManagerConfig()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	presenceManager, err := centrifuge.NewMemoryPresenceManager(n, *presenceManagerConf)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
-	return broker, presenceManager, nil
+	return broker, presenceManager, nil, nil
 }
 
-func memoryBrokerConfig() (*centrifuge.MemoryBrokerConfig, error) {
-	return &centrifuge.MemoryBrokerConfig{
-		HistoryMetaTTL: GetDuration("history_meta_ttl", true),
+func memoryBrokerConfig() (*memengine.BrokerConfig, error) {
+	return &memengine.BrokerConfig{
+		HistoryMetaTTL:  GetDuration("history_meta_ttl", true),
+		HistoryMaxBytes: viper.GetInt64("memory_history_max_bytes"),
 	}, nil
 }
 
@@ -1729,8 +3242,9 @@ func memoryPresenceManagerConfig() (*centrifuge.MemoryPresenceManagerConfig, err
 	return &centrifuge.MemoryPresenceManagerConfig{}, nil
 }
 
-func addRedisShardCommonSettings(shardConf *centrifuge.RedisShardConfig) {
+func addRedisShardCommonSettings(shardConf *redisengine.ShardConfig) {
 	shardConf.DB = viper.GetInt("redis_db")
+	shardConf.User = viper.GetString("redis_user")
 	shardConf.Password = viper.GetString("redis_password")
 	shardConf.UseTLS = viper.GetBool("redis_tls")
 	shardConf.TLSSkipVerify = viper.GetBool("redis_tls_skip_verify")
@@ -1738,10 +3252,25 @@ func addRedisShardCommonSettings(shardConf *centrifuge.RedisShardConfig) {
 	shardConf.ConnectTimeout = GetDuration("redis_connect_timeout")
 	shardConf.ReadTimeout = GetDuration("redis_read_timeout")
 	shardConf.WriteTimeout = GetDuration("redis_write_timeout")
-}
-
-func getRedisShardConfigs() ([]centrifuge.RedisShardConfig, error) {
-	var shardConfigs []centrifuge.RedisShardConfig
+	shardConf.PubSubReadTimeout = GetDuration("redis_pubsub_read_timeout")
+	shardConf.PubSubNumConnections = viper.GetInt("redis_pubsub_num_connections")
+	// Pipeline wait is usually set in microseconds so GetDuration can't be used here.
+	pipelineMaxWait, err := time.ParseDuration(viper.GetString("redis_pipeline_max_wait"))
+	if err != nil {
+		log.Fatal().Msgf("malformed duration for key 'redis_pipeline_max_wait': %v", err)
+	}
+	shardConf.PipelineMaxWait = pipelineMaxWait
+	shardConf.PublishRetryBufferSize = viper.GetInt("redis_publish_retry_buffer_size")
+	shardConf.PublishRetryTimeout = GetDuration("redis_publish_retry_timeout")
+	compatibility, err := redisengine.CompatibilityByName(viper.GetString("redis_compatibility"))
+	if err != nil {
+		log.Fatal().Msgf("malformed value for key 'redis_compatibility': %v", err)
+	}
+	shardConf.Compatibility = compatibility
+}
+
+func getRedisShardConfigs() ([]redisengine.ShardConfig, error) {
+	var shardConfigs []redisengine.ShardConfig
 
 	clusterShards := viper.GetStringSlice("redis_cluster_address")
 	var useCluster bool
@@ -1757,7 +3286,7 @@ func getRedisShardConfigs() ([]centrifuge.RedisShardConfig, error) {
 					return nil, fmt.Errorf("malformed Redis Cluster address: %s", address)
 				}
 			}
-			conf := &centrifuge.RedisShardConfig{
+			conf := &redisengine.ShardConfig{
 				ClusterAddresses: clusterAddresses,
 			}
 			addRedisShardCommonSettings(conf)
@@ -1780,10 +3309,11 @@ func getRedisShardConfigs() ([]centrifuge.RedisShardConfig, error) {
 					return nil, fmt.Errorf("malformed Redis Sentinel address: %s", address)
 				}
 			}
-			conf := &centrifuge.RedisShardConfig{
+			conf := &redisengine.ShardConfig{
 				SentinelAddresses: sentinelAddresses,
 			}
 			addRedisShardCommonSettings(conf)
+			conf.SentinelUser = viper.GetString("redis_sentinel_user")
 			conf.SentinelPassword = viper.GetString("redis_sentinel_password")
 			conf.SentinelMasterName = viper.GetString("redis_sentinel_master_name")
 			if conf.SentinelMasterName == "" {
@@ -1799,7 +3329,7 @@ func getRedisShardConfigs() ([]centrifuge.RedisShardConfig, error) {
 		redisAddresses = []string{"127.0.0.1:6379"}
 	}
 	for _, redisAddress := range redisAddresses {
-		conf := &centrifuge.RedisShardConfig{
+		conf := &redisengine.ShardConfig{
 			Address: redisAddress,
 		}
 		addRedisShardCommonSettings(conf)
@@ -1808,15 +3338,51 @@ func getRedisShardConfigs() ([]centrifuge.RedisShardConfig, error) {
 	return shardConfigs, nil
 }
 
-func getRedisShards(n *centrifuge.Node) ([]*centrifuge.RedisShard, error) {
-	redisShardConfigs, err := getRedisShardConfigs()
-	if err != nil {
-		return nil, err
+// validateRedisShardConfigs checks that shards do not point to the same Redis
+// database, otherwise shards would share keys.
+func validateRedisShardConfigs(redisShardConfigs []redisengine.ShardConfig) error {
+	seen := map[string]struct{}{}
+	for _, conf := range redisShardConfigs {
+		var key string
+		switch {
+		case len(conf.ClusterAddresses) > 0:
+			key = "cluster:" + strings.Join(conf.ClusterAddresses, ",")
+		case len(conf.SentinelAddresses) > 0:
+			key = fmt.Sprintf("sentinel:%s:%s/%d", strings.Join(conf.SentinelAddresses, ","), conf.SentinelMasterName, conf.DB)
+		default:
+			key = fmt.Sprintf("%s/%d", conf.Address, conf.DB)
+		}
+		if _, ok := seen[key]; ok {
+			return fmt.Errorf("duplicate Redis shard: %s", key)
+		}
+		seen[key] = struct{}{}
+	}
+	return nil
+}
+
+// tenantKeyPrefix returns function to build Redis key prefix of channel. Keys
+// of tenant channels are prefixed with tenant name to isolate them from keys of
+// other tenants. Nil returned if there are no tenants.
+func tenantKeyPrefix(prefix string, tenants *tenant.Registry) func(ch string) string {
+	if !tenants.Enabled() {
+		return nil
 	}
-	redisShards := make([]*centrifuge.RedisShard, 0, len(redisShardConfigs))
+	if prefix == "" {
+		prefix = redisengine.DefaultPrefix
+	}
+	return func(ch string) string {
+		if name := tenants.ChannelTenant(ch); name != "" {
+			return prefix + ".tenant." + name
+		}
+		return prefix
+	}
+}
+
+func getRedisShards(n *centrifuge.Node, redisShardConfigs []redisengine.ShardConfig) ([]*redisengine.Shard, error) {
+	redisShards := make([]*redisengine.Shard, 0, len(redisShardConfigs))
 
 	for _, redisConf := range redisShardConfigs {
-		redisShard, err := centrifuge.NewRedisShard(n, redisConf)
+		redisShard, err := redisengine.NewShard(n, redisConf)
 		if err != nil {
 			return nil, err
 		}
@@ -1825,32 +3391,615 @@ func getRedisShards(n *centrifuge.Node) ([]*centrifuge.RedisShard, error) {
 	return redisShards, nil
 }
 
-func redisEngine(n *centrifuge.Node) (centrifuge.Broker, centrifuge.PresenceManager, error) {
-	redisShards, err := getRedisShards(n)
+func redisEngine(n *centrifuge.Node, ruleContainer *rule.Container, tenants *tenant.Registry) (centrifuge.Broker, centrifuge.PresenceManager, []health.Check, error) {
+	redisShardConfigs, err := getRedisShardConfigs()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := validateRedisShardConfigs(redisShardConfigs); err != nil {
+		return nil, nil, nil, err
+	}
+	redisShards, err := getRedisShards(n, redisShardConfigs)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	broker, err := centrifuge.NewRedisBroker(n, centrifuge.RedisBrokerConfig{
+	broker, err := redisengine.NewBroker(n, redisengine.BrokerConfig{
 		Shards:         redisShards,
 		Prefix:         viper.GetString("redis_prefix"),
+		KeyPrefix:      tenantKeyPrefix(viper.GetString("redis_prefix"), tenants),
 		UseLists:       viper.GetBool("redis_use_lists"),
 		HistoryMetaTTL: GetDuration("history_meta_ttl", true),
+
+		PubSubNumWorkers: viper.GetInt("redis_pubsub_num_workers"),
+		PubSubMaxWorkers: viper.GetInt("redis_pubsub_max_workers"),
+
+		PubSubHotChannelThreshold: viper.GetInt("redis_pubsub_hot_channel_threshold"),
+
+		PubSubResubscribeConcurrency: viper.GetInt("redis_pubsub_resubscribe_concurrency"),
+
+		ShardMigrationDelay: GetDuration("redis_shard_migration_delay"),
+
+		OrderingCheck: viper.GetBool("redis_ordering_check"),
+
+		IsPriorityChannel: func(ch string) bool {
+			chOpts, found, err := ruleContainer.ChannelOptions(ch)
+			return err == nil && found && chOpts.PriorityPublications
+		},
 	})
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	presenceManager, err := centrifuge.NewRedisPresenceManager(n, centrifuge.RedisPresenceManagerConfig{
+	presenceManager, err := redisengine.NewPresenceManager(n, redisengine.PresenceManagerConfig{
 		Shards:      redisShards,
 		Prefix:      viper.GetString("redis_prefix"),
+		KeyPrefix:   tenantKeyPrefix(viper.GetString("redis_prefix"), tenants),
 		PresenceTTL: GetDuration("presence_ttl", true),
+		MemberKeys: func(ch string) bool {
+			chOpts, found, err := ruleContainer.ChannelOptions(ch)
+			return err == nil && found && chOpts.PresenceMemberKeys
+		},
 	})
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	return broker, presenceManager, nil
+	readyChecks := append(health.RedisShardChecks(redisShardConfigs), health.RedisResubscribeCheck(broker))
+	return broker, presenceManager, readyChecks, nil
+}
+
+// redisShardAdder adds shards to Redis engine at runtime.
+type redisShardAdder struct {
+	node            *centrifuge.Node
+	broker          *redisengine.Broker
+	presenceManager *redisengine.PresenceManager
+
+	mu           sync.Mutex
+	shardConfigs []redisengine.ShardConfig
+}
+
+// AddRedisShard adds shard with address using common Redis settings from
+// configuration. Shard is not persisted – it must be also added to redis_address
+// in configuration to be used after restart.
+func (a *redisShardAdder) AddRedisShard(address string, since time.Time) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	conf := redisengine.ShardConfig{
+		Address: address,
+	}
+	addRedisShardCommonSettings(&conf)
+
+	shardConfigs := make([]redisengine.ShardConfig, 0, len(a.shardConfigs)+1)
+	shardConfigs = append(shardConfigs, a.shardConfigs...)
+	shardConfigs = append(shardConfigs, conf)
+	if err := validateRedisShardConfigs(shardConfigs); err != nil {
+		return 0, err
+	}
+
+	shard, err := redisengine.NewShard(a.node, conf)
+	if err != nil {
+		return 0, err
+	}
+	numMigrated, err := a.broker.AddShard(shard, since)
+	if err != nil {
+		return 0, err
+	}
+	if err := a.presenceManager.AddShard(shard); err != nil {
+		return 0, err
+	}
+	a.shardConfigs = shardConfigs
+	log.Warn().Str("address", address).Int("num_migrated_channels", numMigrated).Msg("Redis shard added, add it to redis_address in configuration to keep it after restart")
+	return numMigrated, nil
+}
+
+// engineRedisShardAdder returns survey.RedisShardAdder of Redis engine. Nil
+// returned for other engines.
+func engineRedisShardAdder(n *centrifuge.Node, engineName string, broker centrifuge.Broker, presenceManager centrifuge.PresenceManager) (survey.RedisShardAdder, error) {
+	if engineName != "redis" {
+		return nil, nil
+	}
+	redisBroker, ok := broker.(*redisengine.Broker)
+	if !ok {
+		return nil, fmt.Errorf("unexpected broker type: %T", broker)
+	}
+	redisPresenceManager, ok := presenceManager.(*redisengine.PresenceManager)
+	if !ok {
+		// Shards can't be added when presence kept by another engine.
+		return nil, nil
+	}
+	shardConfigs, err := getRedisShardConfigs()
+	if err != nil {
+		return nil, err
+	}
+	return &redisShardAdder{
+		node:            n,
+		broker:          redisBroker,
+		presenceManager: redisPresenceManager,
+		shardConfigs:    shardConfigs,
+	}, nil
+}
+
+// engineKeyspaceReport starts Redis keyspace reporter if enabled. Nil returned
+// if reporter not enabled or engine is not Redis.
+func engineKeyspaceReport(broker centrifuge.Broker, brokerName string, ruleContainer *rule.Container) (admin.KeyspaceReportSource, error) {
+	if !viper.GetBool("redis_keyspace_report") || brokerName == "nats" {
+		return nil, nil
+	}
+	redisBroker, ok := broker.(*redisengine.Broker)
+	if !ok {
+		return nil, errors.New("keyspace report only supported by Redis engine")
+	}
+	// Only configured namespaces labelled separately to keep metric
+	// cardinality bounded.
+	var namespaces []string
+	for _, ns := range ruleContainer.Config().Namespaces {
+		namespaces = append(namespaces, ns.Name)
+	}
+	reporter := redisengine.NewKeyspaceReporter(redisBroker, redisengine.KeyspaceReporterConfig{
+		Namespace:     nsmetrics.New(ruleContainer.ChannelNamespace, namespaces).Label,
+		Interval:      GetDuration("redis_keyspace_report_interval"),
+		SampleKeys:    viper.GetInt("redis_keyspace_report_sample_keys"),
+		MemorySamples: viper.GetInt("redis_keyspace_report_memory_samples"),
+	})
+	go reporter.Run(context.Background())
+	return reporter, nil
+}
+
+// historyMetaFunc is an adapter to use function as api.HistoryMetaReader.
+type historyMetaFunc func(ch string) (api.HistoryMetaInfo, error)
+
+func (f historyMetaFunc) HistoryMeta(ch string) (api.HistoryMetaInfo, error) {
+	return f(ch)
+}
+
+func controlGuardConfig() controlguard.Config {
+	return controlguard.Config{
+		RateLimit:          viper.GetInt("control_rate_limit"),
+		DuplicateLimit:     viper.GetInt("control_duplicate_limit"),
+		SuppressionTimeout: GetDuration("control_suppression_timeout"),
+	}
+}
+
+// presenceRefreshManager wraps presence manager to write unchanged presence
+// updates to engine once in presence_refresh_interval if it's set.
+func presenceRefreshManager(pm centrifuge.PresenceManager) centrifuge.PresenceManager {
+	interval := GetDuration("presence_refresh_interval")
+	if interval == 0 {
+		return pm
+	}
+	updateInterval := GetDuration("client_presence_update_interval")
+	if ttl := GetDuration("presence_ttl", true); interval+updateInterval >= ttl {
+		log.Fatal().Msgf("presence_ttl (%s) must be greater than sum of presence_refresh_interval (%s) and client_presence_update_interval (%s)", ttl, interval, updateInterval)
+	}
+	log.Info().Str("interval", interval.String()).Msg("presence refresh interval set")
+	return presencerefresh.New(pm, interval)
+}
+
+// presenceLimitedFunc is an adapter to use function as api.PresenceLimiter.
+type presenceLimitedFunc func(ctx context.Context, ch string, limit int, partial bool) (api.PresenceLimitedResult, error)
+
+func (f presenceLimitedFunc) PresenceLimited(ctx context.Context, ch string, limit int, partial bool) (api.PresenceLimitedResult, error) {
+	return f(ctx, ch, limit, partial)
+}
+
+// enginePresenceLimiter returns api.PresenceLimiter backed by engine presence
+// manager. Nil returned if presence manager can't read presence partially.
+func enginePresenceLimiter(presenceManager centrifuge.PresenceManager) api.PresenceLimiter {
+	pm, ok := presenceManager.(*redisengine.PresenceManager)
+	if !ok {
+		return nil
+	}
+	return presenceLimitedFunc(func(ctx context.Context, ch string, limit int, partial bool) (api.PresenceLimitedResult, error) {
+		result, err := pm.PresenceLimited(ctx, ch, limit, partial)
+		return api.PresenceLimitedResult(result), err
+	})
+}
+
+// engineHistoryMetaReader returns api.HistoryMetaReader backed by engine broker.
+// Nil returned if broker can't return history meta or history is not kept by
+// engine broker.
+func engineHistoryMetaReader(broker centrifuge.Broker, brokerName string) api.HistoryMetaReader {
+	if brokerName == "nats" {
+		return nil
+	}
+	switch b := broker.(type) {
+	case *memengine.Broker:
+		return historyMetaFunc(func(ch string) (api.HistoryMetaInfo, error) {
+			meta, err := b.HistoryMeta(ch)
+			return api.HistoryMetaInfo(meta), err
+		})
+	case *redisengine.Broker:
+		return historyMetaFunc(func(ch string) (api.HistoryMetaInfo, error) {
+			meta, err := b.HistoryMeta(ch)
+			return api.HistoryMetaInfo(meta), err
+		})
+	default:
+		return nil
+	}
+}
+
+// engineLocker returns Locker backed by engine. Nil returned if engine can't
+// provide locks shared by all nodes.
+func engineLocker(n *centrifuge.Node, engineName string, brokerName string, broker centrifuge.Broker) (api.Locker, error) {
+	switch engineName {
+	case "memory":
+		if brokerName == "nats" {
+			// Memory locks are not shared between nodes.
+			return nil, nil
+		}
+		locker := memengine.NewLocker()
+		locker.Run()
+		return locker, nil
+	case "redis":
+		redisBroker, ok := broker.(*redisengine.Broker)
+		if !ok {
+			return nil, fmt.Errorf("unexpected broker type: %T", broker)
+		}
+		return redisengine.NewLocker(n, redisBroker)
+	default:
+		return nil, nil
+	}
+}
+
+// engineMetaStore returns ChannelMetaStore backed by engine. Nil returned if
+// engine can't provide channel meta shared by all nodes.
+func engineMetaStore(n *centrifuge.Node, engineName string, brokerName string, broker centrifuge.Broker) (api.ChannelMetaStore, error) {
+	switch engineName {
+	case "memory":
+		if brokerName == "nats" {
+			// Memory meta is not shared between nodes.
+			return nil, nil
+		}
+		metaStore := memengine.NewMetaStore()
+		metaStore.Run()
+		return metaStore, nil
+	case "redis":
+		redisBroker, ok := broker.(*redisengine.Broker)
+		if !ok {
+			return nil, fmt.Errorf("unexpected broker type: %T", broker)
+		}
+		return redisengine.NewMetaStore(n, redisBroker)
+	default:
+		return nil, nil
+	}
+}
+
+// engineReadPositionStore returns ReadPositionStore backed by engine. Nil
+// returned if engine can't keep read positions shared by all nodes.
+func engineReadPositionStore(engineName string, brokerName string, broker centrifuge.Broker) (api.ReadPositionStore, error) {
+	switch engineName {
+	case "memory":
+		if brokerName == "nats" {
+			// Memory read positions are not shared between nodes.
+			return nil, nil
+		}
+		return memengine.NewReadPositionStore(), nil
+	case "redis":
+		redisBroker, ok := broker.(*redisengine.Broker)
+		if !ok {
+			return nil, fmt.Errorf("unexpected broker type: %T", broker)
+		}
+		return redisengine.NewReadPositionStore(redisBroker)
+	default:
+		return nil, nil
+	}
+}
+
+// enginePublishDedupStore returns publish dedup Store backed by engine. Nil
+// returned if engine can't keep publication results shared by all nodes.
+func enginePublishDedupStore(engineName string, brokerName string, broker centrifuge.Broker) (pubdedup.Store, error) {
+	switch engineName {
+	case "memory":
+		if brokerName == "nats" {
+			// Memory publication results are not shared between nodes.
+			return nil, nil
+		}
+		s := memengine.NewPublishDedupStore()
+		s.Run()
+		return s, nil
+	case "redis":
+		redisBroker, ok := broker.(*redisengine.Broker)
+		if !ok {
+			return nil, fmt.Errorf("unexpected broker type: %T", broker)
+		}
+		return redisengine.NewPublishDedupStore(redisBroker)
+	default:
+		return nil, nil
+	}
+}
+
+// engineInboxStore returns user inbox Store backed by engine. Nil returned if
+// engine can't keep inboxes shared by all nodes.
+func engineInboxStore(engineName string, brokerName string, broker centrifuge.Broker) (inbox.Store, error) {
+	switch engineName {
+	case "memory":
+		if brokerName == "nats" {
+			// Memory inboxes are not shared between nodes.
+			return nil, nil
+		}
+		s := memengine.NewInboxStore()
+		s.Run()
+		return s, nil
+	case "redis":
+		redisBroker, ok := broker.(*redisengine.Broker)
+		if !ok {
+			return nil, fmt.Errorf("unexpected broker type: %T", broker)
+		}
+		return redisengine.NewInboxStore(redisBroker)
+	default:
+		return nil, nil
+	}
+}
+
+// enginePushDeviceStore returns push DeviceStore backed by engine. Nil
+// returned if engine can't keep devices shared by all nodes.
+func enginePushDeviceStore(engineName string, brokerName string, broker centrifuge.Broker) (pushnotify.DeviceStore, error) {
+	switch engineName {
+	case "memory":
+		if brokerName == "nats" {
+			// Memory devices are not shared between nodes.
+			return nil, nil
+		}
+		return memengine.NewPushDeviceStore(), nil
+	case "redis":
+		redisBroker, ok := broker.(*redisengine.Broker)
+		if !ok {
+			return nil, fmt.Errorf("unexpected broker type: %T", broker)
+		}
+		return redisengine.NewPushDeviceStore(redisBroker)
+	default:
+		return nil, nil
+	}
+}
+
+// pushNotifierFromConfig creates Notifier with senders of configured push
+// services.
+func pushNotifierFromConfig(n *centrifuge.Node, store pushnotify.DeviceStore) (*pushnotify.Notifier, error) {
+	senders := map[string]pushnotify.Sender{}
+	if path := viper.GetString("push_fcm_service_account_file"); path != "" {
+		serviceAccount, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading FCM service account file: %w", err)
+		}
+		sender, err := pushnotify.NewFCMSender(pushnotify.FCMConfig{
+			ServiceAccount: serviceAccount,
+			ProjectID:      viper.GetString("push_fcm_project_id"),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error creating FCM sender: %w", err)
+		}
+		senders[pushnotify.PlatformFCM] = sender
+	}
+	if path := viper.GetString("push_apns_key_file"); path != "" {
+		key, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading APNs key file: %w", err)
+		}
+		endpoint := pushnotify.APNsProductionEndpoint
+		if viper.GetBool("push_apns_development") {
+			endpoint = pushnotify.APNsDevelopmentEndpoint
+		}
+		sender, err := pushnotify.NewAPNsSender(pushnotify.APNsConfig{
+			Key:      key,
+			KeyID:    viper.GetString("push_apns_key_id"),
+			TeamID:   viper.GetString("push_apns_team_id"),
+			Topic:    viper.GetString("push_apns_topic"),
+			Endpoint: endpoint,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error creating APNs sender: %w", err)
+		}
+		senders[pushnotify.PlatformAPNs] = sender
+	}
+	if len(senders) == 0 {
+		return nil, errors.New("no push services configured, set push_fcm_service_account_file or push_apns_key_file")
+	}
+	return pushnotify.NewNotifier(n, pushnotify.Config{
+		Store:     store,
+		Senders:   senders,
+		QueueSize: viper.GetInt("push_queue_size"),
+		Workers:   viper.GetInt("push_workers"),
+	}), nil
+}
+
+// engineChannelOptionsStore returns store of channel option overrides backed
+// by engine. Nil returned if engine can't keep overrides shared by all nodes.
+func engineChannelOptionsStore(engineName string, brokerName string, broker centrifuge.Broker) (rule.OverrideStore, error) {
+	switch engineName {
+	case "memory":
+		if brokerName == "nats" {
+			// Memory overrides are not shared between nodes.
+			return nil, nil
+		}
+		return memengine.NewChannelOptionsStore(), nil
+	case "redis":
+		redisBroker, ok := broker.(*redisengine.Broker)
+		if !ok {
+			return nil, fmt.Errorf("unexpected broker type: %T", broker)
+		}
+		return redisengine.NewChannelOptionsStore(redisBroker)
+	default:
+		return nil, nil
+	}
+}
+
+// engineChannelGroupStore returns channel group Store backed by engine. Nil
+// returned if engine can't keep channel groups shared by all nodes.
+func engineChannelGroupStore(engineName string, brokerName string, broker centrifuge.Broker) (fanin.Store, error) {
+	switch engineName {
+	case "memory":
+		if brokerName == "nats" {
+			// Memory channel groups are not shared between nodes.
+			return nil, nil
+		}
+		return memengine.NewChannelGroupStore(), nil
+	case "redis":
+		redisBroker, ok := broker.(*redisengine.Broker)
+		if !ok {
+			return nil, fmt.Errorf("unexpected broker type: %T", broker)
+		}
+		return redisengine.NewChannelGroupStore(redisBroker)
+	default:
+		return nil, nil
+	}
+}
+
+// publicationIDGenerator returns generator of publication IDs configured with
+// publication_id_generator option. Nil returned if IDs not configured.
+func publicationIDGenerator() (pubid.Generator, error) {
+	v := viper.GetViper()
+	switch v.GetString("publication_id_generator") {
+	case "":
+		return nil, nil
+	case "uuid":
+		return pubid.UUIDGenerator{}, nil
+	case "snowflake":
+		var nodeID int64
+		if v.IsSet("publication_id_node_id") {
+			nodeID = v.GetInt64("publication_id_node_id")
+		} else {
+			// Derived node IDs can collide, so explicit unique node ID for every
+			// node should be configured when IDs must be globally unique.
+			nodeID = pubid.NodeIDFromString(applicationName())
+		}
+		return pubid.NewSnowflakeGenerator(nodeID)
+	default:
+		return nil, fmt.Errorf("unknown publication ID generator: %s", v.GetString("publication_id_generator"))
+	}
+}
+
+func apiAuditLog(n *centrifuge.Node, broker centrifuge.Broker) (*apiaudit.Log, error) {
+	var sink apiaudit.Sink
+	switch viper.GetString("api_audit_sink") {
+	case "file":
+		fileSink, err := apiaudit.NewFileSink(apiaudit.FileConfig{
+			Path:       viper.GetString("api_audit_file_path"),
+			MaxSize:    viper.GetInt64("api_audit_file_max_size"),
+			MaxAge:     GetDuration("api_audit_file_max_age", true),
+			MaxBackups: viper.GetInt("api_audit_file_max_backups"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		sink = fileSink
+	case "redis":
+		redisBroker, ok := broker.(*redisengine.Broker)
+		if !ok {
+			return nil, errors.New("redis audit sink requires Redis engine")
+		}
+		redisSink, err := redisengine.NewAuditLog(n, redisBroker, redisengine.AuditLogConfig{
+			MaxLength: viper.GetInt("api_audit_redis_max_length"),
+			TTL:       GetDuration("api_audit_redis_ttl", true),
+		})
+		if err != nil {
+			return nil, err
+		}
+		sink = redisSink
+	default:
+		return nil, fmt.Errorf("unknown API audit sink: %s", viper.GetString("api_audit_sink"))
+	}
+	return apiaudit.New(n, apiaudit.Config{
+		Sink:      sink,
+		QueueSize: viper.GetInt("api_audit_queue_size"),
+	}), nil
+}
+
+func runRedisAPIConsumer(n *centrifuge.Node, broker centrifuge.Broker, apiExecutor *api.Executor) error {
+	redisBroker, ok := broker.(*redisengine.Broker)
+	if !ok {
+		return fmt.Errorf("unexpected broker type: %T", broker)
+	}
+	apiHandler := api.NewHandler(n, apiExecutor, api.Config{})
+	consumer, err := redisengine.NewAPIConsumer(n, redisBroker, redisengine.APIConsumerConfig{
+		Queues:  redisAPIQueuesFromConfig(viper.GetViper()),
+		Handler: apiHandler.HandleCommands,
+	})
+	if err != nil {
+		return err
+	}
+	consumer.Run()
+	return nil
+}
+
+// redisAPIQueuesFromConfig allows to unmarshal Redis API queues.
+func redisAPIQueuesFromConfig(v *viper.Viper) []redisengine.APIQueueConfig {
+	var queues []redisengine.APIQueueConfig
+	if !v.IsSet("redis_api_queues") {
+		return queues
+	}
+	var err error
+	switch val := v.Get("redis_api_queues").(type) {
+	case string:
+		err = json.Unmarshal([]byte(val), &queues)
+	case []interface{}:
+		decoderCfg := tools.DecoderConfig(&queues)
+		decoder, newErr := mapstructure.NewDecoder(decoderCfg)
+		if newErr != nil {
+			log.Fatal().Msg(newErr.Error())
+			return queues
+		}
+		err = decoder.Decode(v.Get("redis_api_queues"))
+	default:
+		err = fmt.Errorf("unknown redis_api_queues type: %T", val)
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("malformed redis_api_queues")
+	}
+	return queues
+}
+
+func channelGroupsFromConfig(v *viper.Viper) []fanin.Group {
+	var groups []fanin.Group
+	if !v.IsSet("channel_groups") {
+		return groups
+	}
+	var err error
+	switch val := v.Get("channel_groups").(type) {
+	case string:
+		err = json.Unmarshal([]byte(val), &groups)
+	case []interface{}:
+		decoderCfg := tools.DecoderConfig(&groups)
+		decoder, newErr := mapstructure.NewDecoder(decoderCfg)
+		if newErr != nil {
+			log.Fatal().Msg(newErr.Error())
+			return groups
+		}
+		err = decoder.Decode(v.Get("channel_groups"))
+	default:
+		err = fmt.Errorf("unknown channel_groups type: %T", val)
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("malformed channel_groups")
+	}
+	return groups
+}
+
+func bridgeRemotesFromConfig(v *viper.Viper) []bridge.Remote {
+	var remotes []bridge.Remote
+	if !v.IsSet("bridge_remotes") {
+		return remotes
+	}
+	var err error
+	switch val := v.Get("bridge_remotes").(type) {
+	case string:
+		err = json.Unmarshal([]byte(val), &remotes)
+	case []interface{}:
+		decoderCfg := tools.DecoderConfig(&remotes)
+		decoder, newErr := mapstructure.NewDecoder(decoderCfg)
+		if newErr != nil {
+			log.Fatal().Msg(newErr.Error())
+			return remotes
+		}
+		err = decoder.Decode(v.Get("bridge_remotes"))
+	default:
+		err = fmt.Errorf("unknown bridge_remotes type: %T", val)
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("malformed bridge_remotes")
+	}
+	return remotes
 }
 
 func getTarantoolShardConfigs() ([]tntengine.ShardConfig, error) {
@@ -1906,26 +4055,66 @@ func getTarantoolShards() ([]*tntengine.Shard, error) {
 	return tarantoolShards, nil
 }
 
-func tarantoolEngine(n *centrifuge.Node) (centrifuge.Broker, centrifuge.PresenceManager, error) {
+func tarantoolEngine(n *centrifuge.Node) (centrifuge.Broker, centrifuge.PresenceManager, []health.Check, error) {
 	tarantoolShards, err := getTarantoolShards()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	broker, err := tntengine.NewBroker(n, tntengine.BrokerConfig{
 		Shards:         tarantoolShards,
 		HistoryMetaTTL: GetDuration("history_meta_ttl", true),
 	})
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	presenceManager, err := tntengine.NewPresenceManager(n, tntengine.PresenceManagerConfig{
 		Shards:      tarantoolShards,
 		PresenceTTL: GetDuration("presence_ttl", true),
 	})
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
+	}
+	readyChecks := make([]health.Check, 0, len(tarantoolShards))
+	for _, shard := range tarantoolShards {
+		readyChecks = append(readyChecks, health.Check{
+			Name: "tarantool " + tools.GetLogAddresses(shard.Addresses()),
+			Func: shard.Ping,
+		})
 	}
-	return broker, presenceManager, nil
+	return broker, presenceManager, readyChecks, nil
+}
+
+func postgresEngine(n *centrifuge.Node) (centrifuge.Broker, centrifuge.PresenceManager, []health.Check, error) {
+	db, err := pgengine.NewDB(pgengine.DBConfig{
+		DSN:          viper.GetString("postgres_dsn"),
+		Prefix:       viper.GetString("postgres_prefix"),
+		MaxOpenConns: viper.GetInt("postgres_max_open_conns"),
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cleanupInterval := GetDuration("postgres_cleanup_interval", true)
+	broker, err := pgengine.NewBroker(n, pgengine.BrokerConfig{
+		DB:              db,
+		HistoryMetaTTL:  GetDuration("history_meta_ttl", true),
+		CleanupInterval: cleanupInterval,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	presenceManager, err := pgengine.NewPresenceManager(n, pgengine.PresenceManagerConfig{
+		DB:              db,
+		PresenceTTL:     GetDuration("presence_ttl", true),
+		CleanupInterval: cleanupInterval,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	readyChecks := []health.Check{
+		{Name: "postgres", Func: db.Ping},
+		{Name: "postgres listener", Func: broker.Check},
+	}
+	return broker, presenceManager, readyChecks, nil
 }
 
 type logHandler struct {
@@ -1995,8 +4184,24 @@ const (
 	HandlerUniSSE
 	// HandlerUniHTTPStream enables unidirectional stream endpoint.
 	HandlerUniHTTPStream
+	// HandlerHTTPFallback enables bidirectional HTTP-streaming and XHR-polling endpoint.
+	HandlerHTTPFallback
 )
 
+// clientHandlerFlags are handlers of client connections.
+const clientHandlerFlags = HandlerWebsocket | HandlerSockJS | HandlerUniWebsocket | HandlerUniSSE | HandlerUniHTTPStream | HandlerHTTPFallback
+
+// nodeRoleHandlerFlags removes handlers not served by node with role.
+func nodeRoleHandlerFlags(role noderole.Role, flags HandlerFlag) HandlerFlag {
+	if !role.ServesClients() {
+		flags &^= clientHandlerFlags
+	}
+	if !role.ServesAPI() {
+		flags &^= HandlerAPI
+	}
+	return flags
+}
+
 var handlerText = map[HandlerFlag]string{
 	HandlerWebsocket:     "websocket",
 	HandlerSockJS:        "SockJS",
@@ -2008,10 +4213,11 @@ var handlerText = map[HandlerFlag]string{
 	HandlerUniWebsocket:  "uni_websocket",
 	HandlerUniSSE:        "uni_sse",
 	HandlerUniHTTPStream: "uni_http_stream",
+	HandlerHTTPFallback:  "http_fallback",
 }
 
 func (flags HandlerFlag) String() string {
-	flagsOrdered := []HandlerFlag{HandlerWebsocket, HandlerSockJS, HandlerAPI, HandlerAdmin, HandlerPrometheus, HandlerDebug, HandlerHealth, HandlerUniWebsocket, HandlerUniSSE, HandlerUniHTTPStream}
+	flagsOrdered := []HandlerFlag{HandlerWebsocket, HandlerSockJS, HandlerAPI, HandlerAdmin, HandlerPrometheus, HandlerDebug, HandlerHealth, HandlerUniWebsocket, HandlerUniSSE, HandlerUniHTTPStream, HandlerHTTPFallback}
 	var endpoints []string
 	for _, flag := range flagsOrdered {
 		text, ok := handlerText[flag]
@@ -2026,16 +4232,21 @@ func (flags HandlerFlag) String() string {
 }
 
 // Mux returns a mux including set of default handlers for Centrifugo server.
-func Mux(n *centrifuge.Node, apiExecutor *api.Executor, flags HandlerFlag, proxyEnabled bool) *http.ServeMux {
+func Mux(n *centrifuge.Node, apiExecutor *api.Executor, tenants *tenant.Registry, connections admin.ConnectionSource, keyspaceReport admin.KeyspaceReportSource, throttleConfig throttle.Config, deltaManager *delta.Manager, flags HandlerFlag, proxyEnabled bool, readyChecks []health.Check) *http.ServeMux {
 	mux := http.NewServeMux()
 	v := viper.GetViper()
 
+	trustedProxies, err := proxyprotocol.ParseNetworks(v.GetStringSlice("client_ip_trusted_proxies"))
+	if err != nil {
+		log.Fatal().Msgf("error parsing client_ip_trusted_proxies: %v", err)
+	}
+
 	if flags&HandlerDebug != 0 {
-		mux.Handle("/debug/pprof/", middleware.LogRequest(http.HandlerFunc(pprof.Index)))
-		mux.Handle("/debug/pprof/cmdline", middleware.LogRequest(http.HandlerFunc(pprof.Cmdline)))
-		mux.Handle("/debug/pprof/profile", middleware.LogRequest(http.HandlerFunc(pprof.Profile)))
-		mux.Handle("/debug/pprof/symbol", middleware.LogRequest(http.HandlerFunc(pprof.Symbol)))
-		mux.Handle("/debug/pprof/trace", middleware.LogRequest(http.HandlerFunc(pprof.Trace)))
+		mux.Handle("/debug/pprof/", middleware.LogRequest(n, http.HandlerFunc(pprof.Index)))
+		mux.Handle("/debug/pprof/cmdline", middleware.LogRequest(n, http.HandlerFunc(pprof.Cmdline)))
+		mux.Handle("/debug/pprof/profile", middleware.LogRequest(n, http.HandlerFunc(pprof.Profile)))
+		mux.Handle("/debug/pprof/symbol", middleware.LogRequest(n, http.HandlerFunc(pprof.Symbol)))
+		mux.Handle("/debug/pprof/trace", middleware.LogRequest(n, http.HandlerFunc(pprof.Trace)))
 	}
 
 	if flags&HandlerWebsocket != 0 {
@@ -2044,7 +4255,7 @@ func Mux(n *centrifuge.Node, apiExecutor *api.Executor, flags HandlerFlag, proxy
 		if wsPrefix == "" {
 			wsPrefix = "/"
 		}
-		mux.Handle(wsPrefix, middleware.LogRequest(middleware.HeadersToContext(proxyEnabled, centrifuge.NewWebsocketHandler(n, websocketHandlerConfig()))))
+		mux.Handle(wsPrefix, middleware.LogRequest(n, middleware.TraceIDToContext(middleware.ClientIPToContext(trustedProxies, middleware.HeadersToContext(proxyEnabled, wshandler.NewHandler(n, websocketHandlerConfig(throttleConfig, deltaManager)))))))
 	}
 
 	if flags&HandlerSockJS != 0 {
@@ -2052,7 +4263,15 @@ func Mux(n *centrifuge.Node, apiExecutor *api.Executor, flags HandlerFlag, proxy
 		sockjsConfig := sockjsHandlerConfig()
 		sockjsPrefix := strings.TrimRight(v.GetString("sockjs_handler_prefix"), "/")
 		sockjsConfig.HandlerPrefix = sockjsPrefix
-		mux.Handle(sockjsPrefix+"/", middleware.LogRequest(middleware.HeadersToContext(proxyEnabled, centrifuge.NewSockjsHandler(n, sockjsConfig))))
+		mux.Handle(sockjsPrefix+"/", middleware.LogRequest(n, middleware.TraceIDToContext(middleware.ClientIPToContext(trustedProxies, middleware.HeadersToContext(proxyEnabled, centrifuge.NewSockjsHandler(n, sockjsConfig))))))
+	}
+
+	if flags&HandlerHTTPFallback != 0 {
+		// register HTTP-streaming and XHR-polling connection endpoints.
+		fallbackConfig := httpFallbackHandlerConfig(throttleConfig, deltaManager)
+		fallbackPrefix := strings.TrimRight(v.GetString("http_fallback_handler_prefix"), "/")
+		fallbackConfig.HandlerPrefix = fallbackPrefix
+		mux.Handle(fallbackPrefix+"/", middleware.LogRequest(n, middleware.TraceIDToContext(middleware.ClientIPToContext(trustedProxies, middleware.HeadersToContext(proxyEnabled, middleware.CORS(getCheckOrigin(), httpfallback.NewHandler(n, fallbackConfig)))))))
 	}
 
 	if flags&HandlerUniWebsocket != 0 {
@@ -2061,7 +4280,7 @@ func Mux(n *centrifuge.Node, apiExecutor *api.Executor, flags HandlerFlag, proxy
 		if wsPrefix == "" {
 			wsPrefix = "/"
 		}
-		mux.Handle(wsPrefix, middleware.LogRequest(middleware.HeadersToContext(proxyEnabled, uniws.NewHandler(n, uniWebsocketHandlerConfig()))))
+		mux.Handle(wsPrefix, middleware.LogRequest(n, middleware.TraceIDToContext(middleware.ClientIPToContext(trustedProxies, middleware.HeadersToContext(proxyEnabled, uniws.NewHandler(n, uniWebsocketHandlerConfig(throttleConfig, deltaManager)))))))
 	}
 
 	if flags&HandlerUniSSE != 0 {
@@ -2070,7 +4289,7 @@ func Mux(n *centrifuge.Node, apiExecutor *api.Executor, flags HandlerFlag, proxy
 		if ssePrefix == "" {
 			ssePrefix = "/"
 		}
-		mux.Handle(ssePrefix, middleware.LogRequest(middleware.HeadersToContext(proxyEnabled, middleware.CORS(getCheckOrigin(), unisse.NewHandler(n, uniSSEHandlerConfig())))))
+		mux.Handle(ssePrefix, middleware.LogRequest(n, middleware.TraceIDToContext(middleware.ClientIPToContext(trustedProxies, middleware.HeadersToContext(proxyEnabled, middleware.CORS(getCheckOrigin(), unisse.NewHandler(n, uniSSEHandlerConfig(throttleConfig, deltaManager))))))))
 	}
 
 	if flags&HandlerUniHTTPStream != 0 {
@@ -2079,7 +4298,7 @@ func Mux(n *centrifuge.Node, apiExecutor *api.Executor, flags HandlerFlag, proxy
 		if streamPrefix == "" {
 			streamPrefix = "/"
 		}
-		mux.Handle(streamPrefix, middleware.LogRequest(middleware.HeadersToContext(proxyEnabled, middleware.CORS(getCheckOrigin(), unihttpstream.NewHandler(n, uniStreamHandlerConfig())))))
+		mux.Handle(streamPrefix, middleware.LogRequest(n, middleware.TraceIDToContext(middleware.ClientIPToContext(trustedProxies, middleware.HeadersToContext(proxyEnabled, middleware.CORS(getCheckOrigin(), unihttpstream.NewHandler(n, uniStreamHandlerConfig(throttleConfig, deltaManager))))))))
 	}
 
 	if flags&HandlerAPI != 0 {
@@ -2090,9 +4309,9 @@ func Mux(n *centrifuge.Node, apiExecutor *api.Executor, flags HandlerFlag, proxy
 			apiPrefix = "/"
 		}
 		if viper.GetBool("api_insecure") {
-			mux.Handle(apiPrefix, middleware.LogRequest(middleware.Post(apiHandler)))
+			mux.Handle(apiPrefix, middleware.LogRequest(n, middleware.Post(apiHandler)))
 		} else {
-			mux.Handle(apiPrefix, middleware.LogRequest(middleware.Post(middleware.APIKeyAuth(viper.GetString("api_key"), apiHandler))))
+			mux.Handle(apiPrefix, middleware.LogRequest(n, middleware.Post(middleware.TenantAPIKeyAuth(n, viper.GetString("api_key"), tenants, apiHandler))))
 		}
 	}
 
@@ -2102,13 +4321,13 @@ func Mux(n *centrifuge.Node, apiExecutor *api.Executor, flags HandlerFlag, proxy
 		if prometheusPrefix == "" {
 			prometheusPrefix = "/"
 		}
-		mux.Handle(prometheusPrefix, middleware.LogRequest(promhttp.Handler()))
+		mux.Handle(prometheusPrefix, middleware.LogRequest(n, promhttp.Handler()))
 	}
 
 	if flags&HandlerAdmin != 0 {
 		// register admin web interface API endpoints.
 		adminPrefix := strings.TrimRight(v.GetString("admin_handler_prefix"), "/")
-		mux.Handle(adminPrefix+"/", admin.NewHandler(n, apiExecutor, adminHandlerConfig()))
+		mux.Handle(adminPrefix+"/", admin.NewHandler(n, apiExecutor, adminHandlerConfig(connections, keyspaceReport)))
 	}
 
 	if flags&HandlerHealth != 0 {
@@ -2116,7 +4335,16 @@ func Mux(n *centrifuge.Node, apiExecutor *api.Executor, flags HandlerFlag, proxy
 		if healthPrefix == "" {
 			healthPrefix = "/"
 		}
-		mux.Handle(healthPrefix, middleware.LogRequest(health.NewHandler(n, health.Config{})))
+		mux.Handle(healthPrefix, middleware.LogRequest(n, health.NewHandler(n, health.Config{})))
+
+		readyPrefix := strings.TrimRight(v.GetString("ready_handler_prefix"), "/")
+		if readyPrefix == "" {
+			readyPrefix = "/"
+		}
+		mux.Handle(readyPrefix, middleware.LogRequest(n, health.NewReadyHandler(n, health.ReadyConfig{
+			Checks:  readyChecks,
+			Timeout: GetDuration("ready_timeout"),
+		})))
 	}
 
 	return mux
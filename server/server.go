@@ -0,0 +1,309 @@
+// Package server allows to embed Centrifugo real-time server into Go
+// application. Server is configured with Config instead of Centrifugo
+// configuration file and does not use global logger: log entries of server
+// are passed to Config.LogHandler. Application mounts HTTP handlers of Server
+// into own HTTP server and can register custom RPC methods and interceptors.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/centrifugal/centrifugo/v3/internal/api"
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/client"
+	"github.com/centrifugal/centrifugo/v3/internal/interceptor"
+	"github.com/centrifugal/centrifugo/v3/internal/jwtverify"
+	"github.com/centrifugal/centrifugo/v3/internal/memengine"
+	"github.com/centrifugal/centrifugo/v3/internal/middleware"
+	"github.com/centrifugal/centrifugo/v3/internal/proxyprotocol"
+	"github.com/centrifugal/centrifugo/v3/internal/redisengine"
+	"github.com/centrifugal/centrifugo/v3/internal/rule"
+	"github.com/centrifugal/centrifugo/v3/internal/survey"
+	"github.com/centrifugal/centrifugo/v3/internal/tools"
+	"github.com/centrifugal/centrifugo/v3/internal/wshandler"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+// Types of Centrifugo used in Config and Server methods.
+type (
+	// ChannelConfig contains channel options and namespaces.
+	ChannelConfig = rule.Config
+	// ChannelNamespace is a named set of channel options.
+	ChannelNamespace = rule.ChannelNamespace
+	// ChannelOptions of channels.
+	ChannelOptions = rule.ChannelOptions
+	// Duration used in channel options.
+	Duration = tools.Duration
+	// TokenConfig contains keys to verify connection and subscription tokens.
+	TokenConfig = jwtverify.VerifierConfig
+	// WebsocketConfig of WebSocket handler.
+	WebsocketConfig = wshandler.Config
+	// RedisShardConfig is a configuration of Redis shard.
+	RedisShardConfig = redisengine.ShardConfig
+	// RPCExtensionFunc handles custom RPC method called by client.
+	RPCExtensionFunc = client.RPCExtensionFunc
+	// Interceptor is a compiled-in extension, see PublishInterceptor and
+	// SubscribeInterceptor.
+	Interceptor = interceptor.Interceptor
+	// PublishInterceptor is called around publishing.
+	PublishInterceptor = interceptor.PublishInterceptor
+	// SubscribeInterceptor is called on subscriptions.
+	SubscribeInterceptor = interceptor.SubscribeInterceptor
+	// Publication passed to PublishInterceptor.
+	Publication = interceptor.Publication
+	// Subscription passed to SubscribeInterceptor.
+	Subscription = interceptor.Subscription
+	// APIExecutor executes server API commands.
+	APIExecutor = api.Executor
+	// APIError is an error of server API command.
+	APIError = apiproto.Error
+)
+
+// DefaultChannelConfig returns default channel configuration to start
+// customizing Config.Channels from.
+func DefaultChannelConfig() ChannelConfig {
+	return rule.DefaultConfig
+}
+
+// Engine types.
+const (
+	EngineMemory = "memory"
+	EngineRedis  = "redis"
+)
+
+// ErrUnknownEngine returned from New for unsupported engine type.
+var ErrUnknownEngine = errors.New("unknown engine")
+
+// RedisConfig of Redis engine.
+type RedisConfig struct {
+	// Shards to use, at least one shard required.
+	Shards []RedisShardConfig
+	// Prefix of Redis keys and channels, "centrifugo" by default.
+	Prefix string
+	// UseLists turns on keeping history in Redis lists instead of streams.
+	UseLists bool
+}
+
+// EngineConfig of engine keeping history and presence and delivering
+// publications between nodes.
+type EngineConfig struct {
+	// Type of engine, EngineMemory by default.
+	Type  string
+	Redis RedisConfig
+}
+
+// Config of Server.
+type Config struct {
+	// Name of node, must be unique for nodes running with Redis engine.
+	// Centrifuge generates name if not set.
+	Name string
+	// Version of application reported in node info.
+	Version string
+	// Channels configuration, DefaultChannelConfig used if nil.
+	Channels *ChannelConfig
+	// Token contains keys to verify connection and subscription JWT.
+	Token TokenConfig
+	// Engine configuration.
+	Engine EngineConfig
+	// Websocket handler configuration.
+	Websocket WebsocketConfig
+	// TrustedProxies is a list of CIDRs or IP addresses of proxies which are
+	// allowed to pass client IP address in X-Real-IP or X-Forwarded-For
+	// headers. Headers are ignored by default.
+	TrustedProxies []string
+	// APIKey protects APIHandler, empty value means that application
+	// protects API handler itself.
+	APIKey string
+	// LogLevel of server log entries.
+	LogLevel centrifuge.LogLevel
+	// LogHandler receives server log entries. Nothing logged if not set.
+	LogHandler centrifuge.LogHandler
+}
+
+// Server is an embedded Centrifugo server.
+type Server struct {
+	config        Config
+	node          *centrifuge.Node
+	ruleContainer *rule.Container
+	clientHandler *client.Handler
+	interceptors  *interceptor.Chain
+	api           *api.Executor
+	httpAPI       *api.Executor
+
+	trustedProxies []*net.IPNet
+}
+
+// New creates Server. Server must be started with Run.
+func New(c Config) (*Server, error) {
+	channels := DefaultChannelConfig()
+	if c.Channels != nil {
+		channels = *c.Channels
+	}
+	if err := channels.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid channel config: %w", err)
+	}
+	ruleContainer := rule.NewContainer(channels)
+
+	trustedProxies, err := proxyprotocol.ParseNetworks(c.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
+	node, err := centrifuge.New(centrifuge.Config{
+		Name:             c.Name,
+		Version:          c.Version,
+		MetricsNamespace: "centrifugo",
+		LogLevel:         c.LogLevel,
+		LogHandler:       c.LogHandler,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := setupEngine(node, c.Engine); err != nil {
+		return nil, err
+	}
+
+	tokenVerifier, err := jwtverify.NewVerifierJWT(c.Token, ruleContainer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token config: %w", err)
+	}
+	clientHandler := client.NewHandler(node, ruleContainer, tokenVerifier, &client.ProxyMap{}, false)
+	interceptors := interceptor.NewChain()
+	clientHandler.SetInterceptors(interceptors)
+
+	surveyCaller := survey.NewCaller(node, ruleContainer, survey.Config{})
+	newExecutor := func(protocol string) *api.Executor {
+		e := api.NewExecutor(node, ruleContainer, surveyCaller, protocol)
+		e.SetInterceptors(interceptors)
+		return e
+	}
+
+	return &Server{
+		config:        c,
+		node:          node,
+		ruleContainer: ruleContainer,
+		clientHandler: clientHandler,
+		interceptors:  interceptors,
+		api:           newExecutor("go"),
+		httpAPI:       newExecutor("http"),
+
+		trustedProxies: trustedProxies,
+	}, nil
+}
+
+func setupEngine(node *centrifuge.Node, c EngineConfig) error {
+	switch c.Type {
+	case "", EngineMemory:
+		broker, err := memengine.NewBroker(node, memengine.BrokerConfig{})
+		if err != nil {
+			return err
+		}
+		presenceManager, err := centrifuge.NewMemoryPresenceManager(node, centrifuge.MemoryPresenceManagerConfig{})
+		if err != nil {
+			return err
+		}
+		node.SetBroker(broker)
+		node.SetPresenceManager(presenceManager)
+	case EngineRedis:
+		if len(c.Redis.Shards) == 0 {
+			return errors.New("no Redis shards configured")
+		}
+		shards := make([]*redisengine.Shard, 0, len(c.Redis.Shards))
+		for _, shardConfig := range c.Redis.Shards {
+			shard, err := redisengine.NewShard(node, shardConfig)
+			if err != nil {
+				return err
+			}
+			shards = append(shards, shard)
+		}
+		prefix := c.Redis.Prefix
+		if prefix == "" {
+			prefix = "centrifugo"
+		}
+		broker, err := redisengine.NewBroker(node, redisengine.BrokerConfig{
+			Shards:   shards,
+			Prefix:   prefix,
+			UseLists: c.Redis.UseLists,
+		})
+		if err != nil {
+			return err
+		}
+		presenceManager, err := redisengine.NewPresenceManager(node, redisengine.PresenceManagerConfig{
+			Shards: shards,
+			Prefix: prefix,
+		})
+		if err != nil {
+			return err
+		}
+		node.SetBroker(broker)
+		node.SetPresenceManager(presenceManager)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownEngine, c.Type)
+	}
+	return nil
+}
+
+// Node returns Centrifuge node of Server.
+func (s *Server) Node() *centrifuge.Node {
+	return s.node
+}
+
+// API returns executor of server API commands to call them from Go code.
+func (s *Server) API() *APIExecutor {
+	return s.api
+}
+
+// Publish publishes data into channel with channel options applied. Error
+// returned is *APIError if publication was rejected.
+func (s *Server) Publish(ctx context.Context, channel string, data []byte) (centrifuge.StreamPosition, error) {
+	resp := s.api.Publish(ctx, &apiproto.PublishRequest{Channel: channel, Data: data})
+	if resp.Error != nil {
+		return centrifuge.StreamPosition{}, resp.Error
+	}
+	return centrifuge.StreamPosition{Offset: resp.Result.Offset, Epoch: resp.Result.Epoch}, nil
+}
+
+// SetRPCExtension registers handler of custom RPC method. Must be called
+// before Run.
+func (s *Server) SetRPCExtension(method string, fn RPCExtensionFunc) {
+	s.clientHandler.SetRPCExtension(method, fn)
+}
+
+// RegisterInterceptor registers interceptor of publications and
+// subscriptions. Interceptors called in order of registration.
+func (s *Server) RegisterInterceptor(i Interceptor) error {
+	return s.interceptors.Register(i)
+}
+
+// Run sets up client event handlers and runs node.
+func (s *Server) Run() error {
+	if err := s.clientHandler.Setup(); err != nil {
+		return err
+	}
+	return s.node.Run()
+}
+
+// Shutdown disconnects clients and shuts down node.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.node.Shutdown(ctx)
+}
+
+// WebsocketHandler returns handler of bidirectional WebSocket connections.
+func (s *Server) WebsocketHandler() http.Handler {
+	return middleware.TraceIDToContext(middleware.ClientIPToContext(s.trustedProxies, wshandler.NewHandler(s.node, s.config.Websocket)))
+}
+
+// APIHandler returns handler of HTTP server API. Handler checks API key if
+// Config.APIKey set.
+func (s *Server) APIHandler() http.Handler {
+	h := http.Handler(api.NewHandler(s.node, s.httpAPI, api.Config{}))
+	if s.config.APIKey != "" {
+		h = middleware.TenantAPIKeyAuth(s.node, s.config.APIKey, nil, h)
+	}
+	return middleware.Post(h)
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/apiproto"
+	"github.com/centrifugal/centrifugo/v3/internal/jwtverify"
+
+	"github.com/centrifugal/centrifuge"
+	"github.com/stretchr/testify/require"
+)
+
+type testInterceptor struct{}
+
+func (testInterceptor) Name() string {
+	return "test"
+}
+
+func (testInterceptor) BeforePublish(_ context.Context, pub *Publication) error {
+	if pub.Channel == "denied" {
+		return centrifuge.ErrorPermissionDenied
+	}
+	return nil
+}
+
+func (testInterceptor) AfterPublish(context.Context, Publication, centrifuge.StreamPosition, error) {}
+
+func TestNewUnknownEngine(t *testing.T) {
+	_, err := New(Config{Engine: EngineConfig{Type: "unknown"}})
+	require.True(t, errors.Is(err, ErrUnknownEngine))
+	_, err = New(Config{Engine: EngineConfig{Type: EngineRedis}})
+	require.Error(t, err)
+}
+
+func TestNewInvalidTokenConfig(t *testing.T) {
+	_, err := New(Config{Token: jwtverify.VerifierConfig{Keys: []jwtverify.Key{{HMACSecretKey: "secret"}}}})
+	require.Error(t, err)
+}
+
+func TestServer(t *testing.T) {
+	channels := DefaultChannelConfig()
+	channels.HistorySize = 10
+	channels.HistoryTTL = Duration(time.Minute)
+	s, err := New(Config{Channels: &channels, APIKey: "secret"})
+	require.NoError(t, err)
+	require.NoError(t, s.RegisterInterceptor(testInterceptor{}))
+	require.NoError(t, s.Run())
+	defer func() { _ = s.Shutdown(context.Background()) }()
+
+	sp, err := s.Publish(context.Background(), "test", []byte("{}"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), sp.Offset)
+	_, err = s.Publish(context.Background(), "denied", []byte("{}"))
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, apiproto.ErrorPermissionDenied.Code, apiErr.Code)
+
+	server := httptest.NewServer(s.APIHandler())
+	defer server.Close()
+
+	body := `{"method":"publish","params":{"channel":"test","data":{}}}`
+	httpResp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	_ = httpResp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "apikey secret")
+	httpResp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = httpResp.Body.Close()
+	require.Equal(t, http.StatusOK, httpResp.StatusCode)
+}
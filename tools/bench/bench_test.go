@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderSummary(t *testing.T) {
+	r := newRecorder("test")
+	for i := 1; i <= 1000; i++ {
+		r.observe(time.Duration(i) * time.Millisecond)
+	}
+	r.fail()
+	s := r.summary()
+	require.Equal(t, 1000, s.Count)
+	require.Equal(t, 1, s.Errors)
+	require.Equal(t, 500*time.Millisecond, s.P50)
+	require.Equal(t, 990*time.Millisecond, s.P99)
+	require.Equal(t, 1000*time.Millisecond, s.Max)
+
+	require.Equal(t, summary{Name: "empty"}, newRecorder("empty").summary())
+}
+
+func TestDeliveryLatency(t *testing.T) {
+	latency, ok := deliveryLatency(newPayload(10))
+	require.True(t, ok)
+	require.True(t, latency >= 0)
+	_, ok = deliveryLatency([]byte(`{}`))
+	require.False(t, ok)
+}
+
+func TestRunEngineMemory(t *testing.T) {
+	var out bytes.Buffer
+	err := runEngine(engineOptions{
+		commonOptions: commonOptions{
+			Channels:      2,
+			ChannelPrefix: "bench",
+			PublishRate:   200,
+			Publishers:    2,
+			Duration:      200 * time.Millisecond,
+		},
+		Engine: "memory",
+	}, &out)
+	require.NoError(t, err)
+	require.Contains(t, out.String(), "delivery")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/memengine"
+	"github.com/centrifugal/centrifugo/v3/internal/redisengine"
+
+	"github.com/centrifugal/centrifuge"
+)
+
+type engineOptions struct {
+	commonOptions
+	Engine       string
+	RedisAddress string
+	HistorySize  int
+	HistoryTTL   time.Duration
+}
+
+// deliveryGrace is a time to wait for in-flight publications after publishing
+// phase finished.
+const deliveryGrace = time.Second
+
+// brokerHandler records delivery latency of publications received from Broker.
+type brokerHandler struct {
+	delivery *recorder
+}
+
+func (h *brokerHandler) HandlePublication(_ string, pub *centrifuge.Publication, _ centrifuge.StreamPosition) error {
+	if latency, ok := deliveryLatency(pub.Data); ok {
+		h.delivery.observe(latency)
+	}
+	return nil
+}
+
+func (h *brokerHandler) HandleJoin(_ string, _ *centrifuge.ClientInfo) error {
+	return nil
+}
+
+func (h *brokerHandler) HandleLeave(_ string, _ *centrifuge.ClientInfo) error {
+	return nil
+}
+
+func (h *brokerHandler) HandleControl(_ []byte) error {
+	return nil
+}
+
+func newBenchBroker(node *centrifuge.Node, o engineOptions) (centrifuge.Broker, error) {
+	switch o.Engine {
+	case "memory":
+		return memengine.NewBroker(node, memengine.BrokerConfig{})
+	case "redis":
+		shard, err := redisengine.NewShard(node, redisengine.ShardConfig{Address: o.RedisAddress})
+		if err != nil {
+			return nil, err
+		}
+		return redisengine.NewBroker(node, redisengine.BrokerConfig{
+			Shards: []*redisengine.Shard{shard},
+			Prefix: "centrifugo_bench",
+		})
+	default:
+		return nil, fmt.Errorf("unknown engine: %s", o.Engine)
+	}
+}
+
+func runEngine(o engineOptions, out io.Writer) error {
+	if o.Channels < 1 {
+		return fmt.Errorf("at least one channel required")
+	}
+	node, err := centrifuge.New(centrifuge.DefaultConfig)
+	if err != nil {
+		return err
+	}
+	broker, err := newBenchBroker(node, o)
+	if err != nil {
+		return err
+	}
+
+	subscribe := newRecorder("subscribe")
+	publish := newRecorder("publish")
+	delivery := newRecorder("delivery")
+
+	if err := broker.Run(&brokerHandler{delivery: delivery}); err != nil {
+		return err
+	}
+
+	for i := 0; i < o.Channels; i++ {
+		started := time.Now()
+		if err := broker.Subscribe(o.channel(i)); err != nil {
+			subscribe.fail()
+			continue
+		}
+		subscribe.observe(time.Since(started))
+	}
+
+	opts := centrifuge.PublishOptions{HistorySize: o.HistorySize}
+	if o.HistorySize > 0 {
+		opts.HistoryTTL = o.HistoryTTL
+	}
+
+	ctx, cancel := benchContext(o.Duration)
+	defer cancel()
+	started := time.Now()
+	runPublishers(ctx, o.PublishRate, o.Publishers, func(i int) {
+		publishStarted := time.Now()
+		if _, err := broker.Publish(o.channel(i), newPayload(o.PayloadSize), opts); err != nil {
+			publish.fail()
+			return
+		}
+		publish.observe(time.Since(publishStarted))
+	})
+	elapsed := time.Since(started)
+	time.Sleep(deliveryGrace)
+
+	for i := 0; i < o.Channels; i++ {
+		_ = broker.Unsubscribe(o.channel(i))
+	}
+
+	report(out, elapsed, subscribe, publish, delivery)
+	return nil
+}
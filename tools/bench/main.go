@@ -0,0 +1,181 @@
+// Command bench drives synthetic load against running Centrifugo node or
+// directly against engine implementation and reports latency percentiles.
+//
+// Load against node (WebSocket clients subscribe, publications sent over
+// HTTP API):
+//
+//	go run ./tools/bench node --clients 1000 --channels 10 --publish-rate 100 --api-key secret --token-secret secret
+//
+// Load against engine (Broker of memory or Redis engine in-process):
+//
+//	go run ./tools/bench engine --engine redis --redis-address localhost:6379 --channels 100 --publish-rate 5000
+//
+// Delivery latency is measured using send time embedded in publication data,
+// so load generator must be the only publisher into benchmark channels.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type commonOptions struct {
+	Channels      int
+	ChannelPrefix string
+	PublishRate   int
+	Publishers    int
+	PayloadSize   int
+	Duration      time.Duration
+}
+
+func (o commonOptions) channel(i int) string {
+	return fmt.Sprintf("%s%d", o.ChannelPrefix, i%o.Channels)
+}
+
+// payload is a data of benchmark publication.
+type payload struct {
+	// SentAt is a time of publish in Unix nanoseconds.
+	SentAt int64  `json:"sent_at"`
+	Pad    string `json:"pad,omitempty"`
+}
+
+func newPayload(size int) []byte {
+	data, _ := json.Marshal(payload{SentAt: time.Now().UnixNano(), Pad: strings.Repeat("x", size)})
+	return data
+}
+
+// deliveryLatency returns time passed since payload was published.
+func deliveryLatency(data []byte) (time.Duration, bool) {
+	var p payload
+	if err := json.Unmarshal(data, &p); err != nil || p.SentAt == 0 {
+		return 0, false
+	}
+	return time.Since(time.Unix(0, p.SentAt)), true
+}
+
+// pacingInterval is how often pace wakes up to send due operations.
+const pacingInterval = 10 * time.Millisecond
+
+// runPublishers calls publish with channel index at rate per second using
+// numWorkers goroutines until ctx done.
+func runPublishers(ctx context.Context, rate int, numWorkers int, publish func(i int)) {
+	if rate <= 0 {
+		<-ctx.Done()
+		return
+	}
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	jobs := make(chan int, numWorkers)
+	var wg sync.WaitGroup
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				publish(i)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(jobs)
+
+	started := time.Now()
+	ticker := time.NewTicker(pacingInterval)
+	defer ticker.Stop()
+	var sent int
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			due := int(time.Since(started).Seconds() * float64(rate))
+			for ; sent < due; sent++ {
+				select {
+				case jobs <- sent:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// benchContext is done after duration or on interrupt.
+func benchContext(duration time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	go func() {
+		select {
+		case <-sigCh:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sigCh)
+	}()
+	return ctx, cancel
+}
+
+func addCommonFlags(cmd *cobra.Command, o *commonOptions) {
+	cmd.Flags().IntVar(&o.Channels, "channels", 1, "number of channels, subscribers and publications spread over channels")
+	cmd.Flags().StringVar(&o.ChannelPrefix, "channel-prefix", "bench", "prefix of channel names")
+	cmd.Flags().IntVar(&o.PublishRate, "publish-rate", 10, "publications per second over all channels")
+	cmd.Flags().IntVar(&o.Publishers, "publishers", 4, "number of concurrent publishers")
+	cmd.Flags().IntVar(&o.PayloadSize, "payload-size", 0, "size of padding added to publication data")
+	cmd.Flags().DurationVar(&o.Duration, "duration", 10*time.Second, "duration of publishing phase")
+}
+
+func main() {
+	rootCmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Centrifugo load generator",
+	}
+
+	var nodeOpts nodeOptions
+	nodeCmd := &cobra.Command{
+		Use:   "node",
+		Short: "Connect WebSocket clients to running node, subscribe and publish over HTTP API",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runNode(nodeOpts); err != nil {
+				fmt.Printf("error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	addCommonFlags(nodeCmd, &nodeOpts.commonOptions)
+	nodeCmd.Flags().StringVar(&nodeOpts.WebsocketURL, "ws-url", "ws://localhost:8000/connection/websocket", "WebSocket endpoint")
+	nodeCmd.Flags().StringVar(&nodeOpts.APIURL, "api-url", "http://localhost:8000/api", "HTTP API endpoint")
+	nodeCmd.Flags().StringVar(&nodeOpts.APIKey, "api-key", "", "HTTP API key")
+	nodeCmd.Flags().StringVar(&nodeOpts.TokenSecret, "token-secret", "", "HMAC secret to generate connection tokens, anonymous connections used if not set")
+	nodeCmd.Flags().IntVar(&nodeOpts.Clients, "clients", 100, "number of clients, each subscribes to one channel")
+	nodeCmd.Flags().IntVar(&nodeOpts.ConnectRate, "connect-rate", 500, "new connections per second")
+
+	var engineOpts engineOptions
+	engineCmd := &cobra.Command{
+		Use:   "engine",
+		Short: "Subscribe and publish using engine Broker directly",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runEngine(engineOpts, os.Stdout); err != nil {
+				fmt.Printf("error: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+	addCommonFlags(engineCmd, &engineOpts.commonOptions)
+	engineCmd.Flags().StringVar(&engineOpts.Engine, "engine", "memory", "engine to use: memory or redis")
+	engineCmd.Flags().StringVar(&engineOpts.RedisAddress, "redis-address", "localhost:6379", "Redis address when redis engine used")
+	engineCmd.Flags().IntVar(&engineOpts.HistorySize, "history-size", 0, "history size of channels, zero means no history")
+	engineCmd.Flags().DurationVar(&engineOpts.HistoryTTL, "history-ttl", time.Minute, "history TTL of channels")
+
+	rootCmd.AddCommand(nodeCmd, engineCmd)
+	_ = rootCmd.Execute()
+}
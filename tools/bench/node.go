@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/centrifugal/centrifugo/v3/internal/cli"
+	"github.com/centrifugal/centrifugo/v3/internal/jwtverify"
+
+	"github.com/gorilla/websocket"
+)
+
+type nodeOptions struct {
+	commonOptions
+	WebsocketURL string
+	APIURL       string
+	APIKey       string
+	TokenSecret  string
+	Clients      int
+	ConnectRate  int
+}
+
+// Methods of client protocol commands.
+const (
+	methodConnect   = 0
+	methodSubscribe = 1
+)
+
+type command struct {
+	ID     uint32      `json:"id"`
+	Method int         `json:"method,omitempty"`
+	Params interface{} `json:"params,omitempty"`
+}
+
+type replyError struct {
+	Code    uint32 `json:"code"`
+	Message string `json:"message"`
+}
+
+type reply struct {
+	ID     uint32          `json:"id,omitempty"`
+	Error  *replyError     `json:"error,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+type push struct {
+	Type    int             `json:"type,omitempty"`
+	Channel string          `json:"channel"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type publication struct {
+	Data json.RawMessage `json:"data"`
+}
+
+// benchClient is a minimal client of JSON protocol over WebSocket.
+type benchClient struct {
+	conn     *websocket.Conn
+	delivery *recorder
+	replies  chan reply
+}
+
+func dialClient(url string, delivery *recorder) (*benchClient, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &benchClient{conn: conn, delivery: delivery, replies: make(chan reply, 1)}
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *benchClient) readLoop() {
+	defer close(c.replies)
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		// Several replies may be sent in one frame separated by new line.
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if len(line) == 0 {
+				continue
+			}
+			var r reply
+			if err := json.Unmarshal(line, &r); err != nil {
+				continue
+			}
+			if r.ID > 0 {
+				c.replies <- r
+				continue
+			}
+			c.handlePush(r.Result)
+		}
+	}
+}
+
+func (c *benchClient) handlePush(data []byte) {
+	var p push
+	if err := json.Unmarshal(data, &p); err != nil || p.Type != 0 {
+		return
+	}
+	var pub publication
+	if err := json.Unmarshal(p.Data, &pub); err != nil {
+		return
+	}
+	if latency, ok := deliveryLatency(pub.Data); ok {
+		c.delivery.observe(latency)
+	}
+}
+
+// call sends command and waits for its reply. Commands sent one by one so
+// reply always matches last command.
+func (c *benchClient) call(cmd command) error {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return err
+	}
+	select {
+	case r, ok := <-c.replies:
+		if !ok {
+			return errors.New("connection closed")
+		}
+		if r.Error != nil {
+			return fmt.Errorf("error %d: %s", r.Error.Code, r.Error.Message)
+		}
+		return nil
+	case <-time.After(10 * time.Second):
+		return errors.New("reply timeout")
+	}
+}
+
+func (c *benchClient) close() {
+	_ = c.conn.Close()
+}
+
+func connectClient(o nodeOptions, i int, connect, subscribe, delivery *recorder) (*benchClient, error) {
+	var token string
+	if o.TokenSecret != "" {
+		var err error
+		token, err = cli.GenerateToken(jwtverify.VerifierConfig{HMACSecretKey: o.TokenSecret}, "bench_"+strconv.Itoa(i), int64(time.Hour.Seconds()))
+		if err != nil {
+			return nil, err
+		}
+	}
+	started := time.Now()
+	c, err := dialClient(o.WebsocketURL, delivery)
+	if err != nil {
+		connect.fail()
+		return nil, err
+	}
+	if err := c.call(command{ID: 1, Method: methodConnect, Params: map[string]string{"token": token}}); err != nil {
+		connect.fail()
+		c.close()
+		return nil, err
+	}
+	connect.observe(time.Since(started))
+
+	started = time.Now()
+	if err := c.call(command{ID: 2, Method: methodSubscribe, Params: map[string]string{"channel": o.channel(i)}}); err != nil {
+		subscribe.fail()
+		c.close()
+		return nil, err
+	}
+	subscribe.observe(time.Since(started))
+	return c, nil
+}
+
+func apiPublish(client *http.Client, o nodeOptions, ch string, data []byte) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"method": "publish",
+		"params": map[string]interface{}{"channel": ch, "data": json.RawMessage(data)},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, o.APIURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.APIKey != "" {
+		req.Header.Set("Authorization", "apikey "+o.APIKey)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respData, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	var r reply
+	if err := json.Unmarshal(respData, &r); err != nil {
+		return err
+	}
+	if r.Error != nil {
+		return fmt.Errorf("error %d: %s", r.Error.Code, r.Error.Message)
+	}
+	return nil
+}
+
+func runNode(o nodeOptions) error {
+	if o.Channels < 1 {
+		return errors.New("at least one channel required")
+	}
+	if o.ConnectRate < 1 {
+		return errors.New("connect rate must be positive")
+	}
+	connect := newRecorder("connect")
+	subscribe := newRecorder("subscribe")
+	publish := newRecorder("publish")
+	delivery := newRecorder("delivery")
+
+	var mu sync.Mutex
+	var clients []*benchClient
+	defer func() {
+		for _, c := range clients {
+			c.close()
+		}
+	}()
+
+	// Connect clients with limited rate.
+	interval := time.Second / time.Duration(o.ConnectRate)
+	var wg sync.WaitGroup
+	var lastErr error
+	for i := 0; i < o.Clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c, err := connectClient(o, i, connect, subscribe, delivery)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				lastErr = err
+				return
+			}
+			clients = append(clients, c)
+		}(i)
+		time.Sleep(interval)
+	}
+	wg.Wait()
+	if lastErr != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "some clients failed to connect, last error: %v\n", lastErr)
+	}
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	ctx, cancel := benchContext(o.Duration)
+	defer cancel()
+	started := time.Now()
+	runPublishers(ctx, o.PublishRate, o.Publishers, func(i int) {
+		publishStarted := time.Now()
+		if err := apiPublish(httpClient, o, o.channel(i), newPayload(o.PayloadSize)); err != nil {
+			publish.fail()
+			return
+		}
+		publish.observe(time.Since(publishStarted))
+	})
+	elapsed := time.Since(started)
+	time.Sleep(deliveryGrace)
+
+	report(os.Stdout, elapsed, connect, subscribe, publish, delivery)
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// recorder collects latencies of one operation.
+type recorder struct {
+	name string
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    int
+}
+
+func newRecorder(name string) *recorder {
+	return &recorder{name: name}
+}
+
+func (r *recorder) observe(d time.Duration) {
+	r.mu.Lock()
+	r.latencies = append(r.latencies, d)
+	r.mu.Unlock()
+}
+
+func (r *recorder) fail() {
+	r.mu.Lock()
+	r.errors++
+	r.mu.Unlock()
+}
+
+type summary struct {
+	Name   string
+	Count  int
+	Errors int
+	P50    time.Duration
+	P90    time.Duration
+	P99    time.Duration
+	P999   time.Duration
+	Max    time.Duration
+}
+
+func (r *recorder) summary() summary {
+	r.mu.Lock()
+	latencies := make([]time.Duration, len(r.latencies))
+	copy(latencies, r.latencies)
+	errors := r.errors
+	r.mu.Unlock()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	s := summary{Name: r.name, Count: len(latencies), Errors: errors}
+	if len(latencies) == 0 {
+		return s
+	}
+	s.P50 = percentile(latencies, 0.5)
+	s.P90 = percentile(latencies, 0.9)
+	s.P99 = percentile(latencies, 0.99)
+	s.P999 = percentile(latencies, 0.999)
+	s.Max = latencies[len(latencies)-1]
+	return s
+}
+
+// percentile of sorted latencies using nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	idx := int(float64(len(sorted))*p+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+func report(w io.Writer, elapsed time.Duration, recorders ...*recorder) {
+	_, _ = fmt.Fprintf(w, "elapsed: %s\n", elapsed.Round(time.Millisecond))
+	_, _ = fmt.Fprintf(w, "%-10s %10s %8s %10s %12s %12s %12s %12s %12s\n", "op", "count", "errors", "rate/s", "p50", "p90", "p99", "p99.9", "max")
+	for _, r := range recorders {
+		s := r.summary()
+		rate := float64(s.Count) / elapsed.Seconds()
+		_, _ = fmt.Fprintf(w, "%-10s %10d %8d %10.0f %12s %12s %12s %12s %12s\n", s.Name, s.Count, s.Errors, rate, s.P50, s.P90, s.P99, s.P999, s.Max)
+	}
+}